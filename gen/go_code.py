@@ -330,7 +330,9 @@ def __init__(self, line: str) -> None:
         self.struct_field_name = self.field[0].upper() + self.field[1:]
 
     def go_declaration(self) -> str:
-        return self.struct_field_name + ' ' + go_field_type(self.field_type) + f'`json:"{self.field},omitempty"`'
+        # zero is a meaningful value for floats, so it must not be omitted
+        omit = '' if self.field_type == 'float' else ',omitempty'
+        return self.struct_field_name + ' ' + go_field_type(self.field_type) + f'`json:"{self.field}{omit}"`'
 
 
 def go_code_for_remote_command(name: str, cmd: RemoteCommand, template: str) -> str:
@@ -420,6 +422,74 @@ def go_code_for_remote_command(name: str, cmd: RemoteCommand, template: str) ->
         STREAM_WANTED='true' if cmd.reads_streaming_data else 'false',
     )
     return ans
+
+
+rc_client_field_types: Dict[str, str] = {
+    'bool': 'bool', 'str': 'string', 'float': 'float64', 'int': 'int', 'scroll_amount': 'any', 'spacing': 'any', 'colors': 'any',
+}
+
+
+def rc_client_field_type(json_field_type: str) -> str:
+    q = rc_client_field_types.get(json_field_type)
+    if q:
+        return q
+    if json_field_type.startswith('choices.'):
+        return 'string'
+    if '.' in json_field_type:
+        p, r = json_field_type.split('.', 1)
+        p = {'list': '[]', 'dict': 'map[string]'}[p]
+        return p + rc_client_field_type(r)
+    raise TypeError(f'Unknown JSON field type: {json_field_type}')
+
+
+def go_code_for_rc_client(name: str, cmd: RemoteCommand) -> Iterator[str]:
+    type_name = ''.join(x.capitalize() for x in name.split('_'))
+    yield f'type {type_name}Payload struct {{'
+    has_data = False
+    for line in cmd.protocol_spec.splitlines():
+        line = line.strip()
+        if ':' not in line:
+            continue
+        f = JSONField(line)
+        field_name = ''.join(x.capitalize() for x in f.field.split('_'))
+        has_data = has_data or field_name == 'Data'
+        yield '// ' + line.partition(':')[2].strip()
+        yield f'{field_name} {rc_client_field_type(f.field_type)} `json:"{f.field},omitempty"`'
+    yield '}'
+    cli_name = name.replace('_', '-')
+    flags = [f'name: "{cli_name}"', 'payload: p', f'timeout: {int(cmd.response_timeout * 1000)} * time.Millisecond']
+    if cmd.is_asynchronous:
+        flags.append('async: true')
+    if cmd.string_return_is_error:
+        flags.append('string_response_is_err: true')
+    if cmd.disallow_responses:
+        flags.append('no_response: true')
+    yield f'// {cmd.short_desc}. See kitten @ {cli_name} --help for details.'
+    if cmd.reads_streaming_data and has_data:
+        yield '// The data is read from the specified reader and sent to kitty in chunks,'
+        yield '// use a nil reader to send the payload as is.'
+        yield f'func (self *Client) {type_name}(p *{type_name}Payload, data io.Reader) (*Response, error) {{'
+        yield f'c := command{{{", ".join(flags)}}}'
+        yield 'if data != nil { c.data, c.set_data = data, func(x string) { p.Data = x } }'
+        yield 'return self.run(&c)'
+    elif cmd.disallow_responses:
+        yield f'func (self *Client) {type_name}(p *{type_name}Payload) error {{'
+        yield f'_, err := self.run(&command{{{", ".join(flags)}}})'
+        yield 'return err'
+    else:
+        yield f'func (self *Client) {type_name}(p *{type_name}Payload) (*Response, error) {{'
+        yield f'return self.run(&command{{{", ".join(flags)}}})'
+    yield '}'
+    yield ''
+
+
+def update_rc_client() -> None:
+    with replace_if_needed('tools/rc/commands_generated.go'):
+        print('package rc')
+        print('import (\n"io"\n"time"\n)')
+        print('var _ io.Reader')
+        for name in all_command_names():
+            print('\n'.join(go_code_for_rc_client(name, command_for_name(name))))
 # }}}
 
 
@@ -435,9 +505,14 @@ def wrapped_kittens() -> Tuple[str, ...]:
     raise Exception('Failed to read wrapped kittens from kitty wrapper script')
 
 
+def go_package_for_kitten(kitten: str) -> str:
+    # Go keywords cannot be used as package names
+    return f'{kitten}_kitten' if kitten in ('switch',) else kitten
+
+
 def generate_conf_parser(kitten: str, defn: Definition) -> None:
     with replace_if_needed(f'kittens/{kitten}/conf_generated.go'):
-        print(f'package {kitten}')
+        print(f'package {go_package_for_kitten(kitten)}')
         print(gen_go_code(defn))
 
 
@@ -471,17 +546,19 @@ def kitten_clis() -> None:
         if ecp:
             for name, spec in ecp.items():
                 with replace_if_needed(f'kittens/{kitten}/{name}_cli_generated.go'):
-                    print(f'package {kitten}')
+                    print(f'package {go_package_for_kitten(kitten)}')
                     generate_extra_cli_parser(name, spec)
 
         with replace_if_needed(f'kittens/{kitten}/cli_generated.go'):
             od = []
             kcd = kitten_cli_docs(kitten)
             has_underscore = '_' in kitten
-            print(f'package {kitten}')
+            print(f'package {go_package_for_kitten(kitten)}')
             print('import "kitty/tools/cli"')
             print('func create_cmd(root *cli.Command, run_func func(*cli.Command, *Options, []string)(int, error)) {')
-            print('ans := root.AddSubCommand(&cli.Command{')
+            gopts, ac = go_options_for_kitten(kitten)
+            print('ans := ' if ac is not None or not kcd or has_underscore else '', end='')
+            print('root.AddLazySubCommand(&cli.Command{')
             print(f'Name: "{kitten}",')
             if kcd:
                 print(f'ShortDescription: "{serialize_as_go_string(kcd["short_desc"])}",')
@@ -495,11 +572,7 @@ def kitten_clis() -> None:
             print('return run_func(cmd, &opts, args)},')
             if has_underscore:
                 print('Hidden: true,')
-            print('})')
-            gopts, ac = go_options_for_kitten(kitten)
-            for opt in gopts:
-                print(opt.as_option('ans'))
-                od.append(opt.struct_declaration())
+            print('}, add_flags)')
             if ac is not None:
                 print(''.join(ac.as_go_code('ans.ArgCompleter', ' = ')))
             if not kcd:
@@ -509,6 +582,11 @@ def kitten_clis() -> None:
                 print('clone.Hidden = false')
                 print(f'clone.Name = "{serialize_as_go_string(kitten.replace("_", "-"))}"')
             print('}')
+            print('func add_flags(ans *cli.Command) {')
+            for opt in gopts:
+                print(opt.as_option('ans'))
+                od.append(opt.struct_declaration())
+            print('}')
             print('type Options struct {')
             print('\n'.join(od))
             print('}')
@@ -684,6 +762,7 @@ def update_at_commands() -> None:
 '''
     with replace_if_needed('tools/cmd/at/global_opts_generated.go') as f:
         f.write(code)
+    update_rc_client()
 
 
 def update_completion() -> None:
@@ -855,6 +934,39 @@ def normalize(t: tarfile.TarInfo) -> tarfile.TarInfo:
             write_compressed_data(buf.getvalue(), d)
 
 
+def generate_help_data() -> None:
+    from kitty.actions import get_all_actions, groups
+    from kitty.conf.types import MultiOption, expand_opt_references
+    from kitty.options.definition import definition
+    skip = {'changelog.rst', 'press-mentions.rst', 'intro_vid.rst'}
+    docs = []
+    for base in ('docs', 'docs/kittens'):
+        docs.extend(os.path.join(base, x) for x in sorted(os.listdir(base)) if x.endswith('.rst') and x not in skip)
+    sources = 'kitty/options/definition.py', 'kitty/window.py', 'kitty/tabs.py', 'kitty/boss.py', 'kitty/actions.py'
+    dest = 'kittens/help/data_generated.bin'
+    if not newer(dest, *docs, *sources):
+        return
+    titles = load_ref_map()['doc']
+    topics = []
+    for path in docs:
+        name = os.path.relpath(path, 'docs')[:-4].replace(os.sep, '/')
+        with open(path) as f:
+            topics.append({'name': f'doc:{name}', 'title': titles.get(name, name), 'text': f.read()})
+    for opt in definition.iter_all_options():
+        if not opt.long_text:
+            continue
+        defaults = [k.defval_as_str for k in opt.items] if isinstance(opt, MultiOption) else [opt.defval_as_string]
+        text = '\n'.join(f'    {opt.name} {d}'.rstrip() for d in defaults)
+        text = f'{opt.name}\n{"=" * len(opt.name)}\n\nDefault::\n\n{text}\n\n' + expand_opt_references('kitty', opt.long_text)
+        topics.append({'name': f'opt:kitty.{opt.name}', 'title': opt.name, 'text': text})
+    for group, actions in get_all_actions().items():
+        for ac in actions:
+            text = f'{ac.name}\n{"=" * len(ac.name)}\n\nGroup: {groups[group]}\n\n{ac.short_help}\n\n{ac.long_help}'
+            topics.append({'name': f'action:{ac.name}', 'title': ac.name, 'text': text})
+    with open(dest, 'wb') as d:
+        write_compressed_data(json.dumps(topics).encode(), d)
+
+
 def start_simdgen() -> 'subprocess.Popen[bytes]':
     return subprocess.Popen(['go', 'run', 'generate.go'], cwd='tools/simdstring', stdout=subprocess.PIPE, stderr=subprocess.PIPE)
 
@@ -877,6 +989,7 @@ def main(args: List[str]=sys.argv) -> None:
         with open('tools/unicode_names/data_generated.bin', 'wb') as dest, open('tools/unicode_names/names.txt') as src:
             generate_unicode_names(src, dest)
     generate_ssh_kitten_data()
+    generate_help_data()
 
     update_completion()
     update_at_commands()
@@ -345,7 +345,7 @@ def generate_functional_table() -> None:
     text += f'\nvar letter_trailer_to_csi_number_map = map[string]int{serialize_go_dict(letter_trailer_codes)}\n'
     tt = ', '.join(f'{x}: true' for x in tilde_trailers)
     text += '\nvar tilde_trailers = map[int]bool{' + f'{tt}' + '}\n'
-    patch_file('tools/tui/loop/key-encoding.go', 'csi mapping', text, start_marker='// ', end_marker='')
+    patch_file('tools/keys/keys.go', 'csi mapping', text, start_marker='// ', end_marker='')
 
 
 def generate_legacy_text_key_maps() -> None:
@@ -405,6 +405,8 @@ def generate_ctrl_mapping() -> None:
     lines.append('')
     patch_file('docs/keyboard-protocol.rst', 'ctrl mapping', '\n'.join(lines), start_marker='.. ', end_marker='')
     patch_file('kitty/key_encoding.c', 'ctrl mapping', '\n'.join(mi))
+    go = 'var ctrl_mapping = map[rune]rune{' + ', '.join(f'{ord(k)}: {v}' for k, v in sorted(ctrl_mapping.items())) + '}\n'
+    patch_file('tools/keys/encode.go', 'ctrl mapping', go, start_marker='// ', end_marker='')
 
 
 def generate_macos_mapping() -> None:
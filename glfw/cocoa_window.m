@@ -2316,6 +2316,13 @@ void _glfwPlatformSetWindowMousePassthrough(_GLFWwindow* window, bool enabled)
     [window->ns.object setIgnoresMouseEvents:enabled];
 }
 
+void _glfwPlatformSetWindowInputRegion(_GLFWwindow* window UNUSED, const int* rects UNUSED, unsigned int count)
+{
+    if (count)
+        _glfwInputError(GLFW_FEATURE_UNAVAILABLE,
+                        "Cocoa: Window input regions are not supported");
+}
+
 float _glfwPlatformGetWindowOpacity(_GLFWwindow* window)
 {
     return (float) [window->ns.object alphaValue];
@@ -1312,6 +1312,9 @@ typedef struct GLFWLayerShellConfig {
     const char *output_name;
     GLFWFocusPolicy focus_policy;
     unsigned size_in_cells;
+    unsigned requested_top_margin, requested_left_margin, requested_bottom_margin, requested_right_margin;
+    int requested_exclusive_zone;
+    unsigned override_exclusive_zone;
     void (*size_callback)(GLFWwindow *window, const struct GLFWLayerShellConfig *config, unsigned monitor_width, unsigned monitor_height, uint32_t *width, uint32_t *height);
 } GLFWLayerShellConfig;
 
@@ -3824,6 +3827,24 @@ GLFWAPI int glfwGetWindowAttrib(GLFWwindow* window, int attrib);
 GLFWAPI void glfwSetWindowAttrib(GLFWwindow* window, int attrib, int value);
 GLFWAPI int glfwSetWindowBlur(GLFWwindow* window, int value);
 
+/*! @brief Restricts mouse input for the specified window to a set of rectangles.
+ *
+ *  Mouse input outside the specified rectangles passes through the window to
+ *  whatever is below it. @a rects is an array of @a count rectangles, each
+ *  specified as four integers: x, y, width and height, in screen coordinates
+ *  relative to the top left corner of the window. A count of zero restores
+ *  the default where the entire window accepts input.
+ *
+ *  @param[in] window The window whose input region to set.
+ *  @param[in] rects The rectangles, or `NULL` if @a count is zero.
+ *  @param[in] count The number of rectangles.
+ *
+ *  @remark @macos Input regions are not supported.
+ *
+ *  @ingroup window
+ */
+GLFWAPI void glfwSetWindowInputRegion(GLFWwindow* window, const int* rects, unsigned int count);
+
 /*! @brief Sets the user pointer of the specified window.
  *
  *  This function sets the user-defined pointer of the specified window.  The
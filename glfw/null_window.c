@@ -391,6 +391,10 @@ void _glfwPlatformSetWindowMousePassthrough(_GLFWwindow* window UNUSED, bool ena
 {
 }
 
+void _glfwPlatformSetWindowInputRegion(_GLFWwindow* window UNUSED, const int* rects UNUSED, unsigned int count UNUSED)
+{
+}
+
 float _glfwPlatformGetWindowOpacity(_GLFWwindow* window)
 {
     return window->null.opacity;
@@ -1023,6 +1023,16 @@ GLFWAPI int glfwSetWindowBlur(GLFWwindow* handle, int value)
     return _glfwPlatformSetWindowBlur(window, value);
 }
 
+GLFWAPI void glfwSetWindowInputRegion(GLFWwindow* handle, const int* rects, unsigned int count)
+{
+    _GLFWwindow* window = (_GLFWwindow*) handle;
+    assert(window != NULL);
+    assert(rects != NULL || count == 0);
+
+    _GLFW_REQUIRE_INIT();
+    _glfwPlatformSetWindowInputRegion(window, rects, count);
+}
+
 
 GLFWAPI GLFWmonitor* glfwGetWindowMonitor(GLFWwindow* handle)
 {
@@ -996,8 +996,11 @@ layer_set_properties(_GLFWwindow *window) {
     if (window->wl.wp_viewport) wp_viewport_set_destination(window->wl.wp_viewport, window->wl.width, window->wl.height);
     debug("Compositor will be informed that layer size: %dx%d viewport: %dx%d at next surface commit\n", panel_width, panel_height, window->wl.width, window->wl.height);
     zwlr_layer_surface_v1_set_anchor(surface, which_anchor);
+#define c window->wl.layer_shell.config
+    if (c.override_exclusive_zone) exclusive_zone = c.requested_exclusive_zone;
     zwlr_layer_surface_v1_set_exclusive_zone(surface, exclusive_zone);
-    zwlr_layer_surface_v1_set_margin(surface, 0, 0, 0, 0);
+    zwlr_layer_surface_v1_set_margin(surface, c.requested_top_margin, c.requested_right_margin, c.requested_bottom_margin, c.requested_left_margin);
+#undef c
     zwlr_layer_surface_v1_set_keyboard_interactivity(surface, focus_policy);
 #undef surface
 }
@@ -1723,6 +1726,21 @@ void _glfwPlatformSetWindowMousePassthrough(_GLFWwindow* window, bool enabled)
     commit_window_surface_if_safe(window);
 }
 
+void _glfwPlatformSetWindowInputRegion(_GLFWwindow* window, const int* rects, unsigned int count)
+{
+    if (count)
+    {
+        struct wl_region* region = wl_compositor_create_region(_glfw.wl.compositor);
+        for (unsigned int i = 0; i < count; i++, rects += 4)
+            wl_region_add(region, rects[0], rects[1], rects[2], rects[3]);
+        wl_surface_set_input_region(window->wl.surface, region);
+        wl_region_destroy(region);
+    }
+    else
+        wl_surface_set_input_region(window->wl.surface, 0);
+    commit_window_surface_if_safe(window);
+}
+
 float _glfwPlatformGetWindowOpacity(_GLFWwindow* window UNUSED)
 {
     return 1.f;
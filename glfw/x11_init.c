@@ -151,6 +151,8 @@ static void detectEWMH(void)
         getAtomIfSupported(supportedAtoms, atomCount, "_NET_REQUEST_FRAME_EXTENTS");
     _glfw.x11.NET_WM_STRUT_PARTIAL =
         getAtomIfSupported(supportedAtoms, atomCount, "_NET_WM_STRUT_PARTIAL");
+    _glfw.x11.NET_WM_STRUT =
+        getAtomIfSupported(supportedAtoms, atomCount, "_NET_WM_STRUT");
 
     XFree(supportedAtoms);
 }
@@ -2624,6 +2624,31 @@ void _glfwPlatformSetWindowMousePassthrough(_GLFWwindow* window, bool enabled)
     }
 }
 
+void _glfwPlatformSetWindowInputRegion(_GLFWwindow* window, const int* rects, unsigned int count)
+{
+    if (!_glfw.x11.xshape.available)
+        return;
+
+    if (count)
+    {
+        Region region = XCreateRegion();
+        for (unsigned int i = 0; i < count; i++, rects += 4)
+        {
+            XRectangle r = {.x = rects[0], .y = rects[1], .width = rects[2], .height = rects[3]};
+            XUnionRectWithRegion(&r, region, region);
+        }
+        XShapeCombineRegion(_glfw.x11.display, window->x11.handle,
+                            ShapeInput, 0, 0, region, ShapeSet);
+        XDestroyRegion(region);
+    }
+    else
+    {
+        XShapeCombineMask(_glfw.x11.display, window->x11.handle,
+                          ShapeInput, 0, 0, None, ShapeSet);
+    }
+    XFlush(_glfw.x11.display);
+}
+
 float _glfwPlatformGetWindowOpacity(_GLFWwindow* window)
 {
     float opacity = 1.f;
@@ -3279,7 +3304,14 @@ GLFWAPI void glfwSetX11WindowAsDock(int32_t x11_window_id) {
 
 GLFWAPI void glfwSetX11WindowStrut(int32_t x11_window_id, uint32_t dimensions[12]) {
     _GLFW_REQUIRE_INIT();
-    XChangeProperty(_glfw.x11.display, x11_window_id,
-                    _glfw.x11.NET_WM_STRUT_PARTIAL, XA_CARDINAL, 32,
-                    PropModeReplace, (unsigned char*) dimensions, 12);
+    if (_glfw.x11.NET_WM_STRUT_PARTIAL)
+        XChangeProperty(_glfw.x11.display, x11_window_id,
+                        _glfw.x11.NET_WM_STRUT_PARTIAL, XA_CARDINAL, 32,
+                        PropModeReplace, (unsigned char*) dimensions, 12);
+    // Older window managers only understand _NET_WM_STRUT which is the
+    // first four values of _NET_WM_STRUT_PARTIAL
+    if (_glfw.x11.NET_WM_STRUT)
+        XChangeProperty(_glfw.x11.display, x11_window_id,
+                        _glfw.x11.NET_WM_STRUT, XA_CARDINAL, 32,
+                        PropModeReplace, (unsigned char*) dimensions, 4);
 }
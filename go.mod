@@ -11,12 +11,15 @@ require (
 	github.com/google/go-cmp v0.6.0
 	github.com/google/uuid v1.6.0
 	github.com/kovidgoyal/imaging v1.6.3
+	github.com/makiuchi-d/gozxing v0.1.1
 	github.com/seancfoley/ipaddress-go v1.5.5
 	github.com/shirou/gopsutil/v3 v3.24.3
 	github.com/zeebo/xxh3 v1.0.2
+	go.starlark.net v0.0.0-20240411212711-9b43f0afd521
 	golang.org/x/exp v0.0.0-20230801115018-d63ba01acd4b
 	golang.org/x/image v0.15.0
 	golang.org/x/sys v0.19.0
+	golang.org/x/text v0.14.0
 	howett.net/plist v1.0.1
 )
 
@@ -32,4 +35,5 @@ require (
 	github.com/tklauser/go-sysconf v0.3.12 // indirect
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
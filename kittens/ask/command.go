@@ -0,0 +1,30 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ask
+
+import (
+	"fmt"
+
+	"kitty/tools/cli"
+	"kitty/tools/utils/shlex"
+)
+
+var _ = fmt.Print
+
+// Complete executables from PATH for the first word of the command line and
+// files for the rest
+func command_completer(before_cursor, after_cursor string) *cli.Completions {
+	ans := cli.NewCompletions()
+	argv, position_of_last_arg := shlex.SplitForCompletion(before_cursor)
+	word := ""
+	if len(argv) > 0 {
+		word = argv[len(argv)-1]
+	}
+	if len(argv) < 2 {
+		cli.CompleteExecutableFirstArg(ans, word, 1)
+	} else {
+		cli.FnmatchCompleter("Files", cli.CWD, "*")(ans, word, len(argv)-1)
+	}
+	ans.CurrentWordIdx = position_of_last_arg
+	return ans
+}
@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"kitty/tools/cli"
+	"kitty/tools/dbus"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+func in_sandbox() bool {
+	if _, err := os.Stat("/.flatpak-info"); err == nil {
+		return true
+	}
+	return os.Getenv("SNAP") != ""
+}
+
+func file_completer(before_cursor, after_cursor string) *cli.Completions {
+	ans := cli.NewCompletions()
+	cli.FnmatchCompleter("Files", cli.CWD, "*")(ans, before_cursor, 0)
+	for _, g := range ans.Groups {
+		// the whole line is a single path, so no spaces between matches
+		g.NoTrailingSpace = true
+	}
+	return ans
+}
+
+// The portal identifier for the window we are running in, if known
+func parent_window() string {
+	if os.Getenv("WAYLAND_DISPLAY") == "" {
+		if wid, err := strconv.ParseUint(os.Getenv("WINDOWID"), 10, 64); err == nil {
+			return fmt.Sprintf("x11:%x", wid)
+		}
+	}
+	return ""
+}
+
+func choose_file_natively(o *Options) (string, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	title := o.Message
+	if title == "" {
+		title = "Choose a file"
+	}
+	cwd, _ := os.Getwd()
+	paths, err := conn.ChooseFiles(context.Background(), dbus.FileChooserOptions{Title: title, CurrentFolder: cwd, ParentWindow: parent_window()})
+	if err != nil {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+func get_file(o *Options) (ans string, err error) {
+	if o.NativeDialog == "always" || (o.NativeDialog == "auto" && in_sandbox()) {
+		if ans, err = choose_file_natively(o); err == nil || errors.Is(err, dbus.ErrCanceled) {
+			return ans, nil
+		}
+		if o.NativeDialog == "always" {
+			return "", fmt.Errorf("Failed to use the file chooser dialog with error: %w", err)
+		}
+	}
+	show_message(o.Message)
+	if ans, err = get_line(o); err == nil && ans != "" {
+		ans = utils.Abspath(utils.Expanduser(ans))
+	}
+	return
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"kitty/tools/cli"
 	"kitty/tools/tui/loop"
 	"kitty/tools/tui/readline"
 	"kitty/tools/utils"
@@ -23,12 +24,25 @@ func get_line(o *Options) (result string, err error) {
 	}
 	cwd, _ := os.Getwd()
 	ropts := readline.RlInit{Prompt: o.Prompt}
+	var rl *readline.Readline
+	switch o.Type {
+	case "file":
+		ropts.Completer = file_completer
+	case "command":
+		ropts.Completer = func(before_cursor, after_cursor string) *cli.Completions {
+			ans := command_completer(before_cursor, after_cursor)
+			history := rl.HistoryCompleter(before_cursor, after_cursor)
+			for _, group := range history.Groups {
+				ans.MergeMatchGroup(group)
+			}
+			return ans
+		}
+	}
 	if o.Name != "" {
-		base := filepath.Join(utils.CacheDir(), "ask")
-		ropts.HistoryPath = filepath.Join(base, o.Name+".history.json")
-		os.MkdirAll(base, 0o755)
+		ropts.HistoryPath = utils.StatePath(filepath.Join("ask", o.Name+".history.json"))
+		os.MkdirAll(filepath.Dir(ropts.HistoryPath), 0o755)
 	}
-	rl := readline.New(lp, ropts)
+	rl = readline.New(lp, ropts)
 	if o.Default != "" {
 		rl.SetText(o.Default)
 	}
@@ -54,6 +54,20 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 		if err != nil {
 			return 1, err
 		}
+	case "command":
+		if o.Name == "" {
+			o.Name = "command"
+		}
+		show_message(o.Message)
+		result.Response, err = get_line(o)
+		if err != nil {
+			return 1, err
+		}
+	case "file":
+		result.Response, err = get_file(o)
+		if err != nil {
+			return 1, err
+		}
 	default:
 		return 1, fmt.Errorf("Unknown type: %s", o.Type)
 	}
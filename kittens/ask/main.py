@@ -15,9 +15,14 @@
 def option_text() -> str:
     return '''\
 --type -t
-choices=line,yesno,choices,password
+choices=line,yesno,choices,password,file,command
 default=line
-Type of input. Defaults to asking for a line of text.
+Type of input. Defaults to asking for a line of text. The :code:`file` type asks
+for the path to a file, with completion, see :option:`--native-dialog`. The
+response is the absolute path to the file. The :code:`command` type asks for a
+command line, with completion of executables in :envvar:`PATH` for the first word
+and of files for the rest, as well as history, see :option:`--name`. The response
+is the command line as typed.
 
 
 --message -m
@@ -55,7 +60,15 @@ def option_text() -> str:
 
 --prompt -p
 default="> "
-The prompt to use when inputting a line of text or a password.
+The prompt to use when inputting a line of text, a password or a file path.
+
+
+--native-dialog
+choices=auto,always,never
+default=auto
+Whether to use the file chooser dialog from the XDG Desktop Portal for the
+:code:`file` type, instead of asking in the terminal. With :code:`auto` the dialog
+is used only when running in a sandbox such as flatpak, if a portal is available.
 
 
 --unhide-key
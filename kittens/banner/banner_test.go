@@ -0,0 +1,60 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package banner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestBannerGradient(t *testing.T) {
+	g, err := parse_gradient("#000000, #ffffff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(pos float64, expected uint8) {
+		if r, gr, b := g.at(pos); r != expected || gr != expected || b != expected {
+			t.Fatalf("Color at %v is (%d, %d, %d) instead of %d", pos, r, gr, b, expected)
+		}
+	}
+	check(0, 0)
+	check(0.5, 128)
+	check(1, 255)
+	check(2, 255)
+	if _, err = parse_gradient(" , "); err == nil {
+		t.Fatalf("Empty gradient did not fail")
+	}
+	if _, err = parse_gradient("red,notacolor"); err == nil {
+		t.Fatalf("Invalid color did not fail")
+	}
+}
+
+func TestBannerRendering(t *testing.T) {
+	f, err := load_font("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, _ := parse_gradient("red")
+	for _, rows := range []int{4, 8} {
+		mask, err := rasterize_for_unicode(f, "kitty", rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimRight(as_unicode(mask, g, false), "\n"), "\n")
+		if len(lines) > rows+1 || len(lines) < rows/2 {
+			t.Fatalf("Rendering with %d rows produced %d lines", rows, len(lines))
+		}
+		mask, _ = rasterize_for_unicode(f, "a\nb", rows)
+		if lines := strings.Split(strings.TrimRight(as_unicode(mask, g, false), "\n"), "\n"); len(lines) <= rows {
+			t.Fatalf("Rendering two lines of text with %d rows produced only %d lines", rows, len(lines))
+		}
+	}
+	face, _ := new_face(f, 32)
+	img := colorize(crop(rasterize(face, "x"), 1), g, false)
+	if b := img.Bounds(); b.Dx() == 0 || b.Dy() == 0 || b.Dy() > line_height(face) {
+		t.Fatalf("Unexpected image size: %v", b)
+	}
+}
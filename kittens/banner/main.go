@@ -0,0 +1,143 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package banner
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"kitty/kittens/icat"
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	"kitty/tools/tui/graphics"
+	"kitty/tools/utils/images"
+)
+
+var _ = fmt.Print
+
+func as_png(img image.Image) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := images.Encode(&buf, img, "image/png"); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func write_graphics(img image.Image) (err error) {
+	data, err := as_png(img)
+	if err != nil {
+		return err
+	}
+	gc := graphics.GraphicsCommand{}
+	gc.SetAction(graphics.GRT_action_transmit_and_display).SetFormat(graphics.GRT_format_png).SetQuiet(graphics.GRT_quiet_silent)
+	// shrink the banner to fit if it is wider than the screen
+	if sz, err := tty.GetSize(int(os.Stdout.Fd())); err == nil && sz.Col > 0 && sz.Xpixel > 0 {
+		cell_width := max(1, int(sz.Xpixel)/int(sz.Col))
+		if cols := (img.Bounds().Dx() + cell_width - 1) / cell_width; cols > int(sz.Col) {
+			gc.SetColumns(uint64(sz.Col))
+		}
+	}
+	if err = gc.WriteWithPayloadTo(os.Stdout, data); err == nil {
+		_, err = os.Stdout.WriteString("\n")
+	}
+	return
+}
+
+func write_unicode(opts *Options, text string, g gradient) (err error) {
+	f, err := load_font(opts.Font)
+	if err != nil {
+		return err
+	}
+	rows := max(1, opts.UnicodeRows)
+	mask, err := rasterize_for_unicode(f, text, rows)
+	if err != nil {
+		return err
+	}
+	// shrink the banner to fit if it is wider than the screen
+	if sz, serr := tty.GetSize(int(os.Stdout.Fd())); serr == nil && sz.Col > 0 && mask.Bounds().Dx() > int(sz.Col) {
+		if rows = rows * int(sz.Col) / mask.Bounds().Dx(); rows > 0 {
+			if mask, err = rasterize_for_unicode(f, text, rows); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = os.Stdout.WriteString(as_unicode(mask, g, opts.Direction == "vertical"))
+	return
+}
+
+func render_image(opts *Options, text string, g gradient) (image.Image, error) {
+	f, err := load_font(opts.Font)
+	if err != nil {
+		return nil, err
+	}
+	face, err := new_face(f, max(1, opts.FontSize))
+	if err != nil {
+		return nil, err
+	}
+	return colorize(crop(rasterize(face, text), 1), g, opts.Direction == "vertical"), nil
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	text := strings.Join(args, " ")
+	if len(args) == 0 {
+		if tty.IsTerminal(os.Stdin.Fd()) {
+			return 1, fmt.Errorf("STDIN is a terminal and no text specified. See --help")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return 1, err
+		}
+		text = strings.TrimRight(string(data), "\r\n")
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	g, err := parse_gradient(opts.Gradient)
+	if err != nil {
+		return 1, err
+	}
+	if opts.Output != "" {
+		img, err := render_image(opts, text, g)
+		if err != nil {
+			return 1, err
+		}
+		data, err := as_png(img)
+		if err == nil {
+			if opts.Output == "-" {
+				_, err = os.Stdout.Write(data)
+			} else {
+				err = os.WriteFile(opts.Output, data, 0o644)
+			}
+		}
+		if err != nil {
+			return 1, err
+		}
+		return 0, nil
+	}
+	use_graphics := opts.Mode == "graphics"
+	if opts.Mode == "auto" && tty.IsTerminal(os.Stdout.Fd()) {
+		_, _, direct, derr := icat.DetectSupport(2 * time.Second)
+		use_graphics = derr == nil && direct
+	}
+	if use_graphics {
+		img, err := render_image(opts, text, g)
+		if err == nil {
+			err = write_graphics(img)
+		}
+		if err != nil {
+			return 1, err
+		}
+		return 0, nil
+	}
+	if err = write_unicode(opts, text, g); err != nil {
+		return 1, err
+	}
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,71 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+OPTIONS = r'''
+--font
+completion=type:file ext:ttf,otf group:"Font files"
+Path to a TrueType or OpenType font file to render the text with. Defaults to
+a built-in bold sans-serif font.
+
+
+--font-size
+type=float
+default=64
+The size of the font in pixels, when displaying the banner as an image.
+
+
+--gradient
+default=#ff5f87,#5fafff
+A comma separated list of colors to fill the text with, as a gradient. Use a
+single color for no gradient. Colors can be specified as names or in the
+:code:`#rrggbb` format.
+
+
+--direction
+default=horizontal
+choices=horizontal,vertical
+The direction of the gradient.
+
+
+--mode
+default=auto
+choices=auto,graphics,unicode
+How to display the banner. :code:`graphics` uses the kitty graphics protocol,
+:code:`unicode` uses Unicode block characters that work in any terminal.
+:code:`auto` uses graphics if the terminal supports it.
+
+
+--unicode-rows
+type=int
+default=8
+The height of the banner in lines when displaying it using Unicode block
+characters.
+
+
+--output -o
+Write the banner as a PNG image to the specified file instead of displaying
+it in the terminal. Use :code:`-` to write to STDOUT.
+'''.format
+help_text = '''\
+Display the specified text as a large, styled banner in the terminal, for
+example, in a message of the day or in presentations. If no text is specified,
+it is read from STDIN. Multiple lines of text are supported.
+'''
+usage = '[text ...]'
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten banner')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Display text as a large banner'
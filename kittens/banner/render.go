@@ -0,0 +1,194 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package banner
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"kitty/tools/utils"
+	"kitty/tools/utils/style"
+)
+
+var _ = fmt.Print
+
+func load_font(path string) (*opentype.Font, error) {
+	data := gobold.TTF
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(utils.Expanduser(path)); err != nil {
+			return nil, err
+		}
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the font from %s with error: %w", path, err)
+	}
+	return f, nil
+}
+
+func new_face(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func line_height(face font.Face) int {
+	m := face.Metrics()
+	return (m.Ascent + m.Descent).Ceil()
+}
+
+// Render the text as an alpha mask, with each line centered
+func rasterize(face font.Face, text string) *image.Alpha {
+	lines := strings.Split(text, "\n")
+	lh := line_height(face)
+	widths := make([]int, len(lines))
+	width := 1
+	for i, line := range lines {
+		widths[i] = font.MeasureString(face, line).Ceil()
+		width = max(width, widths[i])
+	}
+	img := image.NewAlpha(image.Rect(0, 0, width, max(1, lh*len(lines))))
+	d := font.Drawer{Dst: img, Src: image.Opaque, Face: face}
+	ascent := face.Metrics().Ascent
+	for i, line := range lines {
+		d.Dot = fixed.Point26_6{X: fixed.I((width - widths[i]) / 2), Y: fixed.I(i*lh) + ascent}
+		d.DrawString(line)
+	}
+	return img
+}
+
+// Remove the empty space around the text, pixels with alpha below threshold
+// are considered empty
+func crop(mask *image.Alpha, threshold uint8) *image.Alpha {
+	b := mask.Bounds()
+	ans := image.Rectangle{Min: b.Max, Max: b.Min}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if mask.AlphaAt(x, y).A >= threshold {
+				ans = ans.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if ans.Empty() {
+		return mask
+	}
+	return mask.SubImage(ans).(*image.Alpha)
+}
+
+type gradient []style.RGBA
+
+func parse_gradient(spec string) (ans gradient, err error) {
+	for _, x := range strings.Split(spec, ",") {
+		if x = strings.TrimSpace(x); x == "" {
+			continue
+		}
+		c, err := style.ParseColor(x)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid color", x)
+		}
+		ans = append(ans, c)
+	}
+	if len(ans) == 0 {
+		return nil, fmt.Errorf("No colors specified for the gradient")
+	}
+	return
+}
+
+// The color at t in [0, 1] along the gradient
+func (self gradient) at(t float64) (r, g, b uint8) {
+	if len(self) == 1 {
+		return self[0].Red, self[0].Green, self[0].Blue
+	}
+	t = max(0, min(t, 1)) * float64(len(self)-1)
+	idx := min(int(t), len(self)-2)
+	frac := t - float64(idx)
+	a, z := self[idx], self[idx+1]
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*frac + 0.5) }
+	return mix(a.Red, z.Red), mix(a.Green, z.Green), mix(a.Blue, z.Blue)
+}
+
+func (self gradient) at_pos(x, y int, bounds image.Rectangle, vertical bool) (r, g, b uint8) {
+	if vertical {
+		return self.at(float64(y-bounds.Min.Y) / float64(max(1, bounds.Dy()-1)))
+	}
+	return self.at(float64(x-bounds.Min.X) / float64(max(1, bounds.Dx()-1)))
+}
+
+// Fill the mask with the gradient
+func colorize(mask *image.Alpha, g gradient, vertical bool) *image.NRGBA {
+	b := mask.Bounds()
+	ans := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if a := mask.AlphaAt(x, y).A; a > 0 {
+				r, gr, bl := g.at_pos(x, y, b, vertical)
+				ans.SetNRGBA(x, y, color.NRGBA{r, gr, bl, a})
+			}
+		}
+	}
+	return ans
+}
+
+// Render the mask using Unicode half block characters, each character cell
+// represents two vertically stacked pixels
+func as_unicode(mask *image.Alpha, g gradient, vertical bool) string {
+	b := mask.Bounds()
+	is_set := func(x, y int) bool { return y < b.Max.Y && mask.AlphaAt(x, y).A >= 128 }
+	buf := strings.Builder{}
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		last := -1
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if is_set(x, y) || is_set(x, y+1) {
+				last = x
+			}
+		}
+		current := ""
+		for x := b.Min.X; x <= last; x++ {
+			top, bottom := is_set(x, y), is_set(x, y+1)
+			if !top && !bottom {
+				buf.WriteByte(' ')
+				continue
+			}
+			r, gr, bl := g.at_pos(x, y, b, vertical)
+			if c := fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, gr, bl); c != current {
+				buf.WriteString(c)
+				current = c
+			}
+			switch {
+			case top && bottom:
+				buf.WriteString("█")
+			case top:
+				buf.WriteString("▀")
+			default:
+				buf.WriteString("▄")
+			}
+		}
+		if current != "" {
+			buf.WriteString("\x1b[39m")
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Rasterize text so that each line of text is rows cells high when displayed
+// with as_unicode()
+func rasterize_for_unicode(f *opentype.Font, text string, rows int) (*image.Alpha, error) {
+	const reference_size = 100
+	face, err := new_face(f, reference_size)
+	if err != nil {
+		return nil, err
+	}
+	size := reference_size * float64(2*rows) / float64(line_height(face))
+	if face, err = new_face(f, size); err != nil {
+		return nil, err
+	}
+	return crop(rasterize(face, text), 128), nil
+}
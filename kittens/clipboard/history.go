@@ -0,0 +1,195 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Text larger than this is not recorded in the history
+const history_max_entry_size = 1024 * 1024
+
+const history_file_magic = "kitty-clipboard-history-1\n"
+
+// Copied text matching any of these is never recorded in the history
+var builtin_secret_patterns = []string{
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----`,
+	`\bAKIA[0-9A-Z]{16}\b`,
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	`\bgithub_pat_[A-Za-z0-9_]{40,}\b`,
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	`\bsk_live_[0-9A-Za-z]{16,}\b`,
+}
+
+type history_entry struct {
+	Text     string    `json:"text"`
+	CopiedAt time.Time `json:"copied_at"`
+}
+
+// The history of copied text, most recent first, stored encrypted with a
+// key kept in the keyring of the operating system, so that the history
+// cannot be read from backups of it or found by tools searching files. When
+// no keyring is available the key is kept in a file next to the history
+// instead, which only protects it from tools searching files. In neither
+// case is it protected from programs running as the user.
+type history struct {
+	path, key_path string
+	keyring        keyring
+	max_entries    int
+	secrets        []*regexp.Regexp
+	entries        []history_entry
+}
+
+// The history of the primary selection is kept separately
+func history_path(use_primary bool) string {
+	return utils.StatePath(utils.IfElse(use_primary, "clipboard-history-primary", "clipboard-history"))
+}
+
+func new_history(path string, max_entries int, ignore_patterns []string) (*history, error) {
+	ans := &history{path: path, key_path: path + ".key", keyring: system_keyring(), max_entries: max(1, max_entries)}
+	for _, pat := range slices.Concat(builtin_secret_patterns, ignore_patterns) {
+		r, err := regexp.Compile(pat)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression for ignoring clipboard contents: %#v with error: %w", pat, err)
+		}
+		ans.secrets = append(ans.secrets, r)
+	}
+	return ans, nil
+}
+
+// A key file is used if present, as it is created only when there is no keyring
+func (self *history) read_key() ([]byte, error) {
+	key, err := os.ReadFile(self.key_path)
+	if errors.Is(err, fs.ErrNotExist) && self.keyring != nil {
+		key, err = self.keyring.get(self.path)
+	}
+	return key, err
+}
+
+func (self *history) create_key() (key []byte, err error) {
+	key = make([]byte, 32)
+	if _, err = rand.Read(key); err != nil {
+		return nil, err
+	}
+	if self.keyring != nil {
+		if err = self.keyring.set(self.path, key); err == nil {
+			return key, nil
+		}
+		// no usable keyring, for example, in a session without a Secret Service
+	}
+	if err = os.MkdirAll(filepath.Dir(self.key_path), 0o700); err != nil {
+		return nil, err
+	}
+	return key, utils.AtomicWriteFile(self.key_path, key, 0o600)
+}
+
+func (self *history) cipher(create_key bool) (cipher.AEAD, error) {
+	key, err := self.read_key()
+	if errors.Is(err, fs.ErrNotExist) && create_key {
+		key, err = self.create_key()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("The clipboard history key is corrupted")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Load the stored history, a history that does not exist is empty
+func (self *history) load() error {
+	data, err := os.ReadFile(self.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			self.entries = nil
+			return nil
+		}
+		return err
+	}
+	data, found := bytes.CutPrefix(data, []byte(history_file_magic))
+	if !found {
+		return fmt.Errorf("%s is not a clipboard history file", self.path)
+	}
+	aead, err := self.cipher(false)
+	if err != nil {
+		return fmt.Errorf("Failed to read the key for the clipboard history with error: %w", err)
+	}
+	if len(data) < aead.NonceSize() {
+		return fmt.Errorf("The clipboard history in %s is corrupted", self.path)
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(history_file_magic))
+	if err != nil {
+		return fmt.Errorf("Failed to decrypt the clipboard history in %s, it is either corrupted or the key was changed", self.path)
+	}
+	var entries []history_entry
+	if err = json.Unmarshal(plaintext, &entries); err != nil {
+		return fmt.Errorf("The clipboard history in %s is corrupted", self.path)
+	}
+	self.entries = entries
+	return nil
+}
+
+func (self *history) save() error {
+	aead, err := self.cipher(true)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(self.entries)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	data := append([]byte(history_file_magic), nonce...)
+	return utils.AtomicWriteFile(self.path, aead.Seal(data, nonce, plaintext, []byte(history_file_magic)), 0o600)
+}
+
+func (self *history) is_secret(text string) bool {
+	for _, r := range self.secrets {
+		if r.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add text as the most recent entry, moving it to the front if it is
+// already present. Returns false if text is not recorded, because it is
+// empty, too large, looks like a secret or is already the most recent entry.
+func (self *history) add(text string, now time.Time) bool {
+	if text == "" || len(text) > history_max_entry_size || self.is_secret(text) {
+		return false
+	}
+	if len(self.entries) > 0 && self.entries[0].Text == text {
+		return false
+	}
+	self.entries = utils.Filter(self.entries, func(e history_entry) bool { return e.Text != text })
+	self.entries = append([]history_entry{{Text: text, CopiedAt: now}}, self.entries...)
+	if len(self.entries) > self.max_entries {
+		self.entries = self.entries[:self.max_entries]
+	}
+	return true
+}
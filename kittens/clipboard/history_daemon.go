@@ -0,0 +1,144 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"kitty/tools/tui"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type history_daemon struct {
+	lp             *loop.Loop
+	opts           *Options
+	history        *history
+	reading        bool
+	read_data      []byte
+	read_too_large bool
+	// the hash of the clipboard contents last read, so that unchanged contents
+	// are not processed and saved again every tick
+	last_read_hash [sha256.Size]byte
+}
+
+// Never log the copied text itself, the window running the daemon could
+// be visible to others or its scrollback saved
+func (self *history_daemon) log(format string, args ...any) {
+	self.lp.Println(time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func (self *history_daemon) tick() {
+	if self.reading {
+		return
+	}
+	metadata := map[string]string{"type": "read"}
+	if self.opts.UsePrimary {
+		metadata["loc"] = "primary"
+	}
+	self.reading, self.read_data, self.read_too_large = true, nil, false
+	self.lp.QueueWriteString(EncodeEscapeCode(metadata, []byte("text/plain")))
+}
+
+func (self *history_daemon) on_text(text string) {
+	if !self.history.add(text, time.Now()) {
+		return
+	}
+	if err := self.history.save(); err != nil {
+		self.log("Failed to save the clipboard history: %s", err)
+		return
+	}
+	self.log("Recorded %d bytes, the history has %d entries", len(text), len(self.history.entries))
+}
+
+func (self *history_daemon) on_escape_code(etype loop.EscapeCodeType, data []byte) error {
+	metadata, payload, err := ParseEscapeCode(etype, data)
+	if err != nil || metadata == nil || !self.reading || metadata["type"] != "read" {
+		return err
+	}
+	switch status := metadata["status"]; status {
+	case "OK":
+	case "DATA":
+		if metadata["mime"] == "text/plain" && !self.read_too_large {
+			self.read_data = append(self.read_data, payload...)
+			if len(self.read_data) > history_max_entry_size {
+				self.read_data, self.read_too_large = nil, true
+			}
+		}
+	case "DONE":
+		self.reading = false
+		if !self.read_too_large {
+			if h := sha256.Sum256(self.read_data); h != self.last_read_hash {
+				self.last_read_hash = h
+				self.on_text(string(self.read_data))
+			}
+		}
+		self.read_data = nil
+	default:
+		self.reading = false
+		if status == "EPERM" {
+			which := utils.IfElse(self.opts.UsePrimary, "read-primary", "read-clipboard")
+			return fmt.Errorf("Permission to read the clipboard was denied, add %s to clipboard_control in kitty.conf", which)
+		}
+		self.log("Failed to read the clipboard: %s", status)
+	}
+	return nil
+}
+
+func run_history_daemon(opts *Options) (err error) {
+	if m := tui.DetectMultiplexer(); m != tui.NoMultiplexer {
+		return tui.ResponsesNotSupportedError("Recording the clipboard history", m)
+	}
+	h, err := new_history(history_path(opts.UsePrimary), opts.HistorySize, opts.HistoryIgnore)
+	if err != nil {
+		return err
+	}
+	instance, err := utils.SingleInstance(filepath.Base(h.path))
+	if err != nil {
+		return err
+	}
+	if !instance.IsPrimary {
+		return fmt.Errorf("The clipboard history is already being recorded by the process with pid: %d", instance.Pid)
+	}
+	defer instance.Close()
+	if err = h.load(); err != nil {
+		return err
+	}
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return err
+	}
+	self := history_daemon{lp: lp, opts: opts, history: h}
+	lp.OnInitialize = func() (string, error) {
+		lp.SetWindowTitle("Clipboard history")
+		self.log("Recording the clipboard history in %s, press Ctrl+C to quit", h.path)
+		_, err := lp.AddTimer(time.Second, true, func(loop.IdType) error {
+			self.tick()
+			return nil
+		})
+		self.tick()
+		return "", err
+	}
+	lp.OnEscapeCode = self.on_escape_code
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") || event.MatchesPressOrRepeat("q") {
+			event.Handled = true
+			lp.Quit(0)
+		}
+		return nil
+	}
+	if err = lp.Run(); err != nil {
+		return err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+	}
+	return
+}
@@ -0,0 +1,92 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var _ = fmt.Print
+
+const keyring_service = "kitty-clipboard-history"
+
+// Stores the key used to encrypt the clipboard history in the keyring of the
+// operating system. get returns fs.ErrNotExist if there is no key.
+type keyring interface {
+	get(account string) ([]byte, error)
+	set(account string, key []byte) error
+}
+
+// The macOS Keychain via the security command
+type keychain struct{ exe string }
+
+func (self keychain) get(account string) ([]byte, error) {
+	out, err := exec.Command(self.exe, "find-generic-password", "-s", keyring_service, "-a", account, "-w").Output()
+	if err != nil {
+		var eerr *exec.ExitError
+		// 44 is errSecItemNotFound
+		if errors.As(err, &eerr) && eerr.ExitCode() == 44 {
+			return nil, fs.ErrNotExist
+		}
+		return nil, fmt.Errorf("Failed to read the clipboard history key from the keychain with error: %w", err)
+	}
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (self keychain) set(account string, key []byte) error {
+	if strings.ContainsAny(account, "'\\\n") {
+		return fmt.Errorf("Cannot store the clipboard history key in the keychain for the path: %s", account)
+	}
+	// the key is passed via STDIN so that it is not visible in the process list
+	cmd := exec.Command(self.exe, "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a '%s' -w %s\n", keyring_service, account, hex.EncodeToString(key)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Failed to store the clipboard history key in the keychain with error: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// The Secret Service, such as GNOME Keyring or KWallet, via secret-tool
+type secret_service struct{ exe string }
+
+func (self secret_service) get(account string) ([]byte, error) {
+	out, err := exec.Command(self.exe, "lookup", "service", keyring_service, "account", account).Output()
+	if err != nil {
+		var eerr *exec.ExitError
+		// secret-tool fails without output when there is no matching secret
+		if errors.As(err, &eerr) && len(out) == 0 && len(bytes.TrimSpace(eerr.Stderr)) == 0 {
+			return nil, fs.ErrNotExist
+		}
+		return nil, fmt.Errorf("Failed to read the clipboard history key from the Secret Service with error: %w", err)
+	}
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (self secret_service) set(account string, key []byte) error {
+	cmd := exec.Command(self.exe, "store", "--label=kitty clipboard history", "service", keyring_service, "account", account)
+	cmd.Stdin = strings.NewReader(hex.EncodeToString(key))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Failed to store the clipboard history key in the Secret Service with error: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// The keyring of the operating system or nil if none is available.
+// Overridable for testing.
+var system_keyring = func() keyring {
+	if runtime.GOOS == "darwin" {
+		if exe, err := exec.LookPath("security"); err == nil {
+			return keychain{exe}
+		}
+	} else if exe, err := exec.LookPath("secret-tool"); err == nil {
+		return secret_service{exe}
+	}
+	return nil
+}
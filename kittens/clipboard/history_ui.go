@@ -0,0 +1,247 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+
+	"kitty/tools/tui"
+	"kitty/tools/tui/loop"
+	"kitty/tools/tui/readline"
+	"kitty/tools/tui/subseq"
+	"kitty/tools/utils/humanize"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+// Only the start of large entries is displayed and searched
+const history_summary_size = 512
+
+type history_picker struct {
+	lp          *loop.Loop
+	opts        *Options
+	multiplexer tui.Multiplexer
+	all         []history_entry
+	summaries   []string
+
+	search_rl     *readline.Readline
+	matches       []int
+	matcher       *subseq.Matcher
+	current_idx   int
+	scroll_offset int
+	screen_width  int
+	screen_height int
+}
+
+func summarize_history_entry(text string) string {
+	if len(text) > history_summary_size {
+		text = strings.ToValidUTF8(text[:history_summary_size], "")
+	}
+	return strings.Join(strings.Fields(strings.ReplaceAll(text, "\n", " ⏎ ")), " ")
+}
+
+func (self *history_picker) initialize() (string, error) {
+	self.lp.SetWindowTitle("Clipboard history")
+	self.lp.AllowLineWrapping(false)
+	self.update_matches()
+	self.draw_screen()
+	self.lp.SendOverlayReady()
+	return "", nil
+}
+
+func (self *history_picker) finalize() string {
+	self.search_rl.Shutdown()
+	return ""
+}
+
+func (self *history_picker) update_matches() {
+	q := self.search_rl.AllText()
+	self.matches = self.matches[:0]
+	if q == "" {
+		for i := range self.all {
+			self.matches = append(self.matches, i)
+		}
+	} else {
+		if self.matcher == nil {
+			self.matcher = subseq.NewMatcher(self.summaries, subseq.Options{})
+		}
+		scores := self.matcher.Score(q)
+		for i, m := range scores {
+			if m.Score > 0 {
+				self.matches = append(self.matches, i)
+			}
+		}
+		// more recent entries win ties
+		slices.SortStableFunc(self.matches, func(a, b int) int { return cmp.Compare(scores[b].Score, scores[a].Score) })
+	}
+	self.current_idx = max(0, min(self.current_idx, len(self.matches)-1))
+}
+
+func (self *history_picker) list_height() int {
+	return max(1, self.screen_height-2)
+}
+
+func (self *history_picker) format_entry(idx int, is_current bool) string {
+	when := " " + humanize.Time(self.all[idx].CopiedAt)
+	text := wcswidth.TruncateToVisualLength(self.summaries[idx], max(0, self.screen_width-wcswidth.Stringwidth(when)-1))
+	padding := strings.Repeat(" ", max(0, self.screen_width-wcswidth.Stringwidth(text)-wcswidth.Stringwidth(when)))
+	if is_current {
+		return self.lp.SprintStyled("reverse", text+padding+when)
+	}
+	return text + padding + self.lp.SprintStyled("dim", when)
+}
+
+func (self *history_picker) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	self.lp.ClearScreen()
+	if sz, err := self.lp.ScreenSize(); err == nil {
+		self.screen_width, self.screen_height = int(sz.WidthCells), int(sz.HeightCells)
+	}
+	h := self.list_height()
+	if self.current_idx < self.scroll_offset {
+		self.scroll_offset = self.current_idx
+	} else if self.current_idx >= self.scroll_offset+h {
+		self.scroll_offset = self.current_idx - h + 1
+	}
+	if len(self.all) == 0 {
+		self.lp.QueueWriteString(self.lp.SprintStyled("dim", "The clipboard history is empty, record it with: kitten clipboard --history-daemon"))
+	} else if len(self.matches) == 0 {
+		self.lp.QueueWriteString(self.lp.SprintStyled("dim", "No matching entries"))
+	}
+	for i := self.scroll_offset; i < min(len(self.matches), self.scroll_offset+h); i++ {
+		self.lp.QueueWriteString(self.format_entry(self.matches[i], i == self.current_idx))
+		self.lp.Println()
+	}
+	self.lp.MoveCursorTo(1, self.screen_height-1)
+	self.lp.QueueWriteString(self.lp.SprintStyled("dim", "↑↓ move  Enter copy  Esc quit"))
+	// the readline prompt clears to the end of the screen so it is drawn on the last line
+	self.lp.MoveCursorTo(1, self.screen_height)
+	self.search_rl.RedrawNonAtomic()
+}
+
+func (self *history_picker) copy_current() {
+	if self.current_idx >= len(self.matches) {
+		self.lp.Beep()
+		return
+	}
+	dest := "c"
+	if self.opts.UsePrimary {
+		dest = "p"
+	}
+	terminator := "\x1b\\"
+	if self.multiplexer != tui.NoMultiplexer {
+		// screen cannot pass through ST terminated escape codes
+		terminator = "\a"
+	}
+	text := self.all[self.matches[self.current_idx]].Text
+	data := fmt.Sprintf("\x1b]52;%s;%s%s", dest, base64.StdEncoding.EncodeToString([]byte(text)), terminator)
+	if self.multiplexer != tui.NoMultiplexer {
+		data, _ = tui.WrapForPassthrough(self.multiplexer, data)
+	}
+	self.lp.QueueWriteString(data)
+	self.lp.Quit(0)
+}
+
+func (self *history_picker) on_text(text string, from_key_event, in_bracketed_paste bool) error {
+	if err := self.search_rl.OnText(text, from_key_event, in_bracketed_paste); err != nil {
+		return err
+	}
+	self.current_idx = 0
+	self.update_matches()
+	self.draw_screen()
+	return nil
+}
+
+func (self *history_picker) move(delta int) {
+	if len(self.matches) == 0 {
+		self.lp.Beep()
+		return
+	}
+	self.current_idx = max(0, min(self.current_idx+delta, len(self.matches)-1))
+	self.draw_screen()
+}
+
+func (self *history_picker) on_key_event(ev *loop.KeyEvent) error {
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc") || ev.MatchesPressOrRepeat("ctrl+c"):
+		if self.search_rl.AllText() != "" && ev.MatchesPressOrRepeat("esc") {
+			self.search_rl.ResetText()
+			self.update_matches()
+			self.draw_screen()
+		} else {
+			self.lp.Quit(1)
+		}
+	case ev.MatchesPressOrRepeat("enter"):
+		self.copy_current()
+	case ev.MatchesPressOrRepeat("up") || ev.MatchesPressOrRepeat("ctrl+p"):
+		self.move(-1)
+	case ev.MatchesPressOrRepeat("down") || ev.MatchesPressOrRepeat("ctrl+n"):
+		self.move(1)
+	case ev.MatchesPressOrRepeat("page_up"):
+		self.move(-self.list_height())
+	case ev.MatchesPressOrRepeat("page_down"):
+		self.move(self.list_height())
+	default:
+		ev.Handled = false
+		if err := self.search_rl.OnKeyEvent(ev); err != nil {
+			return err
+		}
+		if ev.Handled {
+			self.current_idx = 0
+			self.update_matches()
+			self.draw_screen()
+		}
+	}
+	return nil
+}
+
+func run_history_picker(opts *Options) (rc int, err error) {
+	multiplexer := tui.DetectMultiplexer()
+	if multiplexer != tui.NoMultiplexer {
+		if err = tui.EnsurePassthroughAllowed(multiplexer); err != nil {
+			return 1, err
+		}
+	}
+	h, err := new_history(history_path(opts.UsePrimary), opts.HistorySize, nil)
+	if err != nil {
+		return 1, err
+	}
+	if err = h.load(); err != nil {
+		return 1, err
+	}
+	lp, err := loop.New()
+	if err != nil {
+		return 1, err
+	}
+	self := history_picker{lp: lp, opts: opts, multiplexer: multiplexer, all: h.entries}
+	self.summaries = make([]string, len(self.all))
+	for i, e := range self.all {
+		self.summaries[i] = summarize_history_entry(e.Text)
+	}
+	self.search_rl = readline.New(lp, readline.RlInit{DontMarkPrompts: true, Prompt: "> "})
+	lp.OnInitialize = self.initialize
+	lp.OnFinalize = self.finalize
+	lp.OnKeyEvent = self.on_key_event
+	lp.OnText = self.on_text
+	lp.OnResize = func(_, _ loop.ScreenSize) error {
+		self.draw_screen()
+		return nil
+	}
+	if err = lp.Run(); err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	return lp.ExitCode(), nil
+}
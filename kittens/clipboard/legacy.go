@@ -12,14 +12,13 @@ import (
 	"strings"
 
 	"kitty/tools/tty"
+	"kitty/tools/tui"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
 )
 
 var _ = fmt.Print
 
-var _ = fmt.Print
-
 func encode_read_from_clipboard(use_primary bool) string {
 	dest := "c"
 	if use_primary {
@@ -96,6 +95,21 @@ func preread_stdin() (data_src io.Reader, tempfile *os.File, err error) {
 }
 
 func run_plain_text_loop(opts *Options) (err error) {
+	multiplexer := tui.DetectMultiplexer()
+	terminator := "\x1b\\"
+	if multiplexer != tui.NoMultiplexer {
+		if opts.GetClipboard {
+			return tui.ResponsesNotSupportedError("Reading from the clipboard", multiplexer)
+		}
+		if opts.WaitForCompletion {
+			return tui.ResponsesNotSupportedError("Waiting for completion", multiplexer)
+		}
+		if err = tui.EnsurePassthroughAllowed(multiplexer); err != nil {
+			return err
+		}
+		// screen cannot pass through ST terminated escape codes
+		terminator = "\a"
+	}
 	stdin_is_tty := tty.IsTerminal(os.Stdin.Fd())
 	var data_src io.Reader
 	var tempfile *os.File
@@ -120,6 +134,9 @@ func run_plain_text_loop(opts *Options) (err error) {
 	}
 
 	send_to_loop := func(data string) loop.IdType {
+		if multiplexer != tui.NoMultiplexer {
+			data, _ = tui.WrapForPassthrough(multiplexer, data)
+		}
 		return lp.QueueWriteString(data)
 	}
 	enc_writer := base64_streaming_enc{output: send_to_loop}
@@ -141,7 +158,7 @@ func run_plain_text_loop(opts *Options) (err error) {
 	write_one_chunk := func() error {
 		n, err := data_src.Read(buf[:cap(buf)])
 		if err != nil && !errors.Is(err, io.EOF) {
-			send_to_loop("\x1b\\")
+			send_to_loop(terminator)
 			return err
 		}
 		if n > 0 {
@@ -149,7 +166,7 @@ func run_plain_text_loop(opts *Options) (err error) {
 		}
 		if errors.Is(err, io.EOF) {
 			enc.Close()
-			send_to_loop("\x1b\\")
+			send_to_loop(terminator)
 			after_read_from_stdin()
 		}
 		return nil
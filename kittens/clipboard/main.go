@@ -6,9 +6,13 @@ import (
 	"os"
 
 	"kitty/tools/cli"
+	"kitty/tools/tui"
 )
 
 func run_mime_loop(opts *Options, args []string) (err error) {
+	if m := tui.DetectMultiplexer(); m != tui.NoMultiplexer {
+		return tui.ResponsesNotSupportedError("Copying or pasting MIME types", m)
+	}
 	cwd, err = os.Getwd()
 	if err != nil {
 		return err
@@ -20,6 +24,12 @@ func run_mime_loop(opts *Options, args []string) (err error) {
 }
 
 func clipboard_main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if opts.HistoryDaemon {
+		return 0, run_history_daemon(opts)
+	}
+	if opts.History {
+		return run_history_picker(opts)
+	}
 	if len(args) > 0 {
 		return 0, run_mime_loop(opts, args)
 	}
@@ -44,6 +44,37 @@
 type=bool-set
 Wait till the copy to clipboard is complete before exiting. Useful if running
 the kitten in a dedicated, ephemeral window. Only needed in filter mode.
+
+
+--history
+type=bool-set
+Show the clipboard history recorded by :option:`--history-daemon` and copy the
+selected entry to the clipboard again. Type to filter the entries, use the arrow
+keys to select and press :kbd:`Enter` to copy.
+
+
+--history-daemon
+type=bool-set
+Record the text copied to the clipboard in the clipboard history, until
+interrupted. The history is stored in the kitty state directory, encrypted with a
+key kept in the keychain on macOS or the Secret Service on Linux, or in a file
+next to the history, if neither is available. Needs
+:code:`read-clipboard` (or :code:`read-primary` with :option:`--use-primary`) in
+:opt:`clipboard_control`, so that the clipboard can be read without asking for
+permission.
+
+
+--history-size
+type=int
+default=100
+The maximum number of entries to keep in the clipboard history.
+
+
+--history-ignore
+type=list
+A regular expression, copied text matching it is not recorded in the clipboard
+history. Can be specified multiple times. Text that looks like private keys or
+API tokens is never recorded, as is text larger than one megabyte.
 '''.format
 help_text = '''\
 Read or write to the system clipboard.
@@ -76,6 +107,10 @@
 
     # List the formats available on the system clipboard
     kitten clipboard -g -m . /dev/stdout
+
+The kitten can also maintain a history of copied text. Run
+:code:`kitten clipboard --history-daemon` in a window to record it and
+:code:`kitten clipboard --history` to pick an entry from it to copy again.
 '''
 
 usage = '[files to copy to/from]'
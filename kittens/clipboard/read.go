@@ -229,6 +229,18 @@ func encode(metadata map[string]string, payload string) string {
 	return encode_bytes(metadata, utils.UnsafeStringToBytes(payload))
 }
 
+// Encode an OSC 5522 escape code, for use by other kittens that talk to the
+// terminal clipboard
+func EncodeEscapeCode(metadata map[string]string, payload []byte) string {
+	return encode_bytes(metadata, payload)
+}
+
+// Parse an OSC 5522 escape code, returning nil metadata if the escape code is
+// not an OSC 5522 escape code
+func ParseEscapeCode(etype loop.EscapeCodeType, data []byte) (metadata map[string]string, payload []byte, err error) {
+	return parse_escape_code(etype, data)
+}
+
 func error_from_status(status string) error {
 	switch status {
 	case "ENOSYS":
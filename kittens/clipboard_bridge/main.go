@@ -0,0 +1,270 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard_bridge
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"kitty/kittens/clipboard"
+	"kitty/tools/cli"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type bridge_state int
+
+const (
+	idle bridge_state = iota
+	writing_to_terminal
+	reading_terminal_mimes
+	reading_terminal_data
+)
+
+type fingerprint = [sha256.Size]byte
+
+type bridge struct {
+	lp                         *loop.Loop
+	system                     *backend
+	opts                       *Options
+	max_size                   int
+	to_terminal, from_terminal bool
+
+	state bridge_state
+	// fingerprints of the last seen contents of the clipboards, used to
+	// detect changes and to avoid copying data back to where it came from
+	last_system, last_terminal fingerprint
+	has_last_terminal          bool
+	reading_mime               string
+	read_data                  []byte
+	read_too_large             bool
+}
+
+func (self *bridge) log(format string, args ...any) {
+	self.lp.Println(time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func (self *bridge) metadata(ptype, mime string) map[string]string {
+	ans := map[string]string{"type": ptype}
+	if self.opts.UsePrimary {
+		ans["loc"] = "primary"
+	}
+	if mime != "" {
+		ans["mime"] = mime
+	}
+	return ans
+}
+
+func terminal_fingerprint(mime string, data []byte) fingerprint {
+	s := snapshot{mimes: []string{mime}, data: map[string][]byte{mime: data}}
+	return s.fingerprint()
+}
+
+// The escape codes needed to write all the data in snapshot to the terminal
+// clipboard
+func (self *bridge) encode_write(s *snapshot) string {
+	const chunk_size = 4096
+	var b strings.Builder
+	b.WriteString(clipboard.EncodeEscapeCode(self.metadata("write", ""), nil))
+	for _, mime := range s.mimes {
+		data := s.data[mime]
+		for len(data) > 0 {
+			chunk := data[:min(chunk_size, len(data))]
+			data = data[len(chunk):]
+			b.WriteString(clipboard.EncodeEscapeCode(self.metadata("wdata", mime), chunk))
+		}
+	}
+	b.WriteString(clipboard.EncodeEscapeCode(self.metadata("wdata", ""), nil))
+	return b.String()
+}
+
+func (self *bridge) check_system() (changed bool) {
+	s, err := self.system.read_all(self.opts.UsePrimary, self.max_size)
+	if err != nil {
+		self.log("Failed to read system clipboard: %s", err)
+		return
+	}
+	if fp := s.fingerprint(); fp != self.last_system {
+		self.last_system = fp
+		if len(s.mimes) > 0 {
+			self.state = writing_to_terminal
+			self.lp.QueueWriteString(self.encode_write(s))
+			mime := preferred_mime(s.mimes)
+			self.last_terminal, self.has_last_terminal = terminal_fingerprint(mime, s.data[mime]), true
+			self.log("Copying to terminal: %s", strings.Join(s.mimes, " "))
+			return true
+		}
+	}
+	return
+}
+
+func (self *bridge) tick() {
+	if self.state != idle {
+		return
+	}
+	if self.to_terminal && self.check_system() {
+		return
+	}
+	if self.from_terminal {
+		self.state, self.reading_mime = reading_terminal_mimes, ""
+		self.lp.QueueWriteString(clipboard.EncodeEscapeCode(self.metadata("read", ""), []byte(".")))
+	}
+}
+
+func (self *bridge) on_terminal_data() {
+	fp := terminal_fingerprint(self.reading_mime, self.read_data)
+	if !self.has_last_terminal {
+		// do not overwrite the system clipboard with whatever happened to be
+		// in the terminal clipboard at startup
+		self.last_terminal, self.has_last_terminal = fp, true
+		return
+	}
+	if fp == self.last_terminal {
+		return
+	}
+	self.last_terminal = fp
+	if err := self.system.write(self.reading_mime, self.read_data, self.opts.UsePrimary); err != nil {
+		self.log("Failed to write to system clipboard: %s", err)
+		return
+	}
+	self.log("Copied from terminal: %s", self.reading_mime)
+	if s, err := self.system.read_all(self.opts.UsePrimary, self.max_size); err == nil {
+		self.last_system = s.fingerprint()
+	}
+}
+
+func (self *bridge) on_escape_code(etype loop.EscapeCodeType, data []byte) error {
+	metadata, payload, err := clipboard.ParseEscapeCode(etype, data)
+	if err != nil || metadata == nil {
+		return err
+	}
+	status := metadata["status"]
+	switch self.state {
+	case writing_to_terminal:
+		if metadata["type"] != "write" {
+			return nil
+		}
+		self.state = idle
+		if status != "DONE" {
+			self.log("Failed to write to terminal clipboard: %s", status)
+			if status == "EPERM" {
+				self.log("Permission denied, see the clipboard_control setting in kitty.conf, no longer copying data to the terminal")
+				self.to_terminal = false
+			}
+		}
+	case reading_terminal_mimes:
+		switch status {
+		case "DATA":
+			// wl-copy and xclip can only offer a single type, so reading
+			// the other types would be pointless
+			self.reading_mime = preferred_mime(utils.Filter(strings.Split(string(payload), " "), func(x string) bool { return x != "" }))
+		case "OK":
+		case "DONE":
+			if self.reading_mime == "" {
+				self.state = idle
+			} else {
+				self.state, self.read_data, self.read_too_large = reading_terminal_data, nil, false
+				self.lp.QueueWriteString(clipboard.EncodeEscapeCode(self.metadata("read", ""), []byte(self.reading_mime)))
+			}
+		default:
+			self.state = idle
+			self.log("Failed to read terminal clipboard: %s", status)
+			if status == "EPERM" {
+				self.log("Permission denied, see the clipboard_control setting in kitty.conf, no longer copying data from the terminal")
+				self.from_terminal = false
+			}
+		}
+	case reading_terminal_data:
+		switch status {
+		case "DATA":
+			if metadata["mime"] == self.reading_mime && !self.read_too_large {
+				self.read_data = append(self.read_data, payload...)
+				if self.max_size > 0 && len(self.read_data) > self.max_size {
+					self.read_data, self.read_too_large = nil, true
+				}
+			}
+		case "OK":
+		case "DONE":
+			self.state = idle
+			if !self.read_too_large {
+				self.on_terminal_data()
+			}
+			self.reading_mime, self.read_data = "", nil
+		default:
+			self.state = idle
+			self.log("Failed to read terminal clipboard: %s", status)
+		}
+	}
+	return nil
+}
+
+func main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
+	system, err := detect_backend(opts.Backend)
+	if err != nil {
+		return 1, err
+	}
+	// two bridges syncing the same system clipboard would fight each other
+	instance_name := "clipboard-bridge"
+	if opts.UsePrimary {
+		instance_name += "-primary"
+	}
+	instance, err := utils.SingleInstance(instance_name)
+	if err != nil {
+		return 1, err
+	}
+	if !instance.IsPrimary {
+		return 1, fmt.Errorf("The clipboard bridge is already running with pid: %d", instance.Pid)
+	}
+	defer instance.Close()
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return 1, err
+	}
+	self := bridge{
+		lp: lp, system: system, opts: opts, max_size: max(0, opts.MaxSize) * 1024 * 1024,
+		to_terminal: opts.Direction != "from-terminal", from_terminal: opts.Direction != "to-terminal",
+	}
+	interval := time.Duration(max(0.1, opts.Interval) * float64(time.Second))
+
+	lp.OnInitialize = func() (string, error) {
+		lp.SetWindowTitle("Clipboard bridge")
+		if s, err := system.read_all(opts.UsePrimary, self.max_size); err == nil {
+			self.last_system = s.fingerprint()
+		} else {
+			return "", err
+		}
+		self.log("Syncing the terminal clipboard with the %s clipboard, press Ctrl+C to quit", system.name)
+		_, err := lp.AddTimer(interval, true, func(loop.IdType) error {
+			self.tick()
+			return nil
+		})
+		self.tick()
+		return "", err
+	}
+	lp.OnEscapeCode = self.on_escape_code
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") || event.MatchesPressOrRepeat("q") {
+			event.Handled = true
+			lp.Quit(0)
+		}
+		return nil
+	}
+	if err = lp.Run(); err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
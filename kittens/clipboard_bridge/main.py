@@ -0,0 +1,64 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+help_text = '''\
+Keep the clipboard of the terminal in sync with the system clipboard of the computer this kitten is running on,
+as managed by :program:`wl-copy`/:program:`wl-paste` on Wayland or :program:`xclip` on X11. Useful when
+programs run in an environment whose clipboard is separate from the one kitty uses, such as inside a container,
+a nested compositor or a remote machine. Copying in such a program makes the data available in kitty and
+copying in kitty makes the data available to such programs. When copying to the terminal, all available data
+types are copied. When copying from the terminal, only the best available data type, such as an image or plain
+text, is copied, as that is all the clipboard tools support. Note that reading the terminal clipboard requires
+permission, see :opt:`clipboard_control`.
+'''
+OPTIONS = r'''
+--direction -d
+type=choices
+choices=both,to-terminal,from-terminal
+default=both
+Which direction to copy clipboard data in. :code:`to-terminal` means copy data from the system clipboard to the
+terminal clipboard.
+
+
+--interval -n
+type=float
+default=1
+The number of seconds between checks for changes to the clipboards. The clipboards are polled for changes as
+the clipboard tools provide no way to be notified of changes.
+
+
+--use-primary -p
+type=bool-set
+Sync the primary selection rather than the clipboard.
+
+
+--backend
+type=choices
+choices=auto,wayland,x11
+default=auto
+The tools used to access the system clipboard. By default, :program:`wl-clipboard` is used if
+running under Wayland, otherwise :program:`xclip`.
+
+
+--max-size
+type=int
+default=64
+Clipboard data larger than this size in MB is not copied. Use zero for no limit.
+'''.format
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten clipboard_bridge')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = ''
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Sync the terminal clipboard with the system clipboard'
@@ -0,0 +1,88 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard_bridge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"kitty/kittens/clipboard"
+	"kitty/tools/tui/loop"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestClipboardBridgeMimes(t *testing.T) {
+	offers := offers_from_targets([]string{"TIMESTAMP", "TARGETS", "UTF8_STRING", "text/html", "text/html", "image/png", ""})
+	if diff := cmp.Diff([]offer{{"text/html", "text/html"}, {"image/png", "image/png"}, {"text/plain", "UTF8_STRING"}}, offers, cmp.AllowUnexported(offer{})); diff != "" {
+		t.Fatalf("Incorrect offers from targets:\n%s", diff)
+	}
+	for expected, mimes := range map[string][]string{
+		"image/png":                {"text/plain", "image/jpeg", "image/png"},
+		"image/jpeg":               {"text/plain", "image/jpeg"},
+		"text/plain":               {"text/html", "text/plain"},
+		"text/plain;charset=utf-8": {"text/html", "text/plain;charset=utf-8"},
+		"text/html":                {"application/x-foo", "text/html"},
+		"application/x-foo":        {"application/x-foo"},
+		"":                         nil,
+	} {
+		if actual := preferred_mime(mimes); actual != expected {
+			t.Fatalf("Incorrect preferred MIME type for %v: %#v != %#v", mimes, expected, actual)
+		}
+	}
+}
+
+func TestClipboardBridgeSystem(t *testing.T) {
+	b := backend{
+		list_cmd: func(string, bool) []string { return []string{"sh", "-c", `printf 'TARGETS\ntext/plain\nimage/png\n'`} },
+		read_cmd: func(mime string, primary bool) []string {
+			return []string{"sh", "-c", fmt.Sprintf(`printf '%s:%v'`, mime, primary)}
+		},
+	}
+	s, err := b.read_all(true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := map[string]string{}
+	for _, m := range s.mimes {
+		actual[m] = string(s.data[m])
+	}
+	if diff := cmp.Diff(map[string]string{"text/plain": "text/plain:true", "image/png": "image/png:true"}, actual); diff != "" {
+		t.Fatalf("Incorrect system clipboard contents:\n%s", diff)
+	}
+	if s, err = b.read_all(false, 5); err != nil || len(s.mimes) != 0 {
+		t.Fatalf("Data larger than the maximum size not skipped: %v %v", s.mimes, err)
+	}
+	b.list_cmd = func(string, bool) []string { return []string{"sh", "-c", "exit 1"} }
+	if s, err = b.read_all(false, 0); err != nil || len(s.mimes) != 0 {
+		t.Fatalf("Empty clipboard not handled: %v %v", s.mimes, err)
+	}
+}
+
+func TestClipboardBridgeEncodeWrite(t *testing.T) {
+	self := bridge{opts: &Options{UsePrimary: true}}
+	big := strings.Repeat("x", 5000)
+	s := snapshot{mimes: []string{"text/plain", "text/html"}, data: map[string][]byte{"text/plain": []byte(big), "text/html": []byte("<p>")}}
+	type packet struct{ Type, Mime, Loc, Payload string }
+	var actual []packet
+	for _, x := range strings.Split(self.encode_write(&s), "\x1b\\") {
+		if x == "" {
+			continue
+		}
+		m, payload, err := clipboard.ParseEscapeCode(loop.OSC, []byte(strings.TrimPrefix(x, "\x1b]")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, packet{m["type"], m["mime"], m["loc"], string(payload)})
+	}
+	expected := []packet{
+		{"write", "", "primary", ""}, {"wdata", "text/plain", "primary", big[:4096]}, {"wdata", "text/plain", "primary", big[4096:]},
+		{"wdata", "text/html", "primary", "<p>"}, {"wdata", "", "primary", ""},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Incorrect escape codes to write to terminal clipboard:\n%s", diff)
+	}
+}
@@ -0,0 +1,200 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard_bridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+var _ = fmt.Print
+
+const command_timeout = 5 * time.Second
+
+// The system clipboard, accessed via command line tools
+type backend struct {
+	name                          string
+	list_cmd, read_cmd, write_cmd func(mime string, primary bool) []string
+}
+
+var wayland_backend = backend{
+	name: "Wayland",
+	list_cmd: func(_ string, primary bool) []string {
+		return with_primary([]string{"wl-paste", "--list-types"}, primary, "--primary")
+	},
+	read_cmd: func(mime string, primary bool) []string {
+		return with_primary([]string{"wl-paste", "--no-newline", "--type", mime}, primary, "--primary")
+	},
+	write_cmd: func(mime string, primary bool) []string {
+		return with_primary([]string{"wl-copy", "--type", mime}, primary, "--primary")
+	},
+}
+
+var x11_backend = backend{
+	name: "X11",
+	list_cmd: func(_ string, primary bool) []string {
+		return []string{"xclip", "-selection", selection(primary), "-o", "-t", "TARGETS"}
+	},
+	read_cmd: func(mime string, primary bool) []string {
+		return []string{"xclip", "-selection", selection(primary), "-o", "-t", mime}
+	},
+	write_cmd: func(mime string, primary bool) []string {
+		return []string{"xclip", "-selection", selection(primary), "-i", "-t", mime}
+	},
+}
+
+func with_primary(cmd []string, primary bool, flag string) []string {
+	if primary {
+		cmd = append(cmd, flag)
+	}
+	return cmd
+}
+
+func selection(primary bool) string {
+	if primary {
+		return "primary"
+	}
+	return "clipboard"
+}
+
+func detect_backend(which string) (*backend, error) {
+	has := func(exe string) bool { _, err := exec.LookPath(exe); return err == nil }
+	switch which {
+	case "wayland":
+		return &wayland_backend, nil
+	case "x11":
+		return &x11_backend, nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" && has("wl-paste") && has("wl-copy") {
+		return &wayland_backend, nil
+	}
+	if os.Getenv("DISPLAY") != "" && has("xclip") {
+		return &x11_backend, nil
+	}
+	return nil, fmt.Errorf("Could not find a way to access the system clipboard, install wl-clipboard on Wayland or xclip on X11")
+}
+
+func run(argv []string, stdin []byte) (output []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), command_timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+		err = cmd.Run()
+	} else {
+		output, err = cmd.Output()
+	}
+	if err != nil {
+		err = fmt.Errorf("Running %s failed with error: %w", strings.Join(argv, " "), err)
+	}
+	return
+}
+
+// A single data type available on the system clipboard. target is the name
+// used by the system clipboard tools, which on X11 need not be a MIME type.
+type offer struct {
+	mime, target string
+}
+
+// Convert the targets listed by the system clipboard tools into MIME types,
+// ignoring X11 specific targets such as TIMESTAMP
+func offers_from_targets(targets []string) (ans []offer) {
+	has_text_plain := false
+	for _, t := range targets {
+		if t = strings.TrimSpace(t); strings.Contains(t, "/") && !slices.ContainsFunc(ans, func(o offer) bool { return o.mime == t }) {
+			ans = append(ans, offer{t, t})
+			has_text_plain = has_text_plain || t == "text/plain"
+		}
+	}
+	if !has_text_plain {
+		for _, t := range []string{"text/plain;charset=utf-8", "UTF8_STRING", "STRING"} {
+			if slices.Contains(targets, t) {
+				ans = append(ans, offer{"text/plain", t})
+				break
+			}
+		}
+	}
+	return
+}
+
+// The best MIME type to copy when only a single type can be copied
+func preferred_mime(mimes []string) string {
+	for _, q := range []func(string) bool{
+		func(m string) bool { return m == "image/png" },
+		func(m string) bool { return strings.HasPrefix(m, "image/") },
+		func(m string) bool { return m == "text/plain" },
+		func(m string) bool { return strings.HasPrefix(m, "text/plain;") },
+		func(m string) bool { return strings.HasPrefix(m, "text/") },
+	} {
+		if idx := slices.IndexFunc(mimes, q); idx > -1 {
+			return mimes[idx]
+		}
+	}
+	if len(mimes) > 0 {
+		return mimes[0]
+	}
+	return ""
+}
+
+type snapshot struct {
+	mimes []string
+	data  map[string][]byte
+}
+
+func (self *snapshot) fingerprint() (ans [sha256.Size]byte) {
+	h := sha256.New()
+	for _, m := range self.mimes {
+		fmt.Fprintf(h, "%s\x00%d\x00", m, len(self.data[m]))
+		h.Write(self.data[m])
+	}
+	copy(ans[:], h.Sum(nil))
+	return
+}
+
+// Read all data types on the system clipboard, skipping data larger than
+// max_size
+func (self *backend) read_all(primary bool, max_size int) (ans *snapshot, err error) {
+	ans = &snapshot{data: map[string][]byte{}}
+	raw, err := run(self.list_cmd("", primary), nil)
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			// the clipboard is empty
+			return ans, nil
+		}
+		return nil, err
+	}
+	for _, o := range offers_from_targets(strings.Split(string(raw), "\n")) {
+		data, err := run(self.read_cmd(o.target, primary), nil)
+		if err != nil {
+			var ee *exec.ExitError
+			if errors.As(err, &ee) {
+				// the clipboard changed while reading or the type is not actually available
+				continue
+			}
+			return nil, err
+		}
+		if max_size > 0 && len(data) > max_size {
+			continue
+		}
+		ans.mimes = append(ans.mimes, o.mime)
+		ans.data[o.mime] = data
+	}
+	return
+}
+
+func (self *backend) write(mime string, data []byte, primary bool) error {
+	if data == nil {
+		data = []byte{}
+	}
+	_, err := run(self.write_cmd(mime, primary), data)
+	return err
+}
@@ -0,0 +1,145 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package color
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils/style"
+)
+
+var _ = fmt.Print
+
+func linearize(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.03928 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// The relative luminance of a color as defined by WCAG 2
+func RelativeLuminance(c style.RGBA) float64 {
+	return 0.2126*linearize(c.Red) + 0.7152*linearize(c.Green) + 0.0722*linearize(c.Blue)
+}
+
+// The WCAG 2 contrast ratio between two colors, from 1 to 21
+func ContrastRatio(a, b style.RGBA) float64 {
+	la, lb := RelativeLuminance(a), RelativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// A human readable summary of which WCAG levels the specified contrast ratio passes
+func ContrastRating(ratio float64) string {
+	switch {
+	case ratio >= 7:
+		return "AAA"
+	case ratio >= 4.5:
+		return "AA (AAA for large text)"
+	case ratio >= 3:
+		return "AA for large text only"
+	}
+	return "Fail"
+}
+
+// Convert hue in [0, 360), saturation and lightness in [0, 1] to RGB
+func FromHSL(h, s, l float64) style.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := math.Mod(h, 360) / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g, b = c, x, 0
+	case hp < 2:
+		r, g, b = x, c, 0
+	case hp < 3:
+		r, g, b = 0, c, x
+	case hp < 4:
+		r, g, b = 0, x, c
+	case hp < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	m := l - c/2
+	conv := func(v float64) uint8 { return uint8(math.Round(max(0, min(1, v+m)) * 255)) }
+	return style.RGBA{Red: conv(r), Green: conv(g), Blue: conv(b)}
+}
+
+func FormatColor(c style.RGBA, format string) string {
+	switch format {
+	case "rgb":
+		return fmt.Sprintf("rgb(%d, %d, %d)", c.Red, c.Green, c.Blue)
+	case "sgr":
+		return fmt.Sprintf("38:2:%d:%d:%d", c.Red, c.Green, c.Blue)
+	case "kitty":
+		return fmt.Sprintf("rgb:%02x/%02x/%02x", c.Red, c.Green, c.Blue)
+	}
+	return c.AsRGBSharp()
+}
+
+// The default value of the specified color in the 256 color palette
+func DefaultPaletteColor(idx int) (ans style.RGBA) {
+	ans.FromRGB(style.ColorTable[idx&0xff])
+	return
+}
+
+func parse_x11_component(raw string) (uint8, bool) {
+	if raw == "" || len(raw) > 4 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(raw, 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	max_val := uint64(1)<<(4*len(raw)) - 1
+	return uint8(math.Round(float64(v) / float64(max_val) * 255)), true
+}
+
+// Parse a response to an OSC 4 color query of the form 4;index;rgb:rrrr/gggg/bbbb
+func ParsePaletteResponse(raw string) (idx int, c style.RGBA, ok bool) {
+	parts := strings.Split(raw, ";")
+	if len(parts) != 3 || parts[0] != "4" {
+		return
+	}
+	idx, err := strconv.Atoi(parts[1])
+	if err != nil || idx < 0 || idx > 255 {
+		return
+	}
+	spec, found := strings.CutPrefix(parts[2], "rgb:")
+	if !found {
+		return
+	}
+	comps := strings.Split(spec, "/")
+	if len(comps) != 3 {
+		return
+	}
+	var okr, okg, okb bool
+	c.Red, okr = parse_x11_component(comps[0])
+	c.Green, okg = parse_x11_component(comps[1])
+	c.Blue, okb = parse_x11_component(comps[2])
+	ok = okr && okg && okb
+	return
+}
+
+func foreground_for(bg style.RGBA) string {
+	if ContrastRatio(bg, style.RGBA{}) > ContrastRatio(bg, style.RGBA{Red: 255, Green: 255, Blue: 255}) {
+		return "30"
+	}
+	return "97"
+}
+
+func sgr_for_palette_bg(idx int) string {
+	return fmt.Sprintf("\x1b[48:5:%dm", idx)
+}
+
+func sgr_for_rgb_bg(c style.RGBA) string {
+	return fmt.Sprintf("\x1b[48:2:%d:%d:%dm", c.Red, c.Green, c.Blue)
+}
@@ -0,0 +1,52 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package color
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"kitty/tools/utils/style"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestColorMath(t *testing.T) {
+	black, white := style.RGBA{}, style.RGBA{Red: 255, Green: 255, Blue: 255}
+	if r := ContrastRatio(black, white); math.Abs(r-21) > 0.001 {
+		t.Fatalf("Incorrect contrast ratio for black on white: %f", r)
+	}
+	if r := ContrastRatio(white, white); math.Abs(r-1) > 0.001 {
+		t.Fatalf("Incorrect contrast ratio for white on white: %f", r)
+	}
+	if r := ContrastRatio(style.RGBA{Red: 0x77, Green: 0x77, Blue: 0x77}, white); math.Abs(r-4.48) > 0.01 {
+		t.Fatalf("Incorrect contrast ratio for gray on white: %f", r)
+	}
+	for _, x := range []struct {
+		h, s, l  float64
+		expected style.RGBA
+	}{
+		{0, 1, 0.5, style.RGBA{Red: 255}},
+		{120, 1, 0.5, style.RGBA{Green: 255}},
+		{240, 1, 0.5, style.RGBA{Blue: 255}},
+		{0, 0, 1, white},
+		{60, 1, 0.25, style.RGBA{Red: 128, Green: 128}},
+	} {
+		if diff := cmp.Diff(x.expected, FromHSL(x.h, x.s, x.l)); diff != "" {
+			t.Fatalf("Incorrect conversion of HSL(%f, %f, %f):\n%s", x.h, x.s, x.l, diff)
+		}
+	}
+	idx, c, ok := ParsePaletteResponse("4;17;rgb:0000/5f5f/ffff")
+	if !ok || idx != 17 || c != (style.RGBA{Green: 0x5f, Blue: 0xff}) {
+		t.Fatalf("Incorrect parse of palette response: %d %v %v", idx, c, ok)
+	}
+	if _, _, ok = ParsePaletteResponse("10;rgb:0000/5f5f/ffff"); ok {
+		t.Fatalf("Parsed an invalid palette response")
+	}
+	if q := FormatColor(style.RGBA{Red: 1, Green: 2, Blue: 255}, "kitty"); q != "rgb:01/02/ff" {
+		t.Fatalf("Incorrect formatting: %s", q)
+	}
+}
@@ -0,0 +1,156 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package color
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils/style"
+)
+
+var _ = fmt.Print
+
+func print_contrast(args []string) (rc int, err error) {
+	if len(args) != 2 {
+		return 1, fmt.Errorf("Must specify exactly two colors to compare")
+	}
+	a, err := style.ParseColor(args[0])
+	if err != nil {
+		return 1, err
+	}
+	b, err := style.ParseColor(args[1])
+	if err != nil {
+		return 1, err
+	}
+	ratio := ContrastRatio(a, b)
+	fmt.Printf("%.2f:1 %s\n", ratio, ContrastRating(ratio))
+	return
+}
+
+func print_palette() {
+	out := strings.Builder{}
+	cell := func(idx int) string {
+		return sgr_for_palette_bg(idx) + fmt.Sprintf("\x1b[%sm%4d\x1b[39m", foreground_for(DefaultPaletteColor(idx)), idx)
+	}
+	for i := 0; i < 16; i++ {
+		out.WriteString(cell(i))
+		if i%8 == 7 {
+			out.WriteString("\x1b[49m\n")
+		}
+	}
+	out.WriteString("\n")
+	for g := 0; g < 6; g++ {
+		for r := 0; r < 6; r++ {
+			for b := 0; b < 6; b++ {
+				out.WriteString(cell(16 + 36*r + 6*g + b))
+			}
+			if r == 2 || r == 5 {
+				out.WriteString("\x1b[49m\n")
+			}
+		}
+	}
+	out.WriteString("\n")
+	for i := 232; i < 256; i++ {
+		out.WriteString(cell(i))
+		if i == 243 || i == 255 {
+			out.WriteString("\x1b[49m\n")
+		}
+	}
+	out.WriteString("\n")
+	const width = 72
+	for _, l := range []float64{0.75, 0.5, 0.25} {
+		for x := 0; x < width; x++ {
+			out.WriteString(sgr_for_rgb_bg(FromHSL(float64(x)*360/width, 1, l)) + " ")
+		}
+		out.WriteString("\x1b[49m\n")
+	}
+	for x := 0; x < width; x++ {
+		v := uint8(x * 255 / (width - 1))
+		out.WriteString(sgr_for_rgb_bg(style.RGBA{Red: v, Green: v, Blue: v}) + " ")
+	}
+	out.WriteString("\x1b[49m\n")
+	os.Stdout.WriteString(out.String())
+}
+
+func set_color(opts *Options, c style.RGBA) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{"@", "set-colors"}
+	wid := os.Getenv("KITTY_WINDOW_ID")
+	switch opts.SetIn {
+	case "all":
+		args = append(args, "--all")
+	case "tab":
+		if wid != "" {
+			args = append(args, "--match-tab", "window_id:"+wid)
+		}
+	default:
+		if wid != "" {
+			args = append(args, "--match", "id:"+wid)
+		}
+	}
+	args = append(args, opts.Set+"="+c.AsRGBSharp())
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("Failed to set the color %s using remote control with error: %w", opts.Set, err)
+	}
+	return nil
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if opts.Contrast {
+		return print_contrast(args)
+	}
+	if opts.PrintPalette {
+		print_palette()
+		return
+	}
+	if len(args) > 0 {
+		return 1, fmt.Errorf("Colors can only be specified with --contrast")
+	}
+	lp, err := loop.New(loop.NoRestoreColors)
+	if err != nil {
+		return 1, err
+	}
+	h := new_handler(lp, opts)
+	lp.OnInitialize = h.initialize
+	lp.OnFinalize = h.finalize
+	lp.OnKeyEvent = h.on_key_event
+	lp.OnEscapeCode = h.on_escape_code
+	lp.OnResize = func(_, _ loop.ScreenSize) error {
+		h.draw_screen()
+		return nil
+	}
+	err = lp.Run()
+	if err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	if h.picked == nil {
+		return lp.ExitCode(), nil
+	}
+	fmt.Println(FormatColor(*h.picked, opts.Format))
+	if opts.Set != "" {
+		if err = set_color(opts, *h.picked); err != nil {
+			return 1, err
+		}
+	}
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,61 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+OPTIONS = r'''
+--format -f
+default=hex
+choices=hex,rgb,sgr,kitty
+The format in which to output picked colors. :code:`hex` is of the form
+:code:`#rrggbb`, :code:`rgb` is of the form :code:`rgb(r, g, b)`, :code:`sgr` is
+the SGR escape code parameters to use the color as a foreground color and
+:code:`kitty` is of the form :code:`rgb:rr/gg/bb` as accepted by kitty.
+
+
+--contrast -c
+type=bool-set
+Instead of running interactively, print the contrast ratio between the two colors
+specified as arguments, along with whether they pass the WCAG accessibility guidelines.
+
+
+--print-palette -p
+type=bool-set
+Instead of running interactively, print the current 256 color palette and some
+truecolor gradients to STDOUT.
+
+
+--set -s
+Set the specified kitty color to the picked color, live, using remote control.
+For example: :code:`--set background` or :code:`--set color4`. Any color name
+accepted by :ref:`at-set-colors` can be used.
+
+
+--set-in
+default=window
+choices=window,tab,all
+Where to set the color when using :option:`--set`. Either only the current window,
+all windows in the current tab or all windows in kitty.
+'''.format
+help_text = '''\
+Show the terminal's current 256 color palette and truecolor gradients and
+interactively pick colors from them, printing the picked color to STDOUT.
+Two colors can be compared to check their contrast ratio and the picked color
+can be applied to kitty directly.
+'''
+usage = '[color1 color2]'
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten color')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Pick colors and inspect the terminal palette'
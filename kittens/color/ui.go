@@ -0,0 +1,269 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package color
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils/style"
+)
+
+var _ = fmt.Print
+
+type View int
+
+const (
+	PALETTE View = iota
+	GRADIENT
+)
+
+const status_height = 4
+
+type handler struct {
+	lp   *loop.Loop
+	opts *Options
+
+	view          View
+	palette       [256]style.RGBA
+	palette_rows  [][]int
+	row, col      int
+	grow, gcol    int
+	saturation    float64
+	marked        *style.RGBA
+	picked        *style.RGBA
+	picked_index  int
+	screen_width  int
+	screen_height int
+}
+
+func new_handler(lp *loop.Loop, opts *Options) *handler {
+	ans := handler{lp: lp, opts: opts, saturation: 1, picked_index: -1}
+	for i := range ans.palette {
+		ans.palette[i] = DefaultPaletteColor(i)
+	}
+	row := func(start, count int) []int {
+		r := make([]int, count)
+		for i := range r {
+			r[i] = start + i
+		}
+		return r
+	}
+	ans.palette_rows = append(ans.palette_rows, row(0, 8), row(8, 8))
+	// The 6x6x6 color cube, one row per green value, with red blocks side by side
+	for g := 0; g < 6; g++ {
+		r := make([]int, 0, 36)
+		for red := 0; red < 6; red++ {
+			for b := 0; b < 6; b++ {
+				r = append(r, 16+36*red+6*g+b)
+			}
+		}
+		ans.palette_rows = append(ans.palette_rows, r)
+	}
+	ans.palette_rows = append(ans.palette_rows, row(232, 24))
+	return &ans
+}
+
+func (self *handler) initialize() (string, error) {
+	self.lp.SetCursorVisible(false)
+	self.lp.AllowLineWrapping(false)
+	self.lp.SetWindowTitle("Pick a color")
+	q := strings.Builder{}
+	q.WriteString("\x1b]4")
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&q, ";%d;?", i)
+	}
+	q.WriteString("\x1b\\")
+	self.lp.QueueWriteString(q.String())
+	self.draw_screen()
+	return "", nil
+}
+
+func (self *handler) finalize() string {
+	self.lp.SetCursorVisible(true)
+	return ""
+}
+
+func (self *handler) on_escape_code(etype loop.EscapeCodeType, payload []byte) error {
+	if etype == loop.OSC {
+		if idx, c, ok := ParsePaletteResponse(string(payload)); ok {
+			self.palette[idx] = c
+			if idx == 255 {
+				self.draw_screen()
+			}
+		}
+	}
+	return nil
+}
+
+func (self *handler) cell_width() int {
+	return max(1, min(4, self.screen_width/36))
+}
+
+func (self *handler) gradient_size() (cols, rows int) {
+	return max(1, self.screen_width/2), max(1, self.screen_height-status_height-1)
+}
+
+func (self *handler) gradient_color(col, row int) style.RGBA {
+	cols, rows := self.gradient_size()
+	return FromHSL(float64(col)*360/float64(cols), self.saturation, 1-float64(row+1)/float64(rows+1))
+}
+
+func (self *handler) current() (c style.RGBA, idx int) {
+	if self.view == PALETTE {
+		idx = self.palette_rows[self.row][self.col]
+		return self.palette[idx], idx
+	}
+	return self.gradient_color(self.gcol, self.grow), -1
+}
+
+func (self *handler) draw_palette() {
+	cw := self.cell_width()
+	blank := strings.Repeat(" ", cw)
+	for r, row := range self.palette_rows {
+		if r == 2 || r == len(self.palette_rows)-1 {
+			self.lp.Println()
+		}
+		line := strings.Builder{}
+		for c, idx := range row {
+			line.WriteString(sgr_for_palette_bg(idx))
+			if r == self.row && c == self.col {
+				line.WriteString("\x1b[" + foreground_for(self.palette[idx]) + "m")
+				line.WriteString(("◆" + blank)[:len("◆")+cw-1])
+				line.WriteString("\x1b[39m")
+			} else {
+				line.WriteString(blank)
+			}
+		}
+		line.WriteString("\x1b[49m")
+		self.lp.QueueWriteString(line.String())
+		self.lp.Println()
+	}
+}
+
+func (self *handler) draw_gradient() {
+	cols, rows := self.gradient_size()
+	for y := 0; y < rows; y++ {
+		line := strings.Builder{}
+		for x := 0; x < cols; x++ {
+			c := self.gradient_color(x, y)
+			line.WriteString(sgr_for_rgb_bg(c))
+			if x == self.gcol && y == self.grow {
+				line.WriteString("\x1b[" + foreground_for(c) + "m◆ \x1b[39m")
+			} else {
+				line.WriteString("  ")
+			}
+		}
+		line.WriteString("\x1b[49m")
+		self.lp.QueueWriteString(line.String())
+		self.lp.Println()
+	}
+}
+
+func swatch(c style.RGBA) string {
+	return sgr_for_rgb_bg(c) + "    \x1b[49m"
+}
+
+func (self *handler) draw_status() {
+	c, idx := self.current()
+	self.lp.MoveCursorTo(1, self.screen_height-status_height+1)
+	desc := fmt.Sprintf("%s %s  %s", swatch(c), c.AsRGBSharp(), FormatColor(c, "rgb"))
+	if idx > -1 {
+		desc += fmt.Sprintf("  color%d", idx)
+	}
+	self.lp.QueueWriteString("Current: " + desc)
+	self.lp.Println()
+	if self.marked != nil {
+		m := *self.marked
+		ratio := ContrastRatio(c, m)
+		sample := fmt.Sprintf("\x1b[38:2:%d:%d:%dm%s Sample text \x1b[m", c.Red, c.Green, c.Blue, sgr_for_rgb_bg(m))
+		self.lp.QueueWriteString(fmt.Sprintf("Marked:  %s %s  Contrast: %.2f:1 %s  %s", swatch(m), m.AsRGBSharp(), ratio, ContrastRating(ratio), sample))
+	}
+	self.lp.Println()
+	self.lp.Println()
+	help := "←↓↑→ move  Tab switch view  Space mark for contrast  Enter pick  q quit"
+	if self.view == GRADIENT {
+		help = strings.Replace(help, "Enter", "+/- saturation  Enter", 1)
+	}
+	self.lp.QueueWriteString(self.lp.SprintStyled("dim", help))
+}
+
+func (self *handler) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	self.lp.ClearScreen()
+	if sz, err := self.lp.ScreenSize(); err == nil {
+		self.screen_width, self.screen_height = int(sz.WidthCells), int(sz.HeightCells)
+	}
+	if self.view == PALETTE {
+		self.draw_palette()
+	} else {
+		cols, rows := self.gradient_size()
+		self.gcol, self.grow = min(self.gcol, cols-1), min(self.grow, rows-1)
+		self.draw_gradient()
+	}
+	self.draw_status()
+}
+
+func (self *handler) move(dx, dy int) {
+	if self.view == PALETTE {
+		if dy != 0 {
+			nr := self.row + dy
+			if nr < 0 || nr >= len(self.palette_rows) {
+				self.lp.Beep()
+				return
+			}
+			// keep the horizontal position the same when moving between rows of different lengths
+			frac := float64(self.col) / float64(len(self.palette_rows[self.row]))
+			self.row = nr
+			self.col = min(int(frac*float64(len(self.palette_rows[nr]))), len(self.palette_rows[nr])-1)
+		}
+		self.col = max(0, min(self.col+dx, len(self.palette_rows[self.row])-1))
+	} else {
+		cols, rows := self.gradient_size()
+		self.gcol = max(0, min(self.gcol+dx, cols-1))
+		self.grow = max(0, min(self.grow+dy, rows-1))
+	}
+	self.draw_screen()
+}
+
+func (self *handler) on_key_event(ev *loop.KeyEvent) error {
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc") || ev.MatchesPressOrRepeat("q"):
+		self.lp.Quit(1)
+	case ev.MatchesPressOrRepeat("left") || ev.MatchesPressOrRepeat("h"):
+		self.move(-1, 0)
+	case ev.MatchesPressOrRepeat("right") || ev.MatchesPressOrRepeat("l"):
+		self.move(1, 0)
+	case ev.MatchesPressOrRepeat("up") || ev.MatchesPressOrRepeat("k"):
+		self.move(0, -1)
+	case ev.MatchesPressOrRepeat("down") || ev.MatchesPressOrRepeat("j"):
+		self.move(0, 1)
+	case ev.MatchesPressOrRepeat("tab"):
+		self.view = (self.view + 1) % 2
+		self.draw_screen()
+	case ev.MatchesPressOrRepeat("space"):
+		if self.marked != nil {
+			self.marked = nil
+		} else {
+			c, _ := self.current()
+			self.marked = &c
+		}
+		self.draw_screen()
+	case ev.MatchesPressOrRepeat("+") || ev.MatchesPressOrRepeat("="):
+		self.saturation = min(1, self.saturation+0.1)
+		self.draw_screen()
+	case ev.MatchesPressOrRepeat("-"):
+		self.saturation = max(0, self.saturation-0.1)
+		self.draw_screen()
+	case ev.MatchesPressOrRepeat("enter"):
+		c, idx := self.current()
+		self.picked, self.picked_index = &c, idx
+		self.lp.Quit(0)
+	default:
+		ev.Handled = false
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package diff
+
+import (
+	"fmt"
+	"strconv"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Textual markers for changed lines in accessible mode, so that changes are
+// not signalled by color alone
+const (
+	removed_marker = "-"
+	added_marker   = "+"
+)
+
+// In accessible mode, changes are rendered in a single column, with removed
+// lines before the lines that replace them, so that the reading order of the
+// screen makes sense to screen readers and braille displays
+func is_accessible() bool { return opts != nil && opts.Accessible }
+
+// The margin text for a line in accessible mode, the change marker followed by
+// the line number
+func marked_margin(marker, lnum string) string {
+	return utils.IfElse(marker == "", " ", marker) + " " + lnum
+}
+
+func line_range(start, count int) string {
+	switch count {
+	case 0:
+		return "none"
+	case 1:
+		return fmt.Sprintf("line %d", start+1)
+	}
+	return fmt.Sprintf("lines %d to %d", start+1, start+count)
+}
+
+// A hunk title that can be understood without knowing the unified diff format
+func accessible_hunk_title(hunk_num, num_hunks int, hunk *Hunk) string {
+	ans := fmt.Sprintf("Hunk %d of %d, old: %s, new: %s", hunk_num+1, num_hunks, line_range(hunk.left_start, hunk.left_count), line_range(hunk.right_start, hunk.right_count))
+	if hunk.title != "" {
+		ans += ", in: " + hunk.title
+	}
+	return ans
+}
+
+func linear_lines_for_context_chunk(data *DiffData, chunk *Chunk, ans []*LogicalLine) []*LogicalLine {
+	for i := 0; i < chunk.left_count; i++ {
+		left_line_number, right_line_number := chunk.left_start+i, chunk.right_start+i
+		ll := LogicalLine{line_type: CONTEXT_LINE, is_full_width: true,
+			left_reference:  Reference{path: data.left_path, linenum: left_line_number + 1},
+			right_reference: Reference{path: data.right_path, linenum: right_line_number + 1},
+		}
+		lnum := strconv.Itoa(right_line_number + 1)
+		for _, text := range splitlines(data.left_lines[left_line_number], data.available_cols) {
+			sl := ScreenLine{}
+			sl.left = HalfScreenLine{marked_up_margin_text: marked_margin("", lnum), marked_up_text: text}
+			ll.screen_lines = append(ll.screen_lines, &sl)
+			lnum = ""
+		}
+		ans = append(ans, &ll)
+	}
+	return ans
+}
+
+func linear_change_line(hlines []HalfScreenLine, marker string) *LogicalLine {
+	ll := LogicalLine{line_type: CHANGE_LINE, is_full_width: true, linear_marker: marker}
+	for _, hl := range hlines {
+		hl.marked_up_margin_text = marked_margin(marker, hl.marked_up_margin_text)
+		ll.screen_lines = append(ll.screen_lines, &ScreenLine{left: hl})
+	}
+	return &ll
+}
+
+// All the removed lines of the chunk followed by all the added lines
+func linear_lines_for_diff_chunk(data *DiffData, chunk *Chunk, ans []*LogicalLine) []*LogicalLine {
+	hlines := make([]HalfScreenLine, 0, 32)
+	center := func(i int) Center {
+		if i < len(chunk.centers) {
+			return chunk.centers[i]
+		}
+		return Center{}
+	}
+	for i := 0; i < chunk.left_count; i++ {
+		lnum := chunk.left_start + i
+		hlines = render_half_line(lnum, data.left_lines[lnum], "remove", data.available_cols, center(i), hlines[:0])
+		ll := linear_change_line(hlines, removed_marker)
+		ll.is_change_start = i == 0
+		ll.left_reference = Reference{path: data.left_path, linenum: lnum + 1}
+		ans = append(ans, ll)
+	}
+	for i := 0; i < chunk.right_count; i++ {
+		lnum := chunk.right_start + i
+		hlines = render_half_line(lnum, data.right_lines[lnum], "add", data.available_cols, center(i), hlines[:0])
+		ll := linear_change_line(hlines, added_marker)
+		ll.is_change_start = i == 0 && chunk.left_count == 0
+		ll.right_reference = Reference{path: data.right_path, linenum: lnum + 1}
+		ans = append(ans, ll)
+	}
+	return ans
+}
+
+func linear_all_lines(path string, columns, margin_size int, is_add bool, ans []*LogicalLine) ([]*LogicalLine, error) {
+	lines, err := highlighted_lines_for_path(path)
+	if err != nil {
+		return nil, err
+	}
+	available_cols := columns - margin_size
+	ltype, marker, msg := "add", added_marker, "This file was added"
+	if !is_add {
+		ltype, marker, msg = "remove", removed_marker, "This file was removed"
+	}
+	ref := Reference{path: path}
+	title := LogicalLine{line_type: HUNK_TITLE_LINE, is_full_width: true}
+	for _, line := range splitlines(fmt.Sprintf("%s, %s", msg, line_range(0, len(lines))), available_cols) {
+		sl := ScreenLine{}
+		sl.left.marked_up_text = line
+		title.screen_lines = append(title.screen_lines, &sl)
+	}
+	ans = append(ans, &title)
+	hlines := make([]HalfScreenLine, 0, 8)
+	for line_number, line := range lines {
+		hlines = render_half_line(line_number, line, ltype, available_cols, Center{}, hlines[:0])
+		ll := linear_change_line(hlines, marker)
+		ll.is_change_start = line_number == 0
+		ref.linenum = line_number + 1
+		if is_add {
+			ll.right_reference = ref
+		} else {
+			ll.left_reference = ref
+		}
+		ans = append(ans, ll)
+	}
+	return ans, nil
+}
@@ -0,0 +1,48 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package diff
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestDiffLinearLayout(t *testing.T) {
+	data := DiffData{available_cols: 80, left_lines: []string{"a", "b", "c"}, right_lines: []string{"a", "B", "x", "c"}}
+	chunks := []*Chunk{
+		{is_context: true, left_count: 1, right_count: 1},
+		{left_start: 1, right_start: 1, left_count: 1, right_count: 2},
+		{is_context: true, left_start: 2, right_start: 3, left_count: 1, right_count: 1},
+	}
+	var lines []*LogicalLine
+	for _, c := range chunks {
+		if c.is_context {
+			lines = linear_lines_for_context_chunk(&data, c, lines)
+		} else {
+			lines = linear_lines_for_diff_chunk(&data, c, lines)
+		}
+	}
+	type line struct {
+		Margin, Text string
+		Change_start bool
+	}
+	actual := make([]line, len(lines))
+	for i, ll := range lines {
+		if !ll.is_full_width || len(ll.screen_lines) != 1 {
+			t.Fatalf("Line %d is not a single full width line", i)
+		}
+		actual[i] = line{ll.screen_lines[0].left.marked_up_margin_text, ll.screen_lines[0].left.marked_up_text, ll.is_change_start}
+	}
+	expected := []line{{"  1", "a", false}, {"- 2", "b", true}, {"+ 2", "B", false}, {"+ 3", "x", false}, {"  4", "c", false}}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Incorrect linear layout:\n%s", diff)
+	}
+	h := Hunk{left_start: 0, left_count: 3, right_start: 0, right_count: 4, title: "func x"}
+	if q := accessible_hunk_title(0, 2, &h); q != "Hunk 1 of 2, old: lines 1 to 3, new: lines 1 to 4, in: func x" {
+		t.Fatalf("Incorrect hunk title: %#v", q)
+	}
+}
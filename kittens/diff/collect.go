@@ -17,23 +17,23 @@ import (
 var _ = fmt.Print
 var path_name_map, remote_dirs map[string]string
 
-var mimetypes_cache, data_cache, hash_cache *utils.LRUCache[string, string]
-var size_cache *utils.LRUCache[string, int64]
-var lines_cache *utils.LRUCache[string, []string]
-var highlighted_lines_cache *utils.LRUCache[string, []string]
-var is_text_cache *utils.LRUCache[string, bool]
+var mimetypes_cache, data_cache, hash_cache *utils.LRU[string, string]
+var size_cache *utils.LRU[string, int64]
+var lines_cache *utils.LRU[string, []string]
+var highlighted_lines_cache *utils.LRU[string, []string]
+var is_text_cache *utils.LRU[string, bool]
 
 func init_caches() {
 	path_name_map = make(map[string]string, 32)
 	remote_dirs = make(map[string]string, 32)
 	const sz = 4096
-	size_cache = utils.NewLRUCache[string, int64](sz)
-	mimetypes_cache = utils.NewLRUCache[string, string](sz)
-	data_cache = utils.NewLRUCache[string, string](sz)
-	is_text_cache = utils.NewLRUCache[string, bool](sz)
-	lines_cache = utils.NewLRUCache[string, []string](sz)
-	highlighted_lines_cache = utils.NewLRUCache[string, []string](sz)
-	hash_cache = utils.NewLRUCache[string, string](sz)
+	size_cache = utils.NewLRU[string, int64](sz)
+	mimetypes_cache = utils.NewLRU[string, string](sz)
+	data_cache = utils.NewLRU[string, string](sz)
+	is_text_cache = utils.NewLRU[string, bool](sz)
+	lines_cache = utils.NewLRU[string, []string](sz)
+	highlighted_lines_cache = utils.NewLRU[string, []string](sz)
+	hash_cache = utils.NewLRU[string, string](sz)
 }
 
 func add_remote_dir(val string) {
@@ -293,6 +293,35 @@ func walk(base string, patterns []string, names *utils.Set[string], pmap, path_n
 	})
 }
 
+// The added and removed files that have the same size as a file on the other
+// side, as a renamed file must have identical contents
+func rename_candidates(added *utils.Set[string], added_paths map[string]string, removed *utils.Set[string], removed_paths map[string]string) (*utils.Set[string], *utils.Set[string]) {
+	sizes := func(names *utils.Set[string], path_map map[string]string) map[string]int64 {
+		ans := make(map[string]int64, names.Len())
+		for name := range names.Iterable() {
+			if s, err := os.Stat(path_map[name]); err == nil {
+				ans[name] = s.Size()
+			}
+		}
+		return ans
+	}
+	filter := func(sizes, other map[string]int64) *utils.Set[string] {
+		other_sizes := utils.NewSet[int64](len(other))
+		for _, sz := range other {
+			other_sizes.Add(sz)
+		}
+		ans := utils.NewSet[string](len(sizes))
+		for name, sz := range sizes {
+			if other_sizes.Has(sz) {
+				ans.Add(name)
+			}
+		}
+		return ans
+	}
+	asz, rsz := sizes(added, added_paths), sizes(removed, removed_paths)
+	return filter(asz, rsz), filter(rsz, asz)
+}
+
 func (self *Collection) collect_files(left, right string) error {
 	left_names, right_names := utils.NewSet[string](16), utils.NewSet[string](16)
 	left_path_map, right_path_map := make(map[string]string, 16), make(map[string]string, 16)
@@ -331,30 +360,37 @@ func (self *Collection) collect_files(left, right string) error {
 	}
 	removed := left_names.Subtract(common_names)
 	added := right_names.Subtract(common_names)
-	ahash, rhash := make(map[string]string, added.Len()), make(map[string]string, removed.Len())
-	for a := range added.Iterable() {
+	hash_added, hash_removed := added, removed
+	if utils.IsNetworkFS(left) || utils.IsNetworkFS(right) {
+		// reading files is slow, so only hash files that could be renames
+		hash_added, hash_removed = rename_candidates(added, right_path_map, removed, left_path_map)
+	}
+	ahash, rhash := make(map[string]string, hash_added.Len()), make(map[string]string, hash_removed.Len())
+	for a := range hash_added.Iterable() {
 		ahash[a], err = hash_for_path(right_path_map[a])
 		if err != nil {
 			return err
 		}
 	}
-	for r := range removed.Iterable() {
+	for r := range hash_removed.Iterable() {
 		rhash[r], err = hash_for_path(left_path_map[r])
 		if err != nil {
 			return err
 		}
 	}
-	for name, rh := range rhash {
+	for name := range removed.Iterable() {
 		found := false
-		for n, ah := range ahash {
-			if ah == rh {
-				ld, _ := data_for_path(left_path_map[name])
-				rd, _ := data_for_path(right_path_map[n])
-				if ld == rd {
-					self.add_rename(left_path_map[name], right_path_map[n])
-					added.Discard(n)
-					found = true
-					break
+		if rh, ok := rhash[name]; ok {
+			for n, ah := range ahash {
+				if ah == rh {
+					ld, _ := data_for_path(left_path_map[name])
+					rd, _ := data_for_path(right_path_map[n])
+					if ld == rd {
+						self.add_rename(left_path_map[name], right_path_map[n])
+						added.Discard(n)
+						found = true
+						break
+					}
 				}
 			}
 		}
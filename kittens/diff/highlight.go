@@ -202,6 +202,13 @@ func highlight_file(path string) (highlighted string, err error) {
 			style = styles.Fallback
 		}
 	}
+	var cache_key string
+	if data_hash, herr := hash_for_path(path); herr == nil {
+		cache_key = highlight_cache_key(data_hash, lexer.Config().Name, style.Name)
+		if ans, found := disk_highlight_cache().get(cache_key); found {
+			return ans, nil
+		}
+	}
 	iterator, err := lexer.Tokenise(nil, text)
 	if err != nil {
 		return "", err
@@ -211,10 +218,15 @@ func highlight_file(path string) (highlighted string, err error) {
 	w.Grow(len(text) * 2)
 	err = formatter.Format(&w, style, iterator)
 	// os.WriteFile(filepath.Base(path+".highlighted"), []byte(w.String()), 0o600)
+	if err == nil && cache_key != "" {
+		disk_highlight_cache().set(cache_key, w.String())
+	}
 	return w.String(), err
 }
 
-func highlight_all(paths []string) {
+// Highlight the files in parallel, roughly in the order specified. on_progress,
+// if not nil, is called from the worker goroutines after each file is highlighted.
+func highlight_all(paths []string, on_progress func()) {
 	ctx := images.Context{}
 	ctx.Parallel(0, len(paths), func(nums <-chan int) {
 		for i := range nums {
@@ -222,7 +234,11 @@ func highlight_all(paths []string) {
 			raw, err := highlight_file(path)
 			if err == nil {
 				highlighted_lines_cache.Set(path, text_to_lines(raw))
+				if on_progress != nil {
+					on_progress()
+				}
 			}
 		}
 	})
+	disk_highlight_cache().prune()
 }
@@ -0,0 +1,85 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package diff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Increment when the output of the highlighter changes
+const highlight_cache_version = "1"
+
+// The on disk cache of highlighted files, keyed by the contents of the file,
+// the lexer and the style, so that large files do not need to be highlighted
+// every time they are diffed
+type highlight_cache struct {
+	dir         string
+	max_entries int
+	max_age     time.Duration
+}
+
+var disk_highlight_cache = sync.OnceValue(func() *highlight_cache {
+	return &highlight_cache{dir: filepath.Join(utils.CacheDir(), "diff-highlight"), max_entries: 2048, max_age: 30 * 24 * time.Hour}
+})
+
+func highlight_cache_key(data_hash, lexer, style string) string {
+	h := sha256.New()
+	for _, x := range []string{highlight_cache_version, lexer, style, data_hash} {
+		h.Write(utils.UnsafeStringToBytes(x))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (self *highlight_cache) get(key string) (string, bool) {
+	path := filepath.Join(self.dir, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return utils.UnsafeBytesToString(data), true
+}
+
+func (self *highlight_cache) set(key, highlighted string) {
+	if err := os.MkdirAll(self.dir, 0o700); err == nil {
+		_ = utils.AtomicWriteFile(filepath.Join(self.dir, key), utils.UnsafeStringToBytes(highlighted), 0o600)
+	}
+}
+
+// Remove the least recently used entries beyond max_entries and entries
+// not used for max_age
+func (self *highlight_cache) prune() {
+	entries, err := os.ReadDir(self.dir)
+	if err != nil {
+		return
+	}
+	type entry struct {
+		name  string
+		mtime time.Time
+	}
+	items := make([]entry, 0, len(entries))
+	for _, x := range entries {
+		if info, err := x.Info(); err == nil && info.Mode().IsRegular() {
+			items = append(items, entry{x.Name(), info.ModTime()})
+		}
+	}
+	slices.SortFunc(items, func(a, b entry) int { return b.mtime.Compare(a.mtime) })
+	for i, x := range items {
+		if i >= self.max_entries || time.Since(x.mtime) > self.max_age {
+			os.Remove(filepath.Join(self.dir, x.name))
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package diff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestDiffHighlightCache(t *testing.T) {
+	c := highlight_cache{dir: filepath.Join(t.TempDir(), "cache"), max_entries: 2, max_age: time.Hour}
+	keys := []string{highlight_cache_key("h", "Go", "default"), highlight_cache_key("h", "Python", "default"), highlight_cache_key("h", "Go", "monokai")}
+	if keys[0] == keys[1] || keys[0] == keys[2] || keys[1] == keys[2] {
+		t.Fatalf("Cache keys are not distinct: %v", keys)
+	}
+	if _, found := c.get(keys[0]); found {
+		t.Fatalf("Found entry in empty cache")
+	}
+	for i, key := range keys {
+		c.set(key, fmt.Sprint("highlighted", i))
+		then := time.Now().Add(time.Duration(i-len(keys)) * time.Minute)
+		if err := os.Chtimes(filepath.Join(c.dir, key), then, then); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q, found := c.get(keys[0]); !found || q != "highlighted0" {
+		t.Fatalf("Incorrect cache entry: %#v", q)
+	}
+	// keys[1] is now the least recently used
+	c.prune()
+	if _, found := c.get(keys[1]); found {
+		t.Fatalf("Least recently used entry not pruned")
+	}
+	if q, found := c.get(keys[2]); !found || q != "highlighted2" {
+		t.Fatalf("Incorrect cache entry after pruning: %#v", q)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(c.dir, keys[2]), old, old); err != nil {
+		t.Fatal(err)
+	}
+	c.prune()
+	if _, found := c.get(keys[2]); found {
+		t.Fatalf("Old entry not pruned")
+	}
+}
@@ -48,8 +48,16 @@ func exists(path string) bool {
 	return err == nil
 }
 
+var remote_temp_space *utils.TempSpace
+
 func get_ssh_file(hostname, rpath string) (string, error) {
-	tdir, err := os.MkdirTemp("", "*-"+hostname)
+	var err error
+	if remote_temp_space == nil {
+		if remote_temp_space, err = utils.NewTempSpace("kitty-diff"); err != nil {
+			return "", err
+		}
+	}
+	tdir, err := remote_temp_space.Claim(fmt.Sprintf("%d-%s", len(remote_dirs), hostname))
 	if err != nil {
 		return "", err
 	}
@@ -114,8 +122,8 @@ func main(_ *cli.Command, opts_ *Options, args []string) (rc int, err error) {
 	init_caches()
 	create_formatters()
 	defer func() {
-		for tdir := range remote_dirs {
-			os.RemoveAll(tdir)
+		if remote_temp_space != nil {
+			remote_temp_space.Close()
 		}
 	}()
 	left, err := get_remote_file(args[0])
@@ -53,6 +53,14 @@ def main(args: List[str]) -> None:
     long_text='The string to replace tabs with. Default is to use four spaces.'
     )
 
+opt('show_minimap', 'yes', option_type='to_bool',
+    long_text='''
+Show a one column map of the changes in the entire diff at the right edge of
+the screen, with the currently visible region highlighted. Click on the map to
+jump to the corresponding position in the diff.
+'''
+    )
+
 opt('+ignore_name', '', ctype='string',
     add_to_default=False,
     long_text='''
@@ -267,6 +275,8 @@ def main(args: List[str]) -> None:
     'search_backward_simple b start_search substring backward',
     )
 
+map('Toggle the minimap', 'toggle_minimap m toggle_minimap')
+
 map('Copy selection to clipboard', 'copy_to_clipboard y copy_to_clipboard')
 map('Copy selection to clipboard or exit if no selection is present', 'copy_to_clipboard_or_exit ctrl+c copy_to_clipboard_or_exit')
 
@@ -291,6 +301,15 @@ def main(args: List[str]) -> None:
 Override individual configuration options, can be specified multiple times.
 Syntax: :italic:`name=value`. For example: :italic:`-o background=gray`
 
+
+--accessible
+type=bool-set
+Make the diff usable with screen readers and braille displays. Changes are
+shown in a single column, with removed lines before the lines that replace
+them, instead of side-by-side. Changed lines are prefixed with :code:`-` or
+:code:`+` so that changes are not signalled by color alone, the start of every
+hunk is announced along with the lines it covers and the minimap is hidden.
+
 '''.format, config_help=CONFIG_HELP.format(conf_name='diff', appname=appname))
 help_text = 'Show a side-by-side diff of the specified files/directories. You can also use :italic:`ssh:hostname:remote-file-path` to diff remote files.'
 usage = 'file_or_directory_left file_or_directory_right'
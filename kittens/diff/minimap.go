@@ -0,0 +1,134 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package diff
+
+import (
+	"fmt"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type minimap_cell uint8
+
+const (
+	minimap_added minimap_cell = 1 << iota
+	minimap_removed
+)
+
+type minimap struct {
+	cells []minimap_cell
+	// The number of screen lines each cell represents is scale / len(cells)
+	scale int
+}
+
+// Summarize the changes in all screen lines into num_rows cells. When there
+// are fewer screen lines than rows, every screen line gets its own cell.
+func build_minimap(ll *LogicalLines, num_rows int) *minimap {
+	ans := minimap{cells: make([]minimap_cell, utils.Max(0, num_rows))}
+	total := 0
+	for _, line := range ll.lines {
+		total += len(line.screen_lines)
+	}
+	if total == 0 || num_rows < 1 {
+		return &ans
+	}
+	ans.scale = utils.Max(total, num_rows)
+	i := 0
+	for _, line := range ll.lines {
+		for _, sl := range line.screen_lines {
+			if line.line_type == CHANGE_LINE {
+				c := &ans.cells[i*num_rows/ans.scale]
+				switch line.linear_marker {
+				case added_marker:
+					*c |= minimap_added
+				case removed_marker:
+					*c |= minimap_removed
+				default:
+					if !sl.left.is_filler {
+						*c |= minimap_removed
+					}
+					if !sl.right.is_filler {
+						*c |= minimap_added
+					}
+				}
+			}
+			i++
+		}
+	}
+	return &ans
+}
+
+func (self *minimap) row_for_screen_line(i int) int {
+	if self.scale == 0 {
+		return 0
+	}
+	return i * len(self.cells) / self.scale
+}
+
+func (self *minimap) screen_line_for_row(row int) int {
+	return row * self.scale / utils.Max(1, len(self.cells))
+}
+
+func (self *Handler) minimap_shown() bool {
+	return self.show_minimap && self.screen_size.columns > 8
+}
+
+func (self *Handler) draw_minimap() {
+	if self.minimap == nil || !self.minimap_shown() {
+		return
+	}
+	first := self.logical_lines.NumScreenLinesTo(self.scroll_pos)
+	last := utils.Min(first+self.screen_size.num_lines, self.minimap.scale) - 1
+	first_row, last_row := self.minimap.row_for_screen_line(first), self.minimap.row_for_screen_line(last)
+	for row, c := range self.minimap.cells {
+		self.lp.MoveCursorTo(self.screen_size.columns, row+1)
+		switch c {
+		case minimap_added:
+			self.lp.QueueWriteString(format_as_sgr.minimap_added)
+		case minimap_removed:
+			self.lp.QueueWriteString(format_as_sgr.minimap_removed)
+		case minimap_added | minimap_removed:
+			self.lp.QueueWriteString(format_as_sgr.minimap_modified)
+		default:
+			self.lp.QueueWriteString(format_as_sgr.minimap)
+		}
+		self.lp.QueueWriteString(utils.IfElse(row >= first_row && row <= last_row, "┃", " "))
+		self.lp.QueueWriteString("\x1b[m")
+	}
+}
+
+// Scroll so that the region of the diff represented by the clicked cell in
+// the minimap is in the middle of the screen
+func (self *Handler) handle_minimap_click(ev *loop.MouseEvent) bool {
+	if self.minimap == nil || !self.minimap_shown() || ev.Cell.X != self.screen_size.columns-1 || ev.Cell.Y >= self.screen_size.num_lines {
+		return false
+	}
+	pos := ScrollPos{}
+	self.logical_lines.IncrementScrollPosBy(&pos, utils.Max(0, self.minimap.screen_line_for_row(ev.Cell.Y)-self.screen_size.num_lines/2))
+	if self.max_scroll_pos.Less(pos) {
+		pos = self.max_scroll_pos
+	}
+	if pos != self.scroll_pos {
+		self.scroll_pos = pos
+		self.draw_screen()
+	}
+	return true
+}
+
+func (self *Handler) toggle_minimap() error {
+	self.show_minimap = !self.show_minimap
+	if self.diff_map != nil && self.collection != nil {
+		self.clear_mouse_selection()
+		if err := self.render_diff(); err != nil {
+			return err
+		}
+		if self.max_scroll_pos.Less(self.scroll_pos) {
+			self.scroll_pos = self.max_scroll_pos
+		}
+	}
+	self.draw_screen()
+	return nil
+}
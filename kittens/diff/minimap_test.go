@@ -0,0 +1,36 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package diff
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestDiffMinimap(t *testing.T) {
+	line := func(ltype LineType, left_filler, right_filler bool) *LogicalLine {
+		return &LogicalLine{line_type: ltype, screen_lines: []*ScreenLine{{left: HalfScreenLine{is_filler: left_filler}, right: HalfScreenLine{is_filler: right_filler}}}}
+	}
+	ll := &LogicalLines{lines: []*LogicalLine{
+		line(TITLE_LINE, false, false), line(CONTEXT_LINE, false, false), line(CHANGE_LINE, true, false),
+		line(CHANGE_LINE, false, true), line(CONTEXT_LINE, false, false), line(CHANGE_LINE, false, false),
+	}}
+	m := build_minimap(ll, 3)
+	if diff := cmp.Diff([]minimap_cell{0, minimap_added | minimap_removed, minimap_added | minimap_removed}, m.cells); diff != "" {
+		t.Fatalf("Incorrect minimap:\n%s", diff)
+	}
+	if m.row_for_screen_line(3) != 1 || m.screen_line_for_row(2) != 4 {
+		t.Fatalf("Incorrect mapping between rows and screen lines")
+	}
+	m = build_minimap(ll, 8)
+	if diff := cmp.Diff([]minimap_cell{0, 0, minimap_added, minimap_removed, 0, minimap_added | minimap_removed, 0, 0}, m.cells); diff != "" {
+		t.Fatalf("Incorrect minimap for short diff:\n%s", diff)
+	}
+	if m.row_for_screen_line(3) != 3 || m.screen_line_for_row(5) != 5 {
+		t.Fatalf("Incorrect mapping between rows and screen lines for short diff")
+	}
+}
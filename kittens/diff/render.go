@@ -65,6 +65,9 @@ type LogicalLine struct {
 		count int
 	}
 	image_lines_offset int
+	// The textual change marker, removed_marker or added_marker, for full width
+	// change lines in the linear layout used in accessible mode
+	linear_marker string
 }
 
 func (self *LogicalLine) render_screen_line(n int, lp *loop.Loop, margin_size, columns int) {
@@ -84,8 +87,13 @@ func (self *LogicalLine) render_screen_line(n int, lp *loop.Loop, margin_size, c
 	} else {
 		switch self.line_type {
 		case CHANGE_LINE, IMAGE_LINE:
-			left_margin = format_as_sgr.removed_margin + left_margin
-			left_text = format_as_sgr.removed + left_text
+			if self.linear_marker == added_marker {
+				left_margin = format_as_sgr.added_margin + left_margin
+				left_text = format_as_sgr.added + left_text
+			} else {
+				left_margin = format_as_sgr.removed_margin + left_margin
+				left_text = format_as_sgr.removed + left_text
+			}
 		case HUNK_TITLE_LINE:
 			left_margin = format_as_sgr.hunk_margin + left_margin
 			left_text = format_as_sgr.hunk + left_text
@@ -157,6 +165,7 @@ func place_in(text string, sz int) string {
 
 var format_as_sgr struct {
 	title, margin, added, removed, added_margin, removed_margin, filler, margin_filler, hunk_margin, hunk, selection, search string
+	minimap, minimap_added, minimap_removed, minimap_modified                                                                string
 }
 
 var statusline_format, added_count_format, removed_count_format, message_format func(...any) string
@@ -183,6 +192,10 @@ func create_formatters() {
 	format_as_sgr.hunk = only_open(fmt.Sprintf("fg=%s bg=%s", conf.Margin_fg.AsRGBSharp(), conf.Hunk_bg.AsRGBSharp()))
 	format_as_sgr.hunk_margin = only_open(fmt.Sprintf("fg=%s bg=%s", conf.Margin_fg.AsRGBSharp(), conf.Hunk_margin_bg.AsRGBSharp()))
 	format_as_sgr.search = only_open(fmt.Sprintf("fg=%s bg=%s", conf.Search_fg.AsRGBSharp(), conf.Search_bg.AsRGBSharp()))
+	format_as_sgr.minimap = only_open(fmt.Sprintf("fg=%s bg=%s", conf.Margin_fg.AsRGBSharp(), conf.Margin_bg.AsRGBSharp()))
+	format_as_sgr.minimap_added = only_open(fmt.Sprintf("fg=%s bg=%s", conf.Margin_fg.AsRGBSharp(), conf.Highlight_added_bg.AsRGBSharp()))
+	format_as_sgr.minimap_removed = only_open(fmt.Sprintf("fg=%s bg=%s", conf.Margin_fg.AsRGBSharp(), conf.Highlight_removed_bg.AsRGBSharp()))
+	format_as_sgr.minimap_modified = only_open(fmt.Sprintf("fg=%s bg=%s", conf.Margin_fg.AsRGBSharp(), conf.Hunk_margin_bg.AsRGBSharp()))
 	statusline_format = ctx.SprintFunc(fmt.Sprintf("fg=%s", conf.Margin_fg.AsRGBSharp()))
 	added_count_format = ctx.SprintFunc(fmt.Sprintf("fg=%s", conf.Highlight_added_bg.AsRGBSharp()))
 	removed_count_format = ctx.SprintFunc(fmt.Sprintf("fg=%s", conf.Highlight_removed_bg.AsRGBSharp()))
@@ -213,7 +226,14 @@ func title_lines(left_path, right_path string, columns, margin_size int, ans []*
 		left_reference: Reference{path: left_path}, right_reference: Reference{path: right_path},
 	}
 	sl := ScreenLine{}
-	if right_name != "" && right_name != left_name {
+	if is_accessible() {
+		title := "File: " + sanitize(left_name)
+		if right_name != "" && right_name != left_name {
+			title = fmt.Sprintf("Files: %s and %s", sanitize(left_name), sanitize(right_name))
+		}
+		sl.left.marked_up_text = format_as_sgr.title + fit_in(title, columns-margin_size)
+		ll.is_full_width = true
+	} else if right_name != "" && right_name != left_name {
 		sl.left.marked_up_text = format_as_sgr.title + fit_in(sanitize(left_name), available_cols)
 		sl.right.marked_up_text = format_as_sgr.title + fit_in(sanitize(right_name), available_cols)
 	} else {
@@ -234,6 +254,8 @@ func title_lines(left_path, right_path string, columns, margin_size int, ans []*
 type LogicalLines struct {
 	lines                []*LogicalLine
 	margin_size, columns int
+	// all lines are full width, see is_accessible()
+	linear bool
 }
 
 func (self *LogicalLines) At(i int) *LogicalLine { return self.lines[i] }
@@ -470,6 +492,9 @@ func hunk_title(hunk *Hunk) string {
 }
 
 func lines_for_context_chunk(data *DiffData, hunk_num int, chunk *Chunk, chunk_num int, ans []*LogicalLine) []*LogicalLine {
+	if is_accessible() {
+		return linear_lines_for_context_chunk(data, chunk, ans)
+	}
 	for i := 0; i < chunk.left_count; i++ {
 		left_line_number := chunk.left_start + i
 		right_line_number := chunk.right_start + i
@@ -515,6 +540,9 @@ func render_half_line(line_number int, line, ltype string, available_cols int, c
 }
 
 func lines_for_diff_chunk(data *DiffData, hunk_num int, chunk *Chunk, chunk_num int, ans []*LogicalLine) []*LogicalLine {
+	if is_accessible() {
+		return linear_lines_for_diff_chunk(data, chunk, ans)
+	}
 	common := utils.Min(chunk.left_count, chunk.right_count)
 	ll, rl := make([]HalfScreenLine, 0, 32), make([]HalfScreenLine, 0, 32)
 	for i := 0; i < utils.Max(chunk.left_count, chunk.right_count); i++ {
@@ -587,7 +615,7 @@ func lines_for_diff(left_path string, right_path string, patch *Patch, columns,
 		ht.is_full_width = true
 		return append(ans, &ht), nil
 	}
-	available_cols := columns/2 - margin_size
+	available_cols := utils.IfElse(is_accessible(), columns-margin_size, columns/2-margin_size)
 	data := DiffData{left_path: left_path, right_path: right_path, available_cols: available_cols, margin_size: margin_size}
 	if left_path != "" {
 		data.left_lines, err = highlighted_lines_for_path(left_path)
@@ -606,7 +634,11 @@ func lines_for_diff(left_path string, right_path string, patch *Patch, columns,
 		htl := ht
 		htl.left_reference.linenum = hunk.left_start + 1
 		htl.right_reference.linenum = hunk.right_start + 1
-		for _, line := range splitlines(hunk_title(hunk), columns-margin_size) {
+		title := hunk_title(hunk)
+		if is_accessible() {
+			title = accessible_hunk_title(hunk_num, len(patch.all_hunks), hunk)
+		}
+		for _, line := range splitlines(title, columns-margin_size) {
 			sl := ScreenLine{}
 			sl.left.marked_up_text = line
 			htl.screen_lines = append(htl.screen_lines, &sl)
@@ -624,6 +656,9 @@ func lines_for_diff(left_path string, right_path string, patch *Patch, columns,
 }
 
 func all_lines(path string, columns, margin_size int, is_add bool, ans []*LogicalLine) ([]*LogicalLine, error) {
+	if is_accessible() {
+		return linear_all_lines(path, columns, margin_size, is_add, ans)
+	}
 	available_cols := columns/2 - margin_size
 	ltype := `add`
 	ll := LogicalLine{line_type: CHANGE_LINE}
@@ -695,6 +730,10 @@ func rename_lines(path, other_path string, columns, margin_size int, ans []*Logi
 
 func render(collection *Collection, diff_map map[string]*Patch, screen_size screen_size, largest_line_number int, image_size graphics.Size) (result *LogicalLines, err error) {
 	margin_size := utils.Max(3, len(strconv.Itoa(largest_line_number))+1)
+	if is_accessible() {
+		// space for the change markers
+		margin_size += 2
+	}
 	ans := make([]*LogicalLine, 0, 1024)
 	columns := screen_size.columns
 	err = collection.Apply(func(path, item_type, changed_path string) error {
@@ -766,5 +805,5 @@ func render(collection *Collection, diff_map map[string]*Patch, screen_size scre
 		// Having am empty list of lines causes panics later on
 		ll = []*LogicalLine{{line_type: EMPTY_LINE, screen_lines: []*ScreenLine{{}}}}
 	}
-	return &LogicalLines{lines: ll, margin_size: margin_size, columns: columns}, err
+	return &LogicalLines{lines: ll, margin_size: margin_size, columns: columns, linear: is_accessible()}, err
 }
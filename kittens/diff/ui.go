@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"kitty/tools/config"
 	"kitty/tools/tui"
@@ -74,6 +76,8 @@ type Handler struct {
 	current_search_is_regex, current_search_is_backward bool
 	largest_line_number                                 int
 	images_resized_to                                   graphics.Size
+	show_minimap                                        bool
+	minimap                                             *minimap
 }
 
 func (self *Handler) calculate_statistics() {
@@ -122,6 +126,8 @@ func (self *Handler) initialize() {
 	sz, _ := self.lp.ScreenSize()
 	self.update_screen_size(sz)
 	self.original_context_count = self.current_context_count
+	// the minimap signals changes only by color
+	self.show_minimap = conf.Show_minimap && !is_accessible()
 	self.lp.SetDefaultColor(loop.FOREGROUND, conf.Foreground)
 	self.lp.SetDefaultColor(loop.CURSOR, conf.Foreground)
 	self.lp.SetDefaultColor(loop.BACKGROUND, conf.Background)
@@ -177,14 +183,41 @@ func (self *Handler) on_wakeup() error {
 }
 
 func (self *Handler) highlight_all() {
-	text_files := utils.Filter(self.collection.paths_to_highlight.AsSlice(), is_path_text)
+	// highlight files in the order they are displayed, so that the first
+	// screen is highlighted first
+	seen := utils.NewSet[string](self.collection.paths_to_highlight.Len())
+	text_files := make([]string, 0, self.collection.paths_to_highlight.Len())
+	add := func(path string) {
+		if path != "" && self.collection.paths_to_highlight.Has(path) && !seen.Has(path) && is_path_text(path) {
+			seen.Add(path)
+			text_files = append(text_files, path)
+		}
+	}
+	_ = self.collection.Apply(func(path, item_type, changed_path string) error {
+		add(path)
+		add(changed_path)
+		return nil
+	})
+	for _, path := range self.collection.paths_to_highlight.AsSlice() {
+		add(path)
+	}
 	go func() {
-		r := AsyncResult{rtype: HIGHLIGHT}
-		highlight_all(text_files)
-		self.async_results <- r
+		var mutex sync.Mutex
+		last_update := time.Now()
+		// show highlighted files progressively, without re-rendering too often
+		on_progress := func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if now := time.Now(); now.Sub(last_update) > 250*time.Millisecond {
+				last_update = now
+				self.async_results <- AsyncResult{rtype: HIGHLIGHT}
+				self.lp.WakeupMainThread()
+			}
+		}
+		highlight_all(text_files, on_progress)
+		self.async_results <- AsyncResult{rtype: HIGHLIGHT}
 		self.lp.WakeupMainThread()
 	}()
-
 }
 
 func (self *Handler) load_all_images() {
@@ -298,10 +331,15 @@ func (self *Handler) render_diff() (err error) {
 	if self.screen_size.rows < 2 {
 		return fmt.Errorf("Screen too short, need at least 2 rows")
 	}
-	self.logical_lines, err = render(self.collection, self.diff_map, self.screen_size, self.largest_line_number, self.images_resized_to)
+	sz := self.screen_size
+	if self.minimap_shown() {
+		sz.columns--
+	}
+	self.logical_lines, err = render(self.collection, self.diff_map, sz, self.largest_line_number, self.images_resized_to)
 	if err != nil {
 		return err
 	}
+	self.minimap = build_minimap(self.logical_lines, self.screen_size.num_lines)
 	last := self.logical_lines.Len() - 1
 	self.max_scroll_pos.logical_line = last
 	if last > -1 {
@@ -378,6 +416,7 @@ func (self *Handler) draw_screen() {
 			break
 		}
 	}
+	self.draw_minimap()
 	self.draw_status_line()
 }
 
@@ -402,7 +441,11 @@ func (self *Handler) draw_status_line() {
 		sp := statusline_format(fmt.Sprintf("%d%%", frac))
 		var counts string
 		if self.current_search == nil {
-			counts = added_count_format(strconv.Itoa(self.added_count)) + statusline_format(`,`) + removed_count_format(strconv.Itoa(self.removed_count))
+			if is_accessible() {
+				counts = statusline_format(fmt.Sprintf("%d added, %d removed", self.added_count, self.removed_count))
+			} else {
+				counts = added_count_format(strconv.Itoa(self.added_count)) + statusline_format(`,`) + removed_count_format(strconv.Itoa(self.removed_count))
+			}
 		} else {
 			counts = statusline_format(fmt.Sprintf("%d matches", self.current_search.Len()))
 		}
@@ -599,6 +642,8 @@ func (self *Handler) dispatch_action(name, args string) error {
 		} else {
 			self.lp.CopyTextToClipboard(text)
 		}
+	case `toggle_minimap`:
+		return self.toggle_minimap()
 	case `scroll_by`:
 		if args == "" {
 			args = "1"
@@ -671,6 +716,9 @@ func (self *Handler) on_mouse_event(ev *loop.MouseEvent) error {
 		return nil
 	}
 	if ev.Event_type == loop.MOUSE_PRESS && ev.Buttons&loop.LEFT_MOUSE_BUTTON != 0 {
+		if self.handle_minimap_click(ev) {
+			return nil
+		}
 		self.start_mouse_selection(ev)
 		return nil
 	}
@@ -0,0 +1,77 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package help
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+func print_topic(t *Topic) {
+	width := 80
+	if sz, err := tty.GetSize(int(os.Stdout.Fd())); err == nil && sz.Col > 0 {
+		width = int(sz.Col)
+	}
+	for _, l := range FormatRST(doc_name_for(t), t.Text, width, false) {
+		fmt.Println(l)
+	}
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if opts.List {
+		for _, t := range AllTopics() {
+			fmt.Println(t.Name)
+		}
+		return
+	}
+	query := strings.Join(args, " ")
+	var topic *Topic
+	if query == "" {
+		topic = TopicByName(index_topic)
+	} else {
+		switch matches := FindTopics(query); len(matches) {
+		case 0:
+			return 1, fmt.Errorf("No documentation topic matching %#v found. Use --list to see all topics", query)
+		case 1:
+			topic = matches[0]
+		default:
+			topic = IndexTopic("Topics matching: "+query, matches)
+		}
+	}
+	if !tty.IsTerminal(os.Stdout.Fd()) {
+		print_topic(topic)
+		return
+	}
+	lp, err := loop.New()
+	if err != nil {
+		return 1, err
+	}
+	p := new_pager(lp, topic)
+	lp.OnInitialize = p.initialize
+	lp.OnFinalize = p.finalize
+	lp.OnKeyEvent = p.on_key_event
+	lp.OnText = p.on_text
+	lp.OnResize = p.on_resize
+	err = lp.Run()
+	if err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	return lp.ExitCode(), nil
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,33 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+help_text = '''\
+Browse the kitty documentation in the terminal, without needing a web browser or network access. The documentation
+for kitty, its protocols and kittens, all the :file:`kitty.conf` options and all mappable actions is available.
+Specify a topic such as :code:`graphics-protocol`, :code:`font_size` or :code:`new_window` to open it directly.
+When the topic does not match exactly, a list of the closest matching topics is shown. With no topic, a list of all
+topics is shown.
+'''
+usage = '[topic]'
+OPTIONS = r'''
+--list -l
+type=bool-set
+List the names of all available topics and exit.
+'''.format
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten help')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Browse the kitty documentation in the terminal'
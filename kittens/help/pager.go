@@ -0,0 +1,387 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package help
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/tui/readline"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type link_pos struct{ line, span int }
+
+type history_entry struct {
+	topic *Topic
+	top   int
+}
+
+type pager struct {
+	lp        *loop.Loop
+	formatter *formatter
+	history   []history_entry
+
+	topic         *Topic
+	lines         []Line
+	plain         []string
+	links         []link_pos
+	top, selected int
+	width, height int
+
+	searching  bool
+	search_rl  *readline.Readline
+	query      string
+	match_line int
+	message    string
+}
+
+func new_pager(lp *loop.Loop, topic *Topic) *pager {
+	ans := pager{lp: lp, topic: topic, formatter: new_formatter(true), selected: -1}
+	ans.search_rl = readline.New(lp, readline.RlInit{DontMarkPrompts: true, Prompt: "/"})
+	return &ans
+}
+
+func (self *pager) initialize() (string, error) {
+	self.lp.AllowLineWrapping(false)
+	self.lp.SetCursorVisible(false)
+	self.update_size()
+	self.load()
+	self.draw_screen()
+	self.lp.SendOverlayReady()
+	return "", nil
+}
+
+func (self *pager) finalize() string {
+	self.search_rl.Shutdown()
+	self.lp.SetCursorVisible(true)
+	return ""
+}
+
+func (self *pager) update_size() {
+	if sz, err := self.lp.ScreenSize(); err == nil {
+		self.width, self.height = int(sz.WidthCells), int(sz.HeightCells)
+	}
+}
+
+func doc_name_for(t *Topic) string {
+	if t.Kind() == "doc" {
+		return t.Identifier()
+	}
+	return ""
+}
+
+// Render the current topic for the current screen width
+func (self *pager) load() {
+	self.lines = RenderRST(doc_name_for(self.topic), self.topic.Text, self.width-1)
+	self.plain = make([]string, len(self.lines))
+	self.links = self.links[:0]
+	for i, l := range self.lines {
+		self.plain[i] = strings.ToLower(l.PlainText())
+		for j, s := range l.Spans {
+			if s.Target != "" || s.URL != "" {
+				self.links = append(self.links, link_pos{i, j})
+			}
+		}
+	}
+	self.selected = -1
+	self.lp.SetWindowTitle(self.topic.Title)
+}
+
+func (self *pager) page_height() int { return max(1, self.height-1) }
+
+func (self *pager) max_top() int { return max(0, len(self.lines)-self.page_height()) }
+
+func (self *pager) scroll_to(top int) {
+	self.top = max(0, min(top, self.max_top()))
+	if self.selected > -1 {
+		if l := self.links[self.selected].line; l < self.top || l >= self.top+self.page_height() {
+			self.selected = -1
+		}
+	}
+	self.draw_screen()
+}
+
+func (self *pager) highlight_matches(line string, plain string) string {
+	q := strings.ToLower(self.query)
+	b := strings.Builder{}
+	runes, lower := []rune(line), []rune(plain)
+	if len(runes) != len(lower) {
+		return self.lp.SprintStyled("reverse", line)
+	}
+	qr := []rune(q)
+	for i := 0; i < len(runes); {
+		if i+len(qr) <= len(lower) && string(lower[i:i+len(qr)]) == q {
+			b.WriteString(self.lp.SprintStyled("reverse", string(runes[i:i+len(qr)])))
+			i += len(qr)
+		} else {
+			b.WriteRune(runes[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func (self *pager) status_line() string {
+	if self.message != "" {
+		return self.lp.SprintStyled("fg=yellow", wcswidth.TruncateToVisualLength(self.message, self.width-1))
+	}
+	pos := "All"
+	if len(self.lines) > self.page_height() {
+		pos = fmt.Sprintf("%d%%", 100*(self.top+self.page_height())/len(self.lines))
+		if self.top == 0 {
+			pos = "Top"
+		}
+	}
+	right := fmt.Sprintf(" %s  Tab:links  /:search  t:topics  Backspace:back  q:quit ", pos)
+	if self.width < 90 {
+		right = " " + pos + " "
+	}
+	left := wcswidth.TruncateToVisualLength(" "+self.topic.Title, max(0, self.width-wcswidth.Stringwidth(right)))
+	pad := strings.Repeat(" ", max(0, self.width-wcswidth.Stringwidth(left)-wcswidth.Stringwidth(right)))
+	return self.lp.SprintStyled("reverse", left+pad+right)
+}
+
+func (self *pager) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	self.lp.ClearScreen()
+	selected := link_pos{-1, -1}
+	if self.selected > -1 {
+		selected = self.links[self.selected]
+	}
+	for i := self.top; i < min(len(self.lines), self.top+self.page_height()); i++ {
+		self.lp.MoveCursorTo(1, i-self.top+1)
+		sel := -1
+		if i == selected.line {
+			sel = selected.span
+		}
+		if self.query != "" && strings.Contains(self.plain[i], strings.ToLower(self.query)) {
+			self.lp.QueueWriteString(self.highlight_matches(self.lines[i].PlainText(), self.plain[i]))
+		} else {
+			self.lp.QueueWriteString(self.formatter.Line(self.lines[i], sel))
+		}
+	}
+	self.lp.MoveCursorTo(1, self.height)
+	if self.searching {
+		self.search_rl.RedrawNonAtomic()
+	} else {
+		self.lp.QueueWriteString(self.status_line())
+	}
+}
+
+func (self *pager) open(t *Topic) {
+	self.history = append(self.history, history_entry{self.topic, self.top})
+	self.topic, self.top, self.query = t, 0, ""
+	self.load()
+	self.draw_screen()
+}
+
+func (self *pager) go_back() {
+	if len(self.history) == 0 {
+		self.lp.Beep()
+		return
+	}
+	h := self.history[len(self.history)-1]
+	self.history = self.history[:len(self.history)-1]
+	self.topic, self.query = h.topic, ""
+	self.load()
+	self.scroll_to(h.top)
+}
+
+func (self *pager) select_link(delta int) {
+	if len(self.links) == 0 {
+		self.lp.Beep()
+		return
+	}
+	if self.selected < 0 {
+		// start from the first or last link on screen
+		bottom := self.top + self.page_height()
+		self.selected = len(self.links) - 1
+		for i, l := range self.links {
+			if l.line >= self.top {
+				self.selected = i
+				break
+			}
+		}
+		if delta < 0 {
+			for i := len(self.links) - 1; i >= 0; i-- {
+				if self.links[i].line < bottom {
+					self.selected = i
+					break
+				}
+			}
+		}
+	} else {
+		self.selected = (self.selected + delta + len(self.links)) % len(self.links)
+	}
+	l := self.links[self.selected].line
+	if l < self.top || l >= self.top+self.page_height() {
+		self.top = max(0, min(l-self.page_height()/2, self.max_top()))
+	}
+	self.draw_screen()
+}
+
+func (self *pager) follow_link() {
+	if self.selected < 0 {
+		self.scroll_to(self.top + 1)
+		return
+	}
+	l := self.links[self.selected]
+	s := self.lines[l.line].Spans[l.span]
+	if s.Target != "" {
+		if t := TopicByName(s.Target); t != nil {
+			self.open(t)
+			return
+		}
+	}
+	if s.URL != "" {
+		self.message = "External link: " + s.URL
+	} else {
+		self.message = "Not available offline: " + s.Text
+	}
+	self.draw_screen()
+}
+
+// Move to the next line matching the search query in the specified direction
+func (self *pager) find_next(forward bool) {
+	if self.query == "" {
+		self.lp.Beep()
+		return
+	}
+	q := strings.ToLower(self.query)
+	step := 1
+	if !forward {
+		step = -1
+	}
+	for i := self.match_line + step; i >= 0 && i < len(self.lines); i += step {
+		if strings.Contains(self.plain[i], q) {
+			self.match_line = i
+			self.scroll_to(i)
+			return
+		}
+	}
+	self.message = "Pattern not found: " + self.query
+	self.draw_screen()
+}
+
+func (self *pager) on_search_key_event(ev *loop.KeyEvent) error {
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc"):
+		self.searching = false
+		self.draw_screen()
+	case ev.MatchesPressOrRepeat("enter"):
+		self.searching = false
+		self.query = self.search_rl.AllText()
+		self.match_line = self.top - 1
+		self.find_next(true)
+	default:
+		ev.Handled = false
+		if err := self.search_rl.OnKeyEvent(ev); err != nil {
+			return err
+		}
+		if ev.Handled {
+			self.draw_screen()
+		}
+	}
+	return nil
+}
+
+func (self *pager) on_key_event(ev *loop.KeyEvent) error {
+	self.message = ""
+	if self.searching {
+		return self.on_search_key_event(ev)
+	}
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc"):
+		if self.query != "" || self.selected > -1 {
+			self.query, self.selected = "", -1
+			self.draw_screen()
+		} else {
+			self.lp.Quit(0)
+		}
+	case ev.MatchesPressOrRepeat("down"):
+		self.scroll_to(self.top + 1)
+	case ev.MatchesPressOrRepeat("up"):
+		self.scroll_to(self.top - 1)
+	case ev.MatchesPressOrRepeat("page_down"):
+		self.scroll_to(self.top + self.page_height())
+	case ev.MatchesPressOrRepeat("page_up"):
+		self.scroll_to(self.top - self.page_height())
+	case ev.MatchesPressOrRepeat("home"):
+		self.scroll_to(0)
+	case ev.MatchesPressOrRepeat("end"):
+		self.scroll_to(self.max_top())
+	case ev.MatchesPressOrRepeat("tab"):
+		self.select_link(1)
+	case ev.MatchesPressOrRepeat("shift+tab"):
+		self.select_link(-1)
+	case ev.MatchesPressOrRepeat("enter"):
+		self.follow_link()
+	case ev.MatchesPressOrRepeat("backspace") || ev.MatchesPressOrRepeat("alt+left"):
+		self.go_back()
+	default:
+		ev.Handled = false
+	}
+	return nil
+}
+
+func (self *pager) on_text(text string, from_key_event, in_bracketed_paste bool) error {
+	if self.searching {
+		if err := self.search_rl.OnText(text, from_key_event, in_bracketed_paste); err != nil {
+			return err
+		}
+		self.draw_screen()
+		return nil
+	}
+	switch text {
+	case "q":
+		self.lp.Quit(0)
+	case "j":
+		self.scroll_to(self.top + 1)
+	case "k":
+		self.scroll_to(self.top - 1)
+	case " ", "f":
+		self.scroll_to(self.top + self.page_height())
+	case "b":
+		self.scroll_to(self.top - self.page_height())
+	case "d":
+		self.scroll_to(self.top + self.page_height()/2)
+	case "u":
+		self.scroll_to(self.top - self.page_height()/2)
+	case "g":
+		self.scroll_to(0)
+	case "G":
+		self.scroll_to(self.max_top())
+	case "/":
+		self.searching = true
+		self.search_rl.ResetText()
+		self.draw_screen()
+	case "n":
+		self.find_next(true)
+	case "N":
+		self.find_next(false)
+	case "t":
+		if self.topic.Name != index_topic {
+			self.open(TopicByName(index_topic))
+		}
+	}
+	return nil
+}
+
+func (self *pager) on_resize(_, _ loop.ScreenSize) error {
+	frac := 0.0
+	if len(self.lines) > 0 {
+		frac = float64(self.top) / float64(len(self.lines))
+	}
+	self.update_size()
+	self.load()
+	self.top = max(0, min(int(frac*float64(len(self.lines))), self.max_top()))
+	self.draw_screen()
+	return nil
+}
@@ -0,0 +1,602 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package help
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"kitty"
+	"kitty/tools/cli/markup"
+	"kitty/tools/utils"
+	"kitty/tools/utils/style"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+// A run of text with a single style. Spans that are links have either the
+// name of the topic they refer to or a URL.
+type Span struct {
+	Text   string
+	Style  string
+	Target string
+	URL    string
+}
+
+type Line struct {
+	Indent int
+	Spans  []Span
+}
+
+func (self Line) PlainText() string {
+	b := strings.Builder{}
+	b.WriteString(strings.Repeat(" ", self.Indent))
+	for _, s := range self.Spans {
+		b.WriteString(s.Text)
+	}
+	return b.String()
+}
+
+type block_kind int
+
+const (
+	paragraph block_kind = iota
+	heading
+	literal
+	term
+)
+
+type block struct {
+	kind   block_kind
+	indent int
+	level  int
+	text   string
+	lines  []string
+	bullet string
+}
+
+type parser struct {
+	doc_name        string
+	heading_levels  []byte
+	blocks          []block
+	pending_literal bool
+}
+
+var heading_chars = "=-~^+*#\"'`"
+
+func is_underline(line string, for_text string) bool {
+	line = strings.TrimRight(line, " ")
+	if len(line) < 2 || !strings.ContainsRune(heading_chars, rune(line[0])) || strings.Trim(line, line[:1]) != "" {
+		return false
+	}
+	return for_text == "" || len(line) >= wcswidth.Stringwidth(for_text)
+}
+
+func leading_spaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func is_blank(line string) bool { return strings.TrimSpace(line) == "" }
+
+// Remove common indentation from lines
+func dedent(lines []string) []string {
+	ind := -1
+	for _, l := range lines {
+		if !is_blank(l) {
+			if n := leading_spaces(l); ind < 0 || n < ind {
+				ind = n
+			}
+		}
+	}
+	ans := make([]string, len(lines))
+	for i, l := range lines {
+		if len(l) >= ind && ind > 0 {
+			ans[i] = l[ind:]
+		} else {
+			ans[i] = strings.TrimLeft(l, " ")
+		}
+	}
+	return ans
+}
+
+// The lines starting at i that are blank or indented, with trailing blank
+// lines removed
+func indented_block(lines []string, i int) []string {
+	j := i
+	for j < len(lines) && (is_blank(lines[j]) || leading_spaces(lines[j]) > 0) {
+		j++
+	}
+	for j > i && is_blank(lines[j-1]) {
+		j--
+	}
+	return lines[i:j]
+}
+
+func (self *parser) heading_level(ch byte) int {
+	for i, x := range self.heading_levels {
+		if x == ch {
+			return i
+		}
+	}
+	self.heading_levels = append(self.heading_levels, ch)
+	return len(self.heading_levels) - 1
+}
+
+var directive_pat = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(`^\.\.\s+([a-zA-Z0-9_:-]+)::\s*(.*)$`)
+})
+var bullet_pat = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(`^([-*+•]|#\.|\d+\.|\(\d+\)) +`)
+})
+
+var admonitions = map[string]string{
+	"note": "Note", "warning": "Warning", "tip": "Tip", "important": "Important", "caution": "Caution",
+	"hint": "Hint", "seealso": "See also", "attention": "Attention", "danger": "Danger",
+}
+
+var version_notes = map[string]string{
+	"versionadded": "New in version", "versionchanged": "Changed in version", "deprecated": "Deprecated since version",
+}
+
+var cli_include_pat = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(`cli-kitten-(.+)\.rst$`)
+})
+
+func (self *parser) add(b block) {
+	self.blocks = append(self.blocks, b)
+}
+
+func (self *parser) directive(name, args string, body []string, indent int) {
+	body = dedent(body)
+	switch name {
+	case "code-block", "code", "sourcecode", "parsed-literal", "csv-table", "table":
+		for len(body) > 0 && (strings.HasPrefix(body[0], ":") || is_blank(body[0])) {
+			body = body[1:]
+		}
+		if len(body) > 0 {
+			self.add(block{kind: literal, indent: indent + 4, lines: body})
+		}
+	case "versionadded", "versionchanged", "deprecated":
+		text := version_notes[name] + " " + args
+		if len(body) > 0 {
+			text += ": " + strings.Join(body, " ")
+		}
+		self.add(block{kind: paragraph, indent: indent, text: "*" + strings.TrimSpace(text) + "*"})
+	case "envvar", "tab", "glossary", "only", "container", "rst-class":
+		if name == "envvar" || name == "tab" {
+			self.add(block{kind: term, indent: indent, text: args})
+			indent += 4
+		}
+		self.parse(body, indent)
+	case "include":
+		if m := cli_include_pat().FindStringSubmatch(args); m != nil {
+			self.add(block{kind: paragraph, indent: indent, text: fmt.Sprintf("Run ``kitten %s --help`` to see the command line options of this kitten.", m[1])})
+		}
+	default:
+		if title, found := admonitions[name]; found {
+			if args != "" {
+				body = append([]string{args}, body...)
+			}
+			self.add(block{kind: term, indent: indent, text: title + ":"})
+			self.parse(body, indent+4)
+		}
+		// Other directives such as images, toctrees and raw HTML have no
+		// useful text representation
+	}
+}
+
+func (self *parser) parse(lines []string, indent int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if is_blank(line) {
+			i++
+			continue
+		}
+		if leading_spaces(line) > 0 {
+			body := indented_block(lines, i)
+			i += len(body)
+			if self.pending_literal {
+				self.pending_literal = false
+				self.add(block{kind: literal, indent: indent + 4, lines: dedent(body)})
+			} else {
+				self.parse(dedent(body), indent+4)
+			}
+			continue
+		}
+		self.pending_literal = false
+		if strings.HasPrefix(line, "..") {
+			m := directive_pat().FindStringSubmatch(line)
+			body := indented_block(lines, i+1)
+			i += 1 + len(body)
+			if m != nil {
+				self.directive(m[1], strings.TrimSpace(m[2]), body, indent)
+			}
+			continue
+		}
+		// section titles with an overline
+		if i+2 < len(lines) && is_underline(line, "") && !is_blank(lines[i+1]) && strings.TrimRight(lines[i+2], " ") == strings.TrimRight(line, " ") {
+			self.add(block{kind: heading, indent: indent, level: self.heading_level(line[0]), text: strings.TrimSpace(lines[i+1])})
+			i += 3
+			continue
+		}
+		if i+1 < len(lines) && is_underline(lines[i+1], line) {
+			self.add(block{kind: heading, indent: indent, level: self.heading_level(lines[i+1][0]), text: strings.TrimSpace(line)})
+			i += 2
+			continue
+		}
+		if strings.HasPrefix(line, "+-") || strings.HasPrefix(line, "==") {
+			// tables are displayed as is
+			j := i
+			for j < len(lines) && !is_blank(lines[j]) {
+				j++
+			}
+			self.add(block{kind: literal, indent: indent, lines: lines[i:j]})
+			i = j
+			continue
+		}
+		if m := bullet_pat().FindString(line); m != "" {
+			body := []string{line[len(m):]}
+			j := i + 1
+			for j < len(lines) && !is_blank(lines[j]) && leading_spaces(lines[j]) == 0 && bullet_pat().FindString(lines[j]) == "" {
+				body = append(body, lines[j])
+				j++
+			}
+			rest := indented_block(lines, j)
+			body = append(body, dedent(rest)...)
+			first := len(self.blocks)
+			self.parse(body, indent+len(m))
+			if first < len(self.blocks) {
+				bullet := strings.TrimSpace(m)
+				if len(bullet) == 1 && strings.Contains("-*+", bullet) {
+					bullet = "•"
+				}
+				self.blocks[first].bullet = bullet
+			}
+			i = j + len(rest)
+			continue
+		}
+		// paragraph
+		j := i
+		for j < len(lines) && !is_blank(lines[j]) && leading_spaces(lines[j]) == 0 {
+			j++
+		}
+		text := strings.Join(lines[i:j], " ")
+		kind := paragraph
+		if j < len(lines) && !is_blank(lines[j]) && !strings.HasSuffix(text, "::") {
+			// a definition list term followed immediately by its indented definition
+			kind = term
+		}
+		i = j
+		if strings.HasSuffix(text, "::") {
+			self.pending_literal = true
+			text = strings.TrimSuffix(text, "::")
+			if strings.HasSuffix(text, " ") || text == "" {
+				text = strings.TrimRight(text, " ")
+			} else {
+				text += ":"
+			}
+		}
+		if text != "" {
+			self.add(block{kind: kind, indent: indent, text: text})
+		}
+	}
+}
+
+func expand_tabs(text string) string {
+	if !strings.Contains(text, "\t") {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		b := strings.Builder{}
+		col := 0
+		for _, ch := range l {
+			if ch == '\t' {
+				n := 8 - col%8
+				b.WriteString(strings.Repeat(" ", n))
+				col += n
+			} else {
+				b.WriteRune(ch)
+				col++
+			}
+		}
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parse_rst(doc_name, text string) []block {
+	p := parser{doc_name: doc_name}
+	lines := strings.Split(expand_tabs(strings.ReplaceAll(text, "\r\n", "\n")), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRightFunc(l, unicode.IsSpace)
+	}
+	p.parse(lines, 0)
+	return p.blocks
+}
+
+var inline_pat = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile("(?::(?P<role>[a-z]+(?::[a-z]+)?):`(?P<payload>[^`]+)`)|(?:``(?P<code>[^`]+?)``)|(?:\\*\\*(?P<bold>[^*]+)\\*\\*)|(?:\\*(?P<italic>[^*\\s][^*]*)\\*)|(?:`(?P<link>[^`]+)`__?)")
+})
+
+func doc_url(ref string) string {
+	return "kitty+doc://" + utils.Hostname() + "/#ref=" + ref
+}
+
+// Resolve a :doc: reference relative to the document it occurs in
+func resolve_doc(current, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.Trim(target, "/")
+	}
+	return strings.Trim(path.Join(path.Dir(current), target), "/")
+}
+
+func (self *parser) role_span(role, payload string) Span {
+	text, target := markup.Text_and_target(payload)
+	text = markup.Remove_backslash_escapes(text)
+	explicit := text != target
+	switch role {
+	case "doc":
+		name := resolve_doc(self.doc_name, target)
+		if title, found := kitty.DocTitleMap[name]; found && !explicit {
+			text = plain_title(title)
+		}
+		return Span{Text: text, Style: "fg=blue", Target: "doc:" + name, URL: doc_url("doc-" + name)}
+	case "ref":
+		ans := Span{Text: strings.ReplaceAll(text, "_", " "), Style: "fg=blue", URL: doc_url(target)}
+		if explicit {
+			ans.Text = text
+		}
+		if href, found := kitty.RefMap[target]; found {
+			if name, _, _ := strings.Cut(href, "/#"); name != "" && !strings.Contains(name, "://") {
+				ans.Target = "doc:" + strings.Trim(name, "/")
+			}
+		}
+		return ans
+	case "opt":
+		name := target
+		if !strings.Contains(name, ".") {
+			name = "kitty." + name
+		}
+		if explicit {
+			text = strings.TrimPrefix(text, "kitty.")
+		} else {
+			text = strings.TrimPrefix(target, "kitty.")
+		}
+		return Span{Text: text, Style: "bold", Target: "opt:" + name}
+	case "ac":
+		return Span{Text: text, Style: "bold", Target: "action:" + target, URL: doc_url("action-" + target)}
+	case "code", "kbd", "program":
+		return Span{Text: text, Style: "fg=bright-cyan"}
+	case "file", "emph", "term", "dfn":
+		return Span{Text: text, Style: "italic"}
+	case "option":
+		if idx := strings.LastIndex(text, "--"); idx > 0 && !explicit {
+			text = text[idx:]
+		}
+		return Span{Text: text, Style: "bold"}
+	case "env", "envvar":
+		return Span{Text: text, Style: "bold", URL: doc_url("envvar-" + target)}
+	case "iss", "pull", "disc":
+		kind := map[string]string{"iss": "issues", "pull": "pull", "disc": "discussions"}[role]
+		return Span{Text: text, Style: "fg=blue", URL: "https://github.com/kovidgoyal/kitty/" + kind + "/" + target}
+	case "link":
+		return Span{Text: text, Style: "fg=blue", URL: target}
+	}
+	return Span{Text: text}
+}
+
+func (self *parser) inline(text string) (ans []Span) {
+	// the only substitution used in the kitty documentation
+	text = strings.ReplaceAll(text, "|kitty|", "kitty")
+	pat := inline_pat()
+	names := pat.SubexpNames()
+	for len(text) > 0 {
+		loc := pat.FindStringSubmatchIndex(text)
+		if loc == nil {
+			ans = append(ans, Span{Text: markup.Remove_backslash_escapes(text)})
+			break
+		}
+		if loc[0] > 0 {
+			ans = append(ans, Span{Text: markup.Remove_backslash_escapes(text[:loc[0]])})
+		}
+		groups := make(map[string]string, len(names))
+		for i, name := range names {
+			if name != "" && loc[2*i] > -1 {
+				groups[name] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		switch {
+		case groups["role"] != "":
+			ans = append(ans, self.role_span(groups["role"], groups["payload"]))
+		case groups["code"] != "":
+			ans = append(ans, Span{Text: groups["code"], Style: "fg=bright-cyan"})
+		case groups["bold"] != "":
+			ans = append(ans, Span{Text: groups["bold"], Style: "bold"})
+		case groups["italic"] != "":
+			ans = append(ans, Span{Text: groups["italic"], Style: "italic"})
+		case groups["link"] != "":
+			t, u := markup.Text_and_target(groups["link"])
+			ans = append(ans, Span{Text: t, Style: "fg=blue", URL: u})
+		}
+		text = text[loc[1]:]
+	}
+	return
+}
+
+// Split spans into words, keeping the whitespace attached to the preceding word
+func split_words(spans []Span) (ans []Span) {
+	for _, s := range spans {
+		text := s.Text
+		for text != "" {
+			idx := strings.IndexByte(text, ' ')
+			if idx < 0 {
+				s.Text, text = text, ""
+			} else {
+				end := idx
+				for end < len(text) && text[end] == ' ' {
+					end++
+				}
+				s.Text, text = text[:end], text[end:]
+			}
+			ans = append(ans, s)
+		}
+	}
+	return
+}
+
+// Merge adjacent spans with the same attributes
+func merge_spans(spans []Span) (ans []Span) {
+	for _, s := range spans {
+		if n := len(ans); n > 0 && ans[n-1].Style == s.Style && ans[n-1].Target == s.Target && ans[n-1].URL == s.URL {
+			ans[n-1].Text += s.Text
+		} else {
+			ans = append(ans, s)
+		}
+	}
+	return
+}
+
+func wrap_spans(spans []Span, indent, first_indent, width int) (ans []Line) {
+	current := Line{Indent: first_indent}
+	col := 0
+	avail := func() int { return max(10, width-current.Indent) }
+	flush := func() {
+		if len(current.Spans) > 0 {
+			n := len(current.Spans) - 1
+			current.Spans[n].Text = strings.TrimRight(current.Spans[n].Text, " ")
+			current.Spans = merge_spans(current.Spans)
+			ans = append(ans, current)
+		}
+		current = Line{Indent: indent}
+		col = 0
+	}
+	for _, w := range split_words(spans) {
+		sz := wcswidth.Stringwidth(strings.TrimRight(w.Text, " "))
+		if col > 0 && col+sz > avail() {
+			flush()
+		}
+		current.Spans = append(current.Spans, w)
+		col += wcswidth.Stringwidth(w.Text)
+	}
+	flush()
+	return
+}
+
+// Render RST text into lines that fit in the specified width
+func RenderRST(doc_name, text string, width int) (ans []Line) {
+	p := parser{doc_name: doc_name}
+	blocks := parse_rst(doc_name, text)
+	var prev block
+	blank := func(b block) {
+		// definitions follow their terms directly
+		if prev.kind == term && b.indent > prev.indent {
+			return
+		}
+		if len(ans) > 0 && len(ans[len(ans)-1].Spans) > 0 {
+			ans = append(ans, Line{})
+		}
+	}
+	for _, b := range blocks {
+		is_list_item := b.bullet != ""
+		switch b.kind {
+		case heading:
+			blank(b)
+			style := "bold"
+			if b.level == 0 {
+				style = "bold fg=blue"
+			}
+			spans := p.inline(b.text)
+			for i := range spans {
+				spans[i].Style = strings.TrimSpace(spans[i].Style + " " + style)
+			}
+			ans = append(ans, wrap_spans(spans, b.indent, b.indent, width)...)
+		case literal:
+			blank(b)
+			for _, l := range b.lines {
+				ans = append(ans, Line{Indent: b.indent, Spans: []Span{{Text: l, Style: "fg=bright-cyan"}}})
+			}
+		default:
+			if !is_list_item || prev.bullet == "" || b.kind == term {
+				blank(b)
+			}
+			spans := p.inline(b.text)
+			if b.kind == term {
+				for i := range spans {
+					if spans[i].Style == "" {
+						spans[i].Style = "bold"
+					}
+				}
+			}
+			first_indent := b.indent
+			if b.bullet != "" {
+				first_indent = max(0, b.indent-wcswidth.Stringwidth(b.bullet)-1)
+				spans = append([]Span{{Text: b.bullet + strings.Repeat(" ", max(1, b.indent-first_indent-wcswidth.Stringwidth(b.bullet)))}}, spans...)
+			}
+			ans = append(ans, wrap_spans(spans, b.indent, first_indent, width)...)
+		}
+		prev = b
+	}
+	return
+}
+
+type formatter struct {
+	ctx    style.Context
+	sprint map[string]func(...any) string
+	url    map[string]func(string, string) string
+}
+
+func new_formatter(allow_escape_codes bool) *formatter {
+	return &formatter{ctx: style.Context{AllowEscapeCodes: allow_escape_codes}, sprint: make(map[string]func(...any) string), url: make(map[string]func(string, string) string)}
+}
+
+func (self *formatter) span(s Span, spec string) string {
+	if s.URL != "" {
+		f := self.url[spec]
+		if f == nil {
+			f = self.ctx.UrlFunc(spec)
+			self.url[spec] = f
+		}
+		return f(s.URL, s.Text)
+	}
+	if spec == "" {
+		return s.Text
+	}
+	f := self.sprint[spec]
+	if f == nil {
+		f = self.ctx.SprintFunc(spec)
+		self.sprint[spec] = f
+	}
+	return f(s.Text)
+}
+
+// Format a line for display, the span at index selected is highlighted
+func (self *formatter) Line(l Line, selected int) string {
+	b := strings.Builder{}
+	b.WriteString(strings.Repeat(" ", l.Indent))
+	for i, s := range l.Spans {
+		st := s.Style
+		if i == selected {
+			st = strings.TrimSpace(st + " reverse")
+		}
+		b.WriteString(self.span(s, st))
+	}
+	return b.String()
+}
+
+// Render RST text as lines of plain text, optionally with formatting escape
+// codes, suitable for printing to a terminal
+func FormatRST(doc_name, text string, width int, allow_escape_codes bool) []string {
+	f := new_formatter(allow_escape_codes)
+	lines := RenderRST(doc_name, text, width)
+	ans := make([]string, len(lines))
+	for i, l := range lines {
+		ans[i] = f.Line(l, -1)
+	}
+	return ans
+}
@@ -0,0 +1,91 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package help
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestHelpRenderRST(t *testing.T) {
+	render := func(doc, text string) (lines []string, targets []string) {
+		for _, l := range RenderRST(doc, text, 40) {
+			lines = append(lines, strings.TrimRight(l.PlainText(), " "))
+			for _, s := range l.Spans {
+				if s.Target != "" {
+					targets = append(targets, s.Target)
+				}
+			}
+		}
+		return
+	}
+	lines, targets := render("kittens/icat", strings.ReplaceAll(`
+Title
+=====
+
+.. highlight:: sh
+
+Some *text* with a :doc:¬link <../launch>¬ and an option :opt:¬font_size¬
+that wraps as it is long.
+
+- item one
+  continued
+- item :ac:¬two <new_window>¬
+
+Example::
+
+    kitten icat x.png
+
+.. note::
+   Be careful
+
+.. versionadded:: 0.35.0
+`, "¬", "`"))
+	expected := []string{
+		"Title", "",
+		"Some text with a link and an option", "font_size that wraps as it is long.", "",
+		"• item one continued", "• item two", "",
+		"Example:", "",
+		"    kitten icat x.png", "",
+		"Note:",
+		"    Be careful", "",
+		"New in version 0.35.0",
+	}
+	if diff := cmp.Diff(expected, lines); diff != "" {
+		t.Fatalf("Incorrect rendering:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"doc:launch", "opt:kitty.font_size", "action:new_window"}, targets); diff != "" {
+		t.Fatalf("Incorrect link targets:\n%s", diff)
+	}
+}
+
+func TestHelpTopics(t *testing.T) {
+	for q, expected := range map[string]string{
+		"doc:graphics-protocol": "doc:graphics-protocol", "font_size": "opt:kitty.font_size",
+		"new_window": "action:new_window", "icat": "doc:kittens/icat",
+	} {
+		m := FindTopics(q)
+		if len(m) != 1 || m[0].Name != expected {
+			t.Fatalf("Incorrect topic for %#v: %v", q, m)
+		}
+	}
+	if m := FindTopics("grphprot"); len(m) == 0 || m[0].Name != "doc:graphics-protocol" {
+		t.Fatalf("Fuzzy matching failed, got: %v", m)
+	}
+	linked := make(map[string]bool)
+	for _, l := range RenderRST("", IndexTopic("", AllTopics()).Text, 80) {
+		for _, s := range l.Spans {
+			if s.Target != "" && TopicByName(s.Target) != nil {
+				linked[s.Target] = true
+			}
+		}
+	}
+	if len(linked) != len(AllTopics()) {
+		t.Fatalf("The index links to %d topics instead of %d", len(linked), len(AllTopics()))
+	}
+}
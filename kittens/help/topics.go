@@ -0,0 +1,155 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package help
+
+import (
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"kitty/tools/cli/markup"
+	"kitty/tools/tui/subseq"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+//go:embed data_generated.bin
+var help_data string
+
+// A topic is a single page of documentation: a document from the kitty
+// documentation, a kitty.conf option or a mappable action. The name of a
+// topic is its kind followed by a colon and its identifier, for example:
+// doc:graphics-protocol, opt:kitty.font_size or action:new_window
+type Topic struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+const index_topic = "topics"
+
+func (self *Topic) Kind() string {
+	kind, _, _ := strings.Cut(self.Name, ":")
+	return kind
+}
+
+func (self *Topic) Identifier() string {
+	_, q, _ := strings.Cut(self.Name, ":")
+	return q
+}
+
+// The names a user can use to refer to this topic
+func (self *Topic) short_names() []string {
+	q := self.Identifier()
+	ans := []string{self.Name, q}
+	switch self.Kind() {
+	case "doc":
+		if idx := strings.LastIndexByte(q, '/'); idx > -1 {
+			ans = append(ans, q[idx+1:])
+		}
+	case "opt":
+		ans = append(ans, strings.TrimPrefix(q, "kitty."))
+	}
+	return ans
+}
+
+// Titles can contain markup, such as: The :command:`launch` command
+func plain_title(title string) string {
+	return markup.ReplaceAllRSTRoles(title, func(m markup.Rst_format_match) string { return m.Payload })
+}
+
+var AllTopics = sync.OnceValue(func() []*Topic {
+	var ans []*Topic
+	if err := json.Unmarshal(utils.ReadCompressedEmbeddedData(help_data), &ans); err != nil {
+		panic(err)
+	}
+	for _, t := range ans {
+		t.Title = plain_title(t.Title)
+	}
+	return ans
+})
+
+var topic_map = sync.OnceValue(func() map[string]*Topic {
+	ans := make(map[string]*Topic)
+	for _, t := range AllTopics() {
+		ans[t.Name] = t
+	}
+	return ans
+})
+
+func TopicByName(name string) *Topic {
+	if name == index_topic {
+		return IndexTopic("", AllTopics())
+	}
+	return topic_map()[name]
+}
+
+var kind_titles = []struct{ kind, title, role string }{
+	{"doc", "Documentation", "doc"}, {"opt", "Options for kitty.conf", "opt"}, {"action", "Mappable actions", "ac"},
+}
+
+// A synthetic topic that links to all the specified topics
+func IndexTopic(title string, topics []*Topic) *Topic {
+	b := strings.Builder{}
+	if title == "" {
+		title = "kitty documentation"
+	}
+	fmt.Fprintf(&b, "%s\n%s\n\n", title, strings.Repeat("=", len(title)))
+	for _, k := range kind_titles {
+		items := utils.Filter(topics, func(t *Topic) bool { return t.Kind() == k.kind })
+		if len(items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s\n%s\n\n", k.title, strings.Repeat("-", len(k.title)))
+		for _, t := range items {
+			target := t.Identifier()
+			if k.kind == "doc" {
+				target = "/" + target
+			}
+			fmt.Fprintf(&b, "- :%s:`%s <%s>`\n", k.role, t.Title, target)
+		}
+		b.WriteString("\n")
+	}
+	return &Topic{Name: index_topic, Title: title, Text: b.String()}
+}
+
+// Find the topics matching query. Exact matches for the name of a topic are
+// preferred, otherwise the topics are fuzzy matched against the query.
+func FindTopics(query string) []*Topic {
+	query = strings.TrimSpace(query)
+	if t := TopicByName(query); t != nil {
+		return []*Topic{t}
+	}
+	all := AllTopics()
+	var exact []*Topic
+	for _, t := range all {
+		if slices.Contains(t.short_names(), query) {
+			exact = append(exact, t)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	texts := make([]string, len(all))
+	for i, t := range all {
+		texts[i] = t.Identifier() + " " + t.Title
+	}
+	matches := subseq.NewMatcher(texts, subseq.Options{}).Score(query)
+	order := make([]int, 0, len(matches))
+	for i, m := range matches {
+		if m.Score > 0 {
+			order = append(order, i)
+		}
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(matches[b].Score, matches[a].Score) })
+	ans := make([]*Topic, 0, len(order))
+	for _, i := range order[:min(len(order), 100)] {
+		ans = append(ans, all[i])
+	}
+	return ans
+}
@@ -137,7 +137,6 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 	if alphabet == "" {
 		alphabet = DEFAULT_HINT_ALPHABET
 	}
-	ignore_mark_indices := utils.NewSet[int](8)
 	window_title := o.WindowTitle
 	if window_title == "" {
 		switch o.Type {
@@ -159,7 +158,8 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 			match_suffix = " "
 		}
 	}
-	chosen := []*Mark{}
+	// the chosen marks, by index, in the order they were chosen
+	chosen := utils.NewOrderedMap[int, *Mark]()
 	lp, err := loop.New(loop.NoAlternateScreen) // no alternate screen reduces flicker on exit
 	if err != nil {
 		return
@@ -174,6 +174,9 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 		if current_input != "" && !strings.HasPrefix(hint, current_input) {
 			return faint(mark_text)
 		}
+		if m.is_continuation {
+			return text_style(mark_text)
+		}
 		hint = hint[len(current_input):]
 		if hint == "" {
 			hint = " "
@@ -190,7 +193,7 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 		ans := text
 		for i := len(all_marks) - 1; i >= 0; i-- {
 			mark := &all_marks[i]
-			if ignore_mark_indices.Has(mark.Index) {
+			if chosen.Has(mark.Index) {
 				continue
 			}
 			mtext := highlight_mark(mark, ans[mark.Start:mark.End])
@@ -246,9 +249,8 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 				}
 			}
 			if len(matches) == 1 {
-				chosen = append(chosen, matches[0])
+				chosen.SetIfAbsent(matches[0].Index, matches[0])
 				if o.Multiple {
-					ignore_mark_indices.Add(matches[0].Index)
 					reset()
 				} else {
 					lp.Quit(0)
@@ -276,8 +278,7 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 			if current_input != "" {
 				idx := decode_hint(current_input, alphabet)
 				if m := index_map[idx]; m != nil {
-					chosen = append(chosen, m)
-					ignore_mark_indices.Add(idx)
+					chosen.SetIfAbsent(idx, m)
 					if o.Multiple {
 						reset()
 						draw_screen()
@@ -313,9 +314,9 @@ func main(_ *cli.Command, o *Options, args []string) (rc int, err error) {
 	if lp.ExitCode() != 0 {
 		return lp.ExitCode(), nil
 	}
-	result.Match = make([]string, len(chosen))
-	result.Groupdicts = make([]map[string]any, len(chosen))
-	for i, m := range chosen {
+	result.Match = make([]string, chosen.Len())
+	result.Groupdicts = make([]map[string]any, chosen.Len())
+	for i, m := range chosen.Values() {
 		result.Match[i] = m.Text + match_suffix
 		result.Groupdicts[i] = m.Groupdict
 	}
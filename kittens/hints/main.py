@@ -110,6 +110,10 @@ def custom_marking() -> None:
 must have the named groups: :code:`path` and :code:`line`. If not specified,
 will look for :code:`path:line`. The :option:`--linenum-action` option
 controls where to display the selected error message, other options are ignored.
+A value of :code:`hyperlink` selects the actual OSC 8 hyperlinks on the screen,
+rather than searching the text, so links that are wrapped, styled or whose text
+does not look like a URL are selected exactly. All the parts of a hyperlink
+that share the same id, such as a link drawn one line at a time, get a single hint.
 
 
 --regex
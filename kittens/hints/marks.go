@@ -49,6 +49,9 @@ type Mark struct {
 	Group_id     string         `json:"group_id"`
 	Is_hyperlink bool           `json:"is_hyperlink"`
 	Groupdict    map[string]any `json:"groupdict"`
+	// set for the second and subsequent parts of a hyperlink split into
+	// multiple parts, which share the index of the first part
+	is_continuation bool
 }
 
 func process_escape_codes(text string) (ans string, hyperlinks []Mark) {
@@ -56,13 +59,26 @@ func process_escape_codes(text string) (ans string, hyperlinks []Mark) {
 	active_hyperlink_url := ""
 	active_hyperlink_id := ""
 	active_hyperlink_start_offset := 0
+	// parts of the screen with the same hyperlink id and URL are the same
+	// hyperlink, for example, when a program redraws a link one line at a time
+	index_for_id := make(map[string]int)
 
 	add_hyperlink := func(end int) {
-		hyperlinks = append(hyperlinks, Mark{
-			Index: idx, Start: active_hyperlink_start_offset, End: end, Text: active_hyperlink_url, Is_hyperlink: true, Group_id: active_hyperlink_id})
+		key := active_hyperlink_id + "\x00" + active_hyperlink_url
+		if n := len(hyperlinks); n > 0 && hyperlinks[n-1].End == active_hyperlink_start_offset && hyperlinks[n-1].Text == active_hyperlink_url && hyperlinks[n-1].Group_id == active_hyperlink_id {
+			// adjacent parts, for example, when only the formatting changes
+			hyperlinks[n-1].End = end
+		} else if i, found := index_for_id[key]; found && active_hyperlink_id != "" {
+			hyperlinks = append(hyperlinks, Mark{
+				Index: i, Start: active_hyperlink_start_offset, End: end, Text: active_hyperlink_url, Is_hyperlink: true, Group_id: active_hyperlink_id, is_continuation: true})
+		} else {
+			hyperlinks = append(hyperlinks, Mark{
+				Index: idx, Start: active_hyperlink_start_offset, End: end, Text: active_hyperlink_url, Is_hyperlink: true, Group_id: active_hyperlink_id})
+			index_for_id[key] = idx
+			idx++
+		}
 		active_hyperlink_url, active_hyperlink_id = "", ""
 		active_hyperlink_start_offset = 0
-		idx++
 	}
 
 	ans = utils.ReplaceAll(utils.MustCompile("\x1b(?:\\[[0-9;:]*?m|\\].*?\x1b\\\\)"), text, func(raw string, groupdict map[string]utils.SubMatch) string {
@@ -636,9 +652,9 @@ func adjust_python_offsets(text string, marks []Mark) error {
 func (self *ErrNoMatches) Error() string {
 	none_of := "matches"
 	switch self.Type {
-	case "urls":
+	case "url":
 		none_of = "URLs"
-	case "hyperlinks":
+	case "hyperlink":
 		none_of = "hyperlinks"
 	}
 	if self.Pattern != "" {
@@ -666,7 +682,11 @@ func find_marks(text string, opts *Options, cli_args ...string) (sanitized_text
 	}
 
 	if opts.CustomizeProcessing != "" {
-		cmd := exec.Command(utils.KittyExe(), append([]string{"+runpy", "from kittens.hints.main import custom_marking; custom_marking()"}, cli_args...)...)
+		var kitty_exe string
+		if kitty_exe, err = utils.FindKittyExe(); err != nil {
+			return "", nil, nil, fmt.Errorf("Failed to run custom processor %#v with error: %w", opts.CustomizeProcessing, err)
+		}
+		cmd := exec.Command(kitty_exe, append([]string{"+runpy", "from kittens.hints.main import custom_marking; custom_marking()"}, cli_args...)...)
 		cmd.Stdin = strings.NewReader(sanitized_text)
 		stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
 		cmd.Stdout, cmd.Stderr = &stdout, &stderr
@@ -706,7 +726,10 @@ process_answer:
 	if len(ans) == 0 {
 		return "", nil, nil, &ErrNoMatches{Type: opts.Type, Pattern: used_pattern}
 	}
-	largest_index := ans[len(ans)-1].Index
+	largest_index := 0
+	for _, m := range ans {
+		largest_index = max(largest_index, m.Index)
+	}
 	offset := max(0, opts.HintsOffset)
 	index_map = make(map[int]*Mark, len(ans))
 	for i := range ans {
@@ -716,7 +739,9 @@ process_answer:
 		} else {
 			m.Index = largest_index - m.Index + offset
 		}
-		index_map[m.Index] = m
+		if !m.is_continuation {
+			index_map[m.Index] = m
+		}
 	}
 	return
 }
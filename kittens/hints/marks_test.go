@@ -142,3 +142,31 @@ def mark(text, args, Mark, extra_cli_args, *a):
 	os.WriteFile(simple, []byte(""), 0o600)
 	r("a b", `b`)
 }
+
+func TestHyperlinkMarks(t *testing.T) {
+	opts := &Options{Type: "hyperlink", Ascending: true}
+	link := func(id, url, text string) string {
+		return fmt.Sprintf("\x1b]8;%s;%s\x1b\\%s\x1b]8;;\x1b\\", utils.IfElse(id == "", "", "id="+id), url, text)
+	}
+	text := link("1", "http://a", "first") + "\n" + link("", "http://b", "second") + " " + link("1", "http://a", "rest") + "\n" +
+		"\x1b]8;;http://c\x1b\\st\x1b[1m\x1b]8;;http://c\x1b\\yled" + link("", "", "")
+	sanitized, marks, index_map, err := find_marks(text, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type mark struct {
+		Index        int
+		Text, Marked string
+	}
+	actual := utils.Map(func(m Mark) mark { return mark{m.Index, m.Text, sanitized[m.Start:m.End]} }, marks)
+	expected := []mark{{0, "http://a", "first"}, {1, "http://b", "second"}, {0, "http://a", "rest"}, {2, "http://c", "styled"}}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Incorrect hyperlink marks:\n%s", diff)
+	}
+	if len(index_map) != 3 || index_map[0] != &marks[0] {
+		t.Fatalf("Incorrect index map: %v", index_map)
+	}
+	if _, _, _, err = find_marks("no links", opts); err == nil || err.Error() != "No hyperlinks found" {
+		t.Fatalf("Incorrect error: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"kitty/kittens/clipboard"
+	"kitty/tools/tui"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// The MIME types to read from the clipboard, in order of preference, after
+// which any other image type is used
+var preferred_clipboard_image_types = []string{"image/png", "image/jpeg"}
+
+func clipboard_image_type(available []string) string {
+	for _, q := range preferred_clipboard_image_types {
+		for _, x := range available {
+			if x == q {
+				return x
+			}
+		}
+	}
+	for _, x := range available {
+		if strings.HasPrefix(x, "image/") {
+			return x
+		}
+	}
+	return ""
+}
+
+func clipboard_metadata(ptype, mime string) map[string]string {
+	ans := map[string]string{"type": ptype}
+	if mime != "" {
+		ans["mime"] = mime
+	}
+	return ans
+}
+
+func check_clipboard_usable(what string) error {
+	if m := tui.DetectMultiplexer(); m != tui.NoMultiplexer {
+		return tui.ResponsesNotSupportedError(what, m)
+	}
+	return nil
+}
+
+func new_clipboard_loop() (*loop.Loop, error) {
+	return loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+}
+
+// Read image data from the clipboard using the kitty clipboard protocol
+func read_image_from_clipboard() (data []byte, mime string, err error) {
+	if err = check_clipboard_usable("Reading images from the clipboard"); err != nil {
+		return
+	}
+	lp, err := new_clipboard_loop()
+	if err != nil {
+		return
+	}
+	reading_available_types := true
+	lp.OnInitialize = func() (string, error) {
+		lp.QueueWriteString(clipboard.EncodeEscapeCode(clipboard_metadata("read", ""), []byte(".")))
+		return "", nil
+	}
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, raw []byte) error {
+		metadata, payload, err := clipboard.ParseEscapeCode(etype, raw)
+		if err != nil || metadata == nil {
+			return err
+		}
+		switch status := metadata["status"]; status {
+		case "OK":
+		case "DATA":
+			if reading_available_types {
+				mime = clipboard_image_type(utils.Filter(strings.Split(utils.UnsafeBytesToString(payload), " "), func(x string) bool { return x != "" }))
+			} else if metadata["mime"] == mime {
+				data = append(data, payload...)
+			}
+		case "DONE":
+			if !reading_available_types {
+				lp.Quit(0)
+				return nil
+			}
+			if mime == "" {
+				return fmt.Errorf("The clipboard does not contain an image")
+			}
+			reading_available_types = false
+			lp.QueueWriteString(clipboard.EncodeEscapeCode(clipboard_metadata("read", ""), []byte(mime)))
+		default:
+			what := "the image"
+			if reading_available_types {
+				what = "the list of available data types"
+			}
+			return fmt.Errorf("Failed to read %s from the clipboard with error: %s", what, status)
+		}
+		return nil
+	}
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") {
+			event.Handled = true
+			return fmt.Errorf("Canceled by user")
+		}
+		return nil
+	}
+	if err = lp.Run(); err != nil {
+		return nil, "", err
+	}
+	if ds := lp.DeathSignalName(); ds != "" {
+		lp.KillIfSignalled()
+		return nil, "", fmt.Errorf("Killed by signal: %s", ds)
+	}
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("The image in the clipboard is empty")
+	}
+	return
+}
+
+// Copy the data of the specified image file to the clipboard using the kitty
+// clipboard protocol
+func write_image_to_clipboard(path string) (err error) {
+	if err = check_clipboard_usable("Copying images to the clipboard"); err != nil {
+		return
+	}
+	mime := utils.GuessMimeType(path)
+	if !strings.HasPrefix(mime, "image/") {
+		return fmt.Errorf("Could not detect the image type of %s, cannot copy it to the clipboard", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lp, err := new_clipboard_loop()
+	if err != nil {
+		return
+	}
+	lp.OnInitialize = func() (string, error) {
+		const chunk_size = 4096
+		lp.QueueWriteString(clipboard.EncodeEscapeCode(clipboard_metadata("write", ""), nil))
+		for remaining := data; len(remaining) > 0; {
+			chunk := remaining[:min(chunk_size, len(remaining))]
+			remaining = remaining[len(chunk):]
+			lp.QueueWriteString(clipboard.EncodeEscapeCode(clipboard_metadata("wdata", mime), chunk))
+		}
+		lp.QueueWriteString(clipboard.EncodeEscapeCode(clipboard_metadata("wdata", ""), nil))
+		return "", nil
+	}
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, raw []byte) error {
+		metadata, _, err := clipboard.ParseEscapeCode(etype, raw)
+		if err != nil || metadata == nil || metadata["type"] != "write" {
+			return err
+		}
+		switch status := metadata["status"]; status {
+		case "DONE":
+			lp.Quit(0)
+		case "EPERM":
+			return fmt.Errorf("Permission denied copying to the clipboard, see the clipboard_control setting in kitty.conf")
+		default:
+			return fmt.Errorf("Failed to copy %s to the clipboard with error: %s", path, status)
+		}
+		return nil
+	}
+	if err = lp.Run(); err != nil {
+		return err
+	}
+	if ds := lp.DeathSignalName(); ds != "" {
+		lp.KillIfSignalled()
+		return fmt.Errorf("Killed by signal: %s", ds)
+	}
+	return
+}
@@ -50,9 +50,18 @@ func render_image_with_magick(imgd *image_data, src *opened_input) (err error) {
 	if scale_image(imgd) {
 		ro.ResizeTo.X, ro.ResizeTo.Y = imgd.canvas_width, imgd.canvas_height
 	}
+	key, kerr := magick_cache_key(src.FileSystemName(), &ro)
+	if kerr == nil {
+		if imgd.frames, err = load_from_magick_cache(key); err == nil {
+			return nil
+		}
+	}
 	imgd.frames, err = Render(src.FileSystemName(), &ro, frames)
 	if err != nil {
 		return err
 	}
+	if kerr == nil {
+		_ = store_in_magick_cache(key, imgd.frames)
+	}
 	return nil
 }
@@ -0,0 +1,138 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"kitty/tools/tui/graphics"
+	"kitty/tools/utils"
+	"kitty/tools/utils/images"
+)
+
+var _ = fmt.Print
+
+// Converting images with ImageMagick is slow, so the converted frames are
+// stored in the icat namespace of the cache directory, keyed by the contents
+// of the image and the options used to render it
+var magick_cache_dir = func() string { return filepath.Join(utils.CacheDir(), "icat") }
+
+type cached_frame struct {
+	Number, Width, Height, Left, Top int
+	Compose_onto, Delay_ms           int
+	Is_opaque                        bool
+}
+
+func magick_cache_key(path string, ro *images.RenderOptions) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	remove_alpha := ""
+	if ro.RemoveAlpha != nil {
+		remove_alpha = ro.RemoveAlpha.AsSharp()
+	}
+	fmt.Fprintf(h, "\x00%s:%v:%v:%v:%v", remove_alpha, ro.Flip, ro.Flop, ro.ResizeTo, ro.OnlyFirstFrame)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Return the frames stored in the cache for key, copied to temporary files as
+// the files of frames are deleted once they are transmitted
+func load_from_magick_cache(key string) (ans []*image_frame, err error) {
+	dir := filepath.Join(magick_cache_dir(), key)
+	data, err := os.ReadFile(filepath.Join(dir, "frames.json"))
+	if err != nil {
+		return nil, err
+	}
+	var frames []cached_frame
+	if err = json.Unmarshal(data, &frames); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			for _, f := range ans {
+				os.Remove(f.filename)
+			}
+			ans = nil
+		}
+	}()
+	cm := utils.DefaultCacheManager()
+	copy_frame := func(path string) (string, error) {
+		src, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer src.Close()
+		dest, err := images.CreateTempInRAM()
+		if err != nil {
+			return "", err
+		}
+		defer dest.Close()
+		if _, err = io.Copy(dest, src); err != nil {
+			os.Remove(dest.Name())
+			return "", err
+		}
+		_ = cm.Touch(path)
+		return dest.Name(), nil
+	}
+	for _, cf := range frames {
+		fname, err := copy_frame(filepath.Join(dir, strconv.Itoa(cf.Number)))
+		if err != nil {
+			return ans, err
+		}
+		ans = append(ans, &image_frame{
+			filename: fname, filename_is_temporary: true,
+			number: cf.Number, width: cf.Width, height: cf.Height, left: cf.Left, top: cf.Top,
+			delay_ms: cf.Delay_ms, compose_onto: cf.Compose_onto,
+			transmission_format: utils.IfElse(cf.Is_opaque, graphics.GRT_format_rgb, graphics.GRT_format_rgba),
+		})
+	}
+	_ = cm.Touch(filepath.Join(dir, "frames.json"))
+	return ans, nil
+}
+
+// Store copies of the files of the rendered frames in the cache under key
+func store_in_magick_cache(key string, frames []*image_frame) (err error) {
+	root := magick_cache_dir()
+	if err = os.MkdirAll(root, 0o700); err != nil {
+		return err
+	}
+	tdir, err := os.MkdirTemp(root, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tdir)
+	cf := make([]cached_frame, len(frames))
+	for i, f := range frames {
+		if err = utils.CopyFile(f.filename, filepath.Join(tdir, strconv.Itoa(f.number))); err != nil {
+			return err
+		}
+		cf[i] = cached_frame{
+			Number: f.number, Width: f.width, Height: f.height, Left: f.left, Top: f.top,
+			Compose_onto: f.compose_onto, Delay_ms: f.delay_ms, Is_opaque: f.transmission_format == graphics.GRT_format_rgb,
+		}
+	}
+	data, err := json.Marshal(cf)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(tdir, "frames.json"), data, 0o600); err != nil {
+		return err
+	}
+	// replace any entry some of whose files were evicted
+	dest := filepath.Join(root, key)
+	_ = os.RemoveAll(dest)
+	return os.Rename(tdir, dest)
+}
@@ -0,0 +1,79 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kitty/tools/tui/graphics"
+	"kitty/tools/utils/images"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestMagickCache(t *testing.T) {
+	tdir := t.TempDir()
+	orig := magick_cache_dir
+	magick_cache_dir = func() string { return filepath.Join(tdir, "cache") }
+	defer func() { magick_cache_dir = orig }()
+
+	src := filepath.Join(tdir, "image")
+	if err := os.WriteFile(src, []byte("image data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := magick_cache_key(src, &images.RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, _ := magick_cache_key(src, &images.RenderOptions{Flip: true}); k == key {
+		t.Fatalf("The cache key does not depend on the render options")
+	}
+	if _, err = load_from_magick_cache(key); err == nil {
+		t.Fatalf("Loading a missing entry did not fail")
+	}
+	var frames []*image_frame
+	for i := 1; i < 3; i++ {
+		fname := filepath.Join(tdir, fmt.Sprint(i))
+		if err := os.WriteFile(fname, []byte(fmt.Sprint("frame ", i)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, &image_frame{
+			filename: fname, filename_is_temporary: true, number: i, width: 10 * i, height: 5, left: i, top: 2,
+			transmission_format: graphics.GRT_format_rgb, delay_ms: 40, compose_onto: i - 1,
+		})
+	}
+	if err = store_in_magick_cache(key, frames); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := load_from_magick_cache(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range loaded {
+		defer os.Remove(f.filename)
+		data, err := os.ReadFile(f.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(fmt.Sprint("frame ", i+1), string(data)); diff != "" {
+			t.Fatalf("Incorrect data for frame %d:\n%s", i+1, diff)
+		}
+		f.filename = frames[i].filename
+	}
+	if diff := cmp.Diff(frames, loaded, cmp.AllowUnexported(image_frame{})); diff != "" {
+		t.Fatalf("Incorrect frames loaded from the cache:\n%s", diff)
+	}
+
+	// entries some of whose files were evicted are not used
+	if err = os.Remove(filepath.Join(magick_cache_dir(), key, "2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = load_from_magick_cache(key); err == nil {
+		t.Fatalf("Loading a partially evicted entry did not fail")
+	}
+}
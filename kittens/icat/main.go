@@ -4,6 +4,7 @@ package icat
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strconv"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"kitty/tools/cli"
+	"kitty/tools/termcaps"
 	"kitty/tools/tty"
 	"kitty/tools/tui"
 	"kitty/tools/tui/graphics"
@@ -48,8 +50,15 @@ var files_channel chan input_arg
 var output_channel chan *image_data
 var num_of_items int
 var keep_going *atomic.Bool
+var budget *memory_budget
 var screen_size *unix.Winsize
 
+// Where graphics commands are sent and where the placeholder grid is written
+// when not displaying placeholders in the terminal
+var terminal io.StringWriter = os.Stdout
+var grid_output io.StringWriter
+var num_of_grids_written int
+
 func send_output(imgd *image_data) {
 	output_channel <- imgd
 }
@@ -146,6 +155,7 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 	if err != nil {
 		return 1, err
 	}
+	utils.DefaultCacheManager().SweepInBackground()
 	if opts.UseWindowSize == "" {
 		if tty.IsTerminal(os.Stdout.Fd()) {
 			screen_size, err = tty.GetSize(int(os.Stdout.Fd()))
@@ -194,14 +204,34 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 		fmt.Printf("%dx%d", screen_size.Xpixel, screen_size.Ypixel)
 		return 0, nil
 	}
+	switch opts.UnicodePlaceholderGrid {
+	case "":
+	case "-":
+		t, err := tty.OpenControllingTerm()
+		if err != nil {
+			return 1, fmt.Errorf("Failed to open controlling terminal with error: %w", err)
+		}
+		defer t.Close()
+		terminal, grid_output = t, os.Stdout
+	default:
+		f, err := os.Create(opts.UnicodePlaceholderGrid)
+		if err != nil {
+			return 1, err
+		}
+		defer f.Close()
+		grid_output = f
+	}
 	if opts.Clear {
 		cc := &graphics.GraphicsCommand{}
 		cc.SetAction(graphics.GRT_action_delete).SetDelete(graphics.GRT_free_visible)
-		if err = cc.WriteWithPayloadTo(os.Stdout, nil); err != nil {
+		if err = cc.WriteWithPayloadTo(terminal, nil); err != nil {
 			return 1, err
 		}
 	}
-	if screen_size.Xpixel == 0 || screen_size.Ypixel == 0 {
+	// Terminals that do not report their size in pixels, such as the Linux
+	// console, cannot display graphics, so draw the images with text instead
+	use_text := screen_size.Xpixel == 0 || screen_size.Ypixel == 0
+	if use_text && (opts.DetectSupport || grid_output != nil) {
 		return 1, fmt.Errorf("Terminal does not support reporting screen sizes in pixels, use a terminal such as kitty, WezTerm, Konsole, etc. that does.")
 	}
 
@@ -209,23 +239,36 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 	if err != nil {
 		return 1, err
 	}
+	if opts.FromClipboard {
+		data, mime, err := read_image_from_clipboard()
+		if err != nil {
+			return 1, err
+		}
+		items = append(items, input_arg{arg: "<clipboard>", value: "<clipboard " + mime + ">", data: data})
+	}
+	if opts.ToClipboard {
+		if len(items) != 1 || items[0].value == "" || items[0].is_http_url || items[0].data != nil {
+			return 1, fmt.Errorf("The --to-clipboard option can only be used with a single image file")
+		}
+	}
+	if opts.Watch {
+		if len(items) != 1 || items[0].value == "" || items[0].is_http_url || items[0].data != nil {
+			return 1, fmt.Errorf("The --watch option can only be used with a single image file")
+		}
+	}
 	if opts.Place != "" && len(items) > 1 {
 		return 1, fmt.Errorf("The --place option can only be used with a single image, not %d", len(items))
 	}
 	files_channel = make(chan input_arg, len(items))
-	for _, ia := range items {
+	for i, ia := range items {
+		ia.index = i
 		files_channel <- ia
 	}
 	num_of_items = len(items)
 	output_channel = make(chan *image_data, 1)
 	keep_going = &atomic.Bool{}
 	keep_going.Store(true)
-	if !opts.DetectSupport && num_of_items > 0 {
-		num_workers := utils.Max(1, utils.Min(num_of_items, runtime.NumCPU()))
-		for i := 0; i < num_workers; i++ {
-			go run_worker()
-		}
-	}
+	budget = new_memory_budget(int64(utils.Max(0, opts.MemoryLimit)) * 1024 * 1024)
 
 	passthrough_mode := no_passthrough
 	switch opts.Passthrough {
@@ -234,6 +277,26 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 	case "detect":
 		if tui.TmuxSocketAddress() != "" {
 			passthrough_mode = tmux_passthrough
+		} else if caps := termcaps.FromEnvironment(); caps.InMultiplexer() && !caps.Graphics {
+			if opts.DetectSupport || grid_output != nil {
+				keep_going.Store(false)
+				return 1, caps.UnsupportedError("kitty graphics protocol")
+			}
+			use_text = true
+		}
+	}
+	if use_text {
+		keep_going.Store(false)
+		return render_as_text(items)
+	}
+	if !opts.DetectSupport && num_of_items > 0 {
+		num_workers := opts.Jobs
+		if num_workers < 1 {
+			num_workers = runtime.NumCPU()
+		}
+		num_workers = utils.Max(1, utils.Min(num_of_items, num_workers))
+		for i := 0; i < num_workers; i++ {
+			go run_worker()
 		}
 	}
 
@@ -244,6 +307,9 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 		}
 		if !direct {
 			keep_going.Store(false)
+			if !opts.DetectSupport && grid_output == nil {
+				return render_as_text(items)
+			}
 			return 1, fmt.Errorf("This terminal does not support the graphics protocol use a terminal such as kitty, WezTerm or Konsole that does. If you are running inside a terminal multiplexer such as tmux or screen that might be interfering as well.")
 		}
 		if memory {
@@ -273,12 +339,18 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 		return 0, nil
 	}
 	use_unicode_placeholder := opts.UnicodePlaceholder
-	if passthrough_mode != no_passthrough {
+	if passthrough_mode != no_passthrough || grid_output != nil || opts.Watch {
 		use_unicode_placeholder = true
 	}
 	base_id := uint32(opts.ImageId)
+	output := ordered_output{}
+	var displayed *image_data
 	for num_of_items > 0 {
-		imgd := <-output_channel
+		imgd := output.pop()
+		if imgd == nil {
+			output.add(<-output_channel)
+			continue
+		}
 		if base_id != 0 {
 			imgd.image_id = base_id
 			base_id++
@@ -295,10 +367,24 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 			transmit_image(imgd)
 			if imgd.err != nil {
 				print_error("Failed to transmit \x1b[31m%s\x1b[39m: %s\r\n", imgd.source_name, imgd.err)
+			} else {
+				displayed = imgd
 			}
 		}
+		budget.release(imgd.reserved_memory)
+		budget.set_next_index(output.next)
 	}
 	keep_going.Store(false)
+	if opts.Watch {
+		if err = watch_image(items[0], displayed, passthrough_mode); err != nil {
+			return 1, err
+		}
+	}
+	if opts.ToClipboard {
+		if err = write_image_to_clipboard(items[0].value); err != nil {
+			return 1, err
+		}
+	}
 	if opts.Hold {
 		fmt.Print("\r")
 		if opts.Place != "" {
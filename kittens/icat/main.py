@@ -92,6 +92,20 @@
 not a terminal, but you can turn it off or on explicitly, if needed.
 
 
+--from-clipboard
+type=bool-set
+Display the image in the clipboard, read using the kitty clipboard protocol. A PNG
+or JPEG image is used if available, otherwise any other image type in the clipboard.
+Useful with tools that copy screenshots to the clipboard. Note that reading the
+clipboard may need to be allowed, see :opt:`clipboard_control`.
+
+
+--to-clipboard
+type=bool-set
+Copy the data of the displayed image file to the clipboard, using the kitty clipboard
+protocol. Can only be used with a single image file.
+
+
 --silent
 type=bool-set
 Not used, present for legacy compatibility.
@@ -127,6 +141,16 @@
 Wait for a key press before exiting after displaying the images.
 
 
+--watch
+type=bool-set
+Display the image again, in the same place, whenever the image file changes, until
+interrupted with :kbd:`Ctrl+C`. Useful when iterating on a generated plot or diagram
+in another window. Can only be used with a single image file. Implies
+:option:`--unicode-placeholder`, so that updated images are shown in the same cells
+even if the screen scrolls, an updated image with a different aspect ratio is fitted
+into the cells of the first one.
+
+
 --unicode-placeholder
 type=bool-set
 Use the Unicode placeholder method to display the images. Useful to display
@@ -139,6 +163,17 @@
 with blank lines.
 
 
+--unicode-placeholder-grid
+Instead of displaying the images, write the Unicode placeholder characters
+for them to the specified file, for embedding into the text of other programs,
+such as editors or terminal multiplexers. Use :code:`-` to write to STDOUT, in
+which case the graphics commands are sent to the controlling terminal
+instead. Each row of an image is written as a line of text, with escape codes
+to set the foreground color that encodes the image id. Multiple images are
+separated by blank lines. Implies :option:`--unicode-placeholder`. Use
+:option:`--image-id` to control the ids of the images.
+
+
 --passthrough
 type=choices
 choices=detect,tmux,none
@@ -155,6 +190,22 @@
 The graphics protocol id to use for the created image. Normally, a random id is created if needed.
 This option allows control of the id. When multiple images are sent, sequential ids starting from the specified id
 are used. Valid ids are from 1 to 4294967295. Numbers outside this range are automatically wrapped.
+
+
+--jobs -j
+type=int
+default=0
+The number of images to decode and scale in parallel. Images are still displayed in the order
+they are specified. Defaults to the number of CPUs.
+
+
+--memory-limit
+type=int
+default=1024
+The maximum amount of memory, in MiB, to use for images that have been decoded but not yet sent
+to the terminal. When this limit is reached, decoding of further images waits till earlier images
+have been displayed. The limit is approximate, the next image to be displayed is always decoded, even
+if that means exceeding it. Zero means no limit.
 '''
 
 help_text = (
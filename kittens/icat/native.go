@@ -109,9 +109,27 @@ func scale_image(imgd *image_data) bool {
 	return false
 }
 
+type decoded_image_key struct {
+	path    string
+	version file_version
+}
+
+// Decoded images are re-used when the same file is displayed again, for
+// example because it was specified more than once. Only a few are kept as
+// decoded images can be large.
+var decoded_images = utils.NewLRU[decoded_image_key, image.Image](4)
+
 func load_one_frame_image(imgd *image_data, src *opened_input) (img image.Image, err error) {
-	img, _, err = exiffix.Decode(src.file)
-	src.Rewind()
+	decode := func(decoded_image_key) (image.Image, error) {
+		img, _, err := exiffix.Decode(src.file)
+		src.Rewind()
+		return img, err
+	}
+	if src.cache_key != nil {
+		img, err = decoded_images.GetOrCreate(*src.cache_key, decode)
+	} else {
+		img, err = decode(decoded_image_key{})
+	}
 	if err != nil {
 		return
 	}
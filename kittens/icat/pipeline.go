@@ -0,0 +1,89 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"fmt"
+	"sync"
+)
+
+var _ = fmt.Print
+
+// Limits the memory used by images that have been decoded but not yet
+// transmitted. Images are transmitted in order, so the image that is next in
+// line is never made to wait, otherwise decoding could deadlock with all
+// workers waiting on images that are queued behind it.
+type memory_budget struct {
+	lock       sync.Mutex
+	cond       *sync.Cond
+	limit      int64
+	used       int64
+	next_index int
+}
+
+func new_memory_budget(limit int64) *memory_budget {
+	ans := memory_budget{limit: limit}
+	ans.cond = sync.NewCond(&ans.lock)
+	return &ans
+}
+
+// Block until amt bytes can be used for the image at index
+func (self *memory_budget) acquire(index int, amt int64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for self.limit > 0 && self.used > 0 && self.used+amt > self.limit && index != self.next_index {
+		self.cond.Wait()
+	}
+	self.used += amt
+}
+
+// Change the amount of memory accounted for an image once its actual size is known, never blocks
+func (self *memory_budget) adjust(delta int64) {
+	self.lock.Lock()
+	self.used += delta
+	self.lock.Unlock()
+	if delta < 0 {
+		self.cond.Broadcast()
+	}
+}
+
+func (self *memory_budget) release(amt int64) { self.adjust(-amt) }
+
+// Indicate that the image at index is the next one to be transmitted
+func (self *memory_budget) set_next_index(index int) {
+	self.lock.Lock()
+	self.next_index = index
+	self.lock.Unlock()
+	self.cond.Broadcast()
+}
+
+func (self *image_data) memory_used() (ans int64) {
+	for _, f := range self.frames {
+		ans += int64(len(f.in_memory_bytes))
+	}
+	return
+}
+
+// Re-orders images that are processed in parallel so that they are output in
+// the order they were specified
+type ordered_output struct {
+	pending map[int]*image_data
+	next    int
+}
+
+func (self *ordered_output) add(imgd *image_data) {
+	if self.pending == nil {
+		self.pending = make(map[int]*image_data)
+	}
+	self.pending[imgd.index] = imgd
+}
+
+// Return the next image in order, if it is ready
+func (self *ordered_output) pop() *image_data {
+	if ans, found := self.pending[self.next]; found {
+		delete(self.pending, self.next)
+		self.next++
+		return ans
+	}
+	return nil
+}
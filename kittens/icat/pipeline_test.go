@@ -0,0 +1,79 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestDecodePipeline(t *testing.T) {
+	const num_images, num_workers, limit, max_size = 64, 8, 100, 170
+	b := new_memory_budget(limit)
+	inputs := make(chan int, num_images)
+	for i := 0; i < num_images; i++ {
+		inputs <- i
+	}
+	close(inputs)
+	outputs := make(chan *image_data)
+	var wg sync.WaitGroup
+	for i := 0; i < num_workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range inputs {
+				// some images are larger than the entire budget
+				sz := int64(10 + (idx%5)*(max_size-10)/4)
+				b.acquire(idx, sz)
+				b.lock.Lock()
+				// the limit can be exceeded by a single image larger than it
+				// and by the next image to be output
+				if b.used > 2*max_size {
+					t.Errorf("Memory limit exceeded: %d > %d while decoding image: %d", b.used, limit, idx)
+				}
+				b.lock.Unlock()
+				time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+				outputs <- &image_data{index: idx, reserved_memory: sz}
+			}
+		}()
+	}
+	o := ordered_output{}
+	actual := make([]int, 0, num_images)
+	done := make(chan bool)
+	go func() {
+		for len(actual) < num_images {
+			imgd := o.pop()
+			if imgd == nil {
+				o.add(<-outputs)
+				continue
+			}
+			actual = append(actual, imgd.index)
+			b.release(imgd.reserved_memory)
+			b.set_next_index(o.next)
+		}
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Decoding pipeline deadlocked after outputting %d images", len(actual))
+	}
+	wg.Wait()
+	expected := make([]int, num_images)
+	for i := range expected {
+		expected[i] = i
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Images not output in order:\n%s", diff)
+	}
+	if b.used != 0 {
+		t.Fatalf("Memory not released: %d", b.used)
+	}
+}
@@ -65,6 +65,9 @@ type input_arg struct {
 	arg         string
 	value       string
 	is_http_url bool
+	index       int
+	// image data already read, for example, from the clipboard
+	data []byte
 }
 
 func is_http_url(arg string) bool {
@@ -122,6 +125,8 @@ func process_dirs(args ...string) (results []input_arg, err error) {
 type opened_input struct {
 	file           io.ReadSeekCloser
 	name_to_unlink string
+	// set for files, whose decoded images can be re-used
+	cache_key *decoded_image_key
 }
 
 func (self *opened_input) Rewind() {
@@ -191,6 +196,11 @@ type image_data struct {
 	width_cells, height_cells         int
 	use_unicode_placeholder           bool
 	passthrough_mode                  passthrough_type
+	// a previously displayed image whose unicode placeholders this image re-uses
+	replaces *image_data
+	// position in the list of images to display and the amount of memory reserved for it
+	index           int
+	reserved_memory int64
 
 	// for error reporting
 	err         error
@@ -211,8 +221,8 @@ func set_basic_metadata(imgd *image_data) {
 	imgd.needs_conversion = imgd.needs_scaling || remove_alpha != nil || flip || flop || imgd.format_uppercase != "PNG"
 }
 
-func report_error(source_name, msg string, err error) {
-	imgd := image_data{source_name: source_name, err: fmt.Errorf("%s: %w", msg, err)}
+func report_error(arg input_arg, source_name, msg string, err error) {
+	imgd := image_data{source_name: source_name, index: arg.index, err: fmt.Errorf("%s: %w", msg, err)}
 	send_output(&imgd)
 }
 
@@ -239,46 +249,53 @@ func make_output_from_input(imgd *image_data, f *opened_input) {
 
 func process_arg(arg input_arg) {
 	var f opened_input
-	if arg.is_http_url {
-		resp, err := http.Get(arg.value)
+	if arg.data != nil {
+		f.file = &BytesBuf{data: arg.data}
+	} else if arg.is_http_url {
+		resp, err := utils.HTTPGet(arg.value, nil)
 		if err != nil {
-			report_error(arg.value, "Could not get", err)
+			report_error(arg, arg.value, "Could not get", err)
 			return
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode != http.StatusOK {
-			report_error(arg.value, "Could not get", fmt.Errorf("bad status: %v", resp.Status))
+			report_error(arg, arg.value, "Could not get", fmt.Errorf("bad status: %v", resp.Status))
 			return
 		}
 		dest := bytes.Buffer{}
 		dest.Grow(64 * 1024)
 		_, err = io.Copy(&dest, resp.Body)
 		if err != nil {
-			report_error(arg.value, "Could not download", err)
+			report_error(arg, arg.value, "Could not download", err)
 			return
 		}
 		f.file = &BytesBuf{data: dest.Bytes()}
 	} else if arg.value == "" {
 		stdin, err := io.ReadAll(os.Stdin)
 		if err != nil {
-			report_error("<stdin>", "Could not read from", err)
+			report_error(arg, "<stdin>", "Could not read from", err)
 			return
 		}
 		f.file = &BytesBuf{data: stdin}
 	} else {
 		q, err := os.Open(arg.value)
 		if err != nil {
-			report_error(arg.value, "Could not open", err)
+			report_error(arg, arg.value, "Could not open", err)
 			return
 		}
 		f.file = q
+		if s, err := q.Stat(); err == nil && s.Mode().IsRegular() {
+			if path, err := filepath.Abs(arg.value); err == nil {
+				f.cache_key = &decoded_image_key{path, file_version{s.ModTime(), s.Size()}}
+			}
+		}
 	}
 	defer f.Release()
 	can_use_go := false
 	var c image.Config
 	var format string
 	var err error
-	imgd := image_data{source_name: arg.value}
+	imgd := image_data{source_name: arg.value, index: arg.index}
 	if opts.Engine == "auto" || opts.Engine == "native" {
 		c, format, err = image.DecodeConfig(f.file)
 		f.Rewind()
@@ -294,24 +311,34 @@ func process_arg(arg input_arg) {
 		set_basic_metadata(&imgd)
 		if !imgd.needs_conversion {
 			make_output_from_input(&imgd, &f)
+			imgd.reserved_memory = imgd.memory_used()
+			budget.acquire(imgd.index, imgd.reserved_memory)
 			send_output(&imgd)
 			return
 		}
+		// an estimate, the actual size is known only after decoding
+		imgd.reserved_memory = 4 * int64(c.Width) * int64(c.Height)
+		budget.acquire(imgd.index, imgd.reserved_memory)
 		err = render_image_with_go(&imgd, &f)
 		if err != nil {
-			report_error(arg.value, "Could not render image to RGB", err)
+			budget.release(imgd.reserved_memory)
+			report_error(arg, arg.value, "Could not render image to RGB", err)
 			return
 		}
 	} else {
+		budget.acquire(imgd.index, 0)
 		err = render_image_with_magick(&imgd, &f)
 		if err != nil {
-			report_error(arg.value, "ImageMagick failed", err)
+			report_error(arg, arg.value, "ImageMagick failed", err)
 			return
 		}
 	}
 	if !keep_going.Load() {
 		return
 	}
+	actual := imgd.memory_used()
+	budget.adjust(actual - imgd.reserved_memory)
+	imgd.reserved_memory = actual
 	send_output(&imgd)
 
 }
@@ -0,0 +1,90 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+
+	"kitty/tools/tui/canvas"
+	"kitty/tools/utils"
+	"kitty/tools/utils/images"
+
+	"github.com/kovidgoyal/imaging"
+)
+
+var _ = fmt.Print
+
+func load_for_text(arg input_arg) (img image.Image, err error) {
+	var data []byte
+	switch {
+	case arg.data != nil:
+		data = arg.data
+	case arg.is_http_url:
+		resp, err := utils.HTTPGet(arg.value, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("bad status: %v", resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	case arg.value == "":
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return nil, err
+		}
+	default:
+		imgd, err := images.OpenImageFromPath(arg.value)
+		if err != nil {
+			return nil, err
+		}
+		return imgd.Frames[0].Img, nil
+	}
+	imgd, err := images.OpenNativeImageFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return imgd.Frames[0].Img, nil
+}
+
+// Draw the images using text characters, for terminals that do not support
+// the graphics protocol
+func render_as_text(items []input_arg) (rc int, err error) {
+	max_cols, max_rows := int(screen_size.Col), int(screen_size.Row)-1
+	if place != nil {
+		max_cols, max_rows = place.width, place.height
+	}
+	if max_cols < 1 || max_rows < 1 {
+		max_cols, max_rows = 80, 24
+	}
+	color_mode := canvas.ColorModeFromEnvironment()
+	for _, arg := range items {
+		name := utils.IfElse(arg.value == "", "<stdin>", arg.value)
+		img, err := load_for_text(arg)
+		if err != nil {
+			print_error("Failed to process \x1b[31m%s\x1b[39m: %s\r\n", name, err)
+			rc = 1
+			continue
+		}
+		if flip {
+			img = imaging.FlipV(img)
+		}
+		if flop {
+			img = imaging.FlipH(img)
+		}
+		for i, line := range canvas.RenderImage(img, canvas.HalfBlocks, color_mode, max_cols, max_rows) {
+			if place != nil {
+				fmt.Printf("\x1b[%d;%dH", place.top+i+1, place.left+1)
+			}
+			fmt.Println(line)
+		}
+	}
+	return
+}
@@ -9,7 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"kitty"
 	"math"
 	not_rand "math/rand/v2"
 	"os"
@@ -128,7 +127,7 @@ func transmit_shm(imgd *image_data, frame_num int, frame *image_frame) (err erro
 	gc := gc_for_image(imgd, frame_num, frame)
 	gc.SetTransmission(graphics.GRT_transmission_sharedmem)
 	gc.SetDataSize(uint64(data_size))
-	err = gc.WriteWithPayloadTo(os.Stdout, utils.UnsafeStringToBytes(mmap.Name()))
+	err = gc.WriteWithPayloadTo(terminal, utils.UnsafeStringToBytes(mmap.Name()))
 	mmap.Close()
 
 	return
@@ -174,7 +173,7 @@ func transmit_file(imgd *image_data, frame_num int, frame *image_frame) (err err
 	if data_size > 0 {
 		gc.SetDataSize(uint64(data_size))
 	}
-	return gc.WriteWithPayloadTo(os.Stdout, utils.UnsafeStringToBytes(fname))
+	return gc.WriteWithPayloadTo(terminal, utils.UnsafeStringToBytes(fname))
 }
 
 func transmit_stream(imgd *image_data, frame_num int, frame *image_frame) (err error) {
@@ -191,7 +190,7 @@ func transmit_stream(imgd *image_data, frame_num int, frame *image_frame) (err e
 		}
 	}
 	gc := gc_for_image(imgd, frame_num, frame)
-	return gc.WriteWithPayloadTo(os.Stdout, data)
+	return gc.WriteWithPayloadTo(terminal, data)
 }
 
 func calculate_in_cell_x_offset(width, cell_width int) int {
@@ -247,32 +246,43 @@ func next_random() (ans uint32) {
 	return ans
 }
 
+func placeholder_for(imgd *image_data) graphics.Placeholder {
+	return graphics.Placeholder{ImageId: imgd.image_id, Columns: imgd.width_cells, Rows: imgd.height_cells}
+}
+
 func write_unicode_placeholder(imgd *image_data) {
+	p := placeholder_for(imgd)
+	if grid_output != nil {
+		if num_of_grids_written > 0 {
+			_, imgd.err = grid_output.WriteString("\n")
+		}
+		if imgd.err == nil {
+			_, imgd.err = grid_output.WriteString(p.Grid())
+		}
+		num_of_grids_written++
+		return
+	}
 	prefix := ""
-	foreground := fmt.Sprintf("\033[38:2:%d:%d:%dm", (imgd.image_id>>16)&255, (imgd.image_id>>8)&255, imgd.image_id&255)
-	os.Stdout.WriteString(foreground)
-	restore := "\033[39m"
+	terminal.WriteString(p.SGR())
+	restore := p.ResetSGR()
 	if imgd.move_to.y > 0 {
-		os.Stdout.WriteString(loop.SAVE_CURSOR)
+		terminal.WriteString(loop.SAVE_CURSOR)
 		restore += loop.RESTORE_CURSOR
 	} else if imgd.move_x_by > 0 {
 		prefix = strings.Repeat(" ", imgd.move_x_by)
 	}
-	defer func() { os.Stdout.WriteString(restore) }()
+	defer func() { terminal.WriteString(restore) }()
 	if imgd.move_to.y > 0 {
-		fmt.Printf(loop.MoveCursorToTemplate, imgd.move_to.y, 0)
+		terminal.WriteString(fmt.Sprintf(loop.MoveCursorToTemplate, imgd.move_to.y, 0))
 	}
-	id_char := string(images.NumberToDiacritic[(imgd.image_id>>24)&255])
 	for r := 0; r < imgd.height_cells; r++ {
 		if imgd.move_to.x > 0 {
-			fmt.Printf("\x1b[%dC", imgd.move_to.x-1)
+			terminal.WriteString(fmt.Sprintf("\x1b[%dC", imgd.move_to.x-1))
 		} else {
-			os.Stdout.WriteString(prefix)
+			terminal.WriteString(prefix)
 		}
-		for c := 0; c < imgd.width_cells; c++ {
-			os.Stdout.WriteString(string(kitty.ImagePlaceholderChar) + string(images.NumberToDiacritic[r]) + string(images.NumberToDiacritic[c]) + id_char)
-		}
-		os.Stdout.WriteString("\n\r")
+		terminal.WriteString(p.Row(r))
+		terminal.WriteString("\n\r")
 	}
 }
 
@@ -316,9 +326,12 @@ func transmit_image(imgd *image_data) {
 	if f == nil {
 		f = transmit_stream
 	}
+	if imgd.replaces != nil {
+		imgd.image_id = imgd.replaces.image_id
+	}
 	if imgd.image_id == 0 {
 		if imgd.use_unicode_placeholder {
-			for imgd.image_id&0xFF000000 == 0 || imgd.image_id&0x00FFFF00 == 0 || seen_image_ids.Has(imgd.image_id) {
+			for !graphics.IsRobustPlaceholderImageId(imgd.image_id) || seen_image_ids.Has(imgd.image_id) {
 				// Generate a 32-bit image id using rejection sampling such that the most
 				// significant byte and the two bytes in the middle are non-zero to avoid
 				// collisions with applications that cannot represent non-zero most
@@ -336,24 +349,31 @@ func transmit_image(imgd *image_data) {
 		}
 	}
 	place_cursor(imgd)
-	if imgd.use_unicode_placeholder && utils.Max(imgd.width_cells, imgd.height_cells) >= len(images.NumberToDiacritic) {
-		imgd.err = fmt.Errorf("Image too large to be displayed using Unicode placeholders. Maximum size is %dx%d cells", len(images.NumberToDiacritic), len(images.NumberToDiacritic))
-		return
+	if imgd.replaces != nil {
+		// the image is fitted into the existing placeholder cells
+		imgd.width_cells, imgd.height_cells = imgd.replaces.width_cells, imgd.replaces.height_cells
+	}
+	if imgd.use_unicode_placeholder {
+		if imgd.err = placeholder_for(imgd).Validate(); imgd.err != nil {
+			return
+		}
 	}
 	switch imgd.passthrough_mode {
 	case tmux_passthrough:
-		imgd.err = tui.TmuxAllowPassthrough()
+		imgd.err = tui.EnsurePassthroughAllowed(tui.TmuxMultiplexer)
 		if imgd.err != nil {
 			return
 		}
 	}
-	fmt.Print("\r")
+	if grid_output == nil && imgd.replaces == nil {
+		terminal.WriteString("\r")
+	}
 	if !imgd.use_unicode_placeholder {
 		if imgd.move_x_by > 0 {
-			fmt.Printf("\x1b[%dC", imgd.move_x_by)
+			terminal.WriteString(fmt.Sprintf("\x1b[%dC", imgd.move_x_by))
 		}
 		if imgd.move_to.x > 0 {
-			fmt.Printf(loop.MoveCursorToTemplate, imgd.move_to.y, imgd.move_to.x)
+			terminal.WriteString(fmt.Sprintf(loop.MoveCursorToTemplate, imgd.move_to.y, imgd.move_to.x))
 		}
 	}
 	frame_control_cmd := new_graphics_command(imgd)
@@ -384,29 +404,29 @@ func transmit_image(imgd *image_data) {
 				case opts.Loop > 0:
 					c.SetNumberOfLoops(uint64(opts.Loop) + 1)
 				}
-				if imgd.err = c.WriteWithPayloadTo(os.Stdout, nil); imgd.err != nil {
+				if imgd.err = c.WriteWithPayloadTo(terminal, nil); imgd.err != nil {
 					return
 				}
 			case 1:
 				c := frame_control_cmd
 				c.SetAnimationControl(2) // set animation to loading mode
-				if imgd.err = c.WriteWithPayloadTo(os.Stdout, nil); imgd.err != nil {
+				if imgd.err = c.WriteWithPayloadTo(terminal, nil); imgd.err != nil {
 					return
 				}
 			}
 		}
 	}
-	if imgd.use_unicode_placeholder {
+	if imgd.use_unicode_placeholder && imgd.replaces == nil {
 		write_unicode_placeholder(imgd)
 	}
 	if is_animated {
 		c := frame_control_cmd
 		c.SetAnimationControl(3) // set animation to normal mode
-		if imgd.err = c.WriteWithPayloadTo(os.Stdout, nil); imgd.err != nil {
+		if imgd.err = c.WriteWithPayloadTo(terminal, nil); imgd.err != nil {
 			return
 		}
 	}
-	if imgd.move_to.x == 0 {
-		fmt.Println() // ensure cursor is on new line
+	if imgd.move_to.x == 0 && grid_output == nil && imgd.replaces == nil {
+		terminal.WriteString("\n") // ensure cursor is on new line
 	}
 }
@@ -0,0 +1,100 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"kitty/tools/utils"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+type file_version struct {
+	mtime time.Time
+	size  int64
+}
+
+func version_of(path string) (ans file_version, err error) {
+	s, err := os.Stat(path)
+	if err == nil {
+		ans = file_version{s.ModTime(), s.Size()}
+	}
+	return
+}
+
+// Display the image in ia again whenever its file changes, re-using the
+// unicode placeholders of displayed, the first successfully displayed image,
+// if any. Returns when interrupted by a signal.
+func watch_image(ia input_arg, displayed *image_data, passthrough_mode passthrough_type) (err error) {
+	path, err := filepath.Abs(ia.value)
+	if err != nil {
+		return err
+	}
+	last_version, _ := version_of(path)
+	w, err := utils.NewWatcher(100 * time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	// editors often save files by replacing them, so watch the directory
+	if err = w.Add(filepath.Dir(path), false); err != nil {
+		return err
+	}
+	sigs := make(chan os.Signal, 1)
+	defer utils.SuspendExitSignalHandling()()
+	signal.Notify(sigs, unix.SIGINT, unix.SIGTERM, unix.SIGHUP)
+	defer signal.Stop(sigs)
+	keep_going.Store(true)
+	defer keep_going.Store(false)
+
+	redisplay := func() {
+		v, err := version_of(path)
+		if err != nil || v == last_version {
+			// a deleted file is displayed again when it is re-created
+			return
+		}
+		last_version = v
+		process_arg(ia)
+		imgd := <-output_channel
+		imgd.use_unicode_placeholder = true
+		imgd.passthrough_mode = passthrough_mode
+		imgd.replaces = displayed
+		if imgd.err != nil {
+			print_error("Failed to process \x1b[31m%s\x1b[39m: %s\r\n", imgd.source_name, imgd.err)
+		} else {
+			transmit_image(imgd)
+			if imgd.err != nil {
+				print_error("Failed to transmit \x1b[31m%s\x1b[39m: %s\r\n", imgd.source_name, imgd.err)
+			} else if displayed == nil {
+				displayed = imgd
+			}
+		}
+		budget.release(imgd.reserved_memory)
+	}
+
+	for {
+		select {
+		case <-sigs:
+			return nil
+		case <-w.Errors:
+			// changes may have been missed
+			redisplay()
+		case changed, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			// kqueue only reports that the directory changed
+			if slices.Contains(changed, path) || slices.Contains(changed, filepath.Dir(path)) {
+				redisplay()
+			}
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package md
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var _ = fmt.Print
+
+const ascii_punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+type Image struct {
+	Alt, Source, Title string
+}
+
+// Find the end of a bracketed span starting at text[start] == open, taking
+// nesting and backslash escapes into account. Returns -1 if not found.
+func find_matching(text string, start int, open, close byte) int {
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Parse the destination and optional title of a link from the text inside
+// the parentheses following the link text.
+func parse_link_destination(raw string) (dest, title string) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "<") {
+		if idx := strings.IndexByte(raw, '>'); idx > 0 {
+			dest, raw = raw[1:idx], raw[idx+1:]
+		}
+	} else {
+		idx := strings.IndexFunc(raw, unicode.IsSpace)
+		if idx < 0 {
+			return raw, ""
+		}
+		dest, raw = raw[:idx], raw[idx:]
+	}
+	raw = strings.TrimSpace(raw)
+	if len(raw) > 1 {
+		title = strings.Trim(raw, `"'()`)
+	}
+	return
+}
+
+// Parse an inline link or image of the form [text](dest "title") starting at
+// text[pos] == '['. Returns the position after the link or -1.
+func parse_link(text string, pos int) (label, dest, title string, end int) {
+	close_bracket := find_matching(text, pos, '[', ']')
+	if close_bracket < 0 || close_bracket+1 >= len(text) || text[close_bracket+1] != '(' {
+		return "", "", "", -1
+	}
+	close_paren := find_matching(text, close_bracket+1, '(', ')')
+	if close_paren < 0 {
+		return "", "", "", -1
+	}
+	dest, title = parse_link_destination(text[close_bracket+2 : close_paren])
+	return text[pos+1 : close_bracket], dest, title, close_paren + 1
+}
+
+func is_word_char(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func rune_before(text string, pos int) rune {
+	if pos == 0 {
+		return ' '
+	}
+	r, _ := utf8.DecodeLastRuneInString(text[:pos])
+	return r
+}
+
+func rune_after(text string, pos int) rune {
+	if pos >= len(text) {
+		return ' '
+	}
+	r, _ := utf8.DecodeRuneInString(text[pos:])
+	return r
+}
+
+// Find the closing emphasis delimiter for an opening delimiter that ends at pos
+func find_closing_delimiter(text string, pos int, delim string) int {
+	for i := pos; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+			continue
+		case '`':
+			// skip code spans
+			n := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			if idx := strings.Index(text[i+n:], text[i:i+n]); idx > -1 {
+				i += n + idx + n - 1
+			}
+			continue
+		}
+		if strings.HasPrefix(text[i:], delim) && i > pos && !unicode.IsSpace(rune_before(text, i)) {
+			after := rune_after(text, i+len(delim))
+			if delim[0] == '_' && is_word_char(after) {
+				continue
+			}
+			if len(delim) == 1 && i+1 < len(text) && text[i+1] == delim[0] {
+				// part of a stronger delimiter, skip over it
+				if end := find_closing_delimiter(text, i+2, delim+delim); end > -1 {
+					i = end + 1
+					continue
+				}
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func (self *renderer) render_inline(text string) string {
+	out := strings.Builder{}
+	out.Grow(len(text) + 64)
+	for i := 0; i < len(text); {
+		ch := text[i]
+		switch ch {
+		case '\\':
+			if i+1 < len(text) && strings.IndexByte(ascii_punctuation, text[i+1]) > -1 {
+				out.WriteByte(text[i+1])
+				i += 2
+				continue
+			}
+			if i+1 < len(text) && text[i+1] == '\n' {
+				out.WriteByte('\n')
+				i += 2
+				continue
+			}
+		case '\n':
+			s := out.String()
+			if strings.HasSuffix(s, "  ") {
+				t := strings.TrimRight(s, " ")
+				out.Reset()
+				out.WriteString(t)
+				out.WriteByte('\n')
+			} else {
+				out.WriteByte(' ')
+			}
+			i++
+			continue
+		case '`':
+			n := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			fence := text[i : i+n]
+			if idx := strings.Index(text[i+n:], fence); idx > -1 {
+				code := strings.ReplaceAll(text[i+n:i+n+idx], "\n", " ")
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				out.WriteString(self.styles.code(code))
+				i += n + idx + n
+				continue
+			}
+			out.WriteString(fence)
+			i += n
+			continue
+		case '!':
+			if i+1 < len(text) && text[i+1] == '[' {
+				if alt, src, title, end := parse_link(text, i+1); end > -1 {
+					out.WriteString(self.render_inline_image(Image{Alt: alt, Source: src, Title: title}))
+					i = end
+					continue
+				}
+			}
+		case '[':
+			if label, dest, _, end := parse_link(text, i); end > -1 {
+				out.WriteString(self.link(dest, self.render_inline(label)))
+				i = end
+				continue
+			}
+		case '<':
+			if end := strings.IndexByte(text[i:], '>'); end > 1 {
+				target := text[i+1 : i+end]
+				if !strings.ContainsAny(target, " \t\n<") {
+					if strings.Contains(target, "://") {
+						out.WriteString(self.link(target, target))
+						i += end + 1
+						continue
+					}
+					if strings.Contains(target, "@") && !strings.Contains(target, ":") {
+						out.WriteString(self.link("mailto:"+target, target))
+						i += end + 1
+						continue
+					}
+				}
+			}
+		case '*', '_', '~':
+			n := len(text[i:]) - len(strings.TrimLeft(text[i:], string(ch)))
+			if ch == '~' && n != 2 {
+				break
+			}
+			if ch == '_' && is_word_char(rune_before(text, i)) {
+				break
+			}
+			n = min(n, 3)
+			if unicode.IsSpace(rune_after(text, i+n)) {
+				break
+			}
+			delim := text[i : i+n]
+			end := find_closing_delimiter(text, i+n, delim)
+			if end < 0 && n == 3 {
+				// try treating *** as ** followed by *
+				n, delim = 2, text[i:i+2]
+				end = find_closing_delimiter(text, i+n, delim)
+			}
+			if end > -1 {
+				inner := self.render_inline(text[i+n : end])
+				switch {
+				case ch == '~':
+					inner = self.styles.strikethrough(inner)
+				case n == 1:
+					inner = self.styles.italic(inner)
+				case n == 2:
+					inner = self.styles.bold(inner)
+				default:
+					inner = self.styles.bold(self.styles.italic(inner))
+				}
+				out.WriteString(inner)
+				i = end + n
+				continue
+			}
+			out.WriteString(delim)
+			i += n
+			continue
+		}
+		out.WriteByte(ch)
+		i++
+	}
+	return out.String()
+}
+
+func (self *renderer) link(url, text string) string {
+	if text == "" {
+		text = url
+	}
+	if url == "" || !self.opts.Hyperlinks {
+		return self.styles.link_text(text)
+	}
+	return self.styles.url(url, text)
+}
+
+func (self *renderer) render_inline_image(img Image) string {
+	alt := img.Alt
+	if alt == "" {
+		alt = "image"
+	}
+	return self.link(self.resolve_url(img.Source), self.styles.dim("["+alt+"]"))
+}
+
+// If the paragraph consists only of a single image, return it
+func paragraph_as_image(text string) *Image {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "![") {
+		return nil
+	}
+	alt, src, title, end := parse_link(text, 1)
+	if end != len(text) {
+		return nil
+	}
+	return &Image{Alt: alt, Source: src, Title: title}
+}
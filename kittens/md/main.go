@@ -0,0 +1,77 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package md
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+)
+
+var _ = fmt.Print
+
+func tristate(val string, auto bool) bool {
+	switch val {
+	case "yes":
+		return true
+	case "no":
+		return false
+	}
+	return auto
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if len(args) > 1 {
+		return 1, fmt.Errorf("Only a single file can be rendered at a time")
+	}
+	var data []byte
+	base_dir := opts.BaseDir
+	if len(args) == 0 || args[0] == "-" {
+		if tty.IsTerminal(os.Stdin.Fd()) {
+			return 1, fmt.Errorf("STDIN is a terminal and no filename specified. See --help")
+		}
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return 1, err
+		}
+		if base_dir == "" {
+			base_dir, _ = os.Getwd()
+		}
+	} else {
+		if data, err = os.ReadFile(args[0]); err != nil {
+			return 1, err
+		}
+		if base_dir == "" {
+			base_dir = filepath.Dir(args[0])
+		}
+	}
+	is_tty := tty.IsTerminal(os.Stdout.Fd())
+	ro := RenderOptions{
+		Width: opts.Width, Formatting: is_tty || tristate(opts.Hyperlinks, false) || tristate(opts.Images, false),
+		Images: tristate(opts.Images, is_tty), Hyperlinks: tristate(opts.Hyperlinks, is_tty),
+		CodeTheme: opts.CodeTheme, BaseDir: base_dir,
+	}
+	if is_tty {
+		if sz, err := tty.GetSize(int(os.Stdout.Fd())); err == nil {
+			if ro.Width < 1 {
+				ro.Width = int(sz.Col)
+			}
+			if sz.Col > 0 && sz.Row > 0 {
+				ro.CellWidth, ro.CellHeight = int(sz.Xpixel/sz.Col), int(sz.Ypixel/sz.Row)
+			}
+		}
+	}
+	lines := Render(string(data), ro)
+	if _, err = os.Stdout.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return 1, err
+	}
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
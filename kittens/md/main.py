@@ -0,0 +1,64 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+from kitty.cli import CompletionSpec
+
+OPTIONS = r'''
+--width -w
+type=int
+default=0
+The width, in cells, to wrap the rendered text at. The default is to use the
+width of the terminal, or 80 if the output is not a terminal.
+
+
+--images
+default=auto
+choices=auto,yes,no
+Whether to display images using the kitty graphics protocol. With :code:`auto`,
+images are displayed only when STDOUT is a terminal. Only images that are
+local files are displayed, remote images are shown as hyperlinks.
+
+
+--hyperlinks
+default=auto
+choices=auto,yes,no
+Whether to render links as clickable OSC 8 hyperlinks. With :code:`auto`,
+hyperlinks are used only when STDOUT is a terminal.
+
+
+--code-theme
+default=monokai
+The syntax highlighting theme to use for fenced code blocks. Any theme name
+supported by the Chroma library can be used, for example: :code:`monokai`, :code:`github`,
+:code:`dracula`, :code:`solarized-dark`. Use :code:`none` to disable highlighting.
+
+
+--base-dir
+Directory relative to which image paths in the document are resolved. Defaults
+to the directory containing the Markdown file, or the current directory when
+reading from STDIN.
+'''.format
+help_text = '''\
+Render Markdown documents in the terminal, with styled headings, tables, syntax
+highlighted code blocks, clickable hyperlinks and inline images. If no file is
+specified, the document is read from STDIN.
+'''
+usage = '[path to markdown file]'
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten md')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Render Markdown documents in the terminal'
+    cd['args_completion'] = CompletionSpec.from_string('type:file ext:md,markdown group:"Markdown files"')
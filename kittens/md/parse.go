@@ -0,0 +1,371 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package md
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var _ = fmt.Print
+
+type BlockType int
+
+const (
+	Paragraph BlockType = iota
+	Heading
+	CodeBlock
+	BlockQuote
+	List
+	ListItem
+	Table
+	ThematicBreak
+)
+
+type Alignment int
+
+const (
+	AlignLeft Alignment = iota
+	AlignCenter
+	AlignRight
+)
+
+type Block struct {
+	Type BlockType
+	// The heading level for headings
+	Level int
+	// The raw inline text for paragraphs and headings and the contents of code blocks
+	Text string
+	// The language of fenced code blocks
+	Language string
+	// Child blocks for block quotes, lists and list items
+	Children []*Block
+	// For lists, whether the list is ordered and the number of the first item
+	Ordered bool
+	Start   int
+	// For tables, the header row, the body rows and the column alignments
+	Header     []string
+	Rows       [][]string
+	Alignments []Alignment
+}
+
+var patterns = sync.OnceValue(func() (ans struct {
+	atx_heading, setext, fence, hr, bullet, ordered, table_sep *regexp.Regexp
+}) {
+	ans.atx_heading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ans.setext = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	ans.fence = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	ans.hr = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	ans.bullet = regexp.MustCompile(`^( {0,3})([-*+])([ \t]+|$)`)
+	ans.ordered = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])([ \t]+|$)`)
+	ans.table_sep = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	return
+})
+
+func is_blank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func expand_tabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	b := strings.Builder{}
+	col := 0
+	for _, ch := range line {
+		if ch == '\t' {
+			n := 4 - (col % 4)
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		} else {
+			b.WriteRune(ch)
+			col++
+		}
+	}
+	return b.String()
+}
+
+func leading_spaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func split_table_row(line string) (ans []string) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	cell := strings.Builder{}
+	in_code := false
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case ch == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case ch == '`':
+			in_code = !in_code
+			cell.WriteByte(ch)
+		case ch == '|' && !in_code:
+			ans = append(ans, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(ch)
+		}
+	}
+	return append(ans, strings.TrimSpace(cell.String()))
+}
+
+func parse_alignments(sep string) (ans []Alignment) {
+	for _, cell := range split_table_row(sep) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			ans = append(ans, AlignCenter)
+		case right:
+			ans = append(ans, AlignRight)
+		default:
+			ans = append(ans, AlignLeft)
+		}
+	}
+	return
+}
+
+type parser struct {
+	lines []string
+	pos   int
+}
+
+func (self *parser) at_end() bool { return self.pos >= len(self.lines) }
+
+func (self *parser) starts_new_block(line string) bool {
+	p := patterns()
+	return is_blank(line) || p.atx_heading.MatchString(line) || p.fence.MatchString(line) || p.hr.MatchString(line) ||
+		strings.HasPrefix(strings.TrimLeft(line, " "), ">") || p.bullet.MatchString(line) || p.ordered.MatchString(line)
+}
+
+func (self *parser) parse_fenced_code(m []string) *Block {
+	indent, fence, lang := len(m[1]), m[2], m[3]
+	self.pos++
+	lines := []string{}
+	for !self.at_end() {
+		line := self.lines[self.pos]
+		self.pos++
+		t := strings.TrimSpace(line)
+		if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" && leading_spaces(line) < 4 {
+			break
+		}
+		if leading_spaces(line) >= indent {
+			line = line[indent:]
+		} else {
+			line = strings.TrimLeft(line, " ")
+		}
+		lines = append(lines, line)
+	}
+	return &Block{Type: CodeBlock, Text: strings.Join(lines, "\n"), Language: lang}
+}
+
+func (self *parser) parse_indented_code() *Block {
+	lines := []string{}
+	for !self.at_end() {
+		line := self.lines[self.pos]
+		if !is_blank(line) && leading_spaces(line) < 4 {
+			break
+		}
+		if len(line) >= 4 {
+			line = line[4:]
+		} else {
+			line = ""
+		}
+		lines = append(lines, line)
+		self.pos++
+	}
+	for len(lines) > 0 && is_blank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return &Block{Type: CodeBlock, Text: strings.Join(lines, "\n")}
+}
+
+func (self *parser) parse_blockquote() *Block {
+	lines := []string{}
+	for !self.at_end() {
+		line := self.lines[self.pos]
+		t := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(t, ">") {
+			t = t[1:]
+			t = strings.TrimPrefix(t, " ")
+			lines = append(lines, t)
+		} else if !is_blank(line) && len(lines) > 0 && !is_blank(lines[len(lines)-1]) && !self.starts_new_block(line) {
+			// lazy continuation line
+			lines = append(lines, line)
+		} else {
+			break
+		}
+		self.pos++
+	}
+	return &Block{Type: BlockQuote, Children: parse_lines(lines)}
+}
+
+func (self *parser) list_marker(line string) (ordered bool, num int, content_offset int, ok bool) {
+	p := patterns()
+	if m := p.bullet.FindStringSubmatch(line); m != nil && !p.hr.MatchString(line) {
+		return false, 0, len(m[0]), true
+	}
+	if m := p.ordered.FindStringSubmatch(line); m != nil {
+		fmt.Sscanf(m[2], "%d", &num)
+		return true, num, len(m[0]), true
+	}
+	return
+}
+
+func (self *parser) parse_list(ordered bool, start int) *Block {
+	ans := &Block{Type: List, Ordered: ordered, Start: start}
+	for !self.at_end() {
+		line := self.lines[self.pos]
+		o, _, offset, ok := self.list_marker(line)
+		if !ok || o != ordered {
+			break
+		}
+		if offset == len(line) {
+			// empty list item marker
+			offset = len(line) + 1
+		}
+		item_lines := []string{strings.TrimLeft(line[min(offset, len(line)):], " ")}
+		self.pos++
+		for !self.at_end() {
+			line = self.lines[self.pos]
+			if is_blank(line) {
+				// blank lines are part of the item only if followed by indented content
+				j := self.pos + 1
+				for j < len(self.lines) && is_blank(self.lines[j]) {
+					j++
+				}
+				if j < len(self.lines) && leading_spaces(self.lines[j]) >= offset {
+					for ; self.pos < j; self.pos++ {
+						item_lines = append(item_lines, "")
+					}
+					continue
+				}
+				break
+			}
+			if leading_spaces(line) >= offset {
+				item_lines = append(item_lines, line[offset:])
+			} else if _, _, _, is_marker := self.list_marker(line); !is_marker && !self.starts_new_block(line) && !is_blank(item_lines[len(item_lines)-1]) {
+				// lazy continuation line
+				item_lines = append(item_lines, strings.TrimLeft(line, " "))
+			} else {
+				break
+			}
+			self.pos++
+		}
+		ans.Children = append(ans.Children, &Block{Type: ListItem, Children: parse_lines(item_lines)})
+		// Allow blank lines between items
+		j := self.pos
+		for j < len(self.lines) && is_blank(self.lines[j]) {
+			j++
+		}
+		if j < len(self.lines) {
+			if o, _, _, ok := self.list_marker(self.lines[j]); ok && o == ordered {
+				self.pos = j
+			}
+		}
+	}
+	return ans
+}
+
+func (self *parser) parse_table() *Block {
+	ans := &Block{Type: Table, Header: split_table_row(self.lines[self.pos]), Alignments: parse_alignments(self.lines[self.pos+1])}
+	self.pos += 2
+	for !self.at_end() {
+		line := self.lines[self.pos]
+		if is_blank(line) || !strings.Contains(line, "|") {
+			break
+		}
+		ans.Rows = append(ans.Rows, split_table_row(line))
+		self.pos++
+	}
+	for len(ans.Alignments) < len(ans.Header) {
+		ans.Alignments = append(ans.Alignments, AlignLeft)
+	}
+	return ans
+}
+
+func (self *parser) parse_paragraph() *Block {
+	p := patterns()
+	lines := []string{}
+	for !self.at_end() {
+		line := self.lines[self.pos]
+		if len(lines) > 0 {
+			if m := p.setext.FindStringSubmatch(line); m != nil {
+				self.pos++
+				level := 2
+				if m[1][0] == '=' {
+					level = 1
+				}
+				return &Block{Type: Heading, Level: level, Text: strings.Join(lines, " ")}
+			}
+			if self.starts_new_block(line) {
+				break
+			}
+		}
+		lines = append(lines, strings.TrimSpace(line))
+		self.pos++
+	}
+	return &Block{Type: Paragraph, Text: strings.Join(lines, "\n")}
+}
+
+func (self *parser) next_block() *Block {
+	p := patterns()
+	for !self.at_end() && is_blank(self.lines[self.pos]) {
+		self.pos++
+	}
+	if self.at_end() {
+		return nil
+	}
+	line := self.lines[self.pos]
+	if leading_spaces(line) >= 4 {
+		return self.parse_indented_code()
+	}
+	if m := p.fence.FindStringSubmatch(line); m != nil {
+		return self.parse_fenced_code(m)
+	}
+	if m := p.atx_heading.FindStringSubmatch(line); m != nil {
+		self.pos++
+		return &Block{Type: Heading, Level: len(m[1]), Text: m[2]}
+	}
+	if p.hr.MatchString(line) {
+		self.pos++
+		return &Block{Type: ThematicBreak}
+	}
+	if strings.HasPrefix(strings.TrimLeft(line, " "), ">") {
+		return self.parse_blockquote()
+	}
+	if ordered, num, _, ok := self.list_marker(line); ok {
+		return self.parse_list(ordered, num)
+	}
+	if strings.Contains(line, "|") && self.pos+1 < len(self.lines) && p.table_sep.MatchString(self.lines[self.pos+1]) && strings.Contains(self.lines[self.pos+1], "-") {
+		return self.parse_table()
+	}
+	return self.parse_paragraph()
+}
+
+func parse_lines(lines []string) (ans []*Block) {
+	p := parser{lines: lines}
+	for b := p.next_block(); b != nil; b = p.next_block() {
+		ans = append(ans, b)
+	}
+	return
+}
+
+// Parse the specified Markdown text into a tree of blocks. Inline markup is
+// left as is in the Text fields of the blocks.
+func Parse(text string) []*Block {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = expand_tabs(line)
+	}
+	return parse_lines(lines)
+}
@@ -0,0 +1,471 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package md
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"kitty/tools/tui/graphics"
+	"kitty/tools/utils"
+	"kitty/tools/utils/images"
+	"kitty/tools/utils/style"
+	"kitty/tools/wcswidth"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+var _ = fmt.Print
+
+type RenderOptions struct {
+	// The width in cells to wrap text at
+	Width int
+	// Display local images using the graphics protocol
+	Images bool
+	// Render links as OSC 8 hyperlinks
+	Hyperlinks bool
+	// Use SGR formatting, when false plain text is output
+	Formatting bool
+	// The chroma theme for highlighting code blocks, empty or "none" disables highlighting
+	CodeTheme string
+	// The directory relative to which image paths are resolved
+	BaseDir string
+	// The size of a cell in pixels, used for sizing images, zero if unknown
+	CellWidth, CellHeight int
+}
+
+type styles_struct struct {
+	bold, italic, strikethrough, code, dim, link_text, quote_bar, bullet, rule, table_border, table_header, code_label func(...any) string
+	headings                                                                                                           [6]func(...any) string
+	url                                                                                                                func(string, string) string
+}
+
+type renderer struct {
+	opts   RenderOptions
+	styles styles_struct
+	ctx    style.Context
+}
+
+func new_renderer(opts RenderOptions) *renderer {
+	ans := renderer{opts: opts}
+	ans.ctx.AllowEscapeCodes = opts.Formatting
+	s, c := &ans.styles, &ans.ctx
+	s.bold = c.SprintFunc("bold")
+	s.italic = c.SprintFunc("italic")
+	s.strikethrough = c.SprintFunc("strikethrough")
+	s.code = c.SprintFunc("fg=bright-yellow")
+	s.dim = c.SprintFunc("dim")
+	s.link_text = c.SprintFunc("fg=blue u=straight")
+	s.url = c.UrlFunc("fg=blue u=straight")
+	s.quote_bar = c.SprintFunc("fg=gray")
+	s.bullet = c.SprintFunc("fg=cyan")
+	s.rule = c.SprintFunc("dim")
+	s.table_border = c.SprintFunc("dim")
+	s.table_header = c.SprintFunc("bold")
+	s.code_label = c.SprintFunc("dim italic")
+	s.headings[0] = c.SprintFunc("bold fg=bright-magenta")
+	s.headings[1] = c.SprintFunc("bold fg=bright-blue")
+	s.headings[2] = c.SprintFunc("bold fg=bright-cyan")
+	s.headings[3] = c.SprintFunc("bold fg=bright-green")
+	s.headings[4] = c.SprintFunc("bold")
+	s.headings[5] = c.SprintFunc("bold dim")
+	return &ans
+}
+
+func (self *renderer) resolve_url(src string) string {
+	if src == "" || strings.Contains(src, "://") || strings.HasPrefix(src, "mailto:") {
+		return src
+	}
+	p := src
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(self.opts.BaseDir, p)
+	}
+	if a, err := filepath.Abs(p); err == nil {
+		p = a
+	}
+	return "file://" + utils.Hostname() + p
+}
+
+func (self *renderer) wrap(text string, width int) []string {
+	return style.WrapTextAsLines(text, width, style.WrapOptions{Trim_whitespace: true})
+}
+
+func (self *renderer) render_heading(b *Block, width int) []string {
+	f := self.styles.headings[max(0, min(b.Level, 6)-1)]
+	text := self.render_inline(b.Text)
+	if b.Level > 2 {
+		text = strings.Repeat("#", b.Level) + " " + text
+	}
+	lines := self.wrap(f(text), width)
+	if b.Level <= 2 {
+		w := 0
+		for _, l := range lines {
+			w = max(w, wcswidth.Stringwidth(l))
+		}
+		ch := "═"
+		if b.Level == 2 {
+			ch = "─"
+		}
+		lines = append(lines, f(strings.Repeat(ch, w)))
+	}
+	return lines
+}
+
+func (self *renderer) prefix_lines(lines []string, first, rest string) []string {
+	for i, l := range lines {
+		if i == 0 {
+			lines[i] = first + l
+		} else {
+			lines[i] = rest + l
+		}
+	}
+	return lines
+}
+
+func (self *renderer) render_blockquote(b *Block, width int) []string {
+	bar := self.styles.quote_bar("▎ ")
+	lines := self.render_blocks(b.Children, width-2)
+	return self.prefix_lines(lines, bar, bar)
+}
+
+func (self *renderer) render_list(b *Block, width int, depth int) (ans []string) {
+	markers := make([]string, len(b.Children))
+	mw := 0
+	bullets := []string{"•", "◦", "▪", "▫"}
+	for i := range b.Children {
+		if b.Ordered {
+			markers[i] = strconv.Itoa(b.Start+i) + "."
+		} else {
+			markers[i] = bullets[depth%len(bullets)]
+		}
+		mw = max(mw, wcswidth.Stringwidth(markers[i]))
+	}
+	for i, item := range b.Children {
+		m := markers[i]
+		m = strings.Repeat(" ", mw-wcswidth.Stringwidth(m)) + m + " "
+		lines := self.render_list_item(item, width-mw-1, depth)
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		ans = append(ans, self.prefix_lines(lines, self.styles.bullet(m), strings.Repeat(" ", mw+1))...)
+	}
+	return
+}
+
+func (self *renderer) render_list_item(item *Block, width int, depth int) (ans []string) {
+	// Tight list items have no blank lines between their paragraphs and sub-lists
+	for i, child := range item.Children {
+		var lines []string
+		if child.Type == List {
+			lines = self.render_list(child, width, depth+1)
+		} else {
+			lines = self.render_block(child, width)
+		}
+		if i > 0 && child.Type != List {
+			ans = append(ans, "")
+		}
+		ans = append(ans, lines...)
+	}
+	return
+}
+
+func (self *renderer) render_thematic_break(width int) []string {
+	return []string{self.styles.rule(strings.Repeat("─", width))}
+}
+
+func pad_cell(text string, width int, align Alignment) string {
+	extra := max(0, width-wcswidth.Stringwidth(text))
+	switch align {
+	case AlignRight:
+		return strings.Repeat(" ", extra) + text
+	case AlignCenter:
+		l := extra / 2
+		return strings.Repeat(" ", l) + text + strings.Repeat(" ", extra-l)
+	default:
+		return text + strings.Repeat(" ", extra)
+	}
+}
+
+func (self *renderer) render_table(b *Block, width int) (ans []string) {
+	ncols := len(b.Header)
+	for _, r := range b.Rows {
+		ncols = max(ncols, len(r))
+	}
+	if ncols == 0 {
+		return
+	}
+	render_row := func(r []string, header bool) []string {
+		ans := make([]string, ncols)
+		for i := range ans {
+			if i < len(r) {
+				ans[i] = self.render_inline(r[i])
+				if header {
+					ans[i] = self.styles.table_header(ans[i])
+				}
+			}
+		}
+		return ans
+	}
+	header := render_row(b.Header, true)
+	rows := make([][]string, len(b.Rows))
+	for i, r := range b.Rows {
+		rows[i] = render_row(r, false)
+	}
+	widths := make([]int, ncols)
+	for _, r := range append([][]string{header}, rows...) {
+		for i, c := range r {
+			widths[i] = max(widths[i], wcswidth.Stringwidth(c), 1)
+		}
+	}
+	// borders take 3 cells per column plus one
+	available := width - 3*ncols - 1
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	if total > available && available >= ncols {
+		// shrink the widest columns until everything fits
+		for total > available {
+			widest := 0
+			for i, w := range widths {
+				if w > widths[widest] {
+					widest = i
+				}
+			}
+			widths[widest]--
+			total--
+		}
+	}
+	alignment := func(i int) Alignment {
+		if i < len(b.Alignments) {
+			return b.Alignments[i]
+		}
+		return AlignLeft
+	}
+	bs := self.styles.table_border
+	border := func(left, mid, right string) string {
+		parts := make([]string, ncols)
+		for i, w := range widths {
+			parts[i] = strings.Repeat("─", w+2)
+		}
+		return bs(left + strings.Join(parts, mid) + right)
+	}
+	emit_row := func(r []string) {
+		cells := make([][]string, ncols)
+		height := 1
+		for i, c := range r {
+			if wcswidth.Stringwidth(c) > widths[i] {
+				cells[i] = self.wrap(c, widths[i])
+			} else {
+				cells[i] = []string{c}
+			}
+			height = max(height, len(cells[i]))
+		}
+		for y := 0; y < height; y++ {
+			line := strings.Builder{}
+			line.WriteString(bs("│"))
+			for i, c := range cells {
+				text := ""
+				if y < len(c) {
+					text = c[y]
+				}
+				line.WriteString(" " + pad_cell(text, widths[i], alignment(i)) + " ")
+				line.WriteString(bs("│"))
+			}
+			ans = append(ans, line.String())
+		}
+	}
+	ans = append(ans, border("┌", "┬", "┐"))
+	emit_row(header)
+	ans = append(ans, border("╞", "╪", "╡"))
+	for _, r := range rows {
+		emit_row(r)
+	}
+	ans = append(ans, border("└", "┴", "┘"))
+	return
+}
+
+func (self *renderer) highlight_code(code, lang string) []string {
+	theme := self.opts.CodeTheme
+	if !self.opts.Formatting || theme == "" || theme == "none" || lang == "" {
+		return strings.Split(code, "\n")
+	}
+	lexer := lexers.Get(lang)
+	cs := styles.Get(theme)
+	if lexer == nil || cs == nil {
+		return strings.Split(code, "\n")
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return strings.Split(code, "\n")
+	}
+	lines := []string{}
+	current := strings.Builder{}
+	for token := it(); token != chroma.EOF; token = it() {
+		entry := cs.Get(token.Type)
+		prefix, suffix := "", ""
+		if !entry.IsZero() {
+			var p, s []string
+			if entry.Bold == chroma.Yes {
+				p, s = append(p, "1"), append(s, "221")
+			}
+			if entry.Italic == chroma.Yes {
+				p, s = append(p, "3"), append(s, "23")
+			}
+			if entry.Underline == chroma.Yes {
+				p, s = append(p, "4"), append(s, "24")
+			}
+			if entry.Colour.IsSet() {
+				p, s = append(p, fmt.Sprintf("38:2:%d:%d:%d", entry.Colour.Red(), entry.Colour.Green(), entry.Colour.Blue())), append(s, "39")
+			}
+			if len(p) > 0 {
+				prefix, suffix = "\x1b["+strings.Join(p, ";")+"m", "\x1b["+strings.Join(s, ";")+"m"
+			}
+		}
+		// format each line of a multiline token independently so that lines can be output separately
+		for i, text := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				lines = append(lines, current.String())
+				current.Reset()
+			}
+			if text != "" {
+				current.WriteString(prefix + text + suffix)
+			}
+		}
+	}
+	lines = append(lines, current.String())
+	if len(lines) > 1 && lines[len(lines)-1] == "" && !strings.HasSuffix(code, "\n\n") {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func (self *renderer) render_code_block(b *Block, width int) (ans []string) {
+	if b.Language != "" {
+		ans = append(ans, self.styles.code_label("  "+b.Language))
+	}
+	if !self.opts.Formatting {
+		for _, line := range strings.Split(b.Text, "\n") {
+			ans = append(ans, "    "+line)
+		}
+		return
+	}
+	bar := self.styles.quote_bar("┃ ")
+	for _, line := range self.highlight_code(b.Text, b.Language) {
+		if wcswidth.Stringwidth(line) > width-2 {
+			line = wcswidth.TruncateToVisualLength(line, width-3) + "\x1b[m" + self.styles.dim("…")
+		}
+		ans = append(ans, bar+line)
+	}
+	return
+}
+
+func (self *renderer) load_image(src string) (data []byte, cfg image.Config, err error) {
+	if strings.Contains(src, "://") {
+		if !strings.HasPrefix(src, "file://") {
+			return nil, cfg, fmt.Errorf("Remote images are not supported")
+		}
+		src = src[len("file://"):]
+		if idx := strings.IndexByte(src, '/'); idx > 0 {
+			src = src[idx:]
+		}
+	}
+	if !filepath.IsAbs(src) {
+		src = filepath.Join(self.opts.BaseDir, src)
+	}
+	if data, err = os.ReadFile(src); err != nil {
+		return
+	}
+	var format string
+	if cfg, format, err = image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return
+	}
+	if format != "png" {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, cfg, err
+		}
+		buf := bytes.Buffer{}
+		if err = images.Encode(&buf, img, "image/png"); err != nil {
+			return nil, cfg, err
+		}
+		data = buf.Bytes()
+	}
+	return
+}
+
+func (self *renderer) render_image(img *Image, width int) []string {
+	data, cfg, err := self.load_image(img.Source)
+	if err != nil {
+		return self.wrap(self.render_inline_image(*img), width)
+	}
+	cols := width
+	if self.opts.CellWidth > 0 {
+		cols = min(width, (cfg.Width+self.opts.CellWidth-1)/self.opts.CellWidth)
+	} else {
+		cols = min(width, max(1, width/2))
+	}
+	gc := graphics.GraphicsCommand{}
+	gc.SetAction(graphics.GRT_action_transmit_and_display).SetFormat(graphics.GRT_format_png).SetQuiet(graphics.GRT_quiet_silent)
+	gc.SetColumns(uint64(cols))
+	return []string{gc.AsAPC(data)}
+}
+
+func (self *renderer) render_block(b *Block, width int) []string {
+	width = max(8, width)
+	switch b.Type {
+	case Heading:
+		return self.render_heading(b, width)
+	case CodeBlock:
+		return self.render_code_block(b, width)
+	case BlockQuote:
+		return self.render_blockquote(b, width)
+	case List:
+		return self.render_list(b, width, 0)
+	case ListItem:
+		return self.render_list_item(b, width, 0)
+	case Table:
+		return self.render_table(b, width)
+	case ThematicBreak:
+		return self.render_thematic_break(width)
+	default:
+		if self.opts.Images {
+			if img := paragraph_as_image(b.Text); img != nil {
+				return self.render_image(img, width)
+			}
+		}
+		return self.wrap(self.render_inline(b.Text), width)
+	}
+}
+
+func (self *renderer) render_blocks(blocks []*Block, width int) (ans []string) {
+	for i, b := range blocks {
+		if i > 0 {
+			ans = append(ans, "")
+		}
+		ans = append(ans, self.render_block(b, width)...)
+	}
+	return
+}
+
+// Render the specified Markdown text as lines of text formatted with escape
+// codes, suitable for display in a terminal. Can be used by other kittens to
+// preview Markdown documents.
+func Render(text string, opts RenderOptions) []string {
+	if opts.Width < 1 {
+		opts.Width = 80
+	}
+	if !opts.Formatting {
+		opts.Hyperlinks = false
+		opts.Images = false
+	}
+	r := new_renderer(opts)
+	return r.render_blocks(Parse(text), opts.Width)
+}
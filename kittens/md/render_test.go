@@ -0,0 +1,81 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package md
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestMarkdownParsing(t *testing.T) {
+	types := func(blocks []*Block) (ans []BlockType) {
+		for _, b := range blocks {
+			ans = append(ans, b.Type)
+		}
+		return
+	}
+	tp := func(text string, expected ...BlockType) []*Block {
+		blocks := Parse(text)
+		if diff := cmp.Diff(expected, types(blocks)); diff != "" {
+			t.Fatalf("Unexpected blocks for: %#v\n%s", text, diff)
+		}
+		return blocks
+	}
+	b := tp("# one\n\npara\ngraph\n\n---\n", Heading, Paragraph, ThematicBreak)
+	if b[0].Level != 1 || b[0].Text != "one" || b[1].Text != "para\ngraph" {
+		t.Fatalf("Incorrect parse: %#v %#v", b[0], b[1])
+	}
+	b = tp("setext\n======\nh2\n---", Heading, Heading)
+	if b[0].Level != 1 || b[1].Level != 2 {
+		t.Fatalf("Incorrect heading levels: %d %d", b[0].Level, b[1].Level)
+	}
+	b = tp("```py\nx = 1\n\ny = 2\n```\nafter", CodeBlock, Paragraph)
+	if b[0].Language != "py" || b[0].Text != "x = 1\n\ny = 2" {
+		t.Fatalf("Incorrect code block: %#v", b[0])
+	}
+	b = tp("- a\n- b\n  - c\n\n- d\n", List)
+	if len(b[0].Children) != 3 || types(b[0].Children[1].Children)[1] != List {
+		t.Fatalf("Incorrect list parse: %#v", b[0].Children)
+	}
+	b = tp("3. x\n4. y", List)
+	if !b[0].Ordered || b[0].Start != 3 {
+		t.Fatalf("Incorrect ordered list: %#v", b[0])
+	}
+	b = tp("> q\nlazy\n\n> r", BlockQuote, BlockQuote)
+	if b[0].Children[0].Text != "q\nlazy" {
+		t.Fatalf("Incorrect blockquote: %#v", b[0].Children[0])
+	}
+	b = tp("| a | b \\| c | `x|y` |\n|:-|-:|:-:|\n| 1 | 2 | 3 |", Table)
+	if diff := cmp.Diff([]string{"a", "b | c", "`x|y`"}, b[0].Header); diff != "" {
+		t.Fatalf("Incorrect table header:\n%s", diff)
+	}
+	if diff := cmp.Diff([]Alignment{AlignLeft, AlignRight, AlignCenter}, b[0].Alignments); diff != "" {
+		t.Fatalf("Incorrect table alignments:\n%s", diff)
+	}
+}
+
+func TestMarkdownRendering(t *testing.T) {
+	plain := func(text string, expected ...string) {
+		actual := Render(text, RenderOptions{Width: 20})
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Unexpected rendering for: %#v\n%s", text, diff)
+		}
+	}
+	plain("*a* **b** `c` ~~d~~ snake_case \\*e\\*", "a b c d snake_case", "*e*")
+	plain("[text](http://x.com) <http://y.com>", "text http://y.com")
+	plain("# Head", "Head", "════")
+	plain("- one\n- two", "• one", "• two")
+	plain("1. one\n2. two", "1. one", "2. two")
+	plain("| a | b |\n|---|--:|\n| xx | y |",
+		"┌────┬───┐", "│ a  │ b │", "╞────╪───╡", "│ xx │ y │", "└────┴───┘")
+
+	r := new_renderer(RenderOptions{Width: 20, Formatting: true, Hyperlinks: true})
+	if q := r.render_inline("[a](http://x.com)"); !strings.Contains(q, "\x1b]8;;http://x.com\x1b\\a") {
+		t.Fatalf("No hyperlink in: %#v", q)
+	}
+}
@@ -0,0 +1,167 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package notify
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"kitty/tools/cli"
+	"kitty/tools/config"
+	"kitty/tools/dbus"
+	"kitty/tools/tty"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type notification struct {
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	Identifier string `json:"identifier"`
+}
+
+// The characters allowed in identifiers by the desktop notifications protocol
+var valid_identifier = regexp.MustCompile(`^[a-zA-Z0-9-_+.]+$`)
+
+const query_timeout = 2 * time.Second
+
+func load_config(opts *Options) (ans *Config, err error) {
+	ans = NewConfig()
+	p := config.ConfigParser{LineHandler: ans.Parse}
+	if err = p.LoadConfig("notify.conf", opts.Config, opts.Override); err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+// The OSC 99 escape codes to display the notification, the payloads are
+// base64 encoded so that they can contain arbitrary text
+func (self *notification) escape_codes() string {
+	enc := base64.StdEncoding.EncodeToString
+	if self.Body == "" {
+		return fmt.Sprintf("\x1b]99;i=%s:e=1;%s\x1b\\", self.Identifier, enc([]byte(self.Title)))
+	}
+	return fmt.Sprintf("\x1b]99;i=%s:d=0:e=1;%s\x1b\\\x1b]99;i=%s:p=body:e=1;%s\x1b\\",
+		self.Identifier, enc([]byte(self.Title)), self.Identifier, enc([]byte(self.Body)))
+}
+
+// Whether the terminal, identified by its response to XTVERSION, supports the
+// desktop notifications protocol
+func supports_notifications(xtversion string) bool {
+	name, _, _ := strings.Cut(xtversion, "(")
+	return strings.TrimSpace(name) == "kitty"
+}
+
+// The response of the terminal to XTVERSION. Primary device attributes is
+// queried after it, as all terminals respond to that, so that terminals that
+// do not support XTVERSION do not cause a delay.
+func query_terminal_version() (ans string, err error) {
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return
+	}
+	lp.OnInitialize = func() (string, error) {
+		_, _ = lp.AddTimer(query_timeout, false, func(loop.IdType) error {
+			lp.Quit(0)
+			return nil
+		})
+		lp.QueueWriteString("\x1b[>0q\x1b[c")
+		return "", nil
+	}
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, payload []byte) error {
+		raw := utils.UnsafeBytesToString(payload)
+		switch etype {
+		case loop.CSI:
+			if strings.HasPrefix(raw, "?") && strings.HasSuffix(raw, "c") {
+				lp.Quit(0)
+			}
+		case loop.DCS:
+			if v, found := strings.CutPrefix(raw, ">|"); found {
+				ans = strings.Clone(v)
+			}
+		}
+		return nil
+	}
+	err = lp.Run()
+	return
+}
+
+func notify_via_terminal(n *notification) error {
+	term, err := tty.OpenControllingTerm()
+	if err != nil {
+		return fmt.Errorf("Failed to open the controlling terminal with error: %w", err)
+	}
+	defer term.Close()
+	return term.WriteAllString(n.escape_codes())
+}
+
+// Show the notification via org.freedesktop.Notifications, for when it is not
+// run in a terminal that supports the desktop notifications protocol
+func notify_via_desktop(n *notification) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("The terminal does not support desktop notifications and connecting to the D-Bus session bus failed with error: %w", err)
+	}
+	defer conn.Close()
+	_, err = conn.Notify("kitty", n.Title, n.Body, 0)
+	return err
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if len(args) == 0 {
+		return 1, fmt.Errorf("Must specify the title of the notification")
+	}
+	n := notification{Title: args[0], Body: strings.Join(args[1:], " "), Identifier: opts.Identifier}
+	if n.Identifier == "" {
+		n.Identifier = "notify-" + utils.RandomFilename()
+	} else if !valid_identifier.MatchString(n.Identifier) {
+		return 1, fmt.Errorf("Invalid notification identifier: %#v", n.Identifier)
+	}
+	var to_send []sink
+	if len(opts.Also) > 0 {
+		conf, err := load_config(opts)
+		if err != nil {
+			return 1, err
+		}
+		available, err := sinks_from_config(conf)
+		if err != nil {
+			return 1, err
+		}
+		// resolve all sinks before sending anything, so that typos do not
+		// result in partially sent notifications
+		for _, spec := range opts.Also {
+			s, err := available.find(spec)
+			if err != nil {
+				return 1, err
+			}
+			to_send = append(to_send, s)
+		}
+	}
+	// not having a controlling terminal, as when run from cron, means
+	// the notification can only be shown via D-Bus
+	if xtversion, qerr := query_terminal_version(); qerr == nil && supports_notifications(xtversion) {
+		err = notify_via_terminal(&n)
+	} else {
+		err = notify_via_desktop(&n)
+	}
+	if err != nil {
+		return 1, err
+	}
+	for _, s := range to_send {
+		if serr := s.send(&n); serr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send the notification to %s with error: %s\n", s.spec(), serr)
+			rc = 1
+		}
+	}
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
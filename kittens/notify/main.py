@@ -0,0 +1,103 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from functools import partial
+from typing import List
+
+from kitty.cli import CONFIG_HELP
+from kitty.conf.types import Definition
+from kitty.constants import appname
+
+definition = Definition(
+    '!kittens.notify',
+)
+
+agr = definition.add_group
+egr = definition.end_group
+opt = definition.add_option
+
+agr('sinks', 'Additional destinations for notifications')  # {{{
+
+opt('+webhook', '', ctype='string', add_to_default=False, long_text='''
+A named webhook that notifications can be sent to, in addition to the
+terminal, with :option:`kitten notify --also`. The syntax is::
+
+    webhook name URL
+
+The notification is sent to the URL as an HTTP POST request with a JSON
+object containing the :code:`title`, :code:`body` and :code:`identifier`
+of the notification. Can be specified multiple times to define multiple
+webhooks.
+''')
+
+opt('+script', '', ctype='string', add_to_default=False, long_text='''
+A named script that notifications can be piped to, in addition to the
+terminal, with :option:`kitten notify --also`. The syntax is::
+
+    script name command
+
+The command is run by the shell, with the same JSON object as is sent to
+webhooks on its STDIN and the environment variables
+:envvar:`KITTY_NOTIFY_TITLE`, :envvar:`KITTY_NOTIFY_BODY` and
+:envvar:`KITTY_NOTIFY_IDENTIFIER` set. This is useful for forwarding
+notifications to services that need a particular format, for example,
+to your phone via ntfy::
+
+    script phone curl -s -H "Title: $KITTY_NOTIFY_TITLE" -d "$KITTY_NOTIFY_BODY" https://ntfy.sh/mytopic
+
+Can be specified multiple times to define multiple scripts.
+''')
+
+egr()  # }}}
+
+OPTIONS = partial('''\
+--identifier -i
+The identifier for the notification. Sending another notification with the
+same identifier replaces it. Can contain only the characters
+:code:`a-zA-Z0-9-_+.`. Defaults to a random identifier.
+
+
+--also
+type=list
+Also send the notification to the specified webhook or script from
+:file:`notify.conf`, as :code:`webhook:name` or :code:`script:name`. Can be
+specified multiple times.
+
+
+--config
+type=list
+completion=type:file ext:conf group:"Config files" kwds:none,NONE
+{config_help}
+
+
+--override -o
+type=list
+Override individual configuration options, can be specified multiple times.
+Syntax: :italic:`name=value`. For example: :italic:`-o "webhook=test https://example.com/notify"`
+'''.format, config_help=CONFIG_HELP.format(conf_name='notify', appname=appname))
+help_text = '''\
+Show a desktop notification using the :doc:`desktop notifications protocol </desktop-notifications>`
+of the terminal. The first argument is the title of the notification, the remaining
+arguments, if any, are joined to form its body. When not running in kitty, the
+notification is shown via D-Bus instead. Notifications can additionally be
+sent to webhooks or scripts, for example, to forward them to your phone, see
+:option:`kitten notify --also`.
+'''
+usage = 'TITLE [BODY ...]'
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten notify')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Show desktop notifications, optionally forwarding them elsewhere'
+elif __name__ == '__conf__':
+    sys.options_definition = definition  # type: ignore
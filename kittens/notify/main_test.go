@@ -0,0 +1,105 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestNotifyEscapeCodes(t *testing.T) {
+	n := notification{Title: "héllo", Identifier: "x"}
+	if diff := cmp.Diff("\x1b]99;i=x:e=1;aMOpbGxv\x1b\\", n.escape_codes()); diff != "" {
+		t.Fatalf("Incorrect escape code for title:\n%s", diff)
+	}
+	n.Body = "a;b"
+	if diff := cmp.Diff("\x1b]99;i=x:d=0:e=1;aMOpbGxv\x1b\\\x1b]99;i=x:p=body:e=1;YTti\x1b\\", n.escape_codes()); diff != "" {
+		t.Fatalf("Incorrect escape code for title and body:\n%s", diff)
+	}
+	for id, valid := range map[string]bool{"a-Z_0.9+": true, "a:b": false, "a;b": false, "": false} {
+		if valid_identifier.MatchString(id) != valid {
+			t.Fatalf("Identifier %#v not validated correctly", id)
+		}
+	}
+	for xtversion, supported := range map[string]bool{"kitty(0.36.0)": true, "kitty": true, "tmux 3.4": false, "kittyx(1)": false, "": false} {
+		if supports_notifications(xtversion) != supported {
+			t.Fatalf("Support for notifications not detected correctly for: %#v", xtversion)
+		}
+	}
+}
+
+func TestNotifySinks(t *testing.T) {
+	n := notification{Title: "title", Body: "body", Identifier: "id"}
+	var received notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || json.Unmarshal(data, &received) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	output := filepath.Join(t.TempDir(), "output")
+	conf := NewConfig()
+	conf.Webhook = []string{"ok " + server.URL, "missing " + server.URL + "/missing"}
+	conf.Script = []string{`out cat > "$OUTPUT"; echo "$KITTY_NOTIFY_TITLE $KITTY_NOTIFY_BODY $KITTY_NOTIFY_IDENTIFIER" >> "$OUTPUT"`}
+	t.Setenv("OUTPUT", output)
+	available, err := sinks_from_config(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := available.find("webhook:ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.send(&n); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(n, received); diff != "" {
+		t.Fatalf("Incorrect notification received by webhook:\n%s", diff)
+	}
+	if s, err = available.find("webhook:missing"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.send(&n); err == nil {
+		t.Fatalf("No error for a webhook that responded with a failure")
+	}
+
+	if s, err = available.find("script:out"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.send(&n); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(`{"title":"title","body":"body","identifier":"id"}title body id`+"\n", string(data)); diff != "" {
+		t.Fatalf("Incorrect data received by script:\n%s", diff)
+	}
+
+	for _, spec := range []string{"script:ok", "webhook:nonexistent", "email:ok", "ok"} {
+		if _, err = available.find(spec); err == nil {
+			t.Fatalf("No error for unknown destination: %#v", spec)
+		}
+	}
+	conf.Webhook = []string{"no-url"}
+	if _, err = sinks_from_config(conf); err == nil {
+		t.Fatalf("No error for webhook without a URL")
+	}
+}
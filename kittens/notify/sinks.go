@@ -0,0 +1,112 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const webhook_timeout = 30 * time.Second
+
+// A destination that notifications are sent to in addition to the terminal,
+// defined in notify.conf
+type sink interface {
+	send(n *notification) error
+	spec() string
+}
+
+type webhook struct{ name, url string }
+
+func (self *webhook) spec() string { return "webhook:" + self.name }
+
+func (self *webhook) send(n *notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	client, err := utils.HTTPClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhook_timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, self.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("The server at %s responded with: %s", self.url, resp.Status)
+	}
+	return nil
+}
+
+type script struct{ name, cmd string }
+
+func (self *script) spec() string { return "script:" + self.name }
+
+func (self *script) send(n *notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	c := exec.Command("/bin/sh", "-c", self.cmd)
+	c.Env = append(os.Environ(), "KITTY_NOTIFY_TITLE="+n.Title, "KITTY_NOTIFY_BODY="+n.Body, "KITTY_NOTIFY_IDENTIFIER="+n.Identifier)
+	c.Stdin = bytes.NewReader(data)
+	// STDOUT may be used by the program that ran the kitten
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	return c.Run()
+}
+
+type sinks map[string]sink
+
+func sinks_from_config(conf *Config) (ans sinks, err error) {
+	ans = make(sinks)
+	add := func(kind string, specs []string, create func(name, val string) sink) error {
+		for _, x := range specs {
+			name, val, _ := strings.Cut(strings.TrimSpace(x), " ")
+			if val = strings.TrimSpace(val); name == "" || val == "" {
+				return fmt.Errorf("Invalid %s in notify.conf, must be of the form: name value, got: %#v", kind, x)
+			}
+			ans[kind+":"+name] = create(name, val)
+		}
+		return nil
+	}
+	if err = add("webhook", conf.Webhook, func(name, val string) sink { return &webhook{name, val} }); err != nil {
+		return nil, err
+	}
+	if err = add("script", conf.Script, func(name, val string) sink { return &script{name, val} }); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (self sinks) find(spec string) (sink, error) {
+	if s := self[spec]; s != nil {
+		return s, nil
+	}
+	kind, _, _ := strings.Cut(spec, ":")
+	if kind != "webhook" && kind != "script" {
+		return nil, fmt.Errorf("Invalid destination for the notification: %#v, must be of the form webhook:name or script:name", spec)
+	}
+	return nil, fmt.Errorf("No %s named %#v is defined in notify.conf", kind, spec[len(kind)+1:])
+}
@@ -15,10 +15,15 @@
     GLFW_EDGE_TOP,
     GLFW_LAYER_SHELL_BACKGROUND,
     GLFW_LAYER_SHELL_PANEL,
+    glfw_get_monitors,
     glfw_primary_monitor_size,
     make_x11_window_a_dock_window,
+    set_os_window_input_region,
+    set_os_window_mouse_passthrough,
+    set_os_window_pos,
 )
 from kitty.os_window_size import WindowSizeData, edge_spacing
+from kitty.rc.set_click_through import parse_input_region
 from kitty.types import LayerShellConfig
 from kitty.typing import EdgeLiteral
 
@@ -40,6 +45,65 @@
 kitten.
 
 
+--margin-top
+type=int
+default=0
+Leave a gap of the specified number of pixels between the panel and the top edge
+of the screen. Only applies for panels anchored to the top edge or to the left or right edges.
+
+
+--margin-left
+type=int
+default=0
+Leave a gap of the specified number of pixels between the panel and the left edge
+of the screen. Only applies for panels anchored to the left edge or to the top or bottom edges.
+
+
+--margin-bottom
+type=int
+default=0
+Leave a gap of the specified number of pixels between the panel and the bottom edge
+of the screen. Only applies for panels anchored to the bottom edge or to the left or right edges.
+
+
+--margin-right
+type=int
+default=0
+Leave a gap of the specified number of pixels between the panel and the right edge
+of the screen. Only applies for panels anchored to the right edge or to the top or bottom edges.
+
+
+--exclusive-zone
+type=int
+default=-1
+Reserve the specified number of pixels at the panel edge, so that other windows
+are not placed there. On X11 this is done using struts. A value of zero means the panel
+is drawn over other windows without reserving any space. Only used if
+:option:`kitty +kitten panel --override-exclusive-zone` is specified, otherwise the space reserved is the
+size of the panel.
+
+
+--override-exclusive-zone
+type=bool-set
+Use the value of :option:`kitty +kitten panel --exclusive-zone` for the space reserved
+by the panel instead of the size of the panel.
+
+
+--click-through
+type=bool-set
+Make the panel ignore all mouse input, so that clicks and scrolling go to the
+windows below it. Useful for overlays that only display information. Can be
+changed at runtime with :ref:`at-set-click-through`.
+
+
+--input-region
+type=list
+Restrict mouse input for the panel to a rectangle of the form :italic:`x,y,width,height`
+in pixels, relative to the top left corner of the panel. Mouse input outside the
+rectangle goes to the windows below the panel. Can be specified multiple times.
+Ignored if :option:`kitty +kitten panel --click-through` is specified.
+
+
 --config -c
 type=list
 Path to config file to use for kitty when drawing the panel.
@@ -52,9 +116,22 @@
 
 
 --output-name
-On Wayland, the panel can only be displayed on a single monitor (output) at a time. This allows
-you to specify which output is used, by name. If not specified the compositor will choose an
+type=list
+A panel can only be displayed on a single monitor (output). This allows you to specify
+which output is used, by name. If not specified, on Wayland, the compositor will choose an
 output automatically, typically the last output the user interacted with or the primary monitor.
+On X11, the primary monitor is used and output names are the names shown by :program:`xrandr`.
+Can be specified multiple times to show the panel on multiple outputs, in which case a separate
+panel, running its own copy of the program, is created for every output.
+
+
+--output-command
+type=list
+Run a different program in the panel on a specific output. Syntax: :italic:`output-name=program`,
+for example: :code:`--output-command="HDMI-A-1=htop -d 10"`. The program is split into
+arguments using shell quoting rules. Outputs specified here are added to the list of outputs
+from :option:`kitty +kitten panel --output-name`. Outputs without a specific program run the program specified
+on the command line. Can be specified multiple times.
 
 
 --class
@@ -76,7 +153,7 @@
 
 
 args = PanelCLIOptions()
-help_text = 'Use a command line program to draw a GPU accelerated panel on your X11 desktop'
+help_text = 'Use a command line program to draw a GPU accelerated panel on your desktop'
 usage = 'program-to-run'
 
 
@@ -96,55 +173,114 @@ def create_strut(
     return left, right, top, bottom, left_start_y, left_end_y, right_start_y, right_end_y, top_start_x, top_end_x, bottom_start_x, bottom_end_x
 
 
-def create_top_strut(win_id: int, width: int, height: int) -> Strut:
-    return create_strut(win_id, top=height, top_end_x=width)
+def create_top_strut(win_id: int, size: int, start: int, end: int) -> Strut:
+    return create_strut(win_id, top=size, top_start_x=start, top_end_x=end)
+
+
+def create_bottom_strut(win_id: int, size: int, start: int, end: int) -> Strut:
+    return create_strut(win_id, bottom=size, bottom_start_x=start, bottom_end_x=end)
+
 
+def create_left_strut(win_id: int, size: int, start: int, end: int) -> Strut:
+    return create_strut(win_id, left=size, left_start_y=start, left_end_y=end)
 
-def create_bottom_strut(win_id: int, width: int, height: int) -> Strut:
-    return create_strut(win_id, bottom=height, bottom_end_x=width)
 
+def create_right_strut(win_id: int, size: int, start: int, end: int) -> Strut:
+    return create_strut(win_id, right=size, right_start_y=start, right_end_y=end)
 
-def create_left_strut(win_id: int, width: int, height: int) -> Strut:
-    return create_strut(win_id, left=width, left_end_y=height)
 
+window_width = window_height = window_x = window_y = 0
+monitor_geometry = 0, 0, 0, 0
+root_width = root_height = 0
 
-def create_right_strut(win_id: int, width: int, height: int) -> Strut:
-    return create_strut(win_id, right=width, right_end_y=height)
 
+def monitor_for_output(output_name: str) -> Tuple[int, int, int, int]:
+    global root_width, root_height
+    monitors = glfw_get_monitors()
+    if not monitors:
+        w, h = glfw_primary_monitor_size()
+        root_width, root_height = w, h
+        return 0, 0, w, h
+    root_width = max(x + w for _, x, y, w, h in monitors)
+    root_height = max(y + h for _, x, y, w, h in monitors)
+    for name, x, y, w, h in monitors:
+        if name == output_name:
+            return x, y, w, h
+    # The primary monitor is always first
+    return monitors[0][1:]
 
-window_width = window_height = 0
+
+def reserved_size() -> int:
+    # The size of the area reserved for the panel from the edge of the
+    # monitor, the equivalent of the exclusive zone on Wayland
+    if args.edge not in ('top', 'bottom', 'left', 'right'):
+        return 0
+    if args.override_exclusive_zone:
+        zone = args.exclusive_zone
+    else:
+        zone = window_height if args.edge in ('top', 'bottom') else window_width
+    return zone + max(0, getattr(args, f'margin_{args.edge}')) if zone > 0 else 0
 
 
 def setup_x11_window(win_id: int) -> None:
     if is_wayland():
         return
-    func = globals()[f'create_{args.edge}_strut']
-    strut = func(win_id, window_width, window_height)
+    mx, my, mw, mh = monitor_geometry
+    size = reserved_size()
+    if size <= 0:
+        strut = create_strut(win_id)
+    elif args.edge == 'top':
+        strut = create_top_strut(win_id, my + size, window_x, window_x + window_width - 1)
+    elif args.edge == 'bottom':
+        strut = create_bottom_strut(win_id, root_height - my - mh + size, window_x, window_x + window_width - 1)
+    elif args.edge == 'left':
+        strut = create_left_strut(win_id, mx + size, window_y, window_y + window_height - 1)
+    else:
+        strut = create_right_strut(win_id, root_width - mx - mw + size, window_y, window_y + window_height - 1)
     make_x11_window_a_dock_window(win_id, strut)
 
 
+def setup_os_window(os_window_id: int) -> None:
+    if not is_wayland():
+        set_os_window_pos(os_window_id, window_x, window_y)
+    if args.click_through:
+        set_os_window_mouse_passthrough(os_window_id, True)
+    elif args.input_region:
+        set_os_window_input_region(os_window_id, [parse_input_region(x) for x in args.input_region])
+
+
 def initial_window_size_func(opts: WindowSizeData, cached_values: Dict[str, Any]) -> Callable[[int, int, float, float, float, float], Tuple[int, int]]:
 
     def es(which: EdgeLiteral) -> float:
         return edge_spacing(which, opts)
 
     def initial_window_size(cell_width: int, cell_height: int, dpi_x: float, dpi_y: float, xscale: float, yscale: float) -> Tuple[int, int]:
-        if not is_macos and not is_wayland():
-            # Not sure what the deal with scaling on X11 is
-            xscale = yscale = 1
-        global window_width, window_height
-        monitor_width, monitor_height = glfw_primary_monitor_size()
+        global window_width, window_height, window_x, window_y, monitor_geometry
+        if is_wayland():
+            monitor_width, monitor_height = glfw_primary_monitor_size()
+            mt = ml = mb = mr = 0
+        else:
+            if not is_macos:
+                # Not sure what the deal with scaling on X11 is
+                xscale = yscale = 1
+            monitor_geometry = monitor_for_output(args.output_name[0] if args.output_name else '')
+            mx, my, monitor_width, monitor_height = monitor_geometry
+            mt, ml, mb, mr = (max(0, x) for x in (args.margin_top, args.margin_left, args.margin_bottom, args.margin_right))
 
         if args.edge in {'top', 'bottom'}:
             spacing = es('top') + es('bottom')
             window_height = int(cell_height * args.lines / yscale + (dpi_y / 72) * spacing + 1)
-            window_width = monitor_width
+            window_width = max(1, monitor_width - ml - mr)
         elif args.edge == 'background':
             window_width, window_height = monitor_width, monitor_height
+            mt = ml = mb = mr = 0
         else:
             spacing = es('left') + es('right')
             window_width = int(cell_width * args.lines / xscale + (dpi_x / 72) * spacing + 1)
-            window_height = monitor_height
+            window_height = max(1, monitor_height - mt - mb)
+        if not is_wayland():
+            window_x = mx + (monitor_width - mr - window_width if args.edge == 'right' else ml)
+            window_y = my + (monitor_height - mb - window_height if args.edge == 'bottom' else mt)
         return window_width, window_height
 
     return initial_window_size
@@ -153,14 +289,84 @@ def initial_window_size(cell_width: int, cell_height: int, dpi_x: float, dpi_y:
 def layer_shell_config(opts: PanelCLIOptions) -> LayerShellConfig:
     ltype = GLFW_LAYER_SHELL_BACKGROUND if opts.edge == 'background' else GLFW_LAYER_SHELL_PANEL
     edge = {'top': GLFW_EDGE_TOP, 'bottom': GLFW_EDGE_BOTTOM, 'left': GLFW_EDGE_LEFT, 'right': GLFW_EDGE_RIGHT}.get(opts.edge, GLFW_EDGE_TOP)
-    return LayerShellConfig(type=ltype, edge=edge, size_in_cells=max(1, opts.lines), output_name=opts.output_name or '')
+    return LayerShellConfig(
+        type=ltype, edge=edge, size_in_cells=max(1, opts.lines), output_name=opts.output_name[0] if opts.output_name else '',
+        requested_top_margin=max(0, opts.margin_top), requested_left_margin=max(0, opts.margin_left),
+        requested_bottom_margin=max(0, opts.margin_bottom), requested_right_margin=max(0, opts.margin_right),
+        requested_exclusive_zone=opts.exclusive_zone, override_exclusive_zone=opts.override_exclusive_zone)
+
+
+def commands_per_output(opts: PanelCLIOptions, items: List[str]) -> Dict[str, List[str]]:
+    import shlex
+    ans: Dict[str, List[str]] = {x: items for x in opts.output_name}
+    for spec in opts.output_command:
+        name, sep, cmd = spec.partition('=')
+        name = name.strip()
+        if not sep or not name or not cmd.strip():
+            raise SystemExit(f'The output command: {spec} is not of the form output-name=program')
+        ans[name] = shlex.split(cmd)
+    for name, cmd in ans.items():
+        if not cmd:
+            raise SystemExit(f'You must specify the program to run on the output: {name}')
+    return ans
+
+
+def panel_args_for_output(opts: PanelCLIOptions, output_name: str) -> List[str]:
+    ans = [
+        f'--lines={opts.lines}', f'--edge={opts.edge}', f'--output-name={output_name}', f'--class={opts.cls}',
+        f'--margin-top={opts.margin_top}', f'--margin-left={opts.margin_left}',
+        f'--margin-bottom={opts.margin_bottom}', f'--margin-right={opts.margin_right}',
+        f'--exclusive-zone={opts.exclusive_zone}',
+    ]
+    if opts.override_exclusive_zone:
+        ans.append('--override-exclusive-zone')
+    if opts.click_through:
+        ans.append('--click-through')
+    ans.extend(f'--input-region={x}' for x in opts.input_region)
+    if opts.name:
+        ans.append(f'--name={opts.name}')
+    if opts.debug_rendering:
+        ans.append('--debug-rendering')
+    ans.extend(f'--config={x}' for x in opts.config)
+    ans.extend(f'--override={x}' for x in opts.override)
+    return ans
+
+
+def run_panel_per_output(opts: PanelCLIOptions, commands: Dict[str, List[str]]) -> None:
+    import subprocess
+
+    from kitty.constants import kitty_exe
+    exe = kitty_exe()
+    procs = [
+        subprocess.Popen([exe, '+kitten', 'panel'] + panel_args_for_output(opts, name) + ['--'] + cmd)
+        for name, cmd in commands.items()]
+    rc = 0
+    try:
+        for p in procs:
+            rc = p.wait() or rc
+    except KeyboardInterrupt:
+        for p in procs:
+            p.terminate()
+        rc = 1
+    raise SystemExit(rc)
 
 
 def main(sys_args: List[str]) -> None:
     global args
-    if is_macos or not os.environ.get('DISPLAY'):
-        raise SystemExit('Currently the panel kitten is supported only on X11 desktops')
+    if is_macos or not (os.environ.get('DISPLAY') or os.environ.get('WAYLAND_DISPLAY')):
+        raise SystemExit('Currently the panel kitten is supported only on X11 desktops and Wayland compositors')
     args, items = parse_panel_args(sys_args[1:])
+    for spec in args.input_region:
+        try:
+            parse_input_region(spec)
+        except ValueError as e:
+            raise SystemExit(str(e))
+    commands = commands_per_output(args, items)
+    if len(commands) > 1:
+        run_panel_per_output(args, commands)
+    if commands:
+        args.output_name = list(commands)
+        items = next(iter(commands.values()))
     if not items:
         raise SystemExit('You must specify the program to run')
     sys.argv = ['kitty']
@@ -180,6 +386,7 @@ def main(sys_args: List[str]) -> None:
     run_app.cached_values_name = 'panel'
     run_app.layer_shell_config = layer_shell_config(args)
     run_app.first_window_callback = setup_x11_window
+    run_app.first_os_window_created_callback = setup_os_window
     run_app.initial_window_size_func = initial_window_size_func
     real_main()
 
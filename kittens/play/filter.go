@@ -0,0 +1,109 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package play
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var _ = fmt.Print
+
+// Requests to kitty, such as remote control commands or the data requests of
+// the ssh kitten, are sent as DCS escape codes with this prefix. Playing them
+// back would repeat the actions they performed, so they are dropped.
+const kitty_request_prefix = "\x1bP@kitty-"
+
+// Switching between the main and alternate screens in the recording would
+// switch the screens of the terminal the recording is played in, showing the
+// main screen of the user with the output of the recording drawn over it. As
+// playback happens in the alternate screen, they are replaced by clearing the
+// screen, saving and restoring the cursor position as the switches do.
+var screen_switches = map[string]string{
+	"\x1b[?1049h": "\x1b7\x1b[H\x1b[2J", "\x1b[?1049l": "\x1b[H\x1b[2J\x1b8",
+	"\x1b[?1047h": "\x1b[H\x1b[2J", "\x1b[?1047l": "\x1b[H\x1b[2J",
+	"\x1b[?47h": "\x1b[H\x1b[2J", "\x1b[?47l": "\x1b[H\x1b[2J",
+}
+
+type request_filter struct {
+	// a possible partial match for an escape code at the end of the last data
+	pending    []byte
+	in_request bool
+	saw_esc    bool
+}
+
+func is_partial_match(data []byte) bool {
+	if len(data) < len(kitty_request_prefix) && bytes.HasPrefix([]byte(kitty_request_prefix), data) {
+		return true
+	}
+	for seq := range screen_switches {
+		if len(data) < len(seq) && bytes.HasPrefix([]byte(seq), data) {
+			return true
+		}
+	}
+	return false
+}
+
+// Drop the requests to kitty from data and replace the screen switches, data
+// can be any chunk of the stream
+func (self *request_filter) filter(data []byte) []byte {
+	if len(self.pending) > 0 {
+		data = append(self.pending, data...)
+		self.pending = nil
+	}
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		if self.in_request {
+			for i, b := range data {
+				if self.saw_esc && b == '\\' {
+					self.in_request = false
+					data = data[i+1:]
+					break
+				}
+				self.saw_esc = b == 0x1b
+			}
+			if self.in_request {
+				return out
+			}
+			continue
+		}
+		idx := bytes.IndexByte(data, 0x1b)
+		if idx < 0 {
+			out = append(out, data...)
+			break
+		}
+		out = append(out, data[:idx]...)
+		data = data[idx:]
+		if bytes.HasPrefix(data, []byte(kitty_request_prefix)) {
+			data = data[len(kitty_request_prefix):]
+			self.in_request, self.saw_esc = true, false
+			continue
+		}
+		replaced := false
+		for seq, replacement := range screen_switches {
+			if bytes.HasPrefix(data, []byte(seq)) {
+				out = append(out, replacement...)
+				data = data[len(seq):]
+				replaced = true
+				break
+			}
+		}
+		if replaced {
+			continue
+		}
+		if is_partial_match(data) {
+			self.pending = append(self.pending, data...)
+			break
+		}
+		out = append(out, data[0])
+		data = data[1:]
+	}
+	return out
+}
+
+// Return any data held back for being a possible partial match
+func (self *request_filter) flush() []byte {
+	ans := self.pending
+	self.pending = nil
+	return ans
+}
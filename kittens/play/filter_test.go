@@ -0,0 +1,36 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package play
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestRequestFilter(t *testing.T) {
+	f := func(expected string, chunks ...string) {
+		t.Helper()
+		rf := request_filter{}
+		actual := ""
+		for _, c := range chunks {
+			actual += string(rf.filter([]byte(c)))
+		}
+		actual += string(rf.flush())
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Unexpected filtered output for %#v:\n%s", chunks, diff)
+		}
+	}
+	f("ab", "a\x1bP@kitty-cmd{}\x1b\\b")
+	f("a\x1bPxyz\x1b\\b", "a\x1bPxyz\x1b\\b")
+	f("ab\x1b[m", "a\x1bP@ki", "tty-ssh|x=1\x1b", "\\b\x1b[m")
+	f("a\x1bP@kit", "a\x1bP@kit")
+	f("a\x1bP@kix", "a\x1bP@k", "ix")
+	f("a", "a\x1bP@kitty-print|unterminated")
+	f("12", "1\x1bP@kitty-a\x1b\\\x1bP@kitty-b\x1b\\2")
+	f("a\x1b7\x1b[H\x1b[2Jb\x1b[H\x1b[2J\x1b8c", "a\x1b[?1049hb\x1b[?10", "49lc")
+	f("a\x1b[H\x1b[2J\x1b[?1048h\x1b[?10", "a\x1b[?47h\x1b[?1048h\x1b[?10")
+}
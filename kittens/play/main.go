@@ -0,0 +1,240 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package play
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"kitty/tools/asciicast"
+	"kitty/tools/cli"
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+const min_speed, max_speed = 1. / 16, 16.
+const seek_step = 5 * time.Second
+
+// Undo the changes to the modes of the terminal the played events may have
+// made: formatting, the scroll region, mouse and focus tracking, bracketed
+// paste, cursor keys, cursor visibility and shape
+const reset_modes = "\x1b[m\x1b[r\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1004l\x1b[?1006l\x1b[?2004l\x1b[?1l\x1b[?25h\x1b[ q"
+
+// Written before replaying the recording from the start when seeking
+// backwards. Playback happens in the alternate screen, so clearing it and
+// deleting its images does not affect the main screen of the user.
+const reset_terminal = reset_modes + "\x1b[H\x1b[2J\x1b_Ga=d,d=A,q=2\x1b\\"
+
+type player struct {
+	lp       *loop.Loop
+	events   []asciicast.Event
+	speed    float64
+	max_wait time.Duration
+	filter   request_filter
+	paused   bool
+
+	// the index of the next event to play
+	next int
+	// the time in the recording up to which events have been played
+	played_upto time.Duration
+	timer_id    loop.IdType
+	// when the timer to play the next event was started
+	waiting_since time.Time
+}
+
+// Return the output events in the recording, ignoring events of other types
+func read_output_events(rec *asciicast.Reader) ([]asciicast.Event, error) {
+	var ans []asciicast.Event
+	for {
+		ev, err := rec.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return ans, err
+		}
+		if ev.Type == asciicast.Output {
+			ans = append(ans, ev)
+		}
+	}
+}
+
+func (self *player) delay_for(ev *asciicast.Event) time.Duration {
+	d := ev.Time - self.played_upto
+	if self.max_wait > 0 {
+		d = min(d, self.max_wait)
+	}
+	return time.Duration(float64(max(0, d)) / self.speed)
+}
+
+func (self *player) write_event(ev *asciicast.Event) {
+	self.lp.QueueWriteBytesCopy(self.filter.filter([]byte(ev.Data)))
+	self.played_upto = max(self.played_upto, ev.Time)
+}
+
+func (self *player) cancel_timer() {
+	if self.timer_id != 0 {
+		self.lp.RemoveTimer(self.timer_id)
+		self.timer_id = 0
+	}
+}
+
+// Write all events that are due and schedule the next one
+func (self *player) play() (err error) {
+	for self.next < len(self.events) && self.delay_for(&self.events[self.next]) < time.Millisecond {
+		self.write_event(&self.events[self.next])
+		self.next++
+	}
+	if self.next >= len(self.events) {
+		self.lp.QueueWriteBytesCopy(self.filter.flush())
+		self.lp.Quit(0)
+		return nil
+	}
+	self.waiting_since = time.Now()
+	self.timer_id, err = self.lp.AddTimer(self.delay_for(&self.events[self.next]), false, func(loop.IdType) error {
+		self.timer_id = 0
+		// the time to wait has passed, whatever the speed is now
+		self.played_upto = max(self.played_upto, self.events[self.next].Time)
+		return self.play()
+	})
+	return
+}
+
+func (self *player) toggle_pause() error {
+	if self.paused = !self.paused; !self.paused {
+		return self.play()
+	}
+	if self.timer_id != 0 {
+		self.cancel_timer()
+		// so that the remaining wait is used when resuming
+		waited := time.Duration(float64(time.Since(self.waiting_since)) * self.speed)
+		self.played_upto = min(self.played_upto+waited, self.events[self.next].Time)
+	}
+	return nil
+}
+
+// Move the playback position by delta, which can be negative. Seeking
+// backwards replays the recording from the start up to the new position.
+func (self *player) seek(delta time.Duration) error {
+	self.cancel_timer()
+	target := max(0, self.played_upto+delta)
+	if delta < 0 {
+		self.lp.QueueWriteString(reset_terminal)
+		self.filter = request_filter{}
+		self.next, self.played_upto = 0, 0
+	}
+	for self.next < len(self.events) && self.events[self.next].Time <= target {
+		self.write_event(&self.events[self.next])
+		self.next++
+	}
+	if self.next < len(self.events) {
+		self.played_upto = target
+	}
+	if self.paused {
+		return nil
+	}
+	return self.play()
+}
+
+func (self *player) perform(action string) error {
+	switch action {
+	case "quit":
+		self.lp.Quit(0)
+	case "faster":
+		self.speed = min(max_speed, self.speed*2)
+	case "slower":
+		self.speed = max(min_speed, self.speed/2)
+	case "pause":
+		return self.toggle_pause()
+	case "back":
+		return self.seek(-seek_step)
+	case "forward":
+		return self.seek(seek_step)
+	}
+	return nil
+}
+
+var key_actions = map[string]string{
+	"q": "quit", "esc": "quit", "ctrl+c": "quit", "+": "faster", "=": "faster", "-": "slower",
+	"space": "pause", "left": "back", "right": "forward",
+}
+
+// Used in terminals that do not support the kitty keyboard protocol, where
+// keys that generate text are not reported as key events
+var text_actions = map[string]string{"q": "quit", "+": "faster", "=": "faster", "-": "slower", " ": "pause"}
+
+func (self *player) on_key_event(ev *loop.KeyEvent) error {
+	for key, action := range key_actions {
+		if ev.MatchesPressOrRepeat(key) {
+			ev.Handled = true
+			return self.perform(action)
+		}
+	}
+	return nil
+}
+
+func (self *player) on_text(text string, from_key_event, in_bracketed_paste bool) error {
+	if in_bracketed_paste {
+		return nil
+	}
+	// text typed quickly can be delivered together
+	for _, ch := range text {
+		if err := self.perform(text_actions[string(ch)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if len(args) != 1 {
+		return 1, fmt.Errorf("Must specify the recording to play")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return 1, err
+	}
+	defer f.Close()
+	rec, err := asciicast.NewReader(f)
+	if err != nil {
+		return 1, err
+	}
+	events, err := read_output_events(rec)
+	if err != nil {
+		return 1, err
+	}
+	lp, err := loop.New(loop.NoMouseTracking)
+	if err != nil {
+		return 1, err
+	}
+	self := player{
+		lp: lp, events: events, speed: min(max_speed, max(min_speed, opts.Speed)),
+		max_wait: time.Duration(opts.IdleTimeLimit * float64(time.Second)),
+	}
+	lp.OnInitialize = func() (string, error) {
+		return "", self.play()
+	}
+	lp.OnFinalize = func() string {
+		return reset_modes
+	}
+	lp.OnKeyEvent = self.on_key_event
+	lp.OnText = self.on_text
+	if err = lp.Run(); err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,42 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+help_text = '''\
+Play back a terminal session recorded in the asciinema asciicast v2 format, such as the recordings made with
+:code:`kitten record` or :code:`kitten ssh --record`. The recording is played in the current terminal, so it should be at least as large as
+the terminal the recording was made in. Changes to the size of the terminal during the recording are not played back.
+Requests to kitty that are part of the recording, such as remote control commands, are not played back.
+
+While playing, press :kbd:`+` to play faster, :kbd:`-` to play slower, :kbd:`Space` to pause or resume,
+:kbd:`Left` and :kbd:`Right` to seek backwards and forwards by five seconds and :kbd:`q` to quit.
+'''
+usage = 'recording.cast'
+OPTIONS = r'''
+--speed -s
+type=float
+default=1
+The speed at which to play the recording, for example, :code:`2` plays it twice as fast as it was recorded.
+
+
+--idle-time-limit -i
+type=float
+default=0
+Limit pauses in the recording to this many seconds. Zero means no limit.
+'''.format
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten play')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Play back recorded terminal sessions'
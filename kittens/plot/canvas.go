@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package plot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	text_canvas "kitty/tools/tui/canvas"
+)
+
+var _ = fmt.Print
+
+// Each cell is a braille character with 2x4 dots
+type braille_canvas struct {
+	*text_canvas.Canvas
+}
+
+func new_braille_canvas(cols, rows int, color_mode text_canvas.ColorMode) *braille_canvas {
+	return &braille_canvas{text_canvas.New(text_canvas.Braille, color_mode, cols, rows)}
+}
+
+func (self *braille_canvas) MarkerRadius() int { return 0 }
+
+func (self *braille_canvas) Plot(x, y int, c RGB) {
+	self.Set(x, y, color.NRGBA{c.R, c.G, c.B, 0xff})
+}
+
+func (self *braille_canvas) Fill(x0, y0, x1, y1 int, c RGB) {
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			self.Plot(x, y, c)
+		}
+	}
+}
+
+type image_canvas struct {
+	img       *image.NRGBA
+	thickness int
+}
+
+func new_image_canvas(width, height int) *image_canvas {
+	return &image_canvas{img: image.NewNRGBA(image.Rect(0, 0, width, height)), thickness: max(1, height/120)}
+}
+
+func (self *image_canvas) Size() (int, int) {
+	b := self.img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+func (self *image_canvas) MarkerRadius() int { return 2 * self.thickness }
+
+func (self *image_canvas) Fill(x0, y0, x1, y1 int, c RGB) {
+	col := color.NRGBA{c.R, c.G, c.B, 0xff}
+	r := image.Rect(x0, y0, x1+1, y1+1).Intersect(self.img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			self.img.SetNRGBA(x, y, col)
+		}
+	}
+}
+
+func (self *image_canvas) Plot(x, y int, c RGB) {
+	t := self.thickness
+	self.Fill(x-t/2, y-t/2, x-t/2+t-1, y-t/2+t-1, c)
+}
+
+func (self *image_canvas) PNG() ([]byte, error) {
+	b := bytes.Buffer{}
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(&b, self.img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
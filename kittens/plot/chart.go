@@ -0,0 +1,226 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package plot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type point struct{ x, y float64 }
+
+type Series struct {
+	Name   string
+	Points []point
+}
+
+type Chart struct {
+	Kind                   string
+	XName                  string
+	Series                 []Series
+	XMin, XMax, YMin, YMax float64
+}
+
+type RGB struct{ R, G, B uint8 }
+
+var palette = []RGB{
+	{0x4e, 0x79, 0xa7}, {0xf2, 0x8e, 0x2b}, {0xe1, 0x57, 0x59}, {0x76, 0xb7, 0xb2}, {0x59, 0xa1, 0x4f},
+	{0xed, 0xc9, 0x48}, {0xb0, 0x7a, 0xa1}, {0xff, 0x9d, 0xa7}, {0x9c, 0x75, 0x5f}, {0xba, 0xb0, 0xac},
+}
+
+func series_color(i int) RGB { return palette[i%len(palette)] }
+
+func expand_range(lo, hi float64) (float64, float64) {
+	if lo == hi {
+		d := math.Max(1, math.Abs(lo)*0.1)
+		return lo - d, hi + d
+	}
+	return lo, hi
+}
+
+// Build a chart from the last window rows of the table, using all rows if
+// window is zero
+func NewChart(t *Table, kind, x_column, columns string, window int) (*Chart, error) {
+	x_idx := -1
+	var err error
+	if x_column != "" {
+		if x_idx, err = t.find_column(x_column); err != nil {
+			return nil, err
+		}
+	}
+	var cols []int
+	if columns != "" {
+		for _, spec := range strings.Split(columns, ",") {
+			idx, err := t.find_column(spec)
+			if err != nil {
+				return nil, err
+			}
+			cols = append(cols, idx)
+		}
+	} else {
+		for i := range t.Columns {
+			if i != x_idx {
+				cols = append(cols, i)
+			}
+		}
+	}
+	start := 0
+	if window > 0 {
+		start = max(0, len(t.Rows)-window)
+	}
+	ans := Chart{Kind: kind, XName: "row", XMin: math.Inf(1), XMax: math.Inf(-1), YMin: math.Inf(1), YMax: math.Inf(-1)}
+	if x_idx > -1 {
+		ans.XName = t.Columns[x_idx]
+	}
+	for _, c := range cols {
+		s := Series{Name: t.Columns[c]}
+		for r := start; r < len(t.Rows); r++ {
+			x, y := float64(t.Dropped+r+1), t.Value(r, c)
+			if x_idx > -1 {
+				x = t.Value(r, x_idx)
+			}
+			if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+				continue
+			}
+			s.Points = append(s.Points, point{x, y})
+			ans.XMin, ans.XMax = math.Min(ans.XMin, x), math.Max(ans.XMax, x)
+			ans.YMin, ans.YMax = math.Min(ans.YMin, y), math.Max(ans.YMax, y)
+		}
+		// skip columns with no numeric data such as text columns
+		if len(s.Points) > 0 {
+			ans.Series = append(ans.Series, s)
+		}
+	}
+	if len(ans.Series) == 0 {
+		return &ans, nil
+	}
+	if kind == "bar" {
+		ans.YMin, ans.YMax = math.Min(ans.YMin, 0), math.Max(ans.YMax, 0)
+	}
+	ans.XMin, ans.XMax = expand_range(ans.XMin, ans.XMax)
+	ans.YMin, ans.YMax = expand_range(ans.YMin, ans.YMax)
+	return &ans, nil
+}
+
+func (self *Chart) IsEmpty() bool { return len(self.Series) == 0 }
+
+// A surface that charts are drawn on, in units of dots or pixels
+type canvas interface {
+	Size() (width, height int)
+	// Draw a point of the default line thickness
+	Plot(x, y int, c RGB)
+	// Fill a rectangle, the end points are inclusive
+	Fill(x0, y0, x1, y1 int, c RGB)
+	MarkerRadius() int
+}
+
+func (self *Chart) to_canvas(c canvas, p point, bar_slots int) (int, int) {
+	w, h := c.Size()
+	x_scale := float64(w - 1)
+	x_offset := 0.0
+	if bar_slots > 0 {
+		// leave space for half a bar on either side
+		slot := float64(w) / float64(bar_slots)
+		x_scale = float64(w) - slot
+		x_offset = slot / 2
+	}
+	x := x_offset + (p.x-self.XMin)/(self.XMax-self.XMin)*x_scale
+	y := float64(h-1) - (p.y-self.YMin)/(self.YMax-self.YMin)*float64(h-1)
+	return int(math.Round(x)), int(math.Round(y))
+}
+
+func draw_line(c canvas, x0, y0, x1, y1 int, color RGB) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	e := dx + dy
+	for {
+		c.Plot(x0, y0, color)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func draw_marker(c canvas, x, y int, color RGB) {
+	r := c.MarkerRadius()
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			if dx*dx+dy*dy <= r*r {
+				c.Plot(x+dx, y+dy, color)
+			}
+		}
+	}
+}
+
+func (self *Chart) Draw(c canvas) {
+	switch self.Kind {
+	case "bar":
+		self.draw_bars(c)
+	default:
+		for i, s := range self.Series {
+			color := series_color(i)
+			px, py := 0, 0
+			for j, p := range s.Points {
+				x, y := self.to_canvas(c, p, 0)
+				if self.Kind == "scatter" {
+					draw_marker(c, x, y, color)
+				} else if j > 0 {
+					draw_line(c, px, py, x, y, color)
+				} else {
+					c.Plot(x, y, color)
+				}
+				px, py = x, y
+			}
+		}
+	}
+}
+
+func (self *Chart) draw_bars(c canvas) {
+	w, h := c.Size()
+	slots := 0
+	for _, s := range self.Series {
+		slots = max(slots, len(s.Points))
+	}
+	slot_width := float64(w) / float64(slots)
+	group_width := max(1, int(slot_width*0.8))
+	bar_width := max(1, group_width/len(self.Series))
+	_, zero := self.to_canvas(c, point{self.XMin, 0}, slots)
+	zero = max(0, min(zero, h-1))
+	for i, s := range self.Series {
+		color := series_color(i)
+		for _, p := range s.Points {
+			x, y := self.to_canvas(c, p, slots)
+			left := x - group_width/2 + i*bar_width
+			c.Fill(left, min(y, zero), left+bar_width-1, max(y, zero), color)
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
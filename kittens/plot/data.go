@@ -0,0 +1,280 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package plot
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// A table of numeric data, non-numeric and missing values are NaN
+type Table struct {
+	Columns []string
+	Rows    [][]float64
+	// The maximum number of rows to retain, the oldest rows are dropped
+	// once it is exceeded. Zero means no limit.
+	MaxRows int
+	// The number of rows dropped so far
+	Dropped int
+
+	column_map map[string]int
+}
+
+func NewTable() *Table {
+	return &Table{column_map: make(map[string]int)}
+}
+
+func (self *Table) column_index(name string) int {
+	if idx, found := self.column_map[name]; found {
+		return idx
+	}
+	self.Columns = append(self.Columns, name)
+	self.column_map[name] = len(self.Columns) - 1
+	return len(self.Columns) - 1
+}
+
+// Add a row of values. If names is nil the values are positional, otherwise
+// names contains the column name for each value.
+func (self *Table) Add(names []string, values []float64) {
+	idx := make([]int, len(values))
+	for i := range values {
+		if names == nil || i >= len(names) {
+			idx[i] = self.column_index(strconv.Itoa(i + 1))
+		} else {
+			idx[i] = self.column_index(names[i])
+		}
+	}
+	row := make([]float64, len(self.Columns))
+	for i := range row {
+		row[i] = math.NaN()
+	}
+	for i, v := range values {
+		row[idx[i]] = v
+	}
+	self.Rows = append(self.Rows, row)
+	if self.MaxRows > 0 && len(self.Rows) > self.MaxRows {
+		// append() reallocates once the capacity in front of the slice is
+		// used up, so the memory for dropped rows is eventually released
+		n := len(self.Rows) - self.MaxRows
+		clear(self.Rows[:n])
+		self.Rows = self.Rows[n:]
+		self.Dropped += n
+	}
+}
+
+func (self *Table) Value(row, col int) float64 {
+	if r := self.Rows[row]; col < len(r) {
+		return r[col]
+	}
+	return math.NaN()
+}
+
+// Find a column by name or by number starting from 1
+func (self *Table) find_column(spec string) (int, error) {
+	spec = strings.TrimSpace(spec)
+	if idx, found := self.column_map[spec]; found {
+		return idx, nil
+	}
+	if n, err := strconv.Atoi(spec); err == nil && n > 0 && n <= len(self.Columns) {
+		return n - 1, nil
+	}
+	return -1, fmt.Errorf("No column named %#v in the data", spec)
+}
+
+func parse_number(x string) (float64, bool) {
+	x = strings.TrimSpace(x)
+	x = strings.TrimSuffix(x, "%")
+	if x == "" {
+		return math.NaN(), false
+	}
+	v, err := strconv.ParseFloat(x, 64)
+	if err != nil {
+		return math.NaN(), false
+	}
+	return v, true
+}
+
+func detect_format(first_line string) string {
+	s := strings.TrimSpace(first_line)
+	switch {
+	case strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{"):
+		return "json"
+	case strings.Contains(s, "\t"):
+		return "tsv"
+	case strings.Contains(s, ","):
+		return "csv"
+	}
+	return "whitespace"
+}
+
+func split_fields(line, format string) []string {
+	switch format {
+	case "tsv":
+		return strings.Split(line, "\t")
+	case "csv":
+		return split_csv(line)
+	}
+	return strings.Fields(line)
+}
+
+// Split a single line of CSV, handling quoted fields
+func split_csv(line string) (ans []string) {
+	field := strings.Builder{}
+	in_quotes := false
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case in_quotes && ch == '"':
+			if i+1 < len(line) && line[i+1] == '"' {
+				field.WriteByte('"')
+				i++
+			} else {
+				in_quotes = false
+			}
+		case in_quotes:
+			field.WriteByte(ch)
+		case ch == '"':
+			in_quotes = true
+		case ch == ',':
+			ans = append(ans, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(ch)
+		}
+	}
+	return append(ans, field.String())
+}
+
+type emitter = func(names []string, values []float64)
+
+func read_delimited(r *bufio.Reader, format string, emit emitter) error {
+	var header []string
+	first := true
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) != "" {
+			fields := split_fields(line, format)
+			values := make([]float64, len(fields))
+			is_header := false
+			for i, f := range fields {
+				v, ok := parse_number(f)
+				values[i] = v
+				if !ok && strings.TrimSpace(f) != "" {
+					is_header = true
+				}
+			}
+			if first && is_header {
+				header = make([]string, len(fields))
+				for i, f := range fields {
+					header[i] = strings.TrimSpace(f)
+				}
+			} else {
+				emit(header, values)
+			}
+			first = false
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func json_number(x any) float64 {
+	switch v := x.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		ans, _ := parse_number(v)
+		return ans
+	}
+	return math.NaN()
+}
+
+func emit_json_row(x any, emit emitter) {
+	switch v := x.(type) {
+	case map[string]any:
+		names := make([]string, 0, len(v))
+		for k := range v {
+			names = append(names, k)
+		}
+		slices.Sort(names)
+		values := make([]float64, len(names))
+		for i, k := range names {
+			values[i] = json_number(v[k])
+		}
+		emit(names, values)
+	case []any:
+		values := make([]float64, len(v))
+		for i, item := range v {
+			values[i] = json_number(item)
+		}
+		emit(nil, values)
+	default:
+		emit(nil, []float64{json_number(v)})
+	}
+}
+
+// Read JSON data. Top level arrays are lists of rows, other top level values
+// are a single row each, so that JSON Lines input works.
+func read_json(r io.Reader, emit emitter) error {
+	dec := json.NewDecoder(r)
+	for {
+		var x any
+		if err := dec.Decode(&x); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("Invalid JSON input with error: %w", err)
+		}
+		if rows, ok := x.([]any); ok {
+			for _, row := range rows {
+				emit_json_row(row, emit)
+			}
+		} else {
+			emit_json_row(x, emit)
+		}
+	}
+}
+
+// Read data in the specified format from r calling emit for every row of
+// data. Blocks until r is exhausted.
+func ReadData(r io.Reader, format string, emit emitter) error {
+	br := bufio.NewReader(r)
+	if format == "auto" {
+		var first string
+		for strings.TrimSpace(first) == "" {
+			line, err := br.ReadString('\n')
+			first += line
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					return err
+				}
+				break
+			}
+		}
+		format = detect_format(first)
+		br = bufio.NewReader(io.MultiReader(strings.NewReader(first), br))
+	}
+	if format == "json" {
+		return read_json(br, emit)
+	}
+	return read_delimited(br, format, emit)
+}
@@ -0,0 +1,115 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package plot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	text_canvas "kitty/tools/tui/canvas"
+	"kitty/tools/wcswidth"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestPlotReadData(t *testing.T) {
+	read := func(text, format string) *Table {
+		ans := NewTable()
+		if err := ReadData(strings.NewReader(text), format, ans.Add); err != nil {
+			t.Fatalf("Failed to read %#v with error: %s", text, err)
+		}
+		return ans
+	}
+	nan_to_minus_one := func(rows [][]float64) [][]float64 {
+		for _, r := range rows {
+			for i, v := range r {
+				if math.IsNaN(v) {
+					r[i] = -1
+				}
+			}
+		}
+		return rows
+	}
+	tc := func(text, format string, columns []string, rows ...[]float64) {
+		t.Helper()
+		tbl := read(text, format)
+		if diff := cmp.Diff(columns, tbl.Columns); diff != "" {
+			t.Fatalf("Incorrect columns for %#v:\n%s", text, diff)
+		}
+		if diff := cmp.Diff(rows, nan_to_minus_one(tbl.Rows)); diff != "" {
+			t.Fatalf("Incorrect rows for %#v:\n%s", text, diff)
+		}
+	}
+	tc("a,b\n1,2\n3,x\n", "auto", []string{"a", "b"}, []float64{1, 2}, []float64{3, -1})
+	tc("1\t2\n\n3\t4\n", "auto", []string{"1", "2"}, []float64{1, 2}, []float64{3, 4})
+	tc("  r  b  swpd\n  1  0  5%\n", "auto", []string{"r", "b", "swpd"}, []float64{1, 0, 5})
+	tc(`"x,y",z`+"\n1,2\n", "csv", []string{"x,y", "z"}, []float64{1, 2})
+	tc("[1, 2.5, null]", "auto", []string{"1"}, []float64{1}, []float64{2.5}, []float64{-1})
+	tc("[[1, 2], [3, 4]]", "json", []string{"1", "2"}, []float64{1, 2}, []float64{3, 4})
+	tc(`{"b": 1, "a": "2"}`+"\n"+`{"c": 3}`, "auto", []string{"a", "b", "c"}, []float64{2, 1}, []float64{-1, -1, 3})
+
+	if err := ReadData(strings.NewReader("{oops"), "json", NewTable().Add); err == nil {
+		t.Fatalf("No error for invalid JSON")
+	}
+
+	tbl := NewTable()
+	tbl.MaxRows = 2
+	for i := range 5 {
+		tbl.Add(nil, []float64{float64(i)})
+	}
+	if diff := cmp.Diff([][]float64{{3}, {4}}, tbl.Rows); diff != "" || tbl.Dropped != 3 {
+		t.Fatalf("Old rows not dropped (%d dropped):\n%s", tbl.Dropped, diff)
+	}
+	// row numbers continue from the dropped rows
+	c, err := NewChart(tbl, "line", "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]float64{4, 5}, []float64{c.XMin, c.XMax}); diff != "" {
+		t.Fatalf("Incorrect row numbers:\n%s", diff)
+	}
+}
+
+func TestPlotChart(t *testing.T) {
+	tbl := NewTable()
+	if err := ReadData(strings.NewReader("t,name,v\n10,a,1\n20,b,3\n30,c,2\n"), "csv", tbl.Add); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewChart(tbl, "line", "t", "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the text column is dropped and only the last two rows are used
+	if len(c.Series) != 1 || c.Series[0].Name != "v" {
+		t.Fatalf("Incorrect series: %#v", c.Series)
+	}
+	if diff := cmp.Diff([]float64{20, 30, 2, 3}, []float64{c.XMin, c.XMax, c.YMin, c.YMax}); diff != "" {
+		t.Fatalf("Incorrect ranges:\n%s", diff)
+	}
+	if c, err = NewChart(tbl, "bar", "1", "3", 0); err != nil {
+		t.Fatal(err)
+	}
+	if c.YMin != 0 {
+		t.Fatalf("Bar charts must include zero, got minimum: %v", c.YMin)
+	}
+	if _, err = NewChart(tbl, "line", "missing", "", 0); err == nil {
+		t.Fatalf("No error for missing column")
+	}
+
+	canvas := new_braille_canvas(2, 1, text_canvas.TrueColor)
+	if c, err = NewChart(tbl, "line", "", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	c.Draw(canvas)
+	lines := canvas.Lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "\x1b[38;2;78;121;167m") || strings.TrimSpace(wcswidth.StripEscapeCodes(lines[0])) == "" {
+		t.Fatalf("Incorrect braille rendering: %#v", lines)
+	}
+	if diff := cmp.Diff([]string{"1", "0.5", "1.23e+06", "-0.25"}, []string{format_number(1), format_number(0.5), format_number(1234567), format_number(-0.25)}); diff != "" {
+		t.Fatalf("Incorrect number formatting:\n%s", diff)
+	}
+}
@@ -0,0 +1,288 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package plot
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"kitty/kittens/icat"
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	text_canvas "kitty/tools/tui/canvas"
+	"kitty/tools/tui/graphics"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const image_id = 7315
+
+type row struct {
+	names  []string
+	values []float64
+}
+
+func place_image(r *Rendering, gc *graphics.GraphicsCommand) *graphics.GraphicsCommand {
+	gc.SetAction(graphics.GRT_action_transmit_and_display).SetFormat(graphics.GRT_format_png).SetQuiet(graphics.GRT_quiet_silent)
+	gc.SetColumns(uint64(r.ImageCols)).SetRows(uint64(r.ImageRows)).SetCursorMovement(graphics.GRT_cursor_static)
+	return gc
+}
+
+func write_rendering(r *Rendering) (err error) {
+	for i, line := range r.Lines {
+		if _, err = os.Stdout.WriteString(line); err != nil {
+			return
+		}
+		if r.Image != nil && i == r.ImageLine {
+			if err = place_image(r, &graphics.GraphicsCommand{}).WriteWithPayloadTo(os.Stdout, r.Image); err != nil {
+				return
+			}
+		}
+		if _, err = os.Stdout.WriteString("\n"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func should_use_graphics(opts *Options) bool {
+	if opts.Mode == "auto" && tty.IsTerminal(os.Stdout.Fd()) {
+		_, _, direct, err := icat.DetectSupport(2 * time.Second)
+		return err == nil && direct
+	}
+	return opts.Mode == "graphics"
+}
+
+func new_table(opts *Options) *Table {
+	t := NewTable()
+	t.MaxRows = utils.IfElse(opts.Window > 0, opts.Window, opts.MaxRows)
+	return t
+}
+
+func plot_once(opts *Options, use_graphics bool) (rc int, err error) {
+	t := new_table(opts)
+	if err = ReadData(os.Stdin, opts.Format, t.Add); err != nil {
+		return 1, err
+	}
+	chart, err := NewChart(t, opts.Type, opts.XColumn, opts.Columns, opts.Window)
+	if err != nil {
+		return 1, err
+	}
+	if chart.IsEmpty() {
+		return 1, fmt.Errorf("No numeric data to plot was found in the input")
+	}
+	l := Layout{Width: 80, Height: 12, CellWidth: 10, CellHeight: 20, UseGraphics: use_graphics, Title: opts.Title, Colors: text_canvas.ColorModeFromEnvironment()}
+	if sz, err := tty.GetSize(int(os.Stdout.Fd())); err == nil && sz.Col > 0 {
+		l.Width, l.Height = int(sz.Col), max(5, int(sz.Row)/3)
+		if sz.Xpixel > 0 && sz.Ypixel > 0 {
+			l.CellWidth, l.CellHeight = int(sz.Xpixel/sz.Col), int(sz.Ypixel/sz.Row)
+		}
+	}
+	if opts.Width > 0 {
+		l.Width = opts.Width
+	}
+	if opts.Height > 0 {
+		l.Height = opts.Height
+	}
+	r, err := chart.Render(l)
+	if err != nil {
+		return 1, err
+	}
+	if err = write_rendering(r); err != nil {
+		return 1, err
+	}
+	return
+}
+
+type follower struct {
+	lp           *loop.Loop
+	opts         *Options
+	use_graphics bool
+	table        *Table
+	rows         chan row
+	finished     chan error
+
+	draw_timer            loop.IdType
+	input_done, has_image bool
+	status                string
+}
+
+func (self *follower) delete_image() {
+	gc := graphics.GraphicsCommand{}
+	gc.SetAction(graphics.GRT_action_delete).SetDelete(graphics.GRT_free_by_id).SetImageId(image_id).SetQuiet(graphics.GRT_quiet_silent)
+	_ = gc.WriteWithPayloadToLoop(self.lp, nil)
+	self.has_image = false
+}
+
+func (self *follower) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	if self.has_image {
+		self.delete_image()
+	}
+	self.lp.ClearScreen()
+	sz, err := self.lp.ScreenSize()
+	if err != nil {
+		return
+	}
+	status := self.status
+	if status == "" && self.input_done {
+		status = "End of input, press q to quit"
+	}
+	chart, err := NewChart(self.table, self.opts.Type, self.opts.XColumn, self.opts.Columns, self.opts.Window)
+	if err != nil {
+		status = err.Error()
+	} else if chart.IsEmpty() {
+		if status == "" {
+			status = "Waiting for data…"
+		}
+	} else {
+		l := Layout{
+			Width: int(sz.WidthCells), Height: max(1, int(sz.HeightCells)-ExtraLines(self.opts.Title)-1),
+			CellWidth: int(sz.CellWidth), CellHeight: int(sz.CellHeight), UseGraphics: self.use_graphics, Title: self.opts.Title,
+			Colors: text_canvas.ColorModeFromEnvironment(),
+		}
+		if self.opts.Width > 0 {
+			l.Width = min(l.Width, self.opts.Width)
+		}
+		if self.opts.Height > 0 {
+			l.Height = min(l.Height, self.opts.Height)
+		}
+		r, err := chart.Render(l)
+		if err != nil {
+			status = err.Error()
+		} else {
+			for i, line := range r.Lines {
+				self.lp.MoveCursorTo(1, i+1)
+				self.lp.QueueWriteString(line)
+			}
+			if r.Image != nil {
+				self.lp.MoveCursorTo(r.ImageCol+1, r.ImageLine+1)
+				gc := graphics.GraphicsCommand{}
+				_ = place_image(r, &gc).SetImageId(image_id).WriteWithPayloadToLoop(self.lp, r.Image)
+				self.has_image = true
+			}
+		}
+	}
+	if status != "" {
+		self.lp.MoveCursorTo(1, int(sz.HeightCells))
+		self.lp.QueueWriteString(self.lp.SprintStyled("dim", status))
+	}
+}
+
+// Coalesce redraws when data is arriving quickly
+func (self *follower) schedule_draw() {
+	if self.draw_timer != 0 {
+		return
+	}
+	self.draw_timer, _ = self.lp.AddTimer(100*time.Millisecond, false, func(loop.IdType) error {
+		self.draw_timer = 0
+		self.draw_screen()
+		return nil
+	})
+}
+
+func (self *follower) on_wakeup() error {
+	changed := false
+	for done := false; !done; {
+		select {
+		case r := <-self.rows:
+			self.table.Add(r.names, r.values)
+			changed = true
+		case err := <-self.finished:
+			self.input_done = true
+			if err != nil {
+				self.status = err.Error()
+			}
+			changed = true
+		default:
+			done = true
+		}
+	}
+	if changed {
+		self.schedule_draw()
+	}
+	return nil
+}
+
+func (self *follower) read_input() {
+	err := ReadData(os.Stdin, self.opts.Format, func(names []string, values []float64) {
+		self.rows <- row{names, values}
+		self.lp.WakeupMainThread()
+	})
+	self.finished <- err
+	self.lp.WakeupMainThread()
+}
+
+func follow(opts *Options, use_graphics bool) (rc int, err error) {
+	lp, err := loop.New()
+	if err != nil {
+		return 1, err
+	}
+	f := follower{lp: lp, opts: opts, use_graphics: use_graphics, table: new_table(opts), rows: make(chan row, 1024), finished: make(chan error, 1)}
+	lp.OnInitialize = func() (string, error) {
+		lp.SetCursorVisible(false)
+		lp.AllowLineWrapping(false)
+		lp.SetWindowTitle("plot")
+		go f.read_input()
+		f.draw_screen()
+		return "", nil
+	}
+	lp.OnFinalize = func() string {
+		if f.has_image {
+			f.delete_image()
+		}
+		lp.SetCursorVisible(true)
+		return ""
+	}
+	lp.OnWakeup = f.on_wakeup
+	lp.OnKeyEvent = func(ev *loop.KeyEvent) error {
+		if ev.MatchesPressOrRepeat("esc") || ev.MatchesPressOrRepeat("q") {
+			ev.Handled = true
+			lp.Quit(0)
+		}
+		return nil
+	}
+	lp.OnResize = func(_, _ loop.ScreenSize) error {
+		f.draw_screen()
+		return nil
+	}
+	err = lp.Run()
+	if err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	return lp.ExitCode(), nil
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if len(args) > 0 {
+		return 1, fmt.Errorf("The data to plot must be piped to STDIN, no arguments are allowed")
+	}
+	if tty.IsTerminal(os.Stdin.Fd()) {
+		return 1, fmt.Errorf("STDIN is a terminal, the data to plot must be piped to STDIN. See --help")
+	}
+	if opts.Window < 0 {
+		return 1, fmt.Errorf("The window must not be negative")
+	}
+	if opts.MaxRows < 0 {
+		return 1, fmt.Errorf("The maximum number of rows must not be negative")
+	}
+	g := should_use_graphics(opts)
+	if opts.Follow {
+		return follow(opts, g)
+	}
+	return plot_once(opts, g)
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,97 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+help_text = '''\
+Plot numeric data read from STDIN as a line, bar or scatter chart. The data can be CSV, TSV, whitespace
+separated columns, JSON or JSON Lines. Every numeric column becomes a series in the chart. If the first row
+contains non-numeric values, it is used as the names of the columns. The chart is displayed as an image
+using the kitty graphics protocol when the terminal supports it, otherwise it is drawn using braille
+characters. With :option:`--follow` the chart is redrawn as new data arrives, which is useful for monitoring,
+for example::
+
+    vmstat 1 | kitten plot --follow --format=whitespace --window=60
+'''
+usage = ''
+OPTIONS = r'''
+--type -t
+default=line
+choices=line,bar,scatter
+The type of chart to draw.
+
+
+--format
+default=auto
+choices=auto,csv,tsv,whitespace,json
+The format of the input data. When set to :code:`auto` the format is detected from the first line of input.
+
+
+--x-column -x
+The column to use for the X axis values, as either a column name or a number starting from 1.
+By default, the row number is used for the X axis.
+
+
+--columns -c
+Comma separated list of columns to plot, as names or numbers starting from 1. By default,
+all numeric columns are plotted.
+
+
+--width
+type=int
+default=0
+The width of the chart in cells. Defaults to the width of the terminal.
+
+
+--height
+type=int
+default=0
+The height of the chart in cells. Defaults to a third of the height of the terminal.
+
+
+--title
+A title to display above the chart.
+
+
+--mode
+default=auto
+choices=auto,graphics,braille
+How to draw the chart. The default is to use the graphics protocol if the terminal supports it,
+falling back to braille characters otherwise.
+
+
+--follow -f
+type=bool-set
+Keep reading data from STDIN, redrawing the chart full screen as new data arrives.
+Press :kbd:`q` or :kbd:`Esc` to quit.
+
+
+--window -w
+type=int
+default=0
+Only plot the last specified number of rows of data. Zero means plot all rows.
+
+
+--max-rows
+type=int
+default=100000
+The maximum number of rows of data to keep in memory, older rows are discarded
+once this many have been read, so that :option:`--follow` can run indefinitely.
+Zero means no limit. When :option:`--window` is specified, only that many rows
+are kept.
+'''.format
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten plot')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Draw charts of numeric data in the terminal'
@@ -0,0 +1,153 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package plot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	text_canvas "kitty/tools/tui/canvas"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type Layout struct {
+	// The width of the chart including the axis labels and the height of the
+	// plot area, in cells
+	Width, Height int
+	// The size of a cell in pixels, only used for graphics
+	CellWidth, CellHeight int
+	UseGraphics           bool
+	Title                 string
+	// The colors used when drawing with braille characters
+	Colors text_canvas.ColorMode
+}
+
+type Rendering struct {
+	Lines []string
+	// The PNG image to display when using graphics and its position and
+	// size in cells
+	Image                                     []byte
+	ImageLine, ImageCol, ImageCols, ImageRows int
+}
+
+func format_number(v float64) string {
+	a := math.Abs(v)
+	if a != 0 && (a >= 1e6 || a < 1e-2) {
+		return strconv.FormatFloat(v, 'g', 3, 64)
+	}
+	ans := strconv.FormatFloat(v, 'f', 2, 64)
+	ans = strings.TrimRight(ans, "0")
+	return strings.TrimSuffix(ans, ".")
+}
+
+func (self *Chart) y_labels(height int) []string {
+	ans := make([]string, height)
+	label := func(row int) {
+		frac := 1.0
+		if height > 1 {
+			frac = float64(row) / float64(height-1)
+		}
+		ans[row] = format_number(self.YMax - (self.YMax-self.YMin)*frac)
+	}
+	label(0)
+	if height > 1 {
+		label(height - 1)
+	}
+	if height >= 5 {
+		label(height / 2)
+	}
+	return ans
+}
+
+func (self *Chart) x_labels(width int) string {
+	left, right := format_number(self.XMin), format_number(self.XMax)
+	line := []rune(strings.Repeat(" ", width))
+	put := func(pos int, text string) bool {
+		r := []rune(text)
+		if pos < 0 || pos+len(r) > width {
+			return false
+		}
+		for i := max(0, pos-1); i < min(width, pos+len(r)+1); i++ {
+			if line[i] != ' ' {
+				return false
+			}
+		}
+		copy(line[pos:], r)
+		return true
+	}
+	put(0, left)
+	put(width-len([]rune(right)), right)
+	mid := format_number((self.XMin + self.XMax) / 2)
+	put(width/2-len([]rune(mid))/2, mid)
+	return strings.TrimRight(string(line), " ")
+}
+
+func sgr_color(c RGB) string { return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", c.R, c.G, c.B) }
+
+func (self *Chart) legend() string {
+	parts := make([]string, len(self.Series))
+	for i, s := range self.Series {
+		parts[i] = sgr_color(series_color(i)) + "■\x1b[39m " + s.Name
+	}
+	return strings.Join(parts, "  ")
+}
+
+// The number of lines used by the title, axis and legend
+func ExtraLines(title string) int {
+	if title != "" {
+		return 4
+	}
+	return 3
+}
+
+func (self *Chart) Render(l Layout) (*Rendering, error) {
+	ans := Rendering{}
+	labels := self.y_labels(l.Height)
+	margin := 0
+	for _, x := range labels {
+		margin = max(margin, len(x))
+	}
+	width := max(1, l.Width-margin-1)
+	if l.Title != "" {
+		t := wcswidth.TruncateToVisualLength(l.Title, l.Width)
+		pad := max(0, (l.Width-wcswidth.Stringwidth(t))/2)
+		ans.Lines = append(ans.Lines, strings.Repeat(" ", pad)+"\x1b[1m"+t+"\x1b[22m")
+	}
+	var rows []string
+	if l.UseGraphics {
+		c := new_image_canvas(width*max(1, l.CellWidth), l.Height*max(1, l.CellHeight))
+		self.Draw(c)
+		data, err := c.PNG()
+		if err != nil {
+			return nil, err
+		}
+		ans.Image, ans.ImageLine, ans.ImageCol = data, len(ans.Lines), margin+1
+		ans.ImageCols, ans.ImageRows = width, l.Height
+	} else {
+		c := new_braille_canvas(width, l.Height, l.Colors)
+		self.Draw(c)
+		rows = c.Lines()
+	}
+	for i, label := range labels {
+		tick := "│"
+		if label != "" {
+			tick = "┤"
+		}
+		line := strings.Repeat(" ", margin-len(label)) + label + tick
+		if rows != nil {
+			line += rows[i]
+		}
+		ans.Lines = append(ans.Lines, line)
+	}
+	indent := strings.Repeat(" ", margin+1)
+	ans.Lines = append(ans.Lines,
+		strings.Repeat(" ", margin)+"└"+strings.Repeat("─", width),
+		indent+self.x_labels(width),
+		indent+self.legend(),
+	)
+	return &ans, nil
+}
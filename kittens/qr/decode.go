@@ -0,0 +1,39 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package qr
+
+import (
+	"fmt"
+	"image"
+	"io"
+
+	_ "kitty/tools/utils/images"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+var _ = fmt.Print
+
+// Decode a QR code from the specified image
+func Decode(img image.Image) (string, error) {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	result, err := qrcode.NewQRCodeReader().Decode(bmp, hints)
+	if err != nil {
+		return "", fmt.Errorf("No QR code found: %w", err)
+	}
+	return result.GetText(), nil
+}
+
+// Decode a QR code from image data in any of the supported image formats
+func DecodeFrom(r io.Reader) (string, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read image: %w", err)
+	}
+	return Decode(img)
+}
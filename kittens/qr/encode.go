@@ -0,0 +1,120 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"unicode/utf8"
+
+	"kitty/tools/tui/canvas"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+)
+
+var _ = fmt.Print
+
+// The number of modules of blank space around the code required by the spec
+const QuietZone = 4
+
+type Matrix struct {
+	Size    int
+	modules []bool
+}
+
+func (self *Matrix) IsDark(x, y int) bool {
+	if x < 0 || y < 0 || x >= self.Size || y >= self.Size {
+		return false
+	}
+	return self.modules[y*self.Size+x]
+}
+
+func error_correction_level(level string) (decoder.ErrorCorrectionLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return decoder.ErrorCorrectionLevel_L, nil
+	case "M", "":
+		return decoder.ErrorCorrectionLevel_M, nil
+	case "Q":
+		return decoder.ErrorCorrectionLevel_Q, nil
+	case "H":
+		return decoder.ErrorCorrectionLevel_H, nil
+	}
+	return 0, fmt.Errorf("Unknown error correction level: %s", level)
+}
+
+func is_ascii(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode the specified text as a QR code with the specified error correction
+// level, one of L, M, Q or H
+func Encode(text, level string) (*Matrix, error) {
+	ec, err := error_correction_level(level)
+	if err != nil {
+		return nil, err
+	}
+	hints := map[gozxing.EncodeHintType]interface{}{}
+	if !is_ascii(text) {
+		hints[gozxing.EncodeHintType_CHARACTER_SET] = "UTF-8"
+	}
+	code, werr := encoder.Encoder_encode(text, ec, hints)
+	if werr != nil {
+		return nil, fmt.Errorf("Failed to create QR code: %w", werr)
+	}
+	bm := code.GetMatrix()
+	ans := Matrix{Size: bm.GetWidth(), modules: make([]bool, bm.GetWidth()*bm.GetHeight())}
+	for y := 0; y < bm.GetHeight(); y++ {
+		for x := 0; x < bm.GetWidth(); x++ {
+			ans.modules[y*ans.Size+x] = bm.Get(x, y) == 1
+		}
+	}
+	return &ans, nil
+}
+
+// Render the QR code as an image with the specified size in pixels for each
+// module, including the quiet zone
+func (self *Matrix) Image(module_size int) image.Image {
+	module_size = max(1, module_size)
+	sz := (self.Size + 2*QuietZone) * module_size
+	img := image.NewGray(image.Rect(0, 0, sz, sz))
+	for y := 0; y < sz; y++ {
+		my := y/module_size - QuietZone
+		for x := 0; x < sz; x++ {
+			c := color.Gray{Y: 0xff}
+			if self.IsDark(x/module_size-QuietZone, my) {
+				c.Y = 0
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	return img
+}
+
+// Render the QR code using Unicode half block characters, two modules per
+// cell vertically. Colors are set explicitly so that the code is readable
+// regardless of the terminal color scheme.
+func (self *Matrix) Unicode() string {
+	sz := self.Size + 2*QuietZone
+	c := canvas.New(canvas.HalfBlocks, canvas.Colors16, sz, (sz+1)/2)
+	dark, light := color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	for y := 0; y < 2*c.Rows; y++ {
+		for x := 0; x < sz; x++ {
+			if self.IsDark(x-QuietZone, y-QuietZone) {
+				c.Set(x, y, dark)
+			} else {
+				c.Set(x, y, light)
+			}
+		}
+	}
+	return strings.Join(c.Lines(), "\n") + "\n"
+}
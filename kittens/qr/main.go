@@ -0,0 +1,136 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"kitty/kittens/icat"
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	"kitty/tools/tui/graphics"
+	"kitty/tools/utils/images"
+)
+
+var _ = fmt.Print
+
+func decode_images(args []string) (rc int, err error) {
+	if len(args) == 0 {
+		if tty.IsTerminal(os.Stdin.Fd()) {
+			return 1, fmt.Errorf("STDIN is a terminal and no image files specified. See --help")
+		}
+		text, err := DecodeFrom(os.Stdin)
+		if err != nil {
+			return 1, err
+		}
+		fmt.Println(text)
+		return 0, nil
+	}
+	for _, path := range args {
+		f, err := os.Open(path)
+		if err != nil {
+			return 1, err
+		}
+		text, err := DecodeFrom(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			rc = 1
+			continue
+		}
+		fmt.Println(text)
+	}
+	return
+}
+
+func as_png(m *Matrix, module_size int) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := images.Encode(&buf, m.Image(module_size), "image/png"); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func write_graphics(m *Matrix, module_size int) (err error) {
+	data, err := as_png(m, module_size)
+	if err != nil {
+		return err
+	}
+	cols := m.Size + 2*QuietZone
+	if sz, err := tty.GetSize(int(os.Stdout.Fd())); err == nil && sz.Col > 0 {
+		cols = min(cols, int(sz.Col))
+	}
+	gc := graphics.GraphicsCommand{}
+	gc.SetAction(graphics.GRT_action_transmit_and_display).SetFormat(graphics.GRT_format_png).SetQuiet(graphics.GRT_quiet_silent)
+	gc.SetColumns(uint64(cols))
+	if err = gc.WriteWithPayloadTo(os.Stdout, data); err == nil {
+		_, err = os.Stdout.WriteString("\n")
+	}
+	return
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if opts.Decode {
+		return decode_images(args)
+	}
+	text := strings.Join(args, " ")
+	if len(args) == 0 {
+		if tty.IsTerminal(os.Stdin.Fd()) {
+			return 1, fmt.Errorf("STDIN is a terminal and no text specified. See --help")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return 1, err
+		}
+		text = strings.TrimRight(string(data), "\r\n")
+	}
+	m, err := Encode(text, opts.ErrorCorrection)
+	if err != nil {
+		return 1, err
+	}
+	switch opts.Output {
+	case "":
+	case "-":
+		data, err := as_png(m, opts.ModuleSize)
+		if err == nil {
+			_, err = os.Stdout.Write(data)
+		}
+		if err != nil {
+			return 1, err
+		}
+		return 0, nil
+	default:
+		data, err := as_png(m, opts.ModuleSize)
+		if err == nil {
+			err = os.WriteFile(opts.Output, data, 0o644)
+		}
+		if err != nil {
+			return 1, err
+		}
+		return 0, nil
+	}
+	use_graphics := opts.Mode == "graphics"
+	if opts.Mode == "auto" && tty.IsTerminal(os.Stdout.Fd()) {
+		_, _, direct, derr := icat.DetectSupport(2 * time.Second)
+		use_graphics = derr == nil && direct
+	}
+	if use_graphics {
+		if err = write_graphics(m, opts.ModuleSize); err != nil {
+			return 1, err
+		}
+		return
+	}
+	if _, err = os.Stdout.WriteString(m.Unicode()); err != nil {
+		return 1, err
+	}
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,62 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+from kitty.cli import CompletionSpec
+
+OPTIONS = r'''
+--decode -d
+type=bool-set
+Decode QR codes from the specified image files instead of creating one. The
+decoded text is printed to STDOUT, one line per image. If no files are
+specified, the image is read from STDIN.
+
+
+--error-correction -e
+default=M
+choices=L,M,Q,H
+The error correction level to use when creating a QR code. Higher levels
+make the code more robust to damage at the cost of a larger code.
+
+
+--mode
+default=auto
+choices=auto,graphics,unicode
+How to display the QR code. :code:`graphics` uses the kitty graphics protocol,
+:code:`unicode` uses Unicode block characters that work in any terminal.
+:code:`auto` uses graphics if the terminal supports it.
+
+
+--output -o
+Write the QR code as a PNG image to the specified file instead of displaying
+it in the terminal. Use :code:`-` to write to STDOUT.
+
+
+--module-size
+type=int
+default=8
+The size in pixels of a single QR code module (dot) when creating images.
+'''.format
+help_text = '''\
+Display the specified text as a QR code in the terminal, for example, to easily
+transfer URLs or 2FA provisioning codes to your phone. If no text is specified,
+it is read from STDIN. Use :option:`--decode` to decode QR codes from images.
+'''
+usage = '[text to encode or images to decode ...]'
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten qr')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Display and decode QR codes'
+    cd['args_completion'] = CompletionSpec.from_string('type:file mime:image/* group:Images')
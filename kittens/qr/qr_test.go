@@ -0,0 +1,42 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package qr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestQRRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"https://sw.kovidgoyal.net/kitty/",
+		"otpauth://totp/kitty:user@example.com?secret=JBSWY3DPEHPK3PXP&issuer=kitty",
+		"héllo wörld ✓",
+		"12345678901234567890",
+	} {
+		for _, level := range []string{"L", "M", "Q", "H"} {
+			m, err := Encode(text, level)
+			if err != nil {
+				t.Fatalf("Failed to encode %#v at level %s: %s", text, level, err)
+			}
+			actual, err := Decode(m.Image(4))
+			if err != nil {
+				t.Fatalf("Failed to decode %#v at level %s: %s", text, level, err)
+			}
+			if actual != text {
+				t.Fatalf("Round trip failed at level %s: %#v != %#v", level, text, actual)
+			}
+		}
+	}
+	if _, err := Encode("x", "Z"); err == nil {
+		t.Fatalf("Invalid error correction level did not fail")
+	}
+	m, _ := Encode("x", "M")
+	lines := strings.Split(strings.TrimRight(m.Unicode(), "\n"), "\n")
+	if expected := (m.Size + 2*QuietZone + 1) / 2; len(lines) != expected {
+		t.Fatalf("Unicode rendering has %d lines instead of %d", len(lines), expected)
+	}
+}
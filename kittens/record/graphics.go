@@ -0,0 +1,242 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package record
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kitty/tools/tui/graphics"
+	"kitty/tools/utils/shm"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+const graphics_command_start = "\x1b_G"
+const string_terminator = "\x1b\\"
+
+// Images sent to the terminal as files or shared memory refer to data that
+// will not exist when the recording is played back, so such graphics
+// commands are recorded with the data embedded in them, as if it was sent
+// directly. The data is read before the command is passed on to the terminal,
+// which deletes temporary files and shared memory once it has read them.
+type graphics_inliner struct {
+	// a possible partial match for the start of a command at the end of
+	// the last data
+	pending    []byte
+	command    []byte
+	in_command bool
+	// used to read shared memory, overridable for testing
+	read_shm func(name string) ([]byte, error)
+}
+
+// Limits on the data embedded into recordings, matching those of kitty
+const (
+	max_filename_size   = 2048
+	max_image_data_size = 400 * 1000 * 1000
+	// commands larger than this are passed through without inlining
+	max_graphics_command_size = 4 * 1024 * 1024
+)
+
+func read_shm(name string) ([]byte, error) {
+	mmap, err := shm.Open(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer mmap.Close()
+	if len(mmap.Slice()) > max_image_data_size {
+		return nil, fmt.Errorf("The shared memory %s is too large", name)
+	}
+	return bytes.Clone(mmap.Slice()), nil
+}
+
+// Whether the file can be read as image data, using the same checks as
+// kitty: it must be a regular file and not in /proc, /sys or /dev
+func is_ok_to_read_image_file(path string, f *os.File) bool {
+	s, err := f.Stat()
+	if err != nil || !s.Mode().IsRegular() {
+		return false
+	}
+	if rpath, err := filepath.EvalSymlinks(path); err == nil {
+		if rpath, err = filepath.Abs(rpath); err == nil {
+			if ps, err := os.Stat(rpath); err != nil || !os.SameFile(s, ps) {
+				return false
+			}
+			parts := strings.Split(rpath, string(os.PathSeparator))[1:]
+			switch parts[0] {
+			case "proc", "sys":
+				return false
+			case "dev":
+				return len(parts) > 2 && parts[1] == "shm"
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func read_file(path string, offset, size uint64) ([]byte, error) {
+	// O_NONBLOCK so that opening a FIFO does not block
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if !is_ok_to_read_image_file(path, f) {
+		return nil, fmt.Errorf("Refusing to read the image file %s", path)
+	}
+	if offset > 0 {
+		if _, err = f.Seek(int64(offset), io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	if size > 0 {
+		if size > max_image_data_size {
+			return nil, fmt.Errorf("The image file %s is too large", path)
+		}
+		ans := make([]byte, size)
+		_, err = io.ReadFull(f, ans)
+		return ans, err
+	}
+	ans, err := io.ReadAll(io.LimitReader(f, max_image_data_size+1))
+	if err == nil && len(ans) > max_image_data_size {
+		err = fmt.Errorf("The image file %s is too large", path)
+	}
+	return ans, err
+}
+
+// Whether data is plausibly image data for the command, so that programs
+// cannot use graphics commands to embed arbitrary files into recordings
+func is_image_data(gc *graphics.GraphicsCommand, data []byte) bool {
+	if gc.Compression() == graphics.GRT_compression_zlib {
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return false
+		}
+		defer r.Close()
+		if data, err = io.ReadAll(io.LimitReader(r, max_image_data_size+1)); err != nil {
+			return false
+		}
+	}
+	switch gc.Format() {
+	case graphics.GRT_format_png:
+		return bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n"))
+	case graphics.GRT_format_rgb:
+		return uint64(len(data)) == 3*gc.DataWidth()*gc.DataHeight()
+	default:
+		return uint64(len(data)) == 4*gc.DataWidth()*gc.DataHeight()
+	}
+}
+
+// Return the command with its data embedded or the command unchanged if it
+// does not refer to external data or the data cannot be read
+func (self *graphics_inliner) inline(raw []byte) []byte {
+	gc := graphics.GraphicsCommandFromAPCPayload(raw[len(graphics_command_start) : len(raw)-len(string_terminator)])
+	t := gc.Transmission()
+	if t == graphics.GRT_transmission_direct {
+		return raw
+	}
+	name, err := base64.StdEncoding.DecodeString(gc.ResponseMessage())
+	if err != nil || len(name) > max_filename_size {
+		return raw
+	}
+	var data []byte
+	switch t {
+	case graphics.GRT_transmission_tempfile:
+		// kitty only deletes temporary files with names containing this
+		if !strings.Contains(string(name), "tty-graphics-protocol") {
+			return raw
+		}
+		fallthrough
+	case graphics.GRT_transmission_file:
+		data, err = read_file(string(name), gc.DataOffset(), gc.DataSize())
+	case graphics.GRT_transmission_sharedmem:
+		rs := self.read_shm
+		if rs == nil {
+			rs = read_shm
+		}
+		if data, err = rs(string(name)); err == nil {
+			start := min(uint64(len(data)), gc.DataOffset())
+			end := uint64(len(data))
+			if gc.DataSize() > 0 {
+				end = min(end, start+gc.DataSize())
+			}
+			data = data[start:end]
+		}
+	}
+	if err != nil || !is_image_data(gc, data) {
+		return raw
+	}
+	gc.SetTransmission(graphics.GRT_transmission_direct).SetDataOffset(0).SetDataSize(0)
+	// the data is already compressed if the command specifies compression
+	gc.DisableCompression = true
+	buf := bytes.Buffer{}
+	if gc.WriteWithPayloadTo(&buf, data) != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// Return data, which can be any chunk of the output stream, with graphics
+// commands that refer to external data replaced by commands that embed it
+func (self *graphics_inliner) process(data []byte) []byte {
+	if len(self.pending) > 0 {
+		data = append(self.pending, data...)
+		self.pending = nil
+	}
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		if self.in_command {
+			// the terminator could have been split between the previous data and this
+			start := max(0, len(self.command)-1)
+			self.command = append(self.command, data...)
+			idx := bytes.Index(self.command[start:], []byte(string_terminator))
+			if idx < 0 {
+				if len(self.command) > max_graphics_command_size {
+					// commands referring to external data are small, so
+					// this is either direct data or garbage
+					out = append(out, self.command...)
+					self.command, self.in_command = self.command[:0], false
+				}
+				return out
+			}
+			end := start + idx + len(string_terminator)
+			out = append(out, self.inline(self.command[:end])...)
+			data = bytes.Clone(self.command[end:])
+			self.command, self.in_command = self.command[:0], false
+			continue
+		}
+		if idx := bytes.Index(data, []byte(graphics_command_start)); idx > -1 {
+			out = append(out, data[:idx]...)
+			data = data[idx:]
+			self.in_command = true
+			continue
+		}
+		keep := 0
+		for n := min(len(data), len(graphics_command_start)-1); n > 0; n-- {
+			if bytes.HasSuffix(data, []byte(graphics_command_start[:n])) {
+				keep = n
+				break
+			}
+		}
+		out = append(out, data[:len(data)-keep]...)
+		self.pending = append(self.pending, data[len(data)-keep:]...)
+		break
+	}
+	return out
+}
+
+// Return any data held back, such as an unterminated command
+func (self *graphics_inliner) flush() []byte {
+	ans := append(self.pending, self.command...)
+	self.pending, self.command, self.in_command = nil, nil, false
+	return ans
+}
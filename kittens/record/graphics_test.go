@@ -0,0 +1,88 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package record
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func TestGraphicsInliner(t *testing.T) {
+	b64 := func(x string) string { return base64.StdEncoding.EncodeToString([]byte(x)) }
+	// payloads are serialized without padding
+	raw64 := func(x string) string { return base64.RawStdEncoding.EncodeToString([]byte(x)) }
+	path := filepath.Join(t.TempDir(), "image.rgba")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	shm_data := map[string]string{"shm-name": "abcdefgh"}
+	f := func(expected string, chunks ...string) {
+		t.Helper()
+		gi := graphics_inliner{read_shm: func(name string) ([]byte, error) {
+			if d, found := shm_data[name]; found {
+				return []byte(d), nil
+			}
+			return nil, os.ErrNotExist
+		}}
+		actual := ""
+		for _, c := range chunks {
+			actual += string(gi.process([]byte(c)))
+		}
+		actual += string(gi.flush())
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Unexpected output for %#v:\n%s", chunks, diff)
+		}
+	}
+	direct := "\x1b_Ga=T,f=100,q=2;" + b64("xyz") + "\x1b\\"
+	f("a"+direct+"b", "a"+direct+"b")
+	f("a\x1b_Ga=T,q=2,f=24,s=1,v=2;"+raw64("012345")+"\x1b\\b", "a\x1b_Ga=T,t=f,s=1,v=2,f=24,S=6,q=2;"+b64(path)+"\x1b\\b")
+	f("\x1b_Ga=T,q=2,s=1,v=1;"+raw64("2345")+"\x1b\\", "\x1b_Ga=T,t=f,s=1,v=1,O=2,S=4,q=2;"+b64(path)+"\x1b\\")
+	f("\x1b_Ga=T,q=2,s=1,v=1;"+raw64("cdef")+"\x1b\\", "\x1b_Ga=T,t=s,s=1,v=1,O=2,S=4,q=2;"+b64("shm-name")+"\x1b\\")
+	// commands split across chunks, including at the start and terminator
+	shm := b64("shm-name")
+	f("x\x1b_Ga=T,s=2,v=1;"+raw64("abcdefgh")+"\x1b\\y", "x\x1b", "_", "Ga=T,t=s,s=2,v=1;"+shm[:5], shm[5:]+"\x1b", "\\y")
+	// data that cannot be read is left as is
+	missing := "\x1b_Ga=T,t=s;" + b64("missing") + "\x1b\\"
+	f(missing, missing)
+	// only image data from regular files is inlined and temporary files
+	// must be named as such
+	for _, cmd := range []string{
+		"\x1b_Ga=T,t=f;" + b64(path) + "\x1b\\",
+		"\x1b_Ga=T,t=f,f=100;" + b64(path) + "\x1b\\",
+		"\x1b_Ga=T,t=f,o=z,s=1,v=1;" + b64(path) + "\x1b\\",
+		"\x1b_Ga=T,t=t,s=1,v=1,S=4;" + b64(path) + "\x1b\\",
+		"\x1b_Ga=T,t=f,s=1,v=1;" + b64(filepath.Dir(path)) + "\x1b\\",
+		"\x1b_Ga=T,t=f,s=1,v=1;" + b64("/dev/zero") + "\x1b\\",
+		"\x1b_Ga=T,t=f,s=1,v=1;" + b64("/proc/self/environ") + "\x1b\\",
+	} {
+		f(cmd, cmd)
+	}
+	fifo := filepath.Join(t.TempDir(), "tty-graphics-protocol-fifo")
+	if err := unix.Mkfifo(fifo, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fc := "\x1b_Ga=T,t=t,s=1,v=1;" + b64(fifo) + "\x1b\\"
+	f(fc, fc)
+	tpath := filepath.Join(filepath.Dir(path), "tty-graphics-protocol-x")
+	if err := os.WriteFile(tpath, []byte("wxyz"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f("\x1b_Ga=T,s=1,v=1;"+raw64("wxyz")+"\x1b\\", "\x1b_Ga=T,t=t,s=1,v=1;"+b64(tpath)+"\x1b\\")
+	// unterminated commands are not buffered without limit
+	gi := graphics_inliner{}
+	huge := "\x1b_Ga=T;" + strings.Repeat("A", max_graphics_command_size)
+	if out := string(gi.process([]byte(huge))) + string(gi.process([]byte("AA\x1b\\z"))); out != huge+"AA\x1b\\z" || len(gi.command) > 0 {
+		t.Fatalf("Huge unterminated command not passed through")
+	}
+	f("a\x1b_Ga=T,t=f;unterminated", "a\x1b_Ga=T,t=f;unterminated")
+	f("a\x1b_", "a\x1b_")
+}
@@ -0,0 +1,172 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package record
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"kitty/tools/asciicast"
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+func open_output(opts *Options) (*os.File, error) {
+	path := opts.Output
+	if path == "" {
+		path = time.Now().Format("recording-2006-01-02-15-04-05.cast")
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !opts.Overwrite {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		err = fmt.Errorf("%s already exists, use --overwrite to replace it", path)
+	}
+	return f, err
+}
+
+func main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if !tty.IsTerminal(os.Stdin.Fd()) {
+		return 1, fmt.Errorf("STDIN must be a terminal to record sessions")
+	}
+	if len(args) == 0 {
+		sh, err := utils.LoginShellForCurrentUser()
+		if err != nil {
+			return 1, err
+		}
+		args = []string{sh}
+	}
+	term, err := tty.OpenControllingTerm()
+	if err != nil {
+		return 1, fmt.Errorf("Failed to open controlling terminal with error: %w", err)
+	}
+	defer term.RestoreAndClose()
+	sz, err := term.GetSize()
+	if err != nil {
+		return 1, err
+	}
+	f, err := open_output(opts)
+	if err != nil {
+		return 1, err
+	}
+	defer f.Close()
+	rec, err := asciicast.NewWriter(f, asciicast.Header{
+		Width: int(sz.Col), Height: int(sz.Row), Title: opts.Title,
+		Command: strings.Join(utils.Map(utils.QuoteStringForSH, args), " "),
+		Env:     map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	if err != nil {
+		return 1, err
+	}
+	c := exec.Command(args[0], args[1:]...)
+	master, err := tty.StartInPty(c, sz)
+	if err != nil {
+		return 1, err
+	}
+	defer master.Close()
+	fmt.Printf("Recording to %s, exit %s to stop recording\n", f.Name(), filepath.Base(args[0]))
+	if err = term.ApplyOperations(tty.TCSANOW, tty.SetRaw); err != nil {
+		return 1, err
+	}
+
+	sigs := make(chan os.Signal, 8)
+	// the signals are forwarded to the child instead
+	defer utils.SuspendExitSignalHandling()()
+	signal.Notify(sigs, unix.SIGWINCH, unix.SIGTERM, unix.SIGHUP, unix.SIGINT)
+	defer signal.Reset()
+	go func() {
+		for s := range sigs {
+			if s == unix.SIGWINCH {
+				if sz, err := term.GetSize(); err == nil {
+					_ = tty.SetSize(int(master.Fd()), sz)
+					_ = rec.Resize(int(sz.Col), int(sz.Row))
+				}
+			} else {
+				_ = c.Process.Signal(s)
+			}
+		}
+	}()
+
+	input_done := make(chan struct{})
+	go func() {
+		buf := make([]byte, utils.DEFAULT_IO_BUFFER_SIZE)
+		for {
+			select {
+			case <-input_done:
+				return
+			default:
+			}
+			n, err := term.ReadWithTimeout(buf, 100*time.Millisecond)
+			if n > 0 {
+				if _, err = master.Write(buf[:n]); err != nil {
+					return
+				}
+				if opts.RecordInput {
+					_ = rec.WriteInput(buf[:n])
+				}
+			} else if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) && !errors.Is(err, unix.EINTR) {
+				return
+			}
+		}
+	}()
+	defer close(input_done)
+
+	output_done := make(chan struct{})
+	inliner := graphics_inliner{}
+	go func() {
+		defer close(output_done)
+		buf := make([]byte, 64*1024)
+		for {
+			// a read error, usually EIO, means all processes using the pty have exited
+			n, err := master.Read(buf)
+			if n > 0 {
+				// external image data must be read before the terminal deletes it
+				to_record := inliner.process(buf[:n])
+				if werr := term.WriteAll(buf[:n]); werr != nil {
+					return
+				}
+				_, _ = rec.Write(to_record)
+			}
+			if err != nil && !errors.Is(err, unix.EINTR) {
+				return
+			}
+		}
+	}()
+
+	werr := c.Wait()
+	select {
+	case <-output_done:
+	case <-time.After(time.Second):
+		// some background process is still using the pty
+	}
+	_, _ = rec.Write(inliner.flush())
+	rerr := rec.Close()
+	_ = term.Restore()
+	if rerr != nil {
+		return 1, fmt.Errorf("Failed to record the session to %s with error: %w", f.Name(), rerr)
+	}
+	fmt.Printf("Recording saved to %s\n", f.Name())
+	var exit_err *exec.ExitError
+	if errors.As(werr, &exit_err) {
+		return exit_err.ExitCode(), nil
+	}
+	return 0, werr
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,45 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+help_text = '''\
+Run a program, by default your shell, recording everything it outputs, with its timing, in the asciinema asciicast v2
+format. The recording can be played back with the :doc:`play kitten </kittens/play>` or any other asciicast player.
+Images displayed using the :doc:`kitty graphics protocol </graphics-protocol>` are recorded faithfully, even when they
+are sent to the terminal as files or shared memory, whose contents are stored in the recording.
+'''
+usage = '[program-to-run ...]'
+OPTIONS = r'''
+--output -o
+The file to save the recording to. Defaults to a file named with the current date and time in the current directory.
+
+
+--title
+A title for the recording, stored in the recording.
+
+
+--record-input
+type=bool-set
+Also record the keys pressed while recording. Note that this will record any passwords you type.
+
+
+--overwrite
+type=bool-set
+Overwrite the output file if it exists.
+'''.format
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten record')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Record terminal sessions'
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"kitty/tools/cli/markup"
+	"kitty/tools/keys"
 	"kitty/tools/tui/loop"
 )
 
@@ -30,7 +31,7 @@ func run_kitty_loop(opts *Options) (err error) {
 		return "", nil
 	}
 
-	lp.OnKeyEvent = func(e *loop.KeyEvent) (err error) {
+	lp.OnKeyEvent = func(e *keys.KeyEvent) (err error) {
 		e.Handled = true
 		if e.MatchesPressOrRepeat("ctrl+c") || e.MatchesPressOrRepeat("ctrl+d") {
 			lp.Quit(0)
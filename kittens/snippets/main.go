@@ -0,0 +1,156 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package snippets
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/tui"
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+type Result struct {
+	Text    string `json:"text"`
+	Execute bool   `json:"execute"`
+}
+
+func target_path(opts *Options) (string, error) {
+	if opts.Project {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(cwd, project_file_name), nil
+	}
+	return GlobalSnippetsPath(), nil
+}
+
+func list_snippets(all []Snippet) {
+	for _, s := range all {
+		fmt.Printf("\x1b[1m%s\x1b[m", s.Name)
+		if s.Description != "" {
+			fmt.Printf(" \x1b[2m%s\x1b[m", s.Description)
+		}
+		fmt.Println()
+		for _, line := range strings.Split(s.Command, "\n") {
+			fmt.Println("   ", line)
+		}
+	}
+}
+
+// Insert the text into the window this kitten is running in using remote control
+func send_text(text string, execute bool) error {
+	wid := os.Getenv("KITTY_WINDOW_ID")
+	if wid == "" {
+		fmt.Println(text)
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if execute {
+		text += "\r"
+	}
+	cmd := exec.Command(exe, "@", "send-text", "--match", "id:"+wid, "--stdin")
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("Failed to send the snippet to the window using remote control with error: %w", err)
+	}
+	return nil
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	switch {
+	case opts.Add:
+		if len(args) < 2 {
+			return 1, fmt.Errorf("Must specify a name and a command when adding a snippet")
+		}
+		path, err := target_path(opts)
+		if err != nil {
+			return 1, err
+		}
+		if err = AddSnippet(path, Snippet{Name: args[0], Command: strings.Join(args[1:], " "), Description: opts.Description}); err != nil {
+			return 1, err
+		}
+		return 0, nil
+	case opts.Remove:
+		if len(args) == 0 {
+			return 1, fmt.Errorf("Must specify the names of the snippets to remove")
+		}
+		path, err := target_path(opts)
+		if err != nil {
+			return 1, err
+		}
+		if err = RemoveSnippets(path, args...); err != nil {
+			return 1, err
+		}
+		return 0, nil
+	}
+	if len(args) > 0 {
+		return 1, fmt.Errorf("Arguments are only allowed with --add or --remove")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return 1, err
+	}
+	all, err := AllSnippets(cwd)
+	if err != nil {
+		return 1, err
+	}
+	if opts.List {
+		list_snippets(all)
+		return
+	}
+	output := tui.KittenOutputSerializer()
+	lp, err := loop.New()
+	if err != nil {
+		return 1, err
+	}
+	h := new_handler(lp, all)
+	lp.OnInitialize = h.initialize
+	lp.OnFinalize = h.finalize
+	lp.OnKeyEvent = h.on_key_event
+	lp.OnText = h.on_text
+	lp.OnResize = func(_, _ loop.ScreenSize) error {
+		h.draw_screen()
+		return nil
+	}
+	err = lp.Run()
+	if err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	if h.chosen == nil || lp.ExitCode() != 0 {
+		return lp.ExitCode(), nil
+	}
+	if !tui.RunningAsUI() {
+		if err = send_text(h.result, opts.Execute); err != nil {
+			return 1, err
+		}
+		return
+	}
+	o, err := output(Result{Text: h.result, Execute: opts.Execute})
+	if err != nil {
+		return 1, err
+	}
+	fmt.Println(o)
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
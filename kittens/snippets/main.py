@@ -0,0 +1,72 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import Any, Dict, List
+
+from kitty.typing import BossType
+
+from ..tui.handler import result_handler
+
+help_text = '''\
+Manage a collection of named command snippets and insert them into the terminal. Snippets are stored in
+:file:`snippets.json` in the kitty config directory. Project specific snippets are read from
+:file:`.kitty-snippets.json` files in the current directory and its parents. Snippets can contain
+placeholders of the form :code:`${1}` or :code:`${1:default value}` that you fill in before the
+snippet is inserted.
+'''
+usage = '[name command ...]'
+OPTIONS = r'''
+--add
+type=bool-set
+Add a snippet. The first argument is the name of the snippet and the remaining arguments
+are joined with spaces to form the command.
+
+
+--description
+When adding a snippet, a description for it, used when searching.
+
+
+--remove
+type=bool-set
+Remove the snippets whose names are specified as arguments.
+
+
+--list
+type=bool-set
+Print the list of all snippets available in the current directory.
+
+
+--project
+type=bool-set
+Add or remove snippets in the :file:`.kitty-snippets.json` file in the current directory
+rather than in the snippets file in the kitty config directory.
+
+
+--execute
+type=bool-set
+Press :kbd:`Enter` after inserting the chosen snippet, to run it immediately.
+'''.format
+
+
+@result_handler(has_ready_notification=True)
+def handle_result(args: List[str], data: Dict[str, Any], target_window_id: int, boss: BossType) -> None:
+    w = boss.window_id_map.get(target_window_id)
+    if w is not None:
+        w.paste_text(data['text'])
+        if data.get('execute'):
+            w.write_to_child('\r')
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten snippets')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Store command snippets and insert them into the terminal'
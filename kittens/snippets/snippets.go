@@ -0,0 +1,125 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package snippets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const project_file_name = ".kitty-snippets.json"
+
+type Snippet struct {
+	Name        string `json:"name"`
+	Command     string `json:"command"`
+	Description string `json:"description,omitempty"`
+	// The file this snippet was loaded from
+	Source string `json:"-"`
+}
+
+type snippets_file struct {
+	Snippets []Snippet `json:"snippets"`
+}
+
+func GlobalSnippetsPath() string {
+	return filepath.Join(utils.ConfigDir(), "snippets.json")
+}
+
+// The project snippet files applicable to the specified directory, nearest first
+func ProjectSnippetsPaths(cwd string) (ans []string) {
+	for dir := cwd; ; {
+		q := filepath.Join(dir, project_file_name)
+		if s, err := os.Stat(q); err == nil && !s.IsDir() {
+			ans = append(ans, q)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return
+}
+
+func LoadSnippets(path string) ([]Snippet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var f snippets_file
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("The snippets file %s is not valid: %w", path, err)
+	}
+	for i := range f.Snippets {
+		f.Snippets[i].Source = path
+	}
+	return f.Snippets, nil
+}
+
+func SaveSnippets(path string, snippets []Snippet) error {
+	data, err := json.MarshalIndent(snippets_file{Snippets: snippets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return utils.AtomicUpdateFile(path, append(data, '\n'), 0o644)
+}
+
+// All snippets available in the specified directory. Project snippets come
+// first and shadow snippets with the same name from files further away.
+func AllSnippets(cwd string) (ans []Snippet, err error) {
+	seen := utils.NewSet[string]()
+	for _, path := range append(ProjectSnippetsPaths(cwd), GlobalSnippetsPath()) {
+		s, err := LoadSnippets(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range s {
+			if !seen.Has(x.Name) {
+				seen.Add(x.Name)
+				ans = append(ans, x)
+			}
+		}
+	}
+	return
+}
+
+// Add or replace the snippet with the same name in the specified file
+func AddSnippet(path string, s Snippet) error {
+	existing, err := LoadSnippets(path)
+	if err != nil {
+		return err
+	}
+	if idx := slices.IndexFunc(existing, func(x Snippet) bool { return x.Name == s.Name }); idx > -1 {
+		existing[idx] = s
+	} else {
+		existing = append(existing, s)
+	}
+	return SaveSnippets(path, existing)
+}
+
+func RemoveSnippets(path string, names ...string) error {
+	existing, err := LoadSnippets(path)
+	if err != nil {
+		return err
+	}
+	remaining := slices.DeleteFunc(slices.Clone(existing), func(x Snippet) bool { return slices.Contains(names, x.Name) })
+	if len(remaining) == len(existing) {
+		return fmt.Errorf("No snippets with the specified names found in %s", path)
+	}
+	return SaveSnippets(path, remaining)
+}
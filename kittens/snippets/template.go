@@ -0,0 +1,110 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package snippets
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type part struct {
+	literal string
+	// for placeholders, the tabstop number, 0 for literals
+	tabstop      int
+	default_text string
+}
+
+// A snippet command with placeholders of the form ${1} or ${1:default}.
+// Placeholders with the same number are filled with the same value. A
+// backslash before the dollar sign prevents it from being treated as a
+// placeholder.
+type Template struct {
+	parts []part
+}
+
+func parse_placeholder(text string) (p part, consumed int, ok bool) {
+	// text starts just after ${
+	end := strings.IndexByte(text, '}')
+	if end < 0 {
+		return
+	}
+	spec := text[:end]
+	num, default_text, _ := strings.Cut(spec, ":")
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 1 || num[0] == '+' {
+		return
+	}
+	return part{tabstop: n, default_text: default_text}, end + 1, true
+}
+
+func ParseTemplate(text string) *Template {
+	ans := Template{}
+	lit := strings.Builder{}
+	flush := func() {
+		if lit.Len() > 0 {
+			ans.parts = append(ans.parts, part{literal: lit.String()})
+			lit.Reset()
+		}
+	}
+	for i := 0; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], `\${`):
+			lit.WriteString("${")
+			i += 3
+		case strings.HasPrefix(text[i:], "${"):
+			if p, consumed, ok := parse_placeholder(text[i+2:]); ok {
+				flush()
+				ans.parts = append(ans.parts, p)
+				i += 2 + consumed
+			} else {
+				lit.WriteString("${")
+				i += 2
+			}
+		default:
+			lit.WriteByte(text[i])
+			i++
+		}
+	}
+	flush()
+	return &ans
+}
+
+// The distinct tabstop numbers in ascending order
+func (self *Template) Tabstops() (ans []int) {
+	for _, p := range self.parts {
+		if p.tabstop > 0 && !slices.Contains(ans, p.tabstop) {
+			ans = append(ans, p.tabstop)
+		}
+	}
+	slices.Sort(ans)
+	return
+}
+
+// The default value for a tabstop is the first non-empty default specified for it
+func (self *Template) Default(tabstop int) string {
+	for _, p := range self.parts {
+		if p.tabstop == tabstop && p.default_text != "" {
+			return p.default_text
+		}
+	}
+	return ""
+}
+
+// Expand the template, using the defaults for tabstops not present in values
+func (self *Template) Expand(values map[int]string) string {
+	ans := strings.Builder{}
+	for _, p := range self.parts {
+		if p.tabstop == 0 {
+			ans.WriteString(p.literal)
+		} else if v, found := values[p.tabstop]; found {
+			ans.WriteString(v)
+		} else {
+			ans.WriteString(self.Default(p.tabstop))
+		}
+	}
+	return ans.String()
+}
@@ -0,0 +1,70 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package snippets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestSnippetTemplates(t *testing.T) {
+	tt := ParseTemplate(`git commit -m "${2:message}" ${1} && echo ${2} $HOME \${3} ${x} ${+4}`)
+	if diff := cmp.Diff([]int{1, 2}, tt.Tabstops()); diff != "" {
+		t.Fatalf("Incorrect tabstops:\n%s", diff)
+	}
+	if d := tt.Default(2); d != "message" {
+		t.Fatalf("Incorrect default: %#v", d)
+	}
+	if q := tt.Expand(nil); q != `git commit -m "message"  && echo message $HOME ${3} ${x} ${+4}` {
+		t.Fatalf("Incorrect expansion with defaults: %#v", q)
+	}
+	if q := tt.Expand(map[int]string{1: "-a", 2: "fix"}); q != `git commit -m "fix" -a && echo fix $HOME ${3} ${x} ${+4}` {
+		t.Fatalf("Incorrect expansion: %#v", q)
+	}
+}
+
+func TestSnippetStorage(t *testing.T) {
+	tdir := t.TempDir()
+	child := filepath.Join(tdir, "a", "b")
+	if err := os.MkdirAll(child, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	near, far := filepath.Join(tdir, "a", project_file_name), filepath.Join(tdir, project_file_name)
+	if err := AddSnippet(far, Snippet{Name: "build", Command: "make"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSnippet(far, Snippet{Name: "test", Command: "make test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSnippet(near, Snippet{Name: "build", Command: "go build ./..."}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{near, far}, ProjectSnippetsPaths(child)); diff != "" {
+		t.Fatalf("Incorrect project snippet files:\n%s", diff)
+	}
+	os.Setenv("KITTY_CONFIG_DIRECTORY", filepath.Join(tdir, "config"))
+	defer os.Unsetenv("KITTY_CONFIG_DIRECTORY")
+	all, err := AllSnippets(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := []string{}
+	for _, s := range all {
+		commands = append(commands, s.Command)
+	}
+	if diff := cmp.Diff([]string{"go build ./...", "make test"}, commands); diff != "" {
+		t.Fatalf("Incorrect snippets:\n%s", diff)
+	}
+	if err = RemoveSnippets(far, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err = RemoveSnippets(far, "test"); err == nil {
+		t.Fatalf("Removing a non-existent snippet did not fail")
+	}
+}
@@ -0,0 +1,315 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package snippets
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/tui/readline"
+	"kitty/tools/tui/subseq"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type State int
+
+const (
+	SEARCHING State = iota
+	FILLING
+)
+
+type handler struct {
+	lp          *loop.Loop
+	all         []Snippet
+	global_path string
+
+	state         State
+	search_rl     *readline.Readline
+	field_rl      *readline.Readline
+	matches       []*Snippet
+	matcher       *subseq.Matcher
+	current_idx   int
+	scroll_offset int
+	screen_width  int
+	screen_height int
+
+	chosen        *Snippet
+	template      *Template
+	tabstops      []int
+	current_field int
+	values        map[int]string
+	result        string
+}
+
+func new_handler(lp *loop.Loop, all []Snippet) *handler {
+	ans := handler{lp: lp, all: all, global_path: GlobalSnippetsPath()}
+	ans.search_rl = readline.New(lp, readline.RlInit{DontMarkPrompts: true, Prompt: "> "})
+	ans.field_rl = readline.New(lp, readline.RlInit{DontMarkPrompts: true})
+	return &ans
+}
+
+func (self *handler) initialize() (string, error) {
+	self.lp.SetWindowTitle("Snippets")
+	self.lp.AllowLineWrapping(false)
+	self.update_matches()
+	self.draw_screen()
+	self.lp.SendOverlayReady()
+	return "", nil
+}
+
+func (self *handler) finalize() string {
+	self.search_rl.Shutdown()
+	self.field_rl.Shutdown()
+	return ""
+}
+
+func (self *handler) update_matches() {
+	q := self.search_rl.AllText()
+	self.matches = self.matches[:0]
+	if q == "" {
+		for i := range self.all {
+			self.matches = append(self.matches, &self.all[i])
+		}
+	} else {
+		if self.matcher == nil {
+			texts := make([]string, len(self.all))
+			for i, s := range self.all {
+				texts[i] = s.Name + " " + s.Description + " " + s.Command
+			}
+			self.matcher = subseq.NewMatcher(texts, subseq.Options{})
+		}
+		scores := self.matcher.Score(q)
+		order := make([]int, 0, len(scores))
+		for i, m := range scores {
+			if m.Score > 0 {
+				order = append(order, i)
+			}
+		}
+		// project snippets come first in all, so they win ties
+		slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(scores[b].Score, scores[a].Score) })
+		for _, i := range order {
+			self.matches = append(self.matches, &self.all[i])
+		}
+	}
+	self.current_idx = max(0, min(self.current_idx, len(self.matches)-1))
+}
+
+func (self *handler) list_height() int {
+	return max(1, self.screen_height-2)
+}
+
+func (self *handler) format_snippet(s *Snippet, is_current bool) string {
+	name_width := max(12, self.screen_width/4)
+	name := wcswidth.TruncateToVisualLength(s.Name, name_width-1)
+	name += strings.Repeat(" ", max(0, name_width-wcswidth.Stringwidth(name)))
+	rest := strings.ReplaceAll(s.Command, "\n", "⏎")
+	if s.Description != "" {
+		rest = s.Description + " — " + rest
+	}
+	if s.Source != self.global_path {
+		rest = "[project] " + rest
+	}
+	rest = wcswidth.TruncateToVisualLength(rest, max(0, self.screen_width-name_width))
+	if is_current {
+		return self.lp.SprintStyled("reverse", name+rest+strings.Repeat(" ", max(0, self.screen_width-name_width-wcswidth.Stringwidth(rest))))
+	}
+	return self.lp.SprintStyled("bold", name) + self.lp.SprintStyled("dim", rest)
+}
+
+func (self *handler) draw_search() {
+	h := self.list_height()
+	if self.current_idx < self.scroll_offset {
+		self.scroll_offset = self.current_idx
+	} else if self.current_idx >= self.scroll_offset+h {
+		self.scroll_offset = self.current_idx - h + 1
+	}
+	if len(self.all) == 0 {
+		self.lp.QueueWriteString(self.lp.SprintStyled("dim", "No snippets defined, add some with: kitten snippets --add name command"))
+	} else if len(self.matches) == 0 {
+		self.lp.QueueWriteString(self.lp.SprintStyled("dim", "No matching snippets"))
+	}
+	for i := self.scroll_offset; i < min(len(self.matches), self.scroll_offset+h); i++ {
+		self.lp.QueueWriteString(self.format_snippet(self.matches[i], i == self.current_idx))
+		self.lp.Println()
+	}
+	self.lp.MoveCursorTo(1, self.screen_height-1)
+	self.lp.QueueWriteString(self.lp.SprintStyled("dim", "↑↓ move  Enter choose  Esc quit"))
+	self.lp.MoveCursorTo(1, self.screen_height)
+	self.search_rl.RedrawNonAtomic()
+}
+
+// The expanded command with the value of the current placeholder highlighted
+func (self *handler) preview() string {
+	ans := strings.Builder{}
+	current := self.tabstops[self.current_field]
+	for _, p := range self.template.parts {
+		if p.tabstop == 0 {
+			ans.WriteString(p.literal)
+			continue
+		}
+		v, found := self.values[p.tabstop]
+		if !found {
+			v = self.template.Default(p.tabstop)
+		}
+		if p.tabstop == current {
+			v = self.lp.SprintStyled("reverse", v+" ")
+		} else {
+			v = self.lp.SprintStyled("fg=green", v)
+		}
+		ans.WriteString(v)
+	}
+	return ans.String()
+}
+
+func (self *handler) draw_filling() {
+	self.lp.QueueWriteString(self.lp.SprintStyled("bold", self.chosen.Name))
+	self.lp.Println()
+	self.lp.Println()
+	for _, line := range strings.Split(self.preview(), "\n") {
+		self.lp.QueueWriteString(line)
+		self.lp.Println()
+	}
+	self.lp.MoveCursorTo(1, self.screen_height-1)
+	self.lp.QueueWriteString(self.lp.SprintStyled("dim", fmt.Sprintf(
+		"Placeholder %d of %d  Tab next  Shift+Tab previous  Enter insert  Esc back", self.current_field+1, len(self.tabstops))))
+	self.lp.MoveCursorTo(1, self.screen_height)
+	self.field_rl.RedrawNonAtomic()
+}
+
+// The readline prompts clear to the end of the screen so they are drawn on the last line
+func (self *handler) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	self.lp.ClearScreen()
+	if sz, err := self.lp.ScreenSize(); err == nil {
+		self.screen_width, self.screen_height = int(sz.WidthCells), int(sz.HeightCells)
+	}
+	if self.state == FILLING {
+		self.draw_filling()
+	} else {
+		self.draw_search()
+	}
+}
+
+func (self *handler) choose_current() {
+	if self.current_idx >= len(self.matches) {
+		self.lp.Beep()
+		return
+	}
+	self.chosen = self.matches[self.current_idx]
+	self.template = ParseTemplate(self.chosen.Command)
+	self.tabstops = self.template.Tabstops()
+	if len(self.tabstops) == 0 {
+		self.result = self.template.Expand(nil)
+		self.lp.Quit(0)
+		return
+	}
+	self.values = make(map[int]string, len(self.tabstops))
+	for _, t := range self.tabstops {
+		self.values[t] = self.template.Default(t)
+	}
+	self.state = FILLING
+	self.switch_to_field(0)
+}
+
+func (self *handler) switch_to_field(idx int) {
+	self.current_field = idx
+	t := self.tabstops[idx]
+	self.field_rl.SetPrompt(fmt.Sprintf("${%d}: ", t))
+	self.field_rl.SetText(self.values[t])
+	self.draw_screen()
+}
+
+func (self *handler) on_filling_key_event(ev *loop.KeyEvent) error {
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc"):
+		self.state = SEARCHING
+		self.draw_screen()
+	case ev.MatchesPressOrRepeat("tab"):
+		self.switch_to_field((self.current_field + 1) % len(self.tabstops))
+	case ev.MatchesPressOrRepeat("shift+tab"):
+		self.switch_to_field((self.current_field + len(self.tabstops) - 1) % len(self.tabstops))
+	case ev.MatchesPressOrRepeat("enter"):
+		self.result = self.template.Expand(self.values)
+		self.lp.Quit(0)
+	default:
+		ev.Handled = false
+		if err := self.field_rl.OnKeyEvent(ev); err != nil {
+			return err
+		}
+		if ev.Handled {
+			self.values[self.tabstops[self.current_field]] = self.field_rl.AllText()
+			self.draw_screen()
+		}
+	}
+	return nil
+}
+
+func (self *handler) on_text(text string, from_key_event, in_bracketed_paste bool) error {
+	if self.state == FILLING {
+		if err := self.field_rl.OnText(text, from_key_event, in_bracketed_paste); err != nil {
+			return err
+		}
+		self.values[self.tabstops[self.current_field]] = self.field_rl.AllText()
+	} else {
+		if err := self.search_rl.OnText(text, from_key_event, in_bracketed_paste); err != nil {
+			return err
+		}
+		self.current_idx = 0
+		self.update_matches()
+	}
+	self.draw_screen()
+	return nil
+}
+
+func (self *handler) move(delta int) {
+	if len(self.matches) == 0 {
+		self.lp.Beep()
+		return
+	}
+	self.current_idx = max(0, min(self.current_idx+delta, len(self.matches)-1))
+	self.draw_screen()
+}
+
+func (self *handler) on_key_event(ev *loop.KeyEvent) error {
+	if self.state == FILLING {
+		return self.on_filling_key_event(ev)
+	}
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc"):
+		if self.search_rl.AllText() != "" {
+			self.search_rl.ResetText()
+			self.update_matches()
+			self.draw_screen()
+		} else {
+			self.lp.Quit(1)
+		}
+	case ev.MatchesPressOrRepeat("enter"):
+		self.choose_current()
+	case ev.MatchesPressOrRepeat("up") || ev.MatchesPressOrRepeat("ctrl+p"):
+		self.move(-1)
+	case ev.MatchesPressOrRepeat("down") || ev.MatchesPressOrRepeat("ctrl+n"):
+		self.move(1)
+	case ev.MatchesPressOrRepeat("page_up"):
+		self.move(-self.list_height())
+	case ev.MatchesPressOrRepeat("page_down"):
+		self.move(self.list_height())
+	default:
+		ev.Handled = false
+		if err := self.search_rl.OnKeyEvent(ev); err != nil {
+			return err
+		}
+		if ev.Handled {
+			self.update_matches()
+			self.draw_screen()
+		}
+	}
+	return nil
+}
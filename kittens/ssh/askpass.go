@@ -11,6 +11,7 @@ import (
 
 	"kitty/tools/cli"
 	"kitty/tools/tty"
+	"kitty/tools/utils"
 	"kitty/tools/utils/shm"
 )
 
@@ -18,6 +19,7 @@ var _ = fmt.Print
 
 func fatal(err error) {
 	cli.ShowError(err)
+	utils.CleanupAtExit()
 	os.Exit(1)
 }
 
@@ -19,7 +19,6 @@ import (
 	"kitty/tools/utils/paths"
 	"kitty/tools/utils/shlex"
 
-	"github.com/bmatcuk/doublestar/v4"
 	"golang.org/x/sys/unix"
 )
 
@@ -91,20 +90,13 @@ func (self *EnvInstruction) Serialize(for_python bool, get_local_env func(string
 }
 
 func final_env_instructions(for_python bool, get_local_env func(string) (string, bool), env ...*EnvInstruction) string {
-	seen := make(map[string]int, len(env))
-	ans := make([]string, 0, len(env))
+	ans := utils.NewOrderedMap[string, string](len(env))
 	for _, ei := range env {
-		q := ei.Serialize(for_python, get_local_env)
-		if q != "" {
-			if pos, found := seen[ei.key]; found {
-				ans[pos] = q
-			} else {
-				seen[ei.key] = len(ans)
-				ans = append(ans, q)
-			}
+		if q := ei.Serialize(for_python, get_local_env); q != "" {
+			ans.Set(ei.key, q)
 		}
 	}
-	return strings.Join(ans, "\n")
+	return strings.Join(ans.Values(), "\n")
 }
 
 type CopyInstruction struct {
@@ -145,9 +137,9 @@ func resolve_file_spec(spec string, is_glob bool) ([]string, error) {
 		ans = paths_ctx.AbspathFromHome(ans)
 	}
 	if is_glob {
-		files, err := doublestar.FilepathGlob(ans)
+		files, err := utils.Glob(ans)
 		if err != nil {
-			return nil, fmt.Errorf("%s is not a valid glob pattern with error: %w", spec, err)
+			return nil, err
 		}
 		if len(files) == 0 {
 			return nil, fmt.Errorf("%s matches no files", spec)
@@ -234,13 +226,11 @@ func excluded(pattern, path string) bool {
 	if !strings.ContainsRune(pattern, '/') {
 		path = filepath.Base(path)
 	}
-	if matched, err := doublestar.PathMatch(pattern, path); matched && err == nil {
-		return true
-	}
-	return false
+	return utils.GlobMatch(pattern, path)
 }
 
-func get_file_data(callback func(h *tar.Header, data []byte) error, seen map[file_unique_id]string, local_path, arcname string, exclude_patterns []string) error {
+// read_file is used to read the contents of regular files
+func get_file_data(callback func(h *tar.Header, data []byte) error, seen map[file_unique_id]string, local_path, arcname string, exclude_patterns []string, read_file func(string) ([]byte, error)) error {
 	s, err := os.Lstat(local_path)
 	if err != nil {
 		return err
@@ -277,6 +267,7 @@ func get_file_data(callback func(h *tar.Header, data []byte) error, seen map[fil
 		}
 	case fs.ModeDir:
 		local_path = filepath.Clean(local_path)
+		ignore := utils.NewIgnoreMatcher(local_path, utils.IgnoreRules{FileNames: []string{".ignore"}, Patterns: []string{"__pycache__", ".DS_Store"}})
 		type entry struct {
 			path, arcname string
 		}
@@ -298,6 +289,10 @@ func get_file_data(callback func(h *tar.Header, data []byte) error, seen map[fil
 			for _, e := range entries {
 				entry_path := filepath.Join(x.path, e.Name())
 				aname := path.Join(x.arcname, e.Name())
+				is_dir := e.IsDir()
+				if rel, err := filepath.Rel(local_path, entry_path); err == nil && ignore.IsIgnored(rel, is_dir) {
+					continue
+				}
 				ok := true
 				for _, pat := range exclude_patterns {
 					if excluded(pat, entry_path) {
@@ -308,10 +303,10 @@ func get_file_data(callback func(h *tar.Header, data []byte) error, seen map[fil
 				if !ok {
 					continue
 				}
-				if e.IsDir() {
+				if is_dir {
 					stack = append(stack, entry{entry_path, aname})
 				} else {
-					err = get_file_data(callback, seen, entry_path, aname, exclude_patterns)
+					err = get_file_data(callback, seen, entry_path, aname, exclude_patterns, read_file)
 					if err != nil {
 						return err
 					}
@@ -327,7 +322,7 @@ func get_file_data(callback func(h *tar.Header, data []byte) error, seen map[fil
 			}
 		}
 		seen[fid] = arcname
-		data, err := os.ReadFile(local_path)
+		data, err := read_file(local_path)
 		if err != nil {
 			return err
 		}
@@ -339,12 +334,8 @@ func get_file_data(callback func(h *tar.Header, data []byte) error, seen map[fil
 	return nil
 }
 
-func (ci *CopyInstruction) get_file_data(callback func(h *tar.Header, data []byte) error, seen map[file_unique_id]string) (err error) {
-	ep := ci.exclude_patterns
-	for _, folder_name := range []string{"__pycache__", ".DS_Store"} {
-		ep = append(ep, "**/"+folder_name, "**/"+folder_name+"/**")
-	}
-	return get_file_data(callback, seen, ci.local_path, ci.arcname, ep)
+func (ci *CopyInstruction) get_file_data(callback func(h *tar.Header, data []byte) error, seen map[file_unique_id]string, read_file func(string) ([]byte, error)) (err error) {
+	return get_file_data(callback, seen, ci.local_path, ci.arcname, ci.exclude_patterns, read_file)
 }
 
 type ConfigSet struct {
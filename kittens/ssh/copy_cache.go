@@ -0,0 +1,118 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ssh
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"kitty/tools/utils"
+
+	"github.com/zeebo/xxh3"
+)
+
+var _ = fmt.Print
+
+// Change when the way copied files are added to the tarball changes, to
+// invalidate previously cached data
+const copy_cache_version = 1
+
+var copy_cache_dir = func() string { return filepath.Join(utils.CacheDir(), "ssh") }
+var copy_fingerprints = func() *utils.FingerprintStore { return utils.FingerprintStoreFor("ssh") }
+
+// some distro's like nix mess with installed file permissions so ensure
+// files are at least readable and writable by owning user
+func fix_header_permissions(h *tar.Header) { h.Mode |= 0o600 }
+
+// Calculate a key that changes whenever the tar entries for the files to
+// copy would change. Only files whose size or modification time have changed
+// since the last connection are read.
+func copy_cache_key(copies []*CopyInstruction, fingerprints *utils.FingerprintStore) (uint64, error) {
+	h := xxh3.New()
+	fmt.Fprintf(h, "%d\x00", copy_cache_version)
+	var num [8]byte
+	add_num := func(x uint64) {
+		binary.LittleEndian.PutUint64(num[:], x)
+		h.Write(num[:])
+	}
+	seen := make(map[file_unique_id]string, 32)
+	read_file := func(path string) ([]byte, error) {
+		fp, _, err := fingerprints.Check(path)
+		if err == nil {
+			add_num(fp.Hash)
+			add_num(uint64(fp.Size))
+		}
+		return nil, err
+	}
+	callback := func(th *tar.Header, _ []byte) error {
+		fix_header_permissions(th)
+		fmt.Fprintf(h, "%c\x00%s\x00%s\x00%o\x00", th.Typeflag, th.Name, th.Linkname, th.Mode)
+		add_num(uint64(th.ModTime.UnixNano()))
+		return nil
+	}
+	for _, ci := range copies {
+		if err := ci.get_file_data(callback, seen, read_file); err != nil {
+			return 0, err
+		}
+	}
+	return h.Sum64(), nil
+}
+
+// The tar entries for the files to copy, without the end of archive marker,
+// as a gzip stream. Since concatenated gzip streams decompress to the
+// concatenation of their contents, this can be followed by a gzip stream of
+// more tar entries. It is cached, so that repeat connections do not need to
+// read and compress all the files again.
+func copied_files_data(copies []*CopyInstruction) ([]byte, error) {
+	if len(copies) == 0 {
+		return nil, nil
+	}
+	fingerprints := copy_fingerprints()
+	defer func() { _ = fingerprints.Save() }()
+	cache_path := ""
+	if key, err := copy_cache_key(copies, fingerprints); err == nil {
+		cache_path = filepath.Join(copy_cache_dir(), fmt.Sprintf("copy-%016x.tar.gz", key))
+		if data, err := os.ReadFile(cache_path); err == nil {
+			_ = utils.DefaultCacheManager().Touch(cache_path)
+			return data, nil
+		}
+	}
+	w := bytes.Buffer{}
+	gw, err := gzip.NewWriterLevel(&w, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(gw)
+	seen := make(map[file_unique_id]string, 32)
+	add := func(h *tar.Header, data []byte) (err error) {
+		fix_header_permissions(h)
+		if err = tw.WriteHeader(h); err == nil && data != nil {
+			_, err = tw.Write(data)
+		}
+		return
+	}
+	for _, ci := range copies {
+		if err = ci.get_file_data(add, seen, os.ReadFile); err != nil {
+			return nil, err
+		}
+	}
+	// Flush() pads the last entry without writing the end of archive marker
+	if err = tw.Flush(); err != nil {
+		return nil, err
+	}
+	if err = gw.Close(); err != nil {
+		return nil, err
+	}
+	if cache_path != "" {
+		if os.MkdirAll(filepath.Dir(cache_path), 0o700) == nil {
+			_ = utils.AtomicWriteFile(cache_path, w.Bytes(), 0o600)
+		}
+		utils.DefaultCacheManager().SweepInBackground()
+	}
+	return w.Bytes(), nil
+}
@@ -0,0 +1,546 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/utils/shlex"
+	"kitty/tools/utils/style"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+// Details of a changed host key, parsed from the warning printed by OpenSSH
+type host_key_change struct {
+	// the host as understood by ssh-keygen -R
+	host                          string
+	new_key_type, new_fingerprint string
+	known_hosts                   string
+	line_number                   int
+
+	// from the offending line in known_hosts
+	known_hosts_line              string
+	old_key_type, old_fingerprint string
+	old_digest                    []byte
+	// when the old key was first seen by this kitten, if known
+	old_first_seen time.Time
+}
+
+type host_key_filter_state int
+
+const (
+	hkf_deciding host_key_filter_state = iota
+	hkf_capturing
+	hkf_captured
+	hkf_passthrough
+)
+
+// Sits between the stderr of ssh and the real stderr, swallowing the warning
+// OpenSSH prints when a host key has changed and connecting is refused, so
+// that it can be presented in a more readable form. Everything else is passed
+// through unchanged.
+type host_key_filter struct {
+	dest    io.Writer
+	state   host_key_filter_state
+	buf     []byte
+	pending []byte
+	lines   []string
+	mutex   sync.Mutex
+}
+
+var host_key_warning_prefixes = []string{
+	"IT IS POSSIBLE THAT SOMEONE", "Someone could be eavesdropping", "It is also possible that a host key",
+	"The fingerprint for the ", "SHA256:", "MD5:", "+", "|", "Please contact your system administrator",
+	"Add correct host key in ", "Offending ", "  remove with", "  ssh-keygen ", "Host key for ",
+}
+
+func (self *host_key_filter) switch_to_passthrough() (err error) {
+	self.state = hkf_passthrough
+	_, err = self.dest.Write(append(self.buf, self.pending...))
+	self.buf, self.pending, self.lines = nil, nil, nil
+	return
+}
+
+func (self *host_key_filter) Write(p []byte) (int, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	switch self.state {
+	case hkf_passthrough:
+		return self.dest.Write(p)
+	case hkf_captured:
+		return len(p), nil
+	}
+	self.pending = append(self.pending, p...)
+	if self.state == hkf_deciding && len(self.buf) == 0 && self.pending[0] != '@' {
+		return len(p), self.switch_to_passthrough()
+	}
+	for self.state == hkf_deciding || self.state == hkf_capturing {
+		idx := bytes.IndexByte(self.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		raw := self.pending[:idx+1]
+		line := strings.TrimRight(string(raw), "\r\n")
+		switch {
+		case self.state == hkf_deciding && strings.HasPrefix(line, "@"):
+			if strings.Contains(line, "REMOTE HOST IDENTIFICATION HAS CHANGED") {
+				self.state = hkf_capturing
+			}
+		case self.state == hkf_capturing && (strings.HasPrefix(line, "@") || slices.ContainsFunc(host_key_warning_prefixes, func(x string) bool { return strings.HasPrefix(line, x) })):
+		case self.state == hkf_capturing && strings.HasPrefix(line, "Host key verification failed"):
+			self.state = hkf_captured
+		default:
+			// Not the warning we are looking for or ssh is continuing to
+			// connect, so the warning has to be shown as is
+			return len(p), self.switch_to_passthrough()
+		}
+		self.buf = append(self.buf, raw...)
+		self.lines = append(self.lines, line)
+		self.pending = self.pending[idx+1:]
+	}
+	if len(self.pending) > 4096 {
+		return len(p), self.switch_to_passthrough()
+	}
+	return len(p), nil
+}
+
+// Called once ssh has exited. Returns the details of the changed host key if
+// the warning was swallowed, otherwise writes any buffered output.
+func (self *host_key_filter) Finish() *host_key_change {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.state == hkf_captured {
+		if ans := parse_host_key_warning(self.lines); ans != nil {
+			return ans
+		}
+	}
+	if self.state != hkf_passthrough {
+		_ = self.switch_to_passthrough()
+	}
+	return nil
+}
+
+func parse_host_key_warning(lines []string) *host_key_change {
+	ans := host_key_change{}
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "The fingerprint for the "):
+			ans.new_key_type, _, _ = strings.Cut(line[len("The fingerprint for the "):], " ")
+			if i+1 < len(lines) {
+				ans.new_fingerprint = strings.TrimSuffix(strings.TrimSpace(lines[i+1]), ".")
+			}
+		case strings.HasPrefix(line, "Offending "):
+			if _, loc, found := strings.Cut(line, " key in "); found {
+				if idx := strings.LastIndexByte(loc, ':'); idx > 0 {
+					if n, err := strconv.Atoi(loc[idx+1:]); err == nil {
+						ans.known_hosts, ans.line_number = loc[:idx], n
+					}
+				}
+			}
+		case strings.HasPrefix(line, "  ssh-keygen "):
+			if args, err := shlex.Split(line); err == nil {
+				for j := 0; j+1 < len(args); j++ {
+					switch args[j] {
+					case "-f":
+						ans.known_hosts = args[j+1]
+					case "-R":
+						ans.host = args[j+1]
+					}
+				}
+			}
+		case strings.HasPrefix(line, "Host key for ") && ans.host == "":
+			ans.host, _, _ = strings.Cut(line[len("Host key for "):], " has changed")
+		}
+	}
+	if ans.host == "" || ans.new_fingerprint == "" || ans.known_hosts == "" || ans.line_number < 1 {
+		return nil
+	}
+	if f, err := os.Open(utils.Expanduser(ans.known_hosts)); err == nil {
+		defer f.Close()
+		scanner := utils.NewRecordScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			if n == ans.line_number {
+				ans.known_hosts_line = scanner.Text()
+				break
+			}
+		}
+		ans.old_key_type, ans.old_fingerprint, ans.old_digest = fingerprint_of_known_hosts_line(ans.known_hosts_line)
+	}
+	return &ans
+}
+
+// OpenSSH does not record when the key of a host was first seen, so when
+// track_host_keys is enabled, the keys in known_hosts are recorded in the
+// cache directory when connecting, by the name of the host in known_hosts and
+// the fingerprint of the key.
+type host_keys_seen struct {
+	First_seen map[string]time.Time `json:"first_seen"`
+}
+
+func host_keys_cache() *utils.CachedValues[*host_keys_seen] {
+	return utils.NewCachedValues("ssh-host-keys", &host_keys_seen{First_seen: map[string]time.Time{}})
+}
+
+// The name of the host in known_hosts and the known_hosts files from the
+// output of ssh -G
+func known_hosts_name(ssh_config string) (name string, files []string) {
+	port := "22"
+	alias := ""
+	for _, line := range strings.Split(ssh_config, "\n") {
+		key, val, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch key {
+		case "hostname":
+			name = val
+		case "hostkeyalias":
+			alias = val
+		case "port":
+			port = val
+		case "userknownhostsfile":
+			files = strings.Fields(val)
+		}
+	}
+	name = utils.IfElse(alias != "", alias, name)
+	if name != "" && port != "22" {
+		name = fmt.Sprintf("[%s]:%s", name, port)
+	}
+	return
+}
+
+// The fingerprints of the keys for the named host in the known_hosts files,
+// which can have hashed host names, so ssh-keygen is used to find them
+func known_host_keys(name string, files []string) (ans []string) {
+	for _, f := range files {
+		out, err := exec.Command("ssh-keygen", "-F", name, "-f", utils.Expanduser(f)).Output()
+		if err != nil {
+			// no keys for the host in this file
+			continue
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				if _, fp, _ := fingerprint_of_known_hosts_line(line); fp != "" {
+					ans = append(ans, fp)
+				}
+			}
+		}
+	}
+	return
+}
+
+// Record the keys of the host that have not been seen before, with the
+// current time
+func record_host_keys(cache *utils.CachedValues[*host_keys_seen], name string, fingerprints []string, now time.Time) {
+	seen := cache.Load()
+	changed := false
+	for _, fp := range fingerprints {
+		if _, found := seen.First_seen[name+" "+fp]; !found {
+			if seen.First_seen == nil {
+				seen.First_seen = map[string]time.Time{}
+			}
+			seen.First_seen[name+" "+fp] = now
+			changed = true
+		}
+	}
+	if changed {
+		cache.Save()
+	}
+}
+
+// Track the keys of the host ssh is connecting to, using ssh -G with the
+// arguments of the ssh command to find them. Returns a function that looks
+// up when a key was first seen.
+func track_host_keys(ssh_cmd []string, hostname string) (first_seen func(name, fingerprint string) time.Time) {
+	cache := host_keys_cache()
+	first_seen = func(name, fingerprint string) time.Time { return cache.Load().First_seen[name+" "+fingerprint] }
+	cmd := append(slices.Insert(slices.Clone(ssh_cmd), 1, "-G"), "--", hostname)
+	out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+	if err != nil {
+		return
+	}
+	if name, files := known_hosts_name(string(out)); name != "" {
+		record_host_keys(cache, name, known_host_keys(name, files), time.Now())
+	}
+	return
+}
+
+// The key type name as displayed by OpenSSH, for example ED25519 for ssh-ed25519
+func display_key_type(x string) string {
+	x = strings.TrimPrefix(x, "ssh-")
+	if strings.HasPrefix(x, "ecdsa-") {
+		return "ECDSA"
+	}
+	if x == "dss" {
+		return "DSA"
+	}
+	return strings.ToUpper(x)
+}
+
+func fingerprint_of_known_hosts_line(line string) (key_type, fingerprint string, digest []byte) {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		fields = fields[1:]
+	}
+	if len(fields) < 3 {
+		return
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return
+	}
+	h := sha256.Sum256(blob)
+	return display_key_type(fields[1]), "SHA256:" + base64.RawStdEncoding.EncodeToString(h[:]), h[:]
+}
+
+// The raw digest and hash name for a fingerprint as printed by OpenSSH
+func digest_of_fingerprint(fp string) (digest []byte, alg string) {
+	if rest, found := strings.CutPrefix(fp, "SHA256:"); found {
+		digest, _ = base64.RawStdEncoding.DecodeString(rest)
+		return digest, "SHA256"
+	}
+	if rest, found := strings.CutPrefix(fp, "MD5:"); found {
+		digest, _ = hex.DecodeString(strings.ReplaceAll(rest, ":", ""))
+		if len(digest) == md5.Size {
+			return digest, "MD5"
+		}
+	}
+	return nil, ""
+}
+
+// The randomart image for a digest, using the drunken bishop algorithm from
+// OpenSSH so that it looks the same as the output of ssh-keygen -lv
+func randomart(digest []byte, title, alg string) []string {
+	const width, height = 17, 9
+	const symbols = " .o+=*BOX@%&#/^SE"
+	start, end := len(symbols)-2, len(symbols)-1
+	var field [width][height]int
+	x, y := width/2, height/2
+	for _, b := range digest {
+		for i := 0; i < 4; i++ {
+			x += utils.IfElse(b&1 != 0, 1, -1)
+			y += utils.IfElse(b&2 != 0, 1, -1)
+			x, y = utils.Max(0, utils.Min(x, width-1)), utils.Max(0, utils.Min(y, height-1))
+			if field[x][y] < start-1 {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+	field[width/2][height/2] = start
+	field[x][y] = end
+	border := func(label string) string {
+		if label != "" {
+			label = "[" + label + "]"
+		}
+		if len(label) > width {
+			label = ""
+		}
+		left := (width - len(label)) / 2
+		return "+" + strings.Repeat("-", left) + label + strings.Repeat("-", width-left-len(label)) + "+"
+	}
+	ans := make([]string, 0, height+2)
+	ans = append(ans, border(title))
+	for r := 0; r < height; r++ {
+		row := make([]byte, width)
+		for c := 0; c < width; c++ {
+			row[c] = symbols[field[c][r]]
+		}
+		ans = append(ans, "|"+string(row)+"|")
+	}
+	return append(ans, border(alg))
+}
+
+type host_key_ui struct {
+	lp             *loop.Loop
+	change         *host_key_change
+	show_line      bool
+	confirming     bool
+	typed, message string
+	removed        bool
+}
+
+func (self *host_key_ui) lines(width int) (ans []string) {
+	sprint := self.lp.SprintStyled
+	wrap := func(text string) {
+		ans = append(ans, style.WrapTextAsLines(text, width, style.WrapOptions{Trim_whitespace: true})...)
+	}
+	hc := self.change
+	ans = append(ans, sprint("fg=red bold", "WARNING: The host key for "+hc.host+" has changed!"), "")
+	wrap("Either someone is intercepting your connection (a man-in-the-middle attack) or the key of the server has been legitimately replaced, for example, because it was reinstalled. Do not connect until you have verified the new key with the administrator of the server. The connection has been refused.")
+	ans = append(ans, "")
+	art := func(fp, key_type string, digest []byte) []string {
+		if digest == nil {
+			digest, _ = digest_of_fingerprint(fp)
+		}
+		_, alg := digest_of_fingerprint(fp)
+		if digest == nil {
+			return nil
+		}
+		return randomart(digest, key_type, alg)
+	}
+	old_art := art(hc.old_fingerprint, hc.old_key_type, hc.old_digest)
+	new_art := art(hc.new_fingerprint, hc.new_key_type, nil)
+	old_fp := utils.IfElse(hc.old_fingerprint == "", "unknown", hc.old_key_type+" "+hc.old_fingerprint)
+	col := utils.Max(24, wcswidth.Stringwidth(old_fp)+4)
+	pad := func(left string, right string) string {
+		return left + strings.Repeat(" ", utils.Max(1, col-wcswidth.Stringwidth(left))) + right
+	}
+	ans = append(ans, pad(sprint("bold", "Previously known key"), sprint("bold", "Key presented now")))
+	ans = append(ans, pad(sprint("fg=green", old_fp), sprint("fg=red", hc.new_key_type+" "+hc.new_fingerprint)))
+	for i := 0; i < utils.Max(len(old_art), len(new_art)); i++ {
+		l, r := "", ""
+		if i < len(old_art) {
+			l = old_art[i]
+		}
+		if i < len(new_art) {
+			r = new_art[i]
+		}
+		ans = append(ans, pad(l, r))
+	}
+	ans = append(ans, "", fmt.Sprintf("The previously known key is on line %d of %s", hc.line_number, hc.known_hosts))
+	if hc.old_first_seen.IsZero() {
+		wrap("It is not known when the previously known key was first seen, as it was not seen since host key tracking was turned on. If the known hosts file is under version control, its history will show when the key was added.")
+	} else {
+		wrap("The previously known key was first seen on " + hc.old_first_seen.Local().Format("2 January 2006 at 15:04") + ".")
+	}
+	if self.show_line {
+		ans = append(ans, "")
+		wrap(sprint("dim", hc.known_hosts_line))
+	}
+	ans = append(ans, "")
+	switch {
+	case self.confirming:
+		wrap(fmt.Sprintf("To remove the old key, type the host name %s and press Enter, or press Esc to cancel:", sprint("bold", hc.host)))
+		ans = append(ans, "> "+self.typed)
+	case self.removed:
+		wrap(self.message)
+		ans = append(ans, "", "Press any key to exit")
+	default:
+		if self.message != "" {
+			wrap(sprint("fg=red", self.message))
+		}
+		key := func(k, text string) string { return sprint("fg=green", k) + " " + text }
+		ans = append(ans, strings.Join([]string{
+			key("a", "Abort"), key("v", utils.IfElse(self.show_line, "Hide", "View")+" the known hosts line"),
+			key("r", "Remove the old key"),
+		}, "    "))
+	}
+	return
+}
+
+func (self *host_key_ui) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	self.lp.ClearScreen()
+	width := 80
+	if sz, err := self.lp.ScreenSize(); err == nil && sz.WidthCells > 0 {
+		width = int(sz.WidthCells)
+	}
+	self.lp.QueueWriteString(strings.Join(self.lines(width), "\r\n"))
+	self.lp.SetCursorVisible(self.confirming)
+}
+
+func (self *host_key_ui) remove_old_key() {
+	hc := self.change
+	c := exec.Command("ssh-keygen", "-f", utils.Expanduser(hc.known_hosts), "-R", hc.host)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		self.message = fmt.Sprintf("Removing the old key failed with error: %s\n%s", err, strings.TrimSpace(string(out)))
+		return
+	}
+	self.removed = true
+	self.message = fmt.Sprintf("The old key for %s has been removed from %s, a backup was saved in %s.old. When you connect next, you will be asked to verify the new key.", hc.host, hc.known_hosts, hc.known_hosts)
+}
+
+func (self *host_key_ui) on_key_event(ev *loop.KeyEvent) error {
+	switch {
+	case self.removed:
+		if ev.Type == loop.PRESS {
+			ev.Handled = true
+			self.lp.Quit(255)
+		}
+		return nil
+	case self.confirming:
+		switch {
+		case ev.MatchesPressOrRepeat("esc"):
+			self.confirming, self.typed = false, ""
+		case ev.MatchesPressOrRepeat("backspace"):
+			if r := []rune(self.typed); len(r) > 0 {
+				self.typed = string(r[:len(r)-1])
+			}
+		case ev.MatchesPressOrRepeat("enter"):
+			if self.typed == self.change.host {
+				self.remove_old_key()
+			} else {
+				self.message = "The typed name did not match the host name, the old key was not removed"
+			}
+			self.confirming, self.typed = false, ""
+		default:
+			return nil
+		}
+	case ev.MatchesPressOrRepeat("a") || ev.MatchesPressOrRepeat("esc") || ev.MatchesPressOrRepeat("q") || ev.MatchesPressOrRepeat("ctrl+c"):
+		ev.Handled = true
+		self.lp.Quit(255)
+		return nil
+	case ev.MatchesPressOrRepeat("v"):
+		self.show_line = !self.show_line
+	case ev.MatchesPressOrRepeat("r"):
+		self.confirming, self.message = true, ""
+	default:
+		return nil
+	}
+	ev.Handled = true
+	self.draw_screen()
+	return nil
+}
+
+// Explain the changed host key and offer to remove the old one. Returns the
+// exit code of ssh for a refused connection.
+func show_host_key_change(hc *host_key_change) (rc int, err error) {
+	lp, err := loop.New(loop.NoRestoreColors)
+	if err != nil {
+		return 255, err
+	}
+	h := host_key_ui{lp: lp, change: hc}
+	lp.OnInitialize = func() (string, error) {
+		lp.SetWindowTitle("Host key changed for " + hc.host)
+		h.draw_screen()
+		return "", nil
+	}
+	lp.OnFinalize = func() string {
+		lp.SetCursorVisible(true)
+		return ""
+	}
+	lp.OnResize = func(_, _ loop.ScreenSize) error {
+		h.draw_screen()
+		return nil
+	}
+	lp.OnKeyEvent = h.on_key_event
+	lp.OnText = func(text string, from_key_event, in_bracketed_paste bool) error {
+		if h.confirming {
+			h.typed += text
+			h.draw_screen()
+		}
+		return nil
+	}
+	if err = lp.Run(); err != nil {
+		return 255, err
+	}
+	lp.KillIfSignalled()
+	return 255, nil
+}
@@ -0,0 +1,122 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+const test_host_key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAII6tdPAqkbrqLTfsLig4artJM6VE+scMCYoij/2qCeNp"
+
+func TestSSHHostKeyChange(t *testing.T) {
+	key_type, fp, digest := fingerprint_of_known_hosts_line("example.com " + test_host_key + " comment")
+	if key_type != "ED25519" || fp != "SHA256:LZv3ws6jSbD6cAbqVu2KDGnvcpzj2/Fl4lHQ9Ssy9ds" {
+		t.Fatalf("Incorrect fingerprint: %s %s", key_type, fp)
+	}
+	// as output by ssh-keygen -lv
+	expected := strings.Split(strings.TrimSpace(`
++--[ED25519 256]--+
+|           .     |
+|        . . .    |
+|       . . . .   |
+|        ... . .  |
+|    ... S+.. o   |
+| . ....o.+o . o  |
+|o.o.oo+o++.  . E |
+|.=o*.==.=++.     |
+| .O==+.ooooo.    |
++----[SHA256]-----+`), "\n")
+	if diff := cmp.Diff(expected, randomart(digest, "ED25519 256", "SHA256")); diff != "" {
+		t.Fatalf("Incorrect randomart:\n%s", diff)
+	}
+	if d, alg := digest_of_fingerprint(fp); alg != "SHA256" || !bytes.Equal(d, digest) {
+		t.Fatalf("Incorrect digest for fingerprint: %s %x", alg, d)
+	}
+
+	known_hosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(known_hosts, []byte("other.com "+test_host_key+"\n[example.com]:2222 "+test_host_key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	warning := strings.ReplaceAll(fmt.Sprintf(`@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @
+@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+IT IS POSSIBLE THAT SOMEONE IS DOING SOMETHING NASTY!
+Someone could be eavesdropping on you right now (man-in-the-middle attack)!
+It is also possible that a host key has just been changed.
+The fingerprint for the ED25519 key sent by the remote host is
+SHA256:kbi8EbVsyaD6Y3ZMBuMDk0ICIwHiJ5IsviSyIRnDRbU.
+Please contact your system administrator.
+Add correct host key in %[1]s to get rid of this message.
+Offending ED25519 key in %[1]s:2
+  remove with:
+  ssh-keygen -f '%[1]s' -R '[example.com]:2222'
+Host key for [example.com]:2222 has changed and you have requested strict checking.
+Host key verification failed.
+`, known_hosts), "\n", "\r\n")
+
+	run := func(chunks ...string) (*host_key_change, string) {
+		out := bytes.Buffer{}
+		f := host_key_filter{dest: &out}
+		for _, x := range chunks {
+			if _, err := f.Write([]byte(x)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return f.Finish(), out.String()
+	}
+	hc, out := run(warning[:10], warning[10:200], warning[200:])
+	if out != "" || hc == nil {
+		t.Fatalf("Host key warning not swallowed: %#v", out)
+	}
+	if diff := cmp.Diff(host_key_change{
+		host: "[example.com]:2222", new_key_type: "ED25519", new_fingerprint: "SHA256:kbi8EbVsyaD6Y3ZMBuMDk0ICIwHiJ5IsviSyIRnDRbU",
+		known_hosts: known_hosts, line_number: 2, known_hosts_line: "[example.com]:2222 " + test_host_key,
+		old_key_type: "ED25519", old_fingerprint: fp, old_digest: digest,
+	}, *hc, cmp.AllowUnexported(host_key_change{})); diff != "" {
+		t.Fatalf("Incorrectly parsed host key warning:\n%s", diff)
+	}
+
+	if _, err := exec.LookPath("ssh-keygen"); err == nil {
+		if diff := cmp.Diff([]string{fp}, known_host_keys("[example.com]:2222", []string{known_hosts})); diff != "" {
+			t.Fatalf("Incorrect keys for host:\n%s", diff)
+		}
+		if keys := known_host_keys("example.com", []string{known_hosts}); len(keys) != 0 {
+			t.Fatalf("Unexpected keys for host: %#v", keys)
+		}
+	}
+
+	// When ssh continues to connect the warning must be shown
+	continued := strings.Replace(warning, "Host key verification failed.", "Password authentication is disabled to avoid man-in-the-middle attacks.", 1) + "remote output\r\n"
+	for _, x := range []string{"some error\r\n", "@ partial", continued} {
+		if hc, out = run(x); hc != nil || out != x {
+			t.Fatalf("Output not passed through: %#v != %#v", x, out)
+		}
+	}
+
+	for cfg, expected := range map[string]string{
+		"user me\nhostname example.com\nport 22\n":                            "example.com",
+		"hostname example.com\nport 2222\n":                                   "[example.com]:2222",
+		"hostname 10.0.0.1\nhostkeyalias example.com\nport 2222\n":            "[example.com]:2222",
+		"hostname example.com\nuserknownhostsfile ~/.ssh/known_hosts ~/kh2\n": "example.com",
+	} {
+		name, files := known_hosts_name(cfg)
+		if name != expected {
+			t.Fatalf("Incorrect known_hosts name for %#v: %#v", cfg, name)
+		}
+		if strings.Contains(cfg, "kh2") {
+			if diff := cmp.Diff([]string{"~/.ssh/known_hosts", "~/kh2"}, files); diff != "" {
+				t.Fatalf("Incorrect known_hosts files:\n%s", diff)
+			}
+		}
+	}
+}
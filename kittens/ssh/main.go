@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"kitty/tools/cli"
+	"kitty/tools/sshconfig"
 	"kitty/tools/themes"
 	"kitty/tools/tty"
 	"kitty/tools/tui"
@@ -93,11 +94,14 @@ func add_cloned_env(val string) (ans map[string]string, err error) {
 	return ans, err
 }
 
+// The arguments of the kitten that can be mixed in with the arguments for ssh
+var kitten_extra_args = []string{"--kitten", "--record"}
+
 func parse_kitten_args(found_extra_args []string, username, hostname_for_match string) (overrides []string, literal_env map[string]string, ferr error) {
 	literal_env = make(map[string]string)
 	overrides = make([]string, 0, 4)
 	for i, a := range found_extra_args {
-		if i%2 == 0 {
+		if i%2 == 0 || found_extra_args[i-1] != "--kitten" {
 			continue
 		}
 		if key, val, found := strings.Cut(a, "="); found {
@@ -254,19 +258,21 @@ func serialize_env(cd *connection_data, get_local_env func(string) (string, bool
 
 func make_tarfile(cd *connection_data, get_local_env func(string) (string, bool)) ([]byte, error) {
 	env_script, ksi := serialize_env(cd, get_local_env)
+	copied, err := copied_files_data(cd.host_opts.Copy)
+	if err != nil {
+		return nil, err
+	}
 	w := bytes.Buffer{}
-	w.Grow(64 * 1024)
+	w.Grow(64*1024 + len(copied))
+	w.Write(copied)
 	gw, err := gzip.NewWriterLevel(&w, gzip.BestCompression)
 	if err != nil {
 		return nil, err
 	}
 	tw := tar.NewWriter(gw)
 	rd := strings.TrimRight(cd.host_opts.Remote_dir, "/")
-	seen := make(map[file_unique_id]string, 32)
 	add := func(h *tar.Header, data []byte) (err error) {
-		// some distro's like nix mess with installed file permissions so ensure
-		// files are at least readable and writable by owning user
-		h.Mode |= 0o600
+		fix_header_permissions(h)
 		err = tw.WriteHeader(h)
 		if err != nil {
 			return
@@ -279,12 +285,6 @@ func make_tarfile(cd *connection_data, get_local_env func(string) (string, bool)
 		}
 		return
 	}
-	for _, ci := range cd.host_opts.Copy {
-		err = ci.get_file_data(add, seen)
-		if err != nil {
-			return nil, err
-		}
-	}
 	type fe struct {
 		arcname string
 		data    []byte
@@ -356,6 +356,11 @@ func make_tarfile(cd *connection_data, get_local_env func(string) (string, bool)
 	if err == nil {
 		err = add_entries(path.Join("home", ".terminfo", "x"), shell_integration.Data()["terminfo/x/"+kitty.DefaultTermName])
 	}
+	if err == nil {
+		err = add_extra_terminfo(cd.host_opts.Terminfo, func(arcname string, data []byte) error { return add_data(fe{arcname, data}) }, func(msg string) {
+			fmt.Fprintln(os.Stderr, "Warning:", msg)
+		})
+	}
 	if err == nil {
 		err = tw.Close()
 		if err == nil {
@@ -605,6 +610,12 @@ func run_ssh(ssh_args, server_args, found_extra_args []string) (rc int, err erro
 	}()
 	cmd := append([]string{SSHExe()}, ssh_args...)
 	cd := connection_data{remote_args: server_args[1:]}
+	record_to := ""
+	for i := 0; i+1 < len(found_extra_args); i += 2 {
+		if found_extra_args[i] == "--record" {
+			record_to = found_extra_args[i+1]
+		}
+	}
 	hostname := server_args[0]
 	if len(cd.remote_args) == 0 {
 		cmd = append(cmd, "-t")
@@ -735,6 +746,7 @@ func run_ssh(ssh_args, server_args, found_extra_args []string) (rc int, err erro
 		restore_escape_codes += "\x1b[#Q"
 	}
 	sigs := make(chan os.Signal, 8)
+	resume_exit_signals := utils.SuspendExitSignalHandling()
 	signal.Notify(sigs, unix.SIGINT, unix.SIGTERM)
 	cleaned_up := false
 	cleanup := func() {
@@ -742,6 +754,7 @@ func run_ssh(ssh_args, server_args, found_extra_args []string) (rc int, err erro
 			_ = term.WriteAllString(restore_escape_codes)
 			term.RestoreAndClose()
 			signal.Reset()
+			resume_exit_signals()
 			cleaned_up = true
 		}
 	}
@@ -753,6 +766,24 @@ func run_ssh(ssh_args, server_args, found_extra_args []string) (rc int, err erro
 	cmd = append(cmd, cd.rcmd...)
 	c := exec.Command(cmd[0], cmd[1:]...)
 	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	var recorder *session_recorder
+	if record_to != "" {
+		if recorder, err = start_recording(record_to, term, os.Stdout, hostname); err != nil {
+			return 1, err
+		}
+		c.Stdout = recorder
+		// a background ControlMaster could keep the stdout pipe open
+		c.WaitDelay = time.Second
+	}
+	var hk_filter *host_key_filter
+	var host_key_first_seen func(name, fingerprint string) time.Time
+	if host_opts.Track_host_keys && tty.IsTerminal(os.Stderr.Fd()) {
+		host_key_first_seen = track_host_keys(cmd[:insertion_point], hostname)
+		hk_filter = &host_key_filter{dest: os.Stderr}
+		c.Stderr = hk_filter
+		// a background ControlMaster could keep the stderr pipe open
+		c.WaitDelay = time.Second
+	}
 	err = c.Start()
 	if err != nil {
 		return 1, err
@@ -781,6 +812,21 @@ func run_ssh(ssh_args, server_args, found_extra_args []string) (rc int, err erro
 	}()
 	err = c.Wait()
 	drain_potential_tty_garbage(term)
+	if recorder != nil {
+		if rerr := recorder.finish(); rerr != nil {
+			cleanup()
+			fmt.Fprintln(os.Stderr, rerr)
+		}
+	}
+	if hk_filter != nil {
+		if hc := hk_filter.Finish(); hc != nil {
+			cleanup()
+			hc.old_first_seen = host_key_first_seen(hc.host, hc.old_fingerprint)
+			return show_host_key_change(hc)
+		}
+		// record the key of the host if it was accepted when connecting
+		track_host_keys(cmd[:insertion_point], hostname)
+	}
 	if err != nil {
 		var exit_err *exec.ExitError
 		if errors.As(err, &exit_err) {
@@ -807,7 +853,7 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 			return
 		}
 	}
-	ssh_args, server_args, passthrough, found_extra_args, err := ParseSSHArgs(args, "--kitten")
+	ssh_args, server_args, passthrough, found_extra_args, err := ParseSSHArgs(args, kitten_extra_args...)
 	if err != nil {
 		var invargs *ErrInvalidSSHArgs
 		switch {
@@ -835,13 +881,41 @@ func EntryPoint(parent *cli.Command) {
 	create_cmd(parent, main)
 }
 
+// Complete host names from the SSH config and known_hosts files for the
+// destination, delegating everything else to the completion for ssh
+func complete_ssh_args(completions *cli.Completions, word string, arg_num int) {
+	if !strings.HasPrefix(word, "-") {
+		n := completions.CurrentWordIdxInParent - 1
+		if n >= 0 && n <= completions.CurrentWordIdx {
+			const sentinel = "\x00"
+			prev := slices.Clone(completions.AllWords[completions.CurrentWordIdx-n : completions.CurrentWordIdx])
+			if _, server_args, _, _, err := ParseSSHArgs(append(prev, sentinel), kitten_extra_args...); err == nil && len(server_args) == 1 && server_args[0] == sentinel {
+				user_prefix, host_prefix := "", word
+				if u, h, found := strings.Cut(word, "@"); found {
+					user_prefix, host_prefix = u+"@", h
+				}
+				mg := completions.AddMatchGroup("Hosts")
+				for _, h := range sshconfig.AllHostNames() {
+					if strings.HasPrefix(h, host_prefix) {
+						mg.AddMatch(user_prefix + h)
+					}
+				}
+				if len(mg.Matches) > 0 {
+					return
+				}
+			}
+		}
+	}
+	cli.CompletionForWrapper("ssh")(completions, word, arg_num)
+}
+
 func specialize_command(ssh *cli.Command) {
 	ssh.Usage = "arguments for the ssh command"
 	ssh.ShortDescription = "Truly convenient SSH"
 	ssh.HelpText = "The ssh kitten is a thin wrapper around the ssh command. It automatically enables shell integration on the remote host, re-uses existing connections to reduce latency, makes the kitty terminfo database available, etc. It's invocation is identical to the ssh command. For details on its usage, see :doc:`/kittens/ssh`."
 	ssh.IgnoreAllArgs = true
 	ssh.OnlyArgsAllowed = true
-	ssh.ArgCompleter = cli.CompletionForWrapper("ssh")
+	ssh.ArgCompleter = complete_ssh_args
 }
 
 func test_integration_with_python(args []string) (rc int, err error) {
@@ -851,7 +925,7 @@ func test_integration_with_python(args []string) (rc int, err error) {
 	}
 	defer func() {
 		f.Close()
-		os.Remove(f.Name())
+		_ = utils.RemoveAllSecure(f.Name())
 	}()
 	_, err = io.Copy(f, os.Stdin)
 	if err != nil {
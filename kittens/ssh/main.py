@@ -42,7 +42,9 @@ def option_text() -> str:
 or more directories. For example, to exclude a directory and everything under it use
 :code:`**/directory_name`.
 See the :link:`detailed syntax <https://github.com/bmatcuk/doublestar#patterns>` for
-how wildcards match.
+how wildcards match. Files matching the rules in :file:`.ignore` files, which use the
+:link:`gitignore syntax <https://git-scm.com/docs/gitignore>`, in the copied
+directories are also excluded, as are :file:`__pycache__` and :file:`.DS_Store`.
 
 
 --symlink-strategy
@@ -161,6 +163,17 @@ def option_text() -> str:
 in the .conf files/themes are ignored.
 ''')
 
+opt('terminfo', '', long_text='''
+A space separated list of additional terminal types, whose terminfo entries are
+copied from the local terminfo database to the remote host, along with the
+kitty terminfo. Useful if you set :envvar:`TERM` to something other than
+:code:`xterm-kitty`, for example, when running a terminal multiplexer on the
+remote host: :code:`terminfo tmux-256color foot`. The entries are decompiled
+with :program:`infocmp` and compiled on the remote host with :program:`tic`, if
+available, otherwise the locally compiled entries are used. Terminal types that
+are not found in the local terminfo database are ignored with a warning.
+''')
+
 opt('remote_kitty', 'if-needed', choices=('if-needed', 'no', 'yes'), long_text='''
 Make :program:`kitten` available on the remote host. Useful to run kittens such
 as the :doc:`icat kitten </kittens/icat>` to display images or the
@@ -202,6 +215,15 @@ def option_text() -> str:
 latency.
 ''')
 
+opt('track_host_keys', 'no', option_type='to_bool', long_text='''
+Record when the keys of hosts are first seen, in the kitty cache directory, as
+OpenSSH does not. Then, when SSH refuses to connect because the key of a host
+has changed, the change is explained in a readable form, showing the previously
+known and new keys and when the previously known key was first seen, with the
+option to remove the previously known key. Note that this works by filtering
+the error output of SSH.
+''')
+
 opt('delegate', '', long_text='''
 Do not use the SSH kitten for this host. Instead run the command specified as the delegate.
 For example using :code:`delegate ssh` will run the ssh command with all arguments passed
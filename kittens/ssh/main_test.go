@@ -3,11 +3,13 @@
 package ssh
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"kitty"
+	"kitty/tools/utils"
 	"kitty/tools/utils/shm"
 	"os"
 	"os/exec"
@@ -154,3 +156,52 @@ func TestSSHTarfile(t *testing.T) {
 		t.Fatalf("Contents of shell-integration/ssh not excluded")
 	}
 }
+
+func TestSSHCopyCache(t *testing.T) {
+	tdir := t.TempDir()
+	src := filepath.Join(tdir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0o755)
+	os.WriteFile(filepath.Join(src, "sub", "a"), []byte("a"), 0o644)
+	orig_dir, orig_fingerprints := copy_cache_dir, copy_fingerprints
+	defer func() { copy_cache_dir, copy_fingerprints = orig_dir, orig_fingerprints }()
+	cache_dir := filepath.Join(tdir, "cache")
+	copy_cache_dir = func() string { return cache_dir }
+	copy_fingerprints = func() *utils.FingerprintStore {
+		return utils.NewFingerprintStore(filepath.Join(tdir, "fingerprints.json"))
+	}
+	cd := basic_connection_data("copy --dest=copied " + src)
+	num_cached := func() int {
+		entries, _ := os.ReadDir(cache_dir)
+		return len(entries)
+	}
+	extract := func() string {
+		data, err := make_tarfile(cd, func(key string) (val string, found bool) { return })
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest := t.TempDir()
+		cmd := exec.Command("tar", "xpzf", "-", "-C", dest)
+		cmd.Stdin = bytes.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to extract tarfile: %s %s", err, out)
+		}
+		if _, err := os.Stat(filepath.Join(dest, "data.sh")); err != nil {
+			t.Fatal(err)
+		}
+		ans, err := os.ReadFile(filepath.Join(dest, "home", "copied", "sub", "a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(ans)
+	}
+	if x := extract(); x != "a" || num_cached() != 1 {
+		t.Fatalf("Incorrect copied file: %#v or number of cached entries: %d", x, num_cached())
+	}
+	if x := extract(); x != "a" || num_cached() != 1 {
+		t.Fatalf("Incorrect copied file: %#v or number of cached entries: %d", x, num_cached())
+	}
+	os.WriteFile(filepath.Join(src, "sub", "a"), []byte("changed"), 0o644)
+	if x := extract(); x != "changed" || num_cached() != 2 {
+		t.Fatalf("Incorrect copied file: %#v or number of cached entries: %d", x, num_cached())
+	}
+}
@@ -0,0 +1,97 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"kitty/tools/asciicast"
+	"kitty/tools/tty"
+)
+
+var _ = fmt.Print
+
+// Writes to the terminal and records what was written. A failure to record
+// must not break the session, so it is only reported once the session ends.
+type session_recorder struct {
+	dest io.Writer
+	rec  *asciicast.Writer
+	file *os.File
+
+	mutex    sync.Mutex
+	err      error
+	on_winch chan os.Signal
+}
+
+func (self *session_recorder) failed(err error) {
+	self.mutex.Lock()
+	if self.err == nil {
+		self.err = err
+	}
+	self.mutex.Unlock()
+}
+
+func (self *session_recorder) Write(p []byte) (int, error) {
+	n, err := self.dest.Write(p)
+	if n > 0 {
+		if _, rerr := self.rec.Write(p[:n]); rerr != nil {
+			self.failed(rerr)
+		}
+	}
+	return n, err
+}
+
+// Record the output of the session to path in the asciicast v2 format,
+// including changes to the size of term
+func start_recording(path string, term *tty.Term, dest io.Writer, title string) (*session_recorder, error) {
+	sz, err := term.GetSize()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the file to record the session to with error: %w", err)
+	}
+	h := asciicast.Header{Width: int(sz.Col), Height: int(sz.Row), Title: title, Env: map[string]string{"TERM": os.Getenv("TERM")}}
+	rec, err := asciicast.NewWriter(f, h)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ans := &session_recorder{dest: dest, rec: rec, file: f, on_winch: make(chan os.Signal, 8)}
+	signal.Notify(ans.on_winch, unix.SIGWINCH)
+	go func() {
+		for range ans.on_winch {
+			if sz, err := term.GetSize(); err == nil {
+				if err = rec.Resize(int(sz.Col), int(sz.Row)); err != nil {
+					ans.failed(err)
+				}
+			}
+		}
+	}()
+	return ans, nil
+}
+
+// Stop recording, returning any error that occurred while recording
+func (self *session_recorder) finish() error {
+	signal.Stop(self.on_winch)
+	close(self.on_winch)
+	if err := self.rec.Close(); err != nil {
+		self.failed(err)
+	}
+	if err := self.file.Close(); err != nil {
+		self.failed(err)
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.err != nil {
+		return fmt.Errorf("Failed to record the session to %s with error: %w", self.file.Name(), self.err)
+	}
+	return nil
+}
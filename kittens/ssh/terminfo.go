@@ -0,0 +1,89 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"kitty"
+	"kitty/tools/tui/shell_integration"
+)
+
+var _ = fmt.Print
+
+type terminfo_entry struct {
+	name string
+	// the entry as decompiled by infocmp, if available
+	source []byte
+	// the entry from the local compiled database, if available
+	compiled []byte
+}
+
+var decompile_terminfo = func(name string) ([]byte, error) {
+	return exec.Command("infocmp", "-x", name).Output()
+}
+
+// Get the terminfo entries for the terminal types specified in the terminfo
+// option from the local terminfo database. Terminal types that are invalid or
+// not found are reported via warn and skipped, so that they do not prevent
+// connecting to the remote host.
+func terminfo_entries(spec string, warn func(string)) (ans []terminfo_entry) {
+	seen := map[string]bool{kitty.DefaultTermName: true}
+	for _, name := range strings.Fields(spec) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+			warn(fmt.Sprintf("Ignoring invalid terminal type in the terminfo option: %#v", name))
+			continue
+		}
+		e := terminfo_entry{name: name}
+		if path := shell_integration.PathToTerminfoDb(name); path != "" {
+			if data, err := os.ReadFile(path); err == nil {
+				e.compiled = data
+			} else {
+				warn(fmt.Sprintf("Failed to read the terminfo entry for %s from %s with error: %s", name, path, err))
+			}
+		}
+		if src, err := decompile_terminfo(name); err == nil && len(src) > 0 {
+			e.source = src
+		}
+		if e.source == nil && e.compiled == nil {
+			warn(fmt.Sprintf("The terminfo entry for %#v was not found in the local terminfo database, it will not be copied to the remote host", name))
+			continue
+		}
+		ans = append(ans, e)
+	}
+	return
+}
+
+// Add the extra terminfo entries to the data sent to the remote host. The
+// compiled entries are placed in both the directory layouts used by ncurses,
+// the decompiled ones are compiled by the bootstrap script, if tic is
+// available on the remote host, overwriting the compiled entries.
+func add_extra_terminfo(spec string, add func(arcname string, data []byte) error, warn func(string)) (err error) {
+	source := bytes.Buffer{}
+	for _, e := range terminfo_entries(spec, warn) {
+		if e.compiled != nil {
+			for _, dir := range []string{e.name[:1], fmt.Sprintf("%x", e.name[0])} {
+				if err = add(path.Join("home", ".terminfo", dir, e.name), e.compiled); err != nil {
+					return err
+				}
+			}
+		}
+		if e.source != nil {
+			source.Write(e.source)
+			source.WriteByte('\n')
+		}
+	}
+	if source.Len() > 0 {
+		return add(path.Join("home", ".terminfo", "extra.terminfo"), source.Bytes())
+	}
+	return nil
+}
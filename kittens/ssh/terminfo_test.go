@@ -0,0 +1,69 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestSSHExtraTerminfo(t *testing.T) {
+	tdir := t.TempDir()
+	t.Setenv("TERMINFO", tdir)
+	if err := os.MkdirAll(filepath.Join(tdir, "t"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tdir, "t", "test-compiled"), []byte("compiled"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	orig := decompile_terminfo
+	defer func() { decompile_terminfo = orig }()
+	decompile_terminfo = func(name string) ([]byte, error) {
+		if name == "test-source" || name == "test-compiled" {
+			return []byte(name + "|source,"), nil
+		}
+		return nil, fmt.Errorf("not found")
+	}
+	added := map[string]string{}
+	add := func(arcname string, data []byte) error {
+		added[arcname] = string(data)
+		return nil
+	}
+	var warnings []string
+	warn := func(msg string) { warnings = append(warnings, msg) }
+	if err := add_extra_terminfo("xterm-kitty test-compiled test-source test-source", add, warn); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"home/.terminfo/t/test-compiled":  "compiled",
+		"home/.terminfo/74/test-compiled": "compiled",
+		"home/.terminfo/extra.terminfo":   "test-compiled|source,\ntest-source|source,\n",
+	}
+	if diff := cmp.Diff(expected, added); diff != "" {
+		t.Fatalf("Incorrect terminfo data:\n%s", diff)
+	}
+	if err := add_extra_terminfo("", add, warn); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Fatalf("Unexpected warnings: %#v", warnings)
+	}
+	// missing and invalid terminal types are skipped with a warning rather
+	// than preventing the connection
+	clear(added)
+	if err := add_extra_terminfo("test-missing ../evil . test-source", add, warn); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("Incorrect warnings: %#v", warnings)
+	}
+	if diff := cmp.Diff(map[string]string{"home/.terminfo/extra.terminfo": "test-source|source,\n"}, added); diff != "" {
+		t.Fatalf("Incorrect terminfo data:\n%s", diff)
+	}
+}
@@ -0,0 +1,254 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package switch_kitten
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"kitty/tools/tui/subseq"
+)
+
+var _ = fmt.Print
+
+type ProcessDesc struct {
+	Pid     int      `json:"pid"`
+	Cwd     string   `json:"cwd"`
+	Cmdline []string `json:"cmdline"`
+}
+
+type WindowDesc struct {
+	Id                  int           `json:"id"`
+	Pid                 int           `json:"pid"`
+	Title               string        `json:"title"`
+	Cwd                 string        `json:"cwd"`
+	IsActive            bool          `json:"is_active"`
+	IsSelf              bool          `json:"is_self"`
+	AtPrompt            bool          `json:"at_prompt"`
+	LastFocusedAt       int64         `json:"last_focused_at"`
+	ForegroundProcesses []ProcessDesc `json:"foreground_processes"`
+}
+
+type TabDesc struct {
+	Id       int          `json:"id"`
+	Title    string       `json:"title"`
+	IsActive bool         `json:"is_active"`
+	Windows  []WindowDesc `json:"windows"`
+}
+
+type OSWindowDesc struct {
+	Id       int       `json:"id"`
+	IsActive bool      `json:"is_active"`
+	Tabs     []TabDesc `json:"tabs"`
+}
+
+type Kind int
+
+const (
+	OS_WINDOW Kind = iota
+	TAB
+	WINDOW
+)
+
+func (self Kind) String() string {
+	switch self {
+	case OS_WINDOW:
+		return "os_window"
+	case TAB:
+		return "tab"
+	}
+	return "window"
+}
+
+type Item struct {
+	Kind  Kind
+	Id    int
+	Depth int
+	Title string
+	Cwd   string
+	// The command running in the foreground of a window
+	Command    string
+	LastActive time.Time
+	// Whether this is the window the kitten was run from or the tab or OS window containing it
+	IsCurrent bool
+	// The window to focus when this item is chosen
+	FocusWindowId int
+	// The tabs to close when this item is closed
+	TabIds []int
+}
+
+func (self *Item) search_text() string {
+	return strings.Join([]string{self.Title, self.Cwd, self.Command}, " ")
+}
+
+type Model struct {
+	os_windows []OSWindowDesc
+	sort_by    string
+	home       string
+	own_pid    int
+}
+
+func NewModel(ls_output []byte, sort_by string) (*Model, error) {
+	ans := Model{sort_by: sort_by, own_pid: os.Getpid()}
+	if err := json.Unmarshal(ls_output, &ans.os_windows); err != nil {
+		return nil, fmt.Errorf("Failed to parse the list of windows with error: %w", err)
+	}
+	ans.home, _ = os.UserHomeDir()
+	return &ans, nil
+}
+
+func (self *Model) shorten_path(path string) string {
+	if self.home != "" {
+		if rel, err := filepath.Rel(self.home, path); err == nil && !strings.HasPrefix(rel, "..") {
+			if rel == "." {
+				return "~"
+			}
+			return "~" + string(os.PathSeparator) + rel
+		}
+	}
+	return path
+}
+
+func (self *Model) window_item(w *WindowDesc, depth int) *Item {
+	ans := Item{Kind: WINDOW, Id: w.Id, Depth: depth, Title: w.Title, Cwd: w.Cwd, IsCurrent: w.IsSelf, FocusWindowId: w.Id}
+	if w.LastFocusedAt > 0 {
+		ans.LastActive = time.Unix(0, w.LastFocusedAt)
+	}
+	if !w.AtPrompt && len(w.ForegroundProcesses) > 0 {
+		// use the most recently started foreground process, the same heuristic kitty uses
+		p := slices.MaxFunc(w.ForegroundProcesses, func(a, b ProcessDesc) int { return a.Pid - b.Pid })
+		ans.Command = strings.Join(p.Cmdline, " ")
+		if p.Cwd != "" {
+			ans.Cwd = p.Cwd
+		}
+	}
+	ans.Cwd = self.shorten_path(ans.Cwd)
+	return &ans
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// The list of items to display when there is no search query
+func (self *Model) Items() (ans []*Item) {
+	for i := range self.os_windows {
+		osw := &self.os_windows[i]
+		oi := &Item{Kind: OS_WINDOW, Id: osw.Id, Title: fmt.Sprintf("OS Window %d", i+1)}
+		if self.sort_by == "tree" {
+			ans = append(ans, oi)
+		}
+		for t := range osw.Tabs {
+			tab := &osw.Tabs[t]
+			ti := &Item{Kind: TAB, Id: tab.Id, Depth: 1, Title: tab.Title}
+			oi.TabIds = append(oi.TabIds, tab.Id)
+			if self.sort_by == "tree" {
+				ans = append(ans, ti)
+			}
+			for w := range tab.Windows {
+				if tab.Windows[w].Pid == self.own_pid {
+					continue // the window this kitten is running in
+				}
+				wi := self.window_item(&tab.Windows[w], 2)
+				if tab.Windows[w].IsActive || ti.FocusWindowId == 0 {
+					ti.FocusWindowId = wi.Id
+					if tab.IsActive || oi.FocusWindowId == 0 {
+						oi.FocusWindowId = wi.Id
+					}
+				}
+				ti.LastActive, oi.LastActive = later(ti.LastActive, wi.LastActive), later(oi.LastActive, wi.LastActive)
+				ti.IsCurrent = ti.IsCurrent || wi.IsCurrent
+				oi.IsCurrent = oi.IsCurrent || wi.IsCurrent
+				ans = append(ans, wi)
+			}
+		}
+	}
+	if self.sort_by != "tree" {
+		slices.SortStableFunc(ans, func(a, b *Item) int { return b.LastActive.Compare(a.LastActive) })
+		for _, x := range ans {
+			x.Depth = 0
+		}
+	}
+	return
+}
+
+// The windows and tabs matching the specified query, best matches first
+func (self *Model) Search(query string) (ans []*Item) {
+	candidates := []*Item{}
+	for _, x := range self.Items() {
+		if x.Kind != OS_WINDOW {
+			candidates = append(candidates, x)
+		}
+	}
+	texts := make([]string, len(candidates))
+	for i, x := range candidates {
+		texts[i] = x.search_text()
+	}
+	matches := subseq.NewMatcher(texts, subseq.Options{}).Score(query)
+	type scored struct {
+		item  *Item
+		score float64
+	}
+	results := make([]scored, 0, len(matches))
+	for i, m := range matches {
+		if m.Score > 0 {
+			results = append(results, scored{candidates[i], m.Score})
+		}
+	}
+	slices.SortStableFunc(results, func(a, b scored) int {
+		if a.score != b.score {
+			if a.score > b.score {
+				return -1
+			}
+			return 1
+		}
+		return b.item.LastActive.Compare(a.item.LastActive)
+	})
+	ans = make([]*Item, len(results))
+	for i, x := range results {
+		ans[i] = x.item
+		x.item.Depth = 0
+	}
+	return
+}
+
+// Remove the specified item from the model, removing tabs and OS windows that become empty
+func (self *Model) Remove(kind Kind, id int) {
+	for i := range self.os_windows {
+		osw := &self.os_windows[i]
+		if kind == WINDOW {
+			for t := range osw.Tabs {
+				tab := &osw.Tabs[t]
+				tab.Windows = slices.DeleteFunc(tab.Windows, func(w WindowDesc) bool { return w.Id == id })
+			}
+		}
+		osw.Tabs = slices.DeleteFunc(osw.Tabs, func(tab TabDesc) bool { return (kind == TAB && tab.Id == id) || len(tab.Windows) == 0 })
+	}
+	self.os_windows = slices.DeleteFunc(self.os_windows, func(osw OSWindowDesc) bool {
+		return (kind == OS_WINDOW && osw.Id == id) || len(osw.Tabs) == 0
+	})
+}
+
+func (self *Model) Rename(kind Kind, id int, title string) {
+	for i := range self.os_windows {
+		for t := range self.os_windows[i].Tabs {
+			tab := &self.os_windows[i].Tabs[t]
+			if kind == TAB && tab.Id == id {
+				tab.Title = title
+			}
+			for w := range tab.Windows {
+				if kind == WINDOW && tab.Windows[w].Id == id {
+					tab.Windows[w].Title = title
+				}
+			}
+		}
+	}
+}
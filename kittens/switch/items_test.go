@@ -0,0 +1,64 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package switch_kitten
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+const sample_ls = `[
+{"id": 1, "is_active": true, "tabs": [
+	{"id": 1, "title": "editing", "is_active": true, "windows": [
+		{"id": 1, "title": "vim", "cwd": "/src/kitty", "is_active": true, "last_focused_at": 3000,
+		 "foreground_processes": [{"pid": 10, "cwd": "/src", "cmdline": ["zsh"]}, {"pid": 11, "cwd": "/src/kitty", "cmdline": ["vim", "main.go"]}]},
+		{"id": 2, "title": "shell", "cwd": "/tmp", "at_prompt": true, "is_self": true, "last_focused_at": 5000}
+	]},
+	{"id": 2, "title": "logs", "windows": [
+		{"id": 3, "title": "tail", "cwd": "/var/log", "is_active": true, "last_focused_at": 1000}
+	]}
+]}]`
+
+func TestSwitchModel(t *testing.T) {
+	m, err := NewModel([]byte(sample_ls), "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.home = "/src"
+	summarize := func(items []*Item) (ans []string) {
+		for _, x := range items {
+			ans = append(ans, fmt.Sprintf("%s:%d:%d:%s:%s", x.Kind, x.Id, x.FocusWindowId, x.Cwd, x.Command))
+		}
+		return
+	}
+	items := m.Items()
+	if diff := cmp.Diff([]string{
+		"os_window:1:1::", "tab:1:1::", "window:1:1:~/kitty:vim main.go", "window:2:2:/tmp:", "tab:2:3::", "window:3:3:/var/log:",
+	}, summarize(items)); diff != "" {
+		t.Fatalf("Incorrect tree of items:\n%s", diff)
+	}
+	if !items[0].IsCurrent || !items[1].IsCurrent || items[4].IsCurrent {
+		t.Fatalf("Current window not propagated to its tab and OS window correctly")
+	}
+	m.sort_by = "activity"
+	if diff := cmp.Diff([]string{"window:2:2:/tmp:", "window:1:1:~/kitty:vim main.go", "window:3:3:/var/log:"}, summarize(m.Items())); diff != "" {
+		t.Fatalf("Incorrect sorting by activity:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"window:1:1:~/kitty:vim main.go"}, summarize(m.Search("vimmain"))); diff != "" {
+		t.Fatalf("Incorrect search results:\n%s", diff)
+	}
+	m.sort_by = "tree"
+	m.Rename(TAB, 2, "renamed")
+	m.Remove(WINDOW, 2)
+	m.Remove(WINDOW, 1)
+	if diff := cmp.Diff([]string{"os_window:1:3::", "tab:2:3::", "window:3:3:/var/log:"}, summarize(m.Items())); diff != "" {
+		t.Fatalf("Incorrect items after removal:\n%s", diff)
+	}
+	if m.Items()[1].Title != "renamed" {
+		t.Fatalf("Tab was not renamed")
+	}
+}
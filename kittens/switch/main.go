@@ -0,0 +1,117 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package switch_kitten
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"kitty/tools/cli"
+	"kitty/tools/tui"
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+type Result struct {
+	Actions []Action `json:"actions"`
+}
+
+func run_remote_control(args ...string) ([]byte, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, append([]string{"@"}, args...)...)
+	cmd.Stderr = os.Stderr
+	ans, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run the remote control command %s with error: %w", args[0], err)
+	}
+	return ans, nil
+}
+
+// Perform the actions using remote control, used when not running as an overlay inside kitty
+func perform_actions(actions []Action) error {
+	for _, a := range actions {
+		match := "id:" + strconv.Itoa(a.Id)
+		var args []string
+		switch a.Action {
+		case "focus":
+			args = []string{"focus-" + a.Kind, "--match", match}
+		case "close":
+			args = []string{"close-" + a.Kind, "--match", match}
+		case "rename":
+			args = []string{"set-" + a.Kind + "-title", "--match", match, a.Title}
+		}
+		if _, err := run_remote_control(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if len(args) > 0 {
+		return 1, fmt.Errorf("Unknown extra arguments: %v", args)
+	}
+	var raw []byte
+	if tui.RunningAsUI() {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = run_remote_control("ls")
+	}
+	if err != nil {
+		return 1, err
+	}
+	model, err := NewModel(raw, opts.SortBy)
+	if err != nil {
+		return 1, err
+	}
+	output := tui.KittenOutputSerializer()
+	lp, err := loop.New()
+	if err != nil {
+		return 1, err
+	}
+	h := new_handler(lp, model)
+	lp.OnInitialize = h.initialize
+	lp.OnFinalize = h.finalize
+	lp.OnKeyEvent = h.on_key_event
+	lp.OnText = h.on_text
+	lp.OnResize = func(_, _ loop.ScreenSize) error {
+		h.draw_screen()
+		return nil
+	}
+	err = lp.Run()
+	if err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	if len(h.actions) == 0 {
+		return lp.ExitCode(), nil
+	}
+	if !tui.RunningAsUI() {
+		if err = perform_actions(h.actions); err != nil {
+			return 1, err
+		}
+		return
+	}
+	o, err := output(Result{Actions: h.actions})
+	if err != nil {
+		return 1, err
+	}
+	fmt.Println(o)
+	return
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,66 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import Any, Dict, List
+
+from kitty.typing import BossType
+
+from ..tui.handler import result_handler
+
+help_text = '''\
+Switch between windows and tabs using a fuzzy-searchable list of all OS windows, tabs and windows, showing
+the working directory and running program of each window, along with when it was last active. Once the
+kitten is running, you can also rename and close windows and tabs from it.
+'''
+usage = ''
+OPTIONS = r'''
+--sort-by
+type=choices
+default=tree
+choices=tree,activity
+How to sort the list of windows when no search query is entered. :code:`tree` shows OS windows,
+tabs and windows in the same order as kitty does. :code:`activity` shows windows
+sorted by when they were last focused, most recent first.
+'''.format
+
+
+@result_handler(type_of_input='window-list', has_ready_notification=True)
+def handle_result(args: List[str], data: Dict[str, Any], target_window_id: int, boss: BossType) -> None:
+    for action in data.get('actions', ()):
+        kind, item_id, which = action['kind'], action['id'], action['action']
+        if kind == 'tab':
+            tab = boss.tab_for_id(item_id)
+            if tab is None:
+                continue
+            if which == 'focus':
+                if tab.active_window is not None:
+                    boss.set_active_window(tab.active_window, switch_os_window_if_needed=True)
+            elif which == 'close':
+                boss.confirm_tab_close(tab)
+            elif which == 'rename':
+                tab.set_title(action['title'])
+        else:
+            w = boss.window_id_map.get(item_id)
+            if w is None:
+                continue
+            if which == 'focus':
+                boss.set_active_window(w, switch_os_window_if_needed=True)
+            elif which == 'close':
+                boss.mark_window_for_close(w)
+            elif which == 'rename':
+                w.set_title(action['title'])
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten switch')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Switch between windows and tabs'
@@ -0,0 +1,312 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package switch_kitten
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/tui/readline"
+	"kitty/tools/utils/humanize"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type State int
+
+const (
+	SEARCHING State = iota
+	RENAMING
+	CONFIRMING_CLOSE
+)
+
+type Action struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Id     int    `json:"id"`
+	Title  string `json:"title,omitempty"`
+}
+
+type handler struct {
+	lp    *loop.Loop
+	model *Model
+
+	state         State
+	search_rl     *readline.Readline
+	rename_rl     *readline.Readline
+	items         []*Item
+	current_idx   int
+	scroll_offset int
+	actions       []Action
+	screen_width  int
+	screen_height int
+}
+
+func new_handler(lp *loop.Loop, model *Model) *handler {
+	ans := handler{lp: lp, model: model}
+	ans.search_rl = readline.New(lp, readline.RlInit{DontMarkPrompts: true, Prompt: "> "})
+	ans.rename_rl = readline.New(lp, readline.RlInit{DontMarkPrompts: true, Prompt: "New title: "})
+	return &ans
+}
+
+func (self *handler) initialize() (string, error) {
+	self.lp.SetWindowTitle("Switch windows")
+	self.lp.AllowLineWrapping(false)
+	self.update_items()
+	// start on the item for the window the kitten was run from
+	for i, x := range self.items {
+		if x.Kind == WINDOW && x.IsCurrent {
+			self.current_idx = i
+			break
+		}
+	}
+	self.draw_screen()
+	self.lp.SendOverlayReady()
+	return "", nil
+}
+
+func (self *handler) finalize() string {
+	self.search_rl.Shutdown()
+	self.rename_rl.Shutdown()
+	return ""
+}
+
+func (self *handler) update_items() {
+	if q := self.search_rl.AllText(); q != "" {
+		self.items = self.model.Search(q)
+	} else {
+		self.items = self.model.Items()
+	}
+	self.current_idx = max(0, min(self.current_idx, len(self.items)-1))
+}
+
+func (self *handler) current_item() *Item {
+	if self.current_idx < len(self.items) {
+		return self.items[self.current_idx]
+	}
+	return nil
+}
+
+func (self *handler) list_height() int {
+	return max(1, self.screen_height-2)
+}
+
+func (self *handler) format_item(x *Item, is_current bool) string {
+	indent := strings.Repeat("  ", x.Depth)
+	title := x.Title
+	if x.Kind == TAB {
+		title = "Tab: " + title
+	}
+	title_width := max(16, self.screen_width*2/5)
+	title = wcswidth.TruncateToVisualLength(indent+title, title_width-1)
+	title += strings.Repeat(" ", max(0, title_width-wcswidth.Stringwidth(title)))
+	details := []string{}
+	if x.Cwd != "" {
+		details = append(details, x.Cwd)
+	}
+	if x.Command != "" {
+		details = append(details, x.Command)
+	}
+	if !x.LastActive.IsZero() {
+		details = append(details, humanize.Time(x.LastActive))
+	}
+	d := wcswidth.TruncateToVisualLength(strings.Join(details, "  "), max(0, self.screen_width-title_width))
+	switch {
+	case is_current:
+		return self.lp.SprintStyled("reverse", title+d+strings.Repeat(" ", max(0, self.screen_width-title_width-wcswidth.Stringwidth(d))))
+	case x.Kind != WINDOW:
+		return self.lp.SprintStyled("bold", title) + self.lp.SprintStyled("dim", d)
+	case x.IsCurrent:
+		return self.lp.SprintStyled("fg=green", title) + self.lp.SprintStyled("dim", d)
+	}
+	return title + self.lp.SprintStyled("dim", d)
+}
+
+// The readline prompts clear to the end of the screen so they are drawn on the last line
+func (self *handler) draw_footer() {
+	self.lp.MoveCursorTo(1, self.screen_height-1)
+	self.lp.QueueWriteString(self.lp.SprintStyled("dim", "↑↓ move  Enter focus  F2 rename  F8 close  Esc quit"))
+	self.lp.MoveCursorTo(1, self.screen_height)
+	self.lp.ClearToEndOfLine()
+	switch self.state {
+	case SEARCHING:
+		self.search_rl.RedrawNonAtomic()
+	case RENAMING:
+		self.rename_rl.RedrawNonAtomic()
+	case CONFIRMING_CLOSE:
+		if x := self.current_item(); x != nil {
+			self.lp.QueueWriteString(fmt.Sprintf("Close %s %s? [y/n]", strings.ReplaceAll(x.Kind.String(), "_", " "), self.lp.SprintStyled("italic", x.Title)))
+		}
+	}
+}
+
+func (self *handler) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	self.lp.ClearScreen()
+	if sz, err := self.lp.ScreenSize(); err == nil {
+		self.screen_width, self.screen_height = int(sz.WidthCells), int(sz.HeightCells)
+	}
+	h := self.list_height()
+	if self.current_idx < self.scroll_offset {
+		self.scroll_offset = self.current_idx
+	} else if self.current_idx >= self.scroll_offset+h {
+		self.scroll_offset = self.current_idx - h + 1
+	}
+	if len(self.items) == 0 {
+		self.lp.QueueWriteString(self.lp.SprintStyled("dim", "No matching windows"))
+	}
+	for i := self.scroll_offset; i < min(len(self.items), self.scroll_offset+h); i++ {
+		self.lp.QueueWriteString(self.format_item(self.items[i], i == self.current_idx))
+		self.lp.Println()
+	}
+	self.draw_footer()
+	self.lp.SetCursorVisible(self.state != CONFIRMING_CLOSE)
+}
+
+func (self *handler) move(delta int) {
+	if len(self.items) == 0 {
+		self.lp.Beep()
+		return
+	}
+	self.current_idx = max(0, min(self.current_idx+delta, len(self.items)-1))
+	self.draw_screen()
+}
+
+func (self *handler) focus_current() {
+	if x := self.current_item(); x != nil && x.FocusWindowId > 0 {
+		self.actions = append(self.actions, Action{Action: "focus", Kind: "window", Id: x.FocusWindowId})
+		self.lp.Quit(0)
+	} else {
+		self.lp.Beep()
+	}
+}
+
+func (self *handler) close_current() {
+	x := self.current_item()
+	if x == nil {
+		return
+	}
+	switch x.Kind {
+	case OS_WINDOW:
+		for _, tid := range x.TabIds {
+			self.actions = append(self.actions, Action{Action: "close", Kind: "tab", Id: tid})
+		}
+	default:
+		self.actions = append(self.actions, Action{Action: "close", Kind: x.Kind.String(), Id: x.Id})
+	}
+	self.model.Remove(x.Kind, x.Id)
+	self.update_items()
+}
+
+func (self *handler) rename_current(title string) {
+	x := self.current_item()
+	if x == nil {
+		return
+	}
+	self.actions = append(self.actions, Action{Action: "rename", Kind: x.Kind.String(), Id: x.Id, Title: title})
+	self.model.Rename(x.Kind, x.Id, title)
+	self.update_items()
+}
+
+func (self *handler) on_text(text string, from_key_event, in_bracketed_paste bool) error {
+	switch self.state {
+	case SEARCHING:
+		if err := self.search_rl.OnText(text, from_key_event, in_bracketed_paste); err != nil {
+			return err
+		}
+		self.current_idx = 0
+		self.update_items()
+	case RENAMING:
+		if err := self.rename_rl.OnText(text, from_key_event, in_bracketed_paste); err != nil {
+			return err
+		}
+	case CONFIRMING_CLOSE:
+		if strings.ToLower(text) == "y" {
+			self.close_current()
+		}
+		self.state = SEARCHING
+	}
+	self.draw_screen()
+	return nil
+}
+
+func (self *handler) on_renaming_key_event(ev *loop.KeyEvent) error {
+	switch {
+	case ev.MatchesPressOrRepeat("esc"):
+		ev.Handled = true
+		self.state = SEARCHING
+	case ev.MatchesPressOrRepeat("enter"):
+		ev.Handled = true
+		self.state = SEARCHING
+		self.rename_current(self.rename_rl.AllText())
+	default:
+		if err := self.rename_rl.OnKeyEvent(ev); err != nil {
+			return err
+		}
+	}
+	self.draw_screen()
+	return nil
+}
+
+func (self *handler) on_key_event(ev *loop.KeyEvent) error {
+	switch self.state {
+	case RENAMING:
+		return self.on_renaming_key_event(ev)
+	case CONFIRMING_CLOSE:
+		if ev.MatchesPressOrRepeat("esc") || ev.MatchesPressOrRepeat("enter") {
+			ev.Handled = true
+			self.state = SEARCHING
+			self.draw_screen()
+		}
+		return nil
+	}
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc"):
+		if self.search_rl.AllText() != "" {
+			self.search_rl.ResetText()
+			self.update_items()
+			self.draw_screen()
+		} else {
+			self.lp.Quit(0)
+		}
+	case ev.MatchesPressOrRepeat("enter"):
+		self.focus_current()
+	case ev.MatchesPressOrRepeat("up") || ev.MatchesPressOrRepeat("ctrl+p"):
+		self.move(-1)
+	case ev.MatchesPressOrRepeat("down") || ev.MatchesPressOrRepeat("ctrl+n"):
+		self.move(1)
+	case ev.MatchesPressOrRepeat("page_up"):
+		self.move(-self.list_height())
+	case ev.MatchesPressOrRepeat("page_down"):
+		self.move(self.list_height())
+	case ev.MatchesPressOrRepeat("f2"):
+		if x := self.current_item(); x != nil && x.Kind != OS_WINDOW {
+			self.state = RENAMING
+			self.rename_rl.SetText(x.Title)
+			self.draw_screen()
+		} else {
+			self.lp.Beep()
+		}
+	case ev.MatchesPressOrRepeat("f8"):
+		if self.current_item() != nil {
+			self.state = CONFIRMING_CLOSE
+			self.draw_screen()
+		} else {
+			self.lp.Beep()
+		}
+	default:
+		ev.Handled = false
+		if err := self.search_rl.OnKeyEvent(ev); err != nil {
+			return err
+		}
+		if ev.Handled {
+			self.update_items()
+			self.draw_screen()
+		}
+	}
+	return nil
+}
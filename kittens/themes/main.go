@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -43,6 +44,10 @@ func non_interactive(opts *Options, theme_name string) (rc int, err error) {
 			return 1, err
 		}
 		fmt.Println(code)
+	} else if applies_to_matched_windows(opts) {
+		if err = apply_to_matched_windows(opts, theme); err != nil {
+			return 1, err
+		}
 	} else {
 		err = theme.SaveInConf(utils.ConfigDir(), opts.ReloadIn, opts.ConfigFileName)
 		if err != nil {
@@ -52,6 +57,50 @@ func non_interactive(opts *Options, theme_name string) (rc int, err error) {
 	return
 }
 
+func applies_to_matched_windows(opts *Options) bool {
+	return opts.Match != "" || opts.MatchTab != ""
+}
+
+// Apply the theme live to the windows matched by --match or --match-tab
+func apply_to_matched_windows(opts *Options, theme *themes.Theme) (err error) {
+	code, err := theme.Code()
+	if err != nil {
+		return err
+	}
+	return apply_code_to_matched_windows(opts, theme.Name(), code)
+}
+
+// Must not be called while a TUI loop is running, as kitten @ uses the terminal
+func apply_code_to_matched_windows(opts *Options, name, code string) (err error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "kitty-theme-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(code)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	args := []string{"@", "set-colors"}
+	if opts.Match != "" {
+		args = append(args, "--match", opts.Match)
+	}
+	if opts.MatchTab != "" {
+		args = append(args, "--match-tab", opts.MatchTab)
+	}
+	cmd := exec.Command(exe, append(args, f.Name())...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("Failed to apply the theme %s using remote control with error: %w", name, err)
+	}
+	return nil
+}
+
 func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
 	if len(args) > 1 {
 		args = []string{strings.Join(args, ` `)}
@@ -63,6 +112,7 @@ func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
 	if err != nil {
 		return 1, err
 	}
+	utils.DefaultCacheManager().SweepInBackground()
 	cv := utils.NewCachedValues("unicode-input", &CachedData{Category: "All"})
 	h := &handler{lp: lp, opts: opts, cached_data: cv.Load()}
 	defer cv.Save()
@@ -94,6 +144,11 @@ func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
 		lp.KillIfSignalled()
 		return 1, nil
 	}
+	if h.apply_after_quit != nil {
+		if err = apply_code_to_matched_windows(opts, h.apply_after_quit.name, h.apply_after_quit.code); err != nil {
+			return 1, err
+		}
+	}
 	return
 }
 
@@ -44,6 +44,20 @@
 kitty.conf is edited. This is most useful if you add :code:`include themes.conf`
 to your kitty.conf and then have the kitten operate only on :file:`themes.conf`,
 allowing :code:`kitty.conf` to remain unchanged.
+
+
+--match -m
+completion=type:special group:cli.CompleteWindowSearch
+Instead of changing the config file, apply the theme live, using remote control,
+only to the windows matching the specified expression. See :option:`kitten @ set-colors --match`
+for the syntax. Useful to color windows differently based on the project or the SSH host,
+for example, from a shell hook. Note that remote control must be enabled, see :opt:`allow_remote_control`.
+
+
+--match-tab -t
+completion=type:special group:cli.CompleteTabSearch
+Same as :option:`--match` except that the theme is applied to all windows in
+the matching tabs. See :option:`kitten @ set-colors --match-tab` for the syntax.
 '''.format
 
 def main(args: List[str]) -> None:
@@ -72,6 +72,8 @@ type handler struct {
 	colors_set_once  bool
 	tabs             []string
 	rl               *readline.Readline
+	// the theme to apply to the matched windows once the loop has quit
+	apply_after_quit *struct{ name, code string }
 }
 
 // fetching {{{
@@ -165,7 +167,7 @@ func (self *handler) set_current_category(category string) {
 func ReadKittyColorSettings() map[string]string {
 	settings := make(map[string]string, 512)
 	handle_line := func(key, val string) error {
-		if themes.AllColorSettingNames[key] {
+		if themes.AllColorSettingNames()[key] {
 			settings[key] = val
 		}
 		return nil
@@ -509,9 +511,32 @@ func (self *handler) on_accepting_key_event(ev *loop.KeyEvent) error {
 		self.lp.Quit(0)
 		return nil
 	}
+	if ev.MatchesPressOrRepeat("r") || ev.MatchesPressOrRepeat("shift+r") {
+		ev.Handled = true
+		theme := self.themes_list.CurrentTheme()
+		if !theme.IsUserDefined() {
+			return nil
+		}
+		if err := theme.MoveToTrash(); err != nil {
+			return err
+		}
+		self.all_themes = self.all_themes.Filtered(func(t *themes.Theme) bool { return t != theme })
+		self.state = BROWSING
+		self.redraw_after_category_change()
+		return nil
+	}
 	if ev.MatchesPressOrRepeat("m") || ev.MatchesPressOrRepeat("shift+m") {
 		ev.Handled = true
-		self.themes_list.CurrentTheme().SaveInConf(utils.ConfigDir(), self.opts.ReloadIn, self.opts.ConfigFileName)
+		if applies_to_matched_windows(self.opts) {
+			theme := self.themes_list.CurrentTheme()
+			code, err := theme.Code()
+			if err != nil {
+				return err
+			}
+			self.apply_after_quit = &struct{ name, code string }{theme.Name(), code}
+		} else {
+			self.themes_list.CurrentTheme().SaveInConf(utils.ConfigDir(), self.opts.ReloadIn, self.opts.ConfigFileName)
+		}
 		self.update_recent()
 		self.lp.Quit(0)
 		return nil
@@ -547,12 +572,21 @@ func (self *handler) draw_accepting_screen() {
 	self.lp.Println()
 	self.lp.Println(`What would you like to do?`)
 	self.lp.Println()
-	self.lp.Printf(` %sodify %s to load %s`, ac("M"), kc, name)
+	if applies_to_matched_windows(self.opts) {
+		self.lp.Printf(` %sodify the colors of the matching windows to use %s`, ac("M"), name)
+	} else {
+		self.lp.Printf(` %sodify %s to load %s`, ac("M"), kc, name)
+	}
 	self.lp.Println()
 	self.lp.Println()
 	self.lp.Printf(` %slace the theme file in %s but do not modify %s`, ac("P"), utils.ConfigDir(), kc)
 	self.lp.Println()
 	self.lp.Println()
+	if self.themes_list.CurrentTheme().IsUserDefined() {
+		self.lp.Printf(` %semove the theme file, moving it to the trash`, ac("R"))
+		self.lp.Println()
+		self.lp.Println()
+	}
 	self.lp.Printf(` %sbort and return to list of themes`, ac("A"))
 	self.lp.Println()
 	self.lp.Println()
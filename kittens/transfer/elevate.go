@@ -0,0 +1,361 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"kitty/tools/cli"
+	"kitty/tools/utils"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+const elevated_install_cmd = "__transfer_install__"
+
+// A file received into the staging directory that has to be moved into place
+// with elevated privileges
+type elevated_entry struct {
+	Type   string `json:"type"`
+	Staged string `json:"staged,omitempty"`
+	Dest   string `json:"dest"`
+	// The path of the file a hard link points to
+	Target string `json:"target,omitempty"`
+}
+
+// Whether the current user cannot create or overwrite path
+func needs_elevation(path string) bool {
+	if os.Geteuid() == 0 {
+		return false
+	}
+	if s, err := os.Lstat(path); err == nil {
+		if s.IsDir() {
+			return false
+		}
+		if s.Mode().IsRegular() && unix.Access(path, unix.W_OK) != nil {
+			return true
+		}
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if s, err := os.Stat(dir); err == nil {
+			return !s.IsDir() || unix.Access(dir, unix.W_OK|unix.X_OK) != nil
+		}
+		if filepath.Dir(dir) == dir {
+			return false
+		}
+	}
+}
+
+func (self *manager) stage_for_elevation(f *remote_file) (err error) {
+	if self.staging_dir == "" {
+		if self.staging_dir, err = os.MkdirTemp("", "kitty-transfer-elevate-"); err != nil {
+			return fmt.Errorf("Failed to create staging directory for files needing elevated privileges with error: %w", err)
+		}
+	}
+	f.staged_path = filepath.Join(self.staging_dir, "root", f.expanded_local_path)
+	self.num_staged++
+	return
+}
+
+func elevation_tool() (cmd []string, err error) {
+	for _, x := range []string{"sudo", "pkexec"} {
+		if p, err := exec.LookPath(x); err == nil {
+			if x == "sudo" {
+				return []string{p, "--"}, nil
+			}
+			return []string{p}, nil
+		}
+	}
+	return nil, fmt.Errorf("Neither sudo nor pkexec was found, cannot move files into place with elevated privileges")
+}
+
+func confirm_elevation(out io.Writer, tool string, entries []elevated_entry) bool {
+	fmt.Fprintln(out, "The following destinations need elevated privileges to be written to:")
+	for _, e := range entries {
+		if e.Type != "directory" {
+			fmt.Fprintln(out, "  "+e.Dest)
+		}
+	}
+	fmt.Fprintf(out, "Move them into place using %s? [y/n] ", filepath.Base(tool))
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// The manifest has one JSON encoded entry per NUL terminated record, so that
+// it can be read without buffering all of it in memory
+const max_manifest_entry_size = 64 * 1024
+
+func encode_manifest(entries []elevated_entry) (ans []byte, err error) {
+	for _, e := range entries {
+		var data []byte
+		if data, err = json.Marshal(e); err != nil {
+			return nil, err
+		}
+		ans = append(append(ans, data...), 0)
+	}
+	return
+}
+
+func decode_manifest(r io.Reader) (entries []elevated_entry, err error) {
+	scanner := utils.NewRecordScanner(r, utils.RecordScannerOptions{Delimiters: utils.NulDelimiters, MaxRecordSize: max_manifest_entry_size})
+	for scanner.Scan() {
+		var e elevated_entry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Ask for confirmation and run the privileged helper to move the staged files
+// into place, the staging directory is left in place if anything fails. The
+// manifest is sent to the helper over STDIN, so that it cannot be modified
+// by anything else before it is read.
+func install_elevated(staging_dir string, entries []elevated_entry, messages *os.File) (err error) {
+	tool, err := elevation_tool()
+	if err == nil {
+		if !confirm_elevation(messages, tool[0], entries) {
+			err = fmt.Errorf("Canceled by user")
+		}
+	}
+	if err == nil {
+		var exe string
+		if exe, err = os.Executable(); err == nil {
+			var data []byte
+			if data, err = encode_manifest(entries); err == nil {
+				cmd := exec.Command(tool[0], append(tool[1:], exe, elevated_install_cmd, filepath.Join(staging_dir, "root"))...)
+				cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(data), messages, os.Stderr
+				err = cmd.Run()
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Files needing elevated privileges were not moved into place, they are in %s. Error: %w", filepath.Join(staging_dir, "root"), err)
+	}
+	return utils.RemoveAllSecure(staging_dir)
+}
+
+// The owner of dest, or of its parent directory if it does not exist
+func owner_for(dest string) (uid, gid int, err error) {
+	s, err := os.Lstat(dest)
+	if err != nil {
+		if s, err = os.Stat(filepath.Dir(dest)); err != nil {
+			return
+		}
+	}
+	if st, ok := s.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid), nil
+	}
+	return -1, -1, nil
+}
+
+// Move src to dest, in is src opened before it was checked, or nil
+// if src is a symlink
+func move_into_place(src, dest string, in *os.File) (err error) {
+	if err = os.Rename(src, dest); err != nil {
+		if !errors.Is(err, unix.EXDEV) {
+			return
+		}
+		if in != nil {
+			return utils.CopyFileFrom(in, dest)
+		}
+		tgt, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		os.Remove(dest)
+		return os.Symlink(tgt, dest)
+	}
+	// the staging directory is writable by the user, so src could have been
+	// replaced since it was checked
+	d, err := os.Lstat(dest)
+	if err == nil {
+		if in == nil {
+			if d.Mode()&fs.ModeSymlink == 0 {
+				err = fmt.Errorf("The staged file was replaced while being moved into place")
+			}
+		} else if s, serr := in.Stat(); serr != nil {
+			err = serr
+		} else if !os.SameFile(s, d) {
+			err = fmt.Errorf("The staged file was replaced while being moved into place")
+		}
+	}
+	if err != nil {
+		os.Remove(dest)
+	}
+	return
+}
+
+// Check that the staged path is inside the staging directory, resolving
+// symlinks in its parent directories
+func check_staged_path(root, real_root, path string) error {
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) || !is_inside(root, path) {
+		return fmt.Errorf("The staged file %s is not inside the staging directory", path)
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if parent != real_root && !is_inside(real_root, parent) {
+		return fmt.Errorf("The staged file %s is not inside the staging directory", path)
+	}
+	return nil
+}
+
+// A staged file could be a hard link to some other file, chowning it would
+// then change the owner of that file as well. The check is done on an
+// opened fd, so the file cannot be replaced by such a link after it is
+// checked.
+func open_staged_file(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	s, err := f.Stat()
+	if err == nil {
+		if !s.Mode().IsRegular() {
+			err = fmt.Errorf("The staged file is not a regular file")
+		} else if st, ok := s.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+			err = fmt.Errorf("The staged file has other hard links")
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func is_inside(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// Run with elevated privileges to move the files listed in the manifest read
+// from r, prepared by install_elevated, into place from the staging directory
+// root
+func install_elevated_entries(root string, r io.Reader) (err error) {
+	var real_root string
+	if root, err = filepath.Abs(root); err == nil {
+		real_root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid staging directory with error: %w", err)
+	}
+	entries, err := decode_manifest(r)
+	if err != nil {
+		return fmt.Errorf("Invalid manifest of files to move into place with error: %w", err)
+	}
+	// hard links can only point to regular files moved into place by this
+	// manifest, so they cannot be used to gain access to other files, and
+	// are created after all such files are in place
+	link_targets := utils.NewSet[string]()
+	var links []elevated_entry
+	for _, e := range entries {
+		if !filepath.IsAbs(e.Dest) || filepath.Clean(e.Dest) != e.Dest {
+			return fmt.Errorf("Invalid destination in the manifest of files to move into place: %#v", e)
+		}
+		if e.Type == "link" {
+			links = append(links, e)
+			continue
+		}
+		if err = check_staged_path(root, real_root, e.Staged); err != nil {
+			return err
+		}
+		if e.Type == "regular" {
+			link_targets.Add(e.Dest)
+		}
+	}
+	for _, e := range links {
+		if !link_targets.Has(e.Target) {
+			return fmt.Errorf("The hard link %s does not point to a file being moved into place", e.Dest)
+		}
+	}
+	for _, e := range entries {
+		if e.Type == "link" {
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(e.Dest), 0o755); err != nil {
+			return err
+		}
+		uid, gid, err := owner_for(e.Dest)
+		if err != nil {
+			return err
+		}
+		switch e.Type {
+		case "directory":
+			if s, err := os.Stat(e.Dest); err == nil && s.IsDir() {
+				continue
+			}
+			var s fs.FileInfo
+			if s, err = os.Lstat(e.Staged); err == nil {
+				if err = os.Mkdir(e.Dest, 0o700); err == nil {
+					err = os.Chmod(e.Dest, s.Mode().Perm())
+				}
+			}
+		case "regular":
+			var f *os.File
+			if f, err = open_staged_file(e.Staged); err == nil {
+				if uid > -1 {
+					err = f.Chown(uid, gid)
+				}
+				if err == nil {
+					err = move_into_place(e.Staged, e.Dest, f)
+				}
+				f.Close()
+			}
+		case "symlink":
+			err = move_into_place(e.Staged, e.Dest, nil)
+		default:
+			err = fmt.Errorf("Unknown file type: %s", e.Type)
+		}
+		if err == nil && uid > -1 {
+			err = os.Lchown(e.Dest, uid, gid)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to move %s into place with error: %w", e.Dest, err)
+		}
+	}
+	for _, e := range links {
+		// the link shares its inode with its target, which already has the
+		// correct owner, so it must not be chowned
+		os.Remove(e.Dest)
+		if err = os.Link(e.Target, e.Dest); err != nil {
+			return fmt.Errorf("Failed to create the hard link %s with error: %w", e.Dest, err)
+		}
+	}
+	return
+}
+
+func ElevatedInstallEntryPoint(root *cli.Command) {
+	root.AddSubCommand(&cli.Command{
+		Name:            elevated_install_cmd,
+		Hidden:          true,
+		OnlyArgsAllowed: true,
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			if len(args) != 1 {
+				return 1, fmt.Errorf("Usage: %s path-to-staging-directory < manifest", elevated_install_cmd)
+			}
+			if err = install_elevated_entries(args[0], os.Stdin); err != nil {
+				return 1, err
+			}
+			return
+		},
+	})
+}
@@ -0,0 +1,117 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestElevatedInstall(t *testing.T) {
+	tdir := t.TempDir()
+	staging, dest := filepath.Join(tdir, "staging"), filepath.Join(tdir, "dest")
+	root := filepath.Join(staging, "root")
+	staged := func(path string) string { return filepath.Join(root, path) }
+	for _, x := range []string{dest, staged(filepath.Join(dest, "sub"))} {
+		if err := os.MkdirAll(x, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dest, "existing"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staged(filepath.Join(dest, "existing")), []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staged(filepath.Join(dest, "sub", "file")), []byte("file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", staged(filepath.Join(dest, "sub", "sym"))); err != nil {
+		t.Fatal(err)
+	}
+	entries := []elevated_entry{
+		{Type: "regular", Staged: staged(filepath.Join(dest, "existing")), Dest: filepath.Join(dest, "existing")},
+		{Type: "directory", Staged: staged(filepath.Join(dest, "sub")), Dest: filepath.Join(dest, "sub")},
+		{Type: "regular", Staged: staged(filepath.Join(dest, "sub", "file")), Dest: filepath.Join(dest, "sub", "file")},
+		{Type: "symlink", Staged: staged(filepath.Join(dest, "sub", "sym")), Dest: filepath.Join(dest, "sub", "sym")},
+		{Type: "link", Dest: filepath.Join(dest, "link"), Target: filepath.Join(dest, "sub", "file")},
+	}
+	install := func(entries []elevated_entry) error {
+		data, _ := encode_manifest(entries)
+		return install_elevated_entries(root, bytes.NewReader(data))
+	}
+	if err := install(entries); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{"existing": "new", "sub/file": "file", "sub/sym": "file", "link": "file"} {
+		if data, err := os.ReadFile(filepath.Join(dest, name)); err != nil || string(data) != expected {
+			t.Fatalf("Incorrect contents for %s: %#v %v", name, string(data), err)
+		}
+	}
+	if s, err := os.Stat(filepath.Join(dest, "existing")); err != nil || s.Mode().Perm() != 0o600 {
+		t.Fatalf("Incorrect permissions for moved file: %v %v", s, err)
+	}
+	// entries outside the staging directory and hard links to files not
+	// being moved into place are rejected
+	if err := os.WriteFile(staged("evil"), []byte("evil"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(tdir, staged("escape")); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []elevated_entry{
+		{Type: "regular", Staged: filepath.Join(tdir, "other"), Dest: filepath.Join(dest, "other")},
+		{Type: "regular", Staged: root + "/../other", Dest: filepath.Join(dest, "other")},
+		{Type: "regular", Staged: root + "-other/x", Dest: filepath.Join(dest, "other")},
+		{Type: "regular", Staged: staged("escape/other"), Dest: filepath.Join(dest, "other")},
+		{Type: "regular", Staged: staged("evil"), Dest: dest + "/sub/../other"},
+		{Type: "link", Dest: filepath.Join(dest, "other"), Target: filepath.Join(dest, "existing")},
+	} {
+		if err := install([]elevated_entry{e}); err == nil {
+			t.Fatalf("No error for invalid manifest entry: %#v", e)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "other")); err == nil {
+		t.Fatalf("Invalid manifest entry was installed")
+	}
+	// corrupt manifests and entries that are too large are rejected
+	for _, data := range []string{"{\"type\":\x00", "{\"dest\": \"" + strings.Repeat("x", max_manifest_entry_size) + "\"}\x00"} {
+		if err := install_elevated_entries(root, strings.NewReader(data)); err == nil {
+			t.Fatalf("No error for invalid manifest: %.40q", data)
+		}
+	}
+	// staged files with other hard links are not installed
+	if err := os.Link(staged("evil"), staged("evil-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := install([]elevated_entry{{Type: "regular", Staged: staged("evil"), Dest: filepath.Join(dest, "other")}}); err == nil {
+		t.Fatalf("No error for staged file with other hard links")
+	}
+	// a staged file replaced by a different type of file is removed from the
+	// destination
+	if err := install([]elevated_entry{{Type: "symlink", Staged: staged("evil"), Dest: filepath.Join(dest, "other")}}); err == nil {
+		t.Fatalf("No error for staged symlink that is a regular file")
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "other")); err == nil {
+		t.Fatalf("Invalid staged file was left in the destination")
+	}
+
+	if os.Geteuid() != 0 {
+		if needs_elevation(filepath.Join(dest, "new", "file")) {
+			t.Fatalf("Writable destination needs elevation")
+		}
+		if err := os.Chmod(dest, 0o500); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(dest, 0o755)
+		if !needs_elevation(filepath.Join(dest, "new", "file")) {
+			t.Fatalf("Read-only destination does not need elevation")
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// A subset of the rsync filter rules, see the FILTER RULES section of the
+// rsync man page. Supported are include (+) and exclude (-) rules, merge (.)
+// to read rules from a file, dir-merge (:) to read rules from a file in every
+// directory and clear (!).
+type filter_rule struct {
+	include        bool
+	dir_only       bool
+	match_basename bool
+	pattern        *regexp.Regexp
+	// The directory relative to which anchored patterns are matched, as a
+	// slash separated path relative to the transfer root
+	base string
+
+	// For dir-merge rules, the name of the per-directory file and the rules
+	// read from it in the current directory and its parents, innermost first
+	dir_merge string
+	merged    []*filter_rule
+}
+
+type Filter struct {
+	rules []*filter_rule
+}
+
+func pattern_to_regexp(pat string) string {
+	b := strings.Builder{}
+	// a trailing /*** matches the directory itself and everything inside it
+	suffix := ""
+	if strings.HasSuffix(pat, "/***") {
+		pat, suffix = pat[:len(pat)-4], "(/.*)?"
+	}
+	for i := 0; i < len(pat); i++ {
+		switch c := pat[i]; c {
+		case '*':
+			if i+1 < len(pat) && pat[i+1] == '*' {
+				b.WriteString(".*")
+				for i+1 < len(pat) && pat[i+1] == '*' {
+					i++
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pat[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pat[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pat) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+		}
+	}
+	return b.String() + suffix + "$"
+}
+
+func new_pattern_rule(include bool, pat, base string) (ans *filter_rule, err error) {
+	ans = &filter_rule{include: include, base: base}
+	if strings.HasSuffix(pat, "/") && pat != "/" {
+		ans.dir_only, pat = true, strings.TrimRight(pat, "/")
+	}
+	anchored := strings.HasPrefix(pat, "/")
+	pat = strings.TrimLeft(pat, "/")
+	ans.match_basename = !anchored && !strings.Contains(pat, "/") && !strings.Contains(pat, "**")
+	prefix := "^"
+	if !anchored && !ans.match_basename {
+		prefix = "(^|/)"
+	}
+	ans.pattern, err = regexp.Compile(prefix + pattern_to_regexp(pat))
+	return
+}
+
+// Parse the rules in data, base is the directory relative to which anchored
+// patterns are matched. Returns cleared as true if the rules start by clearing
+// inherited rules.
+func parse_filter_rules(data []byte, source, base, merge_dir string, depth int) (ans []*filter_rule, cleared bool, err error) {
+	if depth > 16 {
+		return nil, false, fmt.Errorf("Too many nested merge rules in: %s", source)
+	}
+	scanner := utils.NewRecordScanner(bytes.NewReader(data))
+	lnum := 0
+	for scanner.Scan() {
+		lnum++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		fail := func(msg string) error {
+			return fmt.Errorf("%s on line %d of %s: %s", msg, lnum, source, line)
+		}
+		rule, arg, found := strings.Cut(line, " ")
+		if !found && rule != "!" && rule != "clear" {
+			rule, arg, found = strings.Cut(line, "_")
+		}
+		if arg == "" && rule != "!" && rule != "clear" {
+			return nil, false, fail("Filter rule without a pattern")
+		}
+		switch rule {
+		case "+", "include", "-", "exclude":
+			r, err := new_pattern_rule(rule == "+" || rule == "include", arg, base)
+			if err != nil {
+				return nil, false, fail("Invalid pattern")
+			}
+			ans = append(ans, r)
+		case "!", "clear":
+			ans, cleared = nil, true
+		case ".", "merge":
+			path := arg
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(merge_dir, path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, false, err
+			}
+			rules, c, err := parse_filter_rules(data, path, base, filepath.Dir(path), depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			if c {
+				ans, cleared = nil, true
+			}
+			ans = append(ans, rules...)
+		case ":", "dir-merge":
+			if strings.Contains(arg, "/") {
+				return nil, false, fail("Only plain file names are supported in dir-merge rules")
+			}
+			ans = append(ans, &filter_rule{dir_merge: arg})
+		default:
+			return nil, false, fail("Unsupported filter rule")
+		}
+	}
+	return ans, cleared, scanner.Err()
+}
+
+// Load filter rules from the specified files, in the rsync filter rule syntax
+func LoadFilter(paths ...string) (*Filter, error) {
+	ans := Filter{}
+	for _, path := range paths {
+		path = expand_home(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules, cleared, err := parse_filter_rules(data, path, "", filepath.Dir(path), 0)
+		if err != nil {
+			return nil, err
+		}
+		if cleared {
+			ans.rules = nil
+		}
+		ans.rules = append(ans.rules, rules...)
+	}
+	return &ans, nil
+}
+
+// The filter to use for the contents of the directory dir whose path
+// relative to the transfer root is rel. Reads the per-directory files of
+// dir-merge rules.
+func (self *Filter) ForDirectory(dir, rel string) (*Filter, error) {
+	if self == nil {
+		return nil, nil
+	}
+	var ans *Filter
+	for i, r := range self.rules {
+		if r.dir_merge == "" {
+			continue
+		}
+		path := filepath.Join(dir, r.dir_merge)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		rules, cleared, err := parse_filter_rules(data, path, rel, dir, 0)
+		if err != nil {
+			return nil, err
+		}
+		if ans == nil {
+			ans = &Filter{rules: append([]*filter_rule(nil), self.rules...)}
+		}
+		nr := *r
+		if !cleared {
+			rules = append(rules, r.merged...)
+		}
+		nr.merged = rules
+		ans.rules[i] = &nr
+	}
+	if ans == nil {
+		return self, nil
+	}
+	return ans, nil
+}
+
+// Returns the matching rule or nil
+func match_rules(rules []*filter_rule, rel string, is_dir bool) *filter_rule {
+	for _, r := range rules {
+		if r.dir_merge != "" {
+			if m := match_rules(r.merged, rel, is_dir); m != nil {
+				return m
+			}
+			continue
+		}
+		if r.dir_only && !is_dir {
+			continue
+		}
+		path := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			path = rel[len(r.base)+1:]
+		}
+		if r.match_basename {
+			path = path[strings.LastIndexByte(path, '/')+1:]
+		}
+		if r.pattern.MatchString(path) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Whether the file with the slash separated path rel, relative to the
+// transfer root, is excluded. The first matching rule wins and files that
+// match no rules are included.
+func (self *Filter) Excluded(rel string, is_dir bool) bool {
+	if self == nil {
+		return false
+	}
+	m := match_rules(self.rules, rel, is_dir)
+	return m != nil && !m.include
+}
@@ -0,0 +1,86 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestFilterRules(t *testing.T) {
+	tdir := t.TempDir()
+	write := func(path, data string) {
+		path = filepath.Join(tdir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("rules", `
+# comment
++ keep.o
+- *.o
+- /src/build/
+- cache/***
+: .rsync-filter
+. more-rules
+`)
+	write("more-rules", `- a/b/*.tmp
+exclude core
+`)
+	for _, x := range []string{
+		"src/x.c", "src/x.o", "src/keep.o", "src/build/out", "src/sub/build/out", "src/cache/c/d", "src/cached",
+		"src/a/b/f.tmp", "src/z/a/b/f.tmp", "src/a/b/c/f.tmp", "src/core", "src/lib/.rsync-filter", "src/lib/x.h", "src/lib/x.go",
+		"src/lib/inner/x.go", "src/lib/inner/x.h", "src/lib/inner/x.c", "src/lib/clear/x.h",
+	} {
+		write(x, "")
+	}
+	write("src/lib/.rsync-filter", "- *.h\n- /inner/x.c\n")
+	write("src/lib/inner/.rsync-filter", "- *.go\n")
+	write("src/lib/clear/.rsync-filter", "!\n")
+
+	f, err := LoadFilter(filepath.Join(tdir, "rules"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{FilterFrom: []string{filepath.Join(tdir, "rules")}}
+	files, err := files_for_send(opts, []string{filepath.Join(tdir, "src"), "/dest/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := []string{}
+	for _, x := range files {
+		if x.file_type != FileType_directory {
+			r, _ := filepath.Rel(tdir, x.expanded_local_path)
+			actual = append(actual, filepath.ToSlash(r))
+		}
+	}
+	slices.Sort(actual)
+	expected := []string{
+		"src/a/b/c/f.tmp", "src/cached", "src/keep.o", "src/lib/.rsync-filter", "src/lib/clear/.rsync-filter", "src/lib/clear/x.h",
+		"src/lib/inner/.rsync-filter", "src/lib/x.go", "src/sub/build/out", "src/x.c",
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Incorrect files after filtering:\n%s", diff)
+	}
+	if !f.Excluded("src/cache", true) || f.Excluded("src/build", false) {
+		t.Fatalf("Incorrect matching of directories")
+	}
+
+	for _, bad := range []string{"-foo", "+", "dir-merge a/b", "-! x"} {
+		write("bad", bad)
+		if _, err = LoadFilter(filepath.Join(tdir, "bad")); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Fatalf("Invalid rule %#v did not fail: %v", bad, err)
+		}
+	}
+}
@@ -3,7 +3,6 @@
 package transfer
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/fs"
@@ -16,6 +15,7 @@ import (
 
 	"kitty"
 	"kitty/tools/utils"
+	"kitty/tools/utils/base64"
 )
 
 var _ = fmt.Print
@@ -177,7 +177,7 @@ func (self FileTransmissionCommand) Serialize(prefix_with_osc_code ...bool) stri
 				enc := field.Tag.Get("encoding")
 				switch enc {
 				case "base64":
-					encoded_val = base64.RawStdEncoding.EncodeToString(utils.UnsafeStringToBytes(sval))
+					encoded_val = base64.EncodeToString(utils.UnsafeStringToBytes(sval))
 				default:
 					encoded_val = safe_string(sval)
 				}
@@ -186,7 +186,7 @@ func (self FileTransmissionCommand) Serialize(prefix_with_osc_code ...bool) stri
 			switch val.Type().Elem().Kind() {
 			case reflect.Uint8:
 				if bval := val.Bytes(); len(bval) > 0 {
-					encoded_val = base64.RawStdEncoding.EncodeToString(bval)
+					encoded_val = base64.EncodeToString(bval)
 				}
 			}
 		case reflect.Int64:
@@ -245,7 +245,7 @@ func NewFileTransmissionCommand(serialized string) (ans *FileTransmissionCommand
 			case reflect.String:
 				switch field.Tag.Get("encoding") {
 				case "base64":
-					b, err := base64.RawStdEncoding.DecodeString(serialized_val)
+					b, err := base64.DecodeString(serialized_val)
 					if err != nil {
 						return fmt.Errorf("The field %#v has invalid base64 encoded value with error: %w", key, err)
 					}
@@ -256,7 +256,7 @@ func NewFileTransmissionCommand(serialized string) (ans *FileTransmissionCommand
 			case reflect.Slice:
 				switch val.Type().Elem().Kind() {
 				case reflect.Uint8:
-					b, err := base64.RawStdEncoding.DecodeString(serialized_val)
+					b, err := base64.DecodeString(serialized_val)
 					if err != nil {
 						return fmt.Errorf("The field %#v has invalid base64 encoded value with error: %w", key, err)
 					}
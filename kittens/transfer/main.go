@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"kitty/tools/cli"
+	"kitty/tools/dbus"
+	"kitty/tools/tui"
 	"kitty/tools/utils"
 )
 
@@ -43,6 +45,27 @@ func read_bypass(loc string) (string, error) {
 	}
 }
 
+// Prevent the screen from locking during long transfers, on systems with a
+// D-Bus session bus. Failures are ignored as this is only a convenience.
+func inhibit_screensaver() (release func()) {
+	done := make(chan func(), 1)
+	go func() {
+		c, err := dbus.ConnectSessionBus()
+		if err != nil {
+			done <- func() {}
+			return
+		}
+		uninhibit, err := c.InhibitScreensaver("kitty", "Transferring files")
+		if err != nil {
+			c.Close()
+			done <- func() {}
+			return
+		}
+		done <- func() { uninhibit(); c.Close() }
+	}()
+	return func() { (<-done)() }
+}
+
 func main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
 	if opts.PermissionsBypass != "" {
 		val, err := read_bypass(opts.PermissionsBypass)
@@ -54,11 +77,25 @@ func main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
 	if len(args) == 0 {
 		return 1, fmt.Errorf("Must specify at least one file to transfer")
 	}
+	if m := tui.DetectMultiplexer(); m != tui.NoMultiplexer {
+		return 1, tui.ResponsesNotSupportedError("Transferring files", m)
+	}
+	if remote_cmd, err := remote_transfer_command(opts, args, known_host_checker()); err != nil || remote_cmd != nil {
+		if err != nil {
+			return 1, err
+		}
+		return run_remote_transfer(remote_cmd)
+	}
+	defer inhibit_screensaver()()
+	events := new_progress_events(opts, os.Stdout)
+	// STDOUT is reserved for the events, if any, the terminal is accessed
+	// via the controlling tty, so only messages are affected by this
+	messages := utils.IfElse(events == nil, os.Stdout, os.Stderr)
 	switch opts.Direction {
 	case "send", "download":
-		err, rc = send_main(opts, args)
+		err, rc = send_main(opts, args, events, messages)
 	default:
-		err, rc = receive_main(opts, args)
+		err, rc = receive_main(opts, args, events, messages)
 	}
 	if err != nil {
 		rc = 1
@@ -62,6 +62,16 @@
 running the kitten and the home directory on the other computer. It is
 a good idea to use the :option:`--confirm-paths` command line flag to verify
 the kitten will copy the files you expect it to.
+
+Files can also be copied to or from another computer directly, without first
+logging into it, by specifying remote paths as :code:`host:path`, like
+:program:`scp`, where host is from :file:`~/.ssh/config` or
+:file:`known_hosts`. The kitten is then run on that computer via the ssh kitten:
+
+.. code::
+
+    $ kitten transfer my-remote-computer:remote-file /path/to/local-file
+    $ kitten transfer /path/to/local-file my-remote-computer:remote-file
 '''
 
 
@@ -106,6 +116,21 @@ def option_text() -> str:
 read the actual password.
 
 
+--filter-from
+type=list
+Read rules to include or exclude files from the specified file, which uses
+the syntax of :program:`rsync` filter rules, so existing :file:`.rsync-filter`
+files can be reused. Can be specified multiple times. Supported are
+:code:`+ pattern` and :code:`- pattern` rules to include and exclude files,
+:code:`. file` to read rules from another file, :code:`: file` to read rules
+from a file with the specified name in every directory, applying to that
+directory and below, and :code:`!` to clear the current rules. The first
+matching rule wins. Patterns starting with :code:`/` are anchored at the
+directory containing the transferred files, or that of the per-directory file,
+patterns ending with :code:`/` only match directories and excluded directories
+are not descended into. Only applies to files sent by this kitten.
+
+
 --confirm-paths -c
 type=bool-set
 Before actually transferring files, show a mapping of local file names to remote
@@ -118,6 +143,49 @@ def option_text() -> str:
 update it to match the file on the sending side, potentially saving lots of
 bandwidth and also automatically resuming partial transfers. Note that this will
 actually degrade performance on fast links or with small files, so use with care.
+When receiving, files on network file systems are sent in full, unless their
+signatures are cached by :option:`--cache-signatures`, as calculating the
+signature needs reading the entire file over the network.
+
+
+--cache-signatures
+type=bool-set
+Store the signatures of received files in a local database, in the kitty cache
+directory, so that repeated transfers of the same files only need to read the
+local files that have changed since the last transfer. Files that are unchanged
+on both the sending and receiving sides since the last transfer, as determined
+by their sizes and modification times, are skipped entirely. Implies
+:option:`--transmit-deltas`. Only has an effect when receiving files with
+the kitten, when sending files, the signatures are calculated by kitty.
+
+
+--prescan
+type=bool-set
+Quickly scan the files to be sent in parallel to estimate their number before
+examining them, to show the progress of the examination with an ETA. Useful for
+enormous directory trees, on slow file systems the extra pass can cost more
+than it saves.
+
+
+--progress-format
+default=terminal
+choices=terminal,json
+How to report the progress of the transfer. With :code:`json`, instead of
+drawing progress in the terminal, progress events are written to
+:file:`STDOUT`, one JSON object per line, for use by programs wrapping the
+kitten. Other messages are written to :file:`STDERR`. See
+:ref:`transfer_progress_events` for details.
+
+
+--elevate
+type=bool-set
+When receiving files, if some destinations are not writable by the current user,
+for example, files owned by root, receive them into a temporary staging directory
+instead and, after the transfer completes and you confirm, move them into place
+using :program:`sudo` or :program:`pkexec`. The moved files are owned by the owner
+of the file they replace or, for new files, the owner of the directory they are
+placed in. Without this option, the transfer fails before starting if any
+destination is not writable.
 '''
 
 
@@ -0,0 +1,102 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"kitty/tools/tty"
+	"kitty/tools/utils"
+	"kitty/tools/utils/humanize"
+)
+
+var _ = fmt.Print
+
+const scan_report_interval = 100 * time.Millisecond
+
+// The local paths that will be sent, without the remote destination
+func local_paths_for_send(opts *Options, args []string) []string {
+	if opts.Mode != "mirror" {
+		if len(args) < 2 {
+			return nil
+		}
+		args = args[:len(args)-1]
+	}
+	return utils.Map(func(x string) string { return abspath(expand_home(x)) }, args)
+}
+
+type scan_reporter struct {
+	out         *os.File
+	is_tty      bool
+	started_at  time.Time
+	last_report time.Time
+	estimate    utils.DiskUsageStats
+}
+
+func new_scan_reporter(out *os.File) *scan_reporter {
+	return &scan_reporter{out: out, is_tty: tty.IsTerminal(out.Fd()), started_at: time.Now()}
+}
+
+func (self *scan_reporter) report(msg string, force bool) {
+	if !self.is_tty {
+		return
+	}
+	if now := time.Now(); force || now.Sub(self.last_report) >= scan_report_interval {
+		self.last_report = now
+		fmt.Fprint(self.out, "\r\x1b[K"+msg)
+	}
+}
+
+func (self *scan_reporter) finish() {
+	if self.is_tty && !self.last_report.IsZero() {
+		fmt.Fprint(self.out, "\r\x1b[K")
+	}
+}
+
+// Quickly estimate the number and size of the files to be sent by walking
+// the local paths in parallel. This also warms the OS caches for the slower,
+// sequential scan that builds the actual list of files, whose progress can
+// then be reported against the estimate. The estimate ignores filters and
+// paths that cannot be read.
+func (self *scan_reporter) prescan(paths []string) {
+	var done utils.DiskUsageStats
+	add := func(a, b utils.DiskUsageStats) utils.DiskUsageStats {
+		a.ApparentSize += b.ApparentSize
+		a.OnDiskSize += b.OnDiskSize
+		a.NumFiles += b.NumFiles
+		a.NumDirs += b.NumDirs
+		return a
+	}
+	show := func(s utils.DiskUsageStats, force bool) {
+		self.report(fmt.Sprintf("Scanning files… found %d files and directories of total size: %s", s.NumFiles+s.NumDirs, humanize.Size(s.ApparentSize)), force)
+	}
+	for _, path := range paths {
+		s, err := utils.DiskUsage(path, utils.DiskUsageOptions{
+			ProgressInterval: scan_report_interval,
+			Progress:         func(s utils.DiskUsageStats) { show(add(done, s), false) },
+		})
+		if err == nil {
+			done = add(done, s)
+		}
+	}
+	show(done, true)
+	self.estimate = done
+	self.started_at = time.Now()
+}
+
+// Report the progress of the main scan against the estimate from the pre scan
+func (self *scan_reporter) scan_progress(num_found int) {
+	expected := self.estimate.NumFiles + self.estimate.NumDirs
+	if expected <= 0 {
+		self.report(fmt.Sprintf("Scanning files… found %d files and directories", num_found), false)
+		return
+	}
+	frac := utils.Min(1, float64(num_found)/float64(expected))
+	msg := fmt.Sprintf("Examining files… %d of %d (%.0f%%)", num_found, expected, 100*frac)
+	if elapsed := time.Since(self.started_at); frac > 0 && frac < 1 {
+		msg += " ETA: " + humanize.ShortDuration(time.Duration(float64(elapsed)*(1-frac)/frac))
+	}
+	self.report(msg, false)
+}
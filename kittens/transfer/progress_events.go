@@ -0,0 +1,95 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+var _ = fmt.Print
+
+// Progress events for --progress-format=json, output as one JSON object per
+// line. File events are only output for regular files.
+type progress_event struct {
+	Event string `json:"event"`
+	// The path of the file on the computer running the kitten and on the
+	// other computer
+	Path       string `json:"path,omitempty"`
+	RemotePath string `json:"remote_path,omitempty"`
+	// The number of bytes transferred so far and the total to transfer, for
+	// the file or for all files
+	Bytes      int64 `json:"bytes"`
+	TotalBytes int64 `json:"total_bytes"`
+	// The recent transfer rate across all files
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	Files          int     `json:"files,omitempty"`
+	FailedFiles    int     `json:"failed_files,omitempty"`
+	Error          string  `json:"error,omitempty"`
+}
+
+const progress_event_interval = 100 * time.Millisecond
+
+// A nil *progress_events ignores all events, so callers need not check
+// whether events were requested
+type progress_events struct {
+	enc                   *json.Encoder
+	last_progress_at      time.Time
+	last_progress_of_file string
+}
+
+func new_progress_events(opts *Options, w io.Writer) *progress_events {
+	if opts.ProgressFormat != "json" {
+		return nil
+	}
+	return &progress_events{enc: json.NewEncoder(w)}
+}
+
+func (self *progress_events) emit(event string, ev progress_event) {
+	if self == nil {
+		return
+	}
+	ev.Event = event
+	_ = self.enc.Encode(&ev)
+}
+
+func (self *progress_events) started(files int, total_bytes int64) {
+	self.emit("started", progress_event{Files: files, TotalBytes: total_bytes})
+}
+
+func (self *progress_events) file_started(ev progress_event) {
+	self.emit("file_started", ev)
+}
+
+// Progress events for a file are rate limited, except for the first
+func (self *progress_events) file_progress(ev progress_event) {
+	if self == nil {
+		return
+	}
+	now := time.Now()
+	if ev.Path == self.last_progress_of_file && now.Sub(self.last_progress_at) < progress_event_interval {
+		return
+	}
+	self.last_progress_at, self.last_progress_of_file = now, ev.Path
+	self.emit("file_progress", ev)
+}
+
+func (self *progress_events) file_done(ev progress_event) {
+	self.emit("file_done", ev)
+}
+
+func (self *progress_events) finished(ev progress_event) {
+	self.emit("finished", ev)
+}
+
+func finished_error(err error, rc int) string {
+	if err != nil {
+		return err.Error()
+	}
+	if rc != 0 {
+		return "The transfer did not complete successfully"
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestProgressEvents(t *testing.T) {
+	if new_progress_events(&Options{ProgressFormat: "terminal"}, nil) != nil {
+		t.Fatalf("Progress events created for the terminal progress format")
+	}
+	var e *progress_events
+	e.started(1, 1) // a nil instance ignores events
+
+	var b strings.Builder
+	e = new_progress_events(&Options{ProgressFormat: "json"}, &b)
+	e.started(2, 30)
+	e.file_started(progress_event{Path: "/a", RemotePath: "~/a", TotalBytes: 10})
+	for i := 1; i <= 10; i++ {
+		e.file_progress(progress_event{Path: "/a", Bytes: int64(i), TotalBytes: 10})
+	}
+	e.file_done(progress_event{Path: "/a", Bytes: 10, TotalBytes: 10})
+	e.file_progress(progress_event{Path: "/b", Bytes: 1, TotalBytes: 20})
+	e.file_done(progress_event{Path: "/b", Bytes: 1, TotalBytes: 20, Error: "EPERM"})
+	e.finished(progress_event{Files: 2, FailedFiles: 1, Bytes: 11, TotalBytes: 30, Error: finished_error(nil, 1)})
+
+	var actual []string
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var ev progress_event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("Invalid JSON event: %#v with error: %s", line, err)
+		}
+		actual = append(actual, fmt.Sprintf("%s %s %d/%d %s", ev.Event, ev.Path, ev.Bytes, ev.TotalBytes, ev.Error))
+	}
+	// repeated progress events for a file are rate limited
+	expected := []string{
+		"started  0/30 ", "file_started /a 0/10 ", "file_progress /a 1/10 ", "file_done /a 10/10 ",
+		"file_progress /b 1/20 ", "file_done /b 1/20 EPERM", "finished  11/30 The transfer did not complete successfully",
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Unexpected events:\n%s", diff)
+	}
+	if !strings.Contains(b.String(), `"bytes_per_second":0`) || !strings.Contains(b.String(), `"failed_files":1`) {
+		t.Fatalf("Unexpected JSON for events:\n%s", b.String())
+	}
+}
+
+func TestProgressEventsForRemoteErrors(t *testing.T) {
+	var b strings.Builder
+	f := &remote_file{ftype: FileType_regular, expanded_local_path: "/a", remote_path: "~/a", expected_size: 10}
+	m := manager{state: state_transferring, files_to_be_transferred: map[string]*remote_file{"1": f}}
+	m.events = new_progress_events(&Options{ProgressFormat: "json"}, &b)
+	if err := m.on_file_transfer_response(&FileTransmissionCommand{Action: Action_status, File_id: "1", Status: "EIO:Failed to read data from file"}); err == nil {
+		t.Fatalf("No error for a file that kitty failed to send")
+	}
+	var ev progress_event
+	if err := json.Unmarshal([]byte(b.String()), &ev); err != nil {
+		t.Fatalf("Invalid JSON event: %#v with error: %s", b.String(), err)
+	}
+	if ev.Event != "file_done" || ev.Path != "/a" || !strings.Contains(ev.Error, "EIO") {
+		t.Fatalf("Unexpected event for a file that kitty failed to send: %#v", ev)
+	}
+}
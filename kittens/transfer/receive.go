@@ -5,6 +5,7 @@ package transfer
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -67,6 +68,7 @@ func (ff *filesystem_file) write(data []byte) (int, error) {
 }
 
 type patch_file struct {
+	// the path the patched file is written to
 	path      string
 	src, temp *os.File
 	p         *rsync.Patcher
@@ -88,7 +90,7 @@ func (pf *patch_file) close() (err error) {
 	pf.src.Close()
 	pf.temp.Close()
 	if err == nil {
-		err = os.Rename(pf.temp.Name(), pf.src.Name())
+		err = os.Rename(pf.temp.Name(), pf.path)
 	}
 	pf.src = nil
 	pf.temp = nil
@@ -104,10 +106,10 @@ func (pf *patch_file) write(data []byte) (int, error) {
 	}
 }
 
-func new_patch_file(path string, p *rsync.Patcher) (ans *patch_file, err error) {
+func new_patch_file(src_path, path string, p *rsync.Patcher) (ans *patch_file, err error) {
 	ans = &patch_file{p: p, path: path}
 	var f *os.File
-	if f, err = os.Open(path); err != nil {
+	if f, err = os.Open(src_path); err != nil {
 		return
 	} else {
 		ans.src = f
@@ -144,6 +146,17 @@ type remote_file struct {
 	compression_type             Compression
 	remote_symlink_value         string
 	actual_file                  output_file
+	// set when the local file is unchanged since it was last received
+	signature_db_entry *signature_db_entry
+	up_to_date         bool
+	// set when the file is received into the staging directory to be moved
+	// into place with elevated privileges
+	staged_path string
+}
+
+// The path the received data is written to
+func (self *remote_file) write_path() string {
+	return utils.IfElse(self.staged_path == "", self.expanded_local_path, self.staged_path)
 }
 
 func (self *remote_file) close() (err error) {
@@ -172,21 +185,21 @@ func (self *remote_file) Write(data []byte) (n int, err error) {
 		return len(data), nil
 	case FileType_regular:
 		if self.actual_file == nil {
-			parent := filepath.Dir(self.expanded_local_path)
+			parent := filepath.Dir(self.write_path())
 			if parent != "" {
 				if err = os.MkdirAll(parent, 0o755); err != nil {
-					return 0, err
+					return 0, utils.ExplainPathError(err)
 				}
 			}
 			if self.expect_diff {
-				if pf, err := new_patch_file(self.expanded_local_path, self.patcher); err != nil {
+				if pf, err := new_patch_file(self.expanded_local_path, self.write_path(), self.patcher); err != nil {
 					return 0, err
 				} else {
 					self.actual_file = pf
 				}
 			} else {
-				if ff, err := os.Create(self.expanded_local_path); err != nil {
-					return 0, err
+				if ff, err := os.Create(self.write_path()); err != nil {
+					return 0, utils.ExplainPathError(err)
 				} else {
 					f := filesystem_file{f: ff}
 					self.actual_file = &f
@@ -250,20 +263,21 @@ func syscall_mode(i os.FileMode) (o uint32) {
 }
 
 func (self *remote_file) apply_metadata() {
+	path := self.write_path()
 	t := unix.NsecToTimespec(int64(self.mtime))
 	for {
-		if err := unix.UtimesNanoAt(unix.AT_FDCWD, self.expanded_local_path, []unix.Timespec{t, t}, unix.AT_SYMLINK_NOFOLLOW); err == nil || !(errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN)) {
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, path, []unix.Timespec{t, t}, unix.AT_SYMLINK_NOFOLLOW); err == nil || !(errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN)) {
 			break
 		}
 	}
 	if self.ftype == FileType_symlink {
 		for {
-			if err := unix.Fchmodat(unix.AT_FDCWD, self.expanded_local_path, syscall_mode(self.permissions), unix.AT_SYMLINK_NOFOLLOW); err == nil || !(errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN)) {
+			if err := unix.Fchmodat(unix.AT_FDCWD, path, syscall_mode(self.permissions), unix.AT_SYMLINK_NOFOLLOW); err == nil || !(errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN)) {
 				break
 			}
 		}
 	} else {
-		_ = os.Chmod(self.expanded_local_path, self.permissions)
+		_ = os.Chmod(path, self.permissions)
 	}
 }
 
@@ -351,6 +365,21 @@ type manager struct {
 	files_to_be_transferred map[string]*remote_file
 	state                   state
 	progress_tracker        receive_progress_tracker
+	signature_db            *signature_db
+	num_up_to_date          int
+	// the directory files needing elevated privileges are received into
+	staging_dir string
+	num_staged  int
+	elevated    []elevated_entry
+	events      *progress_events
+}
+
+func (self *manager) progress_event(f *remote_file) progress_event {
+	p := &self.progress_tracker
+	return progress_event{
+		Path: f.expanded_local_path, RemotePath: f.remote_path, Bytes: f.written_bytes, TotalBytes: f.expected_size,
+		BytesPerSecond: safe_divide(p.transfered_stats_amt, p.transfered_stats_interval.Abs().Seconds()),
+	}
 }
 
 type transmit_iterator = func(queue_write func(string) loop.IdType) (loop.IdType, error)
@@ -392,7 +421,7 @@ func (self *manager) request_files() transmit_iterator {
 		for pos < len(self.files) {
 			f = self.files[pos]
 			pos++
-			if f.ftype == FileType_directory || (f.ftype == FileType_link && f.remote_target != "") {
+			if f.up_to_date || f.ftype == FileType_directory || (f.ftype == FileType_link && f.remote_target != "") {
 				f = nil
 			} else {
 				break
@@ -404,7 +433,9 @@ func (self *manager) request_files() transmit_iterator {
 		read_signature := self.use_rsync && f.ftype == FileType_regular
 		if read_signature {
 			if s, err := os.Lstat(f.expanded_local_path); err == nil {
-				read_signature = s.Size() > 4096
+				// reading the whole file to calculate its signature is slow on
+				// network file systems
+				read_signature = s.Size() > 4096 && (f.signature_db_entry != nil || !utils.IsNetworkFS(f.expanded_local_path))
 			} else {
 				read_signature = false
 			}
@@ -422,13 +453,17 @@ func (self *manager) request_files() transmit_iterator {
 			f.expect_diff = true
 			f.patcher = rsync.NewPatcher(f.expected_size)
 			output := sigwriter{q: queue_write, file_id: f.file_id, prefix: self.prefix, suffix: self.suffix}
-			s_it := f.patcher.CreateSignatureIterator(fsf, &output)
-			for {
-				err = s_it()
-				if err == io.EOF {
-					break
-				} else if err != nil {
-					return 0, err
+			if e := f.signature_db_entry; e != nil && e.BlockSize == f.patcher.BlockSize() {
+				output.Write(e.signature)
+			} else {
+				s_it := f.patcher.CreateSignatureIterator(fsf, &output)
+				for {
+					err = s_it()
+					if err == io.EOF {
+						break
+					} else if err != nil {
+						return 0, err
+					}
 				}
 			}
 			output.flush()
@@ -499,22 +534,40 @@ func (self *manager) finalize_transfer() (err error) {
 		rid_map[f.remote_id] = f
 	}
 	for _, f := range self.files {
+		path := f.write_path()
 		switch f.ftype {
 		case FileType_directory:
-			if err = os.MkdirAll(f.expanded_local_path, 0o755); err != nil {
-				return fmt.Errorf("Failed to create directory with error: %w", err)
+			if err = os.MkdirAll(path, 0o755); err != nil {
+				return fmt.Errorf("Failed to create directory with error: %w", utils.ExplainPathError(err))
 			}
 		case FileType_link:
 			tgt, found := rid_map[f.remote_target]
 			if !found {
 				return fmt.Errorf(`Hard link with remote id: {%s} not found`, f.remote_target)
 			}
-			if err = os.MkdirAll(filepath.Dir(f.expanded_local_path), 0o755); err == nil {
-				os.Remove(f.expanded_local_path)
-				err = os.Link(tgt.expanded_local_path, f.expanded_local_path)
+			if f.staged_path != "" {
+				if tgt.staged_path != "" {
+					// created by the privileged helper once its target is in place
+					self.elevated = append(self.elevated, elevated_entry{Type: "link", Dest: f.expanded_local_path, Target: tgt.expanded_local_path})
+					continue
+				}
+				// the privileged helper only creates hard links to files it
+				// moves into place, so install a copy of the target instead
+				if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+					err = utils.CopyFile(tgt.write_path(), path)
+				}
+				if err != nil {
+					return fmt.Errorf(`Failed to copy hard link target with error: %w`, utils.ExplainPathError(err))
+				}
+				self.elevated = append(self.elevated, elevated_entry{Type: FileType_regular.String(), Staged: f.staged_path, Dest: f.expanded_local_path})
+				continue
+			}
+			if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+				os.Remove(path)
+				err = os.Link(tgt.write_path(), path)
 			}
 			if err != nil {
-				return fmt.Errorf(`Failed to create link with error: %w`, err)
+				return fmt.Errorf(`Failed to create link with error: %w`, utils.ExplainPathError(err))
 			}
 		case FileType_symlink:
 			lt := f.remote_symlink_value
@@ -533,15 +586,33 @@ func (self *manager) finalize_transfer() (err error) {
 			if lt == "" {
 				return fmt.Errorf("Symlink %s sent without target", f.expanded_local_path)
 			}
-			os.Remove(f.expanded_local_path)
-			if err = os.MkdirAll(filepath.Dir(f.expanded_local_path), 0o755); err != nil {
-				return fmt.Errorf("Failed to create directory with error: %w", err)
+			os.Remove(path)
+			if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return fmt.Errorf("Failed to create directory with error: %w", utils.ExplainPathError(err))
 			}
-			if err = os.Symlink(lt, f.expanded_local_path); err != nil {
-				return fmt.Errorf(`Failed to create symlink with error: %w`, err)
+			if err = os.Symlink(lt, path); err != nil {
+				return fmt.Errorf(`Failed to create symlink with error: %w`, utils.ExplainPathError(err))
 			}
 		}
 		f.apply_metadata()
+		if f.staged_path != "" {
+			self.elevated = append(self.elevated, elevated_entry{Type: f.ftype.String(), Staged: f.staged_path, Dest: f.expanded_local_path})
+		}
+	}
+	if self.signature_db != nil {
+		// calculating a signature reads the whole file, so do it in parallel
+		pool := utils.NewPool(utils.PoolOptions[struct{}]{})
+		for _, f := range self.files {
+			// staged files are not yet in place, so cannot be recorded
+			if f.ftype == FileType_regular && !f.up_to_date && f.staged_path == "" {
+				pool.Submit(func(context.Context) (struct{}, error) {
+					// the signature database is only an optimization, so ignore errors
+					_ = self.signature_db.set(signature_db_key(f.expanded_local_path), f.expected_size, int64(f.mtime))
+					return struct{}{}, nil
+				})
+			}
+		}
+		_ = pool.Wait()
 	}
 	return
 }
@@ -597,17 +668,47 @@ func (self *manager) on_file_transfer_response(ftc *FileTransmissionCommand) (er
 			return fmt.Errorf(`Unexpected response from terminal (invalid action): %s`, ftc.String())
 		}
 	case state_transferring:
+		if ftc.Action == Action_status && ftc.Status != `OK` && ftc.File_id != "" {
+			// kitty failed to read the file and abandoned the transfer
+			f, found := self.files_to_be_transferred[ftc.File_id]
+			if !found {
+				return fmt.Errorf(`Got error for unknown file id: %s`, ftc.String())
+			}
+			err = fmt.Errorf(`Failed to transfer %s with error: %s`, f.remote_path, ftc.Status)
+			if f.ftype == FileType_regular {
+				ev := self.progress_event(f)
+				ev.Error = err.Error()
+				self.events.file_done(ev)
+			}
+			return err
+		}
 		if ftc.Action == Action_data || ftc.Action == Action_end_data {
 			f, found := self.files_to_be_transferred[ftc.File_id]
 			if !found {
 				return fmt.Errorf(`Got data for unknown file id: %s`, ftc.File_id)
 			}
 			is_last := ftc.Action == Action_end_data
+			is_regular := f.ftype == FileType_regular
+			if is_regular && f.transmit_started_at.IsZero() {
+				self.events.file_started(self.progress_event(f))
+			}
 			if amt_written, err := f.write_data(ftc.Data, is_last); err != nil {
+				if is_regular {
+					ev := self.progress_event(f)
+					ev.Error = err.Error()
+					self.events.file_done(ev)
+				}
 				return err
 			} else {
 				self.progress_tracker.file_written(f, amt_written, is_last)
 			}
+			if is_regular {
+				if is_last {
+					self.events.file_done(self.progress_event(f))
+				} else {
+					self.events.file_progress(self.progress_event(f))
+				}
+			}
 			if is_last {
 				delete(self.files_to_be_transferred, ftc.File_id)
 				if len(self.files_to_be_transferred) == 0 {
@@ -750,6 +851,24 @@ func (self *manager) collect_files() (err error) {
 	}
 	self.progress_tracker.total_size_of_all_files = 0
 	for _, f := range self.files {
+		if self.signature_db != nil && f.ftype == FileType_regular {
+			if e := self.signature_db.get(signature_db_key(f.expanded_local_path)); e != nil {
+				f.signature_db_entry = e
+				// the local file and the source file are unchanged since the last transfer
+				if f.up_to_date = e.RemoteSize == f.expected_size && e.RemoteMtime == int64(f.mtime); f.up_to_date {
+					self.num_up_to_date++
+					continue
+				}
+			}
+		}
+		if needs_elevation(f.expanded_local_path) {
+			if !self.cli_opts.Elevate {
+				return fmt.Errorf("Not allowed to write to %s, use the --elevate option to move files into place with elevated privileges after the transfer", f.expanded_local_path)
+			}
+			if err = self.stage_for_elevation(f); err != nil {
+				return err
+			}
+		}
 		if f.ftype != FileType_directory && f.ftype != FileType_link {
 			self.files_to_be_transferred[f.file_id] = f
 			self.progress_tracker.total_size_of_all_files += utils.Max(0, f.expected_size)
@@ -822,6 +941,19 @@ func (self *handler) start_transfer() {
 		msg += fmt.Sprintf(`%d files`, n)
 	}
 	self.lp.Println(msg)
+	if self.manager.num_up_to_date > 0 {
+		self.lp.Println(fmt.Sprintf(`Skipping %d file(s) unchanged since the last transfer`, self.manager.num_up_to_date))
+	}
+	if self.manager.num_staged > 0 {
+		self.lp.Println(fmt.Sprintf(`%d file(s) need elevated privileges and will be moved into place after the transfer`, self.manager.num_staged))
+	}
+	self.manager.events.started(len(self.manager.files), self.manager.progress_tracker.total_bytes_to_transfer)
+	if len(self.manager.files_to_be_transferred) == 0 {
+		if err := self.manager.finalize_transfer(); err != nil {
+			self.abort_with_error(err)
+		}
+		return
+	}
 	self.max_name_length = 0
 	for _, f := range self.manager.files {
 		self.max_name_length = utils.Max(6, self.max_name_length, wcswidth.Stringwidth(f.display_name))
@@ -883,18 +1015,23 @@ func (self *handler) on_file_transfer_response(ftc *FileTransmissionCommand) (er
 			self.start_transfer()
 		}
 	}
+	return self.finish_if_done()
+}
+
+func (self *handler) finish_if_done() error {
+	if self.manager.state == state_canceled {
+		return nil
+	}
 	if self.manager.transfer_done {
-		self.manager.send(FileTransmissionCommand{Action: Action_finish}, self.lp.QueueWriteString)
-		self.quit_after_write_code = 0
-		if err = self.refresh_progress(0); err != nil {
-			return err
+		if self.quit_after_write_code < 0 {
+			self.manager.send(FileTransmissionCommand{Action: Action_finish}, self.lp.QueueWriteString)
+			self.quit_after_write_code = 0
 		}
+		return self.refresh_progress(0)
 	} else if self.transmit_started {
-		if err = self.refresh_progress(0); err != nil {
-			return err
-		}
+		return self.refresh_progress(0)
 	}
-	return
+	return nil
 }
 
 func (self *handler) on_writing_finished(msg_id loop.IdType, has_pending_writes bool) (err error) {
@@ -1011,7 +1148,7 @@ func (self *handler) schedule_progress_update(delay time.Duration) {
 }
 
 func (self *handler) draw_progress() {
-	if self.manager.state == state_canceled {
+	if self.manager.state == state_canceled || self.manager.events != nil {
 		return
 	}
 	self.lp.AllowLineWrapping(false)
@@ -1037,7 +1174,7 @@ func (self *handler) on_text(text string, from_key_event, in_bracketed_paste boo
 		switch strings.ToLower(text) {
 		case "y":
 			self.start_transfer()
-			return nil
+			return self.finish_if_done()
 		case "n":
 			self.abort_with_error(fmt.Errorf(`Canceled by user`))
 			return nil
@@ -1068,7 +1205,7 @@ func (self *handler) on_key_event(ev *loop.KeyEvent) error {
 	return nil
 }
 
-func receive_loop(opts *Options, spec []string, dest string) (err error, rc int) {
+func receive_loop(opts *Options, spec []string, dest string, events *progress_events, messages *os.File) (err error, rc int) {
 	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors)
 	if err != nil {
 		return err, 1
@@ -1078,14 +1215,21 @@ func receive_loop(opts *Options, spec []string, dest string) (err error, rc int)
 		lp: lp, quit_after_write_code: -1, cli_opts: opts, spinner: tui.NewSpinner("dots"),
 		ctx: markup.New(true),
 		manager: manager{
-			request_id: random_id(), spec: spec, dest: dest, bypass: opts.PermissionsBypass, use_rsync: opts.TransmitDeltas,
+			request_id: random_id(), spec: spec, dest: dest, bypass: opts.PermissionsBypass, use_rsync: opts.TransmitDeltas || opts.CacheSignatures,
 			failed_specs: make(map[int]string, len(spec)), spec_counts: make(map[int]int, len(spec)),
 			suffix: "\x1b\\", cli_opts: opts, files_to_be_transferred: make(map[string]*remote_file),
+			events: events,
 		},
 	}
 	for i := range spec {
 		handler.manager.spec_counts[i] = 0
 	}
+	if opts.CacheSignatures {
+		if handler.manager.signature_db, err = default_signature_db(); err != nil {
+			return err, 1
+		}
+		handler.manager.signature_db.prune()
+	}
 	handler.manager.prefix = fmt.Sprintf("\x1b]%d;id=%s;", kitty.FileTransferCode, handler.manager.request_id)
 	if handler.manager.bypass != `` {
 		if handler.manager.bypass, err = encode_bypass(handler.manager.request_id, handler.manager.bypass); err != nil {
@@ -1133,6 +1277,13 @@ func receive_loop(opts *Options, spec []string, dest string) (err error, rc int)
 		return nil
 	}
 
+	defer func() {
+		p := &handler.manager.progress_tracker
+		events.finished(progress_event{
+			Files: len(handler.manager.files), Bytes: p.total_transferred, TotalBytes: p.total_bytes_to_transfer,
+			BytesPerSecond: safe_divide(p.total_transferred, time.Since(p.started_at).Seconds()), Error: finished_error(err, rc),
+		})
+	}()
 	err = lp.Run()
 	defer func() {
 		for _, f := range handler.manager.files {
@@ -1165,12 +1316,21 @@ func receive_loop(opts *Options, spec []string, dest string) (err error, rc int)
 		}
 	}
 	if tsf > 0 && dsz+ssz > 0 && rc == 0 {
-		print_rsync_stats(tsf, dsz, ssz)
+		print_rsync_stats(messages, tsf, dsz, ssz)
+	}
+	if m := &handler.manager; m.staging_dir != "" {
+		if rc == 0 && m.transfer_done {
+			if err = install_elevated(m.staging_dir, m.elevated, messages); err != nil {
+				return err, 1
+			}
+		} else {
+			utils.RemoveAllSecure(m.staging_dir)
+		}
 	}
 	return
 }
 
-func receive_main(opts *Options, args []string) (err error, rc int) {
+func receive_main(opts *Options, args []string, events *progress_events, messages *os.File) (err error, rc int) {
 	spec := args
 	var dest string
 	switch opts.Mode {
@@ -1185,5 +1345,5 @@ func receive_main(opts *Options, args []string) (err error, rc int) {
 		dest = args[len(args)-1]
 		spec = args[:len(args)-1]
 	}
-	return receive_loop(opts, spec, dest)
+	return receive_loop(opts, spec, dest, events, messages)
 }
@@ -0,0 +1,143 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"kitty/tools/sshconfig"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Transfers to and from other computers, with paths specified as host:path,
+// like scp, are performed by running this kitten on the host via the ssh
+// kitten. Only hosts from the SSH config or known_hosts files are recognized,
+// so that local paths that contain colons are not mistaken for remote ones.
+
+type remote_path struct {
+	host, path string
+}
+
+func parse_remote_path(arg string, is_known func(string) bool) (ans remote_path, ok bool) {
+	host, path, found := strings.Cut(arg, ":")
+	if !found || host == "" || strings.ContainsRune(host, '/') {
+		return
+	}
+	if _, err := os.Lstat(arg); err == nil {
+		return
+	}
+	name := host
+	if _, h, has_user := strings.Cut(host, "@"); has_user {
+		name = h
+	}
+	if !is_known(name) {
+		return
+	}
+	return remote_path{host, path}, true
+}
+
+func known_host_checker() func(string) bool {
+	var known *utils.Set[string]
+	return func(name string) bool {
+		if known == nil {
+			known = utils.NewSetWithItems(sshconfig.AllHostNames()...)
+		}
+		return known.Has(name)
+	}
+}
+
+func absolute_local_path(x string) (string, error) {
+	ans, err := filepath.Abs(expand_home(x))
+	if err == nil && strings.HasSuffix(x, "/") && !strings.HasSuffix(ans, "/") {
+		ans += "/"
+	}
+	return ans, err
+}
+
+// The command to run this kitten on the host the paths refer to, or nil if
+// all paths are local
+func remote_transfer_command(opts *Options, args []string, is_known func(string) bool) (cmd []string, err error) {
+	remote := make([]*remote_path, len(args))
+	found := false
+	for i, arg := range args {
+		if r, ok := parse_remote_path(arg, is_known); ok {
+			remote[i], found = &r, true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	switch {
+	case opts.Mode == "mirror":
+		return nil, errors.New("Transferring files to or from other computers using host:path is not supported in mirror mode")
+	case len(args) < 2:
+		return nil, errors.New("Must specify at least one file to transfer and a destination")
+	case len(opts.FilterFrom) > 0 || opts.PermissionsBypass != "":
+		return nil, errors.New("The --filter-from and --permissions-bypass options cannot be used when transferring files using host:path")
+	}
+	dest := remote[len(args)-1]
+	host, direction := "", "send"
+	paths := make([]string, len(args))
+	for i, r := range remote {
+		is_dest := i == len(args)-1
+		switch {
+		case r == nil:
+			if !is_dest && dest == nil {
+				return nil, fmt.Errorf("Cannot transfer the local file %s to a local destination, for downloads all the files must be on the same other computer", args[i])
+			}
+			if paths[i], err = absolute_local_path(args[i]); err != nil {
+				return nil, err
+			}
+		case !is_dest && dest != nil:
+			return nil, fmt.Errorf("Cannot transfer %s to another computer, for uploads all the files must be local", args[i])
+		case host != "" && r.host != host:
+			return nil, fmt.Errorf("All files must be on the same computer, %s is not on %s", args[i], host)
+		default:
+			host, paths[i] = r.host, r.path
+			if paths[i] == "" {
+				// the home directory, like scp
+				paths[i] = utils.IfElse(is_dest, "./", ".")
+			}
+		}
+	}
+	if dest != nil {
+		direction = "receive"
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd = []string{exe, "ssh", "-t", host, "kitten", "transfer", "--direction=" + direction, "--compress=" + opts.Compress}
+	if opts.ConfirmPaths {
+		cmd = append(cmd, "--confirm-paths")
+	}
+	if opts.TransmitDeltas {
+		cmd = append(cmd, "--transmit-deltas")
+	}
+	cmd = append(cmd, "--")
+	// the remote command is run by the shell on the other computer
+	for _, x := range paths {
+		cmd = append(cmd, utils.QuoteStringForSH(x))
+	}
+	return cmd, nil
+}
+
+func run_remote_transfer(cmd []string) (rc int, err error) {
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = c.Run(); err != nil {
+		var eerr *exec.ExitError
+		if errors.As(err, &eerr) {
+			return eerr.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
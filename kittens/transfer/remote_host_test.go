@@ -0,0 +1,66 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kitty/tools/utils"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestRemoteTransferCommand(t *testing.T) {
+	tdir := t.TempDir()
+	cwd, _ := os.Getwd()
+	defer func() { _ = os.Chdir(cwd) }()
+	if err := os.Chdir(tdir); err != nil {
+		t.Fatal(err)
+	}
+	// an existing local file is never a remote path
+	if err := os.WriteFile("myhost:x", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	is_known := func(h string) bool { return h == "myhost" || h == "other" }
+	exe, _ := os.Executable()
+	q := func(expected []string, args ...string) {
+		t.Helper()
+		actual, err := remote_transfer_command(&Options{Mode: "normal", Compress: "auto"}, args, is_known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected != nil {
+			expected = append([]string{exe, "ssh", "-t"}, expected...)
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Incorrect command for %#v:\n%s", args, diff)
+		}
+	}
+	local := func(x string) string {
+		return "'" + filepath.Join(tdir, x) + utils.IfElse(strings.HasSuffix(x, "/"), "/", "") + "'"
+	}
+	q(nil, "a", "b/")
+	q(nil, "myhost:x", "b")
+	q(nil, "unknown:y", "b")
+	q([]string{"myhost", "kitten", "transfer", "--direction=send", "--compress=auto", "--", "'y'", "'~/z'", local("b/")}, "myhost:y", "myhost:~/z", "b/")
+	q([]string{"me@myhost", "kitten", "transfer", "--direction=send", "--compress=auto", "--", "'y'", local("b")}, "me@myhost:y", "b")
+	q([]string{"myhost", "kitten", "transfer", "--direction=send", "--compress=auto", "--", "'.'", local("b")}, "myhost:", "b")
+	q([]string{"myhost", "kitten", "transfer", "--direction=receive", "--compress=auto", "--", local("a"), `'it'"'"'s'`}, "a", "myhost:it's")
+	q([]string{"myhost", "kitten", "transfer", "--direction=receive", "--compress=auto", "--", local("a"), "'./'"}, "a", "myhost:")
+	for _, args := range [][]string{
+		{"myhost:a"}, {"myhost:a", "other:b"}, {"myhost:a", "other:b", "c"}, {"myhost:a", "b", "c"}, {"a", "myhost:b", "myhost:c"},
+	} {
+		if _, err := remote_transfer_command(&Options{Mode: "normal"}, args, is_known); err == nil {
+			t.Fatalf("Invalid arguments did not fail: %#v", args)
+		}
+	}
+	if _, err := remote_transfer_command(&Options{Mode: "mirror"}, []string{"myhost:a", "b"}, is_known); err == nil {
+		t.Fatalf("Mirror mode did not fail")
+	}
+}
@@ -105,6 +105,8 @@ type File struct {
 	differ                                                *rsync.Differ
 	delta_loader                                          func() error
 	deltabuf                                              *bytes.Buffer
+	prefetched                                            []byte
+	prefetch_attempted                                    bool
 }
 
 func get_remote_path(local_path string, remote_base string) string {
@@ -135,47 +137,116 @@ func NewFile(opts *Options, local_path, expanded_local_path string, file_id int,
 	return &ans
 }
 
-func process(opts *Options, paths []string, remote_base string, counter *int) (ans []*File, err error) {
+func process(opts *Options, paths []string, remote_base string, counter *file_counter, filter *Filter) (ans []*File, err error) {
 	for _, x := range paths {
 		expanded := expand_home(x)
 		s, err := os.Lstat(expanded)
 		if err != nil {
-			return ans, fmt.Errorf("Failed to stat %s with error: %w", x, err)
+			return ans, fmt.Errorf("Failed to stat %s with error: %w", x, utils.ExplainPathError(err))
+		}
+		// paths relative to the transfer root are used for filtering
+		rel := filepath.Base(x)
+		if filter.Excluded(rel, s.IsDir()) {
+			continue
 		}
 		if s.IsDir() {
-			*counter += 1
-			ans = append(ans, NewFile(opts, x, expanded, *counter, s, remote_base, FileType_directory))
-			new_remote_base := remote_base
-			if new_remote_base != "" {
-				new_remote_base = strings.TrimRight(new_remote_base, "/") + "/" + filepath.Base(x) + "/"
-			} else {
-				new_remote_base = strings.TrimRight(filepath.ToSlash(x), "/") + "/"
-			}
-			contents, err := os.ReadDir(expanded)
-			if err != nil {
-				return ans, fmt.Errorf("Failed to read the directory %s with error: %w", x, err)
-			}
-			new_paths := make([]string, len(contents))
-			for i, y := range contents {
-				new_paths[i] = filepath.Join(x, y.Name())
-			}
-			new_ans, err := process(opts, new_paths, new_remote_base, counter)
+			ans = append(ans, NewFile(opts, x, expanded, counter.next(), s, remote_base, FileType_directory))
+			new_ans, err := process_dir(opts, x, expanded, remote_base_for_dir(x, remote_base), counter, filter, rel)
+			ans = append(ans, new_ans...)
 			if err != nil {
 				return ans, err
 			}
-			ans = append(ans, new_ans...)
 		} else if s.Mode()&fs.ModeSymlink == fs.ModeSymlink {
-			*counter += 1
-			ans = append(ans, NewFile(opts, x, expanded, *counter, s, remote_base, FileType_symlink))
+			ans = append(ans, NewFile(opts, x, expanded, counter.next(), s, remote_base, FileType_symlink))
 		} else if s.Mode().IsRegular() {
-			*counter += 1
-			ans = append(ans, NewFile(opts, x, expanded, *counter, s, remote_base, FileType_regular))
+			ans = append(ans, NewFile(opts, x, expanded, counter.next(), s, remote_base, FileType_regular))
 		}
 	}
 	return
 }
 
-func process_mirrored_files(opts *Options, args []string) (ans []*File, err error) {
+// The remote base for the contents of the directory x
+func remote_base_for_dir(x, remote_base string) string {
+	if remote_base != "" {
+		return strings.TrimRight(remote_base, "/") + "/" + filepath.Base(x) + "/"
+	}
+	return strings.TrimRight(filepath.ToSlash(x), "/") + "/"
+}
+
+type dir_state struct {
+	filter           *Filter
+	rel, remote_base string
+}
+
+// Process the contents of the directory x. Directories are read concurrently,
+// ahead of the callback, which is called in the same order as a sequential
+// depth first scan, so files are numbered and filtered as they would be by
+// one.
+func process_dir(opts *Options, x, expanded, remote_base string, counter *file_counter, filter *Filter, rel string) (ans []*File, err error) {
+	root_filter, err := filter.ForDirectory(expanded, rel)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Clean(expanded)
+	dirs := map[string]dir_state{root: {root_filter, rel, remote_base}}
+	err = utils.ParallelWalkWithSymlink(expanded, func(path, abspath string, d fs.DirEntry, err error) error {
+		path = filepath.Clean(path)
+		local_path := x
+		if path != root {
+			r, rerr := filepath.Rel(root, path)
+			if rerr != nil {
+				return rerr
+			}
+			local_path = filepath.Join(x, r)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read the directory %s with error: %w", local_path, err)
+		}
+		if path == root {
+			return nil
+		}
+		parent := dirs[filepath.Dir(path)]
+		rel := parent.rel + "/" + d.Name()
+		if parent.filter.Excluded(rel, d.IsDir()) {
+			// SkipDir for a file would skip the rest of the directory
+			return utils.IfElse(d.IsDir(), fs.SkipDir, nil)
+		}
+		s, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("Failed to stat %s with error: %w", local_path, utils.ExplainPathError(err))
+		}
+		switch {
+		case s.IsDir():
+			ans = append(ans, NewFile(opts, local_path, path, counter.next(), s, parent.remote_base, FileType_directory))
+			child_filter, err := parent.filter.ForDirectory(path, rel)
+			if err != nil {
+				return err
+			}
+			dirs[path] = dir_state{child_filter, rel, remote_base_for_dir(local_path, parent.remote_base)}
+		case s.Mode()&fs.ModeSymlink == fs.ModeSymlink:
+			ans = append(ans, NewFile(opts, local_path, path, counter.next(), s, parent.remote_base, FileType_symlink))
+		case s.Mode().IsRegular():
+			ans = append(ans, NewFile(opts, local_path, path, counter.next(), s, parent.remote_base, FileType_regular))
+		}
+		return nil
+	}, utils.ParallelWalkOptions{Ordered: true, DontFollowSymlinks: true, ReportErrors: true})
+	return
+}
+
+type file_counter struct {
+	n        int
+	progress func(int)
+}
+
+func (self *file_counter) next() int {
+	self.n++
+	if self.progress != nil {
+		self.progress(self.n)
+	}
+	return self.n
+}
+
+func process_mirrored_files(opts *Options, args []string, filter *Filter, counter *file_counter) (ans []*File, err error) {
 	paths := utils.Map(func(x string) string { return abspath(expand_home(x)) }, args)
 	home := strings.TrimRight(home_path(), string(filepath.Separator)) + string(filepath.Separator)
 	paths = utils.Map(func(path string) string {
@@ -185,11 +256,10 @@ func process_mirrored_files(opts *Options, args []string) (ans []*File, err erro
 		}
 		return path
 	}, paths)
-	counter := 0
-	return process(opts, paths, "", &counter)
+	return process(opts, paths, "", counter, filter)
 }
 
-func process_normal_files(opts *Options, args []string) (ans []*File, err error) {
+func process_normal_files(opts *Options, args []string, filter *Filter, counter *file_counter) (ans []*File, err error) {
 	if len(args) < 2 {
 		return ans, fmt.Errorf("Must specify at least one local path and one remote path")
 	}
@@ -200,15 +270,26 @@ func process_normal_files(opts *Options, args []string) (ans []*File, err error)
 		remote_base += "/"
 	}
 	paths := utils.Map(func(x string) string { return abspath(expand_home(x)) }, args)
-	counter := 0
-	return process(opts, paths, remote_base, &counter)
+	return process(opts, paths, remote_base, counter, filter)
 }
 
 func files_for_send(opts *Options, args []string) (files []*File, err error) {
+	return scan_files_for_send(opts, args, nil)
+}
+
+// progress, if not nil, is called with the number of files found so far
+func scan_files_for_send(opts *Options, args []string, progress func(int)) (files []*File, err error) {
+	counter := &file_counter{progress: progress}
+	var filter *Filter
+	if len(opts.FilterFrom) > 0 {
+		if filter, err = LoadFilter(opts.FilterFrom...); err != nil {
+			return nil, fmt.Errorf("Failed to load filter rules with error: %w", err)
+		}
+	}
 	if opts.Mode == "mirror" {
-		files, err = process_mirrored_files(opts, args)
+		files, err = process_mirrored_files(opts, args, filter, counter)
 	} else {
-		files, err = process_normal_files(opts, args)
+		files, err = process_normal_files(opts, args, filter, counter)
 	}
 	if err != nil {
 		return files, err
@@ -347,6 +428,7 @@ type SendManager struct {
 	files                                                      []*File
 	bypass                                                     string
 	use_rsync                                                  bool
+	file_started                                               func(*File)
 	file_progress                                              func(*File, int)
 	file_done                                                  func(*File) error
 	fid_map                                                    map[string]*File
@@ -409,6 +491,7 @@ type SendHandler struct {
 	transmit_ok_checked                  bool
 	progress_update_timer                loop.IdType
 	spinner                              *tui.Spinner
+	events                               *progress_events
 }
 
 func safe_divide[A constraints.Integer | constraints.Float, B constraints.Integer | constraints.Float](a A, b B) float64 {
@@ -519,6 +602,12 @@ func (self *SendHandler) render_progress(name string, p Progress) {
 }
 
 func (self *SendHandler) draw_progress() {
+	if self.events != nil {
+		// progress is reported with events instead, but refreshing it also
+		// transmits pending data
+		self.schedule_progress_update(self.spinner.Interval())
+		return
+	}
 	self.lp.AllowLineWrapping(false)
 	defer self.lp.AllowLineWrapping(true)
 	var sc string
@@ -571,7 +660,7 @@ func (self *SendHandler) draw_progress() {
 			bytes_per_sec: safe_divide(p.transfered_stats_amt, p.transfered_stats_interval.Abs().Seconds()),
 		})
 	} else {
-		self.lp.QueueWriteString(`File data transfer has not yet started`)
+		self.lp.QueueWriteString(fmt.Sprintf(`File data transfer has not yet started, total size: %s`, humanize.Size(p.total_bytes_to_transfer)))
 	}
 	self.lp.Println()
 	self.schedule_progress_update(self.spinner.Interval())
@@ -631,7 +720,25 @@ func (self *SendHandler) schedule_progress_update(delay time.Duration) {
 	}
 }
 
+func (self *SendHandler) progress_event(f *File) progress_event {
+	p := &self.manager.progress_tracker
+	return progress_event{
+		Path: f.expanded_local_path, RemotePath: utils.IfElse(f.remote_final_path == "", f.remote_path, f.remote_final_path),
+		Bytes: f.reported_progress, TotalBytes: f.bytes_to_transmit, Error: f.err_msg,
+		BytesPerSecond: safe_divide(p.transfered_stats_amt, p.transfered_stats_interval.Abs().Seconds()),
+	}
+}
+
+func (self *SendHandler) on_file_started(f *File) {
+	if f.file_type == FileType_regular {
+		self.events.file_started(self.progress_event(f))
+	}
+}
+
 func (self *SendHandler) on_file_progress(f *File, change int) {
+	if f.file_type == FileType_regular {
+		self.events.file_progress(self.progress_event(f))
+	}
 	self.schedule_progress_update(100 * time.Millisecond)
 }
 
@@ -640,6 +747,9 @@ func (self *SendHandler) on_file_done(f *File) error {
 	if f.err_msg != "" {
 		self.failed_files = append(self.failed_files, f)
 	}
+	if f.file_type == FileType_regular {
+		self.events.file_done(self.progress_event(f))
+	}
 	return self.refresh_progress(0)
 }
 
@@ -721,6 +831,9 @@ func (self *SendManager) on_file_status_update(ftc *FileTransmissionCommand) err
 			if file.state == WAITING_FOR_DATA {
 				file.differ = rsync.NewDiffer()
 			}
+			if self.file_started != nil {
+				self.file_started(file)
+			}
 			self.update_collective_statuses()
 		}
 	case `PROGRESS`:
@@ -938,6 +1051,9 @@ func (self *File) next_chunk() (ans string, asz int, err error) {
 		}
 		chunk = slices.Clone(self.deltabuf.Bytes())
 		self.deltabuf.Reset()
+	} else if self.prefetched != nil {
+		chunk, is_last = self.prefetched, true
+		self.prefetched = nil
 	} else {
 		if self.actual_file == nil {
 			self.actual_file, err = os.Open(self.expanded_local_path)
@@ -980,6 +1096,46 @@ func (self *File) next_chunk() (ans string, asz int, err error) {
 	return
 }
 
+func (self *File) can_prefetch() bool {
+	return !self.prefetch_attempted && self.file_type == FileType_regular && self.state == TRANSMITTING && self.file_size <= prefetch_max_file_size &&
+		self.actual_file == nil && self.delta_loader == nil && self.differ == nil
+}
+
+const prefetch_max_file_size = 256 * 1024
+const prefetch_max_files = 1024
+const prefetch_max_memory = 32 * 1024 * 1024
+
+// When sending many small files, such as a source code tree, the time taken
+// is dominated by the system calls to open, read and close each file, so read
+// them in batches
+func (self *SendManager) prefetch_small_files() {
+	if af := self.active_file(); af == nil || !af.can_prefetch() {
+		return
+	}
+	files := make([]*File, 0, 64)
+	paths, hints := make([]string, 0, 64), make([]int, 0, 64)
+	mem := 0
+	for _, f := range self.files[self.active_idx:] {
+		if len(files) >= prefetch_max_files || mem >= prefetch_max_memory {
+			break
+		}
+		if f.can_prefetch() {
+			files = append(files, f)
+			paths = append(paths, f.expanded_local_path)
+			hints = append(hints, int(f.file_size))
+			mem += int(f.file_size)
+		}
+	}
+	data, errs := utils.ReadSmallFiles(paths, hints)
+	for i, f := range files {
+		f.prefetch_attempted = true
+		// errors are reported when the file is actually opened
+		if errs[i] == nil {
+			f.prefetched = data[i]
+		}
+	}
+}
+
 func (self *SendManager) next_chunks(callback func(string) loop.IdType) error {
 	if self.active_file() == nil {
 		self.activate_next_ready_file()
@@ -988,6 +1144,7 @@ func (self *SendManager) next_chunks(callback func(string) loop.IdType) error {
 	if af == nil {
 		return nil
 	}
+	self.prefetch_small_files()
 	chunk := ""
 	self.current_chunk_uncompressed_sz = 0
 	for af.state != FINISHED && len(chunk) == 0 {
@@ -1046,6 +1203,7 @@ func (self *SendHandler) start_transfer() (err error) {
 	if self.manager.active_file() != nil {
 		self.transmit_started = true
 		self.manager.progress_tracker.start_transfer()
+		self.events.started(len(self.manager.files), self.manager.progress_tracker.total_bytes_to_transfer)
 		if err = self.transmit_next_chunk(); err != nil {
 			return
 		}
@@ -1195,7 +1353,7 @@ func (self *SendHandler) on_interrupt() {
 	self.abort_transfer()
 }
 
-func send_loop(opts *Options, files []*File) (err error, rc int) {
+func send_loop(opts *Options, files []*File, events *progress_events, messages io.Writer) (err error, rc int) {
 	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors)
 	if err != nil {
 		return err, 1
@@ -1204,11 +1362,12 @@ func send_loop(opts *Options, files []*File) (err error, rc int) {
 	handler := &SendHandler{
 		opts: opts, files: files, lp: lp, quit_after_write_code: -1,
 		max_name_length: utils.Max(0, utils.Map(func(f *File) int { return wcswidth.Stringwidth(f.display_name) }, files)...),
-		progress_drawn:  true, done_file_ids: utils.NewSet[string](),
+		progress_drawn:  events == nil, done_file_ids: utils.NewSet[string](), events: events,
 		manager: &SendManager{
 			request_id: random_id(), files: files, bypass: opts.PermissionsBypass, use_rsync: opts.TransmitDeltas,
 		},
 	}
+	handler.manager.file_started = handler.on_file_started
 	handler.manager.file_progress = handler.on_file_progress
 	handler.manager.file_done = handler.on_file_done
 
@@ -1240,6 +1399,13 @@ func send_loop(opts *Options, files []*File) (err error, rc int) {
 	lp.OnResize = handler.on_resize
 	lp.OnWriteComplete = handler.on_writing_finished
 
+	defer func() {
+		p := &handler.manager.progress_tracker
+		events.finished(progress_event{
+			Files: len(files), FailedFiles: len(handler.failed_files), Bytes: p.total_reported_progress, TotalBytes: p.total_bytes_to_transfer,
+			BytesPerSecond: safe_divide(p.total_transferred, time.Since(p.started_at).Seconds()), Error: finished_error(err, rc),
+		})
+	}()
 	err = lp.Run()
 	if err != nil {
 		return err, 1
@@ -1257,14 +1423,14 @@ func send_loop(opts *Options, files []*File) (err error, rc int) {
 			}
 		}
 		if tsf > 0 {
-			print_rsync_stats(tsf, p.total_transferred, int64(p.signature_bytes))
+			print_rsync_stats(messages, tsf, p.total_transferred, int64(p.signature_bytes))
 		}
 	}
 	if len(handler.failed_files) > 0 {
 		fmt.Fprintf(os.Stderr, "Transfer of %d out of %d files failed\n", len(handler.failed_files), len(handler.manager.files))
 		for _, f := range handler.failed_files {
-			fmt.Println(handler.ctx.BrightRed(f.display_name))
-			fmt.Println(` `, f.err_msg)
+			fmt.Fprintln(messages, handler.ctx.BrightRed(f.display_name))
+			fmt.Fprintln(messages, ` `, f.err_msg)
 		}
 		rc = 1
 	}
@@ -1274,15 +1440,19 @@ func send_loop(opts *Options, files []*File) (err error, rc int) {
 	return
 }
 
-func send_main(opts *Options, args []string) (err error, rc int) {
-	fmt.Println("Scanning files…")
-	files, err := files_for_send(opts, args)
+func send_main(opts *Options, args []string, events *progress_events, messages *os.File) (err error, rc int) {
+	fmt.Fprintln(messages, "Scanning files…")
+	r := new_scan_reporter(messages)
+	if opts.Prescan {
+		r.prescan(local_paths_for_send(opts, args))
+	}
+	files, err := scan_files_for_send(opts, args, r.scan_progress)
+	r.finish()
 	if err != nil {
 		return err, 1
 	}
-	fmt.Printf("Found %d files and directories, requesting transfer permission…", len(files))
-	fmt.Println()
-	err, rc = send_loop(opts, files)
+	fmt.Fprintf(messages, "Found %d files and directories, requesting transfer permission…\n", len(files))
+	err, rc = send_loop(opts, files, events, messages)
 
 	return
 }
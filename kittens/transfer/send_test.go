@@ -100,3 +100,93 @@ func TestPathMappingSend(t *testing.T) {
 		ae(f.file_type, FileType_link)
 	})
 }
+
+func TestPrefetchSmallFiles(t *testing.T) {
+	opts := &Options{Compress: "never"}
+	tdir := t.TempDir()
+	expected := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		sz := i * 100
+		if i == 30 {
+			sz = prefetch_max_file_size + 1
+		}
+		p := filepath.Join(tdir, fmt.Sprint(i))
+		expected[p] = strings.Repeat(fmt.Sprint(i%10), sz)
+		os.WriteFile(p, []byte(expected[p]), 0o600)
+	}
+	files, err := files_for_send(opts, []string{tdir, "/dest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := SendManager{files: files}
+	for _, f := range files {
+		f.metadata_command(false)
+		if f.file_type == FileType_regular {
+			f.state = TRANSMITTING
+		}
+	}
+	for m.files[m.active_idx].file_type != FileType_regular {
+		m.active_idx++
+	}
+	m.prefetch_small_files()
+	for _, f := range files {
+		if f.file_type != FileType_regular {
+			continue
+		}
+		if is_large := f.file_size > prefetch_max_file_size; is_large == (f.prefetched != nil) {
+			t.Fatalf("Prefetching of %s with size %d incorrect", f.expanded_local_path, f.file_size)
+		}
+		actual := ""
+		for f.state != FINISHED {
+			chunk, _, err := f.next_chunk()
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual += chunk
+		}
+		if actual != expected[f.expanded_local_path] {
+			t.Fatalf("Incorrect data for %s with size: %d", f.expanded_local_path, f.file_size)
+		}
+	}
+}
+
+func TestPrescan(t *testing.T) {
+	opts := &Options{}
+	tdir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		d := filepath.Join(tdir, "src", fmt.Sprint(i))
+		os.MkdirAll(d, 0o755)
+		os.WriteFile(filepath.Join(d, "f"), []byte("1234"), 0o600)
+	}
+	args := []string{filepath.Join(tdir, "src"), "/dest"}
+	r := new_scan_reporter(os.Stdout)
+	r.prescan(local_paths_for_send(opts, args))
+	if r.estimate.NumFiles != 10 || r.estimate.NumDirs != 11 {
+		t.Fatalf("Incorrect estimate: %#v", r.estimate)
+	}
+	last := 0
+	files, err := scan_files_for_send(opts, args, func(n int) { last = n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != len(files) || int64(last) != r.estimate.NumFiles+r.estimate.NumDirs {
+		t.Fatalf("Incorrect progress: %d != %d", last, len(files))
+	}
+	// the files are found in depth first order, despite directories being
+	// read concurrently
+	expected := []string{"src"}
+	for i := 0; i < 10; i++ {
+		expected = append(expected, filepath.Join("src", fmt.Sprint(i)), filepath.Join("src", fmt.Sprint(i), "f"))
+	}
+	var actual []string
+	for i, f := range files {
+		if f.file_id != fmt.Sprintf("%x", i+1) {
+			t.Fatalf("Incorrect file id for %s: %s", f.local_path, f.file_id)
+		}
+		r, _ := filepath.Rel(tdir, f.local_path)
+		actual = append(actual, r)
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Files found in incorrect order:\n%s", diff)
+	}
+}
@@ -0,0 +1,157 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"kitty/tools/rsync"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Entries that have not been used for this long are removed
+const signature_db_max_age = 30 * 24 * time.Hour
+
+// A database of the signatures of local files as they were at the end of
+// previous transfers. Used to avoid re-reading local files that have not
+// changed and to skip files that are unchanged on both sides.
+type signature_db struct {
+	dir string
+}
+
+type signature_db_entry struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Mtime int64  `json:"mtime"`
+	Inode uint64 `json:"inode"`
+	// The size and modification time of the source file at the time of the transfer
+	RemoteSize  int64 `json:"remote_size"`
+	RemoteMtime int64 `json:"remote_mtime"`
+	BlockSize   int   `json:"block_size"`
+
+	signature []byte
+}
+
+func new_signature_db(dir string) (*signature_db, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("Failed to create the signature database directory %s with error: %w", dir, err)
+	}
+	return &signature_db{dir: dir}, nil
+}
+
+func default_signature_db() (*signature_db, error) {
+	return new_signature_db(filepath.Join(utils.CacheDir(), "transfer-signatures"))
+}
+
+func signature_db_key(path string) string {
+	if ans, err := filepath.Abs(path); err == nil {
+		return ans
+	}
+	return path
+}
+
+func (self *signature_db) path_for(path string) string {
+	h := sha256.Sum256(utils.UnsafeStringToBytes(path))
+	return filepath.Join(self.dir, hex.EncodeToString(h[:]))
+}
+
+func stat_for_signature_db(path string) (size, mtime int64, inode uint64, err error) {
+	s, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	if !s.Mode().IsRegular() {
+		return 0, 0, 0, fmt.Errorf("%s is not a regular file", path)
+	}
+	if st, ok := s.Sys().(*syscall.Stat_t); ok {
+		inode = uint64(st.Ino)
+	}
+	return s.Size(), s.ModTime().UnixNano(), inode, nil
+}
+
+// The entry for the local file at path, if one exists and the file has not
+// changed since the entry was stored
+func (self *signature_db) get(path string) *signature_db_entry {
+	dbpath := self.path_for(path)
+	data, err := os.ReadFile(dbpath)
+	if err != nil {
+		return nil
+	}
+	header, signature, found := bytes.Cut(data, []byte{'\n'})
+	if !found {
+		return nil
+	}
+	var e signature_db_entry
+	if json.Unmarshal(header, &e) != nil || e.Path != path {
+		return nil
+	}
+	size, mtime, inode, err := stat_for_signature_db(path)
+	if err != nil || size != e.Size || mtime != e.Mtime || inode != e.Inode {
+		return nil
+	}
+	e.signature = signature
+	now := time.Now()
+	_ = os.Chtimes(dbpath, now, now)
+	return &e
+}
+
+// Calculate and store the signature of the local file at path
+func (self *signature_db) set(path string, remote_size, remote_mtime int64) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	size, mtime, inode, err := stat_for_signature_db(path)
+	if err != nil {
+		return
+	}
+	e := signature_db_entry{Path: path, Size: size, Mtime: mtime, Inode: inode, RemoteSize: remote_size, RemoteMtime: remote_mtime}
+	// use the block size that will be used if the size of the source file does
+	// not change
+	p := rsync.NewPatcher(remote_size)
+	e.BlockSize = p.BlockSize()
+	buf := bytes.Buffer{}
+	header, _ := json.Marshal(&e)
+	buf.Write(header)
+	buf.WriteByte('\n')
+	it := p.CreateSignatureIterator(bufio.NewReaderSize(f, 1024*1024), &buf)
+	for {
+		if err = it(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+	// do not store signatures for files that were changed while reading them
+	if s, m, i, err := stat_for_signature_db(path); err != nil || s != size || m != mtime || i != inode {
+		return err
+	}
+	return utils.AtomicUpdateFile(self.path_for(path), buf.Bytes(), 0o600)
+}
+
+// Remove entries that have not been used recently
+func (self *signature_db) prune() {
+	entries, err := os.ReadDir(self.dir)
+	if err != nil {
+		return
+	}
+	for _, x := range entries {
+		if info, err := x.Info(); err == nil && time.Since(info.ModTime()) > signature_db_max_age {
+			os.Remove(filepath.Join(self.dir, x.Name()))
+		}
+	}
+}
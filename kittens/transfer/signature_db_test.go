@@ -0,0 +1,79 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kitty/tools/rsync"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestSignatureDB(t *testing.T) {
+	tdir := t.TempDir()
+	db, err := new_signature_db(filepath.Join(tdir, "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tdir, "file")
+	data := strings.Repeat("abcdefgh", 4096)
+	if err = os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if db.get(path) != nil {
+		t.Fatalf("Got an entry for a file not in the database")
+	}
+	if err = db.set(path, int64(len(data)), 1234); err != nil {
+		t.Fatal(err)
+	}
+	e := db.get(path)
+	if e == nil {
+		t.Fatalf("Failed to get the entry for a file in the database")
+	}
+	if e.RemoteSize != int64(len(data)) || e.RemoteMtime != 1234 || e.Size != int64(len(data)) {
+		t.Fatalf("Incorrect entry: %#v", e)
+	}
+	p := rsync.NewPatcher(int64(len(data)))
+	expected := bytes.Buffer{}
+	it := p.CreateSignatureIterator(strings.NewReader(data), &expected)
+	for it() != io.EOF {
+	}
+	if e.BlockSize != p.BlockSize() {
+		t.Fatalf("Incorrect block size: %d != %d", e.BlockSize, p.BlockSize())
+	}
+	if diff := cmp.Diff(expected.Bytes(), e.signature); diff != "" {
+		t.Fatalf("Incorrect signature:\n%s", diff)
+	}
+
+	// changing the file invalidates the entry
+	mtime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if db.get(path) != nil {
+		t.Fatalf("Got an entry for a file that was changed")
+	}
+
+	// unused entries are pruned
+	if err = db.set(path, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * signature_db_max_age)
+	if err = os.Chtimes(db.path_for(path), old, old); err != nil {
+		t.Fatal(err)
+	}
+	db.prune()
+	if db.get(path) != nil {
+		t.Fatalf("Unused entry was not pruned")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -106,9 +107,9 @@ func should_be_compressed(path, strategy string) bool {
 	return true
 }
 
-func print_rsync_stats(total_bytes, delta_bytes, signature_bytes int64) {
-	fmt.Println("Rsync stats:")
-	fmt.Printf("  Delta size: %s Signature size: %s\n", humanize.Size(delta_bytes), humanize.Size(signature_bytes))
+func print_rsync_stats(w io.Writer, total_bytes, delta_bytes, signature_bytes int64) {
+	fmt.Fprintln(w, "Rsync stats:")
+	fmt.Fprintf(w, "  Delta size: %s Signature size: %s\n", humanize.Size(delta_bytes), humanize.Size(signature_bytes))
 	frac := float64(delta_bytes+signature_bytes) / float64(utils.Max(1, total_bytes))
-	fmt.Printf("  Transmitted: %s of a total of %s (%.1f%%)\n", humanize.Size(delta_bytes+signature_bytes), humanize.Size(total_bytes), frac*100)
+	fmt.Fprintf(w, "  Transmitted: %s of a total of %s (%.1f%%)\n", humanize.Size(delta_bytes+signature_bytes), humanize.Size(total_bytes), frac*100)
 }
@@ -554,7 +554,35 @@ func run_loop(opts *Options) (lp *loop.Loop, err error) {
 	cached_data = cv.Load()
 	defer cv.Save()
 
-	h := handler{recent: cached_data.Recent, lp: lp, emoji_variation: opts.EmojiVariation}
+	store := default_recent_store()
+	h := handler{recent: store.load(cached_data.Recent), lp: lp, emoji_variation: opts.EmojiVariation}
+	type change struct{ recent, favorites bool }
+	changes := make(chan change, 16)
+	if w, err := watch_for_changes(store, func(recent, favorites bool) {
+		select {
+		case changes <- change{recent, favorites}:
+			lp.WakeupMainThread()
+		default:
+		}
+	}); err == nil {
+		defer w.Close()
+	}
+	lp.OnWakeup = func() error {
+		for {
+			select {
+			case c := <-changes:
+				if c.recent {
+					h.recent = store.load(h.recent)
+				}
+				if c.favorites {
+					load_favorites(true)
+				}
+				h.refresh()
+			default:
+				return nil
+			}
+		}
+	}
 	switch opts.Tab {
 	case "previous":
 		switch cached_data.Mode {
@@ -617,15 +645,7 @@ func run_loop(opts *Options) (lp *loop.Loop, err error) {
 			cached_data.Mode = "FAVORITES"
 		}
 		if h.current_char != InvalidChar {
-			cached_data.Recent = h.recent
-			idx := slices.Index(cached_data.Recent, h.current_char)
-			if idx > -1 {
-				cached_data.Recent = slices.Delete(cached_data.Recent, idx, idx+1)
-			}
-			cached_data.Recent = slices.Insert(cached_data.Recent, 0, h.current_char)
-			if len(cached_data.Recent) > len(DEFAULT_SET) {
-				cached_data.Recent = cached_data.Recent[:len(DEFAULT_SET)]
-			}
+			cached_data.Recent, _ = store.add(h.current_char, h.recent, len(DEFAULT_SET))
 			ans := h.resolved_char()
 			o, err := output(ans)
 			if err != nil {
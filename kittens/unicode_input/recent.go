@@ -0,0 +1,125 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package unicode_input
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const recent_lock_timeout = 2 * time.Second
+
+// The recently used characters, shared by all running instances of this
+// kitten. It lives in the runtime directory so that it is fast to access and
+// is seeded from the cached values, which persist across reboots.
+type recent_store struct {
+	path string
+}
+
+func default_recent_store() *recent_store {
+	return &recent_store{path: filepath.Join(utils.RuntimeDir(), "unicode-input-recent.json")}
+}
+
+func (self *recent_store) with_lock(exclusive bool, f func() error) error {
+	lock, err := utils.NewFileLock(self.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err = lock.LockWithTimeout(recent_lock_timeout, exclusive); err != nil {
+		return err
+	}
+	return f()
+}
+
+// must be called with the lock held
+func (self *recent_store) read() (ans []rune, found bool) {
+	raw, err := os.ReadFile(self.path)
+	if err != nil {
+		return nil, !errors.Is(err, fs.ErrNotExist)
+	}
+	if json.Unmarshal(raw, &ans) != nil {
+		return nil, false
+	}
+	return ans, true
+}
+
+// Return the recently used characters, using fallback if no instance has
+// stored them yet
+func (self *recent_store) load(fallback []rune) (ans []rune) {
+	ans = fallback
+	_ = self.with_lock(false, func() error {
+		if q, found := self.read(); found {
+			ans = q
+		}
+		return nil
+	})
+	return
+}
+
+func add_recent(recent []rune, ch rune, max_size int) []rune {
+	recent = slices.DeleteFunc(slices.Clone(recent), func(x rune) bool { return x == ch })
+	recent = slices.Insert(recent, 0, ch)
+	if len(recent) > max_size {
+		recent = recent[:max_size]
+	}
+	return recent
+}
+
+// Atomically add ch to the recently used characters, merging with changes
+// made by other instances, returning the new list
+func (self *recent_store) add(ch rune, fallback []rune, max_size int) (ans []rune, err error) {
+	err = self.with_lock(true, func() error {
+		current, found := self.read()
+		if !found {
+			current = fallback
+		}
+		ans = add_recent(current, ch, max_size)
+		data, err := json.Marshal(ans)
+		if err == nil {
+			err = utils.AtomicWriteFile(self.path, data, 0o600)
+		}
+		return err
+	})
+	if err != nil {
+		ans = add_recent(fallback, ch, max_size)
+	}
+	return
+}
+
+// Call on_change whenever another instance changes the recently used
+// characters or the favorites file is changed. on_change is called in a
+// different goroutine.
+func watch_for_changes(store *recent_store, on_change func(recent_changed, favorites_changed bool)) (*utils.Watcher, error) {
+	w, err := utils.NewWatcher(50 * time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	// files are replaced atomically, so the directories have to be watched
+	fav := favorites_path()
+	for _, dir := range []string{filepath.Dir(store.path), filepath.Dir(fav)} {
+		if err = w.Add(dir, false); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			w.Close()
+			return nil, err
+		}
+	}
+	go func() {
+		for changed := range w.Events {
+			r, f := slices.Contains(changed, store.path), slices.Contains(changed, fav)
+			if r || f {
+				on_change(r, f)
+			}
+		}
+	}()
+	return w, nil
+}
@@ -0,0 +1,40 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package unicode_input
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestUnicodeInputRecentStore(t *testing.T) {
+	a := &recent_store{path: filepath.Join(t.TempDir(), "recent.json")}
+	b := &recent_store{path: a.path}
+	fallback := []rune{'a', 'b', 'c'}
+	q := func(actual, expected []rune) {
+		t.Helper()
+		if diff := cmp.Diff(string(expected), string(actual)); diff != "" {
+			t.Fatalf("Incorrect recent characters:\n%s", diff)
+		}
+	}
+	q(a.load(fallback), fallback)
+	ans, err := a.add('x', fallback, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q(ans, []rune("xab"))
+	// the stale list from b is ignored in favor of the stored one
+	ans, err = b.add('c', fallback, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q(ans, []rune("cxa"))
+	q(a.load(fallback), []rune("cxa"))
+	ans, _ = a.add('a', nil, 3)
+	q(ans, []rune("acx"))
+}
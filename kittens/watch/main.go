@@ -0,0 +1,288 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package watch
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty/tools/cli"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type run_result struct {
+	output    string
+	exit_code int
+	finished  time.Time
+}
+
+type handler struct {
+	lp       *loop.Loop
+	opts     *Options
+	args     []string
+	interval time.Duration
+	results  chan run_result
+
+	current, previous []string
+	has_run           bool
+	last              run_result
+	running, paused   bool
+	show_differences  bool
+	output_changed    bool
+	timer_id          loop.IdType
+	// The text currently displayed on each screen line, used to redraw only
+	// the lines that change
+	rendered      []string
+	width, height int
+}
+
+func (self *handler) update_size() {
+	if sz, err := self.lp.ScreenSize(); err == nil {
+		self.width, self.height = int(sz.WidthCells), int(sz.HeightCells)
+	}
+}
+
+func (self *handler) command() *exec.Cmd {
+	var cmd *exec.Cmd
+	if len(self.args) == 1 {
+		cmd = exec.Command("/bin/sh", "-c", self.args[0])
+	} else {
+		cmd = exec.Command(self.args[0], self.args[1:]...)
+	}
+	cmd.Env = append(os.Environ(), "COLUMNS="+strconv.Itoa(self.width), "LINES="+strconv.Itoa(self.height))
+	return cmd
+}
+
+func (self *handler) start_run() {
+	if self.running {
+		return
+	}
+	if self.timer_id != 0 {
+		self.lp.RemoveTimer(self.timer_id)
+		self.timer_id = 0
+	}
+	self.running = true
+	self.update_size()
+	cmd := self.command()
+	go func() {
+		r := run_result{}
+		output, err := cmd.CombinedOutput()
+		r.output = utils.UnsafeBytesToString(output)
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			r.exit_code = ee.ExitCode()
+		} else if err != nil {
+			r.output, r.exit_code = err.Error(), -1
+		}
+		r.finished = time.Now()
+		self.results <- r
+		self.lp.WakeupMainThread()
+	}()
+	self.draw_screen(false)
+}
+
+func (self *handler) schedule_next_run() {
+	if self.paused || self.timer_id != 0 {
+		return
+	}
+	self.timer_id, _ = self.lp.AddTimer(self.interval, false, func(loop.IdType) error {
+		self.timer_id = 0
+		self.start_run()
+		return nil
+	})
+}
+
+func (self *handler) notify(title, body string) {
+	enc := base64.StdEncoding.EncodeToString
+	self.lp.QueueWriteString("\x1b]99;i=kitten-watch:d=0:e=1;" + enc([]byte(title)) + "\x1b\\")
+	self.lp.QueueWriteString("\x1b]99;i=kitten-watch:d=1:e=1:p=body;" + enc([]byte(body)) + "\x1b\\")
+}
+
+func (self *handler) on_wakeup() error {
+	for {
+		select {
+		case r := <-self.results:
+			self.running = false
+			lines := output_lines(r.output)
+			changed := self.has_run && (!slices.Equal(lines, self.current) || r.exit_code != self.last.exit_code)
+			if self.has_run {
+				self.previous = self.current
+			}
+			self.current, self.last, self.has_run = lines, r, true
+			if changed {
+				self.output_changed = true
+				if self.opts.Notify {
+					self.notify("Output changed", "The output of "+strings.Join(self.args, " ")+" has changed")
+				}
+				if self.opts.ExitOnChange {
+					self.draw_screen(false)
+					self.lp.Quit(0)
+					return nil
+				}
+			}
+			self.draw_screen(false)
+			self.schedule_next_run()
+		default:
+			return nil
+		}
+	}
+}
+
+func (self *handler) header() string {
+	left := fmt.Sprintf("Every %gs: %s", self.interval.Seconds(), strings.Join(self.args, " "))
+	status := ""
+	switch {
+	case self.paused:
+		status = "[paused] "
+	case self.running:
+		status = "[running] "
+	case self.has_run && self.last.exit_code != 0:
+		status = fmt.Sprintf("[exit code: %d] ", self.last.exit_code)
+	}
+	right := status + utils.Hostname() + ": "
+	if self.has_run {
+		right += self.last.finished.Format(time.DateTime)
+	}
+	avail := self.width - wcswidth.Stringwidth(right) - 1
+	if avail < 1 {
+		return wcswidth.TruncateToVisualLength(left, self.width)
+	}
+	left = wcswidth.TruncateToVisualLength(left, avail)
+	return self.lp.SprintStyled("bold", left) + strings.Repeat(" ", max(1, self.width-wcswidth.Stringwidth(left)-wcswidth.Stringwidth(right))) + right
+}
+
+func (self *handler) screen_lines() []string {
+	ans := make([]string, 0, self.height)
+	if !self.opts.NoTitle {
+		ans = append(ans, self.header(), "")
+	}
+	truncate := func(x string) string { return wcswidth.TruncateToVisualLength(x, self.width) }
+	for i, line := range self.current {
+		if len(ans) >= self.height {
+			break
+		}
+		line = truncate(line)
+		if self.show_differences && self.previous != nil {
+			prev := ""
+			if i < len(self.previous) {
+				prev = truncate(self.previous[i])
+			}
+			line = highlight_changes(prev, line, func(a ...any) string { return self.lp.SprintStyled("reverse", a...) })
+		}
+		ans = append(ans, line)
+	}
+	for len(ans) < self.height {
+		ans = append(ans, "")
+	}
+	return ans[:self.height]
+}
+
+func (self *handler) draw_screen(full bool) {
+	self.update_size()
+	lines := self.screen_lines()
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	if full || len(self.rendered) != len(lines) {
+		self.lp.ClearScreen()
+		self.rendered = make([]string, len(lines))
+	}
+	for i, line := range lines {
+		if line != self.rendered[i] {
+			self.lp.MoveCursorTo(1, i+1)
+			self.lp.QueueWriteString(line)
+			self.lp.ClearToEndOfLine()
+			self.rendered[i] = line
+		}
+	}
+}
+
+func (self *handler) on_key_event(ev *loop.KeyEvent) error {
+	ev.Handled = true
+	switch {
+	case ev.MatchesPressOrRepeat("esc") || ev.MatchesPressOrRepeat("q"):
+		self.lp.Quit(0)
+	case ev.MatchesPressOrRepeat("space") || ev.MatchesPressOrRepeat("p"):
+		self.paused = !self.paused
+		if self.paused {
+			if self.timer_id != 0 {
+				self.lp.RemoveTimer(self.timer_id)
+				self.timer_id = 0
+			}
+			self.draw_screen(false)
+		} else {
+			self.start_run()
+		}
+	case ev.MatchesPressOrRepeat("r"):
+		self.start_run()
+	case ev.MatchesPressOrRepeat("d"):
+		self.show_differences = !self.show_differences
+		self.draw_screen(false)
+	default:
+		ev.Handled = false
+	}
+	return nil
+}
+
+func main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if len(args) == 0 {
+		return 1, fmt.Errorf("Must specify the command to run")
+	}
+	if opts.Interval < 0.1 {
+		return 1, fmt.Errorf("The interval must be at least 0.1 seconds")
+	}
+	lp, err := loop.New()
+	if err != nil {
+		return 1, err
+	}
+	h := handler{
+		lp: lp, opts: opts, args: args, interval: time.Duration(opts.Interval * float64(time.Second)),
+		results: make(chan run_result, 1), show_differences: opts.Differences,
+	}
+	lp.OnInitialize = func() (string, error) {
+		lp.SetCursorVisible(false)
+		lp.AllowLineWrapping(false)
+		lp.SetWindowTitle("watch " + strings.Join(args, " "))
+		h.start_run()
+		return "", nil
+	}
+	lp.OnFinalize = func() string {
+		lp.SetCursorVisible(true)
+		return ""
+	}
+	lp.OnWakeup = h.on_wakeup
+	lp.OnKeyEvent = h.on_key_event
+	lp.OnResize = func(_, _ loop.ScreenSize) error {
+		h.draw_screen(true)
+		return nil
+	}
+	err = lp.Run()
+	if err != nil {
+		return 1, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return 1, nil
+	}
+	if opts.ExitOnChange && h.output_changed {
+		// like watch -g, leave the final output on screen
+		fmt.Println(strings.Join(h.current, "\n"))
+	}
+	return lp.ExitCode(), nil
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
@@ -0,0 +1,52 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+from typing import List
+
+help_text = '''\
+Run a command repeatedly, showing its output full screen. Only the parts of the screen that changed are redrawn and,
+optionally, the changes since the previous run are highlighted. If a single argument is specified, it is run
+using the shell, otherwise the arguments are executed directly.
+'''
+usage = 'command [args ...]'
+OPTIONS = r'''
+--interval -n
+type=float
+default=2
+The number of seconds to wait between runs of the command.
+
+
+--differences -d
+type=bool-set
+Highlight the changes in output since the previous run. Can be toggled with the :kbd:`d` key while running.
+
+
+--notify
+type=bool-set
+Send a desktop notification when the output of the command changes.
+
+
+--exit-on-change -g
+type=bool-set
+Exit when the output of the command changes.
+
+
+--no-title -t
+type=bool-set
+Do not show the header line with the command, interval and current time.
+'''.format
+
+
+def main(args: List[str]) -> None:
+    raise SystemExit('This must be run as kitten watch')
+
+
+if __name__ == '__main__':
+    main(sys.argv)
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Run a command periodically, highlighting changes in its output'
@@ -0,0 +1,75 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package watch
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+const tab_width = 8
+
+// Convert the output of the command into lines suitable for display, removing
+// escape codes and control characters and expanding tabs
+func output_lines(output string) []string {
+	output = wcswidth.StripEscapeCodes(strings.ReplaceAll(output, "\r\n", "\n"))
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	for i, line := range lines {
+		if strings.IndexFunc(line, unicode.IsControl) < 0 {
+			continue
+		}
+		b := strings.Builder{}
+		col := 0
+		for _, ch := range line {
+			switch {
+			case ch == '\t':
+				n := tab_width - col%tab_width
+				b.WriteString(strings.Repeat(" ", n))
+				col += n
+			case ch == '\r':
+				// treat carriage returns as in a terminal, later text overwrites
+				// earlier text, approximated by discarding the earlier text
+				b.Reset()
+				col = 0
+			case unicode.IsControl(ch):
+			default:
+				b.WriteRune(ch)
+				col += wcswidth.TerminalRunewidth(ch)
+			}
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+// Highlight the characters in current that differ from those at the same
+// position in previous
+func highlight_changes(previous, current string, highlight func(...any) string) string {
+	prev := []rune(previous)
+	b := strings.Builder{}
+	changed := []rune{}
+	flush := func() {
+		if len(changed) > 0 {
+			b.WriteString(highlight(string(changed)))
+			changed = changed[:0]
+		}
+	}
+	for i, ch := range []rune(current) {
+		if i >= len(prev) || prev[i] != ch {
+			changed = append(changed, ch)
+		} else {
+			flush()
+			b.WriteRune(ch)
+		}
+	}
+	flush()
+	return b.String()
+}
@@ -0,0 +1,33 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package watch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestWatchScreen(t *testing.T) {
+	if diff := cmp.Diff([]string{"a       b", "red", "progress 100%"}, output_lines("a\tb\r\n\x1b[31mred\x1b[m\nprogress 10%\rprogress 100%\n\n")); diff != "" {
+		t.Fatalf("Incorrect output lines:\n%s", diff)
+	}
+	if q := output_lines("\n"); q != nil {
+		t.Fatalf("Empty output not handled: %#v", q)
+	}
+	hl := func(a ...any) string { return "[" + fmt.Sprint(a...) + "]" }
+	for _, x := range []struct{ prev, cur, expected string }{
+		{"12:00:01", "12:00:59", "12:00:[59]"},
+		{"same", "same", "same"},
+		{"short", "shorter", "short[er]"},
+		{"", "new", "[new]"},
+		{"abcd", "xbcy", "[x]bc[y]"},
+	} {
+		if q := highlight_changes(x.prev, x.cur, hl); q != x.expected {
+			t.Fatalf("Incorrect highlighting of %#v -> %#v: %#v", x.prev, x.cur, q)
+		}
+	}
+}
@@ -100,6 +100,7 @@
     os_window_font_size,
     patch_global_colors,
     redirect_mouse_handling,
+    remove_timer,
     ring_bell,
     run_with_activation_token,
     safe_pipe,
@@ -115,11 +116,12 @@
     toggle_fullscreen,
     toggle_maximized,
     toggle_secure_input,
+    viewport_for_window,
     wrapped_kitten_names,
 )
 from .key_encoding import get_name_to_functional_number_map
 from .keys import Mappings
-from .layout.base import set_layout_options
+from .layout.base import resize_to_size, set_layout_options
 from .notify import notification_activated
 from .options.types import Options
 from .options.utils import MINIMUM_FONT_SIZE, KeyboardMode, KeyDefinition
@@ -1497,15 +1499,123 @@ def resize_layout_window(self, window: Window, increment: float, is_horizontal:
             return None
         return tab.resize_window_by(window.id, increment, is_horizontal)
 
-    def resize_os_window(self, os_window_id: int, width: int, height: int, unit: str, incremental: bool = False) -> None:
+    def resize_layout_window_to(
+        self, window: Window, width: str = '', height: str = '', keep_aspect: bool = False, animate: float = 0,
+        on_done: Optional[Callable[[Optional[str]], None]] = None,
+    ) -> Optional[str]:
+        ''' Resize the window to the specified size in cells or, if the size ends with %, as a percentage of the
+        size of the tab. If keep_aspect is True and only one of width or height is specified, the other is changed
+        to preserve the aspect ratio of the window. Returns an error message on failure. When animating, failure to
+        resize is instead passed to on_done, which is called once the animation is complete. '''
+        tab = window.tabref()
+        if tab is None:
+            return 'Window has no tab'
+        central, tab_bar, vw, vh, cell_width, cell_height = viewport_for_window(window.os_window_id)
+        cell_width, cell_height = max(1, cell_width), max(1, cell_height)
+        cols, lines = window.screen.columns, window.screen.lines
+        g = window.geometry
+
+        def target(spec: str, tab_size: int, window_size: int, cell_size: int, cells: int) -> Optional[int]:
+            if not spec:
+                return None
+            if spec.endswith('%'):
+                # As for the biases used by the layouts, percentages are of the size of the tab including the
+                # borders, margins and padding of the window
+                decorations = window_size - cells * cell_size
+                ans = round((float(spec[:-1]) * tab_size / 100 - decorations) / cell_size)
+            else:
+                ans = int(spec)
+            if ans < 1:
+                raise ValueError(f'Invalid window size: {spec}')
+            return ans
+
+        try:
+            tcols = target(width, central.width, g.right - g.left, cell_width, cols)
+            tlines = target(height, central.height, g.bottom - g.top, cell_height, lines)
+        except ValueError as err:
+            return str(err)
+        if keep_aspect and cols and lines:
+            if tcols is None and tlines is not None:
+                tcols = round(tlines * cols / lines)
+            elif tlines is None and tcols is not None:
+                tlines = round(tcols * lines / cols)
+        dx = 0 if tcols is None else tcols - cols
+        dy = 0 if tlines is None else tlines - lines
+        wid = window.id
+
+        def current_size(is_horizontal: bool) -> int:
+            w = self.window_id_map.get(wid)
+            if w is None:
+                return 0
+            return w.screen.columns if is_horizontal else w.screen.lines
+
+        def finish() -> Optional[str]:
+            if wid not in self.window_id_map:
+                return 'Window was closed'
+            for t, is_horizontal in ((tcols, True), (tlines, False)):
+                if t is not None:
+                    err = resize_to_size(partial(current_size, is_horizontal), partial(tab.resize_window_by, wid, is_horizontal=is_horizontal), t)
+                    if err:
+                        return err
+            return None
+
+        if animate <= 0:
+            return finish()
+        done = 0.
+
+        def step(progress: float) -> None:
+            nonlocal done
+            frac, done = progress - done, progress
+            if progress >= 1:
+                # reach the exact size, which the approximate steps of the animation can miss
+                err = finish()
+                if on_done is not None:
+                    on_done(err)
+                return
+            if wid in self.window_id_map:
+                for delta, is_horizontal in ((dx, True), (dy, False)):
+                    if delta:
+                        tab.resize_window_by(wid, delta * frac, is_horizontal)
+
+        self.animate(animate, step)
+        return None
+
+    def animate(self, duration: float, callback: Callable[[float], None]) -> None:
+        ''' Call callback with the progress, from 0 to 1, of an animation lasting duration seconds, about 60 times a
+        second. The last call is guaranteed to have a progress of 1. '''
+        if duration <= 0:
+            callback(1)
+            return
+        start = monotonic()
+
+        def tick(timer_id: Optional[int]) -> None:
+            progress = min(1., (monotonic() - start) / duration)
+            callback(progress)
+            if progress >= 1 and timer_id is not None:
+                remove_timer(timer_id)
+
+        add_timer(tick, 1 / 60, True)
+
+    def resize_os_window(
+        self, os_window_id: int, width: int, height: int, unit: str, incremental: bool = False, keep_aspect: bool = False,
+        animate: float = 0
+    ) -> None:
         if not incremental and (width < 0 or height < 0):
             return
         metrics = get_os_window_size(os_window_id)
         if metrics is None:
             return
         has_window_scaling = is_macos or is_wayland()
-        w, h = get_new_os_window_size(metrics, width, height, unit, incremental, has_window_scaling)
-        set_os_window_size(os_window_id, w, h)
+        w, h = get_new_os_window_size(metrics, width, height, unit, incremental, has_window_scaling, keep_aspect)
+        if animate > 0:
+            ow, oh = metrics['width'], metrics['height']
+
+            def step(progress: float) -> None:
+                set_os_window_size(os_window_id, round(ow + (w - ow) * progress), round(oh + (h - oh) * progress))
+
+            self.animate(animate, step)
+        else:
+            set_os_window_size(os_window_id, w, h)
 
     def tab_for_id(self, tab_id: int) -> Optional[Tab]:
         for tm in self.os_window_map.values():
@@ -1879,6 +1989,8 @@ def run_kitten_with_metadata(
                         'output': CommandOutput.last_run, 'first_output': CommandOutput.first_on_screen,
                         'last_visited_output': CommandOutput.last_visited}[q[0]]
                     data = w.cmd_output(which, as_ansi='ansi' in q, add_wrap_markers='screen' in q).encode('utf-8')
+                elif type_of_input == 'window-list':
+                    data = json.dumps(list(self.list_os_windows(w))).encode('utf-8')
                 else:
                     raise ValueError(f'Unknown type_of_input: {type_of_input}')
             else:
@@ -2504,6 +2616,37 @@ def launch(self, *args: str) -> None:
         opts, args_ = parse_launch_args(args)
         launch(self, opts, args_)
 
+    @ac('misc', '''
+        Ask for a command line, with completion of executables and files, and
+        launch it in a new window/tab/etc.
+
+        The arguments are the same as for the :ac:`launch` action, for example::
+
+            map f1 prompt_and_launch --type=tab --cwd=current
+        ''')
+    def prompt_and_launch(self, *args: str) -> None:
+        result: str = ''
+
+        def callback_(res: Dict[str, Any], x: int, boss: Boss) -> None:
+            nonlocal result
+            result = res.get('response') or ''
+
+        def on_popup_overlay_removal(wid: int, boss: Boss) -> None:
+            if not result.strip():
+                return
+            import shlex
+            try:
+                cmd = shlex.split(result)
+            except ValueError as err:
+                self.show_error(_('Invalid command line'), str(err))
+                return
+            self.launch(*args, *cmd)
+
+        self.run_kitten_with_metadata(
+            'ask', ['--type=command', '--name=prompt-and-launch', '--message', _('Enter the command to run')],
+            custom_callback=callback_, default_data={'response': ''}, action_on_removal=on_popup_overlay_removal
+        )
+
     @ac('tab', 'Move the active tab forward')
     def move_tab_forward(self) -> None:
         tm = self.active_tab_manager
@@ -2878,8 +3021,11 @@ def chosen(ans: Union[None, int, str]) -> None:
 
         self.choose_entry('Choose an OS window to move the tab to', items, chosen)
 
-    def set_background_image(self, path: Optional[str], os_windows: Tuple[int, ...], configured: bool, layout: Optional[str], png_data: bytes = b'') -> None:
-        set_background_image(path, os_windows, configured, layout, png_data)
+    def set_background_image(
+        self, path: Optional[str], os_windows: Tuple[int, ...], configured: bool, layout: Optional[str], png_data: bytes = b'', opacity: float = -1,
+        windows: Tuple[Tuple[int, int, int], ...] = (),
+    ) -> None:
+        set_background_image(path, os_windows, configured, layout, png_data, opacity, windows)
         for os_window_id in os_windows:
             self.default_bg_changed_for(os_window_id)
 
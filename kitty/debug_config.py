@@ -269,7 +269,7 @@ def penv(k: str) -> None:
             p('\t' + k.ljust(35), styled(v, dim=True))
 
     for k in (
-        'PATH LANG KITTY_CONFIG_DIRECTORY KITTY_CACHE_DIRECTORY VISUAL EDITOR SHELL'
+        'PATH LANG KITTY_CONFIG_DIRECTORY KITTY_CACHE_DIRECTORY KITTY_DATA_DIRECTORY KITTY_STATE_DIRECTORY VISUAL EDITOR SHELL'
         ' GLFW_IM_MODULE KITTY_WAYLAND_DETECT_MODIFIERS DISPLAY WAYLAND_DISPLAY USER XCURSOR_SIZE'
     ).split():
         penv(k)
@@ -1,6 +1,6 @@
 import termios
 from ctypes import Array, c_ubyte
-from typing import Any, Callable, Dict, Iterator, List, NewType, Optional, Tuple, TypedDict, Union, overload
+from typing import Any, Callable, Dict, Iterator, List, NewType, Optional, Sequence, Tuple, TypedDict, Union, overload
 
 from kitty.boss import Boss
 from kitty.fonts import FontFeature
@@ -562,6 +562,10 @@ def glfw_primary_monitor_size() -> Tuple[int, int]:
     pass
 
 
+def glfw_get_monitors() -> Tuple[Tuple[str, int, int, int, int], ...]:
+    pass
+
+
 def set_default_window_icon(path: str) -> None:
     pass
 
@@ -619,7 +623,9 @@ def set_background_image(
     os_window_ids: Tuple[int, ...],
     configured: bool = True,
     layout_name: Optional[str] = None,
-    png_data: bytes = b''
+    png_data: bytes = b'',
+    opacity: float = -1,
+    window_ids: Tuple[Tuple[int, int, int], ...] = (),
 ) -> None:
     pass
 
@@ -838,6 +844,14 @@ def change_os_window_state(state: int, os_window_id: Optional[int] = 0) -> None:
     pass
 
 
+def set_os_window_mouse_passthrough(os_window_id: int, enabled: Optional[bool] = None) -> bool:
+    pass
+
+
+def set_os_window_input_region(os_window_id: int, regions: Sequence[Tuple[int, int, int, int]]) -> None:
+    pass
+
+
 def change_background_opacity(os_window_id: int, opacity: float) -> bool:
     pass
 
@@ -846,6 +860,23 @@ def background_opacity_of(os_window_id: int) -> Optional[float]:
     pass
 
 
+def set_os_window_decorations(os_window_id: int, hide_window_decorations: int = -1) -> bool:
+    pass
+
+
+def set_os_window_background_blur(os_window_id: int, radius: int = -1) -> bool:
+    pass
+
+
+class OSWindowChromeSettings(TypedDict):
+    hide_window_decorations: int
+    background_blur: int
+
+
+def os_window_chrome_settings(os_window_id: int) -> Optional[OSWindowChromeSettings]:
+    pass
+
+
 def read_command_response(fd: int, timeout: float, list: List[bytes]) -> None:
     pass
 
@@ -981,6 +1012,9 @@ class Line:
     def sprite_at(self, cell: int) -> Tuple[int, int, int]:
         pass
 
+    def last_char_has_wrapped_flag(self) -> bool:
+        pass
+
 
 def test_shape(line: Line,
                path: Optional[str] = None,
@@ -1028,12 +1062,17 @@ def create_test_font_group(sz: float, dpix: float,
 
 class HistoryBuf:
 
+    count: int
+
     def pagerhist_as_text(self, upto_output_start: bool = False) -> str:
         pass
 
     def pagerhist_as_bytes(self) -> bytes:
         pass
 
+    def line(self, num: int) -> Line:
+        pass
+
 
 class LineBuf:
 
@@ -224,6 +224,9 @@ load_glfw(const char* path) {
     *(void **) (&glfwSetWindowBlur_impl) = dlsym(handle, "glfwSetWindowBlur");
     if (glfwSetWindowBlur_impl == NULL) fail("Failed to load glfw function glfwSetWindowBlur with error: %s", dlerror());
 
+    *(void **) (&glfwSetWindowInputRegion_impl) = dlsym(handle, "glfwSetWindowInputRegion");
+    if (glfwSetWindowInputRegion_impl == NULL) fail("Failed to load glfw function glfwSetWindowInputRegion with error: %s", dlerror());
+
     *(void **) (&glfwSetWindowUserPointer_impl) = dlsym(handle, "glfwSetWindowUserPointer");
     if (glfwSetWindowUserPointer_impl == NULL) fail("Failed to load glfw function glfwSetWindowUserPointer with error: %s", dlerror());
 
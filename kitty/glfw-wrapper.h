@@ -1050,6 +1050,9 @@ typedef struct GLFWLayerShellConfig {
     const char *output_name;
     GLFWFocusPolicy focus_policy;
     unsigned size_in_cells;
+    unsigned requested_top_margin, requested_left_margin, requested_bottom_margin, requested_right_margin;
+    int requested_exclusive_zone;
+    unsigned override_exclusive_zone;
     void (*size_callback)(GLFWwindow *window, const struct GLFWLayerShellConfig *config, unsigned monitor_width, unsigned monitor_height, uint32_t *width, uint32_t *height);
 } GLFWLayerShellConfig;
 
@@ -1976,6 +1979,10 @@ typedef int (*glfwSetWindowBlur_func)(GLFWwindow*, int);
 GFW_EXTERN glfwSetWindowBlur_func glfwSetWindowBlur_impl;
 #define glfwSetWindowBlur glfwSetWindowBlur_impl
 
+typedef void (*glfwSetWindowInputRegion_func)(GLFWwindow*, const int*, unsigned int);
+GFW_EXTERN glfwSetWindowInputRegion_func glfwSetWindowInputRegion_impl;
+#define glfwSetWindowInputRegion glfwSetWindowInputRegion_impl
+
 typedef void (*glfwSetWindowUserPointer_func)(GLFWwindow*, void*);
 GFW_EXTERN glfwSetWindowUserPointer_func glfwSetWindowUserPointer_impl;
 #define glfwSetWindowUserPointer glfwSetWindowUserPointer_impl
@@ -946,9 +946,11 @@ intercept_cocoa_fullscreen(GLFWwindow *w) {
 #endif
 
 static void
-init_window_chrome_state(WindowChromeState *s, color_type active_window_bg, bool is_semi_transparent, float background_opacity) {
+init_window_chrome_state(WindowChromeState *s, color_type active_window_bg, const OSWindow *w) {
     zero_at_ptr(s);
-    const bool should_blur = background_opacity < 1.f && OPT(background_blur) > 0 && is_semi_transparent;
+    const float background_opacity = w->background_opacity;
+    const int background_blur = os_window_background_blur(w);
+    const bool should_blur = background_opacity < 1.f && background_blur > 0 && w->is_semi_transparent;
 #define SET_TCOL(val) \
         s->use_system_color = false; \
         switch (val & 0xff) { \
@@ -970,8 +972,8 @@ init_window_chrome_state(WindowChromeState *s, color_type active_window_bg, bool
 #else
     if (global_state.is_wayland) { SET_TCOL(OPT(wayland_titlebar_color)); }
 #endif
-    s->background_blur = should_blur ? OPT(background_blur) : 0;
-    s->hide_window_decorations = OPT(hide_window_decorations);
+    s->background_blur = should_blur ? background_blur : 0;
+    s->hide_window_decorations = os_window_hide_window_decorations(w);
     s->show_title_in_titlebar = (OPT(macos_show_window_title_in) & WINDOW) != 0;
     s->background_opacity = background_opacity;
 }
@@ -1016,7 +1018,7 @@ set_os_window_chrome(OSWindow *w) {
     }
 
     WindowChromeState new_state;
-    init_window_chrome_state(&new_state, bg, w->is_semi_transparent, w->background_opacity);
+    init_window_chrome_state(&new_state, bg, w);
     if (memcmp(&new_state, &w->last_window_chrome, sizeof(WindowChromeState)) != 0) {
         int width, height;
         glfwGetWindowSize(w->handle, &width, &height);
@@ -1073,13 +1075,13 @@ calculate_layer_shell_window_size(
     OSWindow *os_window = os_window_for_glfw_window(window);
     FONTS_DATA_HANDLE fonts_data = load_fonts_data(os_window ? os_window->fonts_data->font_sz_in_pts : OPT(font_size), xdpi, ydpi);
     if (config->edge == GLFW_EDGE_LEFT || config->edge == GLFW_EDGE_RIGHT) {
-        if (!*height) *height = monitor_height;
+        if (!*height) *height = monitor_height - MIN(monitor_height, config->requested_top_margin + config->requested_bottom_margin);
         double spacing = edge_spacing(GLFW_EDGE_LEFT) + edge_spacing(GLFW_EDGE_RIGHT);
         spacing *= xdpi / 72.;
         spacing += (fonts_data->cell_width * config->size_in_cells) / xscale;
         *width = (uint32_t)(1. + spacing);
     } else {
-        if (!*width) *width = monitor_width;
+        if (!*width) *width = monitor_width - MIN(monitor_width, config->requested_left_margin + config->requested_right_margin);
         double spacing = edge_spacing(GLFW_EDGE_TOP) + edge_spacing(GLFW_EDGE_BOTTOM);
         spacing *= ydpi / 72.;
         spacing += (fonts_data->cell_height * config->size_in_cells) / yscale;
@@ -1096,6 +1098,12 @@ translate_layer_shell_config(PyObject *p) {
     A(edge, PyLong_Check, PyLong_AsLong);
     A(focus_policy, PyLong_Check, PyLong_AsLong);
     A(size_in_cells, PyLong_Check, PyLong_AsLong);
+    A(requested_top_margin, PyLong_Check, PyLong_AsLong);
+    A(requested_left_margin, PyLong_Check, PyLong_AsLong);
+    A(requested_bottom_margin, PyLong_Check, PyLong_AsLong);
+    A(requested_right_margin, PyLong_Check, PyLong_AsLong);
+    A(requested_exclusive_zone, PyLong_Check, PyLong_AsLong);
+    A(override_exclusive_zone, PyBool_Check, PyLong_AsLong);
 #undef A
     return ans;
 }
@@ -1300,7 +1308,7 @@ create_os_window(PyObject UNUSED *self, PyObject *args, PyObject *kw) {
             warned = true;
         }
     }
-    init_window_chrome_state(&w->last_window_chrome, OPT(background), w->is_semi_transparent, w->background_opacity);
+    init_window_chrome_state(&w->last_window_chrome, OPT(background), w);
 #ifdef __APPLE__
     apply_window_chrome_state(w->handle, w->last_window_chrome, width, height, OPT(hide_window_decorations) != 0);
 #else
@@ -1763,6 +1771,36 @@ change_os_window_state(PyObject *self UNUSED, PyObject *args) {
     Py_RETURN_NONE;
 }
 
+static PyObject*
+set_os_window_mouse_passthrough(PyObject *self UNUSED, PyObject *args) {
+    id_type wid; PyObject *enabled = Py_None;
+    if (!PyArg_ParseTuple(args, "K|O", &wid, &enabled)) return NULL;
+    OSWindow *w = os_window_for_id(wid);
+    if (!w || !w->handle) Py_RETURN_FALSE;
+    bool passthrough = enabled == Py_None ? !glfwGetWindowAttrib(w->handle, GLFW_MOUSE_PASSTHROUGH) : PyObject_IsTrue(enabled);
+    glfwSetWindowAttrib(w->handle, GLFW_MOUSE_PASSTHROUGH, passthrough);
+    if (passthrough) { Py_RETURN_TRUE; }
+    Py_RETURN_FALSE;
+}
+
+static PyObject*
+set_os_window_input_region(PyObject *self UNUSED, PyObject *args) {
+    id_type wid; PyObject *regions;
+    if (!PyArg_ParseTuple(args, "KO", &wid, &regions)) return NULL;
+    OSWindow *w = os_window_for_id(wid);
+    if (!w || !w->handle) Py_RETURN_NONE;
+    RAII_PyObject(seq, PySequence_Fast(regions, "regions must be a sequence"));
+    if (!seq) return NULL;
+    size_t count = PySequence_Fast_GET_SIZE(seq);
+    RAII_ALLOC(int, rects, count ? malloc(sizeof(int) * 4 * count) : NULL);
+    if (count && !rects) return PyErr_NoMemory();
+    for (size_t i = 0; i < count; i++) {
+        if (!PyArg_ParseTuple(PySequence_Fast_GET_ITEM(seq, i), "iiii", rects + 4*i, rects + 4*i + 1, rects + 4*i + 2, rects + 4*i + 3)) return NULL;
+    }
+    glfwSetWindowInputRegion(w->handle, rects, count);
+    Py_RETURN_NONE;
+}
+
 void
 request_window_attention(id_type kitty_window_id, bool audio_bell) {
     OSWindow *w = os_window_for_kitty_window(kitty_window_id);
@@ -1823,6 +1861,24 @@ primary_monitor_size(PYNOARG) {
     return Py_BuildValue("ii", mode->width, mode->height);
 }
 
+static PyObject*
+get_monitors(PYNOARG) {
+    int count = 0;
+    GLFWmonitor** monitors = glfwGetMonitors(&count);
+    PyObject *ans = PyTuple_New(MAX(0, count));
+    if (!ans) return NULL;
+    for (int i = 0; i < count; i++) {
+        int x = 0, y = 0;
+        glfwGetMonitorPos(monitors[i], &x, &y);
+        const GLFWvidmode* mode = glfwGetVideoMode(monitors[i]);
+        const char *name = glfwGetMonitorName(monitors[i]);
+        PyObject *m = Py_BuildValue("siiii", name ? name : "", x, y, mode ? mode->width : 0, mode ? mode->height : 0);
+        if (!m) { Py_DECREF(ans); return NULL; }
+        PyTuple_SET_ITEM(ans, i, m);
+    }
+    return ans;
+}
+
 static PyObject*
 primary_monitor_content_scale(PYNOARG) {
     GLFWmonitor* monitor = glfwGetPrimaryMonitor();
@@ -2228,6 +2284,8 @@ static PyMethodDef module_methods[] = {
     METHODB(toggle_fullscreen, METH_VARARGS),
     METHODB(toggle_maximized, METH_VARARGS),
     METHODB(change_os_window_state, METH_VARARGS),
+    METHODB(set_os_window_mouse_passthrough, METH_VARARGS),
+    METHODB(set_os_window_input_region, METH_VARARGS),
     METHODB(glfw_window_hint, METH_VARARGS),
     METHODB(x11_display, METH_NOARGS),
     METHODB(wayland_compositor_data, METH_NOARGS),
@@ -2252,6 +2310,7 @@ static PyMethodDef module_methods[] = {
     {"glfw_get_key_name", (PyCFunction)glfw_get_key_name, METH_VARARGS, ""},
     {"glfw_primary_monitor_size", (PyCFunction)primary_monitor_size, METH_NOARGS, ""},
     {"glfw_primary_monitor_content_scale", (PyCFunction)primary_monitor_content_scale, METH_NOARGS, ""},
+    {"glfw_get_monitors", (PyCFunction)get_monitors, METH_NOARGS, ""},
     {NULL, NULL, 0, NULL}        /* Sentinel */
 };
 
@@ -92,6 +92,9 @@ typedef struct {
     unsigned int height, width;
     uint8_t* bitmap;
     uint32_t refcnt;
+    BackgroundImageLayout layout;
+    // a negative value means use the background_opacity option
+    float opacity;
 } BackgroundImage;
 
 typedef struct {
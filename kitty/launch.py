@@ -5,7 +5,8 @@
 import os
 import shutil
 from contextlib import suppress
-from typing import Any, Container, Dict, FrozenSet, Iterable, Iterator, List, NamedTuple, Optional, Sequence, Tuple
+from functools import partial
+from typing import TYPE_CHECKING, Any, Container, Dict, FrozenSet, Iterable, Iterator, List, NamedTuple, Optional, Sequence, Tuple
 
 from .boss import Boss
 from .child import Child
@@ -24,6 +25,9 @@
 except ImportError:
     TypedDict = dict
 
+if TYPE_CHECKING:
+    import subprocess
+
 
 class LaunchSpec(NamedTuple):
     opts: LaunchCLIOptions
@@ -306,10 +310,12 @@ def options_spec() -> str:
 
 --watcher -w
 type=list
-completion=type:file ext:py relative:conf group:"Python scripts"
+completion=type:file ext:py,star relative:conf group:"Watcher scripts"
 Path to a Python file. Appropriately named functions in this file will be called
-for various events, such as when the window is resized, focused or closed. See
-the section on watchers in the launch command documentation: :ref:`watchers`.
+for various events, such as when the window is resized, focused or closed. Files
+with the :file:`.star` extension are run as :ref:`starlark watchers
+<starlark_watchers>` instead. See the section on watchers in the launch command
+documentation: :ref:`watchers`.
 Relative paths are resolved relative to the :ref:`kitty config directory
 <confloc>`. Global watchers for all windows can be specified with
 :opt:`watcher` in :file:`kitty.conf`.
@@ -369,6 +375,71 @@ def create_tab(tm: Optional[TabManager] = None) -> Tab:
 watcher_modules: Dict[str, Any] = {}
 
 
+class StarlarkWatcher:
+
+    ''' Watchers written in starlark are run by a kitten process that is sent
+    the events as JSON, one per line, on its STDIN '''
+
+    def __init__(self, path: str):
+        self.path = path
+        self.process: Optional['subprocess.Popen[bytes]'] = None
+        self.pending = b''
+        self.flush_timer = 0
+
+    def callbacks(self) -> Dict[str, Any]:
+        import re
+        with open(self.path) as f:
+            names = re.findall(r'^def\s+(on_[a-z_]+)\s*\(', f.read(), flags=re.MULTILINE)
+        return {name: partial(self, name) for name in names}
+
+    def ensure_process(self) -> 'subprocess.Popen[bytes]':
+        if self.process is None or self.process.poll() is not None:
+            import subprocess
+
+            from .constants import clear_handled_signals, kitten_exe
+            if self.process is not None:
+                log_error(f'The hook runner for the watcher {self.path} exited with code: {self.process.returncode}, restarting it')
+            env = os.environ.copy()
+            boss = get_boss()
+            if boss.listening_on:
+                env['KITTY_LISTEN_ON'] = boss.listening_on
+            self.pending = b''
+            self.process = subprocess.Popen([kitten_exe(), '__hook_runner__', self.path], stdin=subprocess.PIPE, env=env, preexec_fn=clear_handled_signals)
+            assert self.process.stdin is not None
+            os.set_blocking(self.process.stdin.fileno(), False)
+        return self.process
+
+    def __call__(self, which: str, boss: Boss, window: Window, data: Dict[str, Any]) -> None:
+        import json
+        w = {
+            'id': window.id, 'title': window.title, 'pid': window.child.pid, 'cwd': window.get_cwd_of_child() or '',
+            'cmdline': window.child.cmdline, 'user_vars': window.user_vars, 'at_prompt': window.at_prompt,
+            'is_focused': window.is_focused, 'lines': window.screen.lines, 'columns': window.screen.columns,
+        }
+        self.ensure_process()
+        self.pending += json.dumps({'event': which, 'window': w, 'data': data}, default=str).encode() + b'\n'
+        self.flush()
+        if len(self.pending) > 1024 * 1024:
+            log_error(f'The hook runner for the watcher {self.path} is not processing events, dropping them')
+            self.pending = b''
+
+    def flush(self, timer_id: Optional[int] = None) -> None:
+        if timer_id is not None:
+            self.flush_timer = 0
+        if not self.pending or self.process is None or self.process.stdin is None:
+            return
+        try:
+            self.pending = self.pending[os.write(self.process.stdin.fileno(), self.pending):]
+        except BlockingIOError:
+            pass
+        except OSError as err:
+            log_error(f'Failed to send event to the hook runner for the watcher {self.path} with error: {err}')
+            self.pending = b''
+        if self.pending and not self.flush_timer:
+            # the pipe is full, try again once the hook runner has had a chance to read from it
+            self.flush_timer = add_timer(self.flush, 0.05, False)
+
+
 def load_watch_modules(watchers: Iterable[str]) -> Optional[Watchers]:
     if not watchers:
         return None
@@ -379,7 +450,10 @@ def load_watch_modules(watchers: Iterable[str]) -> Optional[Watchers]:
         m = watcher_modules.get(path, None)
         if m is None:
             try:
-                m = runpy.run_path(path, run_name='__kitty_watcher__')
+                if path.endswith('.star'):
+                    m = StarlarkWatcher(path).callbacks()
+                else:
+                    m = runpy.run_path(path, run_name='__kitty_watcher__')
             except Exception as err:
                 import traceback
                 log_error(traceback.format_exc())
@@ -407,6 +481,12 @@ def load_watch_modules(watchers: Iterable[str]) -> Optional[Watchers]:
         w = m.get('on_cmd_startstop')
         if callable(w):
             ans.on_cmd_startstop.append(w)
+        w = m.get('on_bell')
+        if callable(w):
+            ans.on_bell.append(w)
+        w = m.get('on_create')
+        if callable(w):
+            ans.on_create.append(w)
     return ans
 
 
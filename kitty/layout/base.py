@@ -3,7 +3,7 @@
 
 from functools import partial
 from itertools import repeat
-from typing import Any, Dict, Generator, Iterable, Iterator, List, NamedTuple, Optional, Sequence, Tuple, Union
+from typing import Any, Callable, Dict, Generator, Iterable, Iterator, List, NamedTuple, Optional, Sequence, Tuple, Union
 
 from kitty.borders import BorderColor
 from kitty.fast_data_types import Region, set_active_window, viewport_for_window
@@ -104,6 +104,25 @@ def calculate_cells_map(
     return cells_map
 
 
+def resize_to_size(current_size: Callable[[], int], resize_by: Callable[[int], Optional[str]], target: int, max_attempts: int = 32) -> Optional[str]:
+    # The bias increment for a cell is only approximate, so resize by the
+    # remaining number of cells until the target size is reached or the size
+    # stops changing
+    size = current_size()
+    seen = {size}
+    for i in range(max_attempts):
+        if size == target:
+            return None
+        err = resize_by(target - size)
+        if err:
+            return err
+        size = current_size()
+        if size in seen:
+            break
+        seen.add(size)
+    return f'Could not resize to {target} cells, the size reached is {size} cells'
+
+
 def layout_dimension(
     start_at: int, length: int, cell_length: int,
     decoration_pairs: DecorationPairs,
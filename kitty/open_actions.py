@@ -269,6 +269,11 @@ def default_launch_actions() -> Tuple[OpenAction, ...]:
 # Open ssh URLs with ssh command
 protocol ssh
 action launch --type=os-window ssh $URL
+
+# Run the actions from the macOS services installed by kitten macos-services
+protocol kitty
+url ^kitty:///launch/services/
+action launch --type=os-window kitten macos-services handle-url $URL
 '''.splitlines()))
 
 
@@ -1125,6 +1125,7 @@
 are undefined. When using :code:`titlebar-only`, it is useful to also set
 :opt:`window_margin_width` and :opt:`placement_strategy` to prevent the rounded
 corners from clipping text. Or use :code:`titlebar-and-corners`.
+The decorations can be changed at runtime with :ref:`at-set-window-decorations`.
 '''
     )
 
@@ -1479,6 +1480,7 @@
 Usually, values up to 64 work well. Note that this might cause performance issues,
 depending on how the platform implements it, so use with care. Currently supported
 on macOS and KDE.
+Can be changed at runtime with :ref:`at-set-background-blur`.
 ''')
 
 opt('background_image', 'none',
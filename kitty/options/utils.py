@@ -83,7 +83,7 @@ class InvalidMods(ValueError):
 @func_with_args(
     'pass_selection_to_program', 'new_window', 'new_tab', 'new_os_window',
     'new_window_with_cwd', 'new_tab_with_cwd', 'new_os_window_with_cwd',
-    'launch', 'mouse_handle_click', 'show_error',
+    'launch', 'mouse_handle_click', 'show_error', 'prompt_and_launch',
     )
 def shlex_parse(func: str, rest: str) -> FuncArgsType:
     return func, to_cmdline(rest)
@@ -134,7 +134,7 @@ def open_url_parse(func: str, rest: str) -> FuncArgsType:
     try:
         url = python_string(rest)
         tokens = urlparse(url)
-        if not all((tokens.scheme, tokens.netloc,)):
+        if not tokens.scheme or (not tokens.netloc and tokens.scheme != 'file'):
             raise ValueError('Invalid URL')
     except Exception:
         log_error('Ignoring invalid URL string: ' + rest)
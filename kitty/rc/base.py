@@ -86,6 +86,7 @@ def __call__(self, key: str, opt_name: Optional[str] = None, missing: Any = None
 
 MATCH_WINDOW_OPTION = '''\
 --match -m
+completion=type:special group:cli.CompleteWindowSearch
 The window to match. Match specifications are of the form: :italic:`field:query`.
 Where :italic:`field` can be one of: :code:`id`, :code:`title`, :code:`pid`, :code:`cwd`, :code:`cmdline`, :code:`num`,
 :code:`env`, :code:`var`, :code:`state`, :code:`neighbor`, and :code:`recent`.
@@ -130,6 +131,7 @@ def __call__(self, key: str, opt_name: Optional[str] = None, missing: Any = None
 '''
 MATCH_TAB_OPTION = '''\
 --match -m
+completion=type:special group:cli.CompleteTabSearch
 The tab to match. Match specifications are of the form: :italic:`field:query`.
 Where :italic:`field` can be one of: :code:`id`, :code:`index`, :code:`title`, :code:`window_id`, :code:`window_title`,
 :code:`pid`, :code:`cwd`, :code:`cmdline` :code:`env`, :code:`var`, :code:`state` and :code:`recent`.
@@ -22,14 +22,20 @@ class CreateMarker(RemoteCommand):
     short_desc = 'Create a marker that highlights specified text'
     desc = (
         'Create a marker which can highlight text in the specified window. For example:'
-        ' :code:`create_marker text 1 ERROR`. For full details see: :doc:`marks`'
+        ' :code:`create_marker text 1 ERROR`. The marker type is one of :code:`text`, :code:`itext`,'
+        ' :code:`regex` or :code:`iregex` followed by pairs of a mark group number, from 1 to 3, which'
+        ' selects the colors set by :opt:`mark1_background` and friends, and the text to mark.'
+        ' To mark errors and warnings case insensitively in different colors:'
+        ' :code:`create_marker itext 1 ERROR 2 WARNING`.'
+        ' For full details see: :doc:`marks`'
     )
     options_spec = MATCH_WINDOW_OPTION + '''\n
 --self
 type=bool-set
 Apply marker to the window this command is run in, rather than the active window.
 '''
-    args = RemoteCommand.Args(spec='MARKER SPECIFICATION', json_field='marker_spec', minimum_count=2)
+    args = RemoteCommand.Args(spec='MARKER SPECIFICATION', json_field='marker_spec', minimum_count=2,
+                              special_parse='parse_marker_spec(args)')
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
         if len(args) < 2:
@@ -2,7 +2,7 @@
 # License: GPLv3 Copyright: 2020, Kovid Goyal <kovid at kovidgoyal.net>
 
 
-from typing import TYPE_CHECKING, Optional
+from typing import TYPE_CHECKING, Iterable, List, Optional
 
 from kitty.fast_data_types import focus_os_window
 
@@ -15,11 +15,41 @@
 class FocusWindow(RemoteCommand):
     protocol_spec = __doc__ = '''
     match/str: The window to focus
+    previous/bool: Boolean indicating whether to focus the previously focused window
+    nth_recent/int: Focus the nth most recently focused window other than the currently focused one, ignored if less than one
+    toggle/bool: Boolean indicating whether to focus the previously focused window if the specified window is already focused
     '''
 
     short_desc = 'Focus the specified window'
-    desc = 'Focus the specified window, if no window is specified, focus the window this command is run inside.'
+    desc = (
+        'Focus the specified window, if no window is specified, focus the window this command is run inside.'
+        ' kitty keeps track of when every window was last focused, in all tabs and OS windows, which can be used to switch'
+        ' to recently focused windows with :option:`--previous` and :option:`--nth-recent`. The time of last focus is'
+        ' reported as :code:`last_focused_at` by :ref:`at-ls`.'
+    )
     options_spec = MATCH_WINDOW_OPTION + '''\n\n
+--previous
+type=bool-set
+Focus the most recently focused window other than the currently focused one. When used
+with :option:`--match`, only the matching windows are considered.
+
+
+--nth-recent
+type=int
+default=0
+Focus the nth most recently focused window, with one being the previously focused window,
+two the window focused before that and so on. The currently focused window is not counted.
+When used with :option:`--match`, only the matching windows are considered.
+
+
+--toggle
+type=bool-set
+If the specified window is already focused, focus the previously focused window instead.
+Useful for scripts that show and hide a scratchpad window with a single key press, for example::
+
+    kitten @ focus-window --toggle --match title:scratchpad
+
+
 --no-response
 type=bool-set
 default=false
@@ -28,15 +58,35 @@ class FocusWindow(RemoteCommand):
 '''
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
-        return {'match': opts.match}
+        return {'match': opts.match, 'previous': opts.previous, 'nth_recent': opts.nth_recent, 'toggle': opts.toggle}
 
     def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
-        for window in self.windows_for_match_payload(boss, window, payload_get):
-            if window:
-                os_window_id = boss.set_active_window(window)
-                if os_window_id:
-                    focus_os_window(os_window_id, True)
-                break
+        def focus_history(windows: Iterable[Window]) -> List[Window]:
+            # most recently focused first, windows that were never focused are not part of the history
+            return sorted((w for w in windows if w.last_focused_at), key=lambda w: w.last_focused_at, reverse=True)
+
+        def candidates() -> Iterable[Window]:
+            return self.windows_for_match_payload(boss, window, payload_get) if payload_get('match') else boss.all_windows
+
+        def nth_previous_window(windows: Iterable[Window], n: int = 1) -> Optional[Window]:
+            active = boss.active_window
+            history = [w for w in focus_history(windows) if w is not active]
+            return history[n-1] if n <= len(history) else None
+
+        target: Optional[Window] = None
+        nth_recent = payload_get('nth_recent') or 0
+        if payload_get('previous'):
+            target = nth_previous_window(candidates())
+        elif nth_recent > 0:
+            target = nth_previous_window(candidates(), nth_recent)
+        else:
+            target = next((w for w in self.windows_for_match_payload(boss, window, payload_get) if w), None)
+            if target is not None and payload_get('toggle') and target is boss.active_window and target.is_focused:
+                target = nth_previous_window(boss.all_windows)
+        if target is not None:
+            os_window_id = boss.set_active_window(target)
+            if os_window_id:
+                focus_os_window(os_window_id, True)
         return None
 
 
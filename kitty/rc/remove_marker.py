@@ -14,14 +14,14 @@ class RemoveMarker(RemoteCommand):
 
     protocol_spec = __doc__ = '''
     match/str: Which window to remove the marker from
-    self/bool: Boolean indicating whether to detach the window the command is run in
+    self/bool: Boolean indicating whether to remove the marker from the window the command is run in
     '''
 
     short_desc = 'Remove the currently set marker, if any.'
     options_spec = MATCH_WINDOW_OPTION + '''\n
 --self
 type=bool-set
-Apply marker to the window this command is run in, rather than the active window.
+Remove the marker from the window this command is run in, rather than the active window.
 '''
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
@@ -15,9 +15,11 @@ class ResizeOSWindow(RemoteCommand):
     self/bool: Boolean indicating whether to close the window the command is run in
     incremental/bool: Boolean indicating whether to adjust the size incrementally
     action/choices.resize.toggle-fullscreen.toggle-maximized: One of :code:`resize, toggle-fullscreen` or :code:`toggle-maximized`
-    unit/choices.cells.pixels: One of :code:`cells` or :code:`pixels`
+    unit/choices.cells.pixels.percent: One of :code:`cells`, :code:`pixels` or :code:`percent`
     width/int: Integer indicating desired window width
     height/int: Integer indicating desired window height
+    aspect/bool: Boolean indicating whether to preserve the aspect ratio when only one of width or height is specified
+    animate/float: The duration in seconds over which to animate the resize
     '''
 
     short_desc = 'Resize the specified OS Windows'
@@ -35,8 +37,9 @@ class ResizeOSWindow(RemoteCommand):
 
 --unit
 default=cells
-choices=cells,pixels
-The unit in which to interpret specified sizes.
+choices=cells,pixels,percent
+The unit in which to interpret specified sizes. :code:`percent` means a
+percentage of the size of the primary monitor.
 
 
 --width
@@ -57,6 +60,18 @@ class ResizeOSWindow(RemoteCommand):
 instead of absolute sizes.
 
 
+--aspect
+type=bool-set
+When only one of :option:`--width` or :option:`--height` is specified, change the other
+as well, to preserve the aspect ratio of the window.
+
+
+--animate
+type=float
+default=0
+Animate the resizing over the specified number of seconds, for example: :code:`0.2`.
+
+
 --self
 type=bool-set
 Resize the window this command is run in, rather than the active window.
@@ -73,7 +88,7 @@ def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: Arg
         return {
             'match': opts.match, 'action': opts.action, 'unit': opts.unit,
             'width': opts.width, 'height': opts.height, 'self': opts.self,
-            'incremental': opts.incremental
+            'incremental': opts.incremental, 'aspect': opts.aspect, 'animate': opts.animate,
         }
 
     def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
@@ -84,7 +99,8 @@ def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get:
                 if ac == 'resize':
                     boss.resize_os_window(
                         os_window_id, width=payload_get('width'), height=payload_get('height'),
-                        unit=payload_get('unit'), incremental=payload_get('incremental')
+                        unit=payload_get('unit'), incremental=payload_get('incremental'),
+                        keep_aspect=bool(payload_get('aspect')), animate=payload_get('animate') or 0,
                     )
                 elif ac == 'toggle-fullscreen':
                     boss.toggle_fullscreen(os_window_id)
@@ -3,6 +3,8 @@
 
 from typing import TYPE_CHECKING, Optional, Union
 
+from kitty.types import AsyncResponse
+
 from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
 
 if TYPE_CHECKING:
@@ -15,6 +17,10 @@ class ResizeWindow(RemoteCommand):
     self/bool: Boolean indicating whether to resize the window the command is run in
     increment/int: Integer specifying the resize increment
     axis/choices.horizontal.vertical.reset: One of :code:`horizontal, vertical` or :code:`reset`
+    width/str: The width to resize to, in cells or as a percentage ending with %
+    height/str: The height to resize to, in cells or as a percentage ending with %
+    aspect/bool: Boolean indicating whether to preserve the aspect ratio when only one of width or height is specified
+    animate/float: The duration in seconds over which to animate the resize
     '''
 
     short_desc = 'Resize the specified windows'
@@ -39,19 +45,63 @@ class ResizeWindow(RemoteCommand):
 The special value :code:`reset` will reset the layout to its default configuration.
 
 
+--width
+Resize the window to this width in cells. If the value ends with a :code:`%` it is
+interpreted as a percentage of the width of the tab, for example: :code:`50%`.
+When either this or :option:`--height` is specified, :option:`--increment` and
+:option:`--axis` are ignored.
+
+
+--height
+Resize the window to this height in cells. If the value ends with a :code:`%` it is
+interpreted as a percentage of the height of the tab.
+
+
+--aspect
+type=bool-set
+When only one of :option:`--width` or :option:`--height` is specified, change the other
+as well, to preserve the aspect ratio of the window.
+
+
+--animate
+type=float
+default=0
+Animate the resizing over the specified number of seconds, for example: :code:`0.2`.
+
+
 --self
 type=bool-set
 Resize the window this command is run in, rather than the active window.
 '''
     string_return_is_error = True
+    # animated resizes report failure once the animation is complete
+    is_asynchronous = True
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
-        return {'match': opts.match, 'increment': opts.increment, 'axis': opts.axis, 'self': opts.self}
+        return {
+            'match': opts.match, 'increment': opts.increment, 'axis': opts.axis, 'self': opts.self,
+            'width': opts.width, 'height': opts.height, 'aspect': opts.aspect, 'animate': opts.animate,
+        }
 
     def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
         windows = self.windows_for_match_payload(boss, window, payload_get)
         resized: Union[bool, None, str] = False
         if windows and windows[0]:
+            width, height = payload_get('width') or '', payload_get('height') or ''
+            if (width or height) and payload_get('axis') != 'reset':
+                animate = payload_get('animate') or 0
+                if animate <= 0:
+                    return boss.resize_layout_window_to(windows[0], width, height, keep_aspect=bool(payload_get('aspect')))
+                responder = self.create_async_responder(payload_get, window)
+
+                def on_done(err: Optional[str]) -> None:
+                    if err:
+                        responder.send_error(err)
+                    else:
+                        responder.send_data(None)
+
+                err = boss.resize_layout_window_to(windows[0], width, height, keep_aspect=bool(payload_get('aspect')), animate=animate, on_done=on_done)
+                return err or AsyncResponse()
             resized = boss.resize_layout_window(
                 windows[0], increment=payload_get('increment'), is_horizontal=payload_get('axis') == 'horizontal',
                 reset=payload_get('axis') == 'reset'
@@ -2,9 +2,21 @@
 # License: GPLv3 Copyright: 2020, Kovid Goyal <kovid at kovidgoyal.net>
 
 
-from typing import TYPE_CHECKING, Optional, Tuple, Union
+import re
+from typing import TYPE_CHECKING, List, Optional, Tuple, Union
 
-from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+from .base import (
+    MATCH_WINDOW_OPTION,
+    ArgsType,
+    Boss,
+    PayloadGetType,
+    PayloadType,
+    RCOptions,
+    RemoteCommand,
+    RemoteControlErrorWithoutTraceback,
+    ResponseType,
+    Window,
+)
 
 if TYPE_CHECKING:
     from kitty.cli_stub import ScrollWindowRCOptions as CLIOptions
@@ -13,10 +25,11 @@
 class ScrollWindow(RemoteCommand):
 
     protocol_spec = __doc__ = '''
-    amount+/list.scroll_amount: The amount to scroll, a two item list with the first item being \
+    amount/list.scroll_amount: The amount to scroll, a two item list with the first item being \
              either a number or the keywords, start and end. \
              And the second item being either 'p' for pages or 'l' for lines or 'u'
              for unscrolling by lines.
+    target/str: A target to scroll to, one of line:N, prompt:N or match:REGEX. Used instead of amount.
     match/str: The window to scroll
     '''
 
@@ -27,6 +40,7 @@ class ScrollWindow(RemoteCommand):
         ' argument of the form :italic:`<number>[unit][+-]`. For example, :code:`30` will scroll down 30 lines, :code:`2p-`'
         ' will scroll up 2 pages and :code:`0.5p`will scroll down half page. :code:`3u` will *unscroll* by 3 lines, which means that 3 lines will move from the'
         ' scrollback buffer onto the top of the screen.'
+        ' Alternately, use :option:`--target` to scroll to a precise position instead of by an amount.'
     )
     options_spec = MATCH_WINDOW_OPTION + '''\n
 --no-response
@@ -34,11 +48,28 @@ class ScrollWindow(RemoteCommand):
 default=false
 Don't wait for a response indicating the success of the action. Note that
 using this option means that you will not be notified of failures.
+
+
+--target
+Scroll so that the specified line is at the top of the screen, instead of
+scrolling by :italic:`SCROLL_AMOUNT`. Can be one of :code:`line:N` for the N-th
+line of the scrollback, counting from the oldest line if N is positive and from
+the bottom of the screen if negative. :code:`prompt:N` for the N-th shell
+prompt, counting from the oldest prompt if N is positive and from the most
+recent prompt if negative, so :code:`prompt:-2` shows the output of the
+previous command, requires :ref:`shell_integration`. :code:`match:REGEX` for
+the most recent line matching the specified regular expression (Python
+syntax).
 '''
-    args = RemoteCommand.Args(spec='SCROLL_AMOUNT', count=1, special_parse='parse_scroll_amount(args[0])', json_field='amount')
+    args = RemoteCommand.Args(spec='[SCROLL_AMOUNT]', special_parse='parse_scroll_args(args, options_scroll_window.Target)', json_field='amount')
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
-        if len(args) < 1:
+        if opts.target:
+            if args:
+                self.fatal('Cannot specify both a scroll amount and --target')
+            parse_scroll_target(opts.target)
+            return {'match': opts.match, 'target': opts.target, 'self': True}
+        if len(args) != 1:
             self.fatal('Scroll amount must be specified')
         amt = args[0]
         amount: Tuple[Union[str, float], Optional[str]] = (amt, None)
@@ -56,9 +87,13 @@ def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: Arg
 
     def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
         amt = payload_get('amount')
+        spec = payload_get('target')
+        target = parse_scroll_target(spec) if spec else None
         for window in self.windows_for_match_payload(boss, window, payload_get):
             if window:
-                if amt[0] in ('start', 'end'):
+                if target is not None:
+                    scroll_to_target(window, *target)
+                elif amt[0] in ('start', 'end'):
                     getattr(window, {'start': 'scroll_home'}.get(amt[0], 'scroll_end'))()
                 else:
                     amt, unit = amt
@@ -76,4 +111,71 @@ def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get:
         return None
 
 
+def parse_scroll_target(spec: str) -> Tuple[str, Union[int, 're.Pattern[str]']]:
+    kind, sep, val = spec.partition(':')
+    if not sep or kind not in ('line', 'prompt', 'match'):
+        raise RemoteControlErrorWithoutTraceback(f'Invalid scroll target: {spec}, must be one of line:N, prompt:N or match:REGEX')
+    if kind == 'match':
+        try:
+            return kind, re.compile(val)
+        except re.error as e:
+            raise RemoteControlErrorWithoutTraceback(f'Invalid regular expression in scroll target: {val} with error: {e}')
+    try:
+        num = int(val)
+    except ValueError:
+        num = 0
+    if num == 0:
+        raise RemoteControlErrorWithoutTraceback(f'Invalid scroll target: {spec}, N must be a non-zero integer')
+    return kind, num
+
+
+def all_lines(window: Window, as_ansi: bool = False) -> List[str]:
+    ' All lines in the scrollback and the screen, oldest first, one per physical line '
+    lines: List[str] = []
+    chunks: List[str] = []
+
+    def add(x: str) -> None:
+        if x == '\r':
+            lines.append(''.join(chunks).lstrip('\n'))
+            chunks.clear()
+        else:
+            chunks.append(x)
+
+    window.screen.as_text_for_history_buf(add, as_ansi, True)
+    chunks.clear()
+    window.screen.as_text_non_visual(add, as_ansi, True)
+    return lines
+
+
+def scroll_to_target(window: Window, kind: str, val: Union[int, 're.Pattern[str]']) -> None:
+    screen = window.screen
+    if not screen.is_main_linebuf():
+        return
+    idx = -1
+    if kind == 'line':
+        assert isinstance(val, int)
+        idx = val - 1 if val > 0 else screen.historybuf.count + screen.lines + val
+    elif kind == 'prompt':
+        assert isinstance(val, int)
+        mark = '\x1b]133;A\x1b\\'
+        prompts = [i for i, line in enumerate(all_lines(window, as_ansi=True)) if line.startswith(mark) or line.startswith('\x1b[m' + mark)]
+        n = val - 1 if val > 0 else val
+        if -len(prompts) <= n < len(prompts):
+            idx = prompts[n]
+        else:
+            raise RemoteControlErrorWithoutTraceback(f'No prompt number {val} found, there are only {len(prompts)} prompts')
+    else:
+        assert not isinstance(val, int)
+        for i, line in reversed(tuple(enumerate(all_lines(window)))):
+            if val.search(line) is not None:
+                idx = i
+                break
+        else:
+            raise RemoteControlErrorWithoutTraceback(f'No line matching: {val.pattern} found')
+    scrolled_by = max(0, min(screen.historybuf.count, screen.historybuf.count - idx))
+    window.scroll_end()
+    if scrolled_by:
+        screen.scroll(scrolled_by, True)
+
+
 scroll_window = ScrollWindow()
@@ -0,0 +1,119 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+import re
+from typing import TYPE_CHECKING, Dict, List, Optional, Tuple
+
+from kitty.types import AsyncResponse
+
+from .base import (
+    MATCH_WINDOW_OPTION,
+    ArgsType,
+    Boss,
+    PayloadGetType,
+    PayloadType,
+    RCOptions,
+    RemoteCommand,
+    RemoteControlError,
+    ResponseType,
+    Window,
+)
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import SearchScrollbackRCOptions as CLIOptions
+    from kitty.window import ScrollbackSearch
+
+
+class SearchScrollback(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    pattern+/str: The regular expression to search for
+    match/str: The window to search in
+    fixed_strings/bool: Boolean, if True the pattern is a literal string rather than a regular expression
+    ignore_case/bool: Boolean, if True search case insensitively
+    line_numbers/bool: Boolean, if True prefix every matching line with its line number
+    max_matches/int: The maximum number of matching lines to return, zero for no limit
+    self/bool: Boolean, if True use window the command was run in
+    '''
+
+    short_desc = 'Search the screen and scrollback of the specified window'
+    desc = (
+        'Search the text on the screen and in the scrollback of the specified window for lines matching'
+        ' the specified regular expression, printing the matching lines, oldest first. The search is'
+        ' performed inside kitty, in chunks, so it is fast even for very large scrollbacks,'
+        ' unlike fetching the text with :ref:`at-get-text` and searching it, and kitty remains responsive'
+        ' while it runs. The regular expression syntax is that of Python, matches do not span lines and'
+        ' :code:`^` and :code:`$` match at the start and end of every line.'
+    )
+    options_spec = MATCH_WINDOW_OPTION + '''\n
+--fixed-strings -F
+type=bool-set
+Interpret the pattern as a literal string rather than a regular expression.
+
+
+--ignore-case -i
+type=bool-set
+Search case insensitively.
+
+
+--line-numbers -n
+type=bool-set
+Prefix every matching line with its line number, counting from the start of the scrollback.
+
+
+--max-matches
+type=int
+default=0
+Stop searching after this many matching lines are found. The default of zero means no limit.
+
+
+--self
+type=bool-set
+Search the window this command is run in, rather than the active window.
+'''
+    args = RemoteCommand.Args(spec='PATTERN', count=1, json_field='pattern')
+    is_asynchronous = True
+    response_timeout = 120
+    searches: Dict[str, 'ScrollbackSearch'] = {}
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        if len(args) != 1:
+            self.fatal('Must specify exactly one PATTERN to search for')
+        return {
+            'pattern': args[0], 'match': opts.match, 'fixed_strings': opts.fixed_strings, 'ignore_case': opts.ignore_case,
+            'line_numbers': opts.line_numbers, 'max_matches': opts.max_matches, 'self': opts.self,
+        }
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        windows = self.windows_for_match_payload(boss, window, payload_get)
+        if windows and windows[0]:
+            window = windows[0]
+        else:
+            return None
+        pattern = payload_get('pattern') or ''
+        if payload_get('fixed_strings'):
+            pattern = re.escape(pattern)
+        try:
+            pat = re.compile(pattern, re.MULTILINE | (re.IGNORECASE if payload_get('ignore_case') else 0))
+        except re.error as err:
+            raise RemoteControlError(f'Invalid regular expression: {pattern} with error: {err}')
+        responder = self.create_async_responder(payload_get, window)
+        line_numbers = payload_get('line_numbers')
+
+        def callback(matches: List[Tuple[int, str]]) -> None:
+            self.searches.pop(responder.async_id, None)
+            if line_numbers:
+                responder.send_data('\n'.join(f'{n}:{line}' for n, line in matches))
+            else:
+                responder.send_data('\n'.join(line for n, line in matches))
+
+        self.searches[responder.async_id] = window.search_scrollback(pat, callback, max(0, payload_get('max_matches') or 0))
+        return AsyncResponse()
+
+    def cancel_async_request(self, boss: 'Boss', window: Optional['Window'], payload_get: PayloadGetType) -> None:
+        s = self.searches.pop(payload_get('async_id', missing=''), None)
+        if s is not None:
+            s.cancel()
+
+
+search_scrollback = SearchScrollback()
@@ -34,8 +34,12 @@ class SendKey(RemoteCommand):
         'Send arbitrary key presses to specified windows. All specified keys are sent first as press events'
         ' then as release events in reverse order. Keys are sent to the programs running in the windows.'
         ' They are sent only if the current keyboard mode for the program supports the particular key.'
-        ' For example: send-key ctrl+a ctrl+b. Note that errors are not reported, for technical reasons,'
-        ' so send-key always succeeds, even if no key was sent to any window.'
+        ' For example: send-key ctrl+a ctrl+b. To send individual events, prefix the key with the type of event,'
+        ' one of :code:`press`, :code:`repeat` or :code:`release`. Such keys are sent in order and no release events'
+        ' are synthesized for them. For example: send-key press:a repeat:a release:a. Keys that generate text,'
+        ' such as :code:`shift+a` are sent with the text they would generate on a US keyboard layout, so that programs'
+        ' using the :doc:`keyboard protocol </keyboard-protocol>` receive complete key events. Note that errors are not reported,'
+        ' for technical reasons, so send-key always succeeds, even if no key was sent to any window.'
    )
     # since send-key can send data over the tty to the window in which it was
     # run --no-reponse is always in effect for it, hence errors are not
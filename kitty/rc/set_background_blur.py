@@ -0,0 +1,97 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+
+from typing import TYPE_CHECKING, Optional, Tuple
+
+from .base import (
+    MATCH_TAB_OPTION,
+    MATCH_WINDOW_OPTION,
+    ArgsType,
+    Boss,
+    PayloadGetType,
+    PayloadType,
+    RCOptions,
+    RemoteCommand,
+    ResponseType,
+    Window,
+)
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import SetBackgroundBlurRCOptions as CLIOptions
+
+# The radius used when toggling blur on, if blur is not enabled in kitty.conf
+DEFAULT_BLUR_RADIUS = 32
+
+
+def parse_blur_arg(arg: str) -> Tuple[str, int]:
+    if arg in ('toggle', 'reset'):
+        return arg, 0
+    try:
+        radius = int(arg)
+    except Exception:
+        raise ValueError(f'{arg} is not a valid blur radius, must be a non-negative integer or toggle or reset')
+    if radius < 0:
+        raise ValueError(f'{arg} is not a valid blur radius, must not be negative')
+    return 'set', radius
+
+
+class SetBackgroundBlur(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    action+/choices.set.toggle.reset: Whether to set the blur radius, toggle blur or go back to the setting from kitty.conf
+    radius/int: The blur radius to set, zero turns off blur
+    match_window/str: Window to change blur in
+    match_tab/str: Tab to change blur in
+    all/bool: Boolean indicating operate on all windows
+    '''
+
+    short_desc = 'Set the background blur'
+    desc = (
+        'Set the blurring of the visuals behind the specified OS windows, overriding the :opt:`background_blur`'
+        ' setting in :file:`kitty.conf`. Specify either the blur radius, with zero turning off blur,'
+        f' :code:`toggle` to turn blur on or off, using the radius from :file:`kitty.conf` or {DEFAULT_BLUR_RADIUS}'
+        ' if blur is not enabled there, or :code:`reset` to go back to the setting from :file:`kitty.conf`.'
+        ' Blur only has an effect when the background is semi-transparent, see :opt:`background_opacity`,'
+        ' and on platforms that support it, currently macOS and KDE. For example::\n\n'
+        '    kitten @ set-background-blur toggle'
+    )
+    options_spec = '''\
+--all -a
+type=bool-set
+By default, background blur is only changed for the currently active OS window. This option will
+cause background blur to be changed in all OS windows.
+''' + '\n\n' + MATCH_WINDOW_OPTION + '\n\n' + MATCH_TAB_OPTION.replace('--match -m', '--match-tab -t')
+    args = RemoteCommand.Args(
+        spec='[RADIUS|toggle|reset]', json_field='action', value_if_unspecified=('toggle',),
+        special_parse='+radius:parse_background_blur(args, &payload)',
+        completion=RemoteCommand.CompletionSpec.from_string('type:keyword group:"Action" kwds:toggle,reset'))
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        if len(args) > 1:
+            self.fatal('At most one blur radius must be specified')
+        try:
+            action, radius = parse_blur_arg(args[0] if args else 'toggle')
+        except ValueError as e:
+            self.fatal(str(e))
+        return {'action': action, 'radius': radius, 'match_window': opts.match, 'all': opts.all, 'match_tab': opts.match_tab}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        from kitty.fast_data_types import get_options, os_window_chrome_settings, set_os_window_background_blur
+        action = payload_get('action') or 'toggle'
+        windows = self.windows_for_payload(boss, window, payload_get)
+        for os_window_id in {w.os_window_id for w in windows if w}:
+            if action == 'reset':
+                val = -1
+            elif action == 'toggle':
+                current = os_window_chrome_settings(os_window_id)
+                if current is None:
+                    continue
+                val = 0 if current['background_blur'] > 0 else (get_options().background_blur or DEFAULT_BLUR_RADIUS)
+            else:
+                val = max(0, int(payload_get('radius') or 0))
+            set_os_window_background_blur(os_window_id, val)
+        return None
+
+
+set_background_blur = SetBackgroundBlur()
@@ -4,7 +4,7 @@
 import os
 from base64 import standard_b64decode, standard_b64encode
 from io import BytesIO
-from typing import TYPE_CHECKING, Optional
+from typing import IO, TYPE_CHECKING, Optional, Tuple
 
 from kitty.types import AsyncResponse
 from kitty.utils import is_png
@@ -27,7 +27,7 @@
     from kitty.cli_stub import SetBackgroundImageRCOptions as CLIOptions
 
 
-layout_choices = 'tiled,scaled,mirror-tiled,clamped,configured'
+layout_choices = 'tiled,scaled,mirror-tiled,clamped,centered,cscaled,configured'
 
 
 class SetBackgroundImage(RemoteCommand):
@@ -37,6 +37,8 @@ class SetBackgroundImage(RemoteCommand):
     Or the special value - to indicate image must be removed.
     match/str: Window to change opacity in
     layout/choices.{layout_choices.replace(",", ".")}: The image layout
+    opacity/float: The opacity of the image, a number from :code:`0` to :code:`1`. Omitted or negative means use :opt:`background_opacity`
+    scope/choices.os-window.window.instance: What to set the image for, omitted means os-window
     all/bool: Boolean indicating operate on all windows
     configured/bool: Boolean indicating if the configured value should be changed
     '''
@@ -45,7 +47,9 @@ class SetBackgroundImage(RemoteCommand):
     desc = (
         'Set the background image for the specified OS windows. You must specify the path to a PNG image that'
         ' will be used as the background. If you specify the special value :code:`none` then any existing image will'
-        ' be removed.'
+        ' be removed. If you specify the special value :code:`-` the image data is read from STDIN, so that'
+        ' it can be piped in, useful when controlling a kitty instance on another computer. When using'
+        ' :program:`kitten @`, images in formats other than PNG are converted to PNG automatically.'
     )
     options_spec = f'''\
 --all -a
@@ -59,11 +63,28 @@ class SetBackgroundImage(RemoteCommand):
 Change the configured background image which is used for new OS windows.
 
 
+--scope
+choices=os-window,window,instance
+default=os-window
+What to set the background image for. :code:`os-window` sets it for the OS windows containing
+the matched windows, drawn behind all their windows. :code:`window` sets it for only the matched
+windows, drawn behind the text in each window. :code:`instance` sets it for all OS windows, including
+OS windows created later, the same as :option:`--all` and :option:`--configured` together.
+
+
 --layout
 type=choices
 choices={layout_choices}
 default=configured
 How the image should be displayed. A value of :code:`configured` will use the configured value.
+See :opt:`background_image_layout` for details of the other values.
+
+
+--opacity
+type=float
+default=-1
+The opacity of the image, a number from :code:`0` to :code:`1`. The image is
+blended with the background color. A negative value means use the value of :opt:`background_opacity`.
 
 
 --no-response
@@ -85,17 +106,26 @@ def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: Arg
             'match': opts.match,
             'configured': opts.configured,
             'layout': opts.layout,
+            'opacity': opts.opacity,
+            'scope': opts.scope,
             'all': opts.all,
             'stream_id': secrets.token_urlsafe(),
         }
         if path.lower() == 'none':
             ret['data'] = '-'
             return ret
-        if not is_png(path):
-            self.fatal(f'{path} is not a PNG image')
+        if args[0] == '-':
+            import sys
+            f: IO[bytes] = BytesIO(sys.stdin.buffer.read())
+            if not f.getvalue().startswith(b'\211PNG\r\n\032\n'):
+                self.fatal('The data read from STDIN is not a PNG image')
+        else:
+            if not is_png(path):
+                self.fatal(f'{path} is not a PNG image, use kitten @ to have images in other formats converted automatically')
+            f = open(path, 'rb')
 
-        def file_pipe(path: str) -> CmdGenerator:
-            with open(path, 'rb') as f:
+        def file_pipe(f: IO[bytes]) -> CmdGenerator:
+            with f:
                 while True:
                     data = f.read(512)
                     if not data:
@@ -104,12 +134,23 @@ def file_pipe(path: str) -> CmdGenerator:
                     yield ret
             ret['data'] = ''
             yield ret
-        return file_pipe(path)
+        return file_pipe(f)
 
     def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
         data = payload_get('data')
-        windows = self.windows_for_payload(boss, window, payload_get, window_match_name='match')
-        os_windows = tuple({w.os_window_id for w in windows if w})
+        scope = payload_get('scope') or 'os-window'
+        configured = bool(payload_get('configured'))
+        kitty_windows: Tuple[Tuple[int, int, int], ...] = ()
+        if scope == 'instance':
+            os_windows = tuple(boss.os_window_map)
+            configured = True
+        else:
+            windows = self.windows_for_payload(boss, window, payload_get, window_match_name='match')
+            if scope == 'window':
+                os_windows = ()
+                kitty_windows = tuple((w.os_window_id, w.tab_id, w.id) for w in windows if w)
+            else:
+                os_windows = tuple({w.os_window_id for w in windows if w})
         layout = payload_get('layout')
         if data == '-':
             path = None
@@ -122,7 +163,9 @@ def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get:
             tfile = q
 
         try:
-            boss.set_background_image(path, os_windows, payload_get('configured'), layout, tfile.getvalue())
+            opacity = payload_get('opacity')
+            boss.set_background_image(
+                path, os_windows, configured, layout, tfile.getvalue(), -1 if opacity is None else opacity, kitty_windows)
         except ValueError as err:
             err.hide_traceback = True  # type: ignore
             raise
@@ -0,0 +1,81 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+from typing import TYPE_CHECKING, List, Optional, Tuple
+
+from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import SetClickThroughRCOptions as CLIOptions
+
+
+def parse_input_region(spec: str) -> Tuple[int, int, int, int]:
+    try:
+        x, y, width, height = map(int, spec.split(','))
+    except Exception:
+        raise ValueError(f'{spec} is not a valid input region, it must be of the form x,y,width,height')
+    if width < 0 or height < 0:
+        raise ValueError(f'{spec} is not a valid input region, the width and height must not be negative')
+    return x, y, width, height
+
+
+class SetClickThrough(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    action+/choices.toggle.on.off: Whether to turn click through on, off or toggle it
+    input_region/list.str: List of rectangles of the form x,y,width,height to restrict mouse input to when click through is off
+    match/str: Which windows to change click through for
+    self/bool: Boolean indicating whether to change click through for the window the command is run in
+    '''
+
+    short_desc = 'Make OS windows ignore mouse input'
+    desc = (
+        'Make the specified OS windows ignore mouse input, so that clicks and scrolling go to whatever'
+        ' is below the window. This is most useful with :doc:`panels </kittens/panel>` that are'
+        ' drawn over other windows. With no argument, click through is toggled.'
+        ' When turning click through off, mouse input can be restricted to just some'
+        ' regions of the OS window with :option:`kitten @ set-click-through --input-region`.'
+    )
+    options_spec = '''\
+--input-region
+type=list
+A rectangle of the form :italic:`x,y,width,height` in pixels, relative to the top left
+corner of the OS window, to which mouse input is restricted when click through is off.
+Can be specified multiple times. If not specified, the whole OS window accepts
+mouse input when click through is off.
+
+
+--self
+type=bool-set
+Change click through for the window this command is run in, rather than the active window.
+''' + '\n\n' + MATCH_WINDOW_OPTION
+    args = RemoteCommand.Args(
+        spec='[on|off|toggle]', json_field='action', value_if_unspecified=('toggle',),
+        completion=RemoteCommand.CompletionSpec.from_string('type:keyword group:"Action" kwds:on,off,toggle'))
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        if len(args) > 1:
+            self.fatal('At most one action must be specified')
+        action = args[0] if args else 'toggle'
+        if action not in ('on', 'off', 'toggle'):
+            self.fatal(f'{action} is not a valid action, must be one of: on, off or toggle')
+        for spec in opts.input_region:
+            try:
+                parse_input_region(spec)
+            except ValueError as e:
+                self.fatal(str(e))
+        return {'action': action, 'input_region': opts.input_region, 'match': opts.match, 'self': opts.self}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        from kitty.fast_data_types import set_os_window_input_region, set_os_window_mouse_passthrough
+        action = payload_get('action') or 'toggle'
+        regions: List[Tuple[int, int, int, int]] = [parse_input_region(x) for x in payload_get('input_region') or ()]
+        windows = self.windows_for_match_payload(boss, window, payload_get)
+        for os_window_id in {w.os_window_id for w in windows if w}:
+            enabled = None if action == 'toggle' else action == 'on'
+            if not set_os_window_mouse_passthrough(os_window_id, enabled) and regions:
+                set_os_window_input_region(os_window_id, regions)
+        return None
+
+
+set_click_through = SetClickThrough()
@@ -0,0 +1,80 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2024, Kovid Goyal <kovid at kovidgoyal.net>
+
+
+from typing import TYPE_CHECKING, Optional
+
+from .base import (
+    MATCH_TAB_OPTION,
+    MATCH_WINDOW_OPTION,
+    ArgsType,
+    Boss,
+    PayloadGetType,
+    PayloadType,
+    RCOptions,
+    RemoteCommand,
+    ResponseType,
+    Window,
+)
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import SetWindowDecorationsRCOptions as CLIOptions
+
+
+class SetWindowDecorations(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    action+/choices.yes.no.titlebar-only.titlebar-and-corners.toggle.reset: How to change the window decorations
+    match_window/str: Window to change decorations for
+    match_tab/str: Tab to change decorations for
+    all/bool: Boolean indicating operate on all windows
+    '''
+
+    short_desc = 'Show or hide OS window decorations'
+    desc = (
+        'Show or hide the decorations (title-bar and window borders) of the specified OS windows, overriding the'
+        ' :opt:`hide_window_decorations` setting in :file:`kitty.conf`. The values have the same meaning as for that'
+        ' setting. :code:`toggle` hides the decorations if they are shown, otherwise shows them, and :code:`reset`'
+        ' goes back to using the setting from :file:`kitty.conf`. For example::\n\n'
+        '    kitten @ set-window-decorations toggle\n\n'
+        'Whether this works and exactly what effect it has depends on the window manager/operating system.'
+    )
+    options_spec = '''\
+--all -a
+type=bool-set
+By default, decorations are only changed for the currently active OS window. This option will
+cause decorations to be changed in all OS windows.
+''' + '\n\n' + MATCH_WINDOW_OPTION + '\n\n' + MATCH_TAB_OPTION.replace('--match -m', '--match-tab -t')
+    args = RemoteCommand.Args(
+        spec='[yes|no|titlebar-only|titlebar-and-corners|toggle|reset]', json_field='action', value_if_unspecified=('toggle',),
+        completion=RemoteCommand.CompletionSpec.from_string(
+            'type:keyword group:"Action" kwds:yes,no,titlebar-only,titlebar-and-corners,toggle,reset'))
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        if len(args) > 1:
+            self.fatal('At most one action must be specified')
+        action = args[0] if args else 'toggle'
+        if action not in ('yes', 'no', 'titlebar-only', 'titlebar-and-corners', 'toggle', 'reset'):
+            self.fatal(f'{action} is not a valid action')
+        return {'action': action, 'match_window': opts.match, 'all': opts.all, 'match_tab': opts.match_tab}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        from kitty.fast_data_types import get_options, os_window_chrome_settings, set_os_window_decorations
+        from kitty.options.utils import hide_window_decorations
+        action = payload_get('action') or 'toggle'
+        windows = self.windows_for_payload(boss, window, payload_get)
+        for os_window_id in {w.os_window_id for w in windows if w}:
+            if action == 'reset':
+                val = -1
+            elif action == 'toggle':
+                current = os_window_chrome_settings(os_window_id)
+                if current is None:
+                    continue
+                val = 0 if current['hide_window_decorations'] else (get_options().hide_window_decorations or 1)
+            else:
+                val = hide_window_decorations(action)
+            set_os_window_decorations(os_window_id, val)
+        return None
+
+
+set_window_decorations = SetWindowDecorations()
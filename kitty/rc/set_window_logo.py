@@ -41,7 +41,7 @@ class SetWindowLogo(RemoteCommand):
     desc = (
         'Set the logo image for the specified windows. You must specify the path to a PNG image that'
         ' will be used as the logo. If you specify the special value :code:`none` then any existing logo will'
-        ' be removed.'
+        ' be removed. If you specify the special value :code:`-` the image data is read from STDIN.'
     )
 
     options_spec = MATCH_WINDOW_OPTION + '''\n
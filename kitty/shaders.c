@@ -441,22 +441,14 @@ cell_prepare_to_render(ssize_t vao_idx, Screen *screen, GLfloat xstart, GLfloat
 }
 
 static void
-draw_background_image(OSWindow *w) {
-    blank_canvas(w->is_semi_transparent ? OPT(background_opacity) : 1.0f, OPT(background));
+draw_bgimage(const BackgroundImage *bgimage, bool premult, GLfloat vwidth, GLfloat vheight, ImageRect r) {
     bind_program(BGIMAGE_PROGRAM);
 
     glUniform1i(bgimage_program_layout.uniforms.image, BGIMAGE_UNIT);
-    glUniform1f(bgimage_program_layout.uniforms.opacity, OPT(background_opacity));
-#ifdef __APPLE__
-    int window_width = w->window_width, window_height = w->window_height;
-#else
-    int window_width = w->viewport_width, window_height = w->viewport_height;
-#endif
-    GLfloat iwidth = (GLfloat)w->bgimage->width;
-    GLfloat iheight = (GLfloat)w->bgimage->height;
-    GLfloat vwidth = (GLfloat)window_width;
-    GLfloat vheight = (GLfloat)window_height;
-    if (CENTER_SCALED == OPT(background_image_layout)) {
+    glUniform1f(bgimage_program_layout.uniforms.opacity, bgimage->opacity < 0 ? OPT(background_opacity) : bgimage->opacity);
+    GLfloat iwidth = (GLfloat)bgimage->width;
+    GLfloat iheight = (GLfloat)bgimage->height;
+    if (CENTER_SCALED == bgimage->layout) {
         GLfloat ifrac = iwidth / iheight;
         if (ifrac > (vwidth / vheight)) {
             iheight = vheight;
@@ -468,18 +460,18 @@ draw_background_image(OSWindow *w) {
     }
     glUniform4f(bgimage_program_layout.uniforms.sizes,
         vwidth, vheight, iwidth, iheight);
-    glUniform1f(bgimage_program_layout.uniforms.premult, w->is_semi_transparent ? 1.f : 0.f);
+    glUniform1f(bgimage_program_layout.uniforms.premult, premult ? 1.f : 0.f);
     GLfloat tiled = 0.f;;
-    GLfloat left = -1.0, top = 1.0, right = 1.0, bottom = -1.0;
-    switch (OPT(background_image_layout)) {
+    GLfloat left = r.left, top = r.top, right = r.right, bottom = r.bottom;
+    switch (bgimage->layout) {
         case TILING: case MIRRORED: case CLAMPED:
             tiled = 1.f; break;
         case SCALED:
             break;
         case CENTER_CLAMPED:
         case CENTER_SCALED: {
-            GLfloat wfrac = (vwidth - iwidth) / vwidth;
-            GLfloat hfrac = (vheight - iheight) / vheight;
+            GLfloat wfrac = (right - left) / 2.f * (vwidth - iwidth) / vwidth;
+            GLfloat hfrac = (top - bottom) / 2.f * (vheight - iheight) / vheight;
             left += wfrac;
             right -= wfrac;
             top -= hfrac;
@@ -489,11 +481,22 @@ draw_background_image(OSWindow *w) {
     glUniform1f(bgimage_program_layout.uniforms.tiled, tiled);
     glUniform4f(bgimage_program_layout.uniforms.positions, left, top, right, bottom);
     glActiveTexture(GL_TEXTURE0 + BGIMAGE_UNIT);
-    glBindTexture(GL_TEXTURE_2D, w->bgimage->texture_id);
+    glBindTexture(GL_TEXTURE_2D, bgimage->texture_id);
     glDrawArrays(GL_TRIANGLE_FAN, 0, 4);
     unbind_program();
 }
 
+static void
+draw_background_image(OSWindow *w) {
+    blank_canvas(w->is_semi_transparent ? OPT(background_opacity) : 1.0f, OPT(background));
+#ifdef __APPLE__
+    int window_width = w->window_width, window_height = w->window_height;
+#else
+    int window_width = w->viewport_width, window_height = w->viewport_height;
+#endif
+    draw_bgimage(w->bgimage, w->is_semi_transparent, (GLfloat)window_width, (GLfloat)window_height, (ImageRect){-1.f, 1.f, 1.f, -1.f});
+}
+
 static void
 draw_graphics(int program, ssize_t vao_idx, ImageRenderData *data, GLuint start, GLuint count, ImageRect viewport) {
     bind_program(program);
@@ -745,6 +748,25 @@ draw_window_logo(ssize_t vao_idx, OSWindow *os_window, const WindowLogoRenderDat
     glUniform1f(graphics_program_layouts[GRAPHICS_PREMULT_PROGRAM].uniforms.inactive_text_alpha, prev_inactive_text_alpha);
 }
 
+static void
+draw_window_background_image(OSWindow *os_window, const BackgroundImage *bgimage, const CellRenderData *crd, bool premult) {
+    if (os_window->live_resize.in_progress) return;
+    if (premult) { BLEND_PREMULT; } else { BLEND_ONTO_OPAQUE; }
+#ifdef __APPLE__
+    GLfloat window_width = os_window->window_width, window_height = os_window->window_height;
+#else
+    GLfloat window_width = os_window->viewport_width, window_height = os_window->viewport_height;
+#endif
+    ImageRect r = viewport_for_cells(crd);
+    // the image must not be drawn outside the window, which it can be in the centered layouts
+    glEnable(GL_SCISSOR_TEST);
+    glScissor(
+        (GLint)roundf((r.left + 1.f) * os_window->viewport_width / 2.f), (GLint)roundf((r.bottom + 1.f) * os_window->viewport_height / 2.f),
+        (GLsizei)roundf(crd->gl.width * os_window->viewport_width / 2.f), (GLsizei)roundf(crd->gl.height * os_window->viewport_height / 2.f));
+    draw_bgimage(bgimage, premult, crd->gl.width * window_width / 2.f, crd->gl.height * window_height / 2.f, r);
+    glDisable(GL_SCISSOR_TEST);
+}
+
 static void
 draw_window_number(OSWindow *os_window, Screen *screen, const CellRenderData *crd, Window *window) {
     GLfloat left = os_window->viewport_width * (crd->gl.xstart + 1.f) / 2.f;
@@ -819,7 +841,7 @@ draw_visual_bell_flash(GLfloat intensity, const CellRenderData *crd, Screen *scr
 }
 
 static void
-draw_cells_interleaved(ssize_t vao_idx, Screen *screen, OSWindow *w, const CellRenderData *crd, const WindowLogoRenderData *wl) {
+draw_cells_interleaved(ssize_t vao_idx, Screen *screen, OSWindow *w, const CellRenderData *crd, const WindowLogoRenderData *wl, const BackgroundImage *wbg) {
     glEnable(GL_BLEND);
     BLEND_ONTO_OPAQUE;
 
@@ -834,7 +856,11 @@ draw_cells_interleaved(ssize_t vao_idx, Screen *screen, OSWindow *w, const CellR
     }
 
     GraphicsManager *grman = screen->paused_rendering.expires_at && screen->paused_rendering.grman ? screen->paused_rendering.grman : screen->grman;
-    if (grman->num_of_below_refs || has_bgimage(w) || wl) {
+    if (grman->num_of_below_refs || has_bgimage(w) || wl || wbg) {
+        if (wbg) {
+            draw_window_background_image(w, wbg, crd, false);
+            BLEND_ONTO_OPAQUE;
+        }
         if (wl) {
             draw_window_logo(vao_idx, w, wl, crd);
             BLEND_ONTO_OPAQUE;
@@ -863,7 +889,7 @@ draw_cells_interleaved(ssize_t vao_idx, Screen *screen, OSWindow *w, const CellR
 }
 
 static void
-draw_cells_interleaved_premult(ssize_t vao_idx, Screen *screen, OSWindow *os_window, const CellRenderData *crd, const WindowLogoRenderData *wl) {
+draw_cells_interleaved_premult(ssize_t vao_idx, Screen *screen, OSWindow *os_window, const CellRenderData *crd, const WindowLogoRenderData *wl, const BackgroundImage *wbg) {
     if (OPT(background_tint) > 0.f) {
         glEnable(GL_BLEND);
         draw_tint(true, screen, crd);
@@ -879,7 +905,11 @@ draw_cells_interleaved_premult(ssize_t vao_idx, Screen *screen, OSWindow *os_win
     BLEND_PREMULT;
 
     GraphicsManager *grman = screen->paused_rendering.expires_at && screen->paused_rendering.grman ? screen->paused_rendering.grman : screen->grman;
-    if (grman->num_of_below_refs || has_bgimage(os_window) || wl) {
+    if (grman->num_of_below_refs || has_bgimage(os_window) || wl || wbg) {
+        if (wbg) {
+            draw_window_background_image(os_window, wbg, crd, true);
+            BLEND_PREMULT;
+        }
         if (wl) {
             draw_window_logo(vao_idx, os_window, wl, crd);
             BLEND_PREMULT;
@@ -991,6 +1021,8 @@ draw_cells(ssize_t vao_idx, const WindowRenderData *srd, OSWindow *os_window, bo
         has_underlying_image = true;
         set_on_gpu_state(window->window_logo.instance, true);
     } else wl = NULL;
+    const BackgroundImage *wbg = window && window->bgimage && window->bgimage->texture_id ? window->bgimage : NULL;
+    if (wbg) has_underlying_image = true;
     ImageRenderData *previous_graphics_render_data = NULL;
     GraphicsManager *grman = screen->paused_rendering.expires_at && screen->paused_rendering.grman ? screen->paused_rendering.grman : screen->grman;
     if (os_window->live_resize.in_progress && grman->render_data.count && (crd.x_ratio != 1 || crd.y_ratio != 1)) {
@@ -1004,10 +1036,10 @@ draw_cells(ssize_t vao_idx, const WindowRenderData *srd, OSWindow *os_window, bo
     bool use_premult = false;
     has_underlying_image |= grman->num_of_below_refs > 0 || grman->num_of_negative_refs > 0;
     if (os_window->is_semi_transparent) {
-        if (has_underlying_image) { draw_cells_interleaved_premult(vao_idx, screen, os_window, &crd, wl); use_premult = true; }
+        if (has_underlying_image) { draw_cells_interleaved_premult(vao_idx, screen, os_window, &crd, wl, wbg); use_premult = true; }
         else draw_cells_simple(vao_idx, screen, &crd, os_window->is_semi_transparent);
     } else {
-        if (has_underlying_image) draw_cells_interleaved(vao_idx, screen, os_window, &crd, wl);
+        if (has_underlying_image) draw_cells_interleaved(vao_idx, screen, os_window, &crd, wl, wbg);
         else draw_cells_simple(vao_idx, screen, &crd, os_window->is_semi_transparent);
     }
     draw_scroll_indicator(use_premult, screen, &crd);
@@ -178,6 +178,7 @@ send_bgimage_to_gpu(BackgroundImageLayout layout, BackgroundImage *bgimage) {
             r = REPEAT_DEFAULT; break;
     }
     bgimage->texture_id = 0;
+    bgimage->layout = layout;
     send_image_to_gpu(&bgimage->texture_id, bgimage->bitmap, bgimage->width,
             bgimage->height, false, true, OPT(background_image_linear), r);
     free(bgimage->bitmap); bgimage->bitmap = NULL;
@@ -213,6 +214,7 @@ add_os_window(void) {
             global_state.bgimage = calloc(1, sizeof(BackgroundImage));
             if (!global_state.bgimage) fatal("Out of memory allocating the global bg image object");
             global_state.bgimage->refcnt++;
+            global_state.bgimage->opacity = -1;
             size_t size;
             if (png_path_to_bitmap(OPT(background_image), &global_state.bgimage->bitmap, &global_state.bgimage->width, &global_state.bgimage->height, &size)) {
                 send_bgimage_to_gpu(OPT(background_image_layout), global_state.bgimage);
@@ -347,6 +349,8 @@ destroy_window(Window *w) {
         decref_window_logo(global_state.all_window_logos, w->window_logo.id);
         w->window_logo.id = 0;
     }
+    free_bgimage(&w->bgimage, true);
+    w->bgimage = NULL;
 }
 
 static void
@@ -907,6 +911,52 @@ PYWRAP1(change_background_opacity) {
     Py_RETURN_FALSE;
 }
 
+unsigned
+os_window_hide_window_decorations(const OSWindow *w) {
+    return w->chrome_overrides.hide_window_decorations_set ? w->chrome_overrides.hide_window_decorations : OPT(hide_window_decorations);
+}
+
+int
+os_window_background_blur(const OSWindow *w) {
+    return w->chrome_overrides.background_blur_set ? w->chrome_overrides.background_blur : OPT(background_blur);
+}
+
+PYWRAP1(set_os_window_decorations) {
+    id_type os_window_id;
+    int hide = -1;
+    PA("K|i", &os_window_id, &hide);
+    WITH_OS_WINDOW(os_window_id)
+        os_window->chrome_overrides.hide_window_decorations_set = hide > -1;
+        os_window->chrome_overrides.hide_window_decorations = MAX(0, hide);
+        set_os_window_chrome(os_window);
+        Py_RETURN_TRUE;
+    END_WITH_OS_WINDOW
+    Py_RETURN_FALSE;
+}
+
+PYWRAP1(set_os_window_background_blur) {
+    id_type os_window_id;
+    int radius = -1;
+    PA("K|i", &os_window_id, &radius);
+    WITH_OS_WINDOW(os_window_id)
+        os_window->chrome_overrides.background_blur_set = radius > -1;
+        os_window->chrome_overrides.background_blur = MAX(0, radius);
+        set_os_window_chrome(os_window);
+        Py_RETURN_TRUE;
+    END_WITH_OS_WINDOW
+    Py_RETURN_FALSE;
+}
+
+PYWRAP1(os_window_chrome_settings) {
+    id_type os_window_id = PyLong_AsUnsignedLongLong(args);
+    WITH_OS_WINDOW(os_window_id)
+        return Py_BuildValue("{sI si}",
+            "hide_window_decorations", os_window_hide_window_decorations(os_window),
+            "background_blur", os_window_background_blur(os_window));
+    END_WITH_OS_WINDOW
+    Py_RETURN_NONE;
+}
+
 PYWRAP1(background_opacity_of) {
     id_type os_window_id = PyLong_AsUnsignedLongLong(args);
     WITH_OS_WINDOW(os_window_id)
@@ -1161,16 +1211,18 @@ static PyObject*
 pyset_background_image(PyObject *self UNUSED, PyObject *args) {
     const char *path;
     PyObject *layout_name = NULL;
-    PyObject *os_window_ids;
+    PyObject *os_window_ids, *window_ids = NULL;
     int configured = 0;
     char *png_data = NULL; Py_ssize_t png_data_size = 0;
-    PA("zO!|pOy#", &path, &PyTuple_Type, &os_window_ids, &configured, &layout_name, &png_data, &png_data_size);
+    float opacity = -1;
+    PA("zO!|pOy#fO!", &path, &PyTuple_Type, &os_window_ids, &configured, &layout_name, &png_data, &png_data_size, &opacity, &PyTuple_Type, &window_ids);
     size_t size;
     BackgroundImageLayout layout = PyUnicode_Check(layout_name) ? bglayout(layout_name) : OPT(background_image_layout);
     BackgroundImage *bgimage = NULL;
     if (path) {
         bgimage = calloc(1, sizeof(BackgroundImage));
         if (!bgimage) return PyErr_NoMemory();
+        bgimage->opacity = opacity < 0 ? -1 : MIN(1.f, opacity);
         bool ok;
         if (png_data && png_data_size) {
             ok = png_from_data(png_data, png_data_size, path, &bgimage->bitmap, &bgimage->width, &bgimage->height, &size);
@@ -1201,6 +1253,21 @@ pyset_background_image(PyObject *self UNUSED, PyObject *args) {
             if (bgimage) bgimage->refcnt++;
         END_WITH_OS_WINDOW
     }
+    // the image is drawn behind the cells of individual windows, rather than the whole OS window
+    for (Py_ssize_t i = 0; window_ids && i < PyTuple_GET_SIZE(window_ids); i++) {
+        id_type os_window_id, tab_id, window_id;
+        if (!PyArg_ParseTuple(PyTuple_GET_ITEM(window_ids, i), "KKK", &os_window_id, &tab_id, &window_id)) {
+            if (bgimage) free_bgimage(&bgimage, true);
+            return NULL;
+        }
+        WITH_WINDOW(os_window_id, tab_id, window_id)
+            make_os_window_context_current(osw);
+            free_bgimage(&window->bgimage, true);
+            window->bgimage = bgimage;
+            osw->is_damaged = true;
+            if (bgimage) bgimage->refcnt++;
+        END_WITH_WINDOW
+    }
     if (bgimage) free_bgimage(&bgimage, true);
     Py_RETURN_NONE;
 }
@@ -1408,6 +1475,9 @@ static PyMethodDef module_methods[] = {
     MW(run_with_activation_token, METH_O),
     MW(change_background_opacity, METH_VARARGS),
     MW(background_opacity_of, METH_O),
+    MW(set_os_window_decorations, METH_VARARGS),
+    MW(set_os_window_background_blur, METH_VARARGS),
+    MW(os_window_chrome_settings, METH_O),
     MW(update_window_visibility, METH_VARARGS),
     MW(sync_os_window_title, METH_VARARGS),
     MW(get_os_window_title, METH_VARARGS),
@@ -159,6 +159,7 @@ typedef struct {
     PyObject *title;
     WindowRenderData render_data;
     WindowLogoRenderData window_logo;
+    BackgroundImage *bgimage;
     MousePosition mouse_pos;
     struct {
         unsigned int left, top, right, bottom;
@@ -244,6 +245,12 @@ typedef struct {
     bool has_pending_resizes, is_semi_transparent, shown_once, is_damaged, ignore_resize_events;
     unsigned int clear_count;
     WindowChromeState last_window_chrome;
+    struct {
+        // values changed at runtime, used instead of the values from the options when set
+        bool hide_window_decorations_set, background_blur_set;
+        unsigned hide_window_decorations;
+        int background_blur;
+    } chrome_overrides;
     float background_opacity;
     FONTS_DATA_HANDLE fonts_data;
     id_type temp_font_group_id;
@@ -329,6 +336,8 @@ void send_sprite_to_gpu(FONTS_DATA_HANDLE fg, unsigned int, unsigned int, unsign
 void blank_canvas(float, color_type);
 void blank_os_window(OSWindow *);
 void set_os_window_chrome(OSWindow *w);
+unsigned os_window_hide_window_decorations(const OSWindow *w);
+int os_window_background_blur(const OSWindow *w);
 FONTS_DATA_HANDLE load_fonts_data(double, double, double);
 void send_prerendered_sprites_for_window(OSWindow *w);
 #ifdef __APPLE__
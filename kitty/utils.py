@@ -956,8 +956,10 @@ class SSHConnectionData(NamedTuple):
 
 
 def get_new_os_window_size(
-    metrics: 'OSWindowSize', width: int, height: int, unit: str, incremental: bool = False, has_window_scaling: bool = True
+    metrics: 'OSWindowSize', width: int, height: int, unit: str, incremental: bool = False, has_window_scaling: bool = True,
+    keep_aspect: bool = False,
 ) -> Tuple[int, int]:
+    width_changed, height_changed = bool(width), bool(height)
     if unit == 'cells':
         cw = metrics['cell_width']
         ch = metrics['cell_height']
@@ -966,12 +968,22 @@ def get_new_os_window_size(
         if has_window_scaling:
             width = round(width / metrics['xscale'])
             height = round(height / metrics['yscale'])
+    elif unit == 'percent':
+        from .fast_data_types import glfw_primary_monitor_size
+        mw, mh = glfw_primary_monitor_size()
+        width = round(mw * width / 100)
+        height = round(mh * height / 100)
     if incremental:
         w = metrics['width'] + width
         h = metrics['height'] + height
     else:
         w = width or metrics['width']
         h = height or metrics['height']
+    if keep_aspect and metrics['width'] > 0 and metrics['height'] > 0 and width_changed != height_changed:
+        if width_changed:
+            h = round(w * metrics['height'] / metrics['width'])
+        else:
+            w = round(h * metrics['width'] / metrics['height'])
     return w, h
 
 
@@ -21,6 +21,7 @@
     Dict,
     Generator,
     Iterable,
+    Iterator,
     List,
     NamedTuple,
     Optional,
@@ -47,7 +48,10 @@
     CURSOR_UNDERLINE,
     ESC_DCS,
     ESC_OSC,
+    GLFW_MOD_CAPS_LOCK,
     GLFW_MOD_CONTROL,
+    GLFW_MOD_NUM_LOCK,
+    GLFW_MOD_SHIFT,
     GLFW_PRESS,
     GLFW_RELEASE,
     GLFW_REPEAT,
@@ -77,6 +81,7 @@
     move_cursor_to_mouse_if_in_prompt,
     pointer_name_to_css_name,
     pt_to_px,
+    remove_timer,
     replace_c0_codes_except_nl_space_tab,
     set_window_logo,
     set_window_padding,
@@ -233,6 +238,7 @@ class WindowDict(TypedDict):
     user_vars: Dict[str, str]
     at_prompt: bool
     created_at: int
+    last_focused_at: int
 
 
 class PipeData(TypedDict):
@@ -283,6 +289,8 @@ class Watchers:
     on_set_user_var: List[Watcher]
     on_title_change: List[Watcher]
     on_cmd_startstop: List[Watcher]
+    on_bell: List[Watcher]
+    on_create: List[Watcher]
 
     def __init__(self) -> None:
         self.on_resize = []
@@ -291,6 +299,8 @@ def __init__(self) -> None:
         self.on_set_user_var = []
         self.on_title_change = []
         self.on_cmd_startstop = []
+        self.on_bell = []
+        self.on_create = []
 
     def add(self, others: 'Watchers') -> None:
         def merge(base: List[Watcher], other: List[Watcher]) -> None:
@@ -303,10 +313,13 @@ def merge(base: List[Watcher], other: List[Watcher]) -> None:
         merge(self.on_set_user_var, others.on_set_user_var)
         merge(self.on_title_change, others.on_title_change)
         merge(self.on_cmd_startstop, others.on_cmd_startstop)
+        merge(self.on_bell, others.on_bell)
+        merge(self.on_create, others.on_create)
 
     def clear(self) -> None:
         del self.on_close[:], self.on_resize[:], self.on_focus_change[:]
         del self.on_set_user_var[:], self.on_title_change[:], self.on_cmd_startstop[:]
+        del self.on_bell[:], self.on_create[:]
 
     def copy(self) -> 'Watchers':
         ans = Watchers()
@@ -316,12 +329,15 @@ def copy(self) -> 'Watchers':
         ans.on_set_user_var = self.on_set_user_var[:]
         ans.on_title_change = self.on_title_change[:]
         ans.on_cmd_startstop = self.on_cmd_startstop[:]
+        ans.on_bell = self.on_bell[:]
+        ans.on_create = self.on_create[:]
         return ans
 
     @property
     def has_watchers(self) -> bool:
         return bool(self.on_close or self.on_resize or self.on_focus_change
-                    or self.on_set_user_var or self.on_title_change or self.on_cmd_startstop)
+                    or self.on_set_user_var or self.on_title_change or self.on_cmd_startstop
+                    or self.on_bell or self.on_create)
 
 
 def call_watchers(windowref: Callable[[], Optional['Window']], which: str, data: Dict[str, Any]) -> None:
@@ -389,6 +405,125 @@ def as_text(
 
 
 
+class ScrollbackSearch:
+
+    '''
+    Search the text of a screen for lines matching a pattern. The text is read
+    lazily from source in chunks of whole lines, one chunk per iteration of the
+    main loop, so that searching very large scrollbacks does not stop kitty from
+    rendering and handling input, and no more text is read once max_matches
+    matches are found. Matches do not span lines.
+    '''
+
+    chunk_size = 1024 * 1024
+
+    def __init__(self, pat: Pattern[str], max_matches: int = 0, source: Iterable[str] = ()):
+        self.pat = pat
+        self.max_matches = max_matches
+        self.source: Iterator[str] = iter(source)
+        self.line_number = 1
+        self.matches: List[Tuple[int, str]] = []
+        self.timer_id = 0
+        self.callback: Optional[Callable[[List[Tuple[int, str]]], None]] = None
+
+    def next_chunk(self) -> str:
+        ''' Read text from the source till the chunk size is reached at the end of a line, returning an empty string when the source is exhausted '''
+        parts: List[str] = []
+        size = 0
+        for text in self.source:
+            parts.append(text)
+            size += len(text)
+            if size >= self.chunk_size and text.endswith('\n'):
+                break
+        return ''.join(parts)
+
+    def search_chunk(self, chunk: str) -> bool:
+        ''' Search the chunk, returning True if the maximum number of matches was reached '''
+        pat, pos, counted_upto, line_number = self.pat, 0, 0, self.line_number
+        while pos < len(chunk):
+            m = pat.search(chunk, pos)
+            if m is None:
+                break
+            start = m.start()
+            line_end = chunk.find('\n', start)
+            if line_end < 0:
+                line_end = len(chunk)
+            if m.end() > line_end:
+                # the match spans lines, look for one in the rest of the line instead
+                m = pat.search(chunk, start, line_end)
+            if m is not None:
+                line_number += chunk.count('\n', counted_upto, start)
+                counted_upto = start
+                line_start = chunk.rfind('\n', 0, start) + 1
+                self.matches.append((line_number, chunk[line_start:line_end]))
+                if self.max_matches and len(self.matches) >= self.max_matches:
+                    return True
+            pos = line_end + 1
+        self.line_number += chunk.count('\n')
+        return False
+
+    def search_all(self) -> List[Tuple[int, str]]:
+        while (chunk := self.next_chunk()) and not self.search_chunk(chunk):
+            pass
+        self.source = iter(())
+        return self.matches
+
+    def start(self, callback: Callable[[List[Tuple[int, str]]], None]) -> None:
+        self.callback = callback
+        self.timer_id = add_timer(self.search_next_chunk, 0, False)
+
+    def search_next_chunk(self, timer_id: Optional[int]) -> None:
+        self.timer_id = 0
+        chunk = self.next_chunk()
+        if chunk and not self.search_chunk(chunk):
+            self.timer_id = add_timer(self.search_next_chunk, 0, False)
+            return
+        self.source = iter(())
+        callback, self.callback = self.callback, None
+        if callback is not None:
+            callback(self.matches)
+
+    def cancel(self) -> None:
+        if self.timer_id:
+            remove_timer(self.timer_id)
+            self.timer_id = 0
+        self.source, self.callback = iter(()), None
+
+
+def screen_lines(screen: Screen) -> Iterator[str]:
+    '''
+    The text of the screen and its scrollback, oldest first, read one line at a
+    time so that it can be consumed across iterations of the main loop. Lines
+    are counted from the oldest line, so output that scrolls lines into the
+    scrollback while this is being consumed does not cause lines to be skipped,
+    unless the scrollback is full.
+    '''
+    alternate = screen.is_using_alternate_linebuf()
+    if not alternate:
+        pht = pagerhist(screen)
+        pos = 0
+        while pos < len(pht):
+            end = pht.find('\n', pos) + 1 or len(pht)
+            yield pht[pos:end]
+            pos = end
+    y = 0
+    while screen.is_using_alternate_linebuf() == alternate:
+        hb = screen.historybuf
+        num_history = 0 if alternate else hb.count
+        if y < num_history:
+            line = hb.line(num_history - 1 - y)
+        elif y - num_history < screen.lines:
+            line = screen.line(y - num_history)
+        else:
+            break
+        y += 1
+        yield str(line) + ('' if line.last_char_has_wrapped_flag() else '\n')
+
+
+def search_scrollback(screen: Screen, pat: Pattern[str], max_matches: int = 0) -> ScrollbackSearch:
+    return ScrollbackSearch(pat, max_matches, screen_lines(screen))
+
+
 def setup_colors(screen: Screen, opts: Options) -> None:
     screen.color_profile.update_ansi_color_table(build_ansi_color_table(opts))
 
@@ -455,6 +590,27 @@ def process_remote_print(msg: memoryview) -> str:
     return replace_c0_codes_except_nl_space_tab(base64_decode(msg)).decode('utf-8', 'replace')
 
 
+# The shifted keys for a US keyboard layout, used to synthesize the text and
+# shifted key for keys sent via send_key
+us_shifted_keys = dict(zip('`1234567890-=[]\\;\',./', '~!@#$%^&*()_+{}|:"<>?'))
+send_key_actions = {'press': GLFW_PRESS, 'repeat': GLFW_REPEAT, 'release': GLFW_RELEASE}
+
+
+def key_event_for_send_key(key: int, mods: int, action: int) -> KeyEvent:
+    shifted_key, text = 0, ''
+    if key < 0xe000 and chr(key).isprintable() and not mods & ~(GLFW_MOD_SHIFT | GLFW_MOD_CAPS_LOCK | GLFW_MOD_NUM_LOCK):
+        text = chr(key)
+        if mods & GLFW_MOD_SHIFT:
+            shifted = us_shifted_keys.get(text, text.upper())
+            if len(shifted) == 1 and shifted != text:
+                shifted_key, text = ord(shifted), shifted
+        elif mods & GLFW_MOD_CAPS_LOCK:
+            text = text.upper()
+    if action == GLFW_RELEASE:
+        text = ''
+    return KeyEvent(key=key, shifted_key=shifted_key, mods=mods, action=action, text=text)
+
+
 class EdgeWidths:
     left: Optional[float]
     top: Optional[float]
@@ -545,6 +701,7 @@ def __init__(
         self.last_resized_at = 0.
         self.started_at = monotonic()
         self.created_at = time_ns()
+        self.last_focused_at_ns = 0
         self.current_remote_data: List[str] = []
         self.current_mouse_event_button = 0
         self.current_clipboard_read_ask: Optional[bool] = None
@@ -588,6 +745,8 @@ def __init__(
             setup_colors(self.screen, opts)
         self.remote_control_passwords = remote_control_passwords
         self.allow_remote_control = allow_remote_control
+        if self.watchers.on_create:
+            call_watchers(weakref.ref(self), 'on_create', {})
 
     def remote_control_allowed(self, pcmd: Dict[str, Any], extra_data: Dict[str, Any]) -> bool:
         if not self.allow_remote_control:
@@ -688,6 +847,7 @@ def as_dict(self, is_focused: bool = False, is_self: bool = False, is_active: bo
             'columns': self.screen.columns,
             'user_vars': self.user_vars,
             'created_at': self.created_at,
+            'last_focused_at': self.last_focused_at_ns,
         }
 
     def serialize_state(self) -> Dict[str, Any]:
@@ -889,21 +1049,34 @@ def send_text(self, *args: str) -> bool:
 
             map f1 send_key ctrl+x alt+y
             map f1 combine : send_key ctrl+x : send_key alt+y
+
+        To send only a particular type of event for a key, prefix it with the event type,
+        one of :code:`press`, :code:`repeat` or :code:`release`::
+
+            map f1 send_key press:ctrl+x release:ctrl+x
     ''')
     def send_key(self, *args: str) -> bool:
         from .options.utils import parse_shortcut
         km = get_options().kitty_mod
         passthrough = True
         events = []
+        releases = []
         prev = ''
         for human_key in args:
-            sk = parse_shortcut(human_key)
+            # keys can be prefixed by an event type, to send only that event
+            event_type, sep, spec = human_key.partition(':')
+            action = send_key_actions.get(event_type) if sep and spec else None
+            sk = parse_shortcut(human_key if action is None else spec)
             if sk.is_native:
                 raise ValueError(f'Native key codes not allowed in send_key: {human_key}')
             sk = sk.resolve_kitty_mod(km)
-            events.append(KeyEvent(key=sk.key, mods=sk.mods, action=GLFW_REPEAT if human_key == prev else GLFW_PRESS))
+            if action is None:
+                events.append(key_event_for_send_key(sk.key, sk.mods, GLFW_REPEAT if human_key == prev else GLFW_PRESS))
+                releases.append(key_event_for_send_key(sk.key, sk.mods, GLFW_RELEASE))
+            else:
+                events.append(key_event_for_send_key(sk.key, sk.mods, action))
             prev = human_key
-        for ev in events + [KeyEvent(key=x.key, mods=x.mods, action=GLFW_RELEASE) for x in reversed(events)]:
+        for ev in events + releases[::-1]:
             enc = self.encoded_key(ev)
             if enc:
                 self.write_to_child(enc)
@@ -1103,6 +1276,7 @@ def focus_changed(self, focused: bool) -> None:
         self.screen.focus_changed(focused)
         if focused:
             self.last_focused_at = monotonic()
+            self.last_focused_at_ns = time_ns()
             update_ime_position_for_window(self.id, False, 1)
             changed = self.needs_attention
             self.needs_attention = False
@@ -1139,6 +1313,7 @@ def on_activity_since_last_focus(self) -> bool:
         return False
 
     def on_bell(self) -> None:
+        self.call_watchers(self.watchers.on_bell, {})
         cb = get_options().command_on_bell
         if cb and cb != ['none']:
             import shlex
@@ -1530,6 +1705,13 @@ def as_text(
     ) -> str:
         return as_text(self.screen, as_ansi, add_history, add_wrap_markers, alternate_screen, add_cursor)
 
+    def search_scrollback(
+        self, pat: Pattern[str], callback: Callable[[List[Tuple[int, str]]], None], max_matches: int = 0
+    ) -> ScrollbackSearch:
+        ans = search_scrollback(self.screen, pat, max_matches)
+        ans.start(callback)
+        return ans
+
     def cmd_output(self, which: CommandOutput = CommandOutput.last_run, as_ansi: bool = False, add_wrap_markers: bool = False) -> str:
         return cmd_output(self.screen, which, as_ansi, add_wrap_markers)
 
@@ -509,6 +509,45 @@ def enc(button=L, action=defines.PRESS, mods=0, x=1, y=1):
         self.ae(enc(mods=defines.GLFW_MOD_ALT), '<8;1;1M')
         self.ae(enc(mods=defines.GLFW_MOD_CONTROL), '<16;1;1M')
 
+    def test_send_key(self):
+        from kitty.window import Window, key_event_for_send_key
+        shift, ctrl, caps_lock = defines.GLFW_MOD_SHIFT, defines.GLFW_MOD_CONTROL, defines.GLFW_MOD_CAPS_LOCK
+        press, repeat, release = defines.GLFW_PRESS, defines.GLFW_REPEAT, defines.GLFW_RELEASE
+
+        def ev(key, mods=0, action=press):
+            e = key_event_for_send_key(ord(key), mods, action)
+            return chr(e.shifted_key) if e.shifted_key else '', e.text
+
+        self.ae(ev('a'), ('', 'a'))
+        self.ae(ev('a', shift), ('A', 'A'))
+        self.ae(ev('1', shift), ('!', '!'))
+        self.ae(ev(';', shift), (':', ':'))
+        self.ae(ev('a', caps_lock), ('', 'A'))
+        self.ae(ev('a', ctrl), ('', ''))
+        self.ae(ev('a', shift, release), ('A', ''))
+
+        class FakeWindow:
+            def __init__(self):
+                self.sent = []
+
+            def encoded_key(self, e):
+                return f'{chr(e.key)}{e.action}{e.text}'.encode()
+
+            def write_to_child(self, data):
+                self.sent.append(data.decode())
+
+        def send_key(*args):
+            w = FakeWindow()
+            Window.send_key(w, *args)
+            return w.sent
+
+        self.set_options()
+        # keys without an event type are pressed and then released in reverse order
+        self.ae(send_key('a', 'a', 'b'), [f'a{press}a', f'a{repeat}a', f'b{press}b', f'b{release}', f'a{release}', f'a{release}'])
+        # keys with an event type are sent as is, in order
+        self.ae(send_key('press:a', 'repeat:a', 'release:a'), [f'a{press}a', f'a{repeat}a', f'a{release}'])
+        self.ae(send_key('press:shift+a', 'b'), [f'a{press}A', f'b{press}b', f'b{release}'])
+
     def test_mapping(self):
         from kitty.config import load_config
         from kitty.options.utils import parse_shortcut
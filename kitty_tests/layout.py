@@ -2,6 +2,7 @@
 # License: GPL v3 Copyright: 2018, Kovid Goyal <kovid at kovidgoyal.net>
 
 from kitty.config import defaults
+from kitty.layout.base import resize_to_size
 from kitty.layout.interface import Grid, Horizontal, Splits, Stack, Tall
 from kitty.types import WindowGeometry
 from kitty.window import EdgeWidths
@@ -257,3 +258,32 @@ def test_splits(self):
         self.ae(q.neighbors_for_window(windows[1], all_windows), {'left': [1], 'right': [], 'top': [], 'bottom': [3, 4]})
         self.ae(q.neighbors_for_window(windows[2], all_windows), {'left': [1], 'right': [4], 'top': [2], 'bottom': []})
         self.ae(q.neighbors_for_window(windows[3], all_windows), {'left': [3], 'right': [], 'top': [2], 'bottom': []})
+
+    def test_resize_to_size(self):
+        class Resizer:
+            # resizes by an approximate number of cells, limited to a range, as the layouts do
+            def __init__(self, size, lo=5, hi=60, factor=0.7):
+                self.size, self.lo, self.hi, self.factor, self.calls = size, lo, hi, factor, 0
+
+            def current_size(self):
+                return self.size
+
+            def resize_by(self, delta):
+                self.calls += 1
+                self.size = max(self.lo, min(self.hi, self.size + round(delta * self.factor)))
+
+        for factor in (0.7, 1, 1.3):
+            r = Resizer(20, factor=factor)
+            self.assertIsNone(resize_to_size(r.current_size, r.resize_by, 40))
+            self.ae(r.size, 40)
+        r = Resizer(20)
+        self.assertIsNone(resize_to_size(r.current_size, r.resize_by, 20))
+        self.ae(r.calls, 0)
+        r = Resizer(20)
+        self.assertIn('60', resize_to_size(r.current_size, r.resize_by, 80))
+        self.ae(r.size, 60)
+        # failures to resize are reported
+        self.ae(resize_to_size(r.current_size, lambda delta: 'Could not resize', 10), 'Could not resize')
+        # sizes that cannot be reached exactly
+        r = Resizer(20, factor=3)
+        self.assertIsNotNone(resize_to_size(r.current_size, r.resize_by, 21))
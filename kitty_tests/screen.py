@@ -740,6 +740,48 @@ def test():
         w('e')
         self.ae(contents(), 'abcde')
 
+    def test_search_scrollback(self):
+        import re
+
+        from kitty.window import ScrollbackSearch, search_scrollback
+
+        def search(pat, *chunks, max_matches=0):
+            ss = ScrollbackSearch(re.compile(pat, re.MULTILINE), max_matches, chunks)
+            ss.chunk_size = 4
+            return ss.search_all()
+
+        self.ae(search('3', 'a1\na2\n', 'a3\n'), [(3, 'a3')])
+        self.ae(search('a', 'a1\na2\n', 'a3\n', max_matches=2), [(1, 'a1'), (2, 'a2')])
+        self.ae(search('^b', 'ab\n', 'b\n', 'cb'), [(2, 'b')])
+        self.ae(search('b$', 'ab\n', 'ba\n', 'cb'), [(1, 'ab'), (3, 'cb')])
+        # matches that span lines do not hide matches that do not
+        self.ae(search(r'\w+\s+\w+', 'x\ny z\n'), [(2, 'y z')])
+        self.ae(search(r'a\s*b', 'xa\nb ab\n', 'ab\n'), [(2, 'b ab'), (3, 'ab')])
+        self.ae(search('x', 'x x\n', 'x\n'), [(1, 'x x'), (2, 'x')])
+        self.ae(search('q', 'a\n', 'b'), [])
+
+        # no more lines are read once the maximum number of matches is found
+        num_read = 0
+
+        def lines():
+            nonlocal num_read
+            for i in range(10000):
+                num_read += 1
+                yield f'a{i}\n'
+        ss = ScrollbackSearch(re.compile('a'), 3, lines())
+        ss.chunk_size = 8
+        self.ae(ss.search_all(), [(1, 'a0'), (2, 'a1'), (3, 'a2')])
+        self.assertLess(num_read, 10)
+
+        s = self.create_screen(cols=10, lines=3, scrollback=10)
+        for i in range(6):
+            s.draw(f'line {i}')
+            s.carriage_return(), s.linefeed()
+        self.ae(search_scrollback(s, re.compile('[135]$', re.MULTILINE)).search_all(), [(2, 'line 1'), (4, 'line 3'), (6, 'line 5')])
+        self.ae(search_scrollback(s, re.compile('line'), 2).search_all(), [(1, 'line 0'), (2, 'line 1')])
+        s.draw('x' * 25)
+        self.ae(search_scrollback(s, re.compile('x+$', re.MULTILINE)).search_all(), [(7, 'x' * 25)])
+
     def test_user_marking(self):
 
         def cells(*a, y=0, mark=3):
@@ -163,6 +163,7 @@ def run_website(args: Any) -> None:
     shutil.copytree(os.path.join(docs_dir, '_build', 'dirhtml'), publish_dir, symlinks=True)
     with open(os.path.join(publish_dir, 'current-version.txt'), 'w') as f:
         f.write(version)
+    sign_file(os.path.join(publish_dir, 'current-version.txt'))
     shutil.copy2(os.path.join(docs_dir, 'installer.sh'), publish_dir)
     os.chdir(os.path.dirname(publish_dir))
     subprocess.check_call(['optipng', '-o7'] + glob.glob('kitty/_images/social_previews/*.png'))
@@ -484,6 +485,15 @@ def run_upload_nightly(args: Any) -> None:
     subprocess.check_call(['git', 'push', 'origin', 'nightly', '-f'])
     gd = get_github_data()
     files = files_for_upload()
+    # Used by kitten update-check to find out if a newer nightly build is available
+    path = os.path.abspath(os.path.join('build', 'nightly-version.txt'))
+    commit = subprocess.check_output(['git', 'rev-parse', 'HEAD']).decode('utf-8').strip()
+    built_at = datetime.datetime.now(datetime.timezone.utc).isoformat(timespec='seconds')
+    with open(path, 'w') as f:
+        f.write(f'{version}\n{commit}\n{built_at}\n')
+    sign_file(path)
+    files[path] = 'Nightly version information'
+    files[f'{path}.sig'] = 'GPG signature for nightly version information'
     gh = GitHub(files, appname, 'nightly', gd['username'], gd['password'])
     gh()
 
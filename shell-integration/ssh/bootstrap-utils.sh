@@ -43,6 +43,10 @@ compile_terminfo() {
     if [ -x "$(command -v tic)" ]; then
         tic_out=$(command tic -x -o "$1/$tname" "$1/.terminfo/kitty.terminfo" 2>&1)
         [ $? = 0 ] || die "Failed to compile terminfo with err: $tic_out"
+        # compile the extra terminfo entries from the terminfo option, ignoring failures
+        if [ -f "$1/.terminfo/extra.terminfo" ]; then
+            command tic -x -o "$1/$tname" "$1/.terminfo/extra.terminfo" >/dev/null 2>&1 || :
+        fi
     fi
 }
 
@@ -167,6 +167,12 @@ def compile_terminfo(base):
     if rc != 0:
         getattr(sys.stderr, 'buffer', sys.stderr).write(output)
         raise SystemExit('Failed to compile the terminfo database')
+    extra = os.path.join(base, '.terminfo', 'extra.terminfo')
+    if os.path.exists(extra):
+        # compile the extra terminfo entries from the terminfo option, ignoring failures
+        p = subprocess.Popen([tic, '-x', '-o', os.path.join(base, tname), extra], stdout=subprocess.PIPE, stderr=subprocess.STDOUT)
+        p.stdout.read()
+        p.wait()
 
 
 def iter_base64_data(f):
@@ -0,0 +1,249 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+// Read and write terminal session recordings in the asciinema asciicast v2
+// format, see https://docs.asciinema.org/manual/asciicast/v2/
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var _ = fmt.Print
+
+const Version = 2
+
+type EventType string
+
+const (
+	Output EventType = "o"
+	Input  EventType = "i"
+	Resize EventType = "r"
+	Marker EventType = "m"
+)
+
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Duration  float64           `json:"duration,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+type Event struct {
+	// The time since the start of the recording
+	Time time.Duration
+	Type EventType
+	Data string
+}
+
+func (self Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{json.Number(strconv.FormatFloat(self.Time.Seconds(), 'f', 6, 64)), self.Type, self.Data})
+}
+
+func (self *Event) UnmarshalJSON(data []byte) error {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	if len(parts) != 3 {
+		return fmt.Errorf("Event does not have three items: %s", data)
+	}
+	var t float64
+	if err := json.Unmarshal(parts[0], &t); err != nil {
+		return fmt.Errorf("Event has invalid time: %s", parts[0])
+	}
+	self.Time = time.Duration(t * float64(time.Second))
+	if err := json.Unmarshal(parts[1], &self.Type); err != nil {
+		return fmt.Errorf("Event has invalid type: %s", parts[1])
+	}
+	if err := json.Unmarshal(parts[2], &self.Data); err != nil {
+		return fmt.Errorf("Event has invalid data: %s", parts[2])
+	}
+	return nil
+}
+
+// The screen size in a resize event
+func (self Event) Size() (width, height int, err error) {
+	w, h, found := strings.Cut(self.Data, "x")
+	if found {
+		if width, err = strconv.Atoi(w); err == nil {
+			height, err = strconv.Atoi(h)
+		}
+	}
+	if !found || err != nil {
+		err = fmt.Errorf("Invalid size in resize event: %#v", self.Data)
+	}
+	return
+}
+
+// Records events to an asciicast stream. Writes to it are recorded as output
+// events. Safe for concurrent use.
+type Writer struct {
+	mutex      sync.Mutex
+	dest       *bufio.Writer
+	started_at time.Time
+	// an incomplete UTF-8 sequence at the end of the last write, JSON
+	// strings can only hold valid UTF-8, so it is prepended to the next
+	// write instead of being mangled
+	pending []byte
+	err     error
+}
+
+// Write the header, using the current time as the start of the recording if
+// no Timestamp is set
+func NewWriter(dest io.Writer, h Header) (*Writer, error) {
+	ans := &Writer{dest: bufio.NewWriter(dest), started_at: time.Now()}
+	h.Version = Version
+	if h.Timestamp == 0 {
+		h.Timestamp = ans.started_at.Unix()
+	}
+	data, err := json.Marshal(h)
+	if err == nil {
+		ans.dest.Write(data)
+		err = ans.dest.WriteByte('\n')
+	}
+	if err == nil {
+		err = ans.dest.Flush()
+	}
+	return ans, err
+}
+
+func (self *Writer) write_event(t EventType, data string) {
+	if self.err != nil {
+		return
+	}
+	var line []byte
+	if line, self.err = json.Marshal(Event{Time: time.Since(self.started_at), Type: t, Data: data}); self.err == nil {
+		self.dest.Write(line)
+		if self.err = self.dest.WriteByte('\n'); self.err == nil {
+			self.err = self.dest.Flush()
+		}
+	}
+}
+
+// Split off a trailing incomplete UTF-8 sequence
+func split_incomplete_utf8(data []byte) (complete, rest []byte) {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i], data[i:]
+			}
+			break
+		}
+	}
+	return data, nil
+}
+
+func (self *Writer) record(t EventType, data []byte) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if len(self.pending) > 0 {
+		data = append(self.pending, data...)
+	}
+	data, rest := split_incomplete_utf8(data)
+	self.pending = append([]byte(nil), rest...)
+	if len(data) > 0 {
+		self.write_event(t, string(data))
+	}
+	return self.err
+}
+
+// Record data as an output event
+func (self *Writer) Write(data []byte) (int, error) {
+	if err := self.record(Output, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (self *Writer) WriteInput(data []byte) error {
+	return self.record(Input, data)
+}
+
+func (self *Writer) Resize(width, height int) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.write_event(Resize, fmt.Sprintf("%dx%d", width, height))
+	return self.err
+}
+
+func (self *Writer) Mark(label string) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.write_event(Marker, label)
+	return self.err
+}
+
+// Record any incomplete trailing UTF-8 sequence, it is invalid so it is
+// replaced by the replacement character
+func (self *Writer) Close() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if len(self.pending) > 0 {
+		self.write_event(Output, string(self.pending))
+		self.pending = nil
+	}
+	return self.err
+}
+
+type Reader struct {
+	Header Header
+
+	src     *bufio.Reader
+	line_no int
+}
+
+// Read the header of an asciicast stream
+func NewReader(src io.Reader) (*Reader, error) {
+	ans := &Reader{src: bufio.NewReaderSize(src, 64*1024)}
+	line, err := ans.read_line()
+	if err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("The recording is empty")
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(line, &ans.Header); err != nil {
+		return nil, fmt.Errorf("The recording has an invalid header: %w", err)
+	}
+	if ans.Header.Version != Version {
+		return nil, fmt.Errorf("Unsupported asciicast version: %d only version %d is supported", ans.Header.Version, Version)
+	}
+	return ans, nil
+}
+
+func (self *Reader) read_line() ([]byte, error) {
+	for {
+		line, err := self.src.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		self.line_no++
+		if line = []byte(strings.TrimSpace(string(line))); len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+// Return the next event, io.EOF at the end of the stream
+func (self *Reader) Next() (ev Event, err error) {
+	line, err := self.read_line()
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(line, &ev); err != nil {
+		err = fmt.Errorf("Invalid event on line %d of the recording: %w", self.line_no, err)
+	}
+	return
+}
@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package asciicast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestAsciicast(t *testing.T) {
+	buf := bytes.Buffer{}
+	w, err := NewWriter(&buf, Header{Width: 80, Height: 24, Env: map[string]string{"TERM": "xterm-kitty"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	euro := []byte("€")
+	w.Write([]byte("a\x1b[31m"))
+	w.Write(append([]byte("b"), euro[:2]...))
+	w.Write(euro[2:])
+	w.Resize(100, 30)
+	w.Write(euro[:1])
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), `{"version":2,"width":80,"height":24,"timestamp":`) {
+		t.Fatalf("Unexpected header: %s", buf.String())
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(Header{Version: 2, Width: 80, Height: 24, Timestamp: r.Header.Timestamp, Env: map[string]string{"TERM": "xterm-kitty"}}, r.Header); diff != "" {
+		t.Fatalf("Unexpected header:\n%s", diff)
+	}
+	var events []string
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Time < 0 {
+			t.Fatalf("Event has negative time: %v", ev.Time)
+		}
+		events = append(events, string(ev.Type)+":"+ev.Data)
+	}
+	if diff := cmp.Diff([]string{"o:a\x1b[31m", "o:b", "o:€", "r:100x30", "o:\ufffd"}, events); diff != "" {
+		t.Fatalf("Unexpected events:\n%s", diff)
+	}
+
+	r, err = NewReader(strings.NewReader(`{"version": 2, "width": 10, "height": 5}` + "\n\n" + `[1.5, "r", "12x7"]` + "\n[1, 2]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width, height, err := ev.Size(); err != nil || width != 12 || height != 7 || ev.Time.Milliseconds() != 1500 {
+		t.Fatalf("Unexpected resize event: %#v", ev)
+	}
+	if _, err = r.Next(); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Fatalf("Invalid event did not fail with the line number: %v", err)
+	}
+	if _, err = NewReader(strings.NewReader(`{"version": 1}`)); err == nil {
+		t.Fatalf("Unsupported version did not fail")
+	}
+}
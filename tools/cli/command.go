@@ -50,10 +50,13 @@ type Command struct {
 
 	option_map      map[string]*Option
 	IndexOfFirstArg int
+	// Creates the options and sub-commands of a command added with AddLazySubCommand
+	lazy_init func(*Command)
 }
 
-func (self *Command) Clone(parent *Command) *Command {
-	ans := *self
+func (self *Command) clone_into(ans *Command, parent *Command) {
+	self.ensure_initialized()
+	*ans = *self
 	ans.Args = make([]string, 0, 8)
 	ans.Parent = parent
 	ans.SubCommandGroups = make([]*CommandGroup, len(self.SubCommandGroups))
@@ -61,15 +64,30 @@ func (self *Command) Clone(parent *Command) *Command {
 	ans.option_map = nil
 
 	for i, o := range self.OptionGroups {
-		ans.OptionGroups[i] = o.Clone(&ans)
+		ans.OptionGroups[i] = o.Clone(ans)
 	}
 	for i, g := range self.SubCommandGroups {
-		ans.SubCommandGroups[i] = g.Clone(&ans)
+		ans.SubCommandGroups[i] = g.Clone(ans)
 	}
+}
+
+func (self *Command) Clone(parent *Command) *Command {
+	var ans Command
+	self.clone_into(&ans, parent)
 	return &ans
 }
 
 func (self *Command) AddClone(group string, src *Command) *Command {
+	if src.lazy_init != nil {
+		// Dont force initialization of src, instead the clone is itself
+		// lazy, keeping the name and visibility it is given by the caller
+		c := self.AddLazySubCommand(&Command{Name: src.Name, Group: group, ShortDescription: src.ShortDescription, Hidden: src.Hidden}, func(c *Command) {
+			name, group, hidden := c.Name, c.Group, c.Hidden
+			src.clone_into(c, self)
+			c.Name, c.Group, c.Hidden = name, group, hidden
+		})
+		return c
+	}
 	c := src.Clone(self)
 	g := self.AddSubCommandGroup(group)
 	c.Group = g.Title
@@ -111,7 +129,32 @@ func (self *Command) AddSubCommand(ans *Command) *Command {
 	return ans
 }
 
+// Add a sub-command whose options and sub-commands are only created, by
+// calling init, when they are actually needed, for example, when the
+// sub-command is run or its help is shown. This keeps startup fast when there
+// are many sub-commands. Only the fields needed to list the sub-command in
+// its parent should be set in ans.
+func (self *Command) AddLazySubCommand(ans *Command, init func(*Command)) *Command {
+	self.AddSubCommand(ans)
+	ans.lazy_init = init
+	return ans
+}
+
+func (self *Command) ensure_initialized() error {
+	if self.lazy_init == nil {
+		return nil
+	}
+	init := self.lazy_init
+	self.lazy_init = nil
+	init(self)
+	return self.Validate()
+}
+
 func (self *Command) Validate() error {
+	if self.lazy_init != nil {
+		// validated when initialized
+		return nil
+	}
 	seen_sc := make(map[string]bool)
 	for _, g := range self.SubCommandGroups {
 		for _, sc := range g.SubCommands {
@@ -154,6 +197,11 @@ func (self *Command) Validate() error {
 		self.option_map["Help"] = self.Add(OptionSpec{Name: "--help -h", Type: "bool-set", Help: "Show help for this command"})
 	}
 
+	if self.option_map["Profile"] == nil && !seen_flags["--profile"] {
+		// Hidden option to capture profiles for performance bug reports, see start_profiling()
+		self.option_map["Profile"] = self.Add(OptionSpec{Name: "--profile", Type: "list", Help: "!"})
+	}
+
 	if self.Parent == nil && self.option_map["Version"] == nil {
 		if seen_flags["--version"] {
 			return &ParseError{Message: fmt.Sprintf("The --version flag is assigned to an option other than Version in %s", self.Name)}
@@ -223,6 +271,7 @@ func (self *Command) ResetAfterParseArgs() {
 }
 
 func (self *Command) HasSubCommands() bool {
+	self.ensure_initialized()
 	for _, g := range self.SubCommandGroups {
 		if len(g.SubCommands) > 0 {
 			return true
@@ -232,6 +281,7 @@ func (self *Command) HasSubCommands() bool {
 }
 
 func (self *Command) HasVisibleSubCommands() bool {
+	self.ensure_initialized()
 	for _, g := range self.SubCommandGroups {
 		if g.HasVisibleSubCommands() {
 			return true
@@ -256,6 +306,7 @@ func (self *Command) VisitAllOptions(callback func(*Option) error) error {
 		return nil
 	}
 	for p := self; p != nil; p = p.Parent {
+		p.ensure_initialized()
 		err := iter_opts(p)
 		if err != nil {
 			return err
@@ -301,6 +352,7 @@ func (self *Command) GetVisibleOptions() ([]string, map[string][]*Option) {
 		}
 	}
 	for p := self; p != nil; p = p.Parent {
+		p.ensure_initialized()
 		process_cmd(p)
 		depth++
 	}
@@ -319,6 +371,7 @@ func sort_levenshtein_matches(q string, matches []string) {
 }
 
 func (self *Command) SuggestionsForCommand(name string, max_distance int /* good default is 2 */) []string {
+	self.ensure_initialized()
 	ans := make([]string, 0, 8)
 	q := strings.ToLower(name)
 	for _, g := range self.SubCommandGroups {
@@ -349,6 +402,7 @@ func (self *Command) SuggestionsForOption(name_with_hyphens string, max_distance
 }
 
 func (self *Command) FindSubCommand(name string) *Command {
+	self.ensure_initialized()
 	for _, g := range self.SubCommandGroups {
 		c := g.FindSubCommand(name)
 		if c != nil {
@@ -543,9 +597,17 @@ func (self *Command) ExecArgs(args []string) (exit_code int) {
 		root.ShowVersion()
 		return
 	} else if cmd.Run != nil {
+		stop_profiling, perr := cmd.start_profiling()
+		if perr != nil {
+			ShowError(perr)
+			return 1
+		}
 		exit_code, err = cmd.Run(cmd, cmd.Args)
+		stop_profiling()
 		if err != nil {
-			if exit_code == 0 {
+			if ec := utils.ExitCodeFor(err); ec != 0 {
+				exit_code = ec
+			} else if exit_code == 0 {
 				exit_code = 1
 			}
 			if self.CallbackOnError != nil {
@@ -561,7 +623,9 @@ func (self *Command) Exec(args ...string) {
 	if len(args) == 0 {
 		args = os.Args
 	}
-	os.Exit(self.ExecArgs(args))
+	exit_code := self.ExecArgs(args)
+	utils.CleanupAtExit()
+	os.Exit(exit_code)
 }
 
 func (self *Command) GetCompletions(argv []string, init_completions func(*Completions)) *Completions {
@@ -0,0 +1,64 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestLazySubCommand(t *testing.T) {
+	num_inits := 0
+	root := NewRootCommand()
+	sc := root.AddLazySubCommand(&Command{Name: "lazy", ShortDescription: "A lazy command"}, func(cmd *Command) {
+		num_inits++
+		cmd.Add(OptionSpec{Name: "--opt", Default: "x"})
+	})
+	clone := root.AddClone("", sc)
+	clone.Name = "lazy-clone"
+	root.AddSubCommand(&Command{Name: "eager"})
+	if err := root.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.ParseArgs([]string{"kitten", "eager"}); err != nil {
+		t.Fatal(err)
+	}
+	if num_inits != 0 {
+		t.Fatalf("Lazy command initialized when not used")
+	}
+	cmd, err := root.ParseArgs([]string{"kitten", "lazy-clone", "--opt", "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type options struct{ Opt string }
+	var opts options
+	if err = cmd.GetOptionValues(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(options{"y"}, opts); diff != "" {
+		t.Fatalf("Failed to parse options of lazy clone:\n%s", diff)
+	}
+	if cmd != clone || cmd.Name != "lazy-clone" || num_inits != 1 {
+		t.Fatalf("Lazy clone not initialized correctly: name=%#v num_inits=%d", cmd.Name, num_inits)
+	}
+	root.ResetAfterParseArgs()
+	if _, err = root.ParseArgs([]string{"kitten", "lazy", "--opt", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if num_inits != 1 {
+		t.Fatalf("Lazy command initialized more than once: %d", num_inits)
+	}
+
+	root = NewRootCommand()
+	root.AddLazySubCommand(&Command{Name: "bad"}, func(cmd *Command) {
+		cmd.Add(OptionSpec{Name: "--opt"})
+		cmd.Add(OptionSpec{Name: "--opt"})
+	})
+	if _, err = root.ParseArgs([]string{"kitten", "bad"}); err == nil {
+		t.Fatalf("Duplicate options in lazy command not detected")
+	}
+}
@@ -119,6 +119,7 @@ func complete_word(word string, completions *Completions, only_args_allowed bool
 }
 
 func completion_parse_args(cmd *Command, words []string, completions *Completions) {
+	cmd.ensure_initialized()
 	completions.CurrentCmd = cmd
 	if len(words) == 0 {
 		complete_word("", completions, false, nil, 0)
@@ -176,6 +177,7 @@ func completion_parse_args(cmd *Command, words []string, completions *Completion
 					only_args_allowed = true
 					continue
 				}
+				sc.ensure_initialized()
 				completions.CurrentCmd = sc
 				cmd = sc
 				arg_num = 0
@@ -0,0 +1,114 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type search_field struct {
+	name, description string
+	values            []string
+}
+
+var window_search_fields = []search_field{
+	{name: "id", description: "Window id, negative numbers count from the most recent window"},
+	{name: "title", description: "Regular expression matching the window title"},
+	{name: "pid", description: "Process id of the process running in the window"},
+	{name: "cwd", description: "Regular expression matching the working directory"},
+	{name: "cmdline", description: "Regular expression matching the command line"},
+	{name: "num", description: "Position of the window in the current tab"},
+	{name: "env", description: "Environment variable name or name=value"},
+	{name: "var", description: "User variable name or name=value"},
+	{name: "state", description: "The state of the window", values: []string{
+		"active", "focused", "needs_attention", "parent_active", "parent_focused", "self", "overlay_parent"}},
+	{name: "neighbor", description: "Neighbor of the active window", values: []string{"left", "right", "top", "bottom"}},
+	{name: "recent", description: "Recently active windows, zero is the active window"},
+}
+
+var tab_search_fields = []search_field{
+	{name: "id", description: "Tab id, negative numbers count from the most recent tab"},
+	{name: "index", description: "Position of the tab in the active OS window"},
+	{name: "title", description: "Regular expression matching the tab title"},
+	{name: "window_id", description: "Id of a window in the tab"},
+	{name: "window_title", description: "Regular expression matching the title of a window in the tab"},
+	{name: "pid", description: "Process id of a process running in the tab"},
+	{name: "cwd", description: "Regular expression matching the working directory"},
+	{name: "cmdline", description: "Regular expression matching the command line"},
+	{name: "env", description: "Environment variable name or name=value"},
+	{name: "var", description: "User variable name or name=value"},
+	{name: "state", description: "The state of the tab", values: []string{
+		"active", "focused", "needs_attention", "parent_active", "parent_focused"}},
+	{name: "recent", description: "Recently active tabs, zero is the active tab"},
+}
+
+var search_operators = []string{"and", "or", "not"}
+
+func complete_search(fields []search_field, completions *Completions, word string) {
+	// Expressions can be combined with boolean operators, only the last
+	// term of the expression is completed
+	prefix := ""
+	if idx := strings.LastIndexAny(word, " ("); idx > -1 {
+		prefix, word = word[:idx+1], word[idx+1:]
+	}
+	field, query, has_query := strings.Cut(word, ":")
+	if !has_query {
+		mg := completions.AddMatchGroup("Search fields")
+		mg.NoTrailingSpace = true
+		for _, f := range fields {
+			if strings.HasPrefix(f.name, word) {
+				mg.AddMatch(prefix+f.name+":", f.description)
+			}
+		}
+		if prefix == "" && strings.HasPrefix("all", word) {
+			mg.AddMatch("all", "Match everything")
+		} else if prefix != "" {
+			for _, op := range search_operators {
+				if op != word && strings.HasPrefix(op, word) {
+					mg.AddMatch(prefix+op+" ", "Boolean operator")
+				}
+			}
+		}
+		return
+	}
+	var values []string
+	if field == "env" {
+		for _, x := range os.Environ() {
+			if name, _, found := strings.Cut(x, "="); found && name != "" {
+				values = append(values, name)
+			}
+		}
+		sort.Strings(values)
+	} else {
+		for _, f := range fields {
+			if f.name == field {
+				values = f.values
+				break
+			}
+		}
+	}
+	if len(values) > 0 {
+		mg := completions.AddMatchGroup("Values for " + field)
+		for _, v := range values {
+			if strings.HasPrefix(v, query) {
+				mg.AddMatch(prefix + field + ":" + v)
+			}
+		}
+	}
+}
+
+// Complete expressions used to select windows, for example, with the --match
+// option of remote control commands
+func CompleteWindowSearch(completions *Completions, word string, arg_num int) {
+	complete_search(window_search_fields, completions, word)
+}
+
+// Complete expressions used to select tabs
+func CompleteTabSearch(completions *Completions, word string, arg_num int) {
+	complete_search(tab_search_fields, completions, word)
+}
@@ -0,0 +1,37 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestSearchCompletion(t *testing.T) {
+	tc := func(f CompletionFunc, word string, expected ...string) {
+		t.Helper()
+		c := NewCompletions()
+		f(c, word, 0)
+		var actual []string
+		for _, mg := range c.Groups {
+			for _, m := range mg.Matches {
+				actual = append(actual, m.Word)
+			}
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Incorrect completions for %#v:\n%s", word, diff)
+		}
+	}
+	tc(CompleteWindowSearch, "c", "cwd:", "cmdline:")
+	tc(CompleteWindowSearch, "a", "all")
+	tc(CompleteTabSearch, "window_", "window_id:", "window_title:")
+	tc(CompleteWindowSearch, "neighbor:", "neighbor:left", "neighbor:right", "neighbor:top", "neighbor:bottom")
+	tc(CompleteWindowSearch, "id:1 and state:p", "id:1 and state:parent_active", "id:1 and state:parent_focused")
+	tc(CompleteTabSearch, "state:s")
+	tc(CompleteWindowSearch, "id:1 o", "id:1 or ")
+	tc(CompleteWindowSearch, "title:x")
+}
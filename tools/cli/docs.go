@@ -0,0 +1,494 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"kitty"
+	"kitty/tools/cli/markup"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+var DocFormats = []string{"man", "html", "markdown"}
+
+// The name of the documentation page for this command, for example: kitten-@-ls
+func (self *Command) doc_page_name() string {
+	var names []string
+	for p := self; p != nil; p = p.Parent {
+		names = append(names, p.Name)
+	}
+	slices.Reverse(names)
+	return strings.Join(names, "-")
+}
+
+func (self *CommandGroup) sorted_visible_sub_commands() []*Command {
+	ans := make([]*Command, 0, len(self.SubCommands))
+	for _, c := range self.SubCommands {
+		if !c.Hidden {
+			ans = append(ans, c)
+		}
+	}
+	slices.SortFunc(ans, func(a, b *Command) int { return strings.Compare(a.Name, b.Name) })
+	return ans
+}
+
+func doc_file_extension(format string, level int) string {
+	switch format {
+	case "html":
+		return "html"
+	case "markdown":
+		return "md"
+	}
+	return fmt.Sprint(level)
+}
+
+// Generate documentation in the specified format, one of DocFormats, for this
+// command, and if recurse is true, all its visible sub commands, placing the
+// generated files in output_dir
+func (self *Command) GenerateDocs(format, output_dir string, recurse bool) error {
+	return self.generate_docs(format, output_dir, 1, recurse)
+}
+
+func (self *Command) generate_docs(format, output_dir string, level int, recurse bool) (err error) {
+	if !slices.Contains(DocFormats, format) {
+		return fmt.Errorf("Unknown documentation format: %s", format)
+	}
+	outf, err := os.Create(filepath.Join(output_dir, self.doc_page_name()+"."+doc_file_extension(format, level)))
+	if err != nil {
+		return err
+	}
+	defer outf.Close()
+	w := bufio.NewWriter(outf)
+	switch format {
+	case "man":
+		self.write_man_page(w, level)
+	case "html":
+		self.write_html_page(w)
+	case "markdown":
+		self.write_markdown_page(w)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if recurse {
+		for _, g := range self.SubCommandGroups {
+			for _, c := range g.sorted_visible_sub_commands() {
+				if err = c.generate_docs(format, output_dir, level, recurse); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return
+}
+
+type help_block struct {
+	is_code   bool
+	is_bullet bool
+	text      string
+}
+
+// Split help text into paragraphs, bullet list items and code blocks
+func help_blocks(raw string) (ans []help_block) {
+	var current []string
+	in_code, next_is_code := false, false
+	flush := func() {
+		if len(current) > 0 {
+			if in_code {
+				ans = append(ans, help_block{is_code: true, text: strings.TrimRight(strings.Join(current, "\n"), "\n ")})
+			} else {
+				text := strings.Join(current, " ")
+				if strings.HasSuffix(text, "::") {
+					text = text[:len(text)-1]
+					next_is_code = true
+				}
+				b := help_block{text: text}
+				if strings.HasPrefix(text, "* ") || strings.HasPrefix(text, "- ") {
+					b.is_bullet, b.text = true, text[2:]
+				}
+				ans = append(ans, b)
+			}
+		}
+		current = nil
+	}
+	for _, line := range utils.Splitlines(raw) {
+		if strings.TrimSpace(line) == "#placeholder_for_formatting#" {
+			continue
+		}
+		if in_code {
+			if line == "" || indent_of_line(line) > 0 {
+				if len(current) > 0 || line != "" {
+					current = append(current, line)
+				}
+				continue
+			}
+			flush()
+			in_code = false
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, ".. code::"):
+			flush()
+			in_code = true
+		case trimmed == "":
+			flush()
+			if next_is_code {
+				in_code, next_is_code = true, false
+			}
+		case (strings.HasPrefix(trimmed, "* ") || strings.HasPrefix(trimmed, "- ")) && len(current) > 0:
+			flush()
+			current = append(current, trimmed)
+		default:
+			current = append(current, trimmed)
+		}
+	}
+	flush()
+	for i, b := range ans {
+		if b.is_code {
+			ans[i].text = dedent(b.text)
+		}
+	}
+	return
+}
+
+func dedent(text string) string {
+	lines := utils.Splitlines(text)
+	indent := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			if q := indent_of_line(l); indent < 0 || q < indent {
+				indent = q
+			}
+		}
+	}
+	for i, l := range lines {
+		if len(l) >= indent {
+			lines[i] = l[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The URL, if any, and the text for a role that links to other documentation
+func url_for_role(role, val string) (text, url string) {
+	text, target := markup.Text_and_target(val)
+	base := kitty.WebsiteBaseURL
+	switch role {
+	case "doc":
+		target = strings.Trim(target, "/")
+		if title, ok := kitty.DocTitleMap[target]; ok && text == target {
+			text = title
+		}
+		return text, base + target + "/"
+	case "ref":
+		if q, found := kitty.RefMap[target]; found {
+			return text, base + q
+		}
+		if strings.HasPrefix(target, "at-") {
+			// remote control commands
+			return text, base + "remote-control/#" + target
+		}
+		return text, ""
+	case "ac":
+		return text, base + "actions/#action-" + strings.ReplaceAll(target, "_", "-")
+	case "opt":
+		return text, base + "conf/#opt-kitty." + target
+	case "env", "envvar":
+		return text, base + "glossary/#envvar-" + target
+	case "iss":
+		return "Issue #" + val, "https://github.com/kovidgoyal/kitty/issues/" + val
+	case "pull":
+		return "PR #" + val, "https://github.com/kovidgoyal/kitty/pull/" + val
+	case "disc":
+		return "Discussion #" + val, "https://github.com/kovidgoyal/kitty/discussions/" + val
+	case "link":
+		return text, target
+	}
+	return val, ""
+}
+
+func option_name_for_role(val string) string {
+	idx := strings.LastIndex(val, "--")
+	if idx < 0 {
+		idx = strings.Index(val, "-")
+	}
+	if idx > -1 {
+		val = strings.TrimSuffix(val[idx:], ">")
+	}
+	return val
+}
+
+var html_escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func help_inline_to_html(text string) string {
+	return markup.ReplaceAllRSTRoles(html_escaper.Replace(text), func(group markup.Rst_format_match) string {
+		val := html.UnescapeString(group.Payload)
+		switch group.Role {
+		case "code":
+			return "<code>" + html.EscapeString(markup.Remove_backslash_escapes(val)) + "</code>"
+		case "option":
+			return "<code>" + html.EscapeString(option_name_for_role(val)) + "</code>"
+		case "file", "italic", "emph":
+			return "<em>" + html.EscapeString(val) + "</em>"
+		case "bold", "program":
+			return "<strong>" + html.EscapeString(val) + "</strong>"
+		}
+		text, url := url_for_role(group.Role, val)
+		if url == "" {
+			return html.EscapeString(text)
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(text))
+	})
+}
+
+func help_to_html(raw string) string {
+	b := strings.Builder{}
+	in_list := false
+	for _, block := range help_blocks(raw) {
+		if in_list && !block.is_bullet {
+			b.WriteString("</ul>\n")
+			in_list = false
+		}
+		switch {
+		case block.is_code:
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(block.text))
+		case block.is_bullet:
+			if !in_list {
+				b.WriteString("<ul>\n")
+				in_list = true
+			}
+			fmt.Fprintf(&b, "<li>%s</li>\n", help_inline_to_html(block.text))
+		default:
+			fmt.Fprintf(&b, "<p>%s</p>\n", help_inline_to_html(block.text))
+		}
+	}
+	if in_list {
+		b.WriteString("</ul>\n")
+	}
+	return b.String()
+}
+
+const markdown_special_chars = "\\*_<>[]|"
+
+func escape_markdown(text string) string {
+	b := strings.Builder{}
+	for _, ch := range text {
+		if strings.ContainsRune(markdown_special_chars, ch) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+func unescape_markdown(text string) string {
+	b := strings.Builder{}
+	prev_was_slash := false
+	for _, ch := range text {
+		if ch == '\\' && !prev_was_slash {
+			prev_was_slash = true
+			continue
+		}
+		if prev_was_slash && !strings.ContainsRune(markdown_special_chars, ch) {
+			b.WriteByte('\\')
+		}
+		prev_was_slash = false
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+func markdown_code(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+func help_inline_to_markdown(text string) string {
+	return markup.ReplaceAllRSTRoles(escape_markdown(text), func(group markup.Rst_format_match) string {
+		val := unescape_markdown(group.Payload)
+		switch group.Role {
+		case "code":
+			return markdown_code(markup.Remove_backslash_escapes(val))
+		case "option":
+			return markdown_code(option_name_for_role(val))
+		case "file", "italic", "emph":
+			return "*" + escape_markdown(val) + "*"
+		case "bold", "program":
+			return "**" + escape_markdown(val) + "**"
+		}
+		text, url := url_for_role(group.Role, val)
+		if url == "" {
+			return escape_markdown(text)
+		}
+		return fmt.Sprintf("[%s](%s)", escape_markdown(text), url)
+	})
+}
+
+func help_to_markdown(raw string) string {
+	b := strings.Builder{}
+	for i, block := range help_blocks(raw) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		switch {
+		case block.is_code:
+			fmt.Fprintf(&b, "```\n%s\n```\n", block.text)
+		case block.is_bullet:
+			fmt.Fprintf(&b, "* %s\n", help_inline_to_markdown(block.text))
+		default:
+			fmt.Fprintf(&b, "%s\n", help_inline_to_markdown(block.text))
+		}
+	}
+	return b.String()
+}
+
+func (self *Option) doc_default() string {
+	switch self.OptionType {
+	case StringOption:
+		if self.IsList {
+			return ""
+		}
+	case BoolOption, CountOption:
+		return ""
+	}
+	return self.Default
+}
+
+func (self *Option) alias_names() []string {
+	ans := make([]string, len(self.Aliases))
+	for i, a := range self.Aliases {
+		ans[i] = a.String()
+	}
+	return ans
+}
+
+func (self *Command) write_html_page(w io.Writer) {
+	name := self.doc_page_name()
+	p := func(format string, args ...any) { fmt.Fprintf(w, format+"\n", args...) }
+	e := html.EscapeString
+	p("<!DOCTYPE html>")
+	p(`<html lang="en">`)
+	p(`<head><meta charset="utf-8"><title>%s</title></head>`, e(name))
+	p("<body>")
+	p(`<h1>%s</h1>`, e(name))
+	p("<p>%s</p>", help_inline_to_html(self.ShortDescription))
+	p("<h2>Usage</h2>")
+	p("<pre><code>%s</code></pre>", e(strings.TrimSpace(self.CommandStringForUsage()+" "+self.Usage)))
+	if self.HelpText != "" {
+		p("<h2>Description</h2>")
+		fmt.Fprint(w, help_to_html(self.HelpText))
+	}
+	for _, g := range self.SubCommandGroups {
+		if !g.HasVisibleSubCommands() {
+			continue
+		}
+		title := g.Title
+		if title == "" {
+			title = "Commands"
+		}
+		p("<h2>%s</h2>", e(title))
+		p("<dl>")
+		for _, c := range g.sorted_visible_sub_commands() {
+			p(`<dt><a href="%s.html">%s</a></dt>`, e(c.doc_page_name()), e(c.Name))
+			p("<dd>%s</dd>", help_inline_to_html(c.ShortDescription))
+		}
+		p("</dl>")
+	}
+	group_titles, gmap := self.GetVisibleOptions()
+	for _, title := range group_titles {
+		ptitle := title
+		if title == "" {
+			ptitle = "Options"
+		}
+		p("<h2>%s</h2>", e(ptitle))
+		p("<dl>")
+		for _, opt := range gmap[title] {
+			names := opt.alias_names()
+			for i, n := range names {
+				names[i] = "<code>" + e(n) + "</code>"
+			}
+			dt := strings.Join(names, ", ")
+			if d := opt.doc_default(); d != "" {
+				dt += fmt.Sprintf(" [=<code>%s</code>]", e(d))
+			}
+			p("<dt>%s</dt>", dt)
+			p("<dd>")
+			fmt.Fprint(w, help_to_html(opt.Help))
+			if opt.Choices != nil {
+				p("<p>Choices: %s</p>", e(strings.Join(opt.Choices, ", ")))
+			}
+			p("</dd>")
+		}
+		p("</dl>")
+	}
+	p("<hr><p>kitten %s</p>", e(kitty.VersionString))
+	p("</body>")
+	p("</html>")
+}
+
+func (self *Command) write_markdown_page(w io.Writer) {
+	name := self.doc_page_name()
+	p := func(format string, args ...any) { fmt.Fprintf(w, format+"\n", args...) }
+	p("# %s\n", escape_markdown(name))
+	p("%s\n", help_inline_to_markdown(self.ShortDescription))
+	p("## Usage\n")
+	p("```\n%s\n```\n", strings.TrimSpace(self.CommandStringForUsage()+" "+self.Usage))
+	if self.HelpText != "" {
+		p("## Description\n")
+		p("%s", help_to_markdown(self.HelpText))
+	}
+	for _, g := range self.SubCommandGroups {
+		if !g.HasVisibleSubCommands() {
+			continue
+		}
+		title := g.Title
+		if title == "" {
+			title = "Commands"
+		}
+		p("## %s\n", escape_markdown(title))
+		for _, c := range g.sorted_visible_sub_commands() {
+			p("* [%s](%s.md): %s", escape_markdown(c.Name), c.doc_page_name(), help_inline_to_markdown(c.ShortDescription))
+		}
+		p("")
+	}
+	group_titles, gmap := self.GetVisibleOptions()
+	for _, title := range group_titles {
+		ptitle := title
+		if title == "" {
+			ptitle = "Options"
+		}
+		p("## %s\n", escape_markdown(ptitle))
+		for _, opt := range gmap[title] {
+			names := opt.alias_names()
+			for i, n := range names {
+				names[i] = markdown_code(n)
+			}
+			heading := strings.Join(names, ", ")
+			if d := opt.doc_default(); d != "" {
+				heading += " [=" + markdown_code(d) + "]"
+			}
+			p("### %s\n", heading)
+			p("%s", help_to_markdown(opt.Help))
+			if opt.Choices != nil {
+				p("Choices: %s\n", escape_markdown(strings.Join(opt.Choices, ", ")))
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kitty"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestDocsGeneration(t *testing.T) {
+	help := "Some *text* with :code:`a<b` and :option:`kitten @ ls --match`.\nSee :doc:`the docs <overview>`.\n\n" +
+		"* one\n* two\n\nAn example::\n\n    $ echo \"hi\"\n\nAfter :envvar:`KITTY_PID`"
+	base := kitty.WebsiteBaseURL
+	if diff := cmp.Diff(`Some \*text\* with `+"`a<b`"+` and `+"`--match`"+`. See [the docs](`+base+`overview/).
+
+* one
+
+* two
+
+An example:
+
+`+"```"+`
+$ echo "hi"
+`+"```"+`
+
+After [KITTY\_PID](`+base+`glossary/#envvar-KITTY_PID)
+`, help_to_markdown(help)); diff != "" {
+		t.Fatalf("Incorrect markdown:\n%s", diff)
+	}
+	if diff := cmp.Diff(`<p>Some *text* with <code>a&lt;b</code> and <code>--match</code>. See <a href="`+base+`overview/">the docs</a>.</p>
+<ul>
+<li>one</li>
+<li>two</li>
+</ul>
+<p>An example:</p>
+<pre><code>$ echo &#34;hi&#34;</code></pre>
+<p>After <a href="`+base+`glossary/#envvar-KITTY_PID">KITTY_PID</a></p>
+`, help_to_html(help)); diff != "" {
+		t.Fatalf("Incorrect HTML:\n%s", diff)
+	}
+
+	root := NewRootCommand()
+	root.Name = "test"
+	child := root.AddSubCommand(&Command{Name: "child", ShortDescription: "A child", HelpText: help})
+	child.Add(OptionSpec{Name: "--opt -o", Default: "x", Help: "An :code:`option`"})
+	root.AddSubCommand(&Command{Name: "hidden", Hidden: true})
+	tdir := t.TempDir()
+	for _, f := range DocFormats {
+		if err := root.GenerateDocs(f, tdir, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if diff := cmp.Diff([]string{"test-child.1", "test-child.html", "test-child.md", "test.1", "test.html", "test.md"}, names); diff != "" {
+		t.Fatalf("Incorrect generated files:\n%s", diff)
+	}
+	md, err := os.ReadFile(filepath.Join(tdir, "test-child.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(md), "### `--opt`, `-o` [=`x`]\n\nAn `option`\n") {
+		t.Fatalf("Option not present in markdown:\n%s", md)
+	}
+	if err := root.GenerateDocs("pdf", tdir, false); err == nil {
+		t.Fatalf("No error for unknown format")
+	}
+}
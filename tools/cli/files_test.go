@@ -89,6 +89,15 @@ func TestCompleteFiles(t *testing.T) {
 	test_candidates("odir/f", "odir/four.txt")
 	test_candidates("x")
 
+	// prefixes for entries in the root directory
+	top := strings.SplitN(tdir[1:], utils.Sep, 2)[0]
+	found := false
+	CompleteFiles(utils.Sep+top[:len(top)-1], func(entry *FileEntry) {
+		found = found || entry.CompletionCandidate == utils.Sep+top+utils.Sep
+	}, "")
+	if !found {
+		t.Fatalf("Did not get %#v as a completion candidate", utils.Sep+top+utils.Sep)
+	}
 }
 
 func TestCompleteExecutables(t *testing.T) {
@@ -10,7 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"golang.org/x/exp/slices"
 	"golang.org/x/sys/unix"
 
 	"kitty"
@@ -26,6 +25,9 @@ func ShowError(err error) {
 	formatter := markup.New(tty.IsTerminal(os.Stderr.Fd()))
 	msg := formatter.Prettify(err.Error())
 	fmt.Fprintln(os.Stderr, formatter.Err("Error")+":", msg)
+	for _, hint := range utils.HintsFor(err) {
+		fmt.Fprintln(os.Stderr, formatter.Italic("Hint")+":", formatter.Prettify(hint))
+	}
 }
 
 func (self *Command) version_string(formatter *markup.Context) string {
@@ -44,6 +46,7 @@ func format_with_indent(output io.Writer, text string, indent string, screen_wid
 }
 
 func (self *Command) FormatSubCommands(output io.Writer, formatter *markup.Context, screen_width int) {
+	self.ensure_initialized()
 	for _, g := range self.SubCommandGroups {
 		if !g.HasVisibleSubCommands() {
 			continue
@@ -136,19 +139,11 @@ func ShowHelpInPager(text string) {
 }
 
 func (self *Command) GenerateManPages(level int, recurse bool) (err error) {
-	var names []string
-	p := self
-	for p != nil {
-		names = append(names, p.Name)
-		p = p.Parent
-	}
-	slices.Reverse(names)
-	name := strings.Join(names, "-")
-	outf, err := os.Create(fmt.Sprintf("%s.%d", name, level))
-	if err != nil {
-		return err
-	}
-	defer outf.Close()
+	return self.generate_docs("man", ".", level, recurse)
+}
+
+func (self *Command) write_man_page(outf io.Writer, level int) {
+	name := self.doc_page_name()
 	fmt.Fprintf(outf, `.TH "%s" "1" "%s" "%s" "%s"`, name, time.Now().Format("Jan 02, 2006"), kitty.VersionString, "kitten Manual")
 	fmt.Fprintln(outf)
 	fmt.Fprintln(outf, ".SH Name")
@@ -172,19 +167,11 @@ func (self *Command) GenerateManPages(level int, recurse bool) (err error) {
 			}
 			fmt.Fprintln(outf, ".SH", title)
 
-			for _, c := range utils.Sort(g.SubCommands, func(a, b *Command) int { return strings.Compare(a.Name, b.Name) }) {
-				if c.Hidden {
-					continue
-				}
-				if recurse {
-					if err = c.GenerateManPages(level, recurse); err != nil {
-						return err
-					}
-				}
+			for _, c := range g.sorted_visible_sub_commands() {
 				fmt.Fprintln(outf, ".TP", "2")
 				fmt.Fprintln(outf, c.Name)
 				fmt.Fprintln(outf, escape_text_for_man(c.ShortDescription)+".", "See: ")
-				fmt.Fprintf(outf, ".MR %s %d\n", name+"-"+c.Name, level)
+				fmt.Fprintf(outf, ".MR %s %d\n", c.doc_page_name(), level)
 			}
 		}
 		fmt.Fprintln(outf, ".PP")
@@ -207,8 +194,6 @@ func (self *Command) GenerateManPages(level int, recurse bool) (err error) {
 			}
 		}
 	}
-
-	return
 }
 
 func (self *Command) ShowHelpWithCommandString(cs string) {
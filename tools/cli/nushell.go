@@ -0,0 +1,67 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"kitty/tools/cli/markup"
+)
+
+var _ = fmt.Print
+
+func nushell_completion_script(commands []string) (string, error) {
+	// Usage: kitten __complete__ setup nushell >> $nu.config-path
+	return `# Completion for kitty, kitten and clone-in-kitty, chains to any previously
+# configured external completer for other commands
+let __ksi_previous_completer = ($env.config?.completions?.external?.completer?)
+$env.config.completions.external.enable = true
+$env.config.completions.external.completer = {|spans|
+    if ($spans.0 in [kitty kitten clone-in-kitty edit-in-kitty]) {
+        $spans | to json --raw | ^kitten __complete__ nushell | from json
+    } else if $__ksi_previous_completer != null {
+        do $__ksi_previous_completer $spans
+    }
+}
+`, nil
+}
+
+// nushell passes the words on the command line, with the last one being the
+// word being completed, as a JSON array
+func nushell_input_parser(data []byte, shell_state map[string]string) ([][]string, error) {
+	var words []string
+	if err := json.Unmarshal(data, &words); err != nil {
+		return nil, err
+	}
+	return [][]string{words}, nil
+}
+
+type nushell_match struct {
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+}
+
+func nushell_output_serializer(completions []*Completions, shell_state map[string]string) ([]byte, error) {
+	if completions[0].Delegate.NumToRemove > 0 {
+		// nushell has no way to delegate to the completions of another
+		// command, null makes it fallback to completing file names
+		return []byte("null"), nil
+	}
+	fm := markup.New(false)
+	ans := make([]nushell_match, 0, 32)
+	for _, mg := range completions[0].Groups {
+		for _, m := range mg.Matches {
+			desc, _, _ := strings.Cut(strings.TrimSpace(m.Description), "\n")
+			ans = append(ans, nushell_match{Value: m.Word, Description: fm.Prettify(desc)})
+		}
+	}
+	return json.Marshal(ans)
+}
+
+func init() {
+	completion_scripts["nushell"] = nushell_completion_script
+	input_parsers["nushell"] = nushell_input_parser
+	output_serializers["nushell"] = nushell_output_serializer
+}
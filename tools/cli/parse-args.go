@@ -10,6 +10,9 @@ import (
 var _ = fmt.Print
 
 func (self *Command) parse_args(ctx *Context, args []string) error {
+	if err := self.ensure_initialized(); err != nil {
+		return err
+	}
 	args_to_parse := make([]string, len(args))
 	copy(args_to_parse, args)
 	ctx.SeenCommands = append(ctx.SeenCommands, self)
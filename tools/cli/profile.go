@@ -0,0 +1,137 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"sync"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Environment variable that can be used to profile a kitten when it is not
+// possible to change its command line, for example, when it is run by kitty.
+// Its value is a comma separated list of profile specifications.
+const PROFILE_ENV_VAR = "KITTEN_PROFILE"
+
+type profile_spec struct {
+	kind, path string
+}
+
+func parse_profile_specs(cmd_name string, specs []string) (ans []profile_spec, err error) {
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		kind, path, _ := strings.Cut(spec, "=")
+		switch kind {
+		case "cpu", "mem", "trace":
+		default:
+			return nil, fmt.Errorf("Unknown profile type: %#v. Must be one of: cpu, mem or trace", kind)
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("The %s profile was specified more than once", kind)
+		}
+		seen[kind] = true
+		if path == "" {
+			ext := "pprof"
+			if kind == "trace" {
+				ext = "out"
+			}
+			path = fmt.Sprintf("kitten-%s-%s.%s", strings.ReplaceAll(cmd_name, " ", "-"), kind, ext)
+		}
+		ans = append(ans, profile_spec{kind, path})
+	}
+	return
+}
+
+// Start the specified profiles, returning a function that must be called to
+// stop them and write the collected data.
+func start_profiling(specs []profile_spec) (stop func(), err error) {
+	var stoppers []func()
+	stop = func() {
+		for _, s := range stoppers {
+			s()
+		}
+	}
+	defer func() {
+		if err != nil {
+			stop()
+			stop = nil
+		}
+	}()
+	for _, spec := range specs {
+		var f *os.File
+		if f, err = os.Create(spec.path); err != nil {
+			return
+		}
+		done := func(err error) {
+			if err == nil {
+				err = f.Close()
+			} else {
+				f.Close()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write %s profile to %s with error: %s\n", spec.kind, spec.path, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Wrote %s profile to: %s\n", spec.kind, spec.path)
+			}
+		}
+		switch spec.kind {
+		case "cpu":
+			if err = pprof.StartCPUProfile(f); err != nil {
+				f.Close()
+				return
+			}
+			stoppers = append(stoppers, func() { pprof.StopCPUProfile(); done(nil) })
+		case "trace":
+			if err = trace.Start(f); err != nil {
+				f.Close()
+				return
+			}
+			stoppers = append(stoppers, func() { trace.Stop(); done(nil) })
+		case "mem":
+			stoppers = append(stoppers, func() {
+				// ensure the profile reflects all allocations up to now
+				runtime.GC()
+				done(pprof.Lookup("allocs").WriteTo(f, 0))
+			})
+		}
+	}
+	return
+}
+
+// Start profiling if requested via the --profile option or the environment
+// variable. The profiles are also stopped by utils.CleanupAtExit(), so that
+// they are written when exiting via os.Exit() or an exit signal. The
+// environment variable is removed so that it does not cause child processes,
+// such as other kittens, to overwrite the profiles.
+func (self *Command) start_profiling() (stop func(), err error) {
+	var specs []string
+	if opt := self.option_map["Profile"]; opt != nil {
+		specs = opt.parsed_value().([]string)
+	}
+	if len(specs) == 0 {
+		specs = strings.Split(os.Getenv(PROFILE_ENV_VAR), ",")
+	}
+	os.Unsetenv(PROFILE_ENV_VAR)
+	ps, err := parse_profile_specs(self.CommandStringForUsage(), specs)
+	if err != nil || len(ps) == 0 {
+		return func() {}, err
+	}
+	s, err := start_profiling(ps)
+	if err != nil {
+		return nil, err
+	}
+	s = sync.OnceFunc(s)
+	unregister := utils.AtExit(s)
+	return func() { unregister(); s() }, nil
+}
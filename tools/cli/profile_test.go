@@ -0,0 +1,86 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kitty/tools/utils"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestProfileSpecs(t *testing.T) {
+	ps, err := parse_profile_specs("kitten diff", []string{"cpu", " trace=/t", "", "mem=/m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []profile_spec{{"cpu", "kitten-kitten-diff-cpu.pprof"}, {"trace", "/t"}, {"mem", "/m"}}
+	if diff := cmp.Diff(expected, ps, cmp.AllowUnexported(profile_spec{})); diff != "" {
+		t.Fatalf("Unexpected profile specs:\n%s", diff)
+	}
+	for _, bad := range [][]string{{"xxx"}, {"cpu=a", "cpu=b"}} {
+		if _, err := parse_profile_specs("x", bad); err == nil {
+			t.Fatalf("No error for invalid profile specs: %#v", bad)
+		}
+	}
+}
+
+func TestProfiling(t *testing.T) {
+	tdir := t.TempDir()
+	ran := false
+	new_root := func() *Command {
+		root := NewRootCommand()
+		root.AddSubCommand(&Command{Name: "work", Run: func(cmd *Command, args []string) (int, error) {
+			ran = true
+			x := make([][]byte, 0, 64)
+			for i := 0; i < 64; i++ {
+				x = append(x, make([]byte, 1024*1024))
+			}
+			return len(x) - 64, nil
+		}})
+		return root
+	}
+	var args []string
+	for _, kind := range []string{"cpu", "mem", "trace"} {
+		args = append(args, "--profile", kind+"="+filepath.Join(tdir, kind))
+	}
+	t.Setenv(PROFILE_ENV_VAR, "")
+	if ec := new_root().ExecArgs(append([]string{"kitten", "work"}, args...)); ec != 0 || !ran {
+		t.Fatalf("Running command with profiling failed with exit code: %d", ec)
+	}
+	for _, kind := range []string{"cpu", "mem", "trace"} {
+		if s, err := os.Stat(filepath.Join(tdir, kind)); err != nil || s.Size() == 0 {
+			t.Fatalf("The %s profile was not written: %v", kind, err)
+		}
+	}
+	t.Setenv(PROFILE_ENV_VAR, "mem="+filepath.Join(tdir, "env-mem"))
+	ran = false
+	if ec := new_root().ExecArgs([]string{"kitten", "work"}); ec != 0 || !ran {
+		t.Fatalf("Running command with profiling failed with exit code: %d", ec)
+	}
+	if _, err := os.Stat(filepath.Join(tdir, "env-mem")); err != nil {
+		t.Fatalf("The profile requested via the environment was not written: %s", err)
+	}
+	if v, found := os.LookupEnv(PROFILE_ENV_VAR); found {
+		t.Fatalf("The profile environment variable was not removed for child processes: %#v", v)
+	}
+
+	// profiles are written when exiting via os.Exit()
+	cmd := &Command{Name: "exit"}
+	t.Setenv(PROFILE_ENV_VAR, "cpu="+filepath.Join(tdir, "exit-cpu"))
+	stop, err := cmd.start_profiling()
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.CleanupAtExit()
+	if s, err := os.Stat(filepath.Join(tdir, "exit-cpu")); err != nil || s.Size() == 0 {
+		t.Fatalf("The profile was not written by the exit hooks: %v", err)
+	}
+	stop()
+}
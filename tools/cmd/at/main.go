@@ -3,7 +3,6 @@
 package at
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,20 +16,19 @@ import (
 
 	"golang.org/x/sys/unix"
 
-	"kitty"
 	"kitty/tools/cli"
 	"kitty/tools/crypto"
+	"kitty/tools/rc"
 	"kitty/tools/tty"
 	"kitty/tools/tui"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
-	"kitty/tools/utils/base85"
 	"kitty/tools/utils/shlex"
 )
 
 const lowerhex = "0123456789abcdef"
 
-var ProtocolVersion [3]int = [3]int{0, 26, 0}
+var ProtocolVersion [3]int = rc.ProtocolVersion
 
 type GlobalOptions struct {
 	to_network, to_address, password string
@@ -71,21 +69,7 @@ func get_pubkey(encoded_key string) (encryption_version string, pubkey []byte, e
 			return
 		}
 	}
-	encryption_version, encoded_key, found := strings.Cut(encoded_key, ":")
-	if !found {
-		err = fmt.Errorf("KITTY_PUBLIC_KEY environment variable does not have a : in it")
-		return
-	}
-	if encryption_version != kitty.RC_ENCRYPTION_PROTOCOL_VERSION {
-		err = fmt.Errorf("KITTY_PUBLIC_KEY has unknown version, if you are running on a remote system, update kitty on this system")
-		return
-	}
-	pubkey = make([]byte, base85.DecodedLen(len(encoded_key)))
-	n, err := base85.Decode(pubkey, []byte(encoded_key))
-	if err == nil {
-		pubkey = pubkey[:n]
-	}
-	return
+	return rc.ParsePublicKey(encoded_key)
 }
 
 type escaped_string string
@@ -156,33 +140,6 @@ func create_serializer(password string, encoded_pubkey string, io_data *rc_io_da
 	return nil
 }
 
-type ResponseData struct {
-	as_str    string
-	is_string bool
-}
-
-func (self *ResponseData) UnmarshalJSON(data []byte) error {
-	if bytes.HasPrefix(data, []byte("\"")) {
-		self.is_string = true
-		return json.Unmarshal(data, &self.as_str)
-	}
-	if bytes.Equal(data, []byte("true")) {
-		self.as_str = "True"
-	} else if bytes.Equal(data, []byte("false")) {
-		self.as_str = "False"
-	} else {
-		self.as_str = string(data)
-	}
-	return nil
-}
-
-type Response struct {
-	Ok        bool         `json:"ok"`
-	Data      ResponseData `json:"data,omitempty"`
-	Error     string       `json:"error,omitempty"`
-	Traceback string       `json:"tb,omitempty"`
-}
-
 type rc_io_data struct {
 	cmd                        *cli.Command
 	rc                         *utils.RemoteControlCmd
@@ -236,13 +193,9 @@ func get_response(do_io func(io_data *rc_io_data) ([]byte, error), io_data *rc_i
 		err = fmt.Errorf("Received empty response from kitty")
 		return
 	}
-	var response Response
-	err = json.Unmarshal(serialized_response, &response)
-	if err != nil {
+	if ans, err = decode_response(serialized_response); err != nil {
 		err = fmt.Errorf("Invalid response received from kitty, unmarshalling error: %w", err)
-		return
 	}
-	ans = &response
 	return
 }
 
@@ -278,7 +231,7 @@ func send_rc_command(io_data *rc_io_data) (err error) {
 		if response.Traceback != "" {
 			fmt.Fprintln(os.Stderr, response.Traceback)
 		}
-		return fmt.Errorf("%s", response.Error)
+		return hint_for_response_error(response.Error)
 	}
 	if response.Data.is_string && io_data.string_response_is_err {
 		return fmt.Errorf("%s", response.Data.as_str)
@@ -342,10 +295,26 @@ func get_password(password string, password_file string, password_env string, us
 	return ans, nil
 }
 
-var all_commands []func(*cli.Command) *cli.Command = make([]func(*cli.Command) *cli.Command, 0, 64)
+type at_cmd struct {
+	create    func() *cli.Command
+	add_flags func(*cli.Command)
+}
+
+var all_commands []at_cmd = make([]at_cmd, 0, 64)
+
+func register_at_cmd(create func() *cli.Command, add_flags func(*cli.Command)) {
+	all_commands = append(all_commands, at_cmd{create, add_flags})
+}
 
-func register_at_cmd(f func(*cli.Command) *cli.Command) {
-	all_commands = append(all_commands, f)
+func hint_for_response_error(msg string) error {
+	err := fmt.Errorf("%s", msg)
+	switch {
+	case strings.HasPrefix(msg, "Remote control is disabled"):
+		return utils.WithHint(err, "Set :opt:`allow_remote_control` in kitty.conf and restart kitty, or use a password with :opt:`remote_control_password`.")
+	case strings.HasPrefix(msg, "Remote control is allowed over a socket only"):
+		return utils.WithHint(err, "Use :option:`kitten @ --to` or set :opt:`listen_on` in kitty.conf to send commands via a socket.")
+	}
+	return err
 }
 
 func setup_global_options(cmd *cli.Command) (err error) {
@@ -387,12 +356,16 @@ func EntryPoint(tool_root *cli.Command) *cli.Command {
 
 	global_options_group := at_root_command.OptionGroups[0]
 
-	for _, reg_func := range all_commands {
-		c := reg_func(at_root_command)
-		clone := tool_root.AddClone("", c)
-		clone.Name = "@" + c.Name
+	// The options of the sub-commands are only created when needed as there
+	// are a great many of them and they would slow down startup of every kitten
+	for _, x := range all_commands {
+		at_root_command.AddLazySubCommand(x.create(), x.add_flags)
+		clone := tool_root.AddLazySubCommand(x.create(), func(clone *cli.Command) {
+			x.add_flags(clone)
+			clone.OptionGroups = append(clone.OptionGroups, global_options_group.Clone(clone))
+		})
+		clone.Name = "@" + clone.Name
 		clone.Hidden = true
-		clone.OptionGroups = append(clone.OptionGroups, global_options_group.Clone(clone))
 	}
 	return at_root_command
 }
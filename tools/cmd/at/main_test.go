@@ -5,9 +5,12 @@ package at
 import (
 	"encoding/json"
 	"fmt"
+	"kitty/tools/cli"
 	"kitty/tools/crypto"
 	"kitty/tools/utils"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestEncodeJSON(t *testing.T) {
@@ -102,3 +105,107 @@ func TestRCSerialization(t *testing.T) {
 		t.Fatal("Incorrect version in encrypted command: ", ec.Version)
 	}
 }
+
+func TestCommandsValidate(t *testing.T) {
+	// the options of the commands are created lazily, so check them all here
+	root := cli.NewRootCommand()
+	EntryPoint(root)
+	for _, x := range all_commands {
+		c := root.AddSubCommand(x.create())
+		x.add_flags(c)
+	}
+	if err := root.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"@", "@ls"} {
+		c := root.FindSubCommand(name)
+		if c == nil || !c.HasSubCommands() && len(c.AllOptions()) < 2 {
+			t.Fatalf("The command %s was not created correctly", name)
+		}
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	type r struct {
+		ok, is_string bool
+		data, err, tb string
+	}
+	for serialized, expected := range map[string]r{
+		`{"ok": true, "data": "a\nb •"}`:                 {ok: true, is_string: true, data: "a\nb •"},
+		`{"ok":true,"data":true}`:                        {ok: true, data: "True"},
+		`{"data":false, "ok":true}`:                      {ok: true, data: "False"},
+		`{"ok":true,"data":12345678901234567890}`:        {ok: true, data: "12345678901234567890"},
+		`{"ok":true,"data":{"a": [1, 2]}}`:               {ok: true, data: `{"a": [1, 2]}`},
+		`{"ok":true,"data":null}`:                        {ok: true, data: "null"},
+		`{"ok":false,"error":"bad","tb":"trace","x":{}}`: {err: "bad", tb: "trace"},
+	} {
+		resp, err := decode_response([]byte(serialized))
+		if err != nil {
+			t.Fatalf("Failed to decode %s with error: %s", serialized, err)
+		}
+		actual := r{ok: resp.Ok, is_string: resp.Data.is_string, data: resp.Data.as_str, err: resp.Error, tb: resp.Traceback}
+		if actual != expected {
+			t.Fatalf("Incorrect decoding of %s\n%#v != %#v", serialized, expected, actual)
+		}
+	}
+	for _, serialized := range []string{`[]`, `{"ok": "x"}`, `{"ok": true`, `{"ok": true, "data": [1,}`} {
+		if _, err := decode_response([]byte(serialized)); err == nil {
+			t.Fatalf("Decoding invalid response did not fail: %s", serialized)
+		}
+	}
+}
+
+func BenchmarkDecodeResponse(b *testing.B) {
+	data := strings.Repeat(`{"id": 1, "title": "some title •", "cmdline": ["/bin/zsh", "-l"], "is_focused": true}, `, 64*1024)
+	serialized, _ := json.Marshal(map[string]any{"ok": true, "data": data})
+	b.SetBytes(int64(len(serialized)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := decode_response(serialized); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	for _, x := range []string{
+		`{"ok": true, "data": "a\nb • 🄣 \ud83c \udd23x \\\"\/\b\f\r\t"}`, `{"ok":true,"data":{"a": [1, "2"]}}`,
+		`{"ok":false,"error":"bad","tb":null, "data": 1.5e3}`, `{"data": false}`, `{}`, "{}\x00", `{"OK":true,"dAtA":0}`,
+	} {
+		f.Add([]byte(x))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if !utf8.Valid(data) {
+			// the builtin decoder replaces invalid UTF-8, kitty never sends it
+			return
+		}
+		var expected Response
+		expected_err := json.Unmarshal(data, &expected)
+		actual, err := decode_response(data)
+		if expected_err != nil {
+			if err == nil && !strings.Contains(expected_err.Error(), "cannot unmarshal") {
+				t.Fatalf("Decoding invalid response did not fail: %#v", string(data))
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Decoding %#v failed with error: %s", string(data), err)
+		}
+		if *actual != expected {
+			t.Fatalf("Incorrect decoding of %#v\n%#v != %#v", string(data), expected, *actual)
+		}
+	})
+}
+
+func TestParseMarkerSpec(t *testing.T) {
+	for _, good := range [][]string{{"text", "1", "ERROR"}, {"iregex", "1", "ERROR", "3", "WARN.*"}, {"function", "mymarker.py"}} {
+		if _, err := parse_marker_spec(good); err != nil {
+			t.Fatalf("Valid marker specification %#v failed to parse: %s", good, err)
+		}
+	}
+	for _, bad := range [][]string{{"text", "1"}, {"text", "4", "x"}, {"regex", "a", "x"}, {"other", "1", "x"}} {
+		if _, err := parse_marker_spec(bad); err == nil {
+			t.Fatalf("Invalid marker specification %#v did not fail", bad)
+		}
+	}
+}
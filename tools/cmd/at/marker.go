@@ -0,0 +1,32 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// Validate the marker specification locally, so that mistakes are reported
+// without a round trip to kitty
+func parse_marker_spec(args []string) ([]escaped_string, error) {
+	switch args[0] {
+	case "text", "itext", "regex", "iregex":
+		parts := args[1:]
+		if len(parts)%2 != 0 {
+			return nil, fmt.Errorf("Mark group number and text/regex are not specified in pairs: %s", strings.Join(parts, " "))
+		}
+		for i := 0; i < len(parts); i += 2 {
+			if n, err := strconv.Atoi(parts[i]); err != nil || n < 1 || n > 3 {
+				return nil, fmt.Errorf("Mark group in marker specification must be one of 1, 2 or 3, not: %s", parts[i])
+			}
+		}
+	case "function":
+	default:
+		return nil, fmt.Errorf("Unknown marker type: %s. Must be one of: text, itext, regex, iregex or function", args[0])
+	}
+	return escape_list_of_strings(args), nil
+}
@@ -0,0 +1,95 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type ResponseData struct {
+	as_str    string
+	is_string bool
+}
+
+func (self *ResponseData) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte("\"")) {
+		self.is_string = true
+		return json.Unmarshal(data, &self.as_str)
+	}
+	if bytes.Equal(data, []byte("true")) {
+		self.as_str = "True"
+	} else if bytes.Equal(data, []byte("false")) {
+		self.as_str = "False"
+	} else {
+		self.as_str = string(data)
+	}
+	return nil
+}
+
+type Response struct {
+	Ok        bool         `json:"ok"`
+	Data      ResponseData `json:"data,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Traceback string       `json:"tb,omitempty"`
+}
+
+func expect_delim(dec *json.Decoder, q json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != q {
+		return fmt.Errorf("Unexpected token at offset %d: %v, expected: %s", dec.InputOffset(), t, q)
+	}
+	return nil
+}
+
+// Responses such as the output of ls or get-text can be very large, so
+// rather than unmarshalling the whole response at once, which buffers and
+// validates it in full before decoding any field, the top level object is
+// read token by token, decoding each field directly into its typed value.
+func decode_response(serialized_response []byte) (ans *Response, err error) {
+	dec := json.NewDecoder(bytes.NewReader(serialized_response))
+	if err = expect_delim(dec, '{'); err != nil {
+		return nil, err
+	}
+	ans = &Response{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := t.(string)
+		switch {
+		// match keys case insensitively, like json.Unmarshal
+		case strings.EqualFold(key, "ok"):
+			err = dec.Decode(&ans.Ok)
+		case strings.EqualFold(key, "data"):
+			err = dec.Decode(&ans.Data)
+		case strings.EqualFold(key, "error"):
+			err = dec.Decode(&ans.Error)
+		case strings.EqualFold(key, "tb"):
+			err = dec.Decode(&ans.Traceback)
+		default:
+			var ignored json.RawMessage
+			err = dec.Decode(&ignored)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = expect_delim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Unexpected data after the end of the response at offset: %d", dec.InputOffset())
+	}
+	return ans, nil
+}
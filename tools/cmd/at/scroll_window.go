@@ -38,3 +38,31 @@ func parse_scroll_amount(amt string) ([]any, error) {
 	}
 	return ans, nil
 }
+
+func parse_scroll_target(spec string) error {
+	kind, val, found := strings.Cut(spec, ":")
+	switch {
+	case !found:
+	case kind == "match":
+		return nil
+	case kind == "line" || kind == "prompt":
+		if n, err := strconv.Atoi(val); err != nil || n == 0 {
+			return fmt.Errorf("Invalid scroll target: %s, N must be a non-zero integer", spec)
+		}
+		return nil
+	}
+	return fmt.Errorf("Invalid scroll target: %s, must be one of line:N, prompt:N or match:REGEX", spec)
+}
+
+func parse_scroll_args(args []string, target string) ([]any, error) {
+	if target != "" {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("Cannot specify both a scroll amount and --target")
+		}
+		return nil, parse_scroll_target(target)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("Must specify exactly 1 argument(s) for scroll-window")
+	}
+	return parse_scroll_amount(args[0])
+}
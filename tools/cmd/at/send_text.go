@@ -5,7 +5,6 @@ package at
 import (
 	"encoding/base64"
 	"errors"
-	"io"
 	"kitty/tools/tty"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
@@ -17,19 +16,25 @@ import (
 var end_reading_from_stdin = errors.New("end reading from STDIN")
 var waiting_on_stdin = errors.New("wait for key events from STDIN")
 
+// Send the contents of f a line at a time, so that programs reading the text
+// in the window receive complete lines, splitting lines that do not fit in a
+// single payload
 func make_file_gen(f *os.File) func(*rc_io_data) (bool, error) {
-	chunk := make([]byte, 2048)
+	scanner := utils.NewRecordScanner(f, utils.RecordScannerOptions{MaxRecordSize: 2048, SplitTooLong: true})
 	file_gen := func(io_data *rc_io_data) (bool, error) {
-		n, err := f.Read(chunk)
-		if err != nil && !errors.Is(err, io.EOF) {
-			return false, err
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return false, err
+			}
+			set_payload_data(io_data, "base64:")
+			return true, nil
 		}
-		set_payload_data(io_data, "base64:"+base64.StdEncoding.EncodeToString(chunk[:n]))
-		return n == 0 || errors.Is(err, io.EOF), nil
+		set_payload_data(io_data, "base64:"+base64.StdEncoding.EncodeToString(utils.UnsafeStringToBytes(scanner.Text()+scanner.Delimiter())))
+		return false, nil
 	}
 	return file_gen
-
 }
+
 func parse_send_text(io_data *rc_io_data, args []string) error {
 	generators := make([]func(io_data *rc_io_data) (bool, error), 0, 1)
 
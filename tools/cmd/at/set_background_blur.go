@@ -0,0 +1,25 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func parse_background_blur(args []string, payload *set_background_blur_json_type) error {
+	if len(args) != 1 {
+		return fmt.Errorf("At most one blur radius must be specified")
+	}
+	switch args[0] {
+	case "toggle", "reset":
+		payload.Action = args[0]
+		return nil
+	}
+	radius, err := strconv.Atoi(args[0])
+	if err != nil || radius < 0 {
+		return fmt.Errorf("%s is not a valid blur radius, must be a non-negative integer or toggle or reset", args[0])
+	}
+	payload.Action, payload.Radius = "set", radius
+	return nil
+}
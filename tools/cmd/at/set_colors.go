@@ -3,7 +3,6 @@
 package at
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
@@ -61,13 +60,16 @@ func parse_colors_and_files(args []string) (map[string]any, error) {
 				return nil, err
 			}
 			defer f.Close()
-			scanner := bufio.NewScanner(f)
+			scanner := utils.NewRecordScanner(f)
 			for scanner.Scan() {
 				key, val, found := strings.Cut(scanner.Text(), " ")
 				if found {
 					set_color_in_color_map(strings.ToLower(key), strings.ToLower(strings.TrimSpace(val)), ans, true, true)
 				}
 			}
+			if err = scanner.Err(); err != nil {
+				return nil, fmt.Errorf("Failed to read the colors from %s with error: %w", path, err)
+			}
 		}
 	}
 	return ans, nil
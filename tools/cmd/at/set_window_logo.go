@@ -27,25 +27,38 @@ func read_window_logo(io_data *rc_io_data, path string) (func(io_data *rc_io_dat
 		}, nil
 	}
 
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	var f io.ReadSeeker
+	var closer io.Closer = io.NopCloser(nil)
+	if path == "-" {
+		// read the image data from STDIN so it can be piped in
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read image data from STDIN with error: %w", err)
+		}
+		f = bytes.NewReader(data)
+		path = "Data from STDIN"
+	} else {
+		ff, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		f, closer = ff, ff
 	}
 	var image_data_stream io.Reader
 	image_data_stream = f
 	config, format, ierr := image.DecodeConfig(f)
 	if ierr != nil {
+		closer.Close()
 		return nil, fmt.Errorf("%s is not a supported image format", path)
 	}
 	f.Seek(0, 0)
 
 	if format != "png" {
-		f.Seek(0, 0)
 		img, _, err := image.Decode(f)
+		closer.Close()
 		if err != nil {
-			f.Close()
+			return nil, err
 		}
-		f.Close()
 		b := bytes.Buffer{}
 		b.Grow(config.Height * config.Width * 4)
 		err = images.Encode(&b, img, "image/png")
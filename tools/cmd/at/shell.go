@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -225,7 +224,7 @@ func shell_main(cmd *cli.Command, args []string) (int, error) {
 		return ans
 	}
 
-	rl = readline.New(nil, readline.RlInit{Prompt: prompt, Completer: combined_completer, HistoryPath: filepath.Join(utils.CacheDir(), "shell.history.json")})
+	rl = readline.New(nil, readline.RlInit{Prompt: prompt, Completer: combined_completer, HistoryPath: utils.StatePath("shell.history.json")})
 	defer func() {
 		rl.Shutdown()
 	}()
@@ -7,11 +7,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"os"
 	"strconv"
 	"time"
 
+	"golang.org/x/sys/unix"
+
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
 	"kitty/tools/wcswidth"
@@ -152,6 +155,19 @@ func simple_socket_io(conn *net.Conn, io_data *rc_io_data) (serialized_response
 	return read_response_from_conn(conn, io_data.timeout)
 }
 
+func hint_for_dial_error(err error) error {
+	src := utils.IfElse(global_options.to_address_is_from_env_var, "the KITTY_LISTEN_ON environment variable", "the --to option")
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, unix.ECONNREFUSED):
+		return utils.WithHint(err, fmt.Sprintf(
+			"No kitty instance is listening on the socket %s specified by %s. Check that kitty is running and that it was started with :option:`kitty --listen-on` or has :opt:`listen_on` set in kitty.conf.",
+			global_options.to_address, src))
+	case errors.Is(err, fs.ErrPermission):
+		return utils.WithHint(err, fmt.Sprintf("The socket %s specified by %s belongs to a different user.", global_options.to_address, src))
+	}
+	return err
+}
+
 func do_socket_io(io_data *rc_io_data) (serialized_response []byte, err error) {
 	var conn net.Conn
 	if global_options.to_network == "fd" {
@@ -168,7 +184,7 @@ func do_socket_io(io_data *rc_io_data) (serialized_response []byte, err error) {
 	} else {
 		conn, err = net.Dial(global_options.to_network, global_options.to_address)
 		if err != nil {
-			return
+			return nil, hint_for_dial_error(err)
 		}
 	}
 	defer conn.Close()
@@ -93,18 +93,20 @@ func run_CMD_NAME(cmd *cli.Command, args []string) (return_code int, err error)
 	return
 }
 
-func setup_CMD_NAME(parent *cli.Command) *cli.Command {
-	ans := parent.AddSubCommand(&cli.Command{
+func create_CMD_NAME() *cli.Command {
+	return &cli.Command{
 		Name:             "CLI_NAME",
 		Usage:            "ARGSPEC",
 		ShortDescription: "SHORT_DESC",
 		HelpText:         "LONG_DESC",
 		Run:              run_CMD_NAME,
-	})
+	}
+}
+
+func add_flags_CMD_NAME(ans *cli.Command) {
 	ADD_FLAGS_CODE
-	return ans
 }
 
 func init() {
-	register_at_cmd(setup_CMD_NAME)
+	register_at_cmd(create_CMD_NAME, add_flags_CMD_NAME)
 }
@@ -26,6 +26,8 @@ type Options struct {
 	Repetitions    int
 	WithScrollback bool
 	Render         bool
+	Format         string
+	LatencySamples int
 }
 
 const reset = "\x1b]\x1b\\\x1bc"
@@ -123,6 +125,7 @@ func random_string_of_bytes(n int, alphabet string) string {
 }
 
 type result struct {
+	name        string
 	desc        string
 	data_sz     int
 	duration    time.Duration
@@ -136,7 +139,7 @@ func simple_ascii() (r result, err error) {
 	if err != nil {
 		return result{}, err
 	}
-	return result{desc, data_sz, duration, reps}, nil
+	return result{"", desc, data_sz, duration, reps}, nil
 }
 
 func unicode() (r result, err error) {
@@ -146,7 +149,7 @@ func unicode() (r result, err error) {
 	if err != nil {
 		return result{}, err
 	}
-	return result{desc, data_sz, duration, reps}, nil
+	return result{"", desc, data_sz, duration, reps}, nil
 }
 
 func ascii_with_csi() (r result, err error) {
@@ -179,7 +182,7 @@ func ascii_with_csi() (r result, err error) {
 	if err != nil {
 		return result{}, err
 	}
-	return result{desc, data_sz, duration, reps}, nil
+	return result{"", desc, data_sz, duration, reps}, nil
 }
 
 func images() (r result, err error) {
@@ -204,7 +207,7 @@ func images() (r result, err error) {
 	if err != nil {
 		return result{}, err
 	}
-	return result{desc, data_sz, duration, reps}, nil
+	return result{"", desc, data_sz, duration, reps}, nil
 }
 
 func long_escape_codes() (r result, err error) {
@@ -216,7 +219,36 @@ func long_escape_codes() (r result, err error) {
 	if err != nil {
 		return result{}, err
 	}
-	return result{desc, data_sz, duration, reps}, nil
+	return result{"", desc, data_sz, duration, reps}, nil
+}
+
+func scrolling() (r result, err error) {
+	const sz = 1024*1024 + 17
+	b := strings.Builder{}
+	b.Grow(sz + 128)
+	// Move to the bottom of the screen so that every line feed scrolls
+	b.WriteString("\x1b[999H")
+	for b.Len() < sz {
+		q := rand.IntN(100)
+		switch {
+		case q < 70:
+			b.WriteString(random_string_of_bytes(rand.IntN(72)+1, ascii_printable) + "\r\n")
+		case 70 <= q && q < 80:
+			// scroll within a region
+			b.WriteString("\x1b[5;20r\x1b[20H\n\n\n\x1b[r\x1b[999H")
+		case 80 <= q && q < 90:
+			b.WriteString("\x1b[3S\x1b[2T")
+		case 90 <= q && q < 100:
+			// reverse index at the top of the screen
+			b.WriteString("\x1b[H\x1bM\x1bM\x1b[999H")
+		}
+	}
+	const desc = "Scrolling"
+	duration, data_sz, reps, err := benchmark_data(desc, b.String(), opts)
+	if err != nil {
+		return result{}, err
+	}
+	return result{"", desc, data_sz, duration, reps}, nil
 }
 
 var divs = []time.Duration{
@@ -235,24 +267,51 @@ func round(d time.Duration, digits int) time.Duration {
 }
 
 func present_result(r result, col_width int) {
-	rate := float64(r.data_sz) / r.duration.Seconds()
-	rate /= 1024. * 1024.
 	f := fmt.Sprintf("%%-%ds", col_width)
-	fmt.Printf("  "+f+" : %-10v @ \x1b[32m%-7.1f\x1b[m MB/s\n", r.desc, round(r.duration, 2), rate)
+	fmt.Printf("  "+f+" : %-10v @ \x1b[32m%-7.1f\x1b[m MB/s\n", r.desc, round(r.duration, 2), throughput(r))
 }
 
-func all_benchamrks() []string {
-	return []string{
-		"ascii", "unicode", "csi", "images", "long_escape_codes",
+func present_latency(l latency_stats, col_width int) {
+	f := fmt.Sprintf("%%-%ds", col_width)
+	fmt.Printf("  "+f+" : \x1b[32m%.2f\x1b[m ms median, %.2f ms min, %.2f ms p95, %.2f ms max over %d samples\n",
+		"Latency", l.Median, l.Minimum, l.Percentile95, l.Maximum, l.Samples)
+}
+
+type benchmark struct {
+	name string
+	run  func() (result, error)
+}
+
+func all_benchmarks() []benchmark {
+	return []benchmark{
+		{"ascii", simple_ascii}, {"unicode", unicode}, {"csi", ascii_with_csi}, {"long_escape_codes", long_escape_codes},
+		{"scrolling", scrolling}, {"images", images},
+	}
+}
+
+func all_benchmark_names() []string {
+	ans := []string{}
+	for _, b := range all_benchmarks() {
+		ans = append(ans, b.name)
 	}
+	return append(ans, "latency")
 }
 
 func main(args []string) (err error) {
 	if len(args) == 0 {
-		args = all_benchamrks()
+		args = all_benchmark_names()
+	}
+	for _, x := range args {
+		if slices.Index(all_benchmark_names(), x) < 0 {
+			return fmt.Errorf("Unknown benchmark: %s. Choose from: %s", x, strings.Join(all_benchmark_names(), ", "))
+		}
+	}
+	ti, err := identify_terminal()
+	if err != nil {
+		return err
 	}
 	var results []result
-	var r result
+	var latency *latency_stats
 	// First warm up the terminal by getting it to render all chars so that font rendering
 	// time is not polluting the benchmarks.
 	w := Options{Repetitions: 1}
@@ -261,41 +320,28 @@ func main(args []string) (err error) {
 	}
 	time.Sleep(time.Second / 2)
 
-	if slices.Index(args, "ascii") >= 0 {
-		if r, err = simple_ascii(); err != nil {
-			return err
-		}
-		results = append(results, r)
-	}
-
-	if slices.Index(args, "unicode") >= 0 {
-		if r, err = unicode(); err != nil {
-			return err
-		}
-		results = append(results, r)
-	}
-
-	if slices.Index(args, "csi") >= 0 {
-		if r, err = ascii_with_csi(); err != nil {
+	if slices.Index(args, "latency") >= 0 {
+		samples, err := measure_latency(opts.LatencySamples)
+		if err != nil {
 			return err
 		}
-		results = append(results, r)
+		l := summarize_latency(samples)
+		latency = &l
 	}
-
-	if slices.Index(args, "long_escape_codes") >= 0 {
-		if r, err = long_escape_codes(); err != nil {
-			return err
+	for _, b := range all_benchmarks() {
+		if slices.Index(args, b.name) >= 0 {
+			r, err := b.run()
+			if err != nil {
+				return err
+			}
+			r.name = b.name
+			results = append(results, r)
 		}
-		results = append(results, r)
 	}
 
-	if slices.Index(args, "images") >= 0 {
-		if r, err = images(); err != nil {
-			return err
-		}
-		results = append(results, r)
+	if opts.Format == "json" {
+		return new_report(ti, results, latency).write_json()
 	}
-
 	fmt.Print(reset)
 	fmt.Println(
 		"These results measure the time it takes the terminal to fully parse all the data sent to it.")
@@ -305,7 +351,7 @@ func main(args []string) (err error) {
 		fmt.Println("Note that \x1b[31mrendering is suppressed\x1b[m (if the terminal supports the synchronized output escape code) to better benchmark parser performance. Use the --render flag to enable rendering.")
 	}
 	fmt.Println()
-	fmt.Println("Results:")
+	fmt.Printf("Results for %s (%dx%d cells):\n", ti.Name, ti.Columns, ti.Lines)
 	mlen := 10
 	for _, r := range results {
 		mlen = max(mlen, len(r.desc))
@@ -313,21 +359,25 @@ func main(args []string) (err error) {
 	for _, r := range results {
 		present_result(r, mlen)
 	}
+	if latency != nil {
+		present_latency(*latency, mlen)
+	}
 	return
 }
 
-func EntryPoint(root *cli.Command) {
+func add_command(root *cli.Command, name string, hidden bool) {
 	sc := root.AddSubCommand(&cli.Command{
-		Name:             "__benchmark__",
-		ShortDescription: "Run various benchmarks",
-		HelpText:         "To run only particular benchmarks, specify them on the command line from the set: " + strings.Join(all_benchamrks(), ", ") + ". Benchmarking works by sending large amount of data to the TTY device and waiting for the terminal to process the data and respond to queries sent to it in the data. By default rendering is suppressed during benchmarking to focus on parser performance. Use the --render flag to enable it, but be aware that rendering in modern terminals is typically asynchronous so it wont be properly benchmarked by this kitten.",
+		Name:             name,
+		ShortDescription: "Benchmark the throughput and latency of the terminal",
+		HelpText:         "To run only particular benchmarks, specify them on the command line from the set: " + strings.Join(all_benchmark_names(), ", ") + ". Benchmarking works by sending large amount of data to the TTY device and waiting for the terminal to process the data and respond to queries sent to it in the data. The latency benchmark measures how long the terminal takes to respond to a query when it is otherwise idle. By default rendering is suppressed during benchmarking to focus on parser performance. Use the --render flag to enable it, but be aware that rendering in modern terminals is typically asynchronous so it wont be properly benchmarked by this kitten. Use :code:`--format=json` to get a report that can be saved and compared across terminals and versions.",
 		Usage:            "[options] [optional benchmark to run ...]",
-		Hidden:           true,
+		Hidden:           hidden,
 		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
 			if err = cmd.GetOptionValues(&opts); err != nil {
 				return 1, err
 			}
 			opts.Repetitions = max(1, opts.Repetitions)
+			opts.LatencySamples = max(1, opts.LatencySamples)
 			if err = main(args); err != nil {
 				ret = 1
 			}
@@ -350,5 +400,23 @@ func EntryPoint(root *cli.Command) {
 		Type: "bool-set",
 		Help: "Allow rendering of the data sent during tests. Note that modern terminals render asynchronously, so timings do not generally reflect render performance.",
 	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--latency-samples",
+		Default: "100",
+		Type:    "int",
+		Help:    "The number of queries to send to the terminal when measuring latency",
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--format",
+		Type:    "choices",
+		Choices: "text, json",
+		Default: "text",
+		Help:    "The format in which to output the results. The JSON format includes details about the terminal and system, suitable for comparing results across terminals and versions.",
+	})
+}
 
+func EntryPoint(root *cli.Command) {
+	add_command(root, "bench", false)
+	// The original name, kept for backwards compatibility
+	add_command(root, "__benchmark__", true)
 }
@@ -0,0 +1,180 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package benchmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"kitty"
+	"kitty/tools/tty"
+)
+
+var _ = fmt.Print
+
+const status_report_query = "\x1b[5n"
+const status_report_response = "\x1b[0n"
+
+// How long to wait for the terminal to respond to a query before giving up
+const query_timeout = 5 * time.Second
+
+// Send query followed by a status report request and return everything the
+// terminal sends back up to and including the status report response
+func query_terminal(term *tty.Term, query string) (response []byte, err error) {
+	if err = term.WriteAllString(query + status_report_query); err != nil {
+		return
+	}
+	q := []byte(status_report_response)
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(query_timeout)
+	for !bytes.Contains(response, q) {
+		n, err := term.ReadWithTimeout(buf, max(0, time.Until(deadline)))
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				err = fmt.Errorf("The terminal did not respond to the status report query within %v", query_timeout)
+			}
+			return response, err
+		}
+		response = append(response, buf[:n]...)
+	}
+	return
+}
+
+var xtversion_pat = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(`\x1bP>\|(.*?)\x1b\\`)
+})
+
+type terminal_info struct {
+	Name    string `json:"name"`
+	TERM    string `json:"term"`
+	Columns int    `json:"columns"`
+	Lines   int    `json:"lines"`
+}
+
+// Identify the terminal using the XTVERSION query, falling back to the
+// environment variables some terminals set when it is not supported
+func identify_terminal() (ans terminal_info, err error) {
+	term, err := tty.OpenControllingTerm(tty.SetRaw)
+	if err != nil {
+		return
+	}
+	defer term.RestoreAndClose()
+	ans.TERM = os.Getenv("TERM")
+	if sz, serr := term.GetSize(); serr == nil {
+		ans.Columns, ans.Lines = int(sz.Col), int(sz.Row)
+	}
+	response, err := query_terminal(term, "\x1b[>q")
+	if err != nil {
+		return
+	}
+	if m := xtversion_pat().FindSubmatch(response); m != nil {
+		ans.Name = string(m[1])
+	} else if tp := os.Getenv("TERM_PROGRAM"); tp != "" {
+		ans.Name = tp
+		if v := os.Getenv("TERM_PROGRAM_VERSION"); v != "" {
+			ans.Name += " " + v
+		}
+	} else {
+		ans.Name = ans.TERM
+	}
+	return
+}
+
+// Measure the round trip time for the terminal to respond to a status report
+// query while it is otherwise idle
+func measure_latency(samples int) (ans []time.Duration, err error) {
+	term, err := tty.OpenControllingTerm(tty.SetRaw)
+	if err != nil {
+		return
+	}
+	defer term.RestoreAndClose()
+	ans = make([]time.Duration, 0, samples)
+	for len(ans) < samples {
+		start := time.Now()
+		if _, err = query_terminal(term, ""); err != nil {
+			return nil, err
+		}
+		ans = append(ans, time.Since(start))
+	}
+	return
+}
+
+type latency_stats struct {
+	Samples      int     `json:"samples"`
+	Minimum      float64 `json:"min_ms"`
+	Median       float64 `json:"median_ms"`
+	Percentile95 float64 `json:"p95_ms"`
+	Maximum      float64 `json:"max_ms"`
+}
+
+func as_ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+func summarize_latency(samples []time.Duration) (ans latency_stats) {
+	if len(samples) == 0 {
+		return
+	}
+	s := slices.Clone(samples)
+	slices.Sort(s)
+	at := func(frac float64) time.Duration { return s[min(len(s)-1, int(frac*float64(len(s))))] }
+	ans.Samples = len(s)
+	ans.Minimum, ans.Maximum = as_ms(s[0]), as_ms(s[len(s)-1])
+	ans.Median, ans.Percentile95 = as_ms(at(0.5)), as_ms(at(0.95))
+	return
+}
+
+type report_result struct {
+	Name            string  `json:"name"`
+	Description     string  `json:"description"`
+	Bytes           int     `json:"bytes"`
+	Repetitions     int     `json:"repetitions"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Throughput      float64 `json:"throughput_mb_per_second"`
+}
+
+type report struct {
+	Terminal       terminal_info   `json:"terminal"`
+	KittenVersion  string          `json:"kitten_version"`
+	OS             string          `json:"os"`
+	Arch           string          `json:"arch"`
+	Timestamp      string          `json:"timestamp"`
+	Render         bool            `json:"render"`
+	WithScrollback bool            `json:"with_scrollback"`
+	Results        []report_result `json:"results"`
+	Latency        *latency_stats  `json:"latency,omitempty"`
+}
+
+func throughput(r result) float64 {
+	return float64(r.data_sz) / r.duration.Seconds() / (1024. * 1024.)
+}
+
+func new_report(ti terminal_info, results []result, latency *latency_stats) *report {
+	ans := report{
+		Terminal: ti, KittenVersion: kitty.VersionString, OS: runtime.GOOS, Arch: runtime.GOARCH,
+		Timestamp: time.Now().UTC().Format(time.RFC3339), Render: opts.Render, WithScrollback: opts.WithScrollback,
+		Results: make([]report_result, 0, len(results)), Latency: latency,
+	}
+	for _, r := range results {
+		ans.Results = append(ans.Results, report_result{
+			Name: r.name, Description: r.desc, Bytes: r.data_sz, Repetitions: r.repetitions,
+			DurationSeconds: r.duration.Seconds(), Throughput: throughput(r),
+		})
+	}
+	return &ans
+}
+
+func (self *report) write_json() error {
+	data, err := json.MarshalIndent(self, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
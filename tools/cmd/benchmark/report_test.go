@@ -0,0 +1,30 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package benchmark
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestLatencySummary(t *testing.T) {
+	samples := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	expected := latency_stats{Samples: 100, Minimum: 1, Median: 51, Percentile95: 96, Maximum: 100}
+	if diff := cmp.Diff(expected, summarize_latency(samples)); diff != "" {
+		t.Fatalf("Incorrect latency summary:\n%s", diff)
+	}
+	if samples[0] != 100*time.Millisecond {
+		t.Fatalf("Summarizing latency modified the samples")
+	}
+	if diff := cmp.Diff(latency_stats{}, summarize_latency(nil)); diff != "" {
+		t.Fatalf("Incorrect latency summary for no samples:\n%s", diff)
+	}
+}
@@ -0,0 +1,308 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"kitty"
+	"kitty/tools/config"
+	"kitty/tools/termcaps"
+	"kitty/tools/tui/shell_integration"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type status int
+
+const (
+	passed status = iota
+	warned
+	failed
+	skipped
+)
+
+type result struct {
+	title   string
+	status  status
+	message string
+	// Actionable instructions to fix the problem
+	fix string
+}
+
+type kitty_config struct {
+	path   string
+	exists bool
+	values map[string]string
+	errors []string
+}
+
+func (self *kitty_config) get(key, defval string) string {
+	if ans, found := self.values[key]; found {
+		return ans
+	}
+	return defval
+}
+
+var known_options = sync.OnceValue(func() *utils.Set[string] {
+	ans := utils.NewSet[string]()
+	for _, x := range utils.Splitlines(kitty.OptionNames) {
+		if x = strings.TrimSpace(x); x != "" {
+			ans.Add(x)
+		}
+	}
+	return ans
+})
+
+func load_kitty_config(paths []string) (ans *kitty_config) {
+	ans = &kitty_config{values: make(map[string]string)}
+	if len(paths) == 0 {
+		paths = []string{filepath.Join(utils.ConfigDirForName("kitty.conf"), "kitty.conf")}
+	}
+	ans.path = strings.Join(paths, ", ")
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			ans.exists = true
+		}
+	}
+	p := config.ConfigParser{LineHandler: func(key, val string) error {
+		if !known_options().Has(key) {
+			return fmt.Errorf("Unknown option: %s", key)
+		}
+		ans.values[key] = val
+		return nil
+	}}
+	for _, path := range paths {
+		if err := p.ParseFiles(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			ans.errors = append(ans.errors, fmt.Sprintf("%s: %s", path, err))
+		}
+	}
+	for _, bl := range p.BadLines() {
+		ans.errors = append(ans.errors, fmt.Sprintf("%s:%d: %s", bl.Src_file, bl.Line_number, bl.Err))
+	}
+	return
+}
+
+func in_kitty() bool { return os.Getenv("KITTY_WINDOW_ID") != "" }
+
+func check_config(cfg *kitty_config) result {
+	ans := result{title: "Configuration"}
+	switch {
+	case !cfg.exists:
+		ans.message = fmt.Sprintf("No config file found at %s, using the defaults", cfg.path)
+	case len(cfg.errors) > 0:
+		ans.status = failed
+		ans.message = "Errors found in the config:\n" + strings.Join(cfg.errors, "\n")
+		ans.fix = "Fix or remove the lines listed above. See " + kitty.WebsiteBaseURL + "conf/ for all available options." +
+			" Note that options added in versions of kitty newer than this kitten are reported as unknown."
+	default:
+		ans.message = "No errors found in " + cfg.path
+	}
+	return ans
+}
+
+func check_terminfo() result {
+	ans := result{title: "Terminfo"}
+	term := os.Getenv("TERM")
+	if term == "" {
+		ans.status = skipped
+		ans.message = "The TERM environment variable is not set"
+		return ans
+	}
+	if in_kitty() && term != "xterm-kitty" && os.Getenv("TMUX") == "" && !strings.HasPrefix(term, "screen") {
+		ans.status = warned
+		ans.message = fmt.Sprintf("TERM is %#v instead of \"xterm-kitty\" inside a kitty window", term)
+		ans.fix = "Something in your shell startup files is setting TERM, remove it. If you need a different value, set the term option in kitty.conf instead."
+		return ans
+	}
+	if path := shell_integration.PathToTerminfoDb(term); path != "" {
+		ans.message = fmt.Sprintf("The terminfo entry for %s was found at %s", term, path)
+		return ans
+	}
+	ans.status = failed
+	ans.message = fmt.Sprintf("No terminfo entry for %s was found, programs will not be able to use the terminal correctly", term)
+	if term == "xterm-kitty" {
+		ans.fix = "Run kitten doctor --install-terminfo to install it in ~/.terminfo. On remote machines, use kitten ssh to connect which installs it automatically."
+	} else {
+		ans.fix = fmt.Sprintf("Install the terminfo database for %s, usually provided by the ncurses package of your Linux distribution", term)
+	}
+	return ans
+}
+
+func install_terminfo() (string, error) {
+	dest := utils.Expanduser("~/.terminfo/x/xterm-kitty")
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+	if err := utils.AtomicWriteFile(dest, utils.UnsafeStringToBytes(shell_integration.TerminfoData()), 0o644); err != nil {
+		return "", err
+	}
+	// ncurses on macOS uses hex dirs
+	if err := os.MkdirAll(utils.Expanduser("~/.terminfo/78"), 0o755); err == nil {
+		_ = utils.AtomicCreateSymlink("../x/xterm-kitty", utils.Expanduser("~/.terminfo/78/xterm-kitty"))
+	}
+	return dest, nil
+}
+
+func check_shell_integration(cfg *kitty_config) result {
+	ans := result{title: "Shell integration"}
+	if !in_kitty() {
+		ans.status = skipped
+		ans.message = "Not running inside a kitty window"
+		return ans
+	}
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if shell == "" || shell == "." {
+		ans.status = skipped
+		ans.message = "The SHELL environment variable is not set"
+		return ans
+	}
+	if !shell_integration.IsSupportedShell(shell) {
+		ans.status = warned
+		ans.message = fmt.Sprintf("The shell %s is not supported by kitty shell integration", shell)
+		ans.fix = "Use one of the supported shells: bash, zsh or fish to get features such as jumping to previous prompts and opening new windows in the current directory"
+		return ans
+	}
+	setting := strings.Fields(cfg.get("shell_integration", "enabled"))
+	has := func(x string) bool {
+		for _, q := range setting {
+			if q == x {
+				return true
+			}
+		}
+		return false
+	}
+	if has("disabled") {
+		ans.status = warned
+		ans.message = "Shell integration is disabled in kitty.conf"
+		ans.fix = "Set shell_integration enabled in kitty.conf, or load the integration manually as described at " + kitty.WebsiteBaseURL + "shell-integration/#manual-shell-integration"
+		return ans
+	}
+	if ksi := os.Getenv("KITTY_SHELL_INTEGRATION"); ksi != "" {
+		// the integration scripts unset this variable once they are loaded
+		ans.status = failed
+		ans.message = fmt.Sprintf("Shell integration for %s was requested but has not been loaded", shell)
+		ans.fix = "Something in your shell startup files is preventing it from loading, for example, by exec-ing a different shell. Load the integration manually as described at " + kitty.WebsiteBaseURL + "shell-integration/#manual-shell-integration"
+		return ans
+	}
+	if has("no-rc") {
+		ans.status = warned
+		ans.message = "Automatic shell integration is turned off with no-rc in kitty.conf"
+		ans.fix = "Make sure your shell rc files load the integration manually as described at " + kitty.WebsiteBaseURL + "shell-integration/#manual-shell-integration"
+		return ans
+	}
+	ans.message = fmt.Sprintf("Enabled for %s", shell)
+	return ans
+}
+
+func check_remote_control(cfg *kitty_config) result {
+	ans := result{title: "Remote control"}
+	arc := cfg.get("allow_remote_control", "no")
+	enable_fix := "Add allow_remote_control socket-only and listen_on unix:/tmp/kitty to kitty.conf and restart kitty"
+	listen_on := os.Getenv("KITTY_LISTEN_ON")
+	if listen_on == "" {
+		if config.StringToBool(arc) || arc == "socket-only" || arc == "socket" || arc == "password" {
+			if !in_kitty() {
+				ans.status = skipped
+				ans.message = "Not running inside a kitty window and KITTY_LISTEN_ON is not set"
+				return ans
+			}
+			ans.status = warned
+			ans.message = "Remote control is allowed but kitty is not listening on a socket, kitten @ only works from a kitty window"
+			if arc == "socket-only" {
+				ans.status = failed
+				ans.message = "Remote control is only allowed over a socket, but kitty is not listening on one"
+			}
+			ans.fix = "Add listen_on unix:/tmp/kitty to kitty.conf and restart kitty to control it from other programs and scripts"
+			return ans
+		}
+		ans.status = warned
+		ans.message = "Remote control is not enabled"
+		ans.fix = enable_fix
+		return ans
+	}
+	network, addr, err := utils.ParseSocketAddress(listen_on)
+	if err != nil {
+		ans.status = failed
+		ans.message = fmt.Sprintf("KITTY_LISTEN_ON has an invalid value: %s", err)
+		return ans
+	}
+	conn, err := net.DialTimeout(network, addr, 2*time.Second)
+	if err != nil {
+		ans.status = failed
+		ans.message = fmt.Sprintf("Could not connect to the remote control socket at %s: %s", listen_on, err)
+		ans.fix = "The kitty instance that created this window may have exited, or the socket was deleted, for example, by a program that cleans /tmp. Try using an abstract socket such as listen_on unix:@mykitty on Linux."
+		return ans
+	}
+	conn.Close()
+	ans.message = "kitty is listening on " + listen_on
+	return ans
+}
+
+func check_ssh() result {
+	ans := result{title: "SSH kitten"}
+	ssh, err := exec.LookPath("ssh")
+	if err != nil {
+		ans.status = failed
+		ans.message = "The ssh program was not found in PATH"
+		ans.fix = "Install the OpenSSH client, usually provided by the openssh or openssh-client package"
+		return ans
+	}
+	// ssh -V prints its version to stderr
+	out, err := exec.Command(ssh, "-V").CombinedOutput()
+	version := strings.TrimSpace(string(out))
+	if err != nil {
+		ans.status = failed
+		ans.message = fmt.Sprintf("Running %s -V failed with error: %s %s", ssh, err, version)
+		return ans
+	}
+	if !strings.Contains(version, "OpenSSH") {
+		ans.status = warned
+		ans.message = fmt.Sprintf("%s is not OpenSSH: %s", ssh, version)
+		ans.fix = "The ssh kitten requires the OpenSSH client, install it and make sure it is first in PATH"
+		return ans
+	}
+	ans.message = fmt.Sprintf("Found %s: %s", ssh, version)
+	return ans
+}
+
+func check_terminal(caps *termcaps.Capabilities, err error) (ans []result) {
+	name := caps.Name()
+	if err != nil {
+		return append(ans, result{title: "Terminal", status: warned, message: fmt.Sprintf("Querying the terminal failed: %s", err),
+			fix: "The terminal did not respond to queries, if you are using a terminal multiplexer, try running kitten doctor outside it, or use --skip-terminal-query"})
+	}
+	t := result{title: "Terminal", message: "Running in " + name}
+	if caps.InMultiplexer() {
+		t.message += " inside " + strings.Join(caps.Multiplexers, " inside ")
+	}
+	ans = append(ans, t)
+	tmux_fix := ""
+	if slices.Contains(caps.Multiplexers, "tmux") {
+		tmux_fix = " When running inside tmux, it must be configured with: set -g allow-passthrough on"
+	}
+	g := result{title: "Graphics protocol", message: "Supported by " + name}
+	if !caps.Graphics {
+		g.status = warned
+		g.message = "Not supported by " + name
+		g.fix = "Images cannot be displayed by kittens such as icat, use a terminal that supports the kitty graphics protocol." + tmux_fix
+	}
+	k := result{title: "Keyboard protocol", message: fmt.Sprintf("Supported by %s, current flags: %d", name, caps.KeyboardFlags)}
+	if !caps.Keyboard {
+		k.status = warned
+		k.message = "Not supported by " + name
+		k.fix = "Some keyboard shortcuts will not work in programs that use the kitty keyboard protocol, use a terminal that supports it."
+	}
+	return append(ans, g, k)
+}
@@ -0,0 +1,39 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestDoctorConfig(t *testing.T) {
+	tdir := t.TempDir()
+	conf := filepath.Join(tdir, "kitty.conf")
+	if err := os.WriteFile(conf, []byte("font_size 12\nnot_an_option 1\ninclude other.conf\nbad\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tdir, "other.conf"), []byte("allow_remote_control socket-only\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := load_kitty_config([]string{conf})
+	if diff := cmp.Diff([]string{conf + `:2: Unknown option: not_an_option`, conf + `:4: Invalid config line: "bad"`}, cfg.errors); diff != "" {
+		t.Fatalf("Incorrect config errors:\n%s", diff)
+	}
+	if cfg.get("allow_remote_control", "no") != "socket-only" || cfg.get("font_size", "") != "12" || cfg.get("shell_integration", "enabled") != "enabled" {
+		t.Fatalf("Incorrect config values: %#v", cfg.values)
+	}
+	if r := check_config(cfg); r.status != failed {
+		t.Fatalf("Config with errors not reported as failed: %#v", r)
+	}
+	cfg = load_kitty_config([]string{filepath.Join(tdir, "missing.conf")})
+	if r := check_config(cfg); r.status != passed || cfg.exists || len(cfg.errors) > 0 {
+		t.Fatalf("Missing config not reported correctly: %#v", r)
+	}
+}
@@ -0,0 +1,110 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kitty"
+	"kitty/tools/cli"
+	"kitty/tools/termcaps"
+	"kitty/tools/tty"
+	"kitty/tools/utils/style"
+)
+
+var _ = fmt.Print
+
+type Options struct {
+	Config            []string
+	SkipTerminalQuery bool
+	InstallTerminfo   bool
+}
+
+func print_results(results []result) {
+	ctx := style.Context{AllowEscapeCodes: tty.IsTerminal(os.Stdout.Fd())}
+	title := ctx.SprintFunc("bold")
+	fix := ctx.SprintFunc("fg=cyan")
+	markers := map[status]string{
+		passed:  ctx.SprintFunc("fg=green")("✔"),
+		warned:  ctx.SprintFunc("fg=yellow")("!"),
+		failed:  ctx.SprintFunc("fg=red")("✘"),
+		skipped: ctx.SprintFunc("dim")("-"),
+	}
+	indent := func(text string) string { return strings.ReplaceAll(text, "\n", "\n    ") }
+	for _, r := range results {
+		fmt.Printf("%s %s: %s\n", markers[r.status], title(r.title), indent(r.message))
+		if r.fix != "" {
+			fmt.Printf("    %s %s\n", fix("Fix:"), indent(r.fix))
+		}
+	}
+}
+
+func doctor(opts *Options) (ret int, err error) {
+	if opts.InstallTerminfo {
+		dest, err := install_terminfo()
+		if err != nil {
+			return 1, fmt.Errorf("Failed to install the terminfo entry for xterm-kitty with error: %w", err)
+		}
+		fmt.Println("Installed the terminfo entry for xterm-kitty to:", dest)
+		return 0, nil
+	}
+	cfg := load_kitty_config(opts.Config)
+	results := []result{
+		{title: "kitten", message: fmt.Sprintf("%s (%s)", kitty.VersionString, os.Args[0])},
+		check_config(cfg), check_terminfo(), check_shell_integration(cfg), check_remote_control(cfg), check_ssh(),
+	}
+	if opts.SkipTerminalQuery || !tty.IsTerminal(os.Stdin.Fd()) || !tty.IsTerminal(os.Stdout.Fd()) {
+		results = append(results, result{title: "Terminal", status: skipped, message: "Not querying the terminal for supported protocols"})
+	} else {
+		results = append(results, check_terminal(termcaps.Query(2*time.Second))...)
+	}
+	print_results(results)
+	for _, r := range results {
+		if r.status == failed {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func EntryPoint(root *cli.Command) *cli.Command {
+	sc := root.AddSubCommand(&cli.Command{
+		Name:             "doctor",
+		Usage:            "[options]",
+		ShortDescription: "Diagnose common problems with kitty",
+		HelpText: "Check for common problems with the kitty setup on this machine and print instructions for fixing them." +
+			" Checks the kitty config for errors, the installation of the terminfo entry, shell integration," +
+			" that remote control is reachable, prerequisites for the ssh kitten and the protocols supported by the terminal." +
+			" Exits with a non-zero code if any check fails.",
+		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
+			if len(args) != 0 {
+				return 1, fmt.Errorf("No command line arguments are allowed")
+			}
+			opts := &Options{}
+			err = cmd.GetOptionValues(opts)
+			if err != nil {
+				return 1, err
+			}
+			return doctor(opts)
+		},
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--config -c",
+		Type: "list",
+		Help: "Path to the kitty config file to check. Can be specified multiple times. Defaults to the kitty.conf in the kitty config directory.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--skip-terminal-query",
+		Type: "bool-set",
+		Help: "Do not query the terminal for the protocols it supports. Useful when running in a terminal that does not respond to queries.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--install-terminfo",
+		Type: "bool-set",
+		Help: "Install the terminfo entry for xterm-kitty into :file:`~/.terminfo` instead of running the checks.",
+	})
+	return sc
+}
@@ -0,0 +1,71 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package generate_docs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"kitty/tools/cli"
+)
+
+var _ = fmt.Print
+
+type Options struct {
+	Format    string
+	OutputDir string
+}
+
+func generate_docs(root *cli.Command, opts *Options, args []string) (err error) {
+	if err = os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return root.GenerateDocs(opts.Format, opts.OutputDir, true)
+	}
+	for _, scname := range args {
+		sc := root.FindSubCommand(scname)
+		if sc == nil {
+			return fmt.Errorf("No sub command named: %s found", scname)
+		}
+		if err = sc.GenerateDocs(opts.Format, opts.OutputDir, true); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+func EntryPoint(root *cli.Command) *cli.Command {
+	sc := root.AddSubCommand(&cli.Command{
+		Name:             "generate-docs",
+		Usage:            "[options] [command ...]",
+		ShortDescription: "Generate documentation for the kitten commands",
+		HelpText: "Generate documentation, such as man pages, for all kitten commands and their sub-commands," +
+			" including the :code:`kitten @` remote control commands, from the same definitions used for the command line help." +
+			" One file is created per command. If the names of commands are specified, documentation is generated only for those commands" +
+			" and their sub-commands, for example: :code:`kitten generate-docs @ icat`.",
+		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
+			opts := &Options{}
+			if err = cmd.GetOptionValues(opts); err != nil {
+				return 1, err
+			}
+			if err = generate_docs(root, opts, args); err != nil {
+				return 1, err
+			}
+			return 0, nil
+		},
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--format",
+		Choices: strings.Join(cli.DocFormats, ", "),
+		Default: cli.DocFormats[0],
+		Help:    "The format of the generated documentation. :code:`man` generates man pages in section one.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--output-dir -o",
+		Default: ".",
+		Help:    "The directory in which to place the generated files. Created if it does not exist.",
+	})
+	return sc
+}
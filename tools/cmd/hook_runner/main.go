@@ -0,0 +1,37 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package hook_runner
+
+import (
+	"fmt"
+	"os"
+
+	"kitty/tools/cli"
+)
+
+var _ = fmt.Print
+
+func main(args []string) (rc int, err error) {
+	if len(args) != 1 {
+		return 1, fmt.Errorf("Usage: __hook_runner__ path/to/hooks.star")
+	}
+	r, err := new_runner(args[0], nil, os.Stderr)
+	if err != nil {
+		return 1, err
+	}
+	if err = r.process(os.Stdin); err != nil {
+		return 1, err
+	}
+	return
+}
+
+func EntryPoint(root *cli.Command) {
+	root.AddSubCommand(&cli.Command{
+		Name:            "__hook_runner__",
+		Hidden:          true,
+		OnlyArgsAllowed: true,
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			return main(args)
+		},
+	})
+}
@@ -0,0 +1,269 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package hook_runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	starlark_json "go.starlark.net/lib/json"
+	starlark_time "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"kitty/tools/rc"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// An event sent by kitty, one JSON object per line
+type event struct {
+	Name   string         `json:"event"`
+	Window map[string]any `json:"window"`
+	Data   map[string]any `json:"data"`
+}
+
+type runner struct {
+	path       string
+	stderr     io.Writer
+	thread     *starlark.Thread
+	globals    starlark.StringDict
+	client     *rc.Client
+	new_client func() (*rc.Client, error)
+	window_id  uint
+}
+
+func to_starlark(x any) (starlark.Value, error) {
+	switch v := x.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		f, err := v.Float64()
+		return starlark.Float(f), err
+	case []any:
+		ans := make([]starlark.Value, len(v))
+		for i, item := range v {
+			var err error
+			if ans[i], err = to_starlark(item); err != nil {
+				return nil, err
+			}
+		}
+		return starlark.NewList(ans), nil
+	case map[string]any:
+		ans := starlark.NewDict(len(v))
+		for key, item := range v {
+			sv, err := to_starlark(item)
+			if err != nil {
+				return nil, err
+			}
+			if err = ans.SetKey(starlark.String(key), sv); err != nil {
+				return nil, err
+			}
+		}
+		return ans, nil
+	}
+	return nil, fmt.Errorf("Cannot convert the value of type %T to starlark", x)
+}
+
+func from_starlark(x starlark.Value) (any, error) {
+	switch v := x.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		return nil, fmt.Errorf("The integer %s is too large", v)
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Indexable:
+		ans := make([]any, v.Len())
+		for i := range ans {
+			var err error
+			if ans[i], err = from_starlark(v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return ans, nil
+	case *starlark.Dict:
+		ans := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("Dictionary keys must be strings, not %s", item[0].Type())
+			}
+			val, err := from_starlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			ans[string(key)] = val
+		}
+		return ans, nil
+	}
+	return nil, fmt.Errorf("Cannot convert the value of type %s", x.Type())
+}
+
+// rc(cmd, **payload) runs the specified remote control command and returns
+// the data in its response, if any
+func (self *runner) rc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var cmd string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, nil, 1, &cmd); err != nil {
+		return nil, err
+	}
+	payload := make(map[string]any, len(kwargs))
+	for _, kw := range kwargs {
+		val, err := from_starlark(kw[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid value for %s: %w", b.Name(), kw[0], err)
+		}
+		payload[string(kw[0].(starlark.String))] = val
+	}
+	if self.client == nil {
+		c, err := self.new_client()
+		if err != nil {
+			return nil, fmt.Errorf("%s: remote control is not available: %w", b.Name(), err)
+		}
+		self.client = c
+	}
+	self.client.WindowId = self.window_id
+	r, err := self.client.Send(strings.ReplaceAll(cmd, "_", "-"), payload)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s failed: %w", b.Name(), cmd, err)
+	}
+	if r == nil || !r.Data.Present {
+		return starlark.None, nil
+	}
+	return starlark.String(r.String()), nil
+}
+
+// run(*argv) runs the specified program and returns its output
+func (self *runner) run_program(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(kwargs) > 0 {
+		return nil, fmt.Errorf("%s: unexpected keyword arguments", b.Name())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: no program specified", b.Name())
+	}
+	argv := make([]string, len(args))
+	for i, x := range args {
+		s, ok := starlark.AsString(x)
+		if !ok {
+			return nil, fmt.Errorf("%s: argument %d is a %s, not a string", b.Name(), i+1, x.Type())
+		}
+		argv[i] = s
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s failed with error: %w and STDERR: %s", b.Name(), argv[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return starlark.String(output), nil
+}
+
+func new_runner(path string, src any, stderr io.Writer) (*runner, error) {
+	ans := &runner{path: path, stderr: stderr, new_client: func() (*rc.Client, error) { return rc.NewClient("") }}
+	ans.thread = &starlark.Thread{Name: path, Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(ans.stderr, msg) }}
+	predeclared := starlark.StringDict{
+		"json": starlark_json.Module,
+		"time": starlark_time.Module,
+		"rc":   starlark.NewBuiltin("rc", ans.rc),
+		"run":  starlark.NewBuiltin("run", ans.run_program),
+		// globals are frozen after the file is loaded, so provide a mutable
+		// dict for hooks to keep state in between events
+		"state": starlark.NewDict(0),
+	}
+	opts := syntax.FileOptions{Set: true, While: true, TopLevelControl: true, GlobalReassign: true}
+	globals, err := starlark.ExecFileOptions(&opts, ans.thread, path, src, predeclared)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the hooks from %s with error: %s", path, format_error(err))
+	}
+	ans.globals = globals
+	return ans, nil
+}
+
+func format_error(err error) string {
+	var eerr *starlark.EvalError
+	if errors.As(err, &eerr) {
+		return eerr.Backtrace()
+	}
+	return err.Error()
+}
+
+// Call the function named after the event, ignoring events for which no
+// function is defined
+func (self *runner) dispatch(ev *event) error {
+	fn, ok := self.globals[ev.Name].(starlark.Callable)
+	if !ok {
+		return nil
+	}
+	window, err := to_starlark(ev.Window)
+	if err != nil {
+		return err
+	}
+	data, err := to_starlark(ev.Data)
+	if err != nil {
+		return err
+	}
+	self.window_id = 0
+	if id, ok := ev.Window["id"].(json.Number); ok {
+		if v, err := strconv.ParseUint(id.String(), 10, 0); err == nil {
+			self.window_id = uint(v)
+		}
+	}
+	if _, err = starlark.Call(self.thread, fn, starlark.Tuple{window, data}, nil); err != nil {
+		return fmt.Errorf("The %s hook in %s failed with error: %s", ev.Name, self.path, format_error(err))
+	}
+	return nil
+}
+
+// Read events from r until EOF, errors in individual events are reported
+// and do not stop processing
+func (self *runner) process(r io.Reader) error {
+	const max_event_size = 16 * 1024 * 1024
+	scanner := utils.NewRecordScanner(r, utils.RecordScannerOptions{MaxRecordSize: max_event_size, SkipTooLong: true})
+	num_skipped := 0
+	report_skipped := func() {
+		for ; num_skipped < scanner.NumSkipped(); num_skipped++ {
+			fmt.Fprintf(self.stderr, "Ignoring an event larger than %d bytes\n", max_event_size)
+		}
+	}
+	defer report_skipped()
+	for scanner.Scan() {
+		report_skipped()
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var ev event
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+		if err := d.Decode(&ev); err != nil {
+			fmt.Fprintf(self.stderr, "Ignoring invalid event: %s with error: %s\n", line, err)
+			continue
+		}
+		if err := self.dispatch(&ev); err != nil {
+			fmt.Fprintln(self.stderr, err)
+		}
+	}
+	return scanner.Err()
+}
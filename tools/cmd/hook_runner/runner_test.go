@@ -0,0 +1,107 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package hook_runner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"kitty/tools/rc"
+
+	"github.com/google/go-cmp/cmp"
+	"go.starlark.net/starlark"
+)
+
+var _ = fmt.Print
+
+const test_hooks = `
+def on_bell(window, data):
+    state["bells"] = state.get("bells", 0) + 1
+    print("bell", state["bells"], window["title"], window["user_vars"]["x"])
+
+def on_cmd_startstop(window, data):
+    if not data["is_start"]:
+        print("finished at", data["time"], json.encode(window["cmdline"]))
+
+def on_close(window, data):
+    rc("close_tab", match="id:%d" % window["id"])
+
+def on_create(window, data):
+    fail("failed for %d" % window["id"])
+`
+
+func TestHookRunner(t *testing.T) {
+	stderr := strings.Builder{}
+	r, err := new_runner("test.star", test_hooks, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.new_client = func() (*rc.Client, error) { return nil, fmt.Errorf("no socket") }
+	events := strings.Join([]string{
+		`{"event": "on_bell", "window": {"id": 1, "title": "one", "user_vars": {"x": "y"}}, "data": {}}`,
+		`{"event": "on_resize", "window": {"id": 1}, "data": {}}`,
+		``,
+		`{"event": "on_bell", "window": {"id": 2, "title": "two", "user_vars": {"x": "z"}}, "data": {}}`,
+		`{"event": "on_cmd_startstop", "window": {"id": 2, "cmdline": ["ls", "-l"]}, "data": {"is_start": false, "time": 1.5}}`,
+		`not json`,
+	}, "\n")
+	if err = r.process(strings.NewReader(events)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	expected := []string{"bell 1 one y", "bell 2 two z", `finished at 1.5 ["ls","-l"]`}
+	if diff := cmp.Diff(expected, lines[:3]); diff != "" {
+		t.Fatalf("Unexpected output from hooks:\n%s", diff)
+	}
+	if len(lines) != 4 || !strings.HasPrefix(lines[3], "Ignoring invalid event:") {
+		t.Fatalf("Invalid event not reported: %#v", lines[3:])
+	}
+	if r.window_id != 2 {
+		t.Fatalf("Incorrect window id: %d", r.window_id)
+	}
+
+	stderr.Reset()
+	if err = r.process(strings.NewReader(`{"event": "on_create", "window": {"id": 3}, "data": {}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if q := stderr.String(); !strings.Contains(q, "on_create hook in test.star failed") || !strings.Contains(q, "failed for 3") {
+		t.Fatalf("Failure in hook not reported: %s", q)
+	}
+	stderr.Reset()
+	if err = r.process(strings.NewReader(`{"event": "on_close", "window": {"id": 3}, "data": {}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if q := stderr.String(); !strings.Contains(q, "remote control is not available: no socket") {
+		t.Fatalf("Unavailable remote control not reported: %s", q)
+	}
+
+	if _, err = new_runner("bad.star", "def on_bell(:\n", &stderr); err == nil {
+		t.Fatalf("Invalid hooks file did not fail to load")
+	}
+}
+
+func TestHookRunnerConversions(t *testing.T) {
+	r, err := new_runner("test.star", "x = {'a': [1, 2.5, None, True, ('t',)], 'b': {'c': 'd'}}", &strings.Builder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := from_starlark(r.globals["x"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"a": []any{int64(1), 2.5, nil, true, []any{"t"}}, "b": map[string]any{"c": "d"}}
+	if diff := cmp.Diff(expected, val); diff != "" {
+		t.Fatalf("Incorrect conversion from starlark:\n%s", diff)
+	}
+	sv, err := to_starlark(map[string]any{"a": []any{"x", nil, true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := sv.String(); q != `{"a": ["x", None, True]}` {
+		t.Fatalf("Incorrect conversion to starlark: %s", q)
+	}
+	if _, err = from_starlark(starlark.NewBuiltin("f", nil)); err == nil {
+		t.Fatalf("Converting a module did not fail")
+	}
+}
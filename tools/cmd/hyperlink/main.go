@@ -0,0 +1,211 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package hyperlink
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/tui"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type Options struct {
+	Url         string
+	File        string
+	Line        int
+	Id          string
+	Passthrough string
+	NoNewline   bool
+}
+
+// Only printable ASCII is allowed in OSC 8 URLs, percent encode everything else
+func escape_url(u string) string {
+	var b strings.Builder
+	for i := 0; i < len(u); i++ {
+		if c := u[i]; c < 32 || c > 126 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// The parameters in OSC 8 are separated by : and terminated by ; so the id
+// cannot contain them
+func sanitize_id(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == ':' || r == ';' {
+			return '_'
+		}
+		return r
+	}, id)
+}
+
+// Remove control characters from the link text so that it cannot terminate
+// or otherwise interfere with the link
+func sanitize_text(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 || (r >= 0x80 && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// An id derived from the URL, so that all links to the same URL are treated
+// as a single link by the terminal
+func id_for_url(u string) string {
+	h := sha1.Sum(utils.UnsafeStringToBytes(u))
+	return hex.EncodeToString(h[:6])
+}
+
+func file_url(path string, line int) string {
+	path = utils.Abspath(utils.Expanduser(path))
+	u := url.URL{Scheme: "file", Host: utils.Hostname(), Path: path}
+	if line > 0 {
+		u.Fragment = strconv.Itoa(line)
+	}
+	return u.String()
+}
+
+func link_target(opts *Options) (string, error) {
+	switch {
+	case opts.Url != "" && opts.File != "":
+		return "", fmt.Errorf("Cannot specify both --url and --file")
+	case opts.Line > 0 && opts.File == "":
+		return "", fmt.Errorf("--line can only be used with --file")
+	case opts.File != "":
+		return file_url(opts.File, opts.Line), nil
+	case opts.Url != "":
+		purl, err := url.Parse(opts.Url)
+		if err != nil {
+			return "", fmt.Errorf("The URL: %s is invalid with error: %w", opts.Url, err)
+		}
+		if purl.Scheme == "" {
+			return "", fmt.Errorf("The URL: %s has no scheme, such as https://", opts.Url)
+		}
+		return opts.Url, nil
+	}
+	return "", fmt.Errorf("Must specify either --url or --file")
+}
+
+// The escape codes to start and end a link to u with the specified id
+func link_escape_codes(u, id string) (start, end string) {
+	params := ""
+	if id != "" {
+		params = "id=" + sanitize_id(id)
+	}
+	return "\x1b]8;" + params + ";" + escape_url(u) + "\x1b\\", "\x1b]8;;\x1b\\"
+}
+
+func hyperlink(opts *Options, text string) (string, error) {
+	u, err := link_target(opts)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		text = utils.IfElse(opts.File != "", opts.File, opts.Url)
+	}
+	id := opts.Id
+	switch id {
+	case "":
+		id = id_for_url(u)
+	case "none":
+		id = ""
+	}
+	start, end := link_escape_codes(u, id)
+	m := tui.NoMultiplexer
+	switch opts.Passthrough {
+	case "detect":
+		m = tui.DetectMultiplexer()
+	case "tmux":
+		m = tui.TmuxMultiplexer
+	}
+	if m != tui.NoMultiplexer {
+		if err = tui.EnsurePassthroughAllowed(m); err != nil {
+			return "", err
+		}
+		if m == tui.ScreenMultiplexer {
+			// screen cannot pass through ST terminated escape codes
+			start, end = strings.ReplaceAll(start, "\x1b\\", "\a"), strings.ReplaceAll(end, "\x1b\\", "\a")
+		}
+		if start, err = tui.WrapForPassthrough(m, start); err == nil {
+			end, err = tui.WrapForPassthrough(m, end)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return start + sanitize_text(text) + end, nil
+}
+
+func EntryPoint(root *cli.Command) *cli.Command {
+	sc := root.AddSubCommand(&cli.Command{
+		Name:             "hyperlink",
+		Usage:            "[options] [text ...]",
+		ShortDescription: "Print a hyperlink to a URL or file",
+		HelpText: "Print the specified text as a hyperlink, using the OSC 8 escape code, to either a URL or a file," +
+			" for use in shell scripts and prompts. If no text is specified, the URL or path is used as the text." +
+			" Links to files include the hostname, so that they work when opened from a remote computer in kitty," +
+			" see :doc:`/open_actions`.",
+		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
+			opts := &Options{}
+			if err = cmd.GetOptionValues(opts); err != nil {
+				return 1, err
+			}
+			text, err := hyperlink(opts, strings.Join(args, " "))
+			if err != nil {
+				return 1, err
+			}
+			if !opts.NoNewline {
+				text += "\n"
+			}
+			_, err = os.Stdout.WriteString(text)
+			return utils.IfElse(err == nil, 0, 1), err
+		},
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--url",
+		Help: "The URL to link to.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name:      "--file",
+		Help:      "The file to link to.",
+		Completer: cli.FnmatchCompleter("Files", cli.CWD, "*"),
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--line",
+		Type: "int",
+		Help: "The line number in the file to link to, added as the fragment of the URL, which can be used in :file:`open-actions.conf`.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--id",
+		Help: "The id of the link. Links with the same id and URL are treated as a single link by the terminal, for example," +
+			" when a link is split over multiple lines. By default, the id is derived from the URL, so all links to the" +
+			" same URL are treated as one link. Use :code:`none` to not set an id.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--passthrough",
+		Choices: "detect, tmux, none",
+		Default: "detect",
+		Help: "Whether to surround the escape codes with escape codes that allow them to passthrough programs like tmux." +
+			" The default is to detect when running inside tmux or GNU screen and use the appropriate passthrough escape codes." +
+			" Note that tmux 3.4 and newer support hyperlinks natively, so :code:`none` can be used with it to let tmux track the link.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--no-newline -n",
+		Type: "bool-set",
+		Help: "Do not print a trailing newline.",
+	})
+	return sc
+}
@@ -0,0 +1,36 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package hyperlink
+
+import (
+	"fmt"
+	"testing"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+func TestHyperlink(t *testing.T) {
+	q := func(opts Options, text, expected string) {
+		opts.Passthrough = "none"
+		actual, err := hyperlink(&opts, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("Incorrect hyperlink for %#v:\n%#v != %#v", opts, expected, actual)
+		}
+	}
+	u := "https://example.com/ü"
+	q(Options{Url: u, Id: "none"}, "", "\x1b]8;;https://example.com/%C3%BC\x1b\\"+u+"\x1b]8;;\x1b\\")
+	q(Options{Url: u, Id: "a;b:c"}, "te\x1bxt", "\x1b]8;id=a_b_c;https://example.com/%C3%BC\x1b\\text\x1b]8;;\x1b\\")
+	q(Options{Url: u}, "x", "\x1b]8;id="+id_for_url(u)+";https://example.com/%C3%BC\x1b\\x\x1b]8;;\x1b\\")
+	q(Options{File: "/a b", Line: 3, Id: "none"}, "x", "\x1b]8;;file://"+utils.Hostname()+"/a%20b#3\x1b\\x\x1b]8;;\x1b\\")
+
+	for _, opts := range []Options{{}, {Url: "x", File: "y"}, {Url: "x", Line: 1}, {Url: "no-scheme"}} {
+		if _, err := hyperlink(&opts, ""); err == nil {
+			t.Fatalf("No error for invalid options: %#v", opts)
+		}
+	}
+}
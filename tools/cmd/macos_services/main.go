@@ -0,0 +1,250 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package macos_services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"kitty/tools/cli"
+	"kitty/tools/tui"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type InstallOptions struct {
+	Force bool
+}
+
+func ensure_macos() error {
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("macOS services are only supported on macOS")
+	}
+	return nil
+}
+
+func kitten_exe() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("Failed to find the path to the kitten executable with error: %w", err)
+	}
+	if q, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = q
+	}
+	return exe, nil
+}
+
+// Tell macOS to re-scan the installed services so they show up in menus
+// without needing to log out
+func refresh_services_menu() {
+	_ = exec.Command("/System/Library/CoreServices/pbs", "-update").Run()
+}
+
+func install(opts *InstallOptions) (err error) {
+	if err = ensure_macos(); err != nil {
+		return
+	}
+	exe, err := kitten_exe()
+	if err != nil {
+		return err
+	}
+	dir := services_dir()
+	for _, s := range services {
+		path, err := s.install(dir, exe, opts.Force)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Installed:", path)
+	}
+	refresh_services_menu()
+	fmt.Println()
+	fmt.Println("The services are available in the Quick Actions and Services menus of Finder.")
+	fmt.Println("You can also use them in Shortcuts with the Open URLs action, for example:")
+	fmt.Println(" ", services[0].url([]string{"~"}))
+	return
+}
+
+func uninstall() (err error) {
+	if err = ensure_macos(); err != nil {
+		return
+	}
+	dir := services_dir()
+	for _, s := range services {
+		path := s.workflow_path(dir)
+		if _, serr := os.Stat(path); serr != nil {
+			continue
+		}
+		if err = os.RemoveAll(path); err != nil {
+			return fmt.Errorf("Failed to remove %s with error: %w", path, err)
+		}
+		fmt.Println("Removed:", path)
+	}
+	refresh_services_menu()
+	return
+}
+
+// Called by the installed workflows with the selected items, asks kitty to
+// perform the action via its URL scheme
+func open_url(args []string) (err error) {
+	if len(args) < 1 {
+		return fmt.Errorf("Must specify the service action")
+	}
+	s := service_for_action(args[0])
+	if s == nil {
+		return fmt.Errorf("Unknown service action: %s", args[0])
+	}
+	if err = s.check_items(args[1:]); err != nil {
+		return
+	}
+	if err = ensure_macos(); err != nil {
+		return
+	}
+	u := s.url(args[1:])
+	if out, err := exec.Command("open", u).CombinedOutput(); err != nil {
+		return fmt.Errorf("Failed to open %s with error: %w and output: %s", u, err, strings.TrimSpace(string(out)))
+	}
+	return
+}
+
+func prompt(reader *bufio.Reader, msg string) (string, error) {
+	fmt.Print(msg)
+	ans, err := reader.ReadString('\n')
+	if err != nil && ans == "" {
+		// stdin was closed
+		return "", tui.Canceled
+	}
+	return strings.TrimSpace(ans), nil
+}
+
+func exec_kitten(args ...string) error {
+	exe, err := kitten_exe()
+	if err != nil {
+		return err
+	}
+	argv := append([]string{"kitten"}, args...)
+	return unix.Exec(exe, argv, os.Environ())
+}
+
+// Called by kitty, in a new OS window, to perform the action requested via
+// the URL
+func handle_url(args []string) (err error) {
+	if len(args) != 1 {
+		return fmt.Errorf("Must specify exactly one URL")
+	}
+	s, paths, err := parse_url(args[0])
+	if err != nil {
+		return err
+	}
+	switch s.action {
+	case "open":
+		cwd := paths[0]
+		if st, err := os.Stat(cwd); err != nil || !st.IsDir() {
+			cwd = filepath.Dir(cwd)
+		}
+		return tui.RunShell(tui.ResolveShell(""), tui.ResolveShellIntegration(""), cwd)
+	case "diff":
+		return exec_kitten("diff", paths[0], paths[1])
+	case "transfer":
+		r := bufio.NewReader(os.Stdin)
+		fmt.Println("Transferring:", strings.Join(utils.Map(filepath.Base, paths), ", "))
+		host, err := prompt(r, "Host to transfer to: ")
+		if err != nil {
+			return err
+		}
+		if host == "" {
+			return tui.Canceled
+		}
+		dest, err := prompt(r, "Destination on the host (defaults to the home directory): ")
+		if err != nil {
+			return err
+		}
+		if dest == "" {
+			dest = "~"
+		}
+		targs := append([]string{"ssh", host, "kitten", "transfer", "--direction=upload"}, paths...)
+		return exec_kitten(append(targs, dest)...)
+	}
+	return
+}
+
+func EntryPoint(root *cli.Command) {
+	parent := root.AddSubCommand(&cli.Command{
+		Name:             "macos-services",
+		Usage:            "install|uninstall",
+		ShortDescription: "Integrate kitty with the Services menu and Shortcuts on macOS",
+		HelpText: "Install Quick Actions for Finder that open the selected items in kitty, diff them" +
+			" with the diff kitten or transfer them to a remote computer with the transfer kitten, over SSH." +
+			" The actions are performed by kitty via its URL scheme, so they can also be used from Shortcuts with" +
+			" the Open URLs action. See :ref:`macos_services` for details.",
+	})
+	sc := parent.AddSubCommand(&cli.Command{
+		Name:             "install",
+		Usage:            "[options]",
+		ShortDescription: "Install the kitty services into ~/Library/Services",
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			if len(args) != 0 {
+				return 1, fmt.Errorf("No command line arguments are allowed")
+			}
+			opts := &InstallOptions{}
+			if err = cmd.GetOptionValues(opts); err != nil {
+				return 1, err
+			}
+			if err = install(opts); err != nil {
+				return 1, err
+			}
+			return
+		},
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--force",
+		Type: "bool-set",
+		Help: "Overwrite previously installed services, useful after kitty has moved to a different location.",
+	})
+	parent.AddSubCommand(&cli.Command{
+		Name:             "uninstall",
+		ShortDescription: "Remove the installed kitty services",
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			if len(args) != 0 {
+				return 1, fmt.Errorf("No command line arguments are allowed")
+			}
+			if err = uninstall(); err != nil {
+				return 1, err
+			}
+			return
+		},
+	})
+	parent.AddSubCommand(&cli.Command{
+		Name:            "open-url",
+		Hidden:          true,
+		OnlyArgsAllowed: true,
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			if err = open_url(args); err != nil {
+				return 1, err
+			}
+			return
+		},
+	})
+	parent.AddSubCommand(&cli.Command{
+		Name:            "handle-url",
+		Hidden:          true,
+		OnlyArgsAllowed: true,
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			if err = handle_url(args); err != nil {
+				if errors.Is(err, tui.Canceled) {
+					return 1, nil
+				}
+				return 1, err
+			}
+			return
+		},
+	})
+}
@@ -0,0 +1,180 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package macos_services
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"howett.net/plist"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const url_prefix = "kitty:///launch/services/"
+
+type service struct {
+	name, action string
+	// the minimum and maximum number of selected items, zero for no limit
+	min_items, max_items int
+}
+
+var services = []service{
+	{name: "Open in kitty", action: "open", min_items: 1},
+	{name: "Diff with kitty", action: "diff", min_items: 2, max_items: 2},
+	{name: "Transfer to host with kitty", action: "transfer", min_items: 1},
+}
+
+func service_for_action(action string) *service {
+	for i, s := range services {
+		if s.action == action {
+			return &services[i]
+		}
+	}
+	return nil
+}
+
+func (self *service) check_items(items []string) error {
+	if len(items) < self.min_items || (self.max_items > 0 && len(items) > self.max_items) {
+		switch {
+		case self.max_items == self.min_items:
+			return fmt.Errorf("%s needs exactly %d items, %d were selected", self.name, self.min_items, len(items))
+		default:
+			return fmt.Errorf("%s needs at least %d items, %d were selected", self.name, self.min_items, len(items))
+		}
+	}
+	return nil
+}
+
+// The kitty URL that is opened by kitty to perform the action on the
+// specified paths
+func (self *service) url(paths []string) string {
+	q := url.Values{}
+	for _, p := range paths {
+		q.Add("path", utils.Abspath(p))
+	}
+	return url_prefix + self.action + "?" + q.Encode()
+}
+
+func parse_url(raw string) (s *service, paths []string, err error) {
+	if !strings.HasPrefix(raw, url_prefix) {
+		return nil, nil, fmt.Errorf("Not a kitty services URL: %s", raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid URL: %s with error: %w", raw, err)
+	}
+	action := strings.TrimPrefix(u.Path, "/launch/services/")
+	if s = service_for_action(action); s == nil {
+		return nil, nil, fmt.Errorf("Unknown service action: %s", action)
+	}
+	paths = u.Query()["path"]
+	if err = s.check_items(paths); err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+func services_dir() string {
+	return utils.Expanduser("~/Library/Services")
+}
+
+func (self *service) workflow_path(dir string) string {
+	return filepath.Join(dir, self.name+".workflow")
+}
+
+func (self *service) info_plist() map[string]any {
+	return map[string]any{
+		"NSServices": []any{map[string]any{
+			"NSMenuItem":      map[string]any{"default": self.name},
+			"NSMessage":       "runWorkflowAsService",
+			"NSSendFileTypes": []string{"public.item"},
+		}},
+	}
+}
+
+// The Automator workflow, consisting of a single Run Shell Script action that
+// passes the selected items as arguments to the kitten
+func (self *service) document(kitten_exe string) map[string]any {
+	id := func(which string) string {
+		return strings.ToUpper(uuid.NewSHA1(uuid.NameSpaceURL, []byte(url_prefix+self.action+"#"+which)).String())
+	}
+	cmd := fmt.Sprintf(`%s macos-services open-url %s "$@"`, utils.QuoteStringForSH(kitten_exe), self.action)
+	action := map[string]any{
+		"AMAccepts": map[string]any{
+			"Container": "List", "Optional": true, "Types": []string{"com.apple.cocoa.string"}},
+		"AMActionVersion": "2.0.3",
+		"AMApplication":   []string{"Automator"},
+		"AMParameterProperties": map[string]any{
+			"COMMAND_STRING": map[string]any{}, "CheckedForUserDefaultShell": map[string]any{},
+			"inputMethod": map[string]any{}, "shell": map[string]any{}, "source": map[string]any{},
+		},
+		"AMProvides":       map[string]any{"Container": "List", "Types": []string{"com.apple.cocoa.string"}},
+		"ActionBundlePath": "/System/Library/Automator/Run Shell Script.action",
+		"ActionName":       "Run Shell Script",
+		"ActionParameters": map[string]any{
+			"COMMAND_STRING": cmd, "CheckedForUserDefaultShell": true,
+			// 1 means pass input as arguments rather than on stdin
+			"inputMethod": 1, "shell": "/bin/sh", "source": "",
+		},
+		"BundleIdentifier":            "com.apple.RunShellScript",
+		"CFBundleVersion":             "2.0.3",
+		"CanShowSelectedItemsWhenRun": false,
+		"CanShowWhenRun":              true,
+		"Category":                    []string{"AMCategoryUtilities"},
+		"Class Name":                  "RunShellScriptAction",
+		"InputUUID":                   id("input"),
+		"Keywords":                    []string{"Shell", "Script", "Command", "Run", "Unix"},
+		"OutputUUID":                  id("output"),
+		"UUID":                        id("action"),
+		"UnlocalizedApplications":     []string{"Automator"},
+		"arguments":                   map[string]any{},
+		"isViewVisible":               1,
+	}
+	return map[string]any{
+		"AMApplicationBuild":   "523",
+		"AMApplicationVersion": "2.10",
+		"AMDocumentVersion":    "2",
+		"actions":              []any{map[string]any{"action": action, "isViewVisible": 1}},
+		"connectors":           map[string]any{},
+		"workflowMetaData": map[string]any{
+			"serviceInputTypeIdentifier":  "com.apple.Automator.fileSystemObject",
+			"serviceOutputTypeIdentifier": "com.apple.Automator.nothing",
+			"serviceProcessesInput":       0,
+			"workflowTypeIdentifier":      "com.apple.Automator.servicesMenu",
+		},
+	}
+}
+
+// Write the workflow bundle for this service into dir, returning its path
+func (self *service) install(dir, kitten_exe string, force bool) (path string, err error) {
+	path = self.workflow_path(dir)
+	if _, err = os.Stat(path); err == nil {
+		if !force {
+			return path, fmt.Errorf("The service %s already exists, use --force to overwrite it", path)
+		}
+		if err = os.RemoveAll(path); err != nil {
+			return
+		}
+	}
+	contents := filepath.Join(path, "Contents")
+	if err = os.MkdirAll(contents, 0o755); err != nil {
+		return
+	}
+	for name, data := range map[string]any{"Info.plist": self.info_plist(), "document.wflow": self.document(kitten_exe)} {
+		raw, err := plist.MarshalIndent(data, plist.XMLFormat, "\t")
+		if err != nil {
+			return path, fmt.Errorf("Failed to serialize %s with error: %w", name, err)
+		}
+		if err = utils.AtomicWriteFile(filepath.Join(contents, name), raw, 0o644); err != nil {
+			return path, err
+		}
+	}
+	return
+}
@@ -0,0 +1,75 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package macos_services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"howett.net/plist"
+)
+
+var _ = fmt.Print
+
+func TestServiceURLs(t *testing.T) {
+	paths := []string{"/some/file with spaces", "/other/f&ile?#"}
+	s := service_for_action("diff")
+	u := s.url(paths)
+	as, apaths, err := parse_url(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as != s {
+		t.Fatalf("Incorrect service parsed from %s: %s", u, as.action)
+	}
+	if diff := cmp.Diff(paths, apaths); diff != "" {
+		t.Fatalf("Incorrect paths parsed from %s:\n%s", u, diff)
+	}
+	for _, bad := range []string{
+		"kitty:///launch/services/nosuch?path=/a",
+		"kitty:///launch/services/diff?path=/a",
+		"kitty:///launch/services/open",
+		"file:///launch/services/open?path=/a",
+	} {
+		if _, _, err := parse_url(bad); err == nil {
+			t.Fatalf("No error parsing invalid URL: %s", bad)
+		}
+	}
+}
+
+func TestServiceInstall(t *testing.T) {
+	tdir := t.TempDir()
+	s := service_for_action("open")
+	path, err := s.install(tdir, "/path/to/it's/kitten", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.install(tdir, "/x", false); err == nil {
+		t.Fatalf("Installing over an existing service did not fail")
+	}
+	if _, err = s.install(tdir, "/path/to/it's/kitten", true); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) (ans map[string]any) {
+		raw, err := os.ReadFile(filepath.Join(path, "Contents", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = plist.Unmarshal(raw, &ans); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	svc := read("Info.plist")["NSServices"].([]any)[0].(map[string]any)
+	if diff := cmp.Diff(map[string]any{"default": "Open in kitty"}, svc["NSMenuItem"]); diff != "" {
+		t.Fatalf("Incorrect menu item:\n%s", diff)
+	}
+	doc := read("document.wflow")
+	params := doc["actions"].([]any)[0].(map[string]any)["action"].(map[string]any)["ActionParameters"].(map[string]any)
+	if diff := cmp.Diff(`'/path/to/it'"'"'s/kitten' macos-services open-url open "$@"`, params["COMMAND_STRING"]); diff != "" {
+		t.Fatalf("Incorrect command:\n%s", diff)
+	}
+}
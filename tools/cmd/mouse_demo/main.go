@@ -140,7 +140,8 @@ func Run(args []string) (rc int, err error) {
 	}
 
 	lp.OnMouseEvent = func(ev *loop.MouseEvent) error {
-		current_mouse_event = ev
+		e := *ev
+		current_mouse_event = &e
 		draw_screen()
 		return nil
 	}
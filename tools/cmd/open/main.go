@@ -0,0 +1,246 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package open
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"kitty/tools/cli"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type Options struct {
+	Transfer   string
+	DownloadTo string
+	PrintUrl   bool
+}
+
+var scheme_pat = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+})
+
+// Convert a command line argument into a URL, arguments that are not URLs
+// are treated as paths to files
+func url_for_target(target string) (string, error) {
+	if _, err := os.Stat(target); err != nil && scheme_pat().MatchString(target) {
+		if _, err := url.Parse(target); err != nil {
+			return "", fmt.Errorf("The URL: %s is invalid with error: %w", target, err)
+		}
+		return target, nil
+	}
+	path := utils.Abspath(utils.Expanduser(target))
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("The file: %s does not exist", target)
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	return u.String(), nil
+}
+
+func file_path(u string) string {
+	if purl, err := url.Parse(u); err == nil && purl.Scheme == "file" {
+		return purl.Path
+	}
+	return ""
+}
+
+func in_kitty() bool { return os.Getenv("KITTY_WINDOW_ID") != "" }
+
+// Whether kitty is running on a different computer, in which case files have
+// to be transferred to it before they can be opened
+func is_remote(opts *Options) bool {
+	switch opts.Transfer {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != ""
+}
+
+func run_kitten(args ...string) ([]byte, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+type ls_window struct {
+	Id  int               `json:"id"`
+	Env map[string]string `json:"env"`
+}
+
+type ls_os_window struct {
+	Tabs []struct {
+		Windows []ls_window `json:"windows"`
+	} `json:"tabs"`
+}
+
+// The home directory on the computer running kitty, from the environment of
+// the window this kitten is running in
+var local_home = sync.OnceValues(func() (string, error) {
+	raw, err := run_kitten("@", "ls", "--self", "--all-env-vars")
+	if err != nil {
+		return "", fmt.Errorf("Failed to query kitty using remote control with error: %w", err)
+	}
+	var ls []ls_os_window
+	if err = json.Unmarshal(raw, &ls); err != nil {
+		return "", fmt.Errorf("Could not parse the output from kitten @ ls with error: %w", err)
+	}
+	wid, _ := strconv.Atoi(os.Getenv("KITTY_WINDOW_ID"))
+	for _, osw := range ls {
+		for _, tab := range osw.Tabs {
+			for _, w := range tab.Windows {
+				if w.Id == wid && w.Env["HOME"] != "" {
+					return w.Env["HOME"], nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("Could not find the home directory of the computer running kitty")
+})
+
+// Copy the file to the computer running kitty with the transfer kitten,
+// returning the URL for the copy
+func transfer_to_local(opts *Options, path string) (string, error) {
+	dest := opts.DownloadTo
+	if dest == "~" || strings.HasPrefix(dest, "~/") {
+		home, err := local_home()
+		if err != nil {
+			return "", err
+		}
+		dest = home + dest[1:]
+	}
+	if !filepath.IsAbs(dest) {
+		return "", fmt.Errorf("The download location must be an absolute path, not: %s", opts.DownloadTo)
+	}
+	dest = filepath.Join(dest, filepath.Base(path))
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(exe, "transfer", "--direction=download", path, dest)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		return "", fmt.Errorf("Failed to transfer %s to the computer running kitty with error: %w", path, err)
+	}
+	u := url.URL{Scheme: "file", Path: dest}
+	return u.String(), nil
+}
+
+// Open the URL in the computer running kitty, exactly as if it had been
+// clicked, so that open-actions.conf and open_url_with in kitty.conf apply
+func open_in_kitty(u string) error {
+	if _, err := run_kitten("@", "action", "--self", "open_url", u); err != nil {
+		return fmt.Errorf("Failed to ask kitty to open %s with error: %w", u, err)
+	}
+	return nil
+}
+
+func system_open_command() []string {
+	if runtime.GOOS == "darwin" {
+		return []string{"open"}
+	}
+	return []string{"xdg-open"}
+}
+
+func open_with_system(u string) error {
+	argv := append(system_open_command(), u)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Failed to open %s with %s with error: %w", u, argv[0], err)
+	}
+	return nil
+}
+
+func open_target(opts *Options, target string) (err error) {
+	u, err := url_for_target(target)
+	if err != nil {
+		return err
+	}
+	if !in_kitty() {
+		if opts.PrintUrl {
+			fmt.Println(u)
+			return
+		}
+		return open_with_system(u)
+	}
+	remote := is_remote(opts)
+	if path := file_path(u); path != "" && remote {
+		if u, err = transfer_to_local(opts, path); err != nil {
+			return err
+		}
+	}
+	if opts.PrintUrl {
+		fmt.Println(u)
+		return
+	}
+	if err = open_in_kitty(u); err != nil && !remote {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "Falling back to", system_open_command()[0])
+		err = open_with_system(u)
+	}
+	return
+}
+
+func EntryPoint(root *cli.Command) *cli.Command {
+	sc := root.AddSubCommand(&cli.Command{
+		Name:             "open",
+		Usage:            "[options] URL or path ...",
+		ShortDescription: "Open URLs and files the same way kitty does when they are clicked",
+		HelpText: "Open the specified URLs or files, using the rules in :file:`open-actions.conf` that kitty uses" +
+			" when a hyperlink or a URL selected by the hints kitten is clicked, see :doc:`/open_actions`." +
+			" When no rule matches, kitty uses :opt:`open_url_with` which defaults to the system" +
+			" handler (:program:`xdg-open` or :program:`open`). When not running inside kitty, the system handler is used directly." +
+			" When running on a remote computer over SSH, files are first copied to the computer running kitty" +
+			" using the :doc:`transfer kitten </kittens/transfer>`, so that they can be opened there.",
+		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
+			if len(args) == 0 {
+				return 1, fmt.Errorf("Must specify at least one URL or path to open")
+			}
+			opts := &Options{}
+			if err = cmd.GetOptionValues(opts); err != nil {
+				return 1, err
+			}
+			for _, arg := range args {
+				if err = open_target(opts, arg); err != nil {
+					return 1, err
+				}
+			}
+			return 0, nil
+		},
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--transfer",
+		Choices: "auto, always, never",
+		Default: "auto",
+		Help: "Whether to copy files to the computer running kitty before opening them. With :code:`auto` files are" +
+			" copied when running in an SSH session.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--download-to",
+		Default: "~/Downloads",
+		Help:    "The folder on the computer running kitty into which files are copied, when they need to be copied.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--print-url",
+		Type: "bool-set",
+		Help: "Print the URL that would be opened instead of opening it. Useful for scripting. Note that files are still copied, if needed.",
+	})
+	return sc
+}
@@ -0,0 +1,46 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package open
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestOpenURLForTarget(t *testing.T) {
+	tdir := t.TempDir()
+	cwd, _ := os.Getwd()
+	defer func() { _ = os.Chdir(cwd) }()
+	if err := os.Chdir(tdir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a b#c.txt", "mailto:x"} {
+		if err := os.WriteFile(filepath.Join(tdir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for target, expected := range map[string]string{
+		"https://kitty.org/a?b": "https://kitty.org/a?b",
+		"mailto:x":              "file://" + filepath.Join(tdir, "mailto:x"),
+		"mailto:y":              "mailto:y",
+		"a b#c.txt":             "file://" + tdir + "/a%20b%23c.txt",
+	} {
+		actual, err := url_for_target(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("Incorrect URL for %#v: %#v != %#v", target, expected, actual)
+		}
+		if fp := file_path(actual); expected[:5] == "file:" && fp != filepath.Join(tdir, target) {
+			t.Fatalf("Incorrect path for %#v: %#v", actual, fp)
+		}
+	}
+	if _, err := url_for_target("does-not-exist"); err == nil {
+		t.Fatalf("No error for non-existent file")
+	}
+}
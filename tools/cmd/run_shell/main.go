@@ -12,6 +12,7 @@ import (
 	"kitty/tools/tty"
 	"kitty/tools/tui"
 	"kitty/tools/tui/shell_integration"
+	"kitty/tools/utils"
 )
 
 var _ = fmt.Print
@@ -42,6 +43,11 @@ func main(args []string, opts *Options) (rc int, err error) {
 		}
 		changed = true
 	}
+	if changed {
+		// the paths used to find shell integration and terminfo files can
+		// depend on the changed env vars, such as XDG_CONFIG_HOME
+		utils.ResetPaths()
+	}
 	if os.Getenv("TERM") == "" {
 		os.Setenv("TERM", kitty.DefaultTermName)
 	}
@@ -62,6 +68,7 @@ func main(args []string, opts *Options) (rc int, err error) {
 			k, v, _ := strings.Cut(entry, "=")
 			os.Setenv(k, v)
 		}
+		utils.ResetPaths()
 	}
 	if err != nil {
 		rc = 1
@@ -6,24 +6,44 @@ import (
 	"fmt"
 
 	"kitty/kittens/ask"
+	"kitty/kittens/banner"
 	"kitty/kittens/clipboard"
+	"kitty/kittens/clipboard_bridge"
+	"kitty/kittens/color"
 	"kitty/kittens/diff"
+	"kitty/kittens/help"
 	"kitty/kittens/hints"
 	"kitty/kittens/hyperlinked_grep"
 	"kitty/kittens/icat"
+	"kitty/kittens/md"
+	"kitty/kittens/notify"
+	"kitty/kittens/play"
+	"kitty/kittens/plot"
+	"kitty/kittens/qr"
+	"kitty/kittens/record"
 	"kitty/kittens/show_key"
+	"kitty/kittens/snippets"
 	"kitty/kittens/ssh"
+	"kitty/kittens/switch"
 	"kitty/kittens/themes"
 	"kitty/kittens/transfer"
 	"kitty/kittens/unicode_input"
+	"kitty/kittens/watch"
 	"kitty/tools/cli"
 	"kitty/tools/cmd/at"
 	"kitty/tools/cmd/benchmark"
+	"kitty/tools/cmd/doctor"
 	"kitty/tools/cmd/edit_in_kitty"
+	"kitty/tools/cmd/generate_docs"
+	"kitty/tools/cmd/hook_runner"
+	"kitty/tools/cmd/hyperlink"
+	"kitty/tools/cmd/macos_services"
 	"kitty/tools/cmd/mouse_demo"
+	"kitty/tools/cmd/open"
 	"kitty/tools/cmd/pytest"
 	"kitty/tools/cmd/run_shell"
 	"kitty/tools/cmd/show_error"
+	"kitty/tools/cmd/update_check"
 	"kitty/tools/cmd/update_self"
 	"kitty/tools/tui"
 )
@@ -38,6 +58,16 @@ func KittyToolEntryPoints(root *cli.Command) {
 	at.EntryPoint(root)
 	// update-self
 	update_self.EntryPoint(root)
+	// update-check
+	update_check.EntryPoint(root)
+	// doctor
+	doctor.EntryPoint(root)
+	// macos-services
+	macos_services.EntryPoint(root)
+	// open
+	open.EntryPoint(root)
+	// hyperlink
+	hyperlink.EntryPoint(root)
 	// edit-in-kitty
 	edit_in_kitty.EntryPoint(root)
 	// clipboard
@@ -48,6 +78,7 @@ func KittyToolEntryPoints(root *cli.Command) {
 	ssh.EntryPoint(root)
 	// transfer
 	transfer.EntryPoint(root)
+	transfer.ElevatedInstallEntryPoint(root)
 	// unicode_input
 	unicode_input.EntryPoint(root)
 	// show_key
@@ -69,6 +100,32 @@ func KittyToolEntryPoints(root *cli.Command) {
 	hints.EntryPoint(root)
 	// hints
 	diff.EntryPoint(root)
+	// md
+	md.EntryPoint(root)
+	// qr
+	qr.EntryPoint(root)
+	// banner
+	banner.EntryPoint(root)
+	// color
+	color.EntryPoint(root)
+	// switch
+	switch_kitten.EntryPoint(root)
+	// snippets
+	snippets.EntryPoint(root)
+	// watch
+	watch.EntryPoint(root)
+	// plot
+	plot.EntryPoint(root)
+	// help
+	help.EntryPoint(root)
+	// clipboard_bridge
+	clipboard_bridge.EntryPoint(root)
+	// play
+	play.EntryPoint(root)
+	// record
+	record.EntryPoint(root)
+	// notify
+	notify.EntryPoint(root)
 	// themes
 	themes.EntryPoint(root)
 	themes.ParseEntryPoint(root)
@@ -78,6 +135,8 @@ func KittyToolEntryPoints(root *cli.Command) {
 	show_error.EntryPoint(root)
 	// __pytest__
 	pytest.EntryPoint(root)
+	// __hook_runner__
+	hook_runner.EntryPoint(root)
 	// __hold_till_enter__
 	root.AddSubCommand(&cli.Command{
 		Name:            "__hold_till_enter__",
@@ -97,6 +156,8 @@ func KittyToolEntryPoints(root *cli.Command) {
 			return confirm_and_run_shebang(args)
 		},
 	})
+	// generate-docs
+	generate_docs.EntryPoint(root)
 	// __generate_man_pages__
 	root.AddSubCommand(&cli.Command{
 		Name:            "__generate_man_pages__",
@@ -0,0 +1,25 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tool
+
+import (
+	"fmt"
+	"testing"
+
+	"kitty/tools/cli"
+)
+
+var _ = fmt.Print
+
+// The time taken to register all commands, which happens every time kitten
+// is run, and to then find the command to run for a simple invocation
+func BenchmarkEntryPoints(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		root := cli.NewRootCommand()
+		KittyToolEntryPoints(root)
+		if root.FindSubCommand("clipboard") == nil {
+			b.Fatal("clipboard kitten not found")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package update_check
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"kitty"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type ChangelogSection struct {
+	Version kitty.VersionType
+	// The date of the release or future for unreleased changes
+	Date string
+	Text string
+}
+
+var section_pat = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(`^(\d+\.\d+\.\d+) \[([^\]]+)\]\s*$`)
+})
+
+// Parse the sections describing the changes in each version from the RST
+// source of the changelog
+func ParseChangelog(text string) (ans []ChangelogSection) {
+	lines := strings.Split(text, "\n")
+	var current *ChangelogSection
+	var body []string
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimSpace(strings.Join(body, "\n"))
+			ans = append(ans, *current)
+		}
+		current, body = nil, nil
+	}
+	for i := 0; i < len(lines); i++ {
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "~~~") {
+			if m := section_pat().FindStringSubmatch(lines[i]); m != nil {
+				if v, err := parse_version(m[1]); err == nil {
+					flush()
+					current = &ChangelogSection{Version: v, Date: m[2]}
+					i++
+					continue
+				}
+			}
+		}
+		if current != nil {
+			if strings.HasPrefix(lines[i], "..") && strings.Contains(lines[i], "}}}") {
+				// end of the changelog fold marker
+				flush()
+				continue
+			}
+			body = append(body, lines[i])
+		}
+	}
+	flush()
+	return
+}
+
+// The changes made after the installed version, up to and including the specified release
+func ChangesSince(sections []ChangelogSection, installed kitty.VersionType, r Release) (ans []ChangelogSection) {
+	for _, s := range sections {
+		if compare_versions(s.Version, installed) < 0 || compare_versions(s.Version, r.Version) > 0 {
+			continue
+		}
+		if compare_versions(s.Version, installed) == 0 {
+			// The installed version can only have further changes if it is unreleased
+			if s.Date != "future" || r.Channel != "nightly" {
+				continue
+			}
+		}
+		if s.Date == "future" && r.Channel != "nightly" {
+			continue
+		}
+		ans = append(ans, s)
+	}
+	return
+}
+
+// Create an RST document from the specified changelog sections
+func ChangelogAsRST(sections []ChangelogSection, installed kitty.VersionType) string {
+	b := strings.Builder{}
+	title := "Changes since kitty " + version_string(installed)
+	fmt.Fprintf(&b, "%s\n%s\n\n", title, strings.Repeat("=", len(title)))
+	for _, s := range sections {
+		heading := version_string(s.Version)
+		if s.Date == "future" {
+			heading += " (unreleased)"
+		} else {
+			heading += " (" + s.Date + ")"
+		}
+		fmt.Fprintf(&b, "%s\n%s\n\n%s\n\n", heading, strings.Repeat("-", len(heading)), s.Text)
+	}
+	return b.String()
+}
+
+func FetchChangelog(r Release) ([]ChangelogSection, error) {
+	url := fmt.Sprintf(changelog_url, r.changelog_ref())
+	data, err := utils.DownloadCached(url, time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to download the changelog from %s with error: %w", url, err)
+	}
+	return ParseChangelog(utils.UnsafeBytesToString(data)), nil
+}
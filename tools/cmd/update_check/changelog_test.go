@@ -0,0 +1,122 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package update_check
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"kitty"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+const sample_changelog = `Changelog
+==============
+
+Recent major new features
+---------------------------
+
+Wayland goodies [0.34]
+~~~~~~~~~~~~~~~~~~~~~~~
+
+* Something big
+
+.. }}}
+
+Detailed list of changes
+-------------------------------------
+
+0.3.0 [future]
+~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+- Unreleased change
+
+0.2.1 [2024-04-19]
+~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+- Fix one
+- Fix two
+
+0.2.0 [2024-04-01]
+~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+- Feature
+
+0.1.0 [2024-01-01]
+~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+- Initial release
+`
+
+func TestUpdateCheckChangelog(t *testing.T) {
+	sections := ParseChangelog(sample_changelog)
+	summary := func(s []ChangelogSection) (ans []string) {
+		for _, x := range s {
+			ans = append(ans, version_string(x.Version)+" "+x.Date+": "+strings.ReplaceAll(x.Text, "\n", "|"))
+		}
+		return
+	}
+	if diff := cmp.Diff([]string{
+		"0.3.0 future: - Unreleased change", "0.2.1 2024-04-19: - Fix one|- Fix two",
+		"0.2.0 2024-04-01: - Feature", "0.1.0 2024-01-01: - Initial release",
+	}, summary(sections)); diff != "" {
+		t.Fatalf("Failed to parse changelog:\n%s", diff)
+	}
+	v := func(x string) kitty.VersionType {
+		ans, err := parse_version(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ans
+	}
+	tc := func(installed, latest, channel string, expected ...string) {
+		t.Helper()
+		var actual []string
+		for _, s := range ChangesSince(sections, v(installed), Release{Channel: channel, Version: v(latest)}) {
+			actual = append(actual, version_string(s.Version))
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Incorrect changes since %s for %s %s:\n%s", installed, channel, latest, diff)
+		}
+	}
+	tc("0.1.0", "0.2.1", "stable", "0.2.1", "0.2.0")
+	tc("0.2.1", "0.2.1", "stable")
+	tc("0.2.0", "0.3.0", "stable", "0.2.1")
+	tc("0.2.1", "0.3.0", "nightly", "0.3.0")
+	tc("0.3.0", "0.3.0", "nightly", "0.3.0")
+	if !strings.Contains(ChangelogAsRST(sections[:1], v("0.2.1")), "0.3.0 (unreleased)\n------------------") {
+		t.Fatalf("Incorrect RST for changes: %s", ChangelogAsRST(sections[:1], v("0.2.1")))
+	}
+}
+
+func TestUpdateCheckMetadata(t *testing.T) {
+	r, err := parse_metadata("stable", []byte("0.35.2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(kitty.VersionType{Major: 0, Minor: 35, Patch: 2}, r.Version); diff != "" {
+		t.Fatalf("Incorrect version:\n%s", diff)
+	}
+	if r, err = parse_metadata("nightly", []byte("0.35.2\nabcdef123456789\n2024-05-01T10:20:30+00:00\n")); err != nil {
+		t.Fatal(err)
+	}
+	if r.Commit != "abcdef123456789" || r.BuiltAt.Unix() != 1714558830 {
+		t.Fatalf("Incorrect nightly metadata: %#v", r)
+	}
+	for _, bad := range []string{"", "1.2", "1.x.3"} {
+		if _, err = parse_metadata("stable", []byte(bad)); err == nil {
+			t.Fatalf("No error for invalid version: %#v", bad)
+		}
+	}
+	if _, err = parse_metadata("nightly", []byte("0.35.2\n")); err == nil {
+		t.Fatalf("No error for incomplete nightly metadata")
+	}
+	newer := Release{Channel: "stable", Version: kitty.VersionType{Major: kitty.Version.Major, Minor: kitty.Version.Minor + 1}}
+	if !newer.IsNewer() || (Release{Channel: "stable", Version: kitty.Version}).IsNewer() {
+		t.Fatalf("Incorrect version comparison")
+	}
+}
@@ -0,0 +1,128 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package update_check
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"kitty"
+	"kitty/kittens/help"
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+)
+
+var _ = fmt.Print
+
+type Options struct {
+	Channel   string
+	Changelog bool
+	Verify    string
+}
+
+func installed_version() string {
+	ans := kitty.VersionString
+	if kitty.VCSRevision != "" {
+		ans += fmt.Sprintf(" (commit: %.12s)", kitty.VCSRevision)
+	}
+	return ans
+}
+
+func print_changelog(r Release) error {
+	sections, err := FetchChangelog(r)
+	if err != nil {
+		return err
+	}
+	changes := ChangesSince(sections, kitty.Version, r)
+	if len(changes) == 0 {
+		fmt.Println("No changes since kitty", installed_version(), "found in the changelog")
+		return nil
+	}
+	width, is_tty := 80, tty.IsTerminal(os.Stdout.Fd())
+	if sz, err := tty.GetSize(int(os.Stdout.Fd())); err == nil && sz.Col > 0 {
+		width = int(sz.Col)
+	}
+	lines := help.FormatRST("changelog", ChangelogAsRST(changes, kitty.Version), width, is_tty)
+	fmt.Println()
+	if is_tty {
+		if sz, err := tty.GetSize(int(os.Stdout.Fd())); err == nil && len(lines) >= int(sz.Row) {
+			cli.ShowHelpInPager(strings.Join(lines, "\n") + "\n")
+			return nil
+		}
+	}
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+	return nil
+}
+
+func update_check(opts *Options) (err error) {
+	r, err := FetchLatestRelease(opts.Channel, opts.Verify)
+	if err != nil {
+		return err
+	}
+	if r.IsNewer() {
+		fmt.Println("A newer version of kitty is available:", r)
+		fmt.Println("Installed version:", installed_version())
+		if kitty.IsStandaloneBuild != "" {
+			self_update := "kitten update-self"
+			if opts.Channel == "nightly" {
+				self_update += " --fetch-version nightly"
+			}
+			fmt.Println("Run", self_update, "to update this kitten")
+		} else {
+			fmt.Println("See", kitty.WebsiteBaseURL+"binary/", "for how to update")
+		}
+	} else {
+		fmt.Println("kitty is up to date. Installed version:", installed_version())
+	}
+	if opts.Changelog {
+		return print_changelog(r)
+	}
+	return
+}
+
+func EntryPoint(root *cli.Command) *cli.Command {
+	sc := root.AddSubCommand(&cli.Command{
+		Name:             "update-check",
+		Usage:            "[options]",
+		ShortDescription: "Check if a newer version of kitty is available",
+		HelpText: "Check if a newer version of kitty is available on the specified release channel." +
+			" The downloaded version information is verified using the GPG signature published alongside it," +
+			" which requires :program:`gpg` to be installed." +
+			" Use :option:`--changelog` to also see what has changed since the installed version.",
+		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
+			if len(args) != 0 {
+				return 1, fmt.Errorf("No command line arguments are allowed")
+			}
+			opts := &Options{}
+			err = cmd.GetOptionValues(opts)
+			if err != nil {
+				return 1, err
+			}
+			if err = update_check(opts); err != nil {
+				return 1, err
+			}
+			return 0, nil
+		},
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--channel",
+		Choices: "stable, nightly",
+		Default: "stable",
+		Help:    "The release channel to check. Nightly builds are made from the latest kitty source code and are updated frequently.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name: "--changelog",
+		Type: "bool-set",
+		Help: "Show the changes from the changelog made since the installed version. For the nightly channel this includes unreleased changes.",
+	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--verify",
+		Choices: "always, never",
+		Default: "always",
+		Help:    "Whether to verify the signature of the version information. Verification requires :program:`gpg` to be installed, it is an error if the signature cannot be verified.",
+	})
+	return sc
+}
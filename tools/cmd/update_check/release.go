@@ -0,0 +1,242 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package update_check
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const signing_key_url = "https://calibre-ebook.com/signatures/kovid.gpg"
+const nightly_metadata_url = "https://github.com/kovidgoyal/kitty/releases/download/nightly/nightly-version.txt"
+const changelog_url = "https://raw.githubusercontent.com/kovidgoyal/kitty/%s/docs/changelog.rst"
+
+type Release struct {
+	Channel string
+	Version kitty.VersionType
+	// Only present for nightly builds
+	Commit  string
+	BuiltAt time.Time
+}
+
+func (self Release) String() string {
+	ans := version_string(self.Version)
+	if self.Channel == "nightly" {
+		ans = fmt.Sprintf("nightly (%s, commit: %.12s, built at: %s)", ans, self.Commit, self.BuiltAt.Local().Format(time.DateTime))
+	}
+	return ans
+}
+
+// The git ref from which to get the changelog for this release
+func (self Release) changelog_ref() string {
+	if self.Channel == "nightly" {
+		return "nightly"
+	}
+	return "v" + version_string(self.Version)
+}
+
+func version_string(v kitty.VersionType) string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func parse_version(raw string) (ans kitty.VersionType, err error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return ans, fmt.Errorf("Not a valid version: %#v", raw)
+	}
+	nums := [3]int{}
+	for i, x := range parts {
+		if nums[i], err = strconv.Atoi(x); err != nil {
+			return ans, fmt.Errorf("Not a valid version: %#v", raw)
+		}
+	}
+	return kitty.VersionType{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+func compare_versions(a, b kitty.VersionType) int {
+	for _, d := range [3]int{a.Major - b.Major, a.Minor - b.Minor, a.Patch - b.Patch} {
+		if d != 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func metadata_url(channel string) string {
+	if channel == "nightly" {
+		return nightly_metadata_url
+	}
+	return kitty.WebsiteBaseURL + "current-version.txt"
+}
+
+// Parse the version metadata. For the stable channel it is just the version,
+// for nightly builds it is three lines: version, git commit and build time.
+func parse_metadata(channel string, data []byte) (ans Release, err error) {
+	ans.Channel = channel
+	lines := strings.Split(strings.TrimSpace(utils.UnsafeBytesToString(data)), "\n")
+	if ans.Version, err = parse_version(lines[0]); err != nil {
+		return
+	}
+	if channel == "nightly" {
+		if len(lines) < 3 {
+			return ans, fmt.Errorf("The nightly version information is incomplete")
+		}
+		ans.Commit = strings.TrimSpace(lines[1])
+		if ans.BuiltAt, err = utils.ISO8601Parse(strings.TrimSpace(lines[2])); err != nil {
+			return ans, fmt.Errorf("The nightly version information has an invalid build time: %w", err)
+		}
+	}
+	return
+}
+
+// Whether the release is newer than the currently running kitten
+func (self Release) IsNewer() bool {
+	if c := compare_versions(self.Version, kitty.Version); c != 0 || self.Channel != "nightly" {
+		return c > 0
+	}
+	return kitty.VCSRevision == "" || !strings.HasPrefix(self.Commit, kitty.VCSRevision)
+}
+
+var ErrNoGPG = errors.New("Cannot verify signatures as neither gpg nor gpg2 is installed")
+
+// The fingerprint of the kitty signing key, the downloaded key is only used
+// if it matches, so that signatures are not merely as trustworthy as the
+// server the key is downloaded from
+const signing_key_fingerprint = "3CE1780F78DD88DF45194FD706BC317B515ACE7C"
+
+func signing_key() (path string, err error) {
+	path = filepath.Join(utils.CacheDir(), "kovid.gpg")
+	if _, err = os.Stat(path); err == nil {
+		return
+	}
+	data, err := utils.DownloadAsSlice(signing_key_url, nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to download the signing key from %s with error: %w", signing_key_url, err)
+	}
+	return path, utils.AtomicWriteFile(path, data, 0o644)
+}
+
+// The fingerprints of the primary keys in the output of gpg --with-colons --list-keys
+func primary_key_fingerprints(list_keys_output string) (ans []string) {
+	in_pub := false
+	for _, line := range strings.Split(list_keys_output, "\n") {
+		fields := strings.Split(line, ":")
+		switch fields[0] {
+		case "pub":
+			in_pub = true
+		case "fpr":
+			if in_pub && len(fields) > 9 {
+				ans = append(ans, fields[9])
+			}
+			in_pub = false
+		}
+	}
+	return
+}
+
+// The fingerprint of the primary key that made a good signature, from the
+// output of gpg --status-fd
+func valid_signature_fingerprint(status_output string) string {
+	for _, line := range strings.Split(status_output, "\n") {
+		if fields := strings.Fields(line); len(fields) > 11 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG" {
+			return fields[11]
+		}
+	}
+	return ""
+}
+
+// Verify the detached GPG signature for data using the kitty signing key
+func VerifySignature(data, sig []byte) (err error) {
+	gpg, err := exec.LookPath("gpg")
+	if err != nil {
+		if gpg, err = exec.LookPath("gpg2"); err != nil {
+			return ErrNoGPG
+		}
+	}
+	key, err := signing_key()
+	if err != nil {
+		return err
+	}
+	// Use a temporary home so that the users keyring is neither used nor modified
+	home, err := os.MkdirTemp("", "kitten-gpg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command(gpg, append([]string{"--homedir", home, "--batch", "--no-tty", "--quiet"}, args...)...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s failed with error: %w\n%s", strings.Join(cmd.Args, " "), err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), nil
+	}
+	if _, err = run("--import", key); err != nil {
+		return err
+	}
+	keys, err := run("--with-colons", "--list-keys")
+	if err != nil {
+		return err
+	}
+	if fp := primary_key_fingerprints(keys); len(fp) != 1 || fp[0] != signing_key_fingerprint {
+		// do not keep using a bad key from the cache
+		os.Remove(key)
+		return fmt.Errorf("The signing key downloaded from %s does not have the expected fingerprint: %s", signing_key_url, signing_key_fingerprint)
+	}
+	data_path, sig_path := filepath.Join(home, "data"), filepath.Join(home, "data.sig")
+	if err = os.WriteFile(data_path, data, 0o600); err != nil {
+		return err
+	}
+	if err = os.WriteFile(sig_path, sig, 0o600); err != nil {
+		return err
+	}
+	status, err := run("--status-fd", "1", "--verify", sig_path, data_path)
+	if err != nil {
+		return fmt.Errorf("The signature is invalid: %w", err)
+	}
+	if valid_signature_fingerprint(status) != signing_key_fingerprint {
+		return fmt.Errorf("The signature was not made with the kitty signing key")
+	}
+	return nil
+}
+
+// Download the signature from sig_url and use it to verify data. The verify
+// policy is one of always or never. It is an error if the signature cannot
+// be verified, including when gpg is not installed.
+func CheckSignature(data []byte, sig_url, verify string) (err error) {
+	if verify == "never" {
+		return
+	}
+	sig, err := utils.DownloadAsSlice(sig_url, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to download the signature from %s with error: %w", sig_url, err)
+	}
+	return VerifySignature(data, sig)
+}
+
+// Fetch information about the latest release on the specified channel, see
+// CheckSignature for the meaning of verify.
+func FetchLatestRelease(channel, verify string) (ans Release, err error) {
+	url := metadata_url(channel)
+	data, err := utils.DownloadAsSlice(url, nil)
+	if err != nil {
+		return ans, fmt.Errorf("Failed to download version information from %s with error: %w", url, err)
+	}
+	if err = CheckSignature(data, url+".sig", verify); err != nil {
+		return ans, fmt.Errorf("Could not verify the version information: %w", err)
+	}
+	return parse_metadata(channel, data)
+}
@@ -0,0 +1,37 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package update_check
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestSignatureFingerprints(t *testing.T) {
+	keys := `tru::1:1710000000:0:3:1:5
+pub:-:4096:1:06BC317B515ACE7C:1400000000:::-:::scESC::::::23::0:
+fpr:::::::::3CE1780F78DD88DF45194FD706BC317B515ACE7C:
+uid:-::::1400000000::ABCD::Kovid Goyal <kovid@kovidgoyal.net>::::::::::0:
+sub:-:4096:1:1111111111111111:1400000000::::::e::::::23:
+fpr:::::::::00000000000000000000000001111111111111111:
+pub:-:255:22:2222222222222222:1700000000:::-:::scESC::::::23::0:
+fpr:::::::::AAAAAAAAAAAAAAAAAAAAAAAA2222222222222222:
+`
+	if diff := cmp.Diff([]string{signing_key_fingerprint, "AAAAAAAAAAAAAAAAAAAAAAAA2222222222222222"}, primary_key_fingerprints(keys)); diff != "" {
+		t.Fatalf("Unexpected primary key fingerprints:\n%s", diff)
+	}
+	status := `[GNUPG:] NEWSIG
+[GNUPG:] GOODSIG 06BC317B515ACE7C Kovid Goyal <kovid@kovidgoyal.net>
+[GNUPG:] VALIDSIG 0000000000000000000000001111111111111111 2024-04-01 1711929600 0 4 0 1 10 00 3CE1780F78DD88DF45194FD706BC317B515ACE7C
+`
+	if fp := valid_signature_fingerprint(status); fp != signing_key_fingerprint {
+		t.Fatalf("Unexpected signing key fingerprint: %#v", fp)
+	}
+	if fp := valid_signature_fingerprint("[GNUPG:] BADSIG 06BC317B515ACE7C x\n"); fp != "" {
+		t.Fatalf("Unexpected signing key fingerprint for a bad signature: %#v", fp)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"kitty"
 	"kitty/tools/cli"
+	"kitty/tools/cmd/update_check"
 	"kitty/tools/tty"
 	"kitty/tools/tui"
 	"kitty/tools/utils"
@@ -21,9 +22,10 @@ var _ = fmt.Print
 
 type Options struct {
 	FetchVersion string
+	Verify       string
 }
 
-func update_self(version string) (err error) {
+func update_self(version, verify string) (err error) {
 	exe := ""
 	exe, err = os.Executable()
 	if err != nil {
@@ -49,21 +51,32 @@ func update_self(version string) (err error) {
 	if err != nil {
 		return err
 	}
+	dest.Close()
 	defer func() { os.Remove(dest.Name()) }()
 
 	if !tty.IsTerminal(os.Stdout.Fd()) {
 		fmt.Println("Downloading:", url)
-		err = utils.DownloadToFile(exe, url, nil, nil)
+		err = utils.DownloadToFile(dest.Name(), url, nil, nil)
 		if err != nil {
 			return err
 		}
-		fmt.Println("Downloaded to:", exe)
 	} else {
-		err = tui.DownloadFileWithProgress(exe, url, true)
+		err = tui.DownloadFileWithProgress(dest.Name(), url, true)
 		if err != nil {
 			return err
 		}
 	}
+	data, err := os.ReadFile(dest.Name())
+	if err != nil {
+		return err
+	}
+	if err = update_check.CheckSignature(data, url+".sig", verify); err != nil {
+		return fmt.Errorf("Could not verify the downloaded kitten: %w", err)
+	}
+	if err = replace_executable(exe, dest.Name(), check_executable); err != nil {
+		return err
+	}
+	fmt.Println("Updated:", exe)
 	fmt.Print("Updated to: ")
 	return unix.Exec(exe, []string{"kitten", "--version"}, os.Environ())
 }
@@ -73,7 +86,9 @@ func EntryPoint(root *cli.Command) *cli.Command {
 		Name:             "update-self",
 		Usage:            "[options]",
 		ShortDescription: "Update this kitten binary",
-		HelpText:         "Update this kitten binary in place to the latest available version.",
+		HelpText: "Update this kitten binary in place to the latest available version." +
+			" The downloaded binary is verified using its GPG signature, which requires :program:`gpg` to be installed." +
+			" The binary is replaced atomically and if the updated binary fails to run, the original is restored.",
 		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
 			if len(args) != 0 {
 				return 1, fmt.Errorf("No command line arguments are allowed")
@@ -83,7 +98,7 @@ func EntryPoint(root *cli.Command) *cli.Command {
 			if err != nil {
 				return 1, err
 			}
-			return 0, update_self(opts.FetchVersion)
+			return 0, update_self(opts.FetchVersion, opts.Verify)
 		},
 	})
 	sc.Add(cli.OptionSpec{
@@ -91,5 +106,14 @@ func EntryPoint(root *cli.Command) *cli.Command {
 		Default: "latest",
 		Help:    fmt.Sprintf("The version to fetch. The special words :code:`latest` and :code:`nightly` fetch the latest stable and nightly release respectively. Other values can be, for example: :code:`%s`.", kitty.VersionString),
 	})
+	sc.Add(cli.OptionSpec{
+		Name:    "--verify",
+		Choices: "always, never",
+		Default: "always",
+		Help:    "Whether to verify the signature of the downloaded binary. Verification requires :program:`gpg` to be installed, the binary is not installed if its signature cannot be verified.",
+	})
+	alias := root.AddClone("", sc)
+	alias.Name = "self-update"
+	alias.Hidden = true
 	return sc
 }
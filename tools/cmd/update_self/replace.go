@@ -0,0 +1,76 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package update_self
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// Check that the executable at path can actually be run on this machine
+func check_executable(path string) error {
+	out, err := exec.Command(path, "--version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Running %s --version failed with error: %w\n%s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func copy_file(src, dest string, perm os.FileMode) (err error) {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	d, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(d, s); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// Atomically replace exe with the file at new_path, which must be on the same
+// filesystem. A backup of exe is kept until check succeeds on the replaced
+// executable, if it fails, the original executable is restored.
+func replace_executable(exe, new_path string, check func(string) error) (err error) {
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(new_path, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = check(new_path); err != nil {
+		return fmt.Errorf("The downloaded executable does not work: %w", err)
+	}
+	backup := exe + ".old"
+	os.Remove(backup)
+	// A hard link means exe is present at all times, fallback to a copy on
+	// filesystems that dont support them
+	if os.Link(exe, backup) != nil {
+		if err = copy_file(exe, backup, fi.Mode().Perm()); err != nil {
+			return fmt.Errorf("Failed to backup %s with error: %w", exe, err)
+		}
+	}
+	if err = os.Rename(new_path, exe); err != nil {
+		os.Remove(backup)
+		return fmt.Errorf("Failed to replace %s with error: %w", exe, err)
+	}
+	if err = check(exe); err != nil {
+		if rerr := os.Rename(backup, exe); rerr != nil {
+			return fmt.Errorf("The updated executable does not work: %w and restoring the original from %s failed with error: %s", err, backup, rerr)
+		}
+		return fmt.Errorf("The updated executable does not work, restored the original. Error: %w", err)
+	}
+	os.Remove(backup)
+	return nil
+}
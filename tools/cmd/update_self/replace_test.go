@@ -0,0 +1,71 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package update_self
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestUpdateSelfReplace(t *testing.T) {
+	tdir := t.TempDir()
+	exe, new_path := filepath.Join(tdir, "kitten"), filepath.Join(tdir, "kitten.new")
+	write := func(path, text string) {
+		if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	write(exe, "old")
+	if err := os.Chmod(exe, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write(new_path, "new")
+	works := func(string) error { return nil }
+	if err := replace_executable(exe, new_path, works); err != nil {
+		t.Fatal(err)
+	}
+	if read(exe) != "new" {
+		t.Fatalf("Executable was not replaced")
+	}
+	if fi, err := os.Stat(exe); err != nil || fi.Mode().Perm() != 0o755 {
+		t.Fatalf("Permissions of replaced executable not preserved: %v", fi.Mode())
+	}
+	if _, err := os.Stat(exe + ".old"); err == nil {
+		t.Fatalf("Backup was not removed")
+	}
+
+	// A downloaded executable that does not work is rejected
+	write(new_path, "broken")
+	if err := replace_executable(exe, new_path, func(string) error { return fmt.Errorf("broken") }); err == nil {
+		t.Fatalf("No error for broken download")
+	}
+	if read(exe) != "new" {
+		t.Fatalf("Executable was replaced by broken download")
+	}
+
+	// An executable that fails after replacement is rolled back
+	write(new_path, "newer")
+	fails_after_replace := func(path string) error {
+		if path == exe {
+			return fmt.Errorf("broken")
+		}
+		return nil
+	}
+	if err := replace_executable(exe, new_path, fails_after_replace); err == nil {
+		t.Fatalf("No error for failed update")
+	}
+	if read(exe) != "new" {
+		t.Fatalf("Executable was not rolled back, has: %#v", read(exe))
+	}
+}
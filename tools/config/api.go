@@ -3,7 +3,6 @@
 package config
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
@@ -65,8 +64,7 @@ func (self *ConfigParser) parse(scanner Scanner, name, base_path_for_includes st
 		if depth > 32 {
 			return fmt.Errorf("Too many nested include directives while processing config file: %s", name)
 		}
-		escanner := bufio.NewScanner(r)
-		return self.parse(escanner, nname, base_path_for_includes, depth+1)
+		return self.parse(utils.NewRecordScanner(r), nname, base_path_for_includes, depth+1)
 	}
 
 	make_absolute := func(path string) (string, error) {
@@ -191,7 +189,7 @@ func (self *ConfigParser) parse(scanner Scanner, name, base_path_for_includes st
 			}
 		}
 	}
-	return nil
+	return scanner.Err()
 }
 
 func (self *ConfigParser) ParseFiles(paths ...string) error {
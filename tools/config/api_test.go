@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -41,7 +42,9 @@ globin
 badline
 `))
 	w(filepath.Join(tdir, "sub/b.conf"), []byte("incb cool\ninclude a.conf"))
-	w(filepath.Join(tdir, "sub/c1.conf"), []byte("inc1 cool"))
+	// lines longer than the default bufio.Scanner limit
+	long_val := strings.Repeat("x", 128*1024)
+	w(filepath.Join(tdir, "sub/c1.conf"), []byte("long "+long_val+"\r\ninc1 cool"))
 	w(filepath.Join(tdir, "sub/c2.conf"), []byte("inc2 cool\nenvinclude ENVINCLUDE"))
 	w(filepath.Join(tdir, "sub/c.conf"), []byte("inc notcool\nerror sub"))
 
@@ -62,7 +65,7 @@ badline
 	if err = p.ParseOverrides("over one", "over two"); err != nil {
 		t.Fatal(err)
 	}
-	diff := cmp.Diff([]string{"a one", "incb cool", "b x", "inc1 cool", "inc2 cool", "env cool", "inc notcool", "over one", "over two"}, parsed_lines)
+	diff := cmp.Diff([]string{"a one", "incb cool", "b x", "long " + long_val, "inc1 cool", "inc2 cool", "env cool", "inc notcool", "over one", "over two"}, parsed_lines)
 	if diff != "" {
 		t.Fatalf("Unexpected parsed config values:\n%s", diff)
 	}
@@ -0,0 +1,452 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+// Package dbus is a minimal, pure Go, D-Bus client, sufficient for calling
+// methods, reading properties and receiving signals from desktop services.
+package dbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+type MessageType byte
+
+const (
+	MethodCall MessageType = iota + 1
+	MethodReturn
+	ErrorReply
+	Signal
+)
+
+const (
+	flag_no_reply_expected = 1
+
+	field_path         = 1
+	field_interface    = 2
+	field_member       = 3
+	field_error_name   = 4
+	field_reply_serial = 5
+	field_destination  = 6
+	field_sender       = 7
+	field_signature    = 8
+
+	max_message_size = 128 * 1024 * 1024
+	DefaultTimeout   = 25 * time.Second
+)
+
+type Message struct {
+	Type                                      MessageType
+	Flags                                     byte
+	Serial, ReplySerial                       uint32
+	Path                                      ObjectPath
+	Interface, Member, ErrorName, Destination string
+	Sender                                    string
+	Signature                                 Signature
+	Body                                      []any
+}
+
+// An error returned by the remote end of a method call
+type Error struct {
+	Name, Message string
+}
+
+func (self *Error) Error() string {
+	if self.Message == "" {
+		return self.Name
+	}
+	return fmt.Sprintf("%s: %s", self.Name, self.Message)
+}
+
+func (self *Message) marshal() ([]byte, error) {
+	body := encoder{}
+	if err := body.encode_all(string(self.Signature), self.Body...); err != nil {
+		return nil, err
+	}
+	fields := map[byte]Variant{}
+	add := func(code byte, sig Signature, val any, present bool) {
+		if present {
+			fields[code] = Variant{sig, val}
+		}
+	}
+	add(field_path, "o", string(self.Path), self.Path != "")
+	add(field_interface, "s", self.Interface, self.Interface != "")
+	add(field_member, "s", self.Member, self.Member != "")
+	add(field_error_name, "s", self.ErrorName, self.ErrorName != "")
+	add(field_reply_serial, "u", self.ReplySerial, self.ReplySerial != 0)
+	add(field_destination, "s", self.Destination, self.Destination != "")
+	add(field_sender, "s", self.Sender, self.Sender != "")
+	add(field_signature, "g", string(self.Signature), self.Signature != "")
+	hf := make([]any, 0, len(fields))
+	for code := byte(1); code <= field_signature; code++ {
+		if v, ok := fields[code]; ok {
+			hf = append(hf, []any{code, v})
+		}
+	}
+	e := encoder{buf: make([]byte, 0, 128+len(body.buf))}
+	e.buf = append(e.buf, 'l', byte(self.Type), self.Flags, 1)
+	e.u32(uint32(len(body.buf)))
+	e.u32(self.Serial)
+	if err := e.encode("a(yv)", hf); err != nil {
+		return nil, err
+	}
+	e.align(8)
+	e.buf = append(e.buf, body.buf...)
+	return e.buf, nil
+}
+
+func read_message(r io.Reader) (*Message, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("Invalid D-Bus message endianness: %#v", fixed[0])
+	}
+	body_len, fields_len := order.Uint32(fixed[4:]), order.Uint32(fixed[12:])
+	header_len := (16 + int(fields_len) + 7) / 8 * 8
+	if total := int64(header_len) + int64(body_len); total > max_message_size {
+		return nil, fmt.Errorf("D-Bus message too large: %d", total)
+	}
+	data := make([]byte, header_len+int(body_len))
+	copy(data, fixed[:])
+	if _, err := io.ReadFull(r, data[16:]); err != nil {
+		return nil, err
+	}
+	d := decoder{data: data, pos: 12, order: order}
+	hf, err := d.decode("a(yv)", 0)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{Type: MessageType(fixed[1]), Flags: fixed[2], Serial: order.Uint32(fixed[8:])}
+	for _, x := range hf.([]any) {
+		f := x.([]any)
+		val := f[1].(Variant).Value
+		str := func() string { s, _ := val.(string); return s }
+		switch f[0].(byte) {
+		case field_path:
+			p, _ := val.(ObjectPath)
+			m.Path = p
+		case field_interface:
+			m.Interface = str()
+		case field_member:
+			m.Member = str()
+		case field_error_name:
+			m.ErrorName = str()
+		case field_reply_serial:
+			m.ReplySerial, _ = val.(uint32)
+		case field_destination:
+			m.Destination = str()
+		case field_sender:
+			m.Sender = str()
+		case field_signature:
+			m.Signature, _ = val.(Signature)
+		}
+	}
+	d.pos = header_len
+	if m.Body, err = d.decode_all(string(m.Signature)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type match_handler struct {
+	rule    string
+	handler func(*Message)
+}
+
+type Conn struct {
+	conn       net.Conn
+	write_lock sync.Mutex
+	lock       sync.Mutex
+	serial     uint32
+	pending    map[uint32]chan *Message
+	handlers   []match_handler
+	closed     error
+	done       chan struct{}
+	// The unique name of this connection on the bus
+	UniqueName string
+}
+
+func bus_address(env_var, fallback string) string {
+	if a := os.Getenv(env_var); a != "" {
+		return a
+	}
+	return fallback
+}
+
+// Connect to the session bus, specified by DBUS_SESSION_BUS_ADDRESS or the
+// bus socket in XDG_RUNTIME_DIR
+func ConnectSessionBus() (*Conn, error) {
+	fallback := ""
+	if rd := os.Getenv("XDG_RUNTIME_DIR"); rd != "" {
+		fallback = "unix:path=" + filepath.Join(rd, "bus")
+	}
+	addr := bus_address("DBUS_SESSION_BUS_ADDRESS", fallback)
+	if addr == "" {
+		return nil, fmt.Errorf("No D-Bus session bus address found")
+	}
+	return Connect(addr)
+}
+
+func ConnectSystemBus() (*Conn, error) {
+	return Connect(bus_address("DBUS_SYSTEM_BUS_ADDRESS", "unix:path=/var/run/dbus/system_bus_socket"))
+}
+
+// Parse a D-Bus server address into the arguments for net.Dial(), only unix
+// and tcp transports are supported
+func parse_address(address string) (network, addr string, err error) {
+	for _, a := range strings.Split(address, ";") {
+		transport, params, found := strings.Cut(a, ":")
+		if !found {
+			continue
+		}
+		kv := map[string]string{}
+		for _, p := range strings.Split(params, ",") {
+			if k, v, found := strings.Cut(p, "="); found {
+				kv[k] = unescape_address_value(v)
+			}
+		}
+		switch transport {
+		case "unix":
+			if kv["path"] != "" {
+				return "unix", kv["path"], nil
+			}
+			if kv["abstract"] != "" {
+				return "unix", "@" + kv["abstract"], nil
+			}
+		case "tcp":
+			if kv["host"] != "" && kv["port"] != "" {
+				return "tcp", net.JoinHostPort(kv["host"], kv["port"]), nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("Unsupported D-Bus address: %s", address)
+}
+
+func unescape_address_value(x string) string {
+	if !strings.Contains(x, "%") {
+		return x
+	}
+	var b strings.Builder
+	for i := 0; i < len(x); i++ {
+		if x[i] == '%' && i+2 < len(x) {
+			if n, err := strconv.ParseUint(x[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(x[i])
+	}
+	return b.String()
+}
+
+// Connect to the bus at the specified address and authenticate
+func Connect(address string) (ans *Conn, err error) {
+	network, addr, err := parse_address(address)
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.DialTimeout(network, addr, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	ans = &Conn{conn: c, pending: make(map[uint32]chan *Message), done: make(chan struct{})}
+	r := bufio.NewReader(c)
+	if err = ans.authenticate(r); err != nil {
+		c.Close()
+		return nil, err
+	}
+	go ans.read_loop(r)
+	reply, err := ans.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "")
+	if err != nil {
+		ans.Close()
+		return nil, err
+	}
+	if len(reply) > 0 {
+		ans.UniqueName, _ = reply[0].(string)
+	}
+	return ans, nil
+}
+
+func (self *Conn) authenticate(r *bufio.Reader) error {
+	_ = self.conn.SetDeadline(time.Now().Add(DefaultTimeout))
+	defer func() { _ = self.conn.SetDeadline(time.Time{}) }()
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := self.conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("Failed to authenticate with D-Bus with error: %w", err)
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("Failed to authenticate with D-Bus, server responded with: %s", strings.TrimSpace(line))
+	}
+	_, err = self.conn.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+func (self *Conn) read_loop(r io.Reader) {
+	for {
+		m, err := read_message(r)
+		if err != nil {
+			self.lock.Lock()
+			self.closed = err
+			for serial, ch := range self.pending {
+				close(ch)
+				delete(self.pending, serial)
+			}
+			close(self.done)
+			self.lock.Unlock()
+			return
+		}
+		switch m.Type {
+		case MethodReturn, ErrorReply:
+			self.lock.Lock()
+			ch := self.pending[m.ReplySerial]
+			delete(self.pending, m.ReplySerial)
+			self.lock.Unlock()
+			if ch != nil {
+				ch <- m
+			}
+		case Signal:
+			self.lock.Lock()
+			handlers := self.handlers
+			self.lock.Unlock()
+			for _, h := range handlers {
+				h.handler(m)
+			}
+		}
+	}
+}
+
+func (self *Conn) send(m *Message) (ch chan *Message, err error) {
+	self.lock.Lock()
+	if self.closed != nil {
+		self.lock.Unlock()
+		return nil, fmt.Errorf("The D-Bus connection is closed: %w", self.closed)
+	}
+	self.serial++
+	m.Serial = self.serial
+	if m.Type == MethodCall && m.Flags&flag_no_reply_expected == 0 {
+		ch = make(chan *Message, 1)
+		self.pending[m.Serial] = ch
+	}
+	self.lock.Unlock()
+	data, err := m.marshal()
+	if err == nil {
+		self.write_lock.Lock()
+		_, err = self.conn.Write(data)
+		self.write_lock.Unlock()
+	}
+	if err != nil && ch != nil {
+		self.lock.Lock()
+		delete(self.pending, m.Serial)
+		self.lock.Unlock()
+	}
+	return
+}
+
+// Call the specified method with a timeout, returning the body of the reply.
+// The method arguments must match the signature sig.
+func (self *Conn) CallWithTimeout(timeout time.Duration, dest string, path ObjectPath, iface, method string, sig Signature, args ...any) ([]any, error) {
+	m := &Message{Type: MethodCall, Destination: dest, Path: path, Interface: iface, Member: method, Signature: sig, Body: args}
+	ch, err := self.send(m)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("The D-Bus connection was closed while waiting for the reply to: %s.%s", iface, method)
+		}
+		if reply.Type == ErrorReply {
+			e := &Error{Name: reply.ErrorName}
+			if len(reply.Body) > 0 {
+				e.Message, _ = reply.Body[0].(string)
+			}
+			return nil, e
+		}
+		return reply.Body, nil
+	case <-time.After(timeout):
+		self.lock.Lock()
+		delete(self.pending, m.Serial)
+		self.lock.Unlock()
+		return nil, fmt.Errorf("Timed out waiting for the reply to: %s.%s", iface, method)
+	}
+}
+
+func (self *Conn) Call(dest string, path ObjectPath, iface, method string, sig Signature, args ...any) ([]any, error) {
+	return self.CallWithTimeout(DefaultTimeout, dest, path, iface, method, sig, args...)
+}
+
+// Get the value of a property via the org.freedesktop.DBus.Properties interface
+func (self *Conn) GetProperty(dest string, path ObjectPath, iface, name string) (any, error) {
+	reply, err := self.Call(dest, path, "org.freedesktop.DBus.Properties", "Get", "ss", iface, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) == 0 {
+		return nil, fmt.Errorf("No value returned for the property: %s.%s", iface, name)
+	}
+	return Unwrap(reply[0]), nil
+}
+
+// Receive signals matching the specified match rule, for example:
+// type='signal',interface='org.freedesktop.portal.Settings'. The handler is
+// called in a different goroutine and receives all signals matching any rule
+// added with this function.
+func (self *Conn) AddMatch(rule string, handler func(*Message)) error {
+	self.lock.Lock()
+	self.handlers = append(self.handlers, match_handler{rule, handler})
+	self.lock.Unlock()
+	_, err := self.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", rule)
+	return err
+}
+
+// Stop receiving signals for a match rule added with AddMatch(), removing
+// its handlers
+func (self *Conn) RemoveMatch(rule string) error {
+	self.lock.Lock()
+	// copy so that the read loop can iterate over the old handlers unlocked
+	self.handlers = slices.DeleteFunc(slices.Clone(self.handlers), func(h match_handler) bool { return h.rule == rule })
+	self.lock.Unlock()
+	_, err := self.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RemoveMatch", "s", rule)
+	return err
+}
+
+// A channel that is closed when the connection is closed, for example, by
+// the bus going away
+func (self *Conn) Done() <-chan struct{} {
+	return self.done
+}
+
+// Emit a signal
+func (self *Conn) Emit(path ObjectPath, iface, member string, sig Signature, args ...any) error {
+	_, err := self.send(&Message{Type: Signal, Path: path, Interface: iface, Member: member, Signature: sig, Body: args})
+	return err
+}
+
+func (self *Conn) Close() error {
+	return self.conn.Close()
+}
@@ -0,0 +1,266 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package dbus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestDBusMarshal(t *testing.T) {
+	m := &Message{
+		Type: MethodCall, Serial: 7, Path: "/a/b", Interface: "org.test.I", Member: "M", Destination: "org.test",
+		Signature: "ybnqiuxtdsogva{sv}a(si)ai",
+		Body: []any{
+			byte(1), true, int16(-2), uint16(3), int32(-4), uint32(5), int64(-6), uint64(7), 1.5, "s", ObjectPath("/o"), Signature("a{sv}"),
+			MakeVariant("as", []string{"x", "y"}), map[string]Variant{"k": MakeVariant("i", int32(1)), "e": MakeVariant("ay", []byte{})},
+			[]any{[]any{"p", int32(1)}, []any{"q", int32(2)}}, []int32{},
+		},
+	}
+	data, err := m.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := read_message(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := *m
+	expected.Body = []any{
+		byte(1), true, int16(-2), uint16(3), int32(-4), uint32(5), int64(-6), uint64(7), 1.5, "s", ObjectPath("/o"), Signature("a{sv}"),
+		MakeVariant("as", []any{"x", "y"}), map[string]any{"k": MakeVariant("i", int32(1)), "e": MakeVariant("ay", []any{})},
+		[]any{[]any{"p", int32(1)}, []any{"q", int32(2)}}, []any{},
+	}
+	if diff := cmp.Diff(&expected, r); diff != "" {
+		t.Fatalf("Message did not round trip:\n%s", diff)
+	}
+	if binary.LittleEndian.Uint32(data[4:]) != uint32(len(data)-(16+int(binary.LittleEndian.Uint32(data[12:]))+7)/8*8) {
+		t.Fatalf("Incorrect body length in header")
+	}
+	for i := 0; i < len(data); i++ {
+		// must not panic on truncated data
+		_, _ = read_message(bytes.NewReader(data[:i]))
+	}
+	for _, bad := range []string{"a", "(i", "a{s", "z", "a{}", "a{s}", "a{sii}", "a{(i)s}", "a{vs}", "{ss}", "(i{ss})", "()"} {
+		if _, err := split_signature(bad); err == nil {
+			t.Fatalf("No error for invalid signature: %s", bad)
+		}
+	}
+	if _, err := (&Message{Signature: "i", Body: []any{"x"}}).marshal(); err == nil {
+		t.Fatalf("No error for mismatched type")
+	}
+}
+
+func TestDBusAddress(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"unix:path=/run/user/1000/bus":           "unix /run/user/1000/bus",
+		"unix:abstract=/tmp/dbus-x,guid=1":       "unix @/tmp/dbus-x",
+		"tcp:host=localhost,port=1;unix:path=/x": "tcp localhost:1",
+		"unix:path=/a%20b":                       "unix /a b",
+	} {
+		n, a, err := parse_address(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := n + " " + a; actual != expected {
+			t.Fatalf("Incorrect parse of %s: %#v != %#v", addr, expected, actual)
+		}
+	}
+	if _, _, err := parse_address("launchd:env=X"); err == nil {
+		t.Fatalf("No error for unsupported address")
+	}
+}
+
+func TestDBusConn(t *testing.T) {
+	exe, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+	cmd := exec.Command(exe, "--session", "--nofork", "--print-address")
+	stdout, _ := cmd.StdoutPipe()
+	if err = cmd.Start(); err != nil {
+		t.Skip("Could not start dbus-daemon: ", err)
+	}
+	defer func() { _ = cmd.Process.Kill(); _ = cmd.Wait() }()
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = strings.TrimSpace(addr)
+	a, err := Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if a.UniqueName == "" || a.UniqueName == b.UniqueName {
+		t.Fatalf("Invalid unique names: %#v %#v", a.UniqueName, b.UniqueName)
+	}
+	reply, err := a.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "ListNames", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := Strings(reply[0]); !strings.Contains(strings.Join(names, " "), b.UniqueName) {
+		t.Fatalf("Unique name %s not in list of names: %v", b.UniqueName, names)
+	}
+	if _, err = a.GetProperty("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Features"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.Call("org.test.DoesNotExist", "/", "org.test", "X", "")
+	if e, ok := err.(*Error); !ok || e.Name != "org.freedesktop.DBus.Error.ServiceUnknown" {
+		t.Fatalf("Unexpected error for call to non-existent service: %#v", err)
+	}
+	received := make(chan []any, 1)
+	rule := "type='signal',interface='org.test.I'"
+	if err = a.AddMatch(rule, func(m *Message) {
+		if m.Member == "Changed" {
+			received <- m.Body
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Emit("/org/test", "org.test.I", "Changed", "sv", "key", MakeVariant("u", uint32(1))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-received:
+		if diff := cmp.Diff([]any{"key", MakeVariant("u", uint32(1))}, body); diff != "" {
+			t.Fatalf("Incorrect signal received:\n%s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for signal")
+	}
+	if err = b.AddMatch(rule, func(*Message) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.RemoveMatch(rule); err != nil || len(b.handlers) != 0 {
+		t.Fatalf("Failed to remove match rule: %v %d", err, len(b.handlers))
+	}
+	b.Close()
+	select {
+	case <-b.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the connection to be reported as closed")
+	}
+}
+
+// A fake bus that authenticates any client and replies to method calls with
+// the body returned by handler
+func fake_bus(t *testing.T, handler func(*Message) (Signature, []any)) string {
+	path := filepath.Join(t.TempDir(), "bus")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+				if _, err := conn.Write([]byte("OK 0123456789abcdef\r\n")); err != nil {
+					return
+				}
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+				for serial := uint32(1); ; serial++ {
+					m, err := read_message(r)
+					if err != nil {
+						return
+					}
+					reply := &Message{Type: MethodReturn, Serial: serial, ReplySerial: m.Serial, Destination: m.Sender}
+					if m.Member == "Hello" {
+						reply.Signature, reply.Body = "s", []any{":1.1"}
+					} else {
+						reply.Signature, reply.Body = handler(m)
+					}
+					data, err := reply.marshal()
+					if err != nil {
+						return
+					}
+					if _, err = conn.Write(data); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "unix:path=" + path
+}
+
+func TestDBusNotify(t *testing.T) {
+	var received []*Message
+	addr := fake_bus(t, func(m *Message) (Signature, []any) {
+		received = append(received, m)
+		return "u", []any{uint32(len(received))}
+	})
+	c, err := Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.UniqueName != ":1.1" {
+		t.Fatalf("Incorrect unique name: %#v", c.UniqueName)
+	}
+	for i, timeout := range []time.Duration{0, 3 * time.Second, -1} {
+		id, err := c.Notify("app", "title", "body", timeout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != uint32(i+1) {
+			t.Fatalf("Incorrect notification id: %d", id)
+		}
+	}
+	m := received[0]
+	if m.Destination != "org.freedesktop.Notifications" || m.Path != "/org/freedesktop/Notifications" || m.Member != "Notify" || m.Signature != "susssasa{sv}i" {
+		t.Fatalf("Unexpected method call: %#v", m)
+	}
+	if diff := cmp.Diff([]any{"app", uint32(0), "", "title", "body", []any{}, map[string]any{}, int32(-1)}, m.Body); diff != "" {
+		t.Fatalf("Incorrect arguments for Notify:\n%s", diff)
+	}
+	for i, expected := range []int32{-1, 3000, 0} {
+		if actual := received[i].Body[7]; actual != expected {
+			t.Fatalf("Incorrect expiry timeout: %#v != %#v", expected, actual)
+		}
+	}
+}
+
+func TestDBusPortalHelpers(t *testing.T) {
+	if p := request_path(":1.23", "tok"); p != "/org/freedesktop/portal/desktop/request/1_23/tok" {
+		t.Fatalf("Incorrect request path: %s", p)
+	}
+	paths, err := paths_from_uris([]string{"file:///a/b%20c", "file:///d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"/a/b c", "/d"}, paths); diff != "" {
+		t.Fatalf("Incorrect paths from URIs:\n%s", diff)
+	}
+	if _, err = paths_from_uris([]string{"https://x.com/a"}); err == nil {
+		t.Fatalf("No error for a non-file URI")
+	}
+}
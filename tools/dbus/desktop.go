@@ -0,0 +1,48 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package dbus
+
+import (
+	"fmt"
+	"time"
+)
+
+var _ = fmt.Print
+
+// Show a desktop notification via org.freedesktop.Notifications returning
+// its id. A timeout of zero means use the server default and a negative
+// timeout means never expire.
+func (self *Conn) Notify(app_name, title, body string, timeout time.Duration) (uint32, error) {
+	ms := int32(-1)
+	switch {
+	case timeout > 0:
+		ms = int32(timeout / time.Millisecond)
+	case timeout < 0:
+		ms = 0
+	}
+	reply, err := self.Call("org.freedesktop.Notifications", "/org/freedesktop/Notifications", "org.freedesktop.Notifications", "Notify",
+		"susssasa{sv}i", app_name, uint32(0), "", title, body, []string{}, map[string]Variant{}, ms)
+	if err != nil {
+		return 0, err
+	}
+	if len(reply) == 0 {
+		return 0, fmt.Errorf("No notification id returned by the notification server")
+	}
+	id, _ := reply[0].(uint32)
+	return id, nil
+}
+
+// Prevent the screensaver from activating till the returned function is
+// called or the connection is closed
+func (self *Conn) InhibitScreensaver(app_name, reason string) (uninhibit func(), err error) {
+	const dest, path = "org.freedesktop.ScreenSaver", "/org/freedesktop/ScreenSaver"
+	reply, err := self.Call(dest, path, dest, "Inhibit", "ss", app_name, reason)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) == 0 {
+		return nil, fmt.Errorf("No cookie returned by the screensaver for the inhibit request")
+	}
+	cookie, _ := reply[0].(uint32)
+	return func() { _, _ = self.Call(dest, path, dest, "UnInhibit", "u", cookie) }, nil
+}
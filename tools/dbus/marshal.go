@@ -0,0 +1,538 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package dbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type ObjectPath string
+type Signature string
+
+// A value of type v, whose type is specified at runtime by Sig
+type Variant struct {
+	Sig   Signature
+	Value any
+}
+
+func MakeVariant(sig Signature, val any) Variant { return Variant{sig, val} }
+
+// Remove all levels of variant wrapping from v
+func Unwrap(v any) any {
+	for {
+		vv, ok := v.(Variant)
+		if !ok {
+			return v
+		}
+		v = vv.Value
+	}
+}
+
+func alignment(t byte) int {
+	switch t {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 's', 'o', 'a', 'h':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// Split sig into its first complete type and the remainder
+func next_type(sig string) (first, rest string, err error) {
+	if sig == "" {
+		return "", "", fmt.Errorf("Empty D-Bus signature")
+	}
+	switch sig[0] {
+	case 'a':
+		if len(sig) > 1 && sig[1] == '{' {
+			// dict entries are only allowed as array elements and must have
+			// a basic type as key and a single value type
+			f, r, err := next_container_type(sig[1:], '}')
+			if err != nil {
+				return "", "", err
+			}
+			if kv, err := split_signature(f[1 : len(f)-1]); err != nil || len(kv) != 2 || !is_basic_type(kv[0]) {
+				return "", "", fmt.Errorf("Invalid dict entry in D-Bus signature: %s", sig)
+			}
+			return "a" + f, r, nil
+		}
+		f, r, err := next_type(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return "a" + f, r, nil
+	case '(':
+		f, r, err := next_container_type(sig, ')')
+		if err == nil && f == "()" {
+			err = fmt.Errorf("Empty struct in D-Bus signature: %s", sig)
+		}
+		return f, r, err
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return sig[:1], sig[1:], nil
+	}
+	return "", "", fmt.Errorf("Invalid character %#v in D-Bus signature: %s", sig[0], sig)
+}
+
+func next_container_type(sig string, closer byte) (first, rest string, err error) {
+	s := sig[1:]
+	for {
+		if s == "" {
+			return "", "", fmt.Errorf("Unterminated container in D-Bus signature: %s", sig)
+		}
+		if s[0] == closer {
+			n := len(sig) - len(s) + 1
+			return sig[:n], sig[n:], nil
+		}
+		if _, s, err = next_type(s); err != nil {
+			return "", "", err
+		}
+	}
+}
+
+func is_basic_type(t string) bool {
+	return len(t) == 1 && strings.IndexByte("ybnqiuxtdsogh", t[0]) > -1
+}
+
+func split_signature(sig string) (ans []string, err error) {
+	for sig != "" {
+		var t string
+		if t, sig, err = next_type(sig); err != nil {
+			return nil, err
+		}
+		ans = append(ans, t)
+	}
+	return
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (self *encoder) align(n int) {
+	for len(self.buf)%n != 0 {
+		self.buf = append(self.buf, 0)
+	}
+}
+
+func (self *encoder) u32(x uint32) {
+	self.align(4)
+	self.buf = binary.LittleEndian.AppendUint32(self.buf, x)
+}
+
+func (self *encoder) str(s string) {
+	self.u32(uint32(len(s)))
+	self.buf = append(self.buf, s...)
+	self.buf = append(self.buf, 0)
+}
+
+func (self *encoder) sig(s string) {
+	self.buf = append(self.buf, byte(len(s)))
+	self.buf = append(self.buf, s...)
+	self.buf = append(self.buf, 0)
+}
+
+func type_error(sig string, val any) error {
+	return fmt.Errorf("Cannot encode value of type %T as D-Bus type: %s", val, sig)
+}
+
+func (self *encoder) encode(sig string, val any) (err error) {
+	rv := reflect.ValueOf(val)
+	is := func(k ...reflect.Kind) bool {
+		for _, q := range k {
+			if rv.Kind() == q {
+				return true
+			}
+		}
+		return false
+	}
+	ints := []reflect.Kind{reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64}
+	uints := []reflect.Kind{reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64}
+	as_int := func() (int64, bool) {
+		if is(ints...) {
+			return rv.Int(), true
+		}
+		if is(uints...) {
+			return int64(rv.Uint()), true
+		}
+		return 0, false
+	}
+	switch sig[0] {
+	case 'y', 'n', 'q', 'i', 'u', 'x', 't', 'h':
+		x, ok := as_int()
+		if !ok {
+			return type_error(sig, val)
+		}
+		self.align(alignment(sig[0]))
+		switch sig[0] {
+		case 'y':
+			self.buf = append(self.buf, byte(x))
+		case 'n', 'q':
+			self.buf = binary.LittleEndian.AppendUint16(self.buf, uint16(x))
+		case 'i', 'u', 'h':
+			self.buf = binary.LittleEndian.AppendUint32(self.buf, uint32(x))
+		default:
+			self.buf = binary.LittleEndian.AppendUint64(self.buf, uint64(x))
+		}
+	case 'b':
+		if !is(reflect.Bool) {
+			return type_error(sig, val)
+		}
+		b := uint32(0)
+		if rv.Bool() {
+			b = 1
+		}
+		self.u32(b)
+	case 'd':
+		var x float64
+		if is(reflect.Float32, reflect.Float64) {
+			x = rv.Float()
+		} else if i, ok := as_int(); ok {
+			x = float64(i)
+		} else {
+			return type_error(sig, val)
+		}
+		self.align(8)
+		self.buf = binary.LittleEndian.AppendUint64(self.buf, math.Float64bits(x))
+	case 's', 'o':
+		if !is(reflect.String) {
+			return type_error(sig, val)
+		}
+		self.str(rv.String())
+	case 'g':
+		if !is(reflect.String) {
+			return type_error(sig, val)
+		}
+		self.sig(rv.String())
+	case 'v':
+		v, ok := val.(Variant)
+		if !ok {
+			return type_error(sig, val)
+		}
+		self.sig(string(v.Sig))
+		return self.encode(string(v.Sig), v.Value)
+	case '(':
+		if !is(reflect.Slice, reflect.Array) {
+			return type_error(sig, val)
+		}
+		fields, err := split_signature(sig[1 : len(sig)-1])
+		if err != nil {
+			return err
+		}
+		if rv.Len() != len(fields) {
+			return fmt.Errorf("The struct with signature %s needs %d fields not %d", sig, len(fields), rv.Len())
+		}
+		self.align(8)
+		for i, f := range fields {
+			if err = self.encode(f, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case 'a':
+		elem := sig[1:]
+		self.u32(0)
+		len_pos := len(self.buf) - 4
+		self.align(alignment(elem[0]))
+		start := len(self.buf)
+		if elem[0] == '{' {
+			if !is(reflect.Map) {
+				return type_error(sig, val)
+			}
+			kv, err := split_signature(elem[1 : len(elem)-1])
+			if err != nil {
+				return err
+			}
+			keys := rv.MapKeys()
+			// a stable order makes the output reproducible
+			sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface()) })
+			for _, k := range keys {
+				self.align(8)
+				if err = self.encode(kv[0], k.Interface()); err != nil {
+					return err
+				}
+				if err = self.encode(kv[1], rv.MapIndex(k).Interface()); err != nil {
+					return err
+				}
+			}
+		} else {
+			if val == nil {
+				break
+			}
+			if !is(reflect.Slice, reflect.Array) {
+				return type_error(sig, val)
+			}
+			for i := 0; i < rv.Len(); i++ {
+				if err = self.encode(elem, rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+		}
+		binary.LittleEndian.PutUint32(self.buf[len_pos:], uint32(len(self.buf)-start))
+	default:
+		return fmt.Errorf("Invalid D-Bus signature: %s", sig)
+	}
+	return nil
+}
+
+func (self *encoder) encode_all(sig string, vals ...any) error {
+	types, err := split_signature(sig)
+	if err != nil {
+		return err
+	}
+	if len(types) != len(vals) {
+		return fmt.Errorf("The signature %s needs %d values not %d", sig, len(types), len(vals))
+	}
+	for i, t := range types {
+		if err = self.encode(t, vals[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decoder for little and big endian data. Alignment is relative to the
+// start of data, which must be the start of a message.
+type decoder struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errTruncated = fmt.Errorf("Truncated D-Bus message")
+
+func (self *decoder) align(n int) error {
+	p := (self.pos + n - 1) / n * n
+	if p > len(self.data) {
+		return errTruncated
+	}
+	self.pos = p
+	return nil
+}
+
+func (self *decoder) read(n int) ([]byte, error) {
+	if self.pos+n > len(self.data) || n < 0 {
+		return nil, errTruncated
+	}
+	ans := self.data[self.pos : self.pos+n]
+	self.pos += n
+	return ans, nil
+}
+
+func (self *decoder) u32() (uint32, error) {
+	if err := self.align(4); err != nil {
+		return 0, err
+	}
+	b, err := self.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return self.order.Uint32(b), nil
+}
+
+func (self *decoder) str() (string, error) {
+	n, err := self.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := self.read(int(n) + 1)
+	if err != nil {
+		return "", err
+	}
+	return string(b[:n]), nil
+}
+
+func (self *decoder) sig() (string, error) {
+	b, err := self.read(1)
+	if err != nil {
+		return "", err
+	}
+	if b, err = self.read(int(b[0]) + 1); err != nil {
+		return "", err
+	}
+	return string(b[:len(b)-1]), nil
+}
+
+// Decode a value. Arrays are decoded as []any except for dicts, which are
+// decoded as map[string]any when the key is a string and map[any]any
+// otherwise. Structs are decoded as []any.
+func (self *decoder) decode(sig string, depth int) (ans any, err error) {
+	if depth > 64 {
+		return nil, fmt.Errorf("D-Bus message nested too deeply")
+	}
+	if err = self.align(alignment(sig[0])); err != nil {
+		return
+	}
+	fixed := func(n int) (b []byte, err error) { return self.read(n) }
+	var b []byte
+	switch sig[0] {
+	case 'y':
+		if b, err = fixed(1); err == nil {
+			ans = b[0]
+		}
+	case 'b':
+		if b, err = fixed(4); err == nil {
+			ans = self.order.Uint32(b) != 0
+		}
+	case 'n':
+		if b, err = fixed(2); err == nil {
+			ans = int16(self.order.Uint16(b))
+		}
+	case 'q':
+		if b, err = fixed(2); err == nil {
+			ans = self.order.Uint16(b)
+		}
+	case 'i':
+		if b, err = fixed(4); err == nil {
+			ans = int32(self.order.Uint32(b))
+		}
+	case 'u', 'h':
+		if b, err = fixed(4); err == nil {
+			ans = self.order.Uint32(b)
+		}
+	case 'x':
+		if b, err = fixed(8); err == nil {
+			ans = int64(self.order.Uint64(b))
+		}
+	case 't':
+		if b, err = fixed(8); err == nil {
+			ans = self.order.Uint64(b)
+		}
+	case 'd':
+		if b, err = fixed(8); err == nil {
+			ans = math.Float64frombits(self.order.Uint64(b))
+		}
+	case 's':
+		ans, err = self.str()
+	case 'o':
+		var s string
+		s, err = self.str()
+		ans = ObjectPath(s)
+	case 'g':
+		var s string
+		s, err = self.sig()
+		ans = Signature(s)
+	case 'v':
+		var s string
+		if s, err = self.sig(); err != nil {
+			return
+		}
+		if _, rest, serr := next_type(s); serr != nil || rest != "" {
+			return nil, fmt.Errorf("Invalid signature for variant: %s", s)
+		}
+		var v any
+		if v, err = self.decode(s, depth+1); err == nil {
+			ans = Variant{Signature(s), v}
+		}
+	case '(':
+		var fields []string
+		if fields, err = split_signature(sig[1 : len(sig)-1]); err != nil {
+			return
+		}
+		vals := make([]any, len(fields))
+		for i, f := range fields {
+			if vals[i], err = self.decode(f, depth+1); err != nil {
+				return
+			}
+		}
+		ans = vals
+	case 'a':
+		var n uint32
+		if n, err = self.u32(); err != nil {
+			return
+		}
+		elem := sig[1:]
+		if err = self.align(alignment(elem[0])); err != nil {
+			return
+		}
+		end := self.pos + int(n)
+		if end > len(self.data) {
+			return nil, errTruncated
+		}
+		if elem[0] == '{' {
+			var kv []string
+			if kv, err = split_signature(elem[1 : len(elem)-1]); err != nil {
+				return
+			}
+			string_keys := kv[0] == "s" || kv[0] == "o"
+			sm, am := map[string]any{}, map[any]any{}
+			for self.pos < end {
+				if err = self.align(8); err != nil {
+					return
+				}
+				var k, v any
+				if k, err = self.decode(kv[0], depth+1); err != nil {
+					return
+				}
+				if v, err = self.decode(kv[1], depth+1); err != nil {
+					return
+				}
+				if string_keys {
+					sm[fmt.Sprint(k)] = v
+				} else {
+					am[k] = v
+				}
+			}
+			if string_keys {
+				ans = sm
+			} else {
+				ans = am
+			}
+		} else {
+			vals := []any{}
+			for self.pos < end {
+				var v any
+				if v, err = self.decode(elem, depth+1); err != nil {
+					return
+				}
+				vals = append(vals, v)
+			}
+			ans = vals
+		}
+		if self.pos != end {
+			return nil, fmt.Errorf("D-Bus array has incorrect length")
+		}
+	default:
+		return nil, fmt.Errorf("Invalid D-Bus signature: %s", sig)
+	}
+	return
+}
+
+func (self *decoder) decode_all(sig string) (ans []any, err error) {
+	types, err := split_signature(sig)
+	if err != nil {
+		return nil, err
+	}
+	ans = make([]any, len(types))
+	for i, t := range types {
+		if ans[i], err = self.decode(t, 0); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Convert a []any decoded from an array of strings into []string
+func Strings(v any) (ans []string) {
+	if a, ok := Unwrap(v).([]any); ok {
+		for _, x := range a {
+			switch s := Unwrap(x).(type) {
+			case string:
+				ans = append(ans, s)
+			case ObjectPath:
+				ans = append(ans, string(s))
+			}
+		}
+	}
+	return
+}
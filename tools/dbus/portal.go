@@ -0,0 +1,149 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package dbus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var _ = fmt.Print
+
+var ErrCanceled = errors.New("Canceled by user")
+
+const (
+	portal_dest          = "org.freedesktop.portal.Desktop"
+	portal_path          = "/org/freedesktop/portal/desktop"
+	portal_request_iface = "org.freedesktop.portal.Request"
+)
+
+type FileChooserOptions struct {
+	// The title of the dialog
+	Title string
+	// The label for the accept button, defaults to the label chosen by the portal
+	AcceptLabel string
+	// The folder the dialog opens in, defaults to the folder chosen by the portal
+	CurrentFolder string
+	// An identifier for the window the dialog is for, such as x11:XID, can be empty
+	ParentWindow string
+	// Allow selecting multiple items
+	Multiple bool
+	// Select folders instead of files
+	Directory bool
+}
+
+// The object path of the request object that the portal will create for a
+// request made with the specified handle_token, see
+// https://flatpak.github.io/xdg-desktop-portal/docs/doc-org.freedesktop.portal.Request.html
+func request_path(unique_name, token string) ObjectPath {
+	sender := strings.ReplaceAll(strings.TrimPrefix(unique_name, ":"), ".", "_")
+	return ObjectPath(portal_path + "/request/" + sender + "/" + token)
+}
+
+func paths_from_uris(uris []string) (ans []string, err error) {
+	ans = make([]string, 0, len(uris))
+	for _, x := range uris {
+		u, err := url.Parse(x)
+		if err != nil {
+			return nil, fmt.Errorf("The file chooser returned an invalid URI: %s with error: %w", x, err)
+		}
+		if u.Scheme != "file" {
+			return nil, fmt.Errorf("The file chooser returned a non-file URI: %s", x)
+		}
+		ans = append(ans, u.Path)
+	}
+	return
+}
+
+// Ask the user to select files or folders with the file chooser from the XDG
+// Desktop Portal, returning their paths. This blocks till the user closes the
+// dialog, ctx is done or the connection is closed, returning ErrCanceled if
+// no selection was made.
+func (self *Conn) ChooseFiles(ctx context.Context, opts FileChooserOptions) (paths []string, err error) {
+	b := make([]byte, 8)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	token := "kitty_" + hex.EncodeToString(b)
+	responses := make(chan *Message, 4)
+	var rules []string
+	defer func() {
+		for _, rule := range rules {
+			_ = self.RemoveMatch(rule)
+		}
+	}()
+	match := func(path ObjectPath) error {
+		rule := fmt.Sprintf("type='signal',interface='%s',member='Response',path='%s'", portal_request_iface, path)
+		rules = append(rules, rule)
+		return self.AddMatch(rule, func(m *Message) {
+			if m.Interface == portal_request_iface && m.Member == "Response" {
+				select {
+				case responses <- m:
+				default:
+				}
+			}
+		})
+	}
+	// subscribe before making the call to avoid missing a fast response
+	handle := request_path(self.UniqueName, token)
+	if err = match(handle); err != nil {
+		return
+	}
+	options := map[string]Variant{
+		"handle_token": MakeVariant("s", token),
+		"modal":        MakeVariant("b", true),
+		"multiple":     MakeVariant("b", opts.Multiple),
+		"directory":    MakeVariant("b", opts.Directory),
+	}
+	if opts.AcceptLabel != "" {
+		options["accept_label"] = MakeVariant("s", opts.AcceptLabel)
+	}
+	if opts.CurrentFolder != "" {
+		options["current_folder"] = MakeVariant("ay", append([]byte(opts.CurrentFolder), 0))
+	}
+	reply, err := self.Call(portal_dest, portal_path, "org.freedesktop.portal.FileChooser", "OpenFile", "ssa{sv}", opts.ParentWindow, opts.Title, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) == 0 {
+		return nil, fmt.Errorf("No request handle returned by the file chooser portal")
+	}
+	if h, ok := reply[0].(ObjectPath); ok && h != handle {
+		// older portals ignore handle_token
+		handle = h
+		if err = match(handle); err != nil {
+			return
+		}
+	}
+	for {
+		var m *Message
+		select {
+		case m = <-responses:
+		case <-self.Done():
+			return nil, fmt.Errorf("The D-Bus connection was closed while waiting for the file chooser")
+		case <-ctx.Done():
+			_, _ = self.Call(portal_dest, handle, portal_request_iface, "Close", "")
+			return nil, ctx.Err()
+		}
+		if m.Path != handle || len(m.Body) < 2 {
+			continue
+		}
+		switch code, _ := m.Body[0].(uint32); code {
+		case 0:
+		case 1:
+			return nil, ErrCanceled
+		default:
+			return nil, fmt.Errorf("The file chooser portal failed with response code: %d", code)
+		}
+		results, _ := m.Body[1].(map[string]any)
+		if paths, err = paths_from_uris(Strings(results["uris"])); err == nil && len(paths) == 0 {
+			err = ErrCanceled
+		}
+		return
+	}
+}
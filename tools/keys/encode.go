@@ -0,0 +1,388 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package keys
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var _ = fmt.Print
+
+// The progressive enhancement flags of the kitty keyboard protocol
+type EnhancementFlags uint8
+
+const (
+	DisambiguateEscapeCodes    EnhancementFlags = 1
+	ReportEventTypes           EnhancementFlags = 2
+	ReportAlternateKeys        EnhancementFlags = 4
+	ReportAllKeysAsEscapeCodes EnhancementFlags = 8
+	ReportAssociatedText       EnhancementFlags = 16
+	AllEnhancements            EnhancementFlags = 31
+)
+
+// ctrl mapping {{{
+// start ctrl mapping (auto generated by gen-key-constants.py do not edit)
+var ctrl_mapping = map[rune]rune{32: 0, 47: 31, 48: 48, 49: 49, 50: 0, 51: 27, 52: 28, 53: 29, 54: 30, 55: 31, 56: 127, 57: 57, 63: 127, 64: 0, 91: 27, 92: 28, 93: 29, 94: 30, 95: 31, 97: 1, 98: 2, 99: 3, 100: 4, 101: 5, 102: 6, 103: 7, 104: 8, 105: 9, 106: 10, 107: 11, 108: 12, 109: 13, 110: 14, 111: 15, 112: 16, 113: 17, 114: 18, 115: 19, 116: 20, 117: 21, 118: 22, 119: 23, 120: 24, 121: 25, 122: 26, 126: 30}
+
+// end ctrl mapping
+// }}}
+
+func fkey(name string) rune { return rune(name_to_functional_number_map[name]) }
+
+func key_code(name string) rune {
+	if name == "" {
+		return 0
+	}
+	if fn, ok := name_to_functional_number_map[name]; ok {
+		return rune(fn)
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return r
+}
+
+func is_functional_key(key rune) bool {
+	return fkey("ESCAPE") <= key && key <= fkey("ISO_LEVEL5_SHIFT")
+}
+
+func is_modifier_key(key rune) bool {
+	if fkey("LEFT_SHIFT") <= key && key <= fkey("ISO_LEVEL5_SHIFT") {
+		return true
+	}
+	switch functional_key_number_to_name_map[int(key)] {
+	case "CAPS_LOCK", "SCROLL_LOCK", "NUM_LOCK":
+		return true
+	}
+	return false
+}
+
+func is_legacy_ascii_key(key rune) bool {
+	return ('a' <= key && key <= 'z') || ('0' <= key && key <= '9') || (key < utf8.RuneSelf && strings.ContainsRune("!@#$%^&*()`~-_=+[{]}\\|;:'\",<.>/? ", key))
+}
+
+func ctrled_key(key rune) rune {
+	if ans, ok := ctrl_mapping[key]; ok {
+		return ans
+	}
+	return key
+}
+
+func starts_with_ascii_control_char(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return r < 32 || r == 127
+}
+
+// Keypad keys generate the same codes as their normal counterparts unless
+// disambiguation is enabled
+func convert_kp_key_to_normal_key(key rune) rune {
+	name := functional_key_number_to_name_map[int(key)]
+	switch name {
+	case "KP_ENTER", "KP_HOME", "KP_END", "KP_INSERT", "KP_DELETE", "KP_PAGE_UP", "KP_PAGE_DOWN", "KP_UP", "KP_DOWN", "KP_LEFT", "KP_RIGHT":
+		return fkey(name[3:])
+	case "KP_DECIMAL":
+		return '.'
+	case "KP_DIVIDE":
+		return '/'
+	case "KP_MULTIPLY":
+		return '*'
+	case "KP_SUBTRACT":
+		return '-'
+	case "KP_ADD":
+		return '+'
+	case "KP_EQUAL":
+		return '='
+	}
+	if fkey("KP_0") <= key && key <= fkey("KP_9") {
+		return '0' + key - fkey("KP_0")
+	}
+	return key
+}
+
+// The number and trailer used for the specified key in CSI escape codes
+func csi_number_and_trailer(key_name string) (int, byte) {
+	key := csi_number_for_name(key_name)
+	trailer := byte('u')
+	if t, found := csi_number_to_letter_trailer_map[key]; found && key_name != "ENTER" {
+		trailer = t[0]
+		key = 1
+	}
+	if fn, found := name_to_functional_number_map[key_name]; found && tilde_trailers[fn] {
+		trailer = '~'
+	}
+	return key, trailer
+}
+
+type encoder struct {
+	key, shifted_key, alternate_key                                             rune
+	mods                                                                        KeyModifiers
+	action                                                                      int
+	text                                                                        string
+	cursor_key_mode, disambiguate, report_all_event_types, report_alternate_key bool
+	report_text, embed_text                                                     bool
+}
+
+type encoding_data struct {
+	key, shifted_key, alternate_key                 int
+	add_alternates, has_mods, add_actions, add_text bool
+	mods                                            KeyModifiers
+	text                                            string
+	action                                          int
+}
+
+func (self *encoder) encoding_data() *encoding_data {
+	ans := &encoding_data{
+		add_actions:    self.report_all_event_types && self.action != 0,
+		has_mods:       self.mods != 0,
+		add_alternates: self.report_alternate_key && ((self.shifted_key > 0 && self.mods&SHIFT != 0) || self.alternate_key > 0),
+		action:         self.action, key: int(self.key), mods: self.mods,
+		add_text: self.embed_text && self.text != "", text: self.text,
+	}
+	if ans.add_alternates {
+		if self.mods&SHIFT != 0 {
+			ans.shifted_key = int(self.shifted_key)
+		}
+		ans.alternate_key = int(self.alternate_key)
+	}
+	return ans
+}
+
+func (self *encoding_data) serialize(trailer byte) string {
+	second_field_not_empty := self.has_mods || self.add_actions
+	third_field_not_empty := self.add_text
+	ans := strings.Builder{}
+	ans.Grow(32)
+	ans.WriteString("\x1b[")
+	if self.key != 1 || self.add_alternates || second_field_not_empty || third_field_not_empty {
+		ans.WriteString(strconv.Itoa(self.key))
+	}
+	if self.add_alternates {
+		ans.WriteByte(':')
+		if self.shifted_key != 0 {
+			ans.WriteString(strconv.Itoa(self.shifted_key))
+		}
+		if self.alternate_key != 0 {
+			ans.WriteByte(':')
+			ans.WriteString(strconv.Itoa(self.alternate_key))
+		}
+	}
+	if second_field_not_empty || third_field_not_empty {
+		ans.WriteByte(';')
+		if second_field_not_empty {
+			ans.WriteString(strconv.Itoa(int(self.mods) + 1))
+		}
+		if self.add_actions {
+			ans.WriteByte(':')
+			ans.WriteString(strconv.Itoa(self.action + 1))
+		}
+	}
+	if third_field_not_empty {
+		sep := byte(';')
+		for _, ch := range self.text {
+			ans.WriteByte(sep)
+			ans.WriteString(strconv.Itoa(int(ch)))
+			sep = ':'
+		}
+	}
+	ans.WriteByte(trailer)
+	return ans.String()
+}
+
+func (self *encoder) legacy_functional_key_encoding_with_modifiers() string {
+	prefix := ""
+	if self.mods&ALT != 0 {
+		prefix = "\x1b"
+	}
+	switch functional_key_number_to_name_map[int(self.key)] {
+	case "ENTER":
+		return prefix + "\r"
+	case "ESCAPE":
+		return prefix + "\x1b"
+	case "BACKSPACE":
+		if self.mods&CTRL != 0 {
+			return prefix + "\x08"
+		}
+		return prefix + "\x7f"
+	case "TAB":
+		if self.mods&SHIFT != 0 {
+			return prefix + "\x1b[Z"
+		}
+		return prefix + "\t"
+	}
+	return ""
+}
+
+func (self *encoder) encode_function_key() string {
+	legacy_mode := !self.report_all_event_types && !self.disambiguate
+	name := functional_key_number_to_name_map[int(self.key)]
+	if self.cursor_key_mode && legacy_mode && self.mods == 0 {
+		switch name {
+		case "UP":
+			return "\x1bOA"
+		case "DOWN":
+			return "\x1bOB"
+		case "RIGHT":
+			return "\x1bOC"
+		case "LEFT":
+			return "\x1bOD"
+		case "KP_BEGIN":
+			return "\x1bOE"
+		case "END":
+			return "\x1bOF"
+		case "HOME":
+			return "\x1bOH"
+		}
+	}
+	if self.mods == 0 {
+		if !self.disambiguate && !self.report_text && name == "ESCAPE" {
+			return "\x1b"
+		}
+		if legacy_mode {
+			switch name {
+			case "F1":
+				return "\x1bOP"
+			case "F2":
+				return "\x1bOQ"
+			case "F3":
+				return "\x1bOR"
+			case "F4":
+				return "\x1bOS"
+			}
+		}
+		if !self.report_text {
+			q := ""
+			switch name {
+			case "ENTER":
+				q = "\r"
+			case "BACKSPACE":
+				q = "\x7f"
+			case "TAB":
+				q = "\t"
+			}
+			if q != "" {
+				if self.action == 2 {
+					return ""
+				}
+				return q
+			}
+		}
+	} else if legacy_mode {
+		if ans := self.legacy_functional_key_encoding_with_modifiers(); ans != "" {
+			return ans
+		}
+	}
+	key, trailer := csi_number_and_trailer(name)
+	if name == "MENU" && legacy_mode {
+		// use the same encoding as xterm for this key in legacy mode (F16)
+		key, trailer = 29, '~'
+	}
+	ed := self.encoding_data()
+	ed.key = key
+	ed.add_alternates = false
+	return ed.serialize(trailer)
+}
+
+func (self *encoder) encode_printable_ascii_key_legacy() string {
+	mods := self.mods
+	if mods == 0 {
+		return string(self.key)
+	}
+	key := self.key
+	if mods&SHIFT != 0 {
+		if shifted := self.shifted_key; shifted != 0 && shifted != key && (mods&CTRL == 0 || key < 'a' || key > 'z') {
+			key = shifted
+			mods &^= SHIFT
+		}
+	}
+	switch {
+	case self.mods == SHIFT:
+		return string(key)
+	case mods == ALT:
+		return "\x1b" + string(key)
+	case mods == CTRL:
+		return string(ctrled_key(key))
+	case mods == CTRL|ALT:
+		return "\x1b" + string(ctrled_key(key))
+	case key == ' ' && mods == CTRL|SHIFT:
+		return string(ctrled_key(key))
+	case key == ' ' && mods == ALT|SHIFT:
+		return "\x1b" + string(key)
+	}
+	return ""
+}
+
+func (self *encoder) encode_key() string {
+	if !self.report_all_event_types && self.action == 2 {
+		return ""
+	}
+	if is_functional_key(self.key) {
+		return self.encode_function_key()
+	}
+	ed := self.encoding_data()
+	if simple_encoding_ok := !ed.add_actions && !ed.add_alternates && !ed.add_text; simple_encoding_ok {
+		if !ed.has_mods {
+			if self.report_text {
+				return ed.serialize('u')
+			}
+			return string(self.key)
+		}
+		if !self.disambiguate && !self.report_text {
+			if is_legacy_ascii_key(self.key) || (self.shifted_key != 0 && is_legacy_ascii_key(self.shifted_key)) {
+				if ans := self.encode_printable_ascii_key_legacy(); ans != "" {
+					return ans
+				}
+			}
+			if mods := self.mods; (mods == CTRL || mods == ALT || mods == CTRL|ALT) && self.alternate_key != 0 && !is_legacy_ascii_key(self.key) && is_legacy_ascii_key(self.alternate_key) {
+				alternate := *self
+				alternate.key, alternate.alternate_key, alternate.shifted_key = self.alternate_key, 0, 0
+				if ans := alternate.encode_printable_ascii_key_legacy(); ans != "" {
+					return ans
+				}
+			}
+		}
+	}
+	return ed.serialize('u')
+}
+
+// Encode the key event as it would be sent by a terminal to a program that has
+// enabled the specified keyboard enhancement flags. cursor_key_mode is
+// whether the DECCKM mode is set, which changes the encoding of the arrow
+// keys in legacy mode. The legacy encodings of shifted keys depend on
+// ShiftedKey being set. Returns the empty string for key events that are
+// not reported with the specified flags.
+func (self *KeyEvent) Encode(flags EnhancementFlags, cursor_key_mode bool) string {
+	ev := encoder{
+		key: key_code(self.Key), shifted_key: key_code(self.ShiftedKey), alternate_key: key_code(self.AlternateKey),
+		text: self.Text, cursor_key_mode: cursor_key_mode,
+		disambiguate:           flags&DisambiguateEscapeCodes != 0,
+		report_all_event_types: flags&ReportEventTypes != 0,
+		report_alternate_key:   flags&ReportAlternateKeys != 0,
+		report_text:            flags&ReportAllKeysAsEscapeCodes != 0,
+		embed_text:             flags&ReportAssociatedText != 0,
+	}
+	if !ev.report_text && is_modifier_key(ev.key) {
+		return ""
+	}
+	has_text := self.Text != "" && !starts_with_ascii_control_char(self.Text)
+	if ev.key == 0 && !has_text {
+		return ""
+	}
+	if !ev.disambiguate && fkey("KP_0") <= ev.key && ev.key <= fkey("KP_BEGIN") {
+		ev.key = convert_kp_key_to_normal_key(ev.key)
+	}
+	switch self.Type {
+	case REPEAT:
+		ev.action = 1
+	case RELEASE:
+		ev.action = 2
+	}
+	if send_text_standalone := !ev.report_text; send_text_standalone && has_text && ev.action != 2 {
+		return self.Text
+	}
+	ev.mods = self.Mods
+	if flags == 0 {
+		ev.mods &^= CAPS_LOCK | NUM_LOCK
+	}
+	return ev.encode_key()
+}
@@ -0,0 +1,209 @@
+// License: GPLv3 Copyright: 2023, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package keys
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestKeyEventFromCSI(t *testing.T) {
+
+	test_text := func(csi string, expected, alternate string) {
+		ev := KeyEventFromCSI(csi)
+		if ev == nil {
+			t.Fatalf("Failed to get parse %#v", csi)
+		}
+		if diff := cmp.Diff(expected, ev.Text); diff != "" {
+			t.Fatalf("Failed to get text from %#v:\n%s", csi, diff)
+		}
+		if diff := cmp.Diff(alternate, ev.AlternateKey); diff != "" {
+			t.Fatalf("Failed to get alternate from %#v:\n%s", csi, diff)
+		}
+	}
+	test_text("121;;121u", "y", "")
+	test_text("121::122;;121u", "y", "z")
+}
+
+// The implementations from before decoding was changed to not allocate, used
+// to check that decoding is unchanged
+func legacy_key_event_from_csi(csi string) *KeyEvent {
+	if len(csi) == 0 {
+		return nil
+	}
+	orig_csi := csi
+	last_char := csi[len(csi)-1:]
+	if !strings.Contains("u~ABCDEHFPQRS", last_char) || (last_char == "~" && (csi == "200~" || csi == "201~")) {
+		return nil
+	}
+	csi = csi[:len(csi)-1]
+	sections := strings.Split(csi, ";")
+
+	get_sub_sections := func(section string, missing int) []int {
+		p := strings.Split(section, ":")
+		ans := make([]int, len(p))
+		for i, x := range p {
+			if x == "" {
+				ans[i] = missing
+			} else {
+				q, err := strconv.Atoi(x)
+				if err != nil {
+					return nil
+				}
+				ans[i] = q
+			}
+		}
+		return ans
+	}
+	first_section := get_sub_sections(sections[0], 0)
+	second_section := []int{}
+	third_section := []int{}
+	if len(sections) > 1 {
+		second_section = get_sub_sections(sections[1], 1)
+	}
+	if len(sections) > 2 {
+		third_section = get_sub_sections(sections[2], 0)
+	}
+	var ans = KeyEvent{Type: PRESS, CSI: orig_csi}
+	var keynum int
+	if val, ok := letter_trailer_to_csi_number_map[last_char]; ok {
+		keynum = val
+	} else {
+		if len(first_section) == 0 {
+			return nil
+		}
+		keynum = first_section[0]
+	}
+
+	key_name := func(keynum int) string {
+		switch keynum {
+		case 0:
+			return ""
+		case 13:
+			if last_char == "u" {
+				return "ENTER"
+			}
+			return "F3"
+		default:
+			if val, ok := csi_number_to_functional_number_map[keynum]; ok {
+				keynum = val
+			}
+			ans := ""
+			if val, ok := functional_key_number_to_name_map[keynum]; ok {
+				ans = val
+			} else {
+				ans = string(rune(keynum))
+			}
+			return ans
+		}
+	}
+
+	ans.Key = key_name(keynum)
+	if len(first_section) > 1 {
+		ans.ShiftedKey = key_name(first_section[1])
+	}
+	if len(first_section) > 2 {
+		ans.AlternateKey = key_name(first_section[2])
+	}
+	if len(second_section) > 0 {
+		ans.Mods = KeyModifiers(second_section[0] - 1)
+	}
+	if len(second_section) > 1 {
+		switch second_section[1] {
+		case 2:
+			ans.Type = REPEAT
+		case 3:
+			ans.Type = RELEASE
+		}
+	}
+	if len(third_section) > 0 {
+		runes := make([]rune, len(third_section))
+		for i, ch := range third_section {
+			runes[i] = rune(ch)
+		}
+		ans.Text = string(runes)
+	}
+	return &ans
+}
+
+func FuzzKeyEventFromCSI(f *testing.F) {
+	for _, seed := range []string{
+		"121;;121u", "121::122;;121u", "97;5u", "1;2A", "15~", "200~", "97:65;2:3;65:66u", "1;1:1S", "13u", "13~", "x1;2u",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, csi string) {
+		if diff := cmp.Diff(legacy_key_event_from_csi(csi), KeyEventFromCSI(csi)); diff != "" {
+			t.Fatalf("Decoding key event from %#v changed:\n%s", csi, diff)
+		}
+	})
+}
+
+func TestKeyEventEncode(t *testing.T) {
+	const legacy, disambiguate = EnhancementFlags(0), DisambiguateEscapeCodes
+	press := func(key string, mods KeyModifiers) *KeyEvent { return &KeyEvent{Type: PRESS, Key: key, Mods: mods} }
+	shifted := func(key, shifted_key string, mods KeyModifiers) *KeyEvent {
+		return &KeyEvent{Type: PRESS, Key: key, ShiftedKey: shifted_key, Mods: mods}
+	}
+	for i, q := range []struct {
+		ev       *KeyEvent
+		flags    EnhancementFlags
+		expected string
+	}{
+		{&KeyEvent{Type: PRESS, Key: "a", Text: "a"}, legacy, "a"},
+		{press("a", CTRL), legacy, "\x01"},
+		{press("a", ALT), legacy, "\x1ba"},
+		{press("a", CTRL|ALT), legacy, "\x1b\x01"},
+		{shifted("a", "A", SHIFT), legacy, "A"},
+		{press(" ", CTRL), legacy, "\x00"},
+		{press("a", CTRL|CAPS_LOCK), legacy, "\x01"},
+		{&KeyEvent{Type: RELEASE, Key: "a"}, legacy, ""},
+		{press("ENTER", 0), legacy, "\r"},
+		{press("TAB", SHIFT), legacy, "\x1b[Z"},
+		{press("BACKSPACE", ALT), legacy, "\x1b\x7f"},
+		{press("BACKSPACE", CTRL), legacy, "\x08"},
+		{press("ESCAPE", 0), legacy, "\x1b"},
+		{press("UP", 0), legacy, "\x1b[A"},
+		{press("UP", SHIFT), legacy, "\x1b[1;2A"},
+		{press("F1", 0), legacy, "\x1bOP"},
+		{press("F1", CTRL), legacy, "\x1b[1;5P"},
+		{press("F3", SHIFT), legacy, "\x1b[13;2~"},
+		{press("F5", 0), legacy, "\x1b[15~"},
+		{press("KP_0", 0), legacy, "0"},
+		{press("KP_ENTER", 0), legacy, "\r"},
+		{press("MENU", 0), legacy, "\x1b[29~"},
+		{press("LEFT_SHIFT", SHIFT), legacy, ""},
+		{press("a", CTRL), disambiguate, "\x1b[97;5u"},
+		{press("ESCAPE", 0), disambiguate, "\x1b[27u"},
+		{press("ENTER", 0), disambiguate, "\r"},
+		{press("KP_0", 0), disambiguate, "\x1b[57399u"},
+		{press("MENU", 0), disambiguate, "\x1b[57363u"},
+		{press("F1", 0), disambiguate, "\x1b[P"},
+		{&KeyEvent{Type: RELEASE, Key: "a"}, disambiguate | ReportEventTypes, "\x1b[97;1:3u"},
+		{&KeyEvent{Type: REPEAT, Key: "UP"}, disambiguate | ReportEventTypes, "\x1b[1;1:2A"},
+		{shifted("a", "A", SHIFT), disambiguate | ReportAlternateKeys, "\x1b[97:65;2u"},
+		{&KeyEvent{Type: PRESS, Key: "a", Text: "a"}, ReportAllKeysAsEscapeCodes, "\x1b[97u"},
+		{&KeyEvent{Type: PRESS, Key: "a", Text: "a"}, ReportAllKeysAsEscapeCodes | ReportAssociatedText, "\x1b[97;;97u"},
+		{press("ENTER", 0), ReportAllKeysAsEscapeCodes, "\x1b[13u"},
+		{press("LEFT_SHIFT", SHIFT), ReportAllKeysAsEscapeCodes, "\x1b[57441;2u"},
+	} {
+		if actual := q.ev.Encode(q.flags, false); actual != q.expected {
+			t.Fatalf("%d: Incorrect encoding of %s with flags %d: %#v != %#v", i, q.ev, q.flags, q.expected, actual)
+		}
+	}
+	if actual := press("UP", 0).Encode(legacy, true); actual != "\x1bOA" {
+		t.Fatalf("Incorrect encoding in cursor key mode: %#v", actual)
+	}
+	ev := &KeyEvent{Type: REPEAT, Key: "a", ShiftedKey: "A", AlternateKey: "b", Mods: SHIFT | CTRL, Text: "A"}
+	csi := ev.Encode(AllEnhancements, false)
+	ev.CSI = csi[2:]
+	if diff := cmp.Diff(ev, KeyEventFromCSI(csi[2:])); diff != "" {
+		t.Fatalf("Encoding did not round trip through %#v:\n%s", csi, diff)
+	}
+}
@@ -0,0 +1,502 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+// Package rc is a client for the kitty remote control protocol, for use by Go
+// programs that want to control kitty without running kitten @ for every
+// command. Create a Client and call the method for the command you want to
+// run, for example:
+//
+//	c, err := rc.NewClient("")
+//	if err != nil {
+//		return err
+//	}
+//	r, err := c.Ls(&rc.LsPayload{})
+//	if err != nil {
+//		return err
+//	}
+//	var windows []any
+//	err = r.Data.Unmarshal(&windows)
+//
+// kitty must be configured to allow remote control over a socket, see
+// https://sw.kovidgoyal.net/kitty/remote-control/
+package rc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"kitty"
+	"kitty/tools/config"
+	"kitty/tools/crypto"
+	"kitty/tools/utils"
+	"kitty/tools/utils/base85"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+var ProtocolVersion [3]int = [3]int{0, 26, 0}
+
+const cmd_escape_code_prefix = "\x1bP@kitty-cmd"
+const cmd_escape_code_suffix = "\x1b\\"
+
+// The default time to wait for a response from kitty, used when neither the
+// client nor the command specify a timeout
+const DefaultTimeout = 10 * time.Second
+
+// When kitty is asked for permission to run a command using password, it may
+// need to ask the user so wait longer
+const password_timeout = 120 * time.Second
+
+// Ask kitty to send streaming data in chunks of this size
+const stream_chunk_size = 2048
+
+type Client struct {
+	// The address of the kitty socket, as used in listen_on for example: unix:/tmp/mykitty
+	Address string
+	// The password to use for commands, sent encrypted with the public key
+	Password string
+	// The public key of kitty used to encrypt the password, defaults to the
+	// value of the KITTY_PUBLIC_KEY environment variable
+	PublicKey string
+	// Time to wait for a response from kitty. When zero, the default timeout
+	// for each command is used.
+	Timeout time.Duration
+	// The id of the kitty window the commands are considered to be run in, used to
+	// resolve matching by state:self. Defaults to the value of the
+	// KITTY_WINDOW_ID environment variable.
+	WindowId uint
+	// Responses to commands that only query kitty, such as ls or get-colors,
+	// are re-used for this long when the same command is run again, zero
+	// means responses are never re-used. The re-used responses are shared
+	// and must not be modified. Must be set before the client is used.
+	CacheResponsesFor time.Duration
+
+	network, address string
+	cache_once       sync.Once
+	cache            *utils.LRU[string, *Response]
+}
+
+// Commands whose responses can be re-used as they do not change anything
+var query_commands = utils.NewSetWithItems("ls", "get-colors", "get-text")
+
+// The key for caching the response to cmd, empty if it must not be cached
+func (self *Client) cache_key(cmd *command) string {
+	if self.CacheResponsesFor <= 0 || cmd.data != nil || !query_commands.Has(cmd.name) {
+		return ""
+	}
+	payload, err := json.Marshal(cmd.payload)
+	if err != nil {
+		return ""
+	}
+	self.cache_once.Do(func() { self.cache = utils.NewLRU[string, *Response](64) })
+	return fmt.Sprintf("%s:%d:%s", cmd.name, self.WindowId, payload)
+}
+
+// The socket kitty listens on as specified by listen_on in kitty.conf. As
+// kitty adds its PID to the path of UNIX sockets, the most recently created
+// socket matching the path is used.
+func address_from_listen_on(listen_on string, getenv func(string) string) (string, error) {
+	listen_on = os.Expand(listen_on, getenv)
+	path, is_unix := strings.CutPrefix(listen_on, "unix:")
+	if !is_unix || strings.HasPrefix(path, "@") {
+		if strings.Contains(listen_on, "{kitty_pid}") || is_unix || strings.HasPrefix(listen_on, "tcp") {
+			return "", fmt.Errorf("The address kitty listens on cannot be determined from listen_on %#v in kitty.conf, specify it explicitly", listen_on)
+		}
+		return listen_on, nil
+	}
+	if strings.HasPrefix(path, "~") {
+		path = utils.Expanduser(path)
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(os.TempDir(), path)
+	}
+	pattern := utils.IfElse(strings.Contains(path, "{kitty_pid}"), strings.ReplaceAll(path, "{kitty_pid}", "[0-9]*"), path+"-[0-9]*")
+	matches, _ := filepath.Glob(pattern)
+	ans, newest := "", time.Time{}
+	for _, x := range matches {
+		if st, err := os.Stat(x); err == nil && st.Mode().Type() == fs.ModeSocket && st.ModTime().After(newest) {
+			ans, newest = x, st.ModTime()
+		}
+	}
+	if ans == "" {
+		return "", fmt.Errorf("No kitty socket matching listen_on %#v in kitty.conf found, is kitty running?", listen_on)
+	}
+	return "unix:" + ans, nil
+}
+
+func listen_on_from_config(path string) (ans string) {
+	cp := config.ConfigParser{LineHandler: func(key, val string) error {
+		if key == "listen_on" {
+			ans = utils.IfElse(val == "none", "", val)
+		}
+		return nil
+	}}
+	_ = cp.ParseFiles(path)
+	return
+}
+
+func resolve_address(address string, getenv func(string) string, kitty_conf string) (string, error) {
+	if address != "" {
+		return address, nil
+	}
+	if address = getenv("KITTY_LISTEN_ON"); address != "" {
+		return address, nil
+	}
+	if listen_on := listen_on_from_config(kitty_conf); listen_on != "" {
+		return address_from_listen_on(listen_on, getenv)
+	}
+	return "", fmt.Errorf("No address for the kitty socket specified, the KITTY_LISTEN_ON environment variable is not set and listen_on is not set in kitty.conf")
+}
+
+// Create a client to control the kitty instance listening at the specified
+// address, in the same format as the --to option of kitten @. When empty,
+// the value of the KITTY_LISTEN_ON environment variable is used, which is
+// set by kitty in the windows it creates, and failing that the socket
+// specified by listen_on in kitty.conf.
+func NewClient(address string) (ans *Client, err error) {
+	if address, err = resolve_address(address, os.Getenv, filepath.Join(utils.ConfigDir(), "kitty.conf")); err != nil {
+		return nil, err
+	}
+	ans = &Client{Address: address}
+	if ans.network, ans.address, err = utils.ParseSocketAddress(address); err != nil {
+		return nil, err
+	}
+	if wid, err := strconv.Atoi(os.Getenv("KITTY_WINDOW_ID")); err == nil && wid > 0 {
+		ans.WindowId = uint(wid)
+	}
+	return
+}
+
+// Parse the public key of kitty in the format of the KITTY_PUBLIC_KEY
+// environment variable
+func ParsePublicKey(encoded_key string) (encryption_version string, pubkey []byte, err error) {
+	encryption_version, encoded_key, found := strings.Cut(encoded_key, ":")
+	if !found {
+		err = fmt.Errorf("KITTY_PUBLIC_KEY environment variable does not have a : in it")
+		return
+	}
+	if encryption_version != kitty.RC_ENCRYPTION_PROTOCOL_VERSION {
+		err = fmt.Errorf("KITTY_PUBLIC_KEY has unknown version, if you are running on a remote system, update kitty on this system")
+		return
+	}
+	pubkey = make([]byte, base85.DecodedLen(len(encoded_key)))
+	n, err := base85.Decode(pubkey, []byte(encoded_key))
+	if err == nil {
+		pubkey = pubkey[:n]
+	}
+	return
+}
+
+// The error returned when kitty fails to run a command
+type Error struct {
+	Message, Traceback string
+}
+
+func (self *Error) Error() string { return self.Message }
+
+// The data returned by kitty for a command. Most commands that return data
+// return it as a string, commands such as ls return JSON serialized as a
+// string.
+type ResponseData struct {
+	// The data as a string, or serialized as JSON if kitty did not return a string
+	Text string
+	// Whether kitty returned a string
+	IsString bool
+	// Whether kitty returned any data
+	Present bool
+}
+
+func (self *ResponseData) UnmarshalJSON(data []byte) error {
+	self.Present = !bytes.Equal(data, []byte("null"))
+	if bytes.HasPrefix(data, []byte("\"")) {
+		self.IsString = true
+		return json.Unmarshal(data, &self.Text)
+	}
+	self.Text = string(data)
+	return nil
+}
+
+// Unmarshal the JSON data into dest
+func (self *ResponseData) Unmarshal(dest any) error {
+	return json.Unmarshal(utils.UnsafeStringToBytes(self.Text), dest)
+}
+
+type Response struct {
+	Ok        bool         `json:"ok"`
+	Data      ResponseData `json:"data,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Traceback string       `json:"tb,omitempty"`
+	Stream    bool         `json:"stream,omitempty"`
+}
+
+// The data in the response as a string
+func (self *Response) String() string { return self.Data.Text }
+
+type command struct {
+	name                   string
+	no_response, async     bool
+	string_response_is_err bool
+	timeout                time.Duration
+	payload                any
+	// for commands that stream data, called to set the data in the payload for every chunk
+	set_data func(string)
+	data     io.Reader
+}
+
+// JSON is transmitted inside an escape code so escape all non-ASCII characters
+func ascii_json(data []byte) []byte {
+	if bytes.IndexFunc(data, func(r rune) bool { return r >= utf8.RuneSelf }) < 0 {
+		return data
+	}
+	ans := make([]byte, 0, len(data)+64)
+	for _, r := range string(data) {
+		switch {
+		case r < utf8.RuneSelf:
+			ans = append(ans, byte(r))
+		case r > 0xffff:
+			r1, r2 := utf16_surrogates(r)
+			ans = fmt.Appendf(ans, `\u%04x\u%04x`, r1, r2)
+		default:
+			ans = fmt.Appendf(ans, `\u%04x`, r)
+		}
+	}
+	return ans
+}
+
+func utf16_surrogates(r rune) (rune, rune) {
+	r -= 0x10000
+	return 0xd800 + (r>>10)&0x3ff, 0xdc00 + r&0x3ff
+}
+
+func (self *Client) serializer() (func(*utils.RemoteControlCmd) ([]byte, error), error) {
+	if self.Password == "" {
+		return func(rc *utils.RemoteControlCmd) (ans []byte, err error) {
+			if ans, err = json.Marshal(rc); err == nil {
+				ans = ascii_json(ans)
+			}
+			return
+		}, nil
+	}
+	encoded_key := self.PublicKey
+	if encoded_key == "" {
+		if encoded_key = os.Getenv("KITTY_PUBLIC_KEY"); encoded_key == "" {
+			return nil, fmt.Errorf("Password usage requested but KITTY_PUBLIC_KEY environment variable is not available")
+		}
+	}
+	encryption_version, pubkey, err := ParsePublicKey(encoded_key)
+	if err != nil {
+		return nil, err
+	}
+	return func(rc *utils.RemoteControlCmd) (ans []byte, err error) {
+		ec, err := crypto.Encrypt_cmd(rc, self.Password, pubkey, encryption_version)
+		if err != nil {
+			return
+		}
+		return json.Marshal(ec)
+	}, nil
+}
+
+func (self *Client) connect() (conn net.Conn, err error) {
+	if self.network == "" {
+		if self.network, self.address, err = utils.ParseSocketAddress(self.Address); err != nil {
+			return nil, err
+		}
+	}
+	if self.network == "fd" {
+		fd, _ := strconv.Atoi(self.address)
+		f := os.NewFile(uintptr(fd), "fd:"+self.address)
+		defer f.Close()
+		return net.FileConn(f)
+	}
+	return net.Dial(self.network, self.address)
+}
+
+type connection struct {
+	conn    net.Conn
+	timeout time.Duration
+	buf     []byte
+	parser  wcswidth.EscapeCodeParser
+	pending [][]byte
+}
+
+func new_connection(conn net.Conn, timeout time.Duration) *connection {
+	ans := connection{conn: conn, timeout: timeout, buf: make([]byte, utils.DEFAULT_IO_BUFFER_SIZE)}
+	ans.parser.HandleDCS = func(data []byte) error {
+		if bytes.HasPrefix(data, []byte("@kitty-cmd")) {
+			ans.pending = append(ans.pending, bytes.Clone(data[len("@kitty-cmd"):]))
+		}
+		return nil
+	}
+	return &ans
+}
+
+func (self *connection) write(data []byte) (err error) {
+	if err = self.conn.SetWriteDeadline(time.Now().Add(self.timeout)); err != nil {
+		return
+	}
+	for _, x := range [][]byte{[]byte(cmd_escape_code_prefix), data, []byte(cmd_escape_code_suffix)} {
+		if _, err = self.conn.Write(x); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (self *connection) read() (*Response, error) {
+	for len(self.pending) == 0 {
+		if err := self.conn.SetReadDeadline(time.Now().Add(self.timeout)); err != nil {
+			return nil, err
+		}
+		n, err := self.conn.Read(self.buf)
+		if n > 0 {
+			self.parser.Parse(self.buf[:n])
+		}
+		if err != nil && len(self.pending) == 0 {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("kitty closed the connection without sending a response")
+			}
+			return nil, err
+		}
+	}
+	data := self.pending[0]
+	self.pending = self.pending[1:]
+	if len(data) == 0 {
+		return nil, fmt.Errorf("Received empty response from kitty")
+	}
+	ans := Response{}
+	if err := json.Unmarshal(data, &ans); err != nil {
+		return nil, fmt.Errorf("Invalid response received from kitty, unmarshalling error: %w", err)
+	}
+	return &ans, nil
+}
+
+func (self *Client) run(cmd *command) (ans *Response, err error) {
+	cache_key := self.cache_key(cmd)
+	if cache_key != "" {
+		if ans, found := self.cache.Get(cache_key); found {
+			return ans, nil
+		}
+	}
+	serialize, err := self.serializer()
+	if err != nil {
+		return nil, err
+	}
+	rc := utils.RemoteControlCmd{Cmd: cmd.name, Version: ProtocolVersion, NoResponse: cmd.no_response, KittyWindowId: self.WindowId, Payload: cmd.payload}
+	timeout := utils.IfElse(self.Timeout > 0, self.Timeout, utils.IfElse(cmd.timeout > 0, cmd.timeout, DefaultTimeout))
+	if self.Password != "" && self.Timeout == 0 {
+		timeout = max(timeout, password_timeout)
+	}
+	if cmd.async {
+		if rc.Async, err = utils.HumanRandomId(128); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := self.connect()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to kitty at %s with error: %w", self.Address, err)
+	}
+	defer conn.Close()
+	c := new_connection(conn, timeout)
+	send := func() error {
+		data, err := serialize(&rc)
+		if err == nil {
+			err = c.write(data)
+		}
+		return err
+	}
+	if cmd.data != nil {
+		if ans, err = self.stream(cmd, &rc, c, send); err != nil || ans != nil {
+			return
+		}
+	} else if err = send(); err != nil {
+		return nil, err
+	}
+	if rc.NoResponse {
+		return &Response{Ok: true}, nil
+	}
+	if ans, err = c.read(); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if rc.Async != "" {
+				rc.Payload, rc.CancelAsync, rc.NoResponse = nil, true, true
+				_ = send()
+			}
+			err = fmt.Errorf("Timed out waiting for a response from kitty")
+		}
+		return nil, err
+	}
+	if !ans.Ok {
+		return ans, &Error{Message: ans.Error, Traceback: ans.Traceback}
+	}
+	if cmd.string_response_is_err && ans.Data.IsString {
+		return ans, &Error{Message: ans.String()}
+	}
+	if cache_key != "" {
+		self.cache.SetWithTTL(cache_key, ans, self.CacheResponsesFor)
+	}
+	return ans, nil
+}
+
+// Send the data in chunks, kitty responds to the first chunk when it is ready
+// to receive the rest. Returns a non-nil response only if kitty sent the
+// final response early.
+func (self *Client) stream(cmd *command, rc *utils.RemoteControlCmd, c *connection, send func() error) (*Response, error) {
+	var err error
+	if rc.StreamId, err = utils.HumanRandomId(128); err != nil {
+		return nil, err
+	}
+	rc.Stream = true
+	buf := make([]byte, stream_chunk_size)
+	for {
+		n, rerr := io.ReadFull(cmd.data, buf)
+		if rerr != nil && !errors.Is(rerr, io.EOF) && !errors.Is(rerr, io.ErrUnexpectedEOF) {
+			return nil, rerr
+		}
+		// an empty chunk indicates the end of the data
+		cmd.set_data(base64.StdEncoding.EncodeToString(buf[:n]))
+		if err = send(); err != nil {
+			return nil, err
+		}
+		if rc.Stream {
+			rc.Stream = false
+			r, err := c.read()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case !r.Ok:
+				return r, &Error{Message: r.Error, Traceback: r.Traceback}
+			case n == 0 && !r.Stream:
+				return r, nil
+			case !r.Stream:
+				return nil, fmt.Errorf("Did not receive expected streaming response")
+			}
+		}
+		if n == 0 {
+			return nil, nil
+		}
+	}
+}
+
+// Send an arbitrary command to kitty, for commands that do not have a typed
+// wrapper in this package. The payload must be JSON serializable
+func (self *Client) Send(cmd string, payload any) (*Response, error) {
+	return self.run(&command{name: cmd, payload: payload})
+}
+
+// Send an arbitrary command to kitty without waiting for a response
+func (self *Client) SendWithoutResponse(cmd string, payload any) error {
+	_, err := self.run(&command{name: cmd, payload: payload, no_response: true})
+	return err
+}
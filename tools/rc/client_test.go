@@ -0,0 +1,296 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package rc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kitty/tools/wcswidth"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+type test_cmd struct {
+	Cmd        string         `json:"cmd"`
+	NoResponse bool           `json:"no_response"`
+	Stream     bool           `json:"stream"`
+	StreamId   string         `json:"stream_id"`
+	Async      string         `json:"async"`
+	Payload    map[string]any `json:"payload"`
+}
+
+// A fake kitty that calls handler for every command received and sends back
+// the responses it returns
+func fake_kitty(t *testing.T, handler func(raw []byte, cmd *test_cmd) []string) *Client {
+	path := filepath.Join(t.TempDir(), "sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				p := wcswidth.EscapeCodeParser{}
+				p.HandleDCS = func(data []byte) error {
+					data = data[len("@kitty-cmd"):]
+					var cmd test_cmd
+					if err := json.Unmarshal(data, &cmd); err != nil {
+						return err
+					}
+					for _, r := range handler(data, &cmd) {
+						if _, err := conn.Write([]byte("\x1bP@kitty-cmd" + r + "\x1b\\")); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+				buf := make([]byte, 4096)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					if p.Parse(buf[:n]) != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	c, err := NewClient("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.WindowId = 0
+	return c
+}
+
+func TestRCClient(t *testing.T) {
+	var received []*test_cmd
+	var raw_commands [][]byte
+	var streamed bytes.Buffer
+	var mutex sync.Mutex
+	num_received := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(received)
+	}
+	c := fake_kitty(t, func(raw []byte, cmd *test_cmd) []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, cmd)
+		raw_commands = append(raw_commands, bytes.Clone(raw))
+		switch cmd.Cmd {
+		case "ls":
+			return []string{`{"ok": true, "data": "[{\"id\": 1}]"}`}
+		case "set-tab-title":
+			return []string{`{"ok": false, "error": "No matching tabs", "tb": "Traceback"}`}
+		case "set-window-logo":
+			// empty chunks are sent as a missing data field
+			chunk, _ := cmd.Payload["data"].(string)
+			d, _ := base64.StdEncoding.DecodeString(chunk)
+			streamed.Write(d)
+			switch {
+			case cmd.Stream:
+				return []string{`{"ok": true, "stream": true}`}
+			case len(d) == 0:
+				return []string{`{"ok": true}`}
+			}
+		case "get-text":
+			return []string{`{"ok": true, "data": "héllo"}`}
+		}
+		return nil
+	})
+	r, err := c.Ls(&LsPayload{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var windows []map[string]int
+	if err = r.Data.Unmarshal(&windows); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]map[string]int{{"id": 1}}, windows); diff != "" {
+		t.Fatalf("Failed to unmarshal response:\n%s", diff)
+	}
+	if received[0].Cmd != "ls" || received[0].NoResponse {
+		t.Fatalf("Unexpected command received: %#v", received[0])
+	}
+
+	_, err = c.SetTabTitle(&SetTabTitlePayload{Title: "héllo 😸"})
+	var rerr *Error
+	if !errors.As(err, &rerr) || rerr.Message != "No matching tabs" || rerr.Traceback != "Traceback" {
+		t.Fatalf("Unexpected error for failed command: %#v", err)
+	}
+	if raw := raw_commands[len(raw_commands)-1]; bytes.IndexFunc(raw, func(r rune) bool { return r > 127 }) > -1 {
+		t.Fatalf("Non-ASCII characters not escaped in: %s", raw)
+	}
+	if title := received[len(received)-1].Payload["title"]; title != "héllo 😸" {
+		t.Fatalf("Title not transmitted correctly: %#v", title)
+	}
+
+	if r, err = c.GetText(&GetTextPayload{}); err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != "héllo" {
+		t.Fatalf("Incorrect string response: %#v", r.String())
+	}
+
+	data := strings.Repeat("0123456789", 1000)
+	start := len(received)
+	if _, err = c.SetWindowLogo(&SetWindowLogoPayload{}, strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if streamed.String() != data {
+		t.Fatalf("Streamed data not received correctly, got %d bytes instead of %d", streamed.Len(), len(data))
+	}
+	chunks := received[start:]
+	if len(chunks) != len(data)/stream_chunk_size+2 || !chunks[0].Stream || chunks[0].StreamId == "" {
+		t.Fatalf("Unexpected chunks received: %d", len(chunks))
+	}
+	for _, x := range chunks[1:] {
+		if x.Stream || x.StreamId != chunks[0].StreamId {
+			t.Fatalf("Unexpected streaming chunk: %#v", x)
+		}
+	}
+
+	// responses to queries are re-used
+	c.CacheResponsesFor = time.Minute
+	start = len(received)
+	for i := 0; i < 2; i++ {
+		if r, err = c.GetText(&GetTextPayload{}); err != nil || r.String() != "héllo" {
+			t.Fatalf("Incorrect cached response: %#v %v", r, err)
+		}
+	}
+	if _, err = c.GetText(&GetTextPayload{Extent: "all"}); err != nil {
+		t.Fatal(err)
+	}
+	if num_received() != start+2 {
+		t.Fatalf("Responses not re-used, %d commands sent instead of 2", num_received()-start)
+	}
+	c.CacheResponsesFor = 0
+
+	// timeouts for async commands cancel the request
+	c.Timeout = 50 * time.Millisecond
+	start = len(received)
+	if _, err = c.SelectWindow(&SelectWindowPayload{}); err == nil || !strings.Contains(err.Error(), "Timed out") {
+		t.Fatalf("Command did not timeout: %v", err)
+	}
+	for num_received() < start+2 {
+		time.Sleep(time.Millisecond)
+	}
+	if a, b := received[start], received[start+1]; a.Async == "" || b.Async != a.Async || !b.NoResponse {
+		t.Fatalf("Async request not cancelled: %#v %#v", a, b)
+	}
+
+	// commands that disallow responses do not wait for one
+	start = len(received)
+	if err = c.SendKey(&SendKeyPayload{Keys: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	for num_received() < start+1 {
+		time.Sleep(time.Millisecond)
+	}
+	if cmd := received[start]; cmd.Cmd != "send-key" || !cmd.NoResponse {
+		t.Fatalf("Unexpected command received: %#v", cmd)
+	}
+}
+
+func TestRCClientHelpers(t *testing.T) {
+	if diff := cmp.Diff(`{"a":"\u00e9\ud83d\ude38"}`, string(ascii_json([]byte(`{"a":"é😸"}`)))); diff != "" {
+		t.Fatalf("Incorrect escaping:\n%s", diff)
+	}
+	var q string
+	if err := json.Unmarshal(ascii_json([]byte(`"é😸"`)), &q); err != nil || q != "é😸" {
+		t.Fatalf("Escaped JSON did not round trip: %#v %v", q, err)
+	}
+	c := Client{Address: "unix:/nonexistent", Password: "x", PublicKey: "1:abc"}
+	if _, err := c.Send("ls", nil); err == nil {
+		t.Fatalf("Invalid public key did not fail")
+	}
+	if _, err := NewClient("bad address"); err == nil {
+		t.Fatalf("Invalid address did not fail")
+	}
+	for raw, expected := range map[string]ResponseData{
+		`{"ok": true, "data": "x"}`:   {Text: "x", IsString: true, Present: true},
+		`{"ok": true, "data": 1}`:     {Text: "1", Present: true},
+		`{"ok": true, "data": null}`:  {Text: "null"},
+		`{"ok": true}`:                {},
+		`{"ok": true, "data": false}`: {Text: "false", Present: true},
+	} {
+		var r Response
+		if err := json.Unmarshal([]byte(raw), &r); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, r.Data); diff != "" {
+			t.Fatalf("Incorrect data for %s:\n%s", raw, diff)
+		}
+	}
+
+	tdir := t.TempDir()
+	conf := filepath.Join(tdir, "kitty.conf")
+	env := map[string]string{"TDIR": tdir}
+	getenv := func(k string) string { return env[k] }
+	resolve := func(expected string) {
+		t.Helper()
+		actual, err := resolve_address("", getenv, conf)
+		if err != nil {
+			if expected != "" {
+				t.Fatal(err)
+			}
+			return
+		}
+		if actual != expected {
+			t.Fatalf("Incorrect address: %#v != %#v", expected, actual)
+		}
+	}
+	resolve("")
+	env["KITTY_LISTEN_ON"] = "unix:/from/env"
+	resolve("unix:/from/env")
+	delete(env, "KITTY_LISTEN_ON")
+	if err := os.WriteFile(conf, []byte("listen_on unix:${TDIR}/mykitty\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	resolve("")
+	for _, pid := range []string{"123", "456"} {
+		l, err := net.Listen("unix", filepath.Join(tdir, "mykitty-"+pid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	resolve("unix:" + filepath.Join(tdir, "mykitty-456"))
+	if err := os.WriteFile(conf, []byte("listen_on unix:${TDIR}/my-{kitty_pid}-kitty\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	resolve("")
+	l, err := net.Listen("unix", filepath.Join(tdir, "my-789-kitty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	resolve("unix:" + filepath.Join(tdir, "my-789-kitty"))
+	if err := os.WriteFile(conf, []byte("listen_on tcp:localhost:0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	resolve("")
+}
@@ -191,6 +191,10 @@ func (self *Patcher) FinishDelta() (err error) {
 	return
 }
 
+func (self *Patcher) BlockSize() int {
+	return self.rsync.BlockSize
+}
+
 // Create a signature for the data source in src.
 func (self *Patcher) CreateSignatureIterator(src io.Reader, output io.Writer) func() error {
 	var it func() (BlockHash, error)
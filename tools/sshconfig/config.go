@@ -0,0 +1,505 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+// Package sshconfig parses OpenSSH client configuration files and
+// known_hosts files, as described in ssh_config(5) and sshd(8).
+package sshconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// The maximum depth of nested Include directives, the same as OpenSSH
+const max_include_depth = 16
+
+type Option struct {
+	// The lowercased keyword
+	Key  string
+	Args []string
+	// The file and line number this option was read from
+	Path string
+	Line int
+}
+
+type BlockType int
+
+const (
+	// Options at the top of the file that apply to all hosts
+	GlobalBlock BlockType = iota
+	HostBlock
+	MatchBlock
+)
+
+type Block struct {
+	Type BlockType
+	// The patterns for a Host block or the criteria for a Match block
+	Criteria []string
+	Options  []Option
+}
+
+type Config struct {
+	Blocks []*Block
+}
+
+type ParseError struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (self *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", self.Path, self.Line, self.Msg)
+}
+
+// Split a line into words, respecting double and single quotes, with an
+// optional = separating the keyword from its arguments
+func split_line(line string) (key string, args []string, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	key, line = strings.ToLower(line[:end]), strings.TrimLeft(line[end:], " \t")
+	if strings.HasPrefix(line, "=") {
+		line = strings.TrimLeft(line[1:], " \t")
+	}
+	var cur strings.Builder
+	in_word := false
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				cur.WriteByte(ch)
+			}
+		case ch == '"' || ch == '\'':
+			quote, in_word = ch, true
+		case ch == ' ' || ch == '\t':
+			if in_word {
+				args = append(args, cur.String())
+				cur.Reset()
+				in_word = false
+			}
+		case ch == '#' && !in_word:
+			// trailing comment
+			i = len(line)
+		default:
+			cur.WriteByte(ch)
+			in_word = true
+		}
+	}
+	if quote != 0 {
+		return "", nil, fmt.Errorf("Unterminated quote")
+	}
+	if in_word {
+		args = append(args, cur.String())
+	}
+	return
+}
+
+type parser struct {
+	ans *Config
+	// the directory relative to which Include paths are resolved
+	include_base string
+	current      *Block
+}
+
+func (self *parser) parse_file(path string, depth int) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return self.parse(raw, path, depth)
+}
+
+func (self *parser) parse(raw []byte, path string, depth int) error {
+	scanner := utils.NewRecordScanner(bytes.NewReader(raw))
+	lnum := 0
+	for scanner.Scan() {
+		lnum++
+		key, args, err := split_line(scanner.Text())
+		if err != nil {
+			return &ParseError{path, lnum, err.Error()}
+		}
+		switch key {
+		case "":
+			continue
+		case "host", "match":
+			if len(args) == 0 {
+				return &ParseError{path, lnum, fmt.Sprintf("%s with no arguments", key)}
+			}
+			b := &Block{Type: HostBlock, Criteria: args}
+			if key == "match" {
+				b.Type = MatchBlock
+			}
+			self.ans.Blocks = append(self.ans.Blocks, b)
+			self.current = b
+		case "include":
+			if depth >= max_include_depth {
+				return &ParseError{path, lnum, "Include nested too deeply"}
+			}
+			for _, pat := range args {
+				pat = utils.Expanduser(pat)
+				if !filepath.IsAbs(pat) {
+					pat = filepath.Join(self.include_base, pat)
+				}
+				matches, err := filepath.Glob(pat)
+				if err != nil {
+					return &ParseError{path, lnum, fmt.Sprintf("Invalid Include pattern: %s", pat)}
+				}
+				for _, m := range matches {
+					// included files have their options added to the current block
+					if err = self.parse_file(m, depth+1); err != nil && !os.IsNotExist(err) {
+						return err
+					}
+				}
+			}
+		default:
+			if self.current == nil {
+				self.current = &Block{Type: GlobalBlock}
+				self.ans.Blocks = append(self.ans.Blocks, self.current)
+			}
+			self.current.Options = append(self.current.Options, Option{Key: key, Args: args, Path: path, Line: lnum})
+		}
+	}
+	return scanner.Err()
+}
+
+// Parse the config data, resolving relative Include paths with respect to include_base
+func Parse(raw []byte, path, include_base string) (*Config, error) {
+	p := parser{ans: &Config{}, include_base: include_base}
+	if err := p.parse(raw, path, 0); err != nil {
+		return nil, err
+	}
+	return p.ans, nil
+}
+
+// Parse the config file at path, relative Include paths are resolved with
+// respect to ~/.ssh for files in ~/.ssh and /etc/ssh otherwise
+func ParseFile(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	base := utils.Expanduser("~/.ssh")
+	if rel, err := filepath.Rel(base, utils.Abspath(path)); err != nil || strings.HasPrefix(rel, "..") {
+		base = "/etc/ssh"
+	}
+	return Parse(raw, path, base)
+}
+
+// Load the user's SSH config followed by the system wide one, missing
+// files are ignored
+func LoadDefault() (*Config, error) {
+	ans := &Config{}
+	for _, path := range []string{utils.Expanduser("~/.ssh/config"), "/etc/ssh/ssh_config"} {
+		c, err := ParseFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		ans.Blocks = append(ans.Blocks, c.Blocks...)
+	}
+	return ans, nil
+}
+
+// Match s against a pattern where * matches any number of characters and ?
+// matches exactly one character, there are no other special characters
+func match_wildcard(pat, s string) bool {
+	for len(pat) > 0 {
+		switch pat[0] {
+		case '*':
+			for len(pat) > 0 && pat[0] == '*' {
+				pat = pat[1:]
+			}
+			if pat == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if match_wildcard(pat, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pat[0] {
+				return false
+			}
+		}
+		pat, s = pat[1:], s[1:]
+	}
+	return s == ""
+}
+
+// Match host against a comma separated list of patterns, any of which can be
+// negated with a leading !. A single matching negated pattern means no match.
+func MatchPatternList(host, patterns string) bool {
+	return match_pattern_list(host, strings.Split(patterns, ","))
+}
+
+func match_pattern_list(host string, patterns []string) (matched bool) {
+	host = strings.ToLower(host)
+	for _, pat := range patterns {
+		negated := strings.HasPrefix(pat, "!")
+		if negated {
+			pat = pat[1:]
+		}
+		if match_wildcard(strings.ToLower(pat), host) {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return
+}
+
+// Keywords whose values accumulate instead of the first value winning
+var multi_valued = map[string]bool{
+	"identityfile": true, "certificatefile": true, "localforward": true, "remoteforward": true,
+	"dynamicforward": true, "sendenv": true, "setenv": true,
+}
+
+// Keywords whose arguments are subject to token and ~ expansion
+var expanded_keys = map[string]bool{
+	"certificatefile": true, "controlpath": true, "identityagent": true, "identityfile": true,
+	"knownhostscommand": true, "localcommand": true, "proxycommand": true, "remotecommand": true,
+	"userknownhostsfile": true,
+}
+
+type Host struct {
+	// The host name as specified by the user
+	OriginalHost string
+	// The resolved options, lowercased keyword to arguments. For multi-valued
+	// keywords the arguments from all matching lines are concatenated.
+	Options map[string][]string
+}
+
+// The first argument of the specified keyword or the empty string
+func (self *Host) Get(key string) string {
+	if v := self.Options[strings.ToLower(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (self *Host) Hostname() string {
+	if h := self.Get("hostname"); h != "" {
+		return h
+	}
+	return self.OriginalHost
+}
+
+func (self *Host) Port() int {
+	if p, err := strconv.Atoi(self.Get("port")); err == nil {
+		return p
+	}
+	return 22
+}
+
+func (self *Host) User() string {
+	if u := self.Get("user"); u != "" {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+type LookupOptions struct {
+	// The user name specified on the command line, if any
+	User string
+	// Allow evaluating Match exec criteria by running commands
+	AllowExec bool
+	// The tag for Match tagged
+	Tag string
+}
+
+func (self *Config) match_block(b *Block, h *Host, opts *LookupOptions) bool {
+	switch b.Type {
+	case GlobalBlock:
+		return true
+	case HostBlock:
+		return match_pattern_list(h.OriginalHost, b.Criteria)
+	}
+	c := b.Criteria
+	for i := 0; i < len(c); i++ {
+		crit := strings.ToLower(c[i])
+		negated := strings.HasPrefix(crit, "!")
+		crit = strings.TrimPrefix(crit, "!")
+		var matched bool
+		switch crit {
+		case "all":
+			matched = true
+		case "canonical":
+			// canonicalization is not performed
+			matched = false
+		case "final":
+			// lookups are done in a single pass which is the final pass
+			matched = true
+		default:
+			if i+1 >= len(c) {
+				return false
+			}
+			i++
+			arg := c[i]
+			switch crit {
+			case "host":
+				matched = MatchPatternList(h.Hostname(), arg)
+			case "originalhost":
+				matched = MatchPatternList(h.OriginalHost, arg)
+			case "user":
+				matched = MatchPatternList(h.User(), arg)
+			case "localuser":
+				if u, err := user.Current(); err == nil {
+					matched = MatchPatternList(u.Username, arg)
+				}
+			case "tagged":
+				matched = MatchPatternList(opts.Tag, arg)
+			case "exec":
+				if opts.AllowExec {
+					matched = exec.Command("/bin/sh", "-c", h.expand_tokens(arg)).Run() == nil
+				}
+			default:
+				// unsupported criteria such as localnetwork never match
+				matched = false
+			}
+		}
+		if matched == negated {
+			return false
+		}
+	}
+	return true
+}
+
+// Expand the % tokens supported by ssh_config(5)
+func (self *Host) expand_tokens(x string) string {
+	if !strings.Contains(x, "%") {
+		return x
+	}
+	var b strings.Builder
+	for i := 0; i < len(x); i++ {
+		if x[i] != '%' || i+1 >= len(x) {
+			b.WriteByte(x[i])
+			continue
+		}
+		i++
+		switch x[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'h':
+			b.WriteString(self.Hostname())
+		case 'n':
+			b.WriteString(self.OriginalHost)
+		case 'k':
+			if a := self.Get("hostkeyalias"); a != "" {
+				b.WriteString(a)
+			} else {
+				b.WriteString(self.OriginalHost)
+			}
+		case 'p':
+			b.WriteString(strconv.Itoa(self.Port()))
+		case 'r':
+			b.WriteString(self.User())
+		case 'u':
+			if u, err := user.Current(); err == nil {
+				b.WriteString(u.Username)
+			}
+		case 'i':
+			b.WriteString(strconv.Itoa(os.Getuid()))
+		case 'd':
+			b.WriteString(utils.Expanduser("~"))
+		case 'L':
+			b.WriteString(utils.Hostname())
+		case 'l':
+			h, _, _ := strings.Cut(utils.Hostname(), ".")
+			b.WriteString(h)
+		default:
+			// unknown tokens are left as is
+			b.WriteByte('%')
+			b.WriteByte(x[i])
+		}
+	}
+	return b.String()
+}
+
+// Resolve the options for the specified host, the same way ssh does: for
+// every keyword the first obtained value is used
+func (self *Config) Lookup(host string, opts LookupOptions) *Host {
+	ans := &Host{OriginalHost: host, Options: make(map[string][]string)}
+	if opts.User != "" {
+		ans.Options["user"] = []string{opts.User}
+	}
+	for _, b := range self.Blocks {
+		if !self.match_block(b, ans, &opts) {
+			continue
+		}
+		for _, o := range b.Options {
+			if !multi_valued[o.Key] {
+				if _, found := ans.Options[o.Key]; found {
+					continue
+				}
+			}
+			ans.Options[o.Key] = append(ans.Options[o.Key], o.Args...)
+		}
+	}
+	if v := ans.Options["hostname"]; len(v) > 0 {
+		// in HostName %h refers to the original host
+		delete(ans.Options, "hostname")
+		ans.Options["hostname"] = []string{ans.expand_tokens(v[0])}
+	}
+	for key := range expanded_keys {
+		if v := ans.Options[key]; len(v) > 0 {
+			nv := make([]string, len(v))
+			for i, x := range v {
+				nv[i] = ans.expand_tokens(x)
+				if strings.HasPrefix(nv[i], "~") {
+					nv[i] = utils.Expanduser(nv[i])
+				}
+			}
+			ans.Options[key] = nv
+		}
+	}
+	return ans
+}
+
+// The host aliases from Host lines that contain no wildcards or negations,
+// useful for completion
+func (self *Config) HostAliases() (ans []string) {
+	seen := utils.NewSet[string]()
+	for _, b := range self.Blocks {
+		if b.Type != HostBlock {
+			continue
+		}
+		for _, pat := range b.Criteria {
+			if !strings.ContainsAny(pat, "*?!") && !seen.Has(pat) {
+				seen.Add(pat)
+				ans = append(ans, pat)
+			}
+		}
+	}
+	return
+}
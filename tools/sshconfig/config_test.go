@@ -0,0 +1,133 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package sshconfig
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestSSHConfigSplitLine(t *testing.T) {
+	for line, expected := range map[string][]string{
+		"Host a b":                   {"host", "a", "b"},
+		"  HostName=x.com":           {"hostname", "x.com"},
+		"HostName = x.com # comment": {"hostname", "x.com"},
+		`ProxyCommand "a b" 'c d'e`:  {"proxycommand", "a b", "c de"},
+		"# comment":                  {""},
+	} {
+		key, args, err := split_line(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, append([]string{key}, args...)); diff != "" {
+			t.Fatalf("Failed to split: %#v\n%s", line, diff)
+		}
+	}
+	if _, _, err := split_line(`Host "a`); err == nil {
+		t.Fatalf("No error for unterminated quote")
+	}
+}
+
+func TestSSHConfigLookup(t *testing.T) {
+	tdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tdir, "extra.conf"), []byte("Host inc\n  Port 2222\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf := `
+Compression yes
+Host web *.example.com !bad.example.com
+	HostName %h.internal
+	User webuser
+	IdentityFile ~/.ssh/web
+Include *.conf
+Match originalhost dev user root
+	Port 23
+Match host *.internal
+	ControlPath /tmp/%r@%h:%p
+Host *
+	IdentityFile ~/.ssh/id
+	User fallback
+	Port 22
+`
+	c, err := Parse([]byte(conf), "config", tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	home, _ := os.UserHomeDir()
+	h := c.Lookup("web", LookupOptions{})
+	if diff := cmp.Diff(map[string][]string{
+		"compression": {"yes"}, "hostname": {"web.internal"}, "user": {"webuser"},
+		"identityfile": {home + "/.ssh/web", home + "/.ssh/id"}, "port": {"22"},
+		"controlpath": {"/tmp/webuser@web.internal:22"},
+	}, h.Options); diff != "" {
+		t.Fatalf("Incorrect options for web:\n%s", diff)
+	}
+	if h = c.Lookup("bad.example.com", LookupOptions{}); h.Hostname() != "bad.example.com" || h.User() != "fallback" {
+		t.Fatalf("Negated pattern did not work: %#v", h.Options)
+	}
+	if h = c.Lookup("inc", LookupOptions{}); h.Port() != 2222 {
+		t.Fatalf("Include did not work: %#v", h.Options)
+	}
+	if h = c.Lookup("dev", LookupOptions{User: "root"}); h.Port() != 23 {
+		t.Fatalf("Match did not work: %#v", h.Options)
+	}
+	if h = c.Lookup("dev", LookupOptions{User: "other"}); h.Port() != 22 {
+		t.Fatalf("Match did not work: %#v", h.Options)
+	}
+	if diff := cmp.Diff([]string{"web", "inc"}, c.HostAliases()); diff != "" {
+		t.Fatalf("Incorrect host aliases:\n%s", diff)
+	}
+}
+
+func TestSSHKnownHosts(t *testing.T) {
+	salt := []byte("0123456789abcdefghij")
+	h := hmac.New(sha1.New, salt)
+	h.Write([]byte("[secret.host]:2200"))
+	hashed := "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+	raw := `
+# comment
+a.com,192.168.1.1 ssh-ed25519 AAAAkey1 some comment
+[b.com]:2222 ssh-rsa AAAAkey2
+*.wild.com,!no.wild.com ssh-rsa AAAAkey3
+@revoked c.com ssh-rsa AAAAkey4
+` + hashed + ` ssh-ed25519 AAAAkey5
+malformed
+`
+	entries := ParseKnownHosts([]byte(raw))
+	if len(entries) != 5 {
+		t.Fatalf("Incorrect number of entries: %d", len(entries))
+	}
+	if entries[0].Comment != "some comment" || entries[3].Marker != "@revoked" || !entries[4].IsHashed() {
+		t.Fatalf("Incorrectly parsed entries: %#v", entries)
+	}
+	for i, q := range []struct {
+		host  string
+		port  int
+		match bool
+	}{
+		{"a.com", 22, true}, {"192.168.1.1", 0, true}, {"a.com", 2222, false},
+		{"b.com", 2222, true}, {"b.com", 22, false},
+		{"x.wild.com", 22, true}, {"no.wild.com", 22, false},
+		{"secret.host", 2200, true}, {"secret.host", 22, false},
+	} {
+		matched := false
+		for _, e := range entries {
+			matched = matched || e.Matches(q.host, q.port)
+		}
+		if matched != q.match {
+			t.Fatalf("%d: Incorrect match for %s:%d: %v", i, q.host, q.port, matched)
+		}
+	}
+	if diff := cmp.Diff([]string{"a.com", "192.168.1.1", "b.com"}, KnownHostNames(entries)); diff != "" {
+		t.Fatalf("Incorrect known host names:\n%s", diff)
+	}
+}
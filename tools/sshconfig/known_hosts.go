@@ -0,0 +1,165 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package sshconfig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type KnownHost struct {
+	// Either empty, @cert-authority or @revoked
+	Marker string
+	// The host patterns, empty for hashed entries
+	Patterns []string
+	// The salt and hash for hashed entries
+	Salt, Hash []byte
+	KeyType    string
+	// The base64 encoded public key
+	Key     string
+	Comment string
+	Line    int
+}
+
+func (self *KnownHost) IsHashed() bool { return self.Hash != nil }
+
+// The form of the host used in known_hosts, [host]:port for non-standard ports
+func known_hosts_name(host string, port int) string {
+	if port > 0 && port != 22 {
+		return "[" + host + "]:" + strconv.Itoa(port)
+	}
+	return host
+}
+
+// Whether this entry applies to the specified host and port, a port of zero
+// means the default port
+func (self *KnownHost) Matches(host string, port int) bool {
+	name := known_hosts_name(strings.ToLower(host), port)
+	if self.IsHashed() {
+		h := hmac.New(sha1.New, self.Salt)
+		h.Write([]byte(name))
+		return hmac.Equal(h.Sum(nil), self.Hash)
+	}
+	return match_pattern_list(name, self.Patterns)
+}
+
+func parse_hashed(x string) (salt, hash []byte, err error) {
+	parts := strings.Split(x, "|")
+	if len(parts) != 4 || parts[1] != "1" {
+		return nil, nil, fmt.Errorf("Unsupported hashed host: %s", x)
+	}
+	if salt, err = base64.StdEncoding.DecodeString(parts[2]); err == nil {
+		hash, err = base64.StdEncoding.DecodeString(parts[3])
+	}
+	return
+}
+
+// Parse data in the known_hosts format. Malformed lines are skipped.
+func ParseKnownHosts(raw []byte) (ans []KnownHost) {
+	scanner := utils.NewRecordScanner(bytes.NewReader(raw))
+	lnum := 0
+	for scanner.Scan() {
+		lnum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		kh := KnownHost{Line: lnum}
+		if strings.HasPrefix(fields[0], "@") {
+			kh.Marker, fields = fields[0], fields[1:]
+		}
+		if len(fields) < 3 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "|") {
+			var err error
+			if kh.Salt, kh.Hash, err = parse_hashed(fields[0]); err != nil {
+				continue
+			}
+		} else {
+			kh.Patterns = strings.Split(fields[0], ",")
+		}
+		kh.KeyType, kh.Key = fields[1], fields[2]
+		kh.Comment = strings.Join(fields[3:], " ")
+		ans = append(ans, kh)
+	}
+	return
+}
+
+func ParseKnownHostsFile(path string) ([]KnownHost, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKnownHosts(raw), nil
+}
+
+// Load the entries from the user's and the system known_hosts files, missing
+// files are ignored
+func LoadDefaultKnownHosts() (ans []KnownHost, err error) {
+	for _, path := range []string{utils.Expanduser("~/.ssh/known_hosts"), "/etc/ssh/ssh_known_hosts"} {
+		entries, err := ParseKnownHostsFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		ans = append(ans, entries...)
+	}
+	return
+}
+
+// The host names in non-hashed entries that contain no wildcards, useful for
+// completion. Hosts with non-standard ports are returned without the port.
+func KnownHostNames(entries []KnownHost) (ans []string) {
+	seen := utils.NewSet[string]()
+	for _, kh := range entries {
+		if kh.Marker != "" {
+			continue
+		}
+		for _, pat := range kh.Patterns {
+			if strings.HasPrefix(pat, "[") {
+				if idx := strings.Index(pat, "]:"); idx > 0 {
+					pat = pat[1:idx]
+				}
+			}
+			if !strings.ContainsAny(pat, "*?![]") && !seen.Has(pat) {
+				seen.Add(pat)
+				ans = append(ans, pat)
+			}
+		}
+	}
+	return
+}
+
+// All host names known from the default SSH config and known_hosts files,
+// suitable for completion and for recognizing host names in arguments.
+// Errors reading the files are ignored.
+func AllHostNames() []string {
+	var ans []string
+	if c, err := LoadDefault(); err == nil {
+		ans = c.HostAliases()
+	}
+	seen := utils.NewSetWithItems(ans...)
+	if entries, err := LoadDefaultKnownHosts(); err == nil {
+		for _, h := range KnownHostNames(entries) {
+			if !seen.Has(h) {
+				seen.Add(h)
+				ans = append(ans, h)
+			}
+		}
+	}
+	return ans
+}
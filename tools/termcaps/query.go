@@ -0,0 +1,99 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package termcaps
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty/tools/tui/graphics"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type query_response struct {
+	// The name and version reported by the terminal in response to XTVERSION
+	name string
+	// Whether the terminal supports the kitty graphics protocol
+	graphics bool
+	// Whether the terminal supports the kitty keyboard protocol, and if so the
+	// currently enabled flags
+	keyboard       bool
+	keyboard_flags int
+}
+
+// Split an XTVERSION response of the form name(version) or name version
+func parse_xtversion(raw string) (name, version string) {
+	raw = strings.TrimSpace(raw)
+	if idx := strings.IndexByte(raw, '('); idx > -1 && strings.HasSuffix(raw, ")") {
+		return raw[:idx], raw[idx+1 : len(raw)-1]
+	}
+	name, version, _ = strings.Cut(raw, " ")
+	return
+}
+
+// Query the terminal for the protocols it supports. Primary device
+// attributes is queried last, as all terminals respond to it, when its
+// response arrives all other responses, if any, have been received.
+func query_terminal(timeout time.Duration) (ans query_response, err error) {
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return
+	}
+	const graphics_query_id = 31
+	lp.OnInitialize = func() (string, error) {
+		_, _ = lp.AddTimer(timeout, false, func(loop.IdType) error {
+			return fmt.Errorf("Timed out waiting for a response from the terminal: %w", os.ErrDeadlineExceeded)
+		})
+		g := &graphics.GraphicsCommand{}
+		g.SetTransmission(graphics.GRT_transmission_direct).SetAction(graphics.GRT_action_query).SetImageId(graphics_query_id).SetDataWidth(1).SetDataHeight(1).SetFormat(
+			graphics.GRT_format_rgb).SetDataSize(3)
+		_ = g.WriteWithPayloadToLoop(lp, []byte{1, 2, 3})
+		lp.QueueWriteString("\x1b[>0q\x1b[?u\x1b[c")
+		return "", nil
+	}
+
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, payload []byte) error {
+		raw := utils.UnsafeBytesToString(payload)
+		switch etype {
+		case loop.CSI:
+			if strings.HasPrefix(raw, "?") && strings.HasSuffix(raw, "c") {
+				lp.Quit(0)
+			} else if strings.HasPrefix(raw, "?") && strings.HasSuffix(raw, "u") {
+				ans.keyboard = true
+				ans.keyboard_flags, _ = strconv.Atoi(raw[1 : len(raw)-1])
+			}
+		case loop.DCS:
+			if name, found := strings.CutPrefix(raw, ">|"); found {
+				ans.name = name
+			}
+		case loop.APC:
+			if g := graphics.GraphicsCommandFromAPC(payload); g != nil && g.ImageId() == graphics_query_id && g.ResponseMessage() == "OK" {
+				ans.graphics = true
+			}
+		}
+		return nil
+	}
+
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		if event.MatchesPressOrRepeat("ctrl+c") {
+			event.Handled = true
+			lp.Quit(1)
+		}
+		return nil
+	}
+
+	if err = lp.Run(); err != nil {
+		return
+	}
+	if ds := lp.DeathSignalName(); ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+	}
+	return
+}
@@ -0,0 +1,196 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+// Package termcaps identifies the terminal the kittens are running in and any
+// terminal multiplexers between them and it, and the protocols that can be
+// used as a result.
+package termcaps
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"kitty/tools/tty"
+	"kitty/tools/tui"
+)
+
+var _ = fmt.Print
+
+type Capabilities struct {
+	// The name of the terminal, empty if unknown
+	Terminal string
+	// The version of the terminal, empty if unknown
+	Version string
+	// The multiplexers kittens are running inside, if any, such as tmux, GNU
+	// screen or zellij
+	Multiplexers []string
+	// Whether the terminal was queried for its capabilities, otherwise they
+	// are guessed from the environment
+	Queried bool
+
+	// Whether the kitty graphics protocol can be used, if so, it might need
+	// to be wrapped for passthrough, see tui.WrapForPassthrough
+	Graphics bool
+	// Whether the kitty keyboard protocol is supported and the currently
+	// enabled flags, if it was queried
+	Keyboard      bool
+	KeyboardFlags int
+	// Whether the clipboard can be written to with OSC 52
+	Clipboard bool
+	// Whether the kitty clipboard protocol with MIME types (OSC 5522) can be
+	// used
+	ExtendedClipboard bool
+}
+
+func (self *Capabilities) InMultiplexer() bool { return len(self.Multiplexers) > 0 }
+
+// A human readable name for the terminal
+func (self *Capabilities) Name() string {
+	switch {
+	case self.Terminal == "":
+		return "the terminal"
+	case self.Version == "":
+		return self.Terminal
+	}
+	return self.Terminal + " " + self.Version
+}
+
+type known_terminal struct {
+	graphics, keyboard, clipboard, extended_clipboard bool
+}
+
+// Protocols supported by default in terminals that can be identified from
+// their environment. Terminals where support must be enabled in their
+// configuration are marked as not supporting them.
+var known_terminals = map[string]known_terminal{
+	"kitty":     {graphics: true, keyboard: true, clipboard: true, extended_clipboard: true},
+	"ghostty":   {graphics: true, keyboard: true, clipboard: true},
+	"WezTerm":   {graphics: true, clipboard: true},
+	"konsole":   {graphics: true},
+	"foot":      {keyboard: true, clipboard: true},
+	"alacritty": {keyboard: true, clipboard: true},
+	"iTerm2":    {keyboard: true},
+}
+
+func detect_multiplexers(m tui.Multiplexer, getenv func(string) string) (ans []string) {
+	if m != tui.NoMultiplexer {
+		ans = append(ans, m.String())
+	}
+	// zellij has no passthrough, so tui does not detect it
+	if getenv("ZELLIJ") != "" {
+		ans = append(ans, "zellij")
+	}
+	return
+}
+
+func detect_terminal(getenv func(string) string) (name, version string) {
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || getenv("TERM") == "xterm-kitty":
+		return "kitty", ""
+	case getenv("GHOSTTY_RESOURCES_DIR") != "" || getenv("TERM") == "xterm-ghostty":
+		return "ghostty", getenv("TERM_PROGRAM_VERSION")
+	case getenv("WEZTERM_PANE") != "":
+		return "WezTerm", getenv("TERM_PROGRAM_VERSION")
+	case getenv("KONSOLE_VERSION") != "":
+		return "konsole", getenv("KONSOLE_VERSION")
+	case getenv("VTE_VERSION") != "":
+		return "VTE", getenv("VTE_VERSION")
+	case getenv("ALACRITTY_WINDOW_ID") != "":
+		return "alacritty", ""
+	case getenv("WT_SESSION") != "":
+		return "Windows Terminal", ""
+	case strings.HasPrefix(getenv("TERM"), "foot"):
+		return "foot", ""
+	}
+	switch tp := getenv("TERM_PROGRAM"); tp {
+	case "", "tmux", "screen", "zellij":
+	case "iTerm.app":
+		return "iTerm2", getenv("TERM_PROGRAM_VERSION")
+	case "Apple_Terminal":
+		return "Terminal.app", getenv("TERM_PROGRAM_VERSION")
+	default:
+		return tp, getenv("TERM_PROGRAM_VERSION")
+	}
+	return
+}
+
+// Remove the capabilities that do not survive the multiplexers
+func (self *Capabilities) apply_multiplexers() {
+	for _, m := range self.Multiplexers {
+		// no multiplexer forwards the responses needed for these
+		self.ExtendedClipboard = false
+		switch m {
+		case "tmux":
+			// tmux translates keys itself, graphics and the clipboard work via passthrough
+			self.Keyboard = false
+		case "GNU screen":
+			// screen cannot pass through the ST terminated escape codes used for graphics
+			self.Keyboard, self.Graphics = false, false
+		default:
+			// zellij has no passthrough, it handles OSC 52 itself
+			self.Graphics = false
+		}
+	}
+}
+
+func from_environment(m tui.Multiplexer, getenv func(string) string) *Capabilities {
+	ans := &Capabilities{Multiplexers: detect_multiplexers(m, getenv)}
+	ans.Terminal, ans.Version = detect_terminal(getenv)
+	if k, found := known_terminals[ans.Terminal]; found {
+		ans.Graphics, ans.Keyboard, ans.Clipboard, ans.ExtendedClipboard = k.graphics, k.keyboard, k.clipboard, k.extended_clipboard
+	}
+	ans.apply_multiplexers()
+	return ans
+}
+
+// Guess the capabilities from environment variables alone, this is fast but
+// does not work across SSH connections, use Query() for accurate results
+func FromEnvironment() *Capabilities { return from_environment(tui.DetectMultiplexer(), os.Getenv) }
+
+// Query the terminal for its capabilities, falling back to those guessed
+// from the environment for anything the terminal cannot be asked about. Note
+// that multiplexers answer queries themselves so it is the capabilities the
+// multiplexer presents that are detected.
+func Query(timeout time.Duration) (ans *Capabilities, err error) {
+	ans = FromEnvironment()
+	q, err := query_terminal(timeout)
+	if err != nil {
+		return ans, err
+	}
+	ans.Queried = true
+	ans.Keyboard, ans.KeyboardFlags = q.keyboard, q.keyboard_flags
+	if !ans.InMultiplexer() {
+		// inside a multiplexer, XTVERSION reports the multiplexer and
+		// graphics queries are not answered
+		if name, version := parse_xtversion(q.name); name != "" {
+			ans.Terminal, ans.Version = name, version
+		}
+		ans.Graphics = q.graphics
+		if k, found := known_terminals[ans.Terminal]; found {
+			ans.Clipboard, ans.ExtendedClipboard = k.clipboard, k.extended_clipboard
+		}
+	}
+	return ans, nil
+}
+
+// The capabilities of the terminal, queried if STDIN and STDOUT are
+// terminals and guessed from the environment otherwise
+var Detect = sync.OnceValue(func() *Capabilities {
+	if tty.IsTerminal(os.Stdin.Fd()) && tty.IsTerminal(os.Stdout.Fd()) {
+		if ans, err := Query(time.Second); err == nil {
+			return ans
+		}
+	}
+	return FromEnvironment()
+})
+
+// An error describing why the specified protocol cannot be used, in a form
+// suitable for showing to the user
+func (self *Capabilities) UnsupportedError(protocol string) error {
+	if self.InMultiplexer() {
+		return fmt.Errorf("The %s cannot be used inside %s", protocol, strings.Join(self.Multiplexers, " inside "))
+	}
+	return fmt.Errorf("The %s is not supported by %s", protocol, self.Name())
+}
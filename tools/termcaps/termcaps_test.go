@@ -0,0 +1,45 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package termcaps
+
+import (
+	"fmt"
+	"testing"
+
+	"kitty/tools/tui"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestTermcapsFromEnvironment(t *testing.T) {
+	q := func(m tui.Multiplexer, env map[string]string, expected Capabilities) {
+		actual := from_environment(m, func(k string) string { return env[k] })
+		if diff := cmp.Diff(&expected, actual); diff != "" {
+			t.Fatalf("Incorrect capabilities for %v:\n%s", env, diff)
+		}
+	}
+	q(tui.NoMultiplexer, map[string]string{"TERM": "xterm-kitty"}, Capabilities{Terminal: "kitty", Graphics: true, Keyboard: true, Clipboard: true, ExtendedClipboard: true})
+	q(tui.TmuxMultiplexer, map[string]string{"TERM": "screen", "KITTY_WINDOW_ID": "1", "TMUX": "/tmp/tmux-1000/default,1,0", "TERM_PROGRAM": "tmux"},
+		Capabilities{Terminal: "kitty", Multiplexers: []string{"tmux"}, Graphics: true, Clipboard: true})
+	q(tui.ScreenMultiplexer, map[string]string{"TERM": "screen", "KITTY_WINDOW_ID": "1", "STY": "1.pts-0"},
+		Capabilities{Terminal: "kitty", Multiplexers: []string{"GNU screen"}, Clipboard: true})
+	q(tui.NoMultiplexer, map[string]string{"TERM": "xterm-kitty", "ZELLIJ": "0"}, Capabilities{Terminal: "kitty", Multiplexers: []string{"zellij"}, Keyboard: true, Clipboard: true})
+	q(tui.NoMultiplexer, map[string]string{"TERM_PROGRAM": "iTerm.app", "TERM_PROGRAM_VERSION": "3.5.0"}, Capabilities{Terminal: "iTerm2", Version: "3.5.0", Keyboard: true})
+	q(tui.NoMultiplexer, map[string]string{"TERM": "xterm-256color"}, Capabilities{})
+}
+
+func TestTermcapsXTVersion(t *testing.T) {
+	for raw, expected := range map[string][2]string{
+		"kitty(0.35.0)":           {"kitty", "0.35.0"},
+		"WezTerm 20240203-110809": {"WezTerm", "20240203-110809"},
+		"tmux 3.4":                {"tmux", "3.4"},
+		"xterm":                   {"xterm", ""},
+	} {
+		name, version := parse_xtversion(raw)
+		if name != expected[0] || version != expected[1] {
+			t.Fatalf("Incorrect parse of %#v: %#v %#v", raw, name, version)
+		}
+	}
+}
@@ -4,7 +4,6 @@ package themes
 
 import (
 	"archive/zip"
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,291 +34,293 @@ import (
 
 var _ = fmt.Print
 
-var AllColorSettingNames = map[string]bool{ // {{{
-	// generated by gen-config.py do not edit
-	// ALL_COLORS_START
-	"active_border_color":     true,
-	"active_tab_background":   true,
-	"active_tab_foreground":   true,
-	"background":              true,
-	"bell_border_color":       true,
-	"color0":                  true,
-	"color1":                  true,
-	"color10":                 true,
-	"color100":                true,
-	"color101":                true,
-	"color102":                true,
-	"color103":                true,
-	"color104":                true,
-	"color105":                true,
-	"color106":                true,
-	"color107":                true,
-	"color108":                true,
-	"color109":                true,
-	"color11":                 true,
-	"color110":                true,
-	"color111":                true,
-	"color112":                true,
-	"color113":                true,
-	"color114":                true,
-	"color115":                true,
-	"color116":                true,
-	"color117":                true,
-	"color118":                true,
-	"color119":                true,
-	"color12":                 true,
-	"color120":                true,
-	"color121":                true,
-	"color122":                true,
-	"color123":                true,
-	"color124":                true,
-	"color125":                true,
-	"color126":                true,
-	"color127":                true,
-	"color128":                true,
-	"color129":                true,
-	"color13":                 true,
-	"color130":                true,
-	"color131":                true,
-	"color132":                true,
-	"color133":                true,
-	"color134":                true,
-	"color135":                true,
-	"color136":                true,
-	"color137":                true,
-	"color138":                true,
-	"color139":                true,
-	"color14":                 true,
-	"color140":                true,
-	"color141":                true,
-	"color142":                true,
-	"color143":                true,
-	"color144":                true,
-	"color145":                true,
-	"color146":                true,
-	"color147":                true,
-	"color148":                true,
-	"color149":                true,
-	"color15":                 true,
-	"color150":                true,
-	"color151":                true,
-	"color152":                true,
-	"color153":                true,
-	"color154":                true,
-	"color155":                true,
-	"color156":                true,
-	"color157":                true,
-	"color158":                true,
-	"color159":                true,
-	"color16":                 true,
-	"color160":                true,
-	"color161":                true,
-	"color162":                true,
-	"color163":                true,
-	"color164":                true,
-	"color165":                true,
-	"color166":                true,
-	"color167":                true,
-	"color168":                true,
-	"color169":                true,
-	"color17":                 true,
-	"color170":                true,
-	"color171":                true,
-	"color172":                true,
-	"color173":                true,
-	"color174":                true,
-	"color175":                true,
-	"color176":                true,
-	"color177":                true,
-	"color178":                true,
-	"color179":                true,
-	"color18":                 true,
-	"color180":                true,
-	"color181":                true,
-	"color182":                true,
-	"color183":                true,
-	"color184":                true,
-	"color185":                true,
-	"color186":                true,
-	"color187":                true,
-	"color188":                true,
-	"color189":                true,
-	"color19":                 true,
-	"color190":                true,
-	"color191":                true,
-	"color192":                true,
-	"color193":                true,
-	"color194":                true,
-	"color195":                true,
-	"color196":                true,
-	"color197":                true,
-	"color198":                true,
-	"color199":                true,
-	"color2":                  true,
-	"color20":                 true,
-	"color200":                true,
-	"color201":                true,
-	"color202":                true,
-	"color203":                true,
-	"color204":                true,
-	"color205":                true,
-	"color206":                true,
-	"color207":                true,
-	"color208":                true,
-	"color209":                true,
-	"color21":                 true,
-	"color210":                true,
-	"color211":                true,
-	"color212":                true,
-	"color213":                true,
-	"color214":                true,
-	"color215":                true,
-	"color216":                true,
-	"color217":                true,
-	"color218":                true,
-	"color219":                true,
-	"color22":                 true,
-	"color220":                true,
-	"color221":                true,
-	"color222":                true,
-	"color223":                true,
-	"color224":                true,
-	"color225":                true,
-	"color226":                true,
-	"color227":                true,
-	"color228":                true,
-	"color229":                true,
-	"color23":                 true,
-	"color230":                true,
-	"color231":                true,
-	"color232":                true,
-	"color233":                true,
-	"color234":                true,
-	"color235":                true,
-	"color236":                true,
-	"color237":                true,
-	"color238":                true,
-	"color239":                true,
-	"color24":                 true,
-	"color240":                true,
-	"color241":                true,
-	"color242":                true,
-	"color243":                true,
-	"color244":                true,
-	"color245":                true,
-	"color246":                true,
-	"color247":                true,
-	"color248":                true,
-	"color249":                true,
-	"color25":                 true,
-	"color250":                true,
-	"color251":                true,
-	"color252":                true,
-	"color253":                true,
-	"color254":                true,
-	"color255":                true,
-	"color26":                 true,
-	"color27":                 true,
-	"color28":                 true,
-	"color29":                 true,
-	"color3":                  true,
-	"color30":                 true,
-	"color31":                 true,
-	"color32":                 true,
-	"color33":                 true,
-	"color34":                 true,
-	"color35":                 true,
-	"color36":                 true,
-	"color37":                 true,
-	"color38":                 true,
-	"color39":                 true,
-	"color4":                  true,
-	"color40":                 true,
-	"color41":                 true,
-	"color42":                 true,
-	"color43":                 true,
-	"color44":                 true,
-	"color45":                 true,
-	"color46":                 true,
-	"color47":                 true,
-	"color48":                 true,
-	"color49":                 true,
-	"color5":                  true,
-	"color50":                 true,
-	"color51":                 true,
-	"color52":                 true,
-	"color53":                 true,
-	"color54":                 true,
-	"color55":                 true,
-	"color56":                 true,
-	"color57":                 true,
-	"color58":                 true,
-	"color59":                 true,
-	"color6":                  true,
-	"color60":                 true,
-	"color61":                 true,
-	"color62":                 true,
-	"color63":                 true,
-	"color64":                 true,
-	"color65":                 true,
-	"color66":                 true,
-	"color67":                 true,
-	"color68":                 true,
-	"color69":                 true,
-	"color7":                  true,
-	"color70":                 true,
-	"color71":                 true,
-	"color72":                 true,
-	"color73":                 true,
-	"color74":                 true,
-	"color75":                 true,
-	"color76":                 true,
-	"color77":                 true,
-	"color78":                 true,
-	"color79":                 true,
-	"color8":                  true,
-	"color80":                 true,
-	"color81":                 true,
-	"color82":                 true,
-	"color83":                 true,
-	"color84":                 true,
-	"color85":                 true,
-	"color86":                 true,
-	"color87":                 true,
-	"color88":                 true,
-	"color89":                 true,
-	"color9":                  true,
-	"color90":                 true,
-	"color91":                 true,
-	"color92":                 true,
-	"color93":                 true,
-	"color94":                 true,
-	"color95":                 true,
-	"color96":                 true,
-	"color97":                 true,
-	"color98":                 true,
-	"color99":                 true,
-	"cursor":                  true,
-	"cursor_text_color":       true,
-	"foreground":              true,
-	"inactive_border_color":   true,
-	"inactive_tab_background": true,
-	"inactive_tab_foreground": true,
-	"macos_titlebar_color":    true,
-	"mark1_background":        true,
-	"mark1_foreground":        true,
-	"mark2_background":        true,
-	"mark2_foreground":        true,
-	"mark3_background":        true,
-	"mark3_foreground":        true,
-	"selection_background":    true,
-	"selection_foreground":    true,
-	"tab_bar_background":      true,
-	"tab_bar_margin_color":    true,
-	"url_color":               true,
-	"visual_bell_color":       true,
-	"wayland_titlebar_color":  true, // ALL_COLORS_END
-} // }}}
+var AllColorSettingNames = sync.OnceValue(func() map[string]bool {
+	return map[string]bool{ // {{{
+		// generated by gen-config.py do not edit
+		// ALL_COLORS_START
+		"active_border_color":     true,
+		"active_tab_background":   true,
+		"active_tab_foreground":   true,
+		"background":              true,
+		"bell_border_color":       true,
+		"color0":                  true,
+		"color1":                  true,
+		"color10":                 true,
+		"color100":                true,
+		"color101":                true,
+		"color102":                true,
+		"color103":                true,
+		"color104":                true,
+		"color105":                true,
+		"color106":                true,
+		"color107":                true,
+		"color108":                true,
+		"color109":                true,
+		"color11":                 true,
+		"color110":                true,
+		"color111":                true,
+		"color112":                true,
+		"color113":                true,
+		"color114":                true,
+		"color115":                true,
+		"color116":                true,
+		"color117":                true,
+		"color118":                true,
+		"color119":                true,
+		"color12":                 true,
+		"color120":                true,
+		"color121":                true,
+		"color122":                true,
+		"color123":                true,
+		"color124":                true,
+		"color125":                true,
+		"color126":                true,
+		"color127":                true,
+		"color128":                true,
+		"color129":                true,
+		"color13":                 true,
+		"color130":                true,
+		"color131":                true,
+		"color132":                true,
+		"color133":                true,
+		"color134":                true,
+		"color135":                true,
+		"color136":                true,
+		"color137":                true,
+		"color138":                true,
+		"color139":                true,
+		"color14":                 true,
+		"color140":                true,
+		"color141":                true,
+		"color142":                true,
+		"color143":                true,
+		"color144":                true,
+		"color145":                true,
+		"color146":                true,
+		"color147":                true,
+		"color148":                true,
+		"color149":                true,
+		"color15":                 true,
+		"color150":                true,
+		"color151":                true,
+		"color152":                true,
+		"color153":                true,
+		"color154":                true,
+		"color155":                true,
+		"color156":                true,
+		"color157":                true,
+		"color158":                true,
+		"color159":                true,
+		"color16":                 true,
+		"color160":                true,
+		"color161":                true,
+		"color162":                true,
+		"color163":                true,
+		"color164":                true,
+		"color165":                true,
+		"color166":                true,
+		"color167":                true,
+		"color168":                true,
+		"color169":                true,
+		"color17":                 true,
+		"color170":                true,
+		"color171":                true,
+		"color172":                true,
+		"color173":                true,
+		"color174":                true,
+		"color175":                true,
+		"color176":                true,
+		"color177":                true,
+		"color178":                true,
+		"color179":                true,
+		"color18":                 true,
+		"color180":                true,
+		"color181":                true,
+		"color182":                true,
+		"color183":                true,
+		"color184":                true,
+		"color185":                true,
+		"color186":                true,
+		"color187":                true,
+		"color188":                true,
+		"color189":                true,
+		"color19":                 true,
+		"color190":                true,
+		"color191":                true,
+		"color192":                true,
+		"color193":                true,
+		"color194":                true,
+		"color195":                true,
+		"color196":                true,
+		"color197":                true,
+		"color198":                true,
+		"color199":                true,
+		"color2":                  true,
+		"color20":                 true,
+		"color200":                true,
+		"color201":                true,
+		"color202":                true,
+		"color203":                true,
+		"color204":                true,
+		"color205":                true,
+		"color206":                true,
+		"color207":                true,
+		"color208":                true,
+		"color209":                true,
+		"color21":                 true,
+		"color210":                true,
+		"color211":                true,
+		"color212":                true,
+		"color213":                true,
+		"color214":                true,
+		"color215":                true,
+		"color216":                true,
+		"color217":                true,
+		"color218":                true,
+		"color219":                true,
+		"color22":                 true,
+		"color220":                true,
+		"color221":                true,
+		"color222":                true,
+		"color223":                true,
+		"color224":                true,
+		"color225":                true,
+		"color226":                true,
+		"color227":                true,
+		"color228":                true,
+		"color229":                true,
+		"color23":                 true,
+		"color230":                true,
+		"color231":                true,
+		"color232":                true,
+		"color233":                true,
+		"color234":                true,
+		"color235":                true,
+		"color236":                true,
+		"color237":                true,
+		"color238":                true,
+		"color239":                true,
+		"color24":                 true,
+		"color240":                true,
+		"color241":                true,
+		"color242":                true,
+		"color243":                true,
+		"color244":                true,
+		"color245":                true,
+		"color246":                true,
+		"color247":                true,
+		"color248":                true,
+		"color249":                true,
+		"color25":                 true,
+		"color250":                true,
+		"color251":                true,
+		"color252":                true,
+		"color253":                true,
+		"color254":                true,
+		"color255":                true,
+		"color26":                 true,
+		"color27":                 true,
+		"color28":                 true,
+		"color29":                 true,
+		"color3":                  true,
+		"color30":                 true,
+		"color31":                 true,
+		"color32":                 true,
+		"color33":                 true,
+		"color34":                 true,
+		"color35":                 true,
+		"color36":                 true,
+		"color37":                 true,
+		"color38":                 true,
+		"color39":                 true,
+		"color4":                  true,
+		"color40":                 true,
+		"color41":                 true,
+		"color42":                 true,
+		"color43":                 true,
+		"color44":                 true,
+		"color45":                 true,
+		"color46":                 true,
+		"color47":                 true,
+		"color48":                 true,
+		"color49":                 true,
+		"color5":                  true,
+		"color50":                 true,
+		"color51":                 true,
+		"color52":                 true,
+		"color53":                 true,
+		"color54":                 true,
+		"color55":                 true,
+		"color56":                 true,
+		"color57":                 true,
+		"color58":                 true,
+		"color59":                 true,
+		"color6":                  true,
+		"color60":                 true,
+		"color61":                 true,
+		"color62":                 true,
+		"color63":                 true,
+		"color64":                 true,
+		"color65":                 true,
+		"color66":                 true,
+		"color67":                 true,
+		"color68":                 true,
+		"color69":                 true,
+		"color7":                  true,
+		"color70":                 true,
+		"color71":                 true,
+		"color72":                 true,
+		"color73":                 true,
+		"color74":                 true,
+		"color75":                 true,
+		"color76":                 true,
+		"color77":                 true,
+		"color78":                 true,
+		"color79":                 true,
+		"color8":                  true,
+		"color80":                 true,
+		"color81":                 true,
+		"color82":                 true,
+		"color83":                 true,
+		"color84":                 true,
+		"color85":                 true,
+		"color86":                 true,
+		"color87":                 true,
+		"color88":                 true,
+		"color89":                 true,
+		"color9":                  true,
+		"color90":                 true,
+		"color91":                 true,
+		"color92":                 true,
+		"color93":                 true,
+		"color94":                 true,
+		"color95":                 true,
+		"color96":                 true,
+		"color97":                 true,
+		"color98":                 true,
+		"color99":                 true,
+		"cursor":                  true,
+		"cursor_text_color":       true,
+		"foreground":              true,
+		"inactive_border_color":   true,
+		"inactive_tab_background": true,
+		"inactive_tab_foreground": true,
+		"macos_titlebar_color":    true,
+		"mark1_background":        true,
+		"mark1_foreground":        true,
+		"mark2_background":        true,
+		"mark2_foreground":        true,
+		"mark3_background":        true,
+		"mark3_foreground":        true,
+		"selection_background":    true,
+		"selection_foreground":    true,
+		"tab_bar_background":      true,
+		"tab_bar_margin_color":    true,
+		"url_color":               true,
+		"visual_bell_color":       true,
+		"wayland_titlebar_color":  true, // ALL_COLORS_END
+	}
+}) // }}}
 
 type JSONMetadata struct {
 	Etag      string `json:"etag"`
@@ -334,19 +335,18 @@ func set_comment_in_zip_file(path string, comment string) error {
 		return err
 	}
 	defer src.Close()
-	buf := bytes.Buffer{}
-	dest := zip.NewWriter(&buf)
-	if err = dest.SetComment(comment); err != nil {
-		return err
-	}
-	for _, sf := range src.File {
-		err = dest.Copy(sf)
-		if err != nil {
+	return utils.AtomicWriteFileWith(path, 0o644, func(w io.Writer) error {
+		dest := zip.NewWriter(w)
+		if err := dest.SetComment(comment); err != nil {
 			return err
 		}
-	}
-	dest.Close()
-	return utils.AtomicUpdateFile(path, buf.Bytes(), 0o644)
+		for _, sf := range src.File {
+			if err := dest.Copy(sf); err != nil {
+				return err
+			}
+		}
+		return dest.Close()
+	})
 }
 
 func fetch_cached(name, url, cache_path string, max_cache_age time.Duration) (string, error) {
@@ -374,14 +374,11 @@ func fetch_cached(name, url, cache_path string, max_cache_age time.Duration) (st
 	if max_cache_age < 0 {
 		return "", ErrNoCacheFound
 	}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return "", err
-	}
+	headers := map[string]string{}
 	if jm.Etag != "" {
-		req.Header.Add("If-None-Match", jm.Etag)
+		headers["If-None-Match"] = jm.Etag
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := utils.HTTPGet(url, headers)
 	if err != nil {
 		return "", fmt.Errorf("Failed to download %s with error: %w", url, err)
 	}
@@ -454,7 +451,14 @@ func fetch_cached(name, url, cache_path string, max_cache_age time.Duration) (st
 }
 
 func FetchCached(max_cache_age time.Duration) (string, error) {
-	return fetch_cached("kitty-themes", "https://codeload.github.com/kovidgoyal/kitty-themes/zip/master", utils.CacheDir(), max_cache_age)
+	// the themes namespace of the cache directory has a quota, the zip file
+	// used to be stored in the cache directory itself
+	dir := filepath.Join(utils.CacheDir(), "themes")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	_ = os.Rename(filepath.Join(utils.CacheDir(), "kitty-themes.zip"), filepath.Join(dir, "kitty-themes.zip"))
+	return fetch_cached("kitty-themes", "https://codeload.github.com/kovidgoyal/kitty-themes/zip/master", dir, max_cache_age)
 }
 
 type ThemeMetadata struct {
@@ -552,6 +556,15 @@ func (self *Theme) Blurb() string       { return self.metadata.Blurb }
 func (self *Theme) IsDark() bool        { return self.metadata.Is_dark }
 func (self *Theme) IsUserDefined() bool { return self.is_user_defined }
 
+// Move the file of a user defined theme to the trash, from where the user can
+// restore it
+func (self *Theme) MoveToTrash() error {
+	if !self.is_user_defined || self.path_for_user_defined_theme == "" {
+		return fmt.Errorf("The theme %s is not user defined and cannot be removed", self.Name())
+	}
+	return utils.Trash(self.path_for_user_defined_theme)
+}
+
 func (self *Theme) load_code() (string, error) {
 	if self.zip_reader != nil {
 		f, err := self.zip_reader.Open()
@@ -594,7 +607,7 @@ func patch_conf(text, theme_name string) string {
 		}
 		ntext = text + addition
 	}
-	pat = utils.MustCompile(fmt.Sprintf(`(?m)^\s*(%s)\b`, strings.Join(maps.Keys(AllColorSettingNames), "|")))
+	pat = utils.MustCompile(fmt.Sprintf(`(?m)^\s*(%s)\b`, strings.Join(maps.Keys(AllColorSettingNames()), "|")))
 	return pat.ReplaceAllString(ntext, `# $1`)
 }
 func is_kitty_gui_cmdline(cmd ...string) bool {
@@ -683,7 +696,7 @@ func (self *Theme) SaveInConf(config_dir, reload_in, config_file_name string) (e
 	}
 	nraw := patch_conf(utils.UnsafeBytesToString(raw), self.metadata.Name)
 	if len(raw) > 0 {
-		_ = os.WriteFile(confpath+".bak", raw, 0o600)
+		_ = utils.AtomicWriteFile(confpath+".bak", raw, 0o600)
 	}
 	err = utils.AtomicUpdateFile(confpath, utils.UnsafeStringToBytes(nraw), 0o600)
 	if err != nil {
@@ -916,7 +929,7 @@ func (self *Themes) ThemeByName(name string) *Theme {
 }
 
 func match(expression string, items []string) []*subseq.Match {
-	matches := subseq.ScoreItems(expression, items, subseq.Options{Level1: " "})
+	matches := subseq.NewMatcher(items, subseq.Options{}).Score(expression)
 	matches = utils.StableSort(matches, func(a, b *subseq.Match) int {
 		if b.Score < a.Score {
 			return -1
@@ -0,0 +1,64 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build linux || darwin
+
+package tty
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// Open a new pseudo-terminal, returning its master and slave ends
+func OpenPty() (master, slave *os.File, err error) {
+	fd, err := unix.Open("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to open a pseudo-terminal with error: %w", err)
+	}
+	master = os.NewFile(uintptr(fd), "/dev/ptmx")
+	name, err := unlock_pty(fd)
+	if err == nil {
+		slave, err = os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("Failed to open the slave end of a pseudo-terminal with error: %w", err)
+	}
+	return
+}
+
+func SetSize(fd int, sz *unix.Winsize) error {
+	return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, sz)
+}
+
+// Start cmd in a new session with a new pseudo-terminal of the specified
+// size as its controlling terminal and STDIO, returning the master end of it
+func StartInPty(cmd *exec.Cmd, sz *unix.Winsize) (master *os.File, err error) {
+	master, slave, err := OpenPty()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+	if sz != nil {
+		if err = SetSize(int(slave.Fd()), sz); err != nil {
+			master.Close()
+			return nil, err
+		}
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid, cmd.SysProcAttr.Setctty, cmd.SysProcAttr.Ctty = true, true, 0
+	if err = cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
@@ -0,0 +1,29 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tty
+
+import (
+	"bytes"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Unlock the pseudo-terminal with master fd, returning the path to its slave end
+func unlock_pty(fd int) (string, error) {
+	if err := unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+		return "", err
+	}
+	if err := unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0); err != nil {
+		return "", err
+	}
+	// TIOCPTYGNAME needs a buffer of 128 bytes
+	buf := make([]byte, 128)
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(unix.TIOCPTYGNAME), uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+		return "", errno
+	}
+	if i := bytes.IndexByte(buf, 0); i > -1 {
+		buf = buf[:i]
+	}
+	return string(buf), nil
+}
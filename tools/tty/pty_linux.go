@@ -0,0 +1,21 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tty
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Unlock the pseudo-terminal with master fd, returning the path to its slave end
+func unlock_pty(fd int) (string, error) {
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		return "", err
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		return "", err
+	}
+	return "/dev/pts/" + strconv.Itoa(n), nil
+}
@@ -0,0 +1,27 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !linux && !darwin
+
+package tty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"golang.org/x/sys/unix"
+)
+
+var ErrPtyNotSupported = errors.New("Pseudo-terminals are not supported on this platform")
+
+func OpenPty() (master, slave *os.File, err error) {
+	return nil, nil, ErrPtyNotSupported
+}
+
+func SetSize(fd int, sz *unix.Winsize) error {
+	return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, sz)
+}
+
+func StartInPty(cmd *exec.Cmd, sz *unix.Winsize) (master *os.File, err error) {
+	return nil, ErrPtyNotSupported
+}
@@ -0,0 +1,264 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+// Package canvas draws bitmaps using text characters, for use when the
+// graphics protocol is not available, such as in the Linux console or in
+// terminals that do not support it.
+package canvas
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+
+	"kitty/tools/utils"
+
+	"github.com/kovidgoyal/imaging"
+)
+
+var _ = fmt.Print
+
+type Style int
+
+const (
+	// Each cell has 1x2 pixels drawn with the upper and lower half block
+	// characters, with full color for every pixel
+	HalfBlocks Style = iota
+	// Each cell has 2x4 pixels drawn with braille characters, giving higher
+	// resolution but only a single color per cell
+	Braille
+)
+
+// The pixels in each cell
+func (self Style) CellSize() (width, height int) {
+	if self == Braille {
+		return 2, 4
+	}
+	return 1, 2
+}
+
+type Canvas struct {
+	Style     Style
+	ColorMode ColorMode
+	// The rows and columns of cells
+	Cols, Rows int
+
+	width, height int
+	pixels        []color.NRGBA
+}
+
+func New(style Style, color_mode ColorMode, cols, rows int) *Canvas {
+	cols, rows = max(0, cols), max(0, rows)
+	cw, ch := style.CellSize()
+	ans := Canvas{Style: style, ColorMode: color_mode, Cols: cols, Rows: rows, width: cols * cw, height: rows * ch}
+	ans.pixels = make([]color.NRGBA, ans.width*ans.height)
+	return &ans
+}
+
+// The size of the canvas in pixels
+func (self *Canvas) Size() (width, height int) { return self.width, self.height }
+
+// Set the pixel at the specified position, pixels with zero alpha are not
+// drawn, letting the terminal background show through
+func (self *Canvas) Set(x, y int, c color.NRGBA) {
+	if x >= 0 && y >= 0 && x < self.width && y < self.height {
+		self.pixels[y*self.width+x] = c
+	}
+}
+
+func (self *Canvas) At(x, y int) color.NRGBA {
+	if x >= 0 && y >= 0 && x < self.width && y < self.height {
+		return self.pixels[y*self.width+x]
+	}
+	return color.NRGBA{}
+}
+
+func (self *Canvas) Clear() {
+	clear(self.pixels)
+}
+
+func luminance(c color.NRGBA) float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
+
+// Draw img scaled to fill the canvas. With the braille style, only pixels
+// brighter than the average brightness of the image are drawn, so that the
+// image is recognizable with a single color per cell.
+func (self *Canvas) DrawImage(img image.Image) {
+	if self.width == 0 || self.height == 0 {
+		return
+	}
+	scaled := imaging.Resize(img, self.width, self.height, imaging.Box)
+	for y := 0; y < self.height; y++ {
+		row := scaled.Pix[y*scaled.Stride:]
+		for x := 0; x < self.width; x++ {
+			self.pixels[y*self.width+x] = color.NRGBA{row[4*x], row[4*x+1], row[4*x+2], row[4*x+3]}
+		}
+	}
+	if self.Style != Braille {
+		return
+	}
+	var total float64
+	count := 0
+	for _, p := range self.pixels {
+		if p.A >= 0x80 {
+			total += luminance(p)
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	threshold := total / float64(count)
+	for i, p := range self.pixels {
+		if p.A < 0x80 || luminance(p) < threshold {
+			self.pixels[i] = color.NRGBA{}
+		}
+	}
+}
+
+// The number of cells needed to display an image of the specified size in
+// pixels, preserving its aspect ratio and fitting within max_cols x max_rows.
+// Images are never scaled up. Assumes cells are twice as tall as they are
+// wide.
+func FitImage(style Style, width, height, max_cols, max_rows int) (cols, rows int) {
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	cw, ch := style.CellSize()
+	scale := min(1, float64(max_cols*cw)/float64(width), float64(max_rows*ch)/float64(height))
+	cols = max(1, int(float64(width)*scale)/cw)
+	rows = max(1, int(float64(height)*scale+float64(ch)-1)/ch)
+	return min(cols, max(1, max_cols)), min(rows, max(1, max_rows))
+}
+
+// Writes a line of cells, only emitting SGR codes when the colors change.
+// Empty colors mean the default colors.
+type line_writer struct {
+	strings.Builder
+	fg, bg string
+}
+
+func (self *line_writer) write(ch string, fg, bg string) {
+	if ch == " " {
+		// the foreground color does not matter for blank cells
+		fg = self.fg
+	}
+	codes := make([]string, 0, 2)
+	if fg != self.fg {
+		codes = append(codes, utils.IfElse(fg == "", "39", fg))
+		self.fg = fg
+	}
+	if bg != self.bg {
+		codes = append(codes, utils.IfElse(bg == "", "49", bg))
+		self.bg = bg
+	}
+	if len(codes) > 0 {
+		self.WriteString("\x1b[" + strings.Join(codes, ";") + "m")
+	}
+	self.WriteString(ch)
+}
+
+func (self *line_writer) finish() string {
+	if self.fg != "" || self.bg != "" {
+		self.WriteString("\x1b[39;49m")
+	}
+	return self.String()
+}
+
+func (self *Canvas) half_block_line(r int) string {
+	w := line_writer{}
+	m := self.ColorMode
+	for c := 0; c < self.Cols; c++ {
+		top, bottom := self.At(c, 2*r), self.At(c, 2*r+1)
+		if m == NoColor {
+			t, b := top.A >= 0x80 && luminance(top) >= 0.5, bottom.A >= 0x80 && luminance(bottom) >= 0.5
+			switch {
+			case t && b:
+				w.write("█", "", "")
+			case t:
+				w.write("▀", "", "")
+			case b:
+				w.write("▄", "", "")
+			default:
+				w.write(" ", "", "")
+			}
+			continue
+		}
+		t, b := top.A >= 0x80, bottom.A >= 0x80
+		switch {
+		case t && b:
+			tfg, bfg := m.sgr(top, true), m.sgr(bottom, true)
+			if tfg == bfg {
+				w.write("█", tfg, "")
+			} else {
+				w.write("▀", tfg, m.sgr(bottom, false))
+			}
+		case t:
+			w.write("▀", m.sgr(top, true), "")
+		case b:
+			w.write("▄", m.sgr(bottom, true), "")
+		default:
+			w.write(" ", "", "")
+		}
+	}
+	return w.finish()
+}
+
+// The bit for each dot in a braille cell indexed by [y][x]
+var braille_bits = [4][2]uint8{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+func (self *Canvas) braille_line(r int) string {
+	w := line_writer{}
+	for c := 0; c < self.Cols; c++ {
+		var dots uint8
+		var red, green, blue, n int
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 2; x++ {
+				if p := self.At(2*c+x, 4*r+y); p.A >= 0x80 {
+					dots |= braille_bits[y][x]
+					red, green, blue, n = red+int(p.R), green+int(p.G), blue+int(p.B), n+1
+				}
+			}
+		}
+		if dots == 0 {
+			w.write(" ", "", "")
+			continue
+		}
+		fg := ""
+		if self.ColorMode != NoColor {
+			fg = self.ColorMode.sgr(color.NRGBA{uint8(red / n), uint8(green / n), uint8(blue / n), 0xff}, true)
+		}
+		w.write(string(rune(0x2800+int(dots))), fg, "")
+	}
+	return w.finish()
+}
+
+// The rows of the canvas as text with SGR color codes. Every line ends with
+// the default colors restored.
+func (self *Canvas) Lines() []string {
+	ans := make([]string, self.Rows)
+	for r := range ans {
+		if self.Style == Braille {
+			ans[r] = self.braille_line(r)
+		} else {
+			ans[r] = self.half_block_line(r)
+		}
+	}
+	return ans
+}
+
+// Render img as lines of text fitting within max_cols x max_rows cells
+func RenderImage(img image.Image, style Style, color_mode ColorMode, max_cols, max_rows int) []string {
+	b := img.Bounds()
+	cols, rows := FitImage(style, b.Dx(), b.Dy(), max_cols, max_rows)
+	c := New(style, color_mode, cols, rows)
+	c.DrawImage(img)
+	return c.Lines()
+}
+
+// Guess the number of colors the terminal supports from the environment
+func ColorModeFromEnvironment() ColorMode {
+	return color_mode_from_environment(os.Getenv)
+}
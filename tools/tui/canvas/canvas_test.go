@@ -0,0 +1,116 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package canvas
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"kitty/tools/wcswidth"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestCanvas(t *testing.T) {
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+
+	c := New(HalfBlocks, TrueColor, 3, 1)
+	c.Set(0, 0, red)
+	c.Set(1, 0, red)
+	c.Set(1, 1, blue)
+	c.Set(2, 1, red)
+	if diff := cmp.Diff([]string{
+		"\x1b[38;2;255;0;0m▀\x1b[48;2;0;0;255m▀\x1b[49m▄\x1b[39;49m",
+	}, c.Lines()); diff != "" {
+		t.Fatalf("Incorrect half block rendering:\n%s", diff)
+	}
+
+	c = New(Braille, Colors16, 2, 1)
+	c.Set(0, 0, red)
+	c.Set(1, 3, red)
+	if diff := cmp.Diff([]string{"\x1b[91m⢁ \x1b[39;49m"}, c.Lines()); diff != "" {
+		t.Fatalf("Incorrect braille rendering:\n%s", diff)
+	}
+	c.Clear()
+	if diff := cmp.Diff([]string{"  "}, c.Lines()); diff != "" {
+		t.Fatalf("Incorrect rendering of empty canvas:\n%s", diff)
+	}
+
+	for _, x := range []struct {
+		c        color.NRGBA
+		expected string
+	}{
+		{color.NRGBA{0, 0, 0, 255}, "38;5;16"},
+		{color.NRGBA{255, 255, 255, 255}, "38;5;231"},
+		{color.NRGBA{128, 128, 128, 255}, "38;5;244"},
+		{color.NRGBA{255, 0, 0, 255}, "38;5;196"},
+		{color.NRGBA{95, 135, 175, 255}, "38;5;67"},
+	} {
+		if actual := Colors256.sgr(x.c, true); actual != x.expected {
+			t.Fatalf("Incorrect 256 color quantization for %v: %s != %s", x.c, x.expected, actual)
+		}
+	}
+	if actual := Colors16.sgr(color.NRGBA{10, 10, 200, 255}, false); actual != "44" {
+		t.Fatalf("Incorrect 16 color quantization: %s", actual)
+	}
+
+	for _, x := range []struct {
+		style                                Style
+		w, h, max_cols, max_rows, cols, rows int
+	}{
+		{HalfBlocks, 100, 100, 80, 24, 48, 24},
+		{HalfBlocks, 10, 10, 80, 24, 10, 5},
+		{HalfBlocks, 200, 10, 80, 24, 80, 2},
+		{Braille, 100, 100, 80, 24, 48, 24},
+		{Braille, 8, 8, 80, 24, 4, 2},
+	} {
+		cols, rows := FitImage(x.style, x.w, x.h, x.max_cols, x.max_rows)
+		if cols != x.cols || rows != x.rows {
+			t.Fatalf("Incorrect fit for %v: %dx%d != %dx%d", x, x.cols, x.rows, cols, rows)
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if x >= 20 {
+				img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+			}
+		}
+	}
+	for _, style := range []Style{HalfBlocks, Braille} {
+		lines := RenderImage(img, style, NoColor, 10, 10)
+		if len(lines) != 5 {
+			t.Fatalf("Incorrect number of lines: %d", len(lines))
+		}
+		for _, line := range lines {
+			if wcswidth.Stringwidth(line) != 10 || []rune(line)[0] != ' ' || []rune(line)[9] == ' ' {
+				t.Fatalf("Incorrect rendering of image with style %v: %#v", style, line)
+			}
+		}
+	}
+}
+
+func TestColorModeFromEnvironment(t *testing.T) {
+	for _, x := range []struct {
+		colorterm, term string
+		expected        ColorMode
+	}{
+		{"truecolor", "xterm-256color", TrueColor},
+		{"", "xterm-256color", Colors256},
+		{"", "linux", Colors16},
+		{"", "xterm-kitty", TrueColor},
+		{"", "dumb", NoColor},
+	} {
+		env := map[string]string{"COLORTERM": x.colorterm, "TERM": x.term}
+		if actual := color_mode_from_environment(func(k string) string { return env[k] }); actual != x.expected {
+			t.Fatalf("Incorrect color mode for %v: %v", x, actual)
+		}
+	}
+}
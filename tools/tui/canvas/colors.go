@@ -0,0 +1,117 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package canvas
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// How colors are quantized for display
+type ColorMode int
+
+const (
+	TrueColor ColorMode = iota
+	// The xterm 256 color palette
+	Colors256
+	// The 16 basic ANSI colors, as supported by the Linux console
+	Colors16
+	// Only the default foreground color
+	NoColor
+)
+
+// The default colors used by xterm for the 16 basic colors
+var basic_colors = [16]color.NRGBA{
+	{0, 0, 0, 0xff}, {205, 0, 0, 0xff}, {0, 205, 0, 0xff}, {205, 205, 0, 0xff},
+	{0, 0, 238, 0xff}, {205, 0, 205, 0xff}, {0, 205, 205, 0xff}, {229, 229, 229, 0xff},
+	{127, 127, 127, 0xff}, {255, 0, 0, 0xff}, {0, 255, 0, 0xff}, {255, 255, 0, 0xff},
+	{92, 92, 255, 0xff}, {255, 0, 255, 0xff}, {0, 255, 255, 0xff}, {255, 255, 255, 0xff},
+}
+
+var cube_levels = [6]int{0, 95, 135, 175, 215, 255}
+
+func distance(a color.NRGBA, r, g, b int) int {
+	dr, dg, db := int(a.R)-r, int(a.G)-g, int(a.B)-b
+	return dr*dr + dg*dg + db*db
+}
+
+func nearest_basic_color(c color.NRGBA) (ans int) {
+	best := -1
+	for i, q := range basic_colors {
+		if d := distance(c, int(q.R), int(q.G), int(q.B)); best < 0 || d < best {
+			best, ans = d, i
+		}
+	}
+	return
+}
+
+func nearest_cube_level(v uint8) int {
+	// the midpoints between the levels
+	for i, limit := range [5]uint8{48, 115, 155, 195, 235} {
+		if v < limit {
+			return i
+		}
+	}
+	return 5
+}
+
+// The index of the closest color in the xterm 256 color palette, ignoring
+// the 16 basic colors, as those are often changed by color themes
+func nearest_256_color(c color.NRGBA) int {
+	r, g, b := nearest_cube_level(c.R), nearest_cube_level(c.G), nearest_cube_level(c.B)
+	cube := 16 + 36*r + 6*g + b
+	cube_distance := distance(c, cube_levels[r], cube_levels[g], cube_levels[b])
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	gray := 23
+	if avg < 238 {
+		gray = max(0, (avg-3)/10)
+	}
+	gray_level := 8 + 10*gray
+	if distance(c, gray_level, gray_level, gray_level) < cube_distance {
+		return 232 + gray
+	}
+	return cube
+}
+
+// The SGR parameters to use c as the foreground or background color
+func (self ColorMode) sgr(c color.NRGBA, fg bool) string {
+	base := 38
+	if !fg {
+		base = 48
+	}
+	switch self {
+	case Colors256:
+		return fmt.Sprintf("%d;5;%d", base, nearest_256_color(c))
+	case Colors16:
+		base -= 8
+		idx := nearest_basic_color(c)
+		if idx > 7 {
+			base += 60
+			idx -= 8
+		}
+		return fmt.Sprint(base + idx)
+	case NoColor:
+		return ""
+	}
+	return fmt.Sprintf("%d;2;%d;%d;%d", base, c.R, c.G, c.B)
+}
+
+func color_mode_from_environment(getenv func(string) string) ColorMode {
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return TrueColor
+	}
+	term := getenv("TERM")
+	switch {
+	case term == "dumb":
+		return NoColor
+	case term == "linux" || term == "" || strings.HasPrefix(term, "vt"):
+		return Colors16
+	case term == "xterm-kitty" || term == "xterm-ghostty" || strings.HasSuffix(term, "-direct"):
+		return TrueColor
+	}
+	return Colors256
+}
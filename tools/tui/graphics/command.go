@@ -5,7 +5,6 @@ package graphics
 import (
 	"bytes"
 	"compress/zlib"
-	"encoding/base64"
 	"fmt"
 	"io"
 	"strconv"
@@ -14,6 +13,7 @@ import (
 	"kitty/tools/tty"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
+	"kitty/tools/utils/base64"
 )
 
 var _ = fmt.Print
@@ -207,7 +207,7 @@ func (self GraphicsCommand) String() string {
 var debugprintln = tty.DebugPrintln
 var _ = debugprintln
 
-func (self *GraphicsCommand) serialize_to(buf io.StringWriter, chunk string) (err error) {
+func (self *GraphicsCommand) serialize_to(buf io.StringWriter, chunk []byte) (err error) {
 	var ws func(string)
 	if self.EncodeSerializedDataFunc == nil {
 		ws = func(s string) {
@@ -239,7 +239,13 @@ func (self *GraphicsCommand) serialize_to(buf io.StringWriter, chunk string) (er
 			if len(chunk) > 0 {
 				ws(";")
 				if err == nil {
-					ws(chunk)
+					// chunk is in a re-used buffer so it must be copied unless
+					// it can be written directly
+					if w, ok := buf.(io.Writer); ok && self.EncodeSerializedDataFunc == nil {
+						_, err = w.Write(chunk)
+					} else {
+						ws(string(chunk))
+					}
 				}
 			}
 			if err == nil {
@@ -269,13 +275,10 @@ func (self *GraphicsCommand) AsAPC(payload []byte) string {
 func (self *GraphicsCommand) WriteWithPayloadTo(o io.StringWriter, payload []byte) (err error) {
 	const compression_threshold = 2048
 	if len(payload) == 0 {
-		return self.serialize_to(o, "")
+		return self.serialize_to(o, nil)
 	}
-	if len(payload) <= compression_threshold {
-		return self.serialize_to(o, base64.RawStdEncoding.EncodeToString(payload))
-	}
-	gc := *self
-	if !self.DisableCompression && self.Format() != GRT_format_png {
+	gc, is_small := *self, len(payload) <= compression_threshold
+	if !is_small && !self.DisableCompression && self.Format() != GRT_format_png {
 		compressed := compress_with_zlib(payload)
 		if len(compressed) < len(payload) {
 			gc.SetCompression(GRT_compression_zlib)
@@ -283,29 +286,22 @@ func (self *GraphicsCommand) WriteWithPayloadTo(o io.StringWriter, payload []byt
 		}
 	}
 	const chunk_size = 128 * 1024
-	data := base64.RawStdEncoding.EncodeToString(payload)
-	for len(data) > 0 && err == nil {
-		chunk := data
-		if len(data) > chunk_size {
-			chunk = data[:chunk_size]
-			data = data[chunk_size:]
-		} else {
-			data = ""
-		}
-		if len(data) > 0 {
-			gc.m = GRT_more_more
-		} else {
-			gc.m = GRT_more_nomore
+	return base64.EncodeChunked(payload, chunk_size, func(chunk []byte, is_last bool) error {
+		if !is_small {
+			if is_last {
+				gc.m = GRT_more_nomore
+			} else {
+				gc.m = GRT_more_more
+			}
 		}
-		err = gc.serialize_to(o, chunk)
-		if err != nil {
+		if err := gc.serialize_to(o, chunk); err != nil {
 			return err
 		}
 		gc = GraphicsCommand{
 			q: self.q, a: self.a, WrapPrefix: self.WrapPrefix, WrapSuffix: self.WrapSuffix,
 			EncodeSerializedDataFunc: self.EncodeSerializedDataFunc}
-	}
-	return
+		return nil
+	})
 }
 
 type loop_io_writer struct {
@@ -317,6 +313,11 @@ func (self *loop_io_writer) WriteString(data string) (n int, err error) {
 	return
 }
 
+func (self *loop_io_writer) Write(data []byte) (n int, err error) {
+	self.lp.QueueWriteBytesCopy(data)
+	return
+}
+
 func (self *GraphicsCommand) WriteWithPayloadToLoop(lp *loop.Loop, payload []byte) (err error) {
 	w := loop_io_writer{lp}
 	return self.WriteWithPayloadTo(&w, payload)
@@ -100,3 +100,46 @@ func TestGraphicsCommandSerialization(t *testing.T) {
 	test_chunked_payload([]byte(strings.Repeat("a", 8007)))
 
 }
+
+// The serialization used before payloads were encoded in chunks into a
+// pooled buffer, kept for comparison
+func legacy_write_with_payload_to(o io.StringWriter, payload []byte) {
+	const chunk_size = 128 * 1024
+	data := base64.RawStdEncoding.EncodeToString(payload)
+	gc := GraphicsCommand{}
+	for len(data) > 0 {
+		chunk := data
+		if len(data) > chunk_size {
+			chunk, data = data[:chunk_size], data[chunk_size:]
+		} else {
+			data = ""
+		}
+		gc.m = GRT_more_nomore
+		if len(data) > 0 {
+			gc.m = GRT_more_more
+		}
+		_, _ = o.WriteString("\033_G" + strings.Join(gc.serialize_non_default_fields(), ",") + ";")
+		_, _ = o.WriteString(chunk)
+		_, _ = o.WriteString("\033\\")
+	}
+}
+
+func BenchmarkWriteWithPayloadTo(b *testing.B) {
+	payload := make([]byte, 4*1024*1024)
+	_, _ = rand.Read(payload)
+	gc := &GraphicsCommand{DisableCompression: true}
+	b.Run("chunked", func(b *testing.B) {
+		b.SetBytes(int64(len(payload)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = gc.WriteWithPayloadTo(io.Discard.(io.StringWriter), payload)
+		}
+	})
+	b.Run("legacy", func(b *testing.B) {
+		b.SetBytes(int64(len(payload)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			legacy_write_with_payload_to(io.Discard.(io.StringWriter), payload)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package graphics
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty"
+	"kitty/tools/utils/images"
+)
+
+var _ = fmt.Print
+
+// The maximum number of rows or columns an image displayed using Unicode
+// placeholders can have
+const MaxPlaceholderSize = len(images.NumberToDiacritic)
+
+// An image displayed using Unicode placeholders. The image must be
+// transmitted to the terminal and have a virtual placement, created using
+// the command returned by PlacementCommand(). The placeholder characters can
+// then be placed in the text of any program, such as editors or terminal
+// multiplexers, that does not understand the graphics protocol, and the
+// terminal will display the image in their place. See
+// https://sw.kovidgoyal.net/kitty/graphics-protocol/#unicode-placeholders
+type Placeholder struct {
+	ImageId, PlacementId uint32
+	Columns, Rows        int
+}
+
+func (self Placeholder) Validate() error {
+	if self.ImageId == 0 {
+		return fmt.Errorf("Images displayed using Unicode placeholders must have a non-zero image id")
+	}
+	if self.Columns < 1 || self.Rows < 1 {
+		return fmt.Errorf("Invalid size for Unicode placeholder: %dx%d", self.Columns, self.Rows)
+	}
+	if max(self.Columns, self.Rows) > MaxPlaceholderSize {
+		return fmt.Errorf("Image too large to be displayed using Unicode placeholders. Maximum size is %dx%d cells", MaxPlaceholderSize, MaxPlaceholderSize)
+	}
+	return nil
+}
+
+// Whether the image id can be represented by programs that only support
+// 256 colors and two combining characters per cell. Such ids have non-zero
+// most significant and middle bytes, so they do not collide with ids from
+// programs that cannot represent them.
+func IsRobustPlaceholderImageId(id uint32) bool {
+	return id&0xFF000000 != 0 && id&0x00FFFF00 != 0
+}
+
+// The foreground color encoding the lower 24 bits of the image id, the same
+// color must be used for all cells of the image
+func (self Placeholder) ForegroundColor() (r, g, b uint8) {
+	return uint8(self.ImageId >> 16), uint8(self.ImageId >> 8), uint8(self.ImageId)
+}
+
+// The underline color encoding the placement id, only needed when the
+// image has more than one virtual placement
+func (self Placeholder) UnderlineColor() (r, g, b uint8) {
+	return uint8(self.PlacementId >> 16), uint8(self.PlacementId >> 8), uint8(self.PlacementId)
+}
+
+// The escape codes to set the colors for the cells of this image
+func (self Placeholder) SGR() string {
+	r, g, b := self.ForegroundColor()
+	ans := fmt.Sprintf("\x1b[38:2:%d:%d:%dm", r, g, b)
+	if self.PlacementId != 0 {
+		r, g, b = self.UnderlineColor()
+		ans += fmt.Sprintf("\x1b[58:2:%d:%d:%dm", r, g, b)
+	}
+	return ans
+}
+
+// The escape codes to reset the colors set by SGR()
+func (self Placeholder) ResetSGR() string {
+	if self.PlacementId != 0 {
+		return "\x1b[39;59m"
+	}
+	return "\x1b[39m"
+}
+
+// The text for a single cell of the image, a placeholder character with
+// diacritics encoding the row, column and most significant byte of the
+// image id. Programs that store cells individually should use this along
+// with the colors from ForegroundColor() and UnderlineColor().
+func (self Placeholder) Cell(row, col int) string {
+	ans := make([]rune, 0, 4)
+	ans = append(ans, kitty.ImagePlaceholderChar, images.NumberToDiacritic[row], images.NumberToDiacritic[col])
+	if msb := self.ImageId >> 24; msb != 0 {
+		ans = append(ans, images.NumberToDiacritic[msb])
+	}
+	return string(ans)
+}
+
+// The text for a single row of the image, without any color escape codes
+func (self Placeholder) Row(row int) string {
+	buf := strings.Builder{}
+	buf.Grow(self.Columns * 12)
+	for c := 0; c < self.Columns; c++ {
+		buf.WriteString(self.Cell(row, c))
+	}
+	return buf.String()
+}
+
+// All rows of the image, with color escape codes, each row terminated by a
+// newline
+func (self Placeholder) Grid() string {
+	sgr, reset := self.SGR(), self.ResetSGR()
+	buf := strings.Builder{}
+	for r := 0; r < self.Rows; r++ {
+		buf.WriteString(sgr)
+		buf.WriteString(self.Row(r))
+		buf.WriteString(reset)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// The command to create the virtual placement for an image that has already
+// been transmitted to the terminal
+func (self Placeholder) PlacementCommand() *GraphicsCommand {
+	gc := &GraphicsCommand{}
+	gc.SetAction(GRT_action_display).SetUnicodePlaceholder(GRT_create_unicode_placeholder).SetImageId(self.ImageId)
+	gc.SetColumns(uint64(self.Columns)).SetRows(uint64(self.Rows)).SetQuiet(GRT_quiet_silent)
+	if self.PlacementId != 0 {
+		gc.SetPlacementId(self.PlacementId)
+	}
+	return gc
+}
@@ -0,0 +1,43 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package graphics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestUnicodePlaceholder(t *testing.T) {
+	p := Placeholder{ImageId: 42, Columns: 2, Rows: 2}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\x1b[38:2:0:0:42m\U0010EEEE\u0305\u0305\U0010EEEE\u0305\u030d\x1b[39m\n" +
+		"\x1b[38:2:0:0:42m\U0010EEEE\u030d\u0305\U0010EEEE\u030d\u030d\x1b[39m\n"
+	if diff := cmp.Diff(expected, p.Grid()); diff != "" {
+		t.Fatalf("Incorrect grid for: %#v\n%s", p, diff)
+	}
+	p.ImageId = 42 + (2 << 24)
+	if diff := cmp.Diff("\U0010EEEE\u030d\u0305\u030e\U0010EEEE\u030d\u030d\u030e", p.Row(1)); diff != "" {
+		t.Fatalf("Incorrect row with most significant byte in id:\n%s", diff)
+	}
+	p.PlacementId = 0x010203
+	if diff := cmp.Diff("\x1b[38:2:0:0:42m\x1b[58:2:1:2:3m", p.SGR()); diff != "" {
+		t.Fatalf("Incorrect SGR with placement id:\n%s", diff)
+	}
+	if diff := cmp.Diff("\x1b_Ga=p,q=2,U=1,c=2,r=2,i=33554474,p=66051\x1b\\", p.PlacementCommand().AsAPC(nil)); diff != "" {
+		t.Fatalf("Incorrect placement command:\n%s", diff)
+	}
+	for _, bad := range []Placeholder{{Columns: 1, Rows: 1}, {ImageId: 1}, {ImageId: 1, Columns: MaxPlaceholderSize + 1, Rows: 1}} {
+		if bad.Validate() == nil {
+			t.Fatalf("Invalid placeholder did not fail validation: %#v", bad)
+		}
+	}
+	if IsRobustPlaceholderImageId(42) || !IsRobustPlaceholderImageId(0x01020304) {
+		t.Fatalf("IsRobustPlaceholderImageId() is incorrect")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 
 	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
 )
 
 var _ = fmt.Print
@@ -41,10 +42,15 @@ func HoldTillEnter(start_with_newline bool) {
 	lp.Run()
 }
 
+func exit(code int) {
+	utils.CleanupAtExit()
+	os.Exit(code)
+}
+
 func ExecAndHoldTillEnter(cmdline []string) {
 	if len(cmdline) == 0 {
 		HoldTillEnter(false)
-		os.Exit(0)
+		exit(0)
 	}
 	var cmd *exec.Cmd
 	if len(cmdline) == 1 {
@@ -63,10 +69,10 @@ func ExecAndHoldTillEnter(cmdline []string) {
 	}
 	HoldTillEnter(true)
 	if err == nil {
-		os.Exit(0)
+		exit(0)
 	}
 	if is_exit_error {
-		os.Exit(ee.ExitCode())
+		exit(ee.ExitCode())
 	}
-	os.Exit(1)
+	exit(1)
 }
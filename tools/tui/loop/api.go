@@ -55,6 +55,9 @@ type Loop struct {
 	style_ctx                              style.Context
 	atomic_update_active                   bool
 	pointer_shapes                         []PointerShape
+	state_stack                            terminal_state_stack
+	key_event                              KeyEvent
+	mouse_event                            MouseEvent
 
 	// Suspend the loop restoring terminal state, and run the provided function. When it returns terminal state is
 	// put back to what it was before suspending unless the function returns an error or an error occurs saving/restoring state.
@@ -70,10 +73,12 @@ type Loop struct {
 	// shutdown
 	OnFinalize func() string
 
-	// Called when a key event happens
+	// Called when a key event happens. The event is re-used for subsequent
+	// events, so copy it if it needs to be retained.
 	OnKeyEvent func(event *KeyEvent) error
 
-	// Called when a mouse event happens
+	// Called when a mouse event happens. The event is re-used for
+	// subsequent events, so copy it if it needs to be retained.
 	OnMouseEvent func(event *MouseEvent) error
 
 	// Called when text is received either from a key event or directly from the terminal
@@ -253,6 +258,8 @@ func (self *Loop) DebugPrintln(args ...any) {
 func (self *Loop) Run() (err error) {
 	defer func() {
 		if r := recover(); r != nil {
+			// the panic may have prevented the loop from restoring the terminal
+			self.state_stack.restore()
 			pcs := make([]uintptr, 256)
 			n := runtime.Callers(2, pcs)
 			frames := runtime.CallersFrames(pcs[:n])
@@ -308,9 +315,11 @@ func (self *Loop) UnsafeQueueWriteBytes(data []byte) IdType {
 }
 
 func (self *Loop) QueueWriteBytesCopy(data []byte) IdType {
-	d := make([]byte, len(data))
-	copy(d, data)
-	return self.UnsafeQueueWriteBytes(d)
+	self.write_msg_id_counter++
+	msg := new_pooled_write_msg(data)
+	msg.id = self.write_msg_id_counter
+	self.add_write_to_pending_queue(msg)
+	return msg.id
 }
 
 func (self *Loop) ExitCode() int {
@@ -326,6 +335,7 @@ func (self *Loop) StartAtomicUpdate() {
 		self.EndAtomicUpdate()
 	}
 	self.QueueWriteString(PENDING_UPDATE.EscapeCodeToSet())
+	self.state_stack.push("atomic update", PENDING_UPDATE.EscapeCodeToReset(), nil)
 	self.atomic_update_active = true
 }
 
@@ -334,6 +344,7 @@ func (self *Loop) IsAtomicUpdateActive() bool { return self.atomic_update_active
 func (self *Loop) EndAtomicUpdate() {
 	if self.atomic_update_active {
 		self.QueueWriteString(PENDING_UPDATE.EscapeCodeToReset())
+		self.state_stack.pop("atomic update")
 		self.atomic_update_active = false
 	}
 }
@@ -345,8 +356,10 @@ func (self *Loop) SetCursorShape(shape CursorShapes, blink bool) {
 func (self *Loop) SetCursorVisible(visible bool) {
 	if visible {
 		self.QueueWriteString(DECTCEM.EscapeCodeToSet())
+		self.state_stack.pop("hidden cursor")
 	} else {
 		self.QueueWriteString(DECTCEM.EscapeCodeToReset())
+		self.state_stack.push("hidden cursor", DECTCEM.EscapeCodeToSet(), nil)
 	}
 }
 
@@ -390,17 +403,21 @@ func (self *Loop) ClearToEndOfLine() {
 
 func (self *Loop) StartBracketedPaste() {
 	self.QueueWriteString(BRACKETED_PASTE.EscapeCodeToSet())
+	self.state_stack.push("bracketed paste", BRACKETED_PASTE.EscapeCodeToReset(), nil)
 }
 
 func (self *Loop) EndBracketedPaste() {
 	self.QueueWriteString(BRACKETED_PASTE.EscapeCodeToReset())
+	self.state_stack.pop("bracketed paste")
 }
 
 func (self *Loop) AllowLineWrapping(allow bool) {
 	if allow {
 		self.QueueWriteString(DECAWM.EscapeCodeToSet())
+		self.state_stack.pop("no line wrapping")
 	} else {
 		self.QueueWriteString(DECAWM.EscapeCodeToReset())
+		self.state_stack.push("no line wrapping", DECAWM.EscapeCodeToSet(), nil)
 	}
 }
 
@@ -457,12 +474,14 @@ func (self *Loop) CopyTextToClipboard(text string) {
 func (self *Loop) PushPointerShape(s PointerShape) {
 	self.pointer_shapes = append(self.pointer_shapes, s)
 	self.QueueWriteString("\x1b]22;" + s.String() + "\x1b\\")
+	self.record_pointer_shapes()
 }
 
 func (self *Loop) PopPointerShape() {
 	if len(self.pointer_shapes) > 0 {
 		self.pointer_shapes = self.pointer_shapes[:len(self.pointer_shapes)-1]
 		self.QueueWriteString("\x1b]22;<\x1b\\")
+		self.record_pointer_shapes()
 	}
 }
 
@@ -472,9 +491,18 @@ func (self *Loop) ClearPointerShapes() (ans []PointerShape) {
 		self.QueueWriteString("\x1b]22;<\x1b\\")
 	}
 	self.pointer_shapes = nil
+	self.record_pointer_shapes()
 	return ans
 }
 
+func (self *Loop) record_pointer_shapes() {
+	if len(self.pointer_shapes) > 0 {
+		self.state_stack.push("pointer shapes", strings.Repeat("\x1b]22;<\x1b\\", len(self.pointer_shapes)), nil)
+	} else {
+		self.state_stack.pop("pointer shapes")
+	}
+}
+
 func (self *Loop) CurrentPointerShape() (ans PointerShape, has_shape bool) {
 	if len(self.pointer_shapes) > 0 {
 		has_shape = true
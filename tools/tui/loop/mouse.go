@@ -218,27 +218,31 @@ func pixel_to_cell(px, length, cell_length int) int {
 	return 0
 }
 
-func decode_sgr_mouse(text string, screen_size ScreenSize) *MouseEvent {
+func decode_sgr_mouse(text string, screen_size ScreenSize, ans *MouseEvent) bool {
 	last_letter := text[len(text)-1]
 	text = text[:len(text)-1]
-	parts := strings.Split(text, ";")
-	if len(parts) != 3 {
-		return nil
+	p0, rest, found := strings.Cut(text, ";")
+	if !found {
+		return false
 	}
-	cb, err := strconv.Atoi(parts[0])
+	p1, p2, found := strings.Cut(rest, ";")
+	if !found || strings.Contains(p2, ";") {
+		return false
+	}
+	cb, err := strconv.Atoi(p0)
 	if err != nil {
-		return nil
+		return false
 	}
-	ans := MouseEvent{}
-	ans.Pixel.X, err = strconv.Atoi(parts[1])
+	*ans = MouseEvent{}
+	ans.Pixel.X, err = strconv.Atoi(p1)
 	if err != nil {
-		return nil
+		return false
 	}
-	if len(parts[2]) < 1 {
-		return nil
+	if len(p2) < 1 {
+		return false
 	}
-	if ans.Pixel.Y, err = strconv.Atoi(parts[2]); err != nil {
-		return nil
+	if ans.Pixel.Y, err = strconv.Atoi(p2); err != nil {
+		return false
 	}
 	if last_letter == 'm' {
 		ans.Event_type = MOUSE_RELEASE
@@ -264,20 +268,27 @@ func decode_sgr_mouse(text string, screen_size ScreenSize) *MouseEvent {
 	}
 	ans.Cell.X = pixel_to_cell(ans.Pixel.X, int(screen_size.WidthPx), int(screen_size.CellWidth))
 	ans.Cell.Y = pixel_to_cell(ans.Pixel.Y, int(screen_size.HeightPx), int(screen_size.CellHeight))
-
-	return &ans
+	return true
 }
 
-func MouseEventFromCSI(csi string, screen_size ScreenSize) *MouseEvent {
+func mouse_event_from_csi(csi string, screen_size ScreenSize, ans *MouseEvent) bool {
 	if len(csi) == 0 {
-		return nil
+		return false
 	}
 	last_char := csi[len(csi)-1]
 	if last_char != 'm' && last_char != 'M' {
-		return nil
+		return false
 	}
 	if !strings.HasPrefix(csi, "<") {
-		return nil
+		return false
+	}
+	return decode_sgr_mouse(csi[1:], screen_size, ans)
+}
+
+func MouseEventFromCSI(csi string, screen_size ScreenSize) *MouseEvent {
+	var ans MouseEvent
+	if mouse_event_from_csi(csi, screen_size, &ans) {
+		return &ans
 	}
-	return decode_sgr_mouse(csi[1:], screen_size)
+	return nil
 }
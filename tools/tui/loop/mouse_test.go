@@ -0,0 +1,82 @@
+// License: GPLv3 Copyright: 2023, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func legacy_decode_sgr_mouse(text string, screen_size ScreenSize) *MouseEvent {
+	last_letter := text[len(text)-1]
+	text = text[:len(text)-1]
+	parts := strings.Split(text, ";")
+	if len(parts) != 3 {
+		return nil
+	}
+	cb, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil
+	}
+	ans := MouseEvent{}
+	ans.Pixel.X, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return nil
+	}
+	if len(parts[2]) < 1 {
+		return nil
+	}
+	if ans.Pixel.Y, err = strconv.Atoi(parts[2]); err != nil {
+		return nil
+	}
+	if last_letter == 'm' {
+		ans.Event_type = MOUSE_RELEASE
+	} else if cb&MOTION_INDICATOR != 0 {
+		ans.Event_type = MOUSE_MOVE
+	}
+	cb3 := cb & 3
+	if cb >= 128 {
+		ans.Buttons |= ebmap[cb3]
+	} else if cb >= 64 {
+		ans.Buttons |= wbmap[cb3]
+	} else if cb3 < 3 {
+		ans.Buttons |= bmap[cb3]
+	}
+	if cb&SHIFT_INDICATOR != 0 {
+		ans.Mods |= SHIFT
+	}
+	if cb&ALT_INDICATOR != 0 {
+		ans.Mods |= ALT
+	}
+	if cb&CTRL_INDICATOR != 0 {
+		ans.Mods |= CTRL
+	}
+	ans.Cell.X = pixel_to_cell(ans.Pixel.X, int(screen_size.WidthPx), int(screen_size.CellWidth))
+	ans.Cell.Y = pixel_to_cell(ans.Pixel.Y, int(screen_size.HeightPx), int(screen_size.CellHeight))
+
+	return &ans
+}
+
+func FuzzMouseEventFromCSI(f *testing.F) {
+	for _, seed := range []string{
+		"97;5u", "1;2A", "<0;10;20M", "<35;1;2m", "<64;3;4M", "<1;2M", "<a;2;3M", "<1;2;3;4M", "<1;2;M",
+	} {
+		f.Add(seed)
+	}
+	sz := ScreenSize{WidthCells: 80, HeightCells: 24, WidthPx: 800, HeightPx: 480, CellWidth: 10, CellHeight: 20}
+	f.Fuzz(func(t *testing.T, csi string) {
+		var expected *MouseEvent
+		if len(csi) > 1 && (csi[len(csi)-1] == 'm' || csi[len(csi)-1] == 'M') && csi[0] == '<' {
+			expected = legacy_decode_sgr_mouse(csi[1:], sz)
+		}
+		if diff := cmp.Diff(expected, MouseEventFromCSI(csi, sz)); diff != "" {
+			t.Fatalf("Decoding mouse event from %#v changed:\n%s", csi, diff)
+		}
+	})
+}
@@ -41,6 +41,7 @@ func read_ignoring_temporary_errors(f *tty.Term, buf []byte) (int, error) {
 }
 
 func read_from_tty(pipe_r *os.File, term *tty.Term, results_channel chan<- []byte, err_channel chan<- error, quit_channel <-chan byte) {
+	defer RestoreTerminalOnPanic()
 	keep_going := true
 	pipe_fd := int(pipe_r.Fd())
 	tty_fd := term.Fd()
@@ -0,0 +1,52 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestInputDispatch(t *testing.T) {
+	lp := new_loop()
+	var texts []string
+	var keys []string
+	lp.OnText = func(text string, from_key_event, in_bracketed_paste bool) error {
+		// pasted text can be delivered in multiple chunks
+		if in_bracketed_paste && len(texts) > 0 && strings.HasSuffix(texts[len(texts)-1], ":true") {
+			texts[len(texts)-1] = strings.TrimSuffix(texts[len(texts)-1], ":true") + text + ":true"
+		} else {
+			texts = append(texts, fmt.Sprintf("%s:%v", text, in_bracketed_paste))
+		}
+		return nil
+	}
+	lp.OnKeyEvent = func(ev *KeyEvent) error {
+		keys = append(keys, ev.String()+ev.CSI)
+		return nil
+	}
+	if err := lp.dispatch_input_data([]byte("ab\x1b[97;5u\x1b[200~pasted\x1b[97u text\x1b[201~c\x1b[98;5u")); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a:false", "b:false", "pasted\x1b[97u text:true", ":false", "c:false"}
+	if fmt.Sprint(expected) != fmt.Sprint(texts) {
+		t.Fatalf("Incorrect text dispatched: %#v", texts)
+	}
+	if len(keys) != 2 || !strings.HasSuffix(keys[0], "97;5u") || !strings.HasSuffix(keys[1], "98;5u") {
+		t.Fatalf("Incorrect key events dispatched: %#v", keys)
+	}
+}
+
+func BenchmarkPaste(b *testing.B) {
+	lp := new_loop()
+	lp.OnText = func(text string, from_key_event, in_bracketed_paste bool) error { return nil }
+	lp.OnKeyEvent = func(ev *KeyEvent) error { return nil }
+	data := []byte("\x1b[200~" + strings.Repeat("Some pasted text with ünïcödé in it\n", 4096) + "\x1b[201~\x1b[97;5u")
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = lp.dispatch_input_data(data)
+	}
+}
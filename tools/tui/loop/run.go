@@ -14,6 +14,7 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"kitty/tools/keys"
 	"kitty/tools/tty"
 	"kitty/tools/utils"
 )
@@ -32,6 +33,7 @@ func new_loop() *Loop {
 	l.escape_code_parser.HandleSOS = l.handle_sos
 	l.escape_code_parser.HandlePM = l.handle_pm
 	l.escape_code_parser.HandleRune = l.handle_rune
+	l.escape_code_parser.HandleText = l.handle_text
 	l.escape_code_parser.HandleEndOfBracketedPaste = l.handle_end_of_bracketed_paste
 	l.style_cache = make(map[string]func(...any) string)
 	l.style_ctx.AllowEscapeCodes = true
@@ -62,6 +64,13 @@ func (self *Loop) set_pointer_shapes(ps []PointerShape) {
 		s.WriteString("\x1b\\")
 		self.QueueWriteString(s.String())
 	}
+	self.record_pointer_shapes()
+}
+
+// Queue the escape codes to setup the terminal state recording the change
+func (self *Loop) queue_set_terminal_state() IdType {
+	self.state_stack.push("terminal state", self.terminal_options.ResetStateEscapeCodes(), nil)
+	return self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
 }
 
 func (self *Loop) update_screen_size() error {
@@ -82,16 +91,17 @@ func (self *Loop) update_screen_size() error {
 }
 
 func (self *Loop) handle_csi(raw []byte) error {
-	csi := string(raw)
-	ke := KeyEventFromCSI(csi)
-	if ke != nil {
-		return self.handle_key_event(ke)
+	// raw is a view into the parser buffer, events are decoded into re-used
+	// structs so that escape codes cause no allocations
+	csi := utils.UnsafeBytesToString(raw)
+	if keys.DecodeCSI(csi, &self.key_event) {
+		self.key_event.CSI = string(raw)
+		return self.handle_key_event(&self.key_event)
 	}
 	sz, err := self.ScreenSize()
 	if err == nil {
-		me := MouseEventFromCSI(csi, sz)
-		if me != nil {
-			return self.handle_mouse_event(me)
+		if mouse_event_from_csi(csi, sz, &self.mouse_event) {
+			return self.handle_mouse_event(&self.mouse_event)
 		}
 	}
 	if self.OnEscapeCode != nil {
@@ -206,6 +216,23 @@ func (self *Loop) handle_rune(raw rune) error {
 	return nil
 }
 
+func (self *Loop) handle_text(text []byte) error {
+	if self.OnText == nil {
+		return nil
+	}
+	if self.escape_code_parser.InBracketedPaste() {
+		return self.OnText(string(text), false, true)
+	}
+	// Outside of pastes, programs expect text typed by the user one
+	// character at a time, as it would be with key events
+	for _, ch := range utils.UnsafeBytesToString(text) {
+		if err := self.OnText(string(ch), false, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (self *Loop) handle_end_of_bracketed_paste() error {
 	if self.OnText != nil {
 		return self.OnText("", false, false)
@@ -280,19 +307,33 @@ func (self *Loop) on_SIGHUP() error {
 func (self *Loop) run() (err error) {
 	signal_channel := make(chan os.Signal, 256)
 	handled_signals := []os.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGTSTP, unix.SIGHUP, unix.SIGWINCH, unix.SIGPIPE}
+	// deferred first so that exit signal handling is restored after the reset
+	defer utils.SuspendExitSignalHandling()()
 	signal.Notify(signal_channel, handled_signals...)
 	defer signal.Reset(handled_signals...)
 
+	self.state_stack.clear()
 	controlling_term, err := tty.OpenControllingTerm(tty.SetRaw)
 	if err != nil {
 		return err
 	}
 	self.controlling_term = controlling_term
+	self.state_stack.push("raw mode", "", controlling_term.Restore)
+	// restore the terminal if the process exits while the loop is running
+	defer utils.AtExit(self.state_stack.restore)()
 	defer func() {
+		self.state_stack.pop("raw mode")
 		controlling_term.RestoreAndClose()
 		self.controlling_term = nil
 	}()
 
+	var probe_leftovers []byte
+	if needs_width_probe(&self.terminal_options) {
+		if probe_leftovers, err = probe_widths(controlling_term); err != nil {
+			return err
+		}
+	}
+
 	self.keep_going = true
 	self.pending_mouse_events = utils.NewRingBuffer[MouseEvent](4)
 	// tty_write_channel is buffered so there is no race between initial
@@ -335,7 +376,7 @@ func (self *Loop) run() (err error) {
 		return err
 	}
 
-	self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
+	self.queue_set_terminal_state()
 	needs_reset_escape_codes := true
 
 	shutdown_tty_reader := func() {
@@ -364,7 +405,7 @@ func (self *Loop) run() (err error) {
 		}
 		if needs_reset_escape_codes {
 			self.ClearPointerShapes()
-			self.QueueWriteString(self.terminal_options.ResetStateEscapeCodes())
+			self.QueueWriteString(self.state_stack.take_escape_codes())
 		}
 		// flush queued data and wait for it to be written for a timeout, then wait for writer to shutdown
 		flush_writer(w_w, self.tty_write_channel, write_done_channel, self.pending_writes, 2*time.Second)
@@ -381,10 +422,15 @@ func (self *Loop) run() (err error) {
 			return err
 		}
 	}
+	if len(probe_leftovers) > 0 {
+		if err = self.dispatch_input_data(probe_leftovers); err != nil {
+			return err
+		}
+	}
 
 	self.SuspendAndRun = func(run func() error) (err error) {
 		ps := self.ClearPointerShapes()
-		write_id := self.QueueWriteString(self.terminal_options.ResetStateEscapeCodes())
+		write_id := self.QueueWriteString(self.state_stack.take_escape_codes())
 		needs_reset_escape_codes = false
 		if err = self.wait_for_write_to_complete(write_id, self.tty_write_channel, write_done_channel, 2*time.Second); err != nil {
 			return err
@@ -404,7 +450,7 @@ func (self *Loop) run() (err error) {
 		if err = resume(); err != nil {
 			return err
 		}
-		write_id = self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
+		write_id = self.queue_set_terminal_state()
 		self.set_pointer_shapes(ps)
 		needs_reset_escape_codes = true
 		return self.wait_for_write_to_complete(write_id, self.tty_write_channel, write_done_channel, 2*time.Second)
@@ -412,7 +458,7 @@ func (self *Loop) run() (err error) {
 
 	self.on_SIGTSTP = func() error {
 		ps := self.ClearPointerShapes()
-		write_id := self.QueueWriteString(self.terminal_options.ResetStateEscapeCodes())
+		write_id := self.QueueWriteString(self.state_stack.take_escape_codes())
 		needs_reset_escape_codes = false
 		err := self.wait_for_write_to_complete(write_id, self.tty_write_channel, write_done_channel, 2*time.Second)
 		if err != nil {
@@ -426,7 +472,7 @@ func (self *Loop) run() (err error) {
 		if err != nil {
 			return err
 		}
-		write_id = self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
+		write_id = self.queue_set_terminal_state()
 		self.set_pointer_shapes(ps)
 		needs_reset_escape_codes = true
 		err = self.wait_for_write_to_complete(write_id, self.tty_write_channel, write_done_channel, 2*time.Second)
@@ -0,0 +1,120 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"kitty/tools/tty"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// A change made to the terminal by the loop and how to undo it
+type state_change struct {
+	name string
+	// escape codes to write to the terminal to undo the change
+	reset string
+	// called after writing reset, to undo changes such as termios settings
+	restore func() error
+}
+
+// A record of all changes made to the terminal by the loop that have not
+// yet been undone, used to restore the terminal if the loop is not shutdown
+// cleanly, for example, because of a panic or the process exiting.
+type terminal_state_stack struct {
+	mutex   sync.Mutex
+	changes []state_change
+}
+
+// Record a change, replacing any previously recorded change with the same name
+func (self *terminal_state_stack) push(name, reset string, restore func() error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.remove(name)
+	self.changes = append(self.changes, state_change{name: name, reset: reset, restore: restore})
+}
+
+// Forget the change with the specified name, after it has been undone
+func (self *terminal_state_stack) pop(name string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.remove(name)
+}
+
+func (self *terminal_state_stack) remove(name string) {
+	for i := len(self.changes) - 1; i >= 0; i-- {
+		if self.changes[i].name == name {
+			self.changes = append(self.changes[:i], self.changes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Forget all recorded changes
+func (self *terminal_state_stack) clear() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.changes = nil
+}
+
+// The escape codes to undo all recorded changes, most recent first
+func (self *terminal_state_stack) reset_escape_codes() string {
+	var sb strings.Builder
+	for i := len(self.changes) - 1; i >= 0; i-- {
+		sb.WriteString(self.changes[i].reset)
+	}
+	return sb.String()
+}
+
+// Return the escape codes to undo all recorded changes, forgetting the
+// changes that are undone by them alone. Used when the loop undoes the
+// changes itself.
+func (self *terminal_state_stack) take_escape_codes() string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	ans := self.reset_escape_codes()
+	self.changes = utils.Filter(self.changes, func(c state_change) bool { return c.restore != nil })
+	for i := range self.changes {
+		self.changes[i].reset = ""
+	}
+	return ans
+}
+
+// Undo all recorded changes, most recent first. The escape codes are written
+// directly to the controlling terminal, bypassing the loop, so this works
+// even if the loop is not running or is wedged.
+func (self *terminal_state_stack) restore() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if len(self.changes) == 0 {
+		return
+	}
+	if codes := self.reset_escape_codes(); codes != "" {
+		if term, err := tty.OpenControllingTerm(); err == nil {
+			_ = term.WriteAllString(codes)
+			term.Close()
+		}
+	}
+	for i := len(self.changes) - 1; i >= 0; i-- {
+		if r := self.changes[i].restore; r != nil {
+			_ = r()
+		}
+	}
+	self.changes = nil
+}
+
+// Use as defer loop.RestoreTerminalOnPanic() at the start of goroutines
+// started while a loop is running. A panic in a goroutine other than the one
+// running the loop cannot be recovered by the loop and would leave the
+// terminal in a broken state. The panic is continued after the terminal is
+// restored by running the cleanups registered with utils.AtExit().
+func RestoreTerminalOnPanic() {
+	if r := recover(); r != nil {
+		utils.CleanupAtExit()
+		panic(r)
+	}
+}
@@ -0,0 +1,46 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestTerminalStateStack(t *testing.T) {
+	lp, _ := New()
+	num_restores := 0
+	lp.state_stack.push("raw mode", "", func() error { num_restores++; return nil })
+	lp.queue_set_terminal_state()
+	lp.StartBracketedPaste()
+	lp.SetCursorVisible(false)
+	lp.PushPointerShape(TEXT_POINTER)
+	lp.PushPointerShape(POINTER_POINTER)
+	lp.SetCursorVisible(false)
+	lp.StartAtomicUpdate()
+	lp.EndAtomicUpdate()
+	lp.PopPointerShape()
+
+	expected := "\x1b]22;<\x1b\\" + DECTCEM.EscapeCodeToSet() + BRACKETED_PASTE.EscapeCodeToReset() + lp.terminal_options.ResetStateEscapeCodes()
+	if diff := cmp.Diff(expected, lp.state_stack.reset_escape_codes()); diff != "" {
+		t.Fatalf("Incorrect reset escape codes:\n%s", diff)
+	}
+	lp.EndBracketedPaste()
+	lp.SetCursorVisible(true)
+	lp.ClearPointerShapes()
+	if diff := cmp.Diff(lp.terminal_options.ResetStateEscapeCodes(), lp.state_stack.take_escape_codes()); diff != "" {
+		t.Fatalf("Incorrect reset escape codes:\n%s", diff)
+	}
+	if len(lp.state_stack.changes) != 1 || lp.state_stack.reset_escape_codes() != "" {
+		t.Fatalf("Changes not forgotten after being undone: %#v", lp.state_stack.changes)
+	}
+	lp.state_stack.restore()
+	lp.state_stack.restore()
+	if num_restores != 1 || len(lp.state_stack.changes) != 0 {
+		t.Fatalf("Incorrect number of restores: %d", num_restores)
+	}
+}
@@ -0,0 +1,185 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty/tools/tty"
+	"kitty/tools/utils"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+// The environment variable used to pass the width table to child processes,
+// set it to builtin to disable probing
+const WidthTableEnvVar = "KITTY_WIDTH_TABLE"
+
+const width_probe_timeout = 500 * time.Millisecond
+
+// The escape codes to measure the widths of the probe strings using cursor
+// position reports, terminated by a primary device attributes request, that
+// every terminal responds to, so the end of the responses can be detected
+// even if the terminal does not respond to the position requests.
+func width_probe_escape_codes(probes []string) string {
+	buf := bytes.Buffer{}
+	buf.WriteString("\x1b[?1049h")
+	for _, p := range probes {
+		buf.WriteString("\x1b[H" + p + "\x1b[6n")
+	}
+	buf.WriteString("\x1b[H\x1b[2J\x1b[?1049l\x1b[c")
+	return buf.String()
+}
+
+// Parse the responses to width_probe_escape_codes() returning the widths
+// from the cursor position reports, whether the device attributes response
+// was seen and any other data, such as key presses, received in between.
+func parse_width_probe_responses(data []byte) (widths []int, finished bool, other []byte) {
+	for len(data) > 0 {
+		idx := bytes.Index(data, []byte("\x1b["))
+		if idx < 0 {
+			other = append(other, data...)
+			break
+		}
+		other = append(other, data[:idx]...)
+		data = data[idx:]
+		end := bytes.IndexFunc(data[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+		if end < 0 {
+			// incomplete escape code
+			other = append(other, data...)
+			break
+		}
+		end += 2
+		code, params := data[end], data[2:end]
+		switch {
+		case code == 'c' && len(params) > 0 && params[0] == '?':
+			finished = true
+		case code == 'R':
+			// the row is always 1 as the probe is at the top of the screen,
+			// which distinguishes most F3 key presses, that use the same encoding
+			if row, col, found := bytes.Cut(params, []byte(";")); found && string(row) == "1" {
+				if c, err := strconv.Atoi(string(col)); err == nil {
+					widths = append(widths, c-1)
+					break
+				}
+			}
+			other = append(other, data[:end+1]...)
+		default:
+			other = append(other, data[:end+1]...)
+		}
+		data = data[end+1:]
+	}
+	return
+}
+
+// Probed width tables, keyed by terminal and version, so that the probe,
+// which is visible and delays startup, is only done once per terminal
+type width_table_cache struct {
+	Tables map[string]string `json:"tables"`
+}
+
+func width_tables_cache() *utils.CachedValues[*width_table_cache] {
+	return utils.NewCachedValues("width-tables", &width_table_cache{Tables: map[string]string{}})
+}
+
+// Terminals change the widths they use when they update their Unicode
+// tables, so the version is part of the key, where the terminal provides it
+func width_table_cache_key(getenv func(string) string) string {
+	parts := []string{}
+	for _, x := range []string{"TERM", "TERM_PROGRAM", "TERM_PROGRAM_VERSION", "VTE_VERSION", "KONSOLE_VERSION"} {
+		parts = append(parts, getenv(x))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Terminal multiplexers have their own widths, which can differ from those of
+// the terminal they are running in, and do not pass the probe through, as
+// they redraw the screen themselves
+func is_multiplexed(getenv func(string) string) bool {
+	term := getenv("TERM")
+	return getenv("TMUX") != "" || getenv("STY") != "" || getenv("ZELLIJ") != "" || strings.HasPrefix(term, "screen") || strings.HasPrefix(term, "tmux")
+}
+
+func use_width_table(val string) bool {
+	if val == "builtin" {
+		return true
+	}
+	if t, err := wcswidth.ParseWidthTable(val); err == nil {
+		wcswidth.SetWidthTable(t)
+		return true
+	}
+	return false
+}
+
+func needs_width_probe(opts *TerminalStateOptions) bool {
+	if !opts.Alternate_screen || os.Getenv("TERM") == "xterm-kitty" {
+		// kitty uses the same width database so the probe is pointless
+		return false
+	}
+	if val := os.Getenv(WidthTableEnvVar); val != "" && use_width_table(val) {
+		return false
+	}
+	if is_multiplexed(os.Getenv) || !tty.IsTerminal(os.Stdout.Fd()) {
+		return false
+	}
+	if val := width_tables_cache().Load().Tables[width_table_cache_key(os.Getenv)]; val != "" && use_width_table(val) {
+		os.Setenv(WidthTableEnvVar, val)
+		return false
+	}
+	return true
+}
+
+// Measure the widths the terminal uses for the width probe strings and use
+// them for all width calculations in this process, passing them via the
+// environment to child processes and caching them. Returns any data received from the terminal
+// that was not a response to the probe, which must be processed normally.
+func probe_widths(term *tty.Term) (other []byte, err error) {
+	probes := wcswidth.WidthProbes()
+	if err = term.WriteAllString(width_probe_escape_codes(probes)); err != nil {
+		return
+	}
+	deadline := time.Now().Add(width_probe_timeout)
+	received := make([]byte, 0, 256)
+	buf := make([]byte, 256)
+	var widths []int
+	finished := false
+	for !finished {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			break
+		}
+		n, rerr := term.ReadWithTimeout(buf, timeout)
+		if rerr != nil {
+			if errors.Is(rerr, os.ErrDeadlineExceeded) || is_temporary_error(rerr) {
+				continue
+			}
+			return received, rerr
+		}
+		received = append(received, buf[:n]...)
+		widths, finished, other = parse_width_probe_responses(received)
+	}
+	if !finished {
+		return received, nil
+	}
+	val := "builtin"
+	if t := wcswidth.WidthTableFromProbes(widths); t != nil && !t.IsBuiltin() {
+		wcswidth.SetWidthTable(t)
+		val = t.Serialize()
+	}
+	os.Setenv(WidthTableEnvVar, val)
+	cache := width_tables_cache()
+	cached := cache.Load()
+	if cached.Tables == nil {
+		cached.Tables = map[string]string{}
+	}
+	cached.Tables[width_table_cache_key(os.Getenv)] = val
+	cache.Save()
+	return other, nil
+}
@@ -0,0 +1,52 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestWidthProbeResponses(t *testing.T) {
+	type result struct {
+		Widths   []int
+		Finished bool
+		Other    string
+	}
+	p := func(data string, expected result) {
+		widths, finished, other := parse_width_probe_responses([]byte(data))
+		if diff := cmp.Diff(expected, result{widths, finished, string(other)}); diff != "" {
+			t.Fatalf("Unexpected result parsing: %#v\n%s", data, diff)
+		}
+	}
+	p("\x1b[1;2R\x1b[1;3R\x1b[?62;c", result{[]int{1, 2}, true, ""})
+	p("a\x1b[1;2Rb\x1b[2;5R\x1b[A\x1b[1;3", result{[]int{1}, false, "ab\x1b[2;5R\x1b[A\x1b[1;3"})
+	p("\x1b[1;2R\x1b[?1;2", result{[]int{1}, false, "\x1b[?1;2"})
+}
+
+func TestWidthProbeConditions(t *testing.T) {
+	env := func(vals ...string) func(string) string {
+		m := map[string]string{}
+		for i := 0; i+1 < len(vals); i += 2 {
+			m[vals[i]] = vals[i+1]
+		}
+		return func(k string) string { return m[k] }
+	}
+	a := width_table_cache_key(env("TERM", "xterm-256color", "TERM_PROGRAM", "WezTerm", "TERM_PROGRAM_VERSION", "1"))
+	b := width_table_cache_key(env("TERM", "xterm-256color", "TERM_PROGRAM", "WezTerm", "TERM_PROGRAM_VERSION", "2"))
+	if a == b || a != width_table_cache_key(env("TERM", "xterm-256color", "TERM_PROGRAM", "WezTerm", "TERM_PROGRAM_VERSION", "1")) {
+		t.Fatalf("Incorrect cache keys: %#v %#v", a, b)
+	}
+	for _, m := range []func(string) string{env("TMUX", "/tmp/x,1,0"), env("STY", "1.x"), env("ZELLIJ", "0"), env("TERM", "tmux-256color"), env("TERM", "screen")} {
+		if !is_multiplexed(m) {
+			t.Fatalf("Multiplexer not detected for: %#v", m("TERM"))
+		}
+	}
+	if is_multiplexed(env("TERM", "xterm-256color")) {
+		t.Fatalf("Multiplexer detected incorrectly")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"sync"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -18,38 +20,54 @@ type write_msg struct {
 	id    IdType
 	bytes []byte
 	str   string
+	// the buffer to return to write_buffer_pool once the write is complete
+	pooled *[]byte
 }
 
 func (self *write_msg) String() string {
 	return fmt.Sprintf("write_msg{%v %#v %#v}", self.id, string(self.bytes), self.str)
 }
 
-func write_ignoring_temporary_errors(f *tty.Term, buf []byte) (int, error) {
-	n, err := f.Write(buf)
-	if err != nil {
-		if is_temporary_error(err) {
-			err = nil
-		}
-		return n, err
+// Larger buffers are not pooled to avoid holding on to large amounts of memory
+const max_pooled_write_buffer_size = 64 * 1024
+
+var write_buffer_pool = sync.Pool{New: func() any {
+	ans := make([]byte, 0, 4096)
+	return &ans
+}}
+
+// Copy data into a buffer from the pool, the buffer is returned to the pool
+// after it is written to the tty
+func new_pooled_write_msg(data []byte) write_msg {
+	if len(data) > max_pooled_write_buffer_size {
+		return write_msg{bytes: slices.Clone(data)}
 	}
-	if n == 0 {
-		return 0, io.EOF
+	b := write_buffer_pool.Get().(*[]byte)
+	*b = append((*b)[:0], data...)
+	return write_msg{bytes: *b, pooled: b}
+}
+
+func (self *write_msg) release() {
+	if self.pooled != nil {
+		write_buffer_pool.Put(self.pooled)
+		self.pooled = nil
 	}
-	return n, err
+	self.bytes = nil
 }
 
-func writestring_ignoring_temporary_errors(f *tty.Term, buf string) (int, error) {
-	n, err := f.WriteString(buf)
-	if err != nil {
-		if is_temporary_error(err) {
-			err = nil
-		}
-		return n, err
+func (self *write_msg) as_bytes() []byte {
+	if self.bytes == nil {
+		return utils.UnsafeStringToBytes(self.str)
 	}
-	if n == 0 {
-		return 0, io.EOF
+	return self.bytes
+}
+
+func (self *write_msg) consume(n int) {
+	if self.bytes == nil {
+		self.str = self.str[n:]
+	} else {
+		self.bytes = self.bytes[n:]
 	}
-	return n, err
 }
 
 func (self *Loop) flush_pending_writes(tty_write_channel chan<- write_msg) (num_sent int) {
@@ -147,27 +165,11 @@ func (self write_msg) is_empty() bool {
 	return len(self.bytes) == 0
 }
 
-func (self *write_msg) write(f *tty.Term) (err error) {
-	n := 0
-	if self.bytes == nil {
-		n, err = writestring_ignoring_temporary_errors(f, self.str)
-	} else {
-		n, err = write_ignoring_temporary_errors(f, self.bytes)
-	}
-	if n > 0 {
-		if self.bytes == nil {
-			self.str = self.str[n:]
-		} else {
-			self.bytes = self.bytes[n:]
-		}
-	}
-	return
-}
-
 func write_to_tty(
 	pipe_r *os.File, term *tty.Term,
 	job_channel <-chan write_msg, err_channel chan<- error, write_done_channel chan<- IdType,
 ) {
+	defer RestoreTerminalOnPanic()
 	keep_going := true
 	defer func() {
 		pipe_r.Close()
@@ -196,30 +198,72 @@ func write_to_tty(
 		}
 	}
 
-	write_data := func(msg write_msg) {
-		for !msg.is_empty() {
+	// Since the loop typically queues many small writes, such as when
+	// rendering or sending chunked escape codes, all writes that are
+	// available are collected and written with a single system call
+	const max_batch_size = 64
+	batch := make([]write_msg, 0, max_batch_size)
+	iovecs := make([][]byte, 0, max_batch_size)
+	write_batch := func() {
+		for keep_going {
+			for len(batch) > 0 && batch[0].is_empty() {
+				batch[0].release()
+				write_done_channel <- batch[0].id
+				batch = batch[1:]
+			}
+			if len(batch) == 0 {
+				return
+			}
+			iovecs = iovecs[:0]
+			for i := range batch {
+				if !batch[i].is_empty() {
+					iovecs = append(iovecs, batch[i].as_bytes())
+				}
+			}
 			wait_for_write_available()
 			if !keep_going {
 				return
 			}
-			if err := msg.write(term); err != nil {
+			n, err := writev(tty_fd, iovecs)
+			if err != nil && !is_temporary_error(err) {
 				err_channel <- err
 				keep_going = false
 				return
 			}
+			if n == 0 && err == nil {
+				err_channel <- io.EOF
+				keep_going = false
+				return
+			}
+			for i := 0; n > 0 && i < len(batch); i++ {
+				if amt := min(n, len(batch[i].as_bytes())); amt > 0 {
+					batch[i].consume(amt)
+					n -= amt
+				}
+			}
 		}
 	}
 
-	for {
+	for keep_going {
 		data, more := <-job_channel
 		if !more {
-			keep_going = false
 			break
 		}
-		write_data(data)
-		if keep_going {
-			write_done_channel <- data.id
-		} else {
+		batch = append(batch[:0], data)
+	collect:
+		for len(batch) < max_batch_size {
+			select {
+			case data, more = <-job_channel:
+				if !more {
+					break collect
+				}
+				batch = append(batch, data)
+			default:
+				break collect
+			}
+		}
+		write_batch()
+		if !more {
 			break
 		}
 	}
@@ -0,0 +1,83 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"kitty/tools/tty"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func TestWriteToTTY(t *testing.T) {
+	out_r, out_w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	quit_r, quit_w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer quit_w.Close()
+	fd, err := unix.Dup(int(out_w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out_w.Close()
+	term, err := tty.WrapTerm(fd, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, errs, done := make(chan write_msg, 512), make(chan error, 1), make(chan IdType)
+	expected := strings.Builder{}
+	for i := 1; i < 300; i++ {
+		var msg write_msg
+		switch i % 3 {
+		case 0:
+			msg = write_msg{str: fmt.Sprint(i, " ")}
+		case 1:
+			msg = new_pooled_write_msg([]byte(strings.Repeat("x", i*i)))
+		case 2:
+			msg = write_msg{}
+		}
+		msg.id = IdType(i)
+		expected.Write(msg.as_bytes())
+		jobs <- msg
+	}
+	close(jobs)
+	go write_to_tty(quit_r, term, jobs, errs, done)
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(out_r)
+		output <- string(data)
+	}()
+	var done_ids []IdType
+	for id := range done {
+		done_ids = append(done_ids, id)
+	}
+	term.Close()
+	select {
+	case err = <-errs:
+		t.Fatal(err)
+	default:
+	}
+	for i, id := range done_ids {
+		if id != IdType(i+1) {
+			t.Fatalf("Writes not completed in order: %v", done_ids)
+		}
+	}
+	if len(done_ids) != 299 {
+		t.Fatalf("Not all writes completed: %d", len(done_ids))
+	}
+	if diff := cmp.Diff(expected.String(), <-output); diff != "" {
+		t.Fatalf("Incorrect data written:\n%s", diff)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func writev(fd int, iovecs [][]byte) (int, error) {
+	return unix.Writev(fd, iovecs)
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// golang.org/x/sys does not provide writev on all platforms, so write the
+// buffers one at a time, stopping at the first short write. Like writev, an
+// error is only reported if nothing was written, callers retry the rest.
+func writev(fd int, iovecs [][]byte) (written int, err error) {
+	for _, b := range iovecs {
+		n, werr := unix.Write(fd, b)
+		if werr != nil {
+			if written == 0 {
+				return 0, werr
+			}
+			break
+		}
+		written += n
+		if n < len(b) {
+			break
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,119 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type Multiplexer int
+
+const (
+	NoMultiplexer Multiplexer = iota
+	TmuxMultiplexer
+	ScreenMultiplexer
+)
+
+func (self Multiplexer) String() string {
+	switch self {
+	case TmuxMultiplexer:
+		return "tmux"
+	case ScreenMultiplexer:
+		return "GNU screen"
+	}
+	return "none"
+}
+
+const (
+	// tmux buffers escape codes in its input buffer, which is 1MB by default,
+	// leave plenty of room
+	tmux_passthrough_limit = 256 * 1024
+	// screen has a fixed size buffer for DCS strings
+	screen_passthrough_limit = 768
+)
+
+func detect_multiplexer() Multiplexer {
+	if TmuxSocketAddress() != "" {
+		return TmuxMultiplexer
+	}
+	if os.Getenv("STY") != "" && strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		return ScreenMultiplexer
+	}
+	return NoMultiplexer
+}
+
+// The terminal multiplexer, if any, that the kittens are running inside
+var DetectMultiplexer = sync.OnceValue(detect_multiplexer)
+
+// Ensure the multiplexer will pass escape codes wrapped with
+// WrapForPassthrough through to the terminal, returning a descriptive error
+// if it will not
+func EnsurePassthroughAllowed(m Multiplexer) error {
+	if m == TmuxMultiplexer {
+		if err := TmuxAllowPassthrough(); err != nil {
+			return utils.WithHint(fmt.Errorf("tmux is not configured to pass escape codes through to the terminal and enabling it failed with error: %w", err),
+				"Add the line: set -g allow-passthrough on to your tmux.conf and reload it with: tmux source-file ~/.tmux.conf")
+		}
+	}
+	return nil
+}
+
+// An error for kittens that need responses from the terminal which
+// multiplexers do not forward
+func ResponsesNotSupportedError(what string, m Multiplexer) error {
+	return fmt.Errorf("%s does not work inside %s as it does not forward responses from the terminal. Run it directly in a kitty window instead", what, m)
+}
+
+func split_for_passthrough(payload string, limit int, escape func(string) string) (ans []string) {
+	for len(payload) > 0 {
+		n := min(limit, len(payload))
+		for n > 1 && len(escape(payload[:n])) > limit {
+			n /= 2
+		}
+		// dont separate an ESC from the byte following it
+		if n > 1 && n < len(payload) && payload[n-1] == 0x1b {
+			n--
+		}
+		ans = append(ans, payload[:n])
+		payload = payload[n:]
+	}
+	return
+}
+
+// Wrap the escape code(s) in payload so that the multiplexer will pass them
+// through to the terminal unchanged, splitting large payloads into chunks
+// that fit within the limits of the multiplexer. Since the wrapped chunks are
+// concatenated by the terminal, payload need not contain whole escape codes.
+// screen cannot pass through escape codes terminated by ST, so under screen
+// OSC codes must be terminated by BEL instead.
+func WrapForPassthrough(m Multiplexer, payload string) (string, error) {
+	var b strings.Builder
+	switch m {
+	case TmuxMultiplexer:
+		escape := func(x string) string { return strings.ReplaceAll(x, "\x1b", "\x1b\x1b") }
+		for _, chunk := range split_for_passthrough(payload, tmux_passthrough_limit, escape) {
+			b.WriteString("\x1bPtmux;")
+			b.WriteString(escape(chunk))
+			b.WriteString("\x1b\\")
+		}
+	case ScreenMultiplexer:
+		if strings.Contains(payload, "\x1b\\") {
+			return "", fmt.Errorf("GNU screen cannot pass through escape codes terminated by ST to the terminal")
+		}
+		for _, chunk := range split_for_passthrough(payload, screen_passthrough_limit, func(x string) string { return x }) {
+			b.WriteString("\x1bP")
+			b.WriteString(chunk)
+			b.WriteString("\x1b\\")
+		}
+	default:
+		return payload, nil
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,54 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestWrapForPassthrough(t *testing.T) {
+	q := func(m Multiplexer, payload, expected string) {
+		actual, err := WrapForPassthrough(m, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("Incorrect wrapping for %s of %#v:\n%#v != %#v", m, payload, expected, actual)
+		}
+	}
+	q(NoMultiplexer, "\x1b]52;c;YQ==\x1b\\", "\x1b]52;c;YQ==\x1b\\")
+	q(TmuxMultiplexer, "\x1b]52;c;YQ==\x1b\\", "\x1bPtmux;\x1b\x1b]52;c;YQ==\x1b\x1b\\\x1b\\")
+	q(ScreenMultiplexer, "\x1b]52;c;YQ==\a", "\x1bP\x1b]52;c;YQ==\a\x1b\\")
+	if _, err := WrapForPassthrough(ScreenMultiplexer, "\x1b_Ga=q\x1b\\"); err == nil {
+		t.Fatalf("No error for ST terminated code under screen")
+	}
+
+	payload := "\x1b]52;c;" + strings.Repeat("a", 2000) + "\a"
+	ans, _ := WrapForPassthrough(ScreenMultiplexer, payload)
+	chunks := strings.Split(strings.TrimSuffix(ans, "\x1b\\"), "\x1b\\")
+	if len(chunks) != 3 {
+		t.Fatalf("Incorrect number of chunks: %d", len(chunks))
+	}
+	unwrapped := ""
+	for _, c := range chunks {
+		c = strings.TrimPrefix(c, "\x1bP")
+		if len(c) > screen_passthrough_limit {
+			t.Fatalf("Chunk too large: %d", len(c))
+		}
+		unwrapped += c
+	}
+	if unwrapped != payload {
+		t.Fatalf("Chunks do not reassemble to the payload")
+	}
+
+	payload = strings.Repeat("\x1b", tmux_passthrough_limit)
+	for _, c := range split_for_passthrough(payload, tmux_passthrough_limit, func(x string) string { return strings.ReplaceAll(x, "\x1b", "\x1b\x1b") }) {
+		if 2*len(c) > tmux_passthrough_limit {
+			t.Fatalf("Chunk too large after escaping: %d", 2*len(c))
+		}
+	}
+}
@@ -130,7 +130,7 @@ func (self *Readline) complete(forwards bool, repeat_count uint) bool {
 func (self *Readline) screen_lines_for_match_group_with_descriptions(g *cli.MatchGroup, lines []string) []string {
 	maxw := 0
 	for _, m := range g.Matches {
-		l := wcswidth.Stringwidth(m.Word)
+		l := wcswidth.CachedStringwidth(m.Word)
 		if l > 16 {
 			maxw = 16
 			break
@@ -205,7 +205,7 @@ func (self *Readline) screen_lines_for_match_group_without_descriptions(g *cli.M
 	max_length := 0
 	for i, m := range g.Matches {
 		words[i] = m.Word
-		l := wcswidth.Stringwidth(words[i])
+		l := wcswidth.CachedStringwidth(words[i])
 		lengths[words[i]] = l
 		if l > max_length {
 			max_length = l
@@ -0,0 +1,245 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package subseq
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// The scoring used by the default scheme of the fzf V2 algorithm, see
+// https://github.com/junegunn/fzf/blob/master/src/algo/algo.go
+const (
+	score_match                 = 16
+	score_gap_start             = -3
+	score_gap_extension         = -1
+	bonus_boundary              = score_match / 2
+	bonus_non_word              = score_match / 2
+	bonus_camel123              = bonus_boundary + score_gap_extension
+	bonus_consecutive           = -(score_gap_start + score_gap_extension)
+	bonus_first_char_multiplier = 2
+	bonus_boundary_white        = bonus_boundary + 2
+	bonus_boundary_delimiter    = bonus_boundary + 1
+	delimiter_chars             = "/,:;|"
+	white_chars                 = " \t\n\v\f\r\x85\xa0"
+)
+
+type char_class int
+
+const (
+	char_white char_class = iota
+	char_non_word
+	char_delimiter
+	char_lower
+	char_upper
+	char_letter
+	char_number
+)
+
+func char_class_of(ch rune) char_class {
+	switch {
+	case 'a' <= ch && ch <= 'z':
+		return char_lower
+	case 'A' <= ch && ch <= 'Z':
+		return char_upper
+	case '0' <= ch && ch <= '9':
+		return char_number
+	case strings.ContainsRune(white_chars, ch):
+		return char_white
+	case strings.ContainsRune(delimiter_chars, ch):
+		return char_delimiter
+	case ch < 128:
+		return char_non_word
+	case unicode.IsLower(ch):
+		return char_lower
+	case unicode.IsUpper(ch):
+		return char_upper
+	case unicode.IsNumber(ch):
+		return char_number
+	case unicode.IsLetter(ch):
+		return char_letter
+	case unicode.IsSpace(ch):
+		return char_white
+	}
+	return char_non_word
+}
+
+func bonus_for(prev, class char_class) int {
+	if class > char_non_word {
+		switch prev {
+		case char_white:
+			return bonus_boundary_white
+		case char_delimiter:
+			return bonus_boundary_delimiter
+		case char_non_word:
+			return bonus_boundary
+		}
+	}
+	if prev == char_lower && class == char_upper || prev != char_number && class == char_number {
+		return bonus_camel123
+	}
+	switch class {
+	case char_non_word, char_delimiter:
+		return bonus_non_word
+	case char_white:
+		return bonus_boundary_white
+	}
+	return 0
+}
+
+type fzf_workspace struct {
+	first, bonus, scores, consecutive []int
+}
+
+func resized(s []int, sz int) []int {
+	if cap(s) < sz {
+		return make([]int, sz)
+	}
+	s = s[:sz]
+	clear(s)
+	return s
+}
+
+// Lower case rune by rune so that positions in the lower cased haystack are
+// the same as in the original
+func lower_runes(x string) []rune {
+	ans := []rune(x)
+	for i, ch := range ans {
+		ans[i] = unicode.ToLower(ch)
+	}
+	return ans
+}
+
+// Score the haystack against the lower cased needle, finding the positions
+// with the highest score, as the V2 algorithm of fzf does.
+func fzf_score(item string, haystack, orig_haystack []rune, idx int, needle []rune, w *fzf_workspace) *Match {
+	ans := &Match{idx: idx, Text: item, Positions: make([]int, len(needle))}
+	M := len(needle)
+	if M == 0 || len(haystack) < M {
+		return ans
+	}
+	// The first occurrence of each needle character, in order, and the last
+	// occurrence of the last needle character, which bound the search
+	w.first = w.first[:0]
+	last := -1
+	for j, ch := range haystack {
+		if len(w.first) < M && ch == needle[len(w.first)] {
+			w.first = append(w.first, j)
+		}
+		if len(w.first) == M && ch == needle[M-1] {
+			last = j
+		}
+	}
+	if last < 0 {
+		return ans
+	}
+	first := w.first
+	f0 := first[0]
+	width := last - f0 + 1
+	w.bonus = resized(w.bonus, width)
+	w.scores = resized(w.scores, width*M)
+	w.consecutive = resized(w.consecutive, width*M)
+	bonus, H, C := w.bonus, w.scores, w.consecutive
+	prev_class := char_white
+	if f0 > 0 {
+		prev_class = char_class_of(orig_haystack[f0-1])
+	}
+	for j := range bonus {
+		class := char_class_of(orig_haystack[f0+j])
+		bonus[j] = bonus_for(prev_class, class)
+		prev_class = class
+	}
+	max_score, max_pos := 0, 0
+	// The first row of the score matrix
+	in_gap, prev := false, 0
+	for j := 0; j < width; j++ {
+		if haystack[f0+j] == needle[0] {
+			s := score_match + bonus[j]*bonus_first_char_multiplier
+			H[j], C[j], in_gap = s, 1, false
+			if M == 1 && s > max_score {
+				max_score, max_pos = s, f0+j
+				if bonus[j] >= bonus_boundary {
+					break
+				}
+			}
+		} else {
+			H[j], in_gap = max(prev+utils.IfElse(in_gap, score_gap_extension, score_gap_start), 0), true
+		}
+		prev = H[j]
+	}
+	if M == 1 {
+		ans.Score, ans.Positions[0] = float64(max_score), max_pos
+	} else {
+		// The remaining rows
+		for i := 1; i < M; i++ {
+			row, in_gap := i*width, false
+			for col := first[i]; col <= last; col++ {
+				j := col - f0
+				left := 0
+				if col > first[i] {
+					left = H[row+j-1]
+				}
+				s1, s2, consecutive := 0, left+utils.IfElse(in_gap, score_gap_extension, score_gap_start), 0
+				if haystack[col] == needle[i] {
+					s1 = H[row-width+j-1] + score_match
+					b := bonus[j]
+					consecutive = C[row-width+j-1] + 1
+					if consecutive > 1 {
+						fb := bonus[j-consecutive+1]
+						if b >= bonus_boundary && b > fb {
+							// a boundary breaks the consecutive chunk
+							consecutive = 1
+						} else {
+							b = max(b, bonus_consecutive, fb)
+						}
+					}
+					if s1+b < s2 {
+						s1 += bonus[j]
+						consecutive = 0
+					} else {
+						s1 += b
+					}
+				}
+				C[row+j] = consecutive
+				in_gap = s1 < s2
+				score := max(s1, s2, 0)
+				if i == M-1 && score > max_score {
+					max_score, max_pos = score, col
+				}
+				H[row+j] = score
+			}
+		}
+		ans.Score = float64(max_score)
+		// Trace back from the highest score to find the matched positions
+		i, col, prefer_match := M-1, max_pos, true
+		for {
+			row, j := i*width, col-f0
+			s, s1, s2 := H[row+j], 0, 0
+			if i > 0 && col >= first[i] {
+				s1 = H[row-width+j-1]
+			}
+			if col > first[i] {
+				s2 = H[row+j-1]
+			}
+			if s > s1 && (s > s2 || s == s2 && prefer_match) {
+				ans.Positions[i] = col
+				if i == 0 {
+					break
+				}
+				i--
+			}
+			prefer_match = C[row+j] > 1 || row+width+j+1 < len(C) && C[row+width+j+1] > 0
+			col--
+		}
+	}
+	adjust := utils.RuneOffsetsToByteOffsets(item)
+	for i := range ans.Positions {
+		ans.Positions[i] = adjust(ans.Positions[i])
+	}
+	return ans
+}
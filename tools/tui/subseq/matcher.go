@@ -0,0 +1,107 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package subseq
+
+import (
+	"fmt"
+
+	"kitty/tools/utils/images"
+)
+
+var _ = fmt.Print
+
+type indexed_item struct {
+	haystack, orig_haystack []rune
+}
+
+// Score a fixed set of items against a query that changes one keystroke at
+// a time, as happens in list widgets with a search box. The items are
+// indexed once and when the new query contains the previous query as a
+// subsequence, only the items that matched the previous query are scored, as
+// no other item can match. Matching is case insensitive and items are scored
+// as by the default scheme of fzf, so the Level options are not used.
+type Matcher struct {
+	items      []string
+	index      []indexed_item
+	threads    int
+	last_query []rune
+	// indices of the items that matched last_query
+	last_matched []int
+	has_last     bool
+}
+
+func NewMatcher(items []string, opts Options) *Matcher {
+	return &Matcher{items: items, threads: opts.NumberOfThreads}
+}
+
+func (self *Matcher) ensure_index() {
+	if self.index == nil {
+		self.index = make([]indexed_item, len(self.items))
+		ctx := images.Context{}
+		ctx.SetNumberOfThreads(self.threads)
+		ctx.Parallel(0, len(self.items), func(nums <-chan int) {
+			for i := range nums {
+				self.index[i] = indexed_item{lower_runes(self.items[i]), []rune(self.items[i])}
+			}
+		})
+	}
+}
+
+func is_subsequence(needle, haystack []rune) bool {
+	for _, ch := range haystack {
+		if len(needle) == 0 {
+			break
+		}
+		if needle[0] == ch {
+			needle = needle[1:]
+		}
+	}
+	return len(needle) == 0
+}
+
+// Return the matches for the specified query, one for every item, in the
+// order of the items
+func (self *Matcher) Score(query string) []*Match {
+	self.ensure_index()
+	needle := lower_runes(query)
+	var candidates []int
+	if self.has_last && is_subsequence(self.last_query, needle) {
+		candidates = self.last_matched
+	}
+	ans := make([]*Match, len(self.items))
+	// shared by all items that are not candidates, to avoid allocating
+	no_match := make([]int, len(needle))
+	for i, item := range self.items {
+		ans[i] = &Match{idx: i, Text: item, Positions: no_match}
+	}
+	score := func(nums <-chan int, get_idx func(int) int) {
+		w := fzf_workspace{}
+		for n := range nums {
+			i := get_idx(n)
+			x := &self.index[i]
+			ans[i] = fzf_score(self.items[i], x.haystack, x.orig_haystack, i, needle, &w)
+		}
+	}
+	if len(needle) == 0 {
+		// An empty query matches nothing, so it cannot be used to restrict
+		// candidates
+		self.has_last = false
+		return ans
+	}
+	ctx := images.Context{}
+	ctx.SetNumberOfThreads(self.threads)
+	if candidates == nil {
+		ctx.Parallel(0, len(self.items), func(nums <-chan int) { score(nums, func(n int) int { return n }) })
+	} else {
+		ctx.Parallel(0, len(candidates), func(nums <-chan int) { score(nums, func(n int) int { return candidates[n] }) })
+	}
+	matched := make([]int, 0, len(candidates))
+	for i, m := range ans {
+		if m.Score > 0 {
+			matched = append(matched, i)
+		}
+	}
+	self.has_last = true
+	self.last_query, self.last_matched = needle, matched
+	return ans
+}
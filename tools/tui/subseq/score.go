@@ -156,9 +156,11 @@ func has_atleast_one_match(w *workspace_type) (found bool) {
 }
 
 func score_item(item string, idx int, needle []rune, opts *resolved_options_type, w *workspace_type) *Match {
+	return score_haystack(item, []rune(strings.ToLower(item)), []rune(item), idx, needle, opts, w)
+}
+
+func score_haystack(item string, haystack, orig_haystack []rune, idx int, needle []rune, opts *resolved_options_type, w *workspace_type) *Match {
 	ans := &Match{idx: idx, Text: item, Positions: make([]int, len(needle))}
-	haystack := []rune(strings.ToLower(item))
-	orig_haystack := []rune(item)
 	w.initialize(len(orig_haystack), len(needle))
 	for i := 0; i < len(haystack); i++ {
 		level_factor_calculated := false
@@ -202,12 +204,7 @@ func score_item(item string, idx int, needle []rune, opts *resolved_options_type
 	return ans
 }
 
-func ScoreItems(query string, items []string, opts Options) []*Match {
-	ctx := images.Context{}
-	ctx.SetNumberOfThreads(opts.NumberOfThreads)
-	ans := make([]*Match, len(items))
-	results := make(chan *Match, len(items))
-	nr := []rune(strings.ToLower(query))
+func resolve_options(opts Options) *resolved_options_type {
 	if opts.Level1 == "" {
 		opts.Level1 = LEVEL1
 	}
@@ -217,9 +214,18 @@ func ScoreItems(query string, items []string, opts Options) []*Match {
 	if opts.Level3 == "" {
 		opts.Level3 = LEVEL3
 	}
-	ropts := resolved_options_type{
+	return &resolved_options_type{
 		level1: []rune(opts.Level1), level2: []rune(opts.Level2), level3: []rune(opts.Level3),
 	}
+}
+
+func ScoreItems(query string, items []string, opts Options) []*Match {
+	ctx := images.Context{}
+	ctx.SetNumberOfThreads(opts.NumberOfThreads)
+	ans := make([]*Match, len(items))
+	results := make(chan *Match, len(items))
+	nr := []rune(strings.ToLower(query))
+	ropts := *resolve_options(opts)
 	ctx.Parallel(0, len(items), func(nums <-chan int) {
 		w := workspace_type{}
 		for i := range nums {
@@ -97,3 +97,101 @@ func TestSubseq(t *testing.T) {
 	}
 	simple(strings.Join(items, "\n"), "2", expected...)
 }
+
+func TestMatcher(t *testing.T) {
+	items := []string{"kitty/tools/tui/subseq/score.go", "kitty/tools/cmd/at/main.go", "README.md", "docs/changelog.rst", "", "kittens/ssh/main.go", "Scores"}
+	m := NewMatcher(items, Options{})
+	for _, q := range []string{"", "s", "sc", "sco", "scO", "sxco", "sc", "", "m", "ma", "mai", "main", "mn", "changes", "kitty", "xyz", "xyzw", "d", "do", "s/s"} {
+		actual := m.Score(q)
+		if q == "" {
+			for _, x := range actual {
+				if x.Score != 0 {
+					t.Fatalf("Empty query matched: %#v", x.Text)
+				}
+			}
+			continue
+		}
+		expected := NewMatcher(items, Options{}).Score(q)
+		if diff := cmp.Diff(expected, actual, cmp.AllowUnexported(Match{})); diff != "" {
+			t.Fatalf("Incremental matching failed for query: %#v\n%s", q, diff)
+		}
+	}
+}
+
+func TestFzfScores(t *testing.T) {
+	// The expected values are from the tests for the V2 algorithm in fzf
+	type e struct {
+		start, end, score int
+	}
+	for _, x := range []struct {
+		text, query string
+		expected    e
+	}{
+		{"fooBarbaz1", "oBZ", e{2, 9, score_match*3 + bonus_camel123 + score_gap_start + score_gap_extension*3}},
+		{"foo bar baz", "fbb", e{0, 9, score_match*3 + bonus_boundary_white*bonus_first_char_multiplier + bonus_boundary_white*2 + 2*score_gap_start + 4*score_gap_extension}},
+		{"/AutomatorDocument.icns", "rdoc", e{9, 13, score_match*4 + bonus_camel123 + bonus_consecutive*2}},
+		{"/man1/zshcompctl.1", "zshc", e{6, 10, score_match*4 + bonus_boundary_delimiter*bonus_first_char_multiplier + bonus_boundary_delimiter*3}},
+		{"/.oh-my-zsh/cache", "zshc", e{8, 13, score_match*4 + bonus_boundary*bonus_first_char_multiplier + bonus_boundary*2 + score_gap_start + bonus_boundary_delimiter}},
+		{"ab0123 456", "12356", e{3, 10, score_match*5 + bonus_consecutive*3 + score_gap_start + score_gap_extension}},
+		{"abc123 456", "12356", e{3, 10, score_match*5 + bonus_camel123*bonus_first_char_multiplier + bonus_camel123*2 + bonus_consecutive + score_gap_start + score_gap_extension}},
+		{"foo/bar/baz", "fbb", e{0, 9, score_match*3 + bonus_boundary_white*bonus_first_char_multiplier + bonus_boundary_delimiter*2 + 2*score_gap_start + 4*score_gap_extension}},
+		{"fooBarBaz", "fbb", e{0, 7, score_match*3 + bonus_boundary_white*bonus_first_char_multiplier + bonus_camel123*2 + 2*score_gap_start + 2*score_gap_extension}},
+		{"foo barbaz", "fbb", e{0, 8, score_match*3 + bonus_boundary_white*bonus_first_char_multiplier + bonus_boundary_white + score_gap_start*2 + score_gap_extension*3}},
+		{"fooBar Baz", "foob", e{0, 4, score_match*4 + bonus_boundary_white*bonus_first_char_multiplier + bonus_boundary_white*3}},
+		{"xFoo-Bar Baz", "foo-b", e{1, 6, score_match*5 + bonus_camel123*bonus_first_char_multiplier + bonus_camel123*2 + bonus_non_word + bonus_boundary}},
+		{"foo", "o", e{1, 2, score_match}},
+		{"a fo", "f", e{2, 3, score_match + bonus_boundary_white*bonus_first_char_multiplier}},
+	} {
+		m := NewMatcher([]string{x.text}, Options{}).Score(x.query)[0]
+		actual := e{m.Positions[0], m.Positions[len(m.Positions)-1] + 1, int(m.Score)}
+		if actual != x.expected {
+			t.Fatalf("Incorrect match for %#v in %#v: %#v != %#v", x.query, x.text, x.expected, actual)
+		}
+	}
+	for _, text := range []string{"fo", "ofo", ""} {
+		if m := NewMatcher([]string{text}, Options{}).Score("foo")[0]; m.Score != 0 {
+			t.Fatalf("%#v incorrectly matched", text)
+		}
+	}
+	// positions are byte offsets
+	m := NewMatcher([]string{"•abc"}, Options{}).Score("ac")[0]
+	if diff := cmp.Diff([]int{3, 5}, m.Positions); diff != "" {
+		t.Fatalf("Incorrect positions: %s", diff)
+	}
+}
+
+func benchmark_candidates(n int) []string {
+	words := []string{"kitty", "tools", "tui", "subseq", "score", "main", "kittens", "ssh", "docs", "changelog", "readline", "utils", "shm", "icat", "transfer"}
+	ans := make([]string, n)
+	for i := range ans {
+		parts := make([]string, 0, 4)
+		for j := 0; j < 4; j++ {
+			parts = append(parts, words[(i*(j+3)+j*7+i/(j+1))%len(words)])
+		}
+		ans[i] = strings.Join(parts, "/") + strconv.Itoa(i) + ".go"
+	}
+	return ans
+}
+
+var benchmark_queries = []string{"k", "ki", "kit", "kitt", "kitty", "kittys", "kittysc", "kittysco"}
+
+func BenchmarkScoreItems(b *testing.B) {
+	items := benchmark_candidates(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, q := range benchmark_queries {
+			ScoreItems(q, items, Options{})
+		}
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	items := benchmark_candidates(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := NewMatcher(items, Options{})
+		for _, q := range benchmark_queries {
+			m.Score(q)
+		}
+	}
+}
@@ -97,7 +97,8 @@ func tmux_allow_passthrough() error {
 		}
 		return err
 	case <-time.After(2 * time.Second):
-		return fmt.Errorf("Tmux command timed out. This often happens when the version of tmux on your PATH is older than the version of the running tmux server")
+		return utils.WithHint(fmt.Errorf("Tmux command timed out"),
+			"This often happens when the version of tmux on your PATH is older than the version of the running tmux server")
 	}
 }
 
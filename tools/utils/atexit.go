@@ -0,0 +1,119 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+var _ = fmt.Print
+
+type exit_hook struct {
+	id   uint64
+	hook func()
+}
+
+var exit_hooks struct {
+	mutex   sync.Mutex
+	hooks   []exit_hook
+	counter uint64
+}
+
+// Register hook to be run by CleanupAtExit(). Hooks are run in the reverse
+// order of registration. Call the returned function to unregister the hook.
+func AtExit(hook func()) (unregister func()) {
+	exit_hooks.mutex.Lock()
+	defer exit_hooks.mutex.Unlock()
+	exit_hooks.counter++
+	id := exit_hooks.counter
+	exit_hooks.hooks = append(exit_hooks.hooks, exit_hook{id, hook})
+	return func() {
+		exit_hooks.mutex.Lock()
+		defer exit_hooks.mutex.Unlock()
+		exit_hooks.hooks = slices.DeleteFunc(exit_hooks.hooks, func(h exit_hook) bool { return h.id == id })
+	}
+}
+
+// Run the hooks registered with AtExit() and remove all temp spaces and
+// temporary files created by this process. Called when the process exits via
+// the cli package or is killed by SIGINT, SIGTERM or SIGHUP while it has temp
+// spaces or files.
+func CleanupAtExit() {
+	exit_hooks.mutex.Lock()
+	hooks := exit_hooks.hooks
+	exit_hooks.hooks = nil
+	exit_hooks.mutex.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].hook()
+	}
+	CloseAllTempSpaces()
+	RemoveAllTemps()
+}
+
+var exit_signals struct {
+	mutex     sync.Mutex
+	ch        chan os.Signal
+	signals   []os.Signal
+	suspended int
+}
+
+// Run CleanupAtExit() and exit when an exit signal is received. Signals that
+// are being ignored, such as SIGHUP when running under nohup, are left alone.
+func handle_exit_signals() {
+	exit_signals.mutex.Lock()
+	defer exit_signals.mutex.Unlock()
+	if exit_signals.ch != nil {
+		return
+	}
+	for _, sig := range []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP} {
+		if !signal.Ignored(sig) {
+			exit_signals.signals = append(exit_signals.signals, sig)
+		}
+	}
+	exit_signals.ch = make(chan os.Signal, 1)
+	if exit_signals.suspended == 0 {
+		signal.Notify(exit_signals.ch, exit_signals.signals...)
+	}
+	go func() {
+		for sig := range exit_signals.ch {
+			exit_signals.mutex.Lock()
+			suspended := exit_signals.suspended > 0
+			exit_signals.mutex.Unlock()
+			if suspended {
+				// received before handling was suspended
+				continue
+			}
+			CleanupAtExit()
+			// Restore the default behavior and let it act on the signal
+			signal.Reset(sig)
+			exit_with_signal(sig)
+		}
+	}()
+}
+
+// Suspend the handling of SIGINT, SIGTERM and SIGHUP that runs
+// CleanupAtExit() and exits, for code that handles these signals itself.
+// Such code must call the returned function once it no longer handles them,
+// after any calls to signal.Reset(), which would otherwise remove the exit
+// signal handler. Suspensions can be nested.
+func SuspendExitSignalHandling() (resume func()) {
+	exit_signals.mutex.Lock()
+	defer exit_signals.mutex.Unlock()
+	exit_signals.suspended++
+	if exit_signals.ch != nil && exit_signals.suspended == 1 {
+		signal.Stop(exit_signals.ch)
+	}
+	return sync.OnceFunc(func() {
+		exit_signals.mutex.Lock()
+		defer exit_signals.mutex.Unlock()
+		exit_signals.suspended--
+		if exit_signals.ch != nil && exit_signals.suspended == 0 {
+			signal.Notify(exit_signals.ch, exit_signals.signals...)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// Send sig to this process, so that its default behavior acts on it
+func exit_with_signal(sig os.Signal) {
+	_ = unix.Kill(os.Getpid(), sig.(unix.Signal))
+}
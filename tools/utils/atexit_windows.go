@@ -0,0 +1,17 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+var _ = fmt.Print
+
+// Signals cannot be sent to a process on Windows, so exit with the status
+// shells use for processes killed by a signal
+func exit_with_signal(sig os.Signal) {
+	os.Exit(128 + int(sig.(syscall.Signal)))
+}
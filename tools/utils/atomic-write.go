@@ -5,6 +5,7 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -23,55 +24,74 @@ func AtomicCreateSymlink(oldname, newname string) (err error) {
 	if et, err := os.Readlink(newname); err == nil && et == oldname {
 		return nil
 	}
-	for {
-		tempname := newname + RandomFilename()
-		err = os.Symlink(oldname, tempname)
-		if err == nil {
-			err = os.Rename(tempname, newname)
-			if err != nil {
-				os.Remove(tempname)
-			}
-			return err
-		}
-		if !errors.Is(err, fs.ErrExist) {
-			return err
-		}
+	tempname, err := CreateWithUniqueName(newname, "", func(name string) error { return os.Symlink(oldname, name) })
+	if err != nil {
+		return err
 	}
+	if err = os.Rename(tempname, newname); err != nil {
+		os.Remove(tempname)
+	}
+	return err
 }
 
+// Write data to path atomically, by writing it to a temporary file in the same
+// directory, syncing it to disk and then renaming it into place. If path is a
+// symlink, the file it points to is replaced.
 func AtomicWriteFile(path string, data []byte, perm os.FileMode) (err error) {
+	return AtomicWriteFileWith(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Same as AtomicWriteFile except that the data is written by the write
+// function. If it returns an error, path is left unchanged.
+func AtomicWriteFileWith(path string, perm os.FileMode, write func(io.Writer) error) (err error) {
 	npath, err := filepath.EvalSymlinks(path)
 	if errors.Is(err, fs.ErrNotExist) {
 		err = nil
 		npath = path
 	}
-	if err == nil {
-		path = npath
-		path, err = filepath.Abs(path)
-		if err == nil {
-			var f *os.File
-			f, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".atomic-write-")
-			if err == nil {
-				removed := false
-				defer func() {
-					f.Close()
-					if !removed {
-						os.Remove(f.Name())
-						removed = true
-					}
-				}()
-				_, err = f.Write(data)
-				if err == nil {
-					err = f.Chmod(perm)
-					if err == nil {
-						err = os.Rename(f.Name(), path)
-						if err == nil {
-							removed = true
-						}
-					}
-				}
-			}
+	if err != nil {
+		return err
+	}
+	if path, err = filepath.Abs(npath); err != nil {
+		return err
+	}
+	f, err := CreateTemp(filepath.Dir(path), filepath.Base(path)+".atomic-write-", "")
+	if err != nil {
+		return err
+	}
+	renamed := false
+	defer func() {
+		f.Close()
+		if renamed {
+			KeepTemp(f.Name())
+		} else {
+			RemoveTemp(f.Name())
 		}
+	}()
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	renamed = true
+	// Ensure the rename itself is durable. Not all platforms/filesystems
+	// support syncing directories so errors are ignored.
+	if d, derr := os.Open(filepath.Dir(path)); derr == nil {
+		_ = d.Sync()
+		d.Close()
 	}
 	return
 }
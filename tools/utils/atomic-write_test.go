@@ -0,0 +1,59 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestAtomicWriteFile(t *testing.T) {
+	tdir := t.TempDir()
+	path := filepath.Join(tdir, "file")
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if err := AtomicWriteFile(path, []byte("one"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if q := read(path); q != "one" {
+		t.Fatalf("Incorrect file contents: %#v", q)
+	}
+	if s, err := os.Stat(path); err != nil || s.Mode().Perm() != 0o600 {
+		t.Fatalf("Incorrect file permissions: %v %v", s.Mode(), err)
+	}
+	if err := AtomicWriteFileWith(path, 0o600, func(w io.Writer) error {
+		_, _ = w.Write([]byte("partial"))
+		return fmt.Errorf("failed")
+	}); err == nil {
+		t.Fatalf("No error returned from failing write function")
+	}
+	if q := read(path); q != "one" {
+		t.Fatalf("File changed by failed write: %#v", q)
+	}
+	link := filepath.Join(tdir, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := AtomicUpdateFile(link, []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	if q := read(path); q != "two" {
+		t.Fatalf("Symlink target not updated: %#v", q)
+	}
+	if tgt, err := os.Readlink(link); err != nil || tgt != "file" {
+		t.Fatalf("Symlink was replaced: %#v %v", tgt, err)
+	}
+	if entries, err := os.ReadDir(tdir); err != nil || len(entries) != 2 {
+		t.Fatalf("Temporary files left behind: %v %v", entries, err)
+	}
+}
@@ -0,0 +1,116 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+// package base64
+// This package provides a fast encoder for unpadded standard base64, as used
+// by the escape codes of the graphics, clipboard and file transfer
+// protocols, using vector instructions where available. Output is identical to
+// base64.RawStdEncoding.
+package base64
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+var _ = fmt.Print
+
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// Every 12 bits of input map to two output bytes, stored so that writing the
+// uint16 in little endian order produces them in the correct order
+var pair_table = sync.OnceValue(func() *[4096]uint16 {
+	var ans [4096]uint16
+	for i := range ans {
+		ans[i] = uint16(alphabet[i>>6]) | uint16(alphabet[i&63])<<8
+	}
+	return &ans
+})
+
+func EncodedLen(n int) int { return base64.RawStdEncoding.EncodedLen(n) }
+
+// Encode src into dst which must be at least EncodedLen(len(src)) bytes.
+// Returns the number of bytes written.
+func Encode(dst, src []byte) int {
+	t := pair_table()
+	di, si := 0, 0
+	enc := func(v uint64) uint64 {
+		return uint64(t[v>>52]) | uint64(t[(v>>40)&0xfff])<<16 | uint64(t[(v>>28)&0xfff])<<32 | uint64(t[(v>>16)&0xfff])<<48
+	}
+	if encode_vector != nil && len(src) >= 16 {
+		si = encode_vector(dst, src)
+		di = si / 3 * 4
+	}
+	// Load eight bytes at a time, of which the top six are encoded, into
+	// eight output bytes with a single store
+	for ; si+26 <= len(src); si, di = si+24, di+32 {
+		s, d := src[si:si+26], dst[di:di+32]
+		binary.LittleEndian.PutUint64(d, enc(binary.BigEndian.Uint64(s)))
+		binary.LittleEndian.PutUint64(d[8:], enc(binary.BigEndian.Uint64(s[6:])))
+		binary.LittleEndian.PutUint64(d[16:], enc(binary.BigEndian.Uint64(s[12:])))
+		binary.LittleEndian.PutUint64(d[24:], enc(binary.BigEndian.Uint64(s[18:])))
+	}
+	for ; si+8 <= len(src); si, di = si+6, di+8 {
+		binary.LittleEndian.PutUint64(dst[di:], enc(binary.BigEndian.Uint64(src[si:])))
+	}
+	for ; si+3 <= len(src); si, di = si+3, di+4 {
+		v := uint(src[si])<<16 | uint(src[si+1])<<8 | uint(src[si+2])
+		binary.LittleEndian.PutUint16(dst[di:], t[v>>12])
+		binary.LittleEndian.PutUint16(dst[di+2:], t[v&0xfff])
+	}
+	if si < len(src) {
+		base64.RawStdEncoding.Encode(dst[di:], src[si:])
+		di += EncodedLen(len(src) - si)
+	}
+	return di
+}
+
+func EncodeToString(src []byte) string {
+	dst := make([]byte, EncodedLen(len(src)))
+	Encode(dst, src)
+	return string(dst)
+}
+
+func DecodeString(s string) ([]byte, error) { return base64.RawStdEncoding.DecodeString(s) }
+
+// Append the encoded form of src to dst, growing it as needed
+func AppendEncode(dst, src []byte) []byte {
+	n := EncodedLen(len(src))
+	if cap(dst)-len(dst) < n {
+		d := make([]byte, len(dst), len(dst)+n)
+		copy(d, dst)
+		dst = d
+	}
+	n = Encode(dst[len(dst):len(dst)+n], src)
+	return dst[:len(dst)+n]
+}
+
+var buffer_pool = sync.Pool{New: func() any { b := make([]byte, 0, 4096); return &b }}
+
+// Call callback with successive chunks of the encoded form of src, each at
+// most chunk_size bytes (rounded down to a multiple of four so that every
+// chunk is independently decodable). The chunks are stored in a buffer
+// re-used across calls, so callback must not retain them.
+func EncodeChunked(src []byte, chunk_size int, callback func(chunk []byte, is_last bool) error) (err error) {
+	chunk_size = max(4, chunk_size&^3)
+	input_size := chunk_size / 4 * 3
+	bp := buffer_pool.Get().(*[]byte)
+	defer buffer_pool.Put(bp)
+	buf := *bp
+	if needed := EncodedLen(min(len(src), input_size)); cap(buf) < needed {
+		buf = make([]byte, needed)
+		*bp = buf
+	}
+	for {
+		chunk := src
+		if len(chunk) > input_size {
+			chunk = chunk[:input_size]
+		}
+		src = src[len(chunk):]
+		n := Encode(buf[:cap(buf)], chunk)
+		if err = callback(buf[:n], len(src) == 0); err != nil || len(src) == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package base64
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func random_data(sz int) []byte {
+	ans := make([]byte, sz)
+	_, _ = rand.New(rand.NewSource(int64(sz))).Read(ans)
+	return ans
+}
+
+func TestBase64Encode(t *testing.T) {
+	test_encode(t)
+	vector := encode_vector
+	defer func() { encode_vector = vector }()
+	encode_vector = nil
+	test_encode(t)
+}
+
+func test_encode(t *testing.T) {
+	t.Helper()
+	for sz := 0; sz < 100; sz++ {
+		data := random_data(sz)
+		expected := base64.RawStdEncoding.EncodeToString(data)
+		if diff := cmp.Diff(expected, EncodeToString(data)); diff != "" {
+			t.Fatalf("Encoding of %d bytes failed:\n%s", sz, diff)
+		}
+		if diff := cmp.Diff("prefix"+expected, string(AppendEncode([]byte("prefix"), data))); diff != "" {
+			t.Fatalf("Appending encoding of %d bytes failed:\n%s", sz, diff)
+		}
+	}
+	for _, sz := range []int{0, 1, 2, 3, 11, 12, 13, 4096, 100000} {
+		data := random_data(sz)
+		expected := base64.RawStdEncoding.EncodeToString(data)
+		for _, chunk_size := range []int{1, 4, 7, 16, 1024} {
+			var chunks []string
+			err := EncodeChunked(data, chunk_size, func(chunk []byte, is_last bool) error {
+				if len(chunk) > max(4, chunk_size) {
+					t.Fatalf("Chunk of size %d larger than chunk_size: %d", len(chunk), chunk_size)
+				}
+				chunks = append(chunks, string(chunk))
+				if is_last && len(chunks)*(chunk_size&^3) < len(expected) && chunk_size >= 4 {
+					t.Fatalf("Got is_last too early for %d bytes with chunk size: %d", sz, chunk_size)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(expected, strings.Join(chunks, "")); diff != "" {
+				t.Fatalf("Chunked encoding of %d bytes with chunk size %d failed:\n%s", sz, chunk_size, diff)
+			}
+			for _, c := range chunks {
+				if _, err := base64.RawStdEncoding.DecodeString(c); err != nil {
+					t.Fatalf("Chunk of %d bytes with chunk size %d not independently decodable: %s", sz, chunk_size, err)
+				}
+			}
+		}
+	}
+}
+
+var sizes = []int{57, 4096, 128 * 1024, 4 * 1024 * 1024}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, sz := range sizes {
+		data := random_data(sz)
+		dst := make([]byte, EncodedLen(sz))
+		b.Run(fmt.Sprintf("fast_sz=%d", sz), func(b *testing.B) {
+			b.SetBytes(int64(sz))
+			for i := 0; i < b.N; i++ {
+				Encode(dst, data)
+			}
+		})
+		b.Run(fmt.Sprintf("stdlib_sz=%d", sz), func(b *testing.B) {
+			b.SetBytes(int64(sz))
+			for i := 0; i < b.N; i++ {
+				base64.RawStdEncoding.Encode(dst, data)
+			}
+		})
+	}
+}
+
+// Compares chunked encoding into a pooled buffer with encoding the whole
+// payload into a string and slicing it into chunks, as the escape code
+// serializers used to do
+func BenchmarkEncodeChunked(b *testing.B) {
+	const chunk_size = 4096
+	for _, sz := range sizes {
+		data := random_data(sz)
+		b.Run(fmt.Sprintf("chunked_sz=%d", sz), func(b *testing.B) {
+			b.SetBytes(int64(sz))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = EncodeChunked(data, chunk_size, func([]byte, bool) error { return nil })
+			}
+		})
+		b.Run(fmt.Sprintf("stdlib_sz=%d", sz), func(b *testing.B) {
+			b.SetBytes(int64(sz))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				encoded := base64.RawStdEncoding.EncodeToString(data)
+				for len(encoded) > 0 {
+					encoded = encoded[min(chunk_size, len(encoded)):]
+				}
+			}
+		})
+	}
+}
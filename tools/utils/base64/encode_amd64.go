@@ -0,0 +1,35 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build amd64
+
+package base64
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/cpu"
+)
+
+var _ = fmt.Print
+
+// Encode src into dst using vector instructions, in blocks of twelve input
+// bytes. At least four more bytes than are encoded are read from src.
+// Returns the number of input bytes encoded.
+//
+//go:noescape
+func encode_ssse3(dst, src []byte) int
+
+// Like encode_ssse3 but in blocks of 24 input bytes
+//
+//go:noescape
+func encode_avx2(dst, src []byte) int
+
+var encode_vector = func() func(dst, src []byte) int {
+	switch {
+	case cpu.X86.HasAVX2:
+		return encode_avx2
+	case cpu.X86.HasSSSE3:
+		return encode_ssse3
+	}
+	return nil
+}()
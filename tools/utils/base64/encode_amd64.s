@@ -0,0 +1,165 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+// vim: ft=goasm
+//go:build amd64
+
+#include "textflag.h"
+
+// The vector encoder of Wojciech Muła and Daniel Lemire, see
+// http://0x80.pl/notesen/2016-01-12-sse-base64-encoding.html
+// Every group of three input bytes is shuffled into a 32-bit lane, the four
+// sextets are moved into their own bytes with two multiplies, and the offset
+// from each sextet to its character in the alphabet is found with a shuffle.
+// The constants are 32 bytes so that both encoders can use them.
+
+// Input bytes [a b c] become [b a c b] in every lane
+DATA shuffle<>+0x00(SB)/8, $0x0405030401020001
+DATA shuffle<>+0x08(SB)/8, $0x0a0b090a07080607
+DATA shuffle<>+0x10(SB)/8, $0x0405030401020001
+DATA shuffle<>+0x18(SB)/8, $0x0a0b090a07080607
+GLOBL shuffle<>(SB), RODATA|NOPTR, $32
+
+DATA mask_high<>+0x00(SB)/8, $0x0fc0fc000fc0fc00
+DATA mask_high<>+0x08(SB)/8, $0x0fc0fc000fc0fc00
+DATA mask_high<>+0x10(SB)/8, $0x0fc0fc000fc0fc00
+DATA mask_high<>+0x18(SB)/8, $0x0fc0fc000fc0fc00
+GLOBL mask_high<>(SB), RODATA|NOPTR, $32
+
+DATA mul_high<>+0x00(SB)/8, $0x0400004004000040
+DATA mul_high<>+0x08(SB)/8, $0x0400004004000040
+DATA mul_high<>+0x10(SB)/8, $0x0400004004000040
+DATA mul_high<>+0x18(SB)/8, $0x0400004004000040
+GLOBL mul_high<>(SB), RODATA|NOPTR, $32
+
+DATA mask_low<>+0x00(SB)/8, $0x003f03f0003f03f0
+DATA mask_low<>+0x08(SB)/8, $0x003f03f0003f03f0
+DATA mask_low<>+0x10(SB)/8, $0x003f03f0003f03f0
+DATA mask_low<>+0x18(SB)/8, $0x003f03f0003f03f0
+GLOBL mask_low<>(SB), RODATA|NOPTR, $32
+
+DATA mul_low<>+0x00(SB)/8, $0x0100001001000010
+DATA mul_low<>+0x08(SB)/8, $0x0100001001000010
+DATA mul_low<>+0x10(SB)/8, $0x0100001001000010
+DATA mul_low<>+0x18(SB)/8, $0x0100001001000010
+GLOBL mul_low<>(SB), RODATA|NOPTR, $32
+
+DATA fifty_one<>+0x00(SB)/8, $0x3333333333333333
+DATA fifty_one<>+0x08(SB)/8, $0x3333333333333333
+DATA fifty_one<>+0x10(SB)/8, $0x3333333333333333
+DATA fifty_one<>+0x18(SB)/8, $0x3333333333333333
+GLOBL fifty_one<>(SB), RODATA|NOPTR, $32
+
+DATA twenty_six<>+0x00(SB)/8, $0x1a1a1a1a1a1a1a1a
+DATA twenty_six<>+0x08(SB)/8, $0x1a1a1a1a1a1a1a1a
+DATA twenty_six<>+0x10(SB)/8, $0x1a1a1a1a1a1a1a1a
+DATA twenty_six<>+0x18(SB)/8, $0x1a1a1a1a1a1a1a1a
+GLOBL twenty_six<>(SB), RODATA|NOPTR, $32
+
+DATA thirteen<>+0x00(SB)/8, $0x0d0d0d0d0d0d0d0d
+DATA thirteen<>+0x08(SB)/8, $0x0d0d0d0d0d0d0d0d
+DATA thirteen<>+0x10(SB)/8, $0x0d0d0d0d0d0d0d0d
+DATA thirteen<>+0x18(SB)/8, $0x0d0d0d0d0d0d0d0d
+GLOBL thirteen<>(SB), RODATA|NOPTR, $32
+
+// The offsets to add to the sextets: 'a'-26, ten times '0'-52, '+'-62, '/'-63, 'A'
+DATA offsets<>+0x00(SB)/8, $0xfcfcfcfcfcfcfc47
+DATA offsets<>+0x08(SB)/8, $0x000041f0edfcfcfc
+DATA offsets<>+0x10(SB)/8, $0xfcfcfcfcfcfcfc47
+DATA offsets<>+0x18(SB)/8, $0x000041f0edfcfcfc
+GLOBL offsets<>(SB), RODATA|NOPTR, $32
+
+// func encode_ssse3(dst, src []byte) int
+TEXT ·encode_ssse3(SB), NOSPLIT, $0-56
+	MOVQ dst_base+0(FP), DI
+	MOVQ src_base+24(FP), SI
+	MOVQ src_len+32(FP), CX
+	XORQ AX, AX // the number of input bytes consumed
+	MOVOU shuffle<>(SB), X8
+	MOVOU mask_high<>(SB), X9
+	MOVOU mul_high<>(SB), X10
+	MOVOU mask_low<>(SB), X11
+	MOVOU mul_low<>(SB), X12
+	MOVOU fifty_one<>(SB), X13
+	MOVOU thirteen<>(SB), X14
+	MOVOU offsets<>(SB), X7
+	MOVOU twenty_six<>(SB), X6
+
+	// 16 bytes are loaded, of which the first 12 are encoded
+ssse3_loop:
+	LEAQ 16(AX), BX
+	CMPQ BX, CX
+	JA ssse3_done
+	MOVOU (SI)(AX*1), X0
+	PSHUFB X8, X0
+	MOVO X0, X1
+	PAND X9, X0
+	PMULHUW X10, X0
+	PAND X11, X1
+	PMULLW X12, X1
+	POR X1, X0 // the sextets
+
+	MOVO X0, X1
+	PSUBUSB X13, X1
+	MOVO X6, X2
+	PCMPGTB X0, X2
+	PAND X14, X2
+	POR X2, X1 // the indices into offsets
+	MOVO X7, X2
+	PSHUFB X1, X2
+	PADDB X2, X0
+
+	MOVOU X0, (DI)
+	ADDQ $16, DI
+	ADDQ $12, AX
+	JMP ssse3_loop
+
+ssse3_done:
+	MOVQ AX, ret+48(FP)
+	RET
+
+// func encode_avx2(dst, src []byte) int
+TEXT ·encode_avx2(SB), NOSPLIT, $0-56
+	MOVQ dst_base+0(FP), DI
+	MOVQ src_base+24(FP), SI
+	MOVQ src_len+32(FP), CX
+	XORQ AX, AX // the number of input bytes consumed
+	VMOVDQU shuffle<>(SB), Y8
+	VMOVDQU mask_high<>(SB), Y9
+	VMOVDQU mul_high<>(SB), Y10
+	VMOVDQU mask_low<>(SB), Y11
+	VMOVDQU mul_low<>(SB), Y12
+	VMOVDQU fifty_one<>(SB), Y13
+	VMOVDQU thirteen<>(SB), Y14
+	VMOVDQU offsets<>(SB), Y7
+	VMOVDQU twenty_six<>(SB), Y6
+
+	// Each 128-bit lane is loaded with 16 bytes, successive lanes 12 bytes
+	// apart, as shuffles do not cross lanes
+avx2_loop:
+	LEAQ 28(AX), BX
+	CMPQ BX, CX
+	JA avx2_done
+	VMOVDQU (SI)(AX*1), X0
+	VINSERTI128 $1, 12(SI)(AX*1), Y0, Y0
+	VPSHUFB Y8, Y0, Y0
+	VPAND Y9, Y0, Y1
+	VPMULHUW Y10, Y1, Y1
+	VPAND Y11, Y0, Y2
+	VPMULLW Y12, Y2, Y2
+	VPOR Y2, Y1, Y0 // the sextets
+
+	VPSUBUSB Y13, Y0, Y1
+	VPCMPGTB Y0, Y6, Y2
+	VPAND Y14, Y2, Y2
+	VPOR Y2, Y1, Y1 // the indices into offsets
+	VPSHUFB Y1, Y7, Y2
+	VPADDB Y2, Y0, Y0
+
+	VMOVDQU Y0, (DI)
+	ADDQ $32, DI
+	ADDQ $24, AX
+	JMP avx2_loop
+
+avx2_done:
+	VZEROUPPER
+	MOVQ AX, ret+48(FP)
+	RET
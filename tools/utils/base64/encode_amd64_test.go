@@ -0,0 +1,25 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package base64
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/sys/cpu"
+)
+
+var _ = fmt.Print
+
+func TestBase64VectorEncoders(t *testing.T) {
+	vector := encode_vector
+	defer func() { encode_vector = vector }()
+	if cpu.X86.HasSSSE3 {
+		encode_vector = encode_ssse3
+		test_encode(t)
+	}
+	if cpu.X86.HasAVX2 {
+		encode_vector = encode_avx2
+		test_encode(t)
+	}
+}
@@ -0,0 +1,8 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !amd64
+
+package base64
+
+// Only the scalar encoder is used on other architectures
+var encode_vector func(dst, src []byte) int
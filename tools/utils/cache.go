@@ -6,67 +6,163 @@ import (
 	"container/list"
 	"fmt"
 	"sync"
+	"time"
 )
 
 var _ = fmt.Print
 
-type LRUCache[K comparable, V any] struct {
-	data     map[K]V
-	lock     sync.RWMutex
+type lru_entry[K comparable, V any] struct {
+	key     K
+	val     V
+	expires time.Time
+}
+
+// A cache that holds at most a fixed number of entries, discarding the least
+// recently used entry to make room for new ones. Entries can optionally
+// expire after a time. Safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	// The time after which entries added with Set() or GetOrCreate() expire,
+	// zero means never. Must be set before the cache is used.
+	TTL time.Duration
+	// Called with entries that are removed from the cache, because they were
+	// evicted, expired, replaced or deleted, after the entries are removed.
+	// Can be used to release resources held by the values. Must be set before
+	// the cache is used.
+	OnEvict func(key K, val V)
+
+	mutex    sync.Mutex
 	max_size int
-	lru      *list.List
+	entries  map[K]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+// Create a cache holding at most max_size entries, a max_size of zero or
+// less means unlimited
+func NewLRU[K comparable, V any](max_size int) *LRU[K, V] {
+	return &LRU[K, V]{max_size: max_size, entries: make(map[K]*list.Element), order: list.New(), now: time.Now}
+}
+
+func (self *LRU[K, V]) remove(e *list.Element, removed []lru_entry[K, V]) []lru_entry[K, V] {
+	entry := self.order.Remove(e).(*lru_entry[K, V])
+	delete(self.entries, entry.key)
+	if self.OnEvict != nil {
+		removed = append(removed, *entry)
+	}
+	return removed
+}
+
+func (self *LRU[K, V]) notify(removed []lru_entry[K, V]) {
+	for _, e := range removed {
+		self.OnEvict(e.key, e.val)
+	}
+}
+
+func (self *LRU[K, V]) get(key K) (e *lru_entry[K, V], removed []lru_entry[K, V]) {
+	if x, found := self.entries[key]; found {
+		entry := x.Value.(*lru_entry[K, V])
+		if !entry.expires.IsZero() && !self.now().Before(entry.expires) {
+			return nil, self.remove(x, nil)
+		}
+		self.order.MoveToFront(x)
+		return entry, nil
+	}
+	return
 }
 
-func NewLRUCache[K comparable, V any](max_size int) *LRUCache[K, V] {
-	ans := LRUCache[K, V]{data: map[K]V{}, max_size: max_size, lru: list.New()}
-	return &ans
+func (self *LRU[K, V]) set(key K, val V, ttl time.Duration) (removed []lru_entry[K, V]) {
+	if x, found := self.entries[key]; found {
+		removed = self.remove(x, removed)
+	}
+	entry := &lru_entry[K, V]{key: key, val: val}
+	if ttl > 0 {
+		entry.expires = self.now().Add(ttl)
+	}
+	self.entries[key] = self.order.PushFront(entry)
+	for self.max_size > 0 && self.order.Len() > self.max_size {
+		removed = self.remove(self.order.Back(), removed)
+	}
+	return
 }
 
-func (self *LRUCache[K, V]) Get(key K) (ans V, found bool) {
-	self.lock.RLock()
-	ans, found = self.data[key]
-	self.lock.RUnlock()
+// Return the value for key, if it is present and not expired, marking it as
+// the most recently used
+func (self *LRU[K, V]) Get(key K) (ans V, found bool) {
+	self.mutex.Lock()
+	e, removed := self.get(key)
+	if e != nil {
+		ans, found = e.val, true
+	}
+	self.mutex.Unlock()
+	self.notify(removed)
 	return
 }
 
-func (self *LRUCache[K, V]) Set(key K, val V) {
-	self.lock.RLock()
-	self.data[key] = val
-	self.lock.RUnlock()
+// Add or replace the value for key, with the default TTL
+func (self *LRU[K, V]) Set(key K, val V) {
+	self.SetWithTTL(key, val, self.TTL)
+}
+
+// Add or replace the value for key, expiring after ttl, zero means never
+func (self *LRU[K, V]) SetWithTTL(key K, val V, ttl time.Duration) {
+	self.mutex.Lock()
+	removed := self.set(key, val, ttl)
+	self.mutex.Unlock()
+	self.notify(removed)
+}
+
+// Remove the entry for key, returning true if it was present
+func (self *LRU[K, V]) Delete(key K) (found bool) {
+	var removed []lru_entry[K, V]
+	self.mutex.Lock()
+	var x *list.Element
+	if x, found = self.entries[key]; found {
+		removed = self.remove(x, removed)
+	}
+	self.mutex.Unlock()
+	self.notify(removed)
 	return
 }
 
-func (self *LRUCache[K, V]) GetOrCreate(key K, create func(key K) (V, error)) (V, error) {
-	self.lock.RLock()
-	ans, found := self.data[key]
-	self.lock.RUnlock()
-	if found {
+// Remove all entries
+func (self *LRU[K, V]) Clear() {
+	var removed []lru_entry[K, V]
+	self.mutex.Lock()
+	for self.order.Len() > 0 {
+		removed = self.remove(self.order.Back(), removed)
+	}
+	self.mutex.Unlock()
+	self.notify(removed)
+}
+
+// The number of entries in the cache, including expired entries that have
+// not yet been accessed
+func (self *LRU[K, V]) Len() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.order.Len()
+}
+
+// Return the value for key, calling create to create it if it is not present.
+// Values for which create returns an error are not added. The cache is not
+// locked while create runs so concurrent calls for the same key can
+// call create more than once.
+func (self *LRU[K, V]) GetOrCreate(key K, create func(key K) (V, error)) (V, error) {
+	if ans, found := self.Get(key); found {
 		return ans, nil
 	}
 	ans, err := create(key)
 	if err == nil {
-		self.lock.Lock()
-		self.data[key] = ans
-		self.lru.PushFront(key)
-		if self.max_size > 0 && self.lru.Len() > self.max_size {
-			k := self.lru.Remove(self.lru.Back())
-			delete(self.data, k.(K))
-		}
-		self.lock.Unlock()
+		self.Set(key, ans)
 	}
 	return ans, err
 }
 
-func (self *LRUCache[K, V]) MustGetOrCreate(key K, create func(key K) V) V {
-	self.lock.RLock()
-	ans, found := self.data[key]
-	self.lock.RUnlock()
-	if found {
+func (self *LRU[K, V]) MustGetOrCreate(key K, create func(key K) V) V {
+	if ans, found := self.Get(key); found {
 		return ans
 	}
-	ans = create(key)
-	self.lock.Lock()
-	self.data[key] = ans
-	self.lock.Unlock()
+	ans := create(key)
+	self.Set(key, ans)
 	return ans
 }
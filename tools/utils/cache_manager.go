@@ -0,0 +1,165 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+// Limits on the files in a namespace, that is a sub-directory of the cache
+// directory. Zero values mean no limit.
+type CacheQuota struct {
+	MaxSize int64
+	MaxAge  time.Duration
+}
+
+// Keeps the cache directory from growing without bound by removing the least
+// recently used files from namespaces that exceed their quotas.
+type CacheManager struct {
+	Root string
+	// The minimum time between two background sweeps
+	SweepInterval time.Duration
+
+	mutex  sync.Mutex
+	quotas map[string]CacheQuota
+}
+
+func NewCacheManager(root string) *CacheManager {
+	return &CacheManager{Root: root, SweepInterval: 24 * time.Hour, quotas: make(map[string]CacheQuota)}
+}
+
+// The cache manager for CacheDir() with quotas for the namespaces used by kitty
+var DefaultCacheManager = sync.OnceValue(func() *CacheManager {
+	ans := NewCacheManager(CacheDir())
+	ans.SetQuota("http", CacheQuota{MaxSize: 64 * 1024 * 1024, MaxAge: 90 * 24 * time.Hour})
+	ans.SetQuota("ssh", CacheQuota{MaxSize: 256 * 1024 * 1024, MaxAge: 30 * 24 * time.Hour})
+	ans.SetQuota("icat", CacheQuota{MaxSize: 256 * 1024 * 1024, MaxAge: 30 * 24 * time.Hour})
+	ans.SetQuota("themes", CacheQuota{MaxSize: 32 * 1024 * 1024, MaxAge: 180 * 24 * time.Hour})
+	return ans
+})
+
+func (self *CacheManager) SetQuota(namespace string, q CacheQuota) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.quotas[namespace] = q
+}
+
+// Mark path as used, needed as file systems are often mounted with noatime
+func (self *CacheManager) Touch(path string) error {
+	return os.Chtimes(path, time.Now(), time.Time{})
+}
+
+type cache_entry struct {
+	path      string
+	size      int64
+	last_used time.Time
+}
+
+// The later of the access and modification times of path
+func last_used(path string, s fs.FileInfo) time.Time {
+	ans := s.ModTime()
+	if atime, ok := access_time(path, s); ok && atime.After(ans) {
+		ans = atime
+	}
+	return ans
+}
+
+// Remove files in dir that are older than the quota allows, then remove the
+// least recently used files until the total size is within the quota
+func sweep_cache_namespace(dir string, q CacheQuota, now time.Time) error {
+	usage, err := DiskUsage(dir, DiskUsageOptions{})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	// files with multiple hard links are counted once
+	total := usage.ApparentSize - usage.DirectoriesSize
+	var entries []cache_entry
+	var dirs []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		s, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, cache_entry{path: path, size: s.Size(), last_used: last_used(path, s)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b cache_entry) int { return a.last_used.Compare(b.last_used) })
+	for _, e := range entries {
+		expired := q.MaxAge > 0 && now.Sub(e.last_used) > q.MaxAge
+		if !expired && (q.MaxSize <= 0 || total <= q.MaxSize) {
+			break
+		}
+		// the cache directory can be shared with other programs, so make sure
+		// that replacing a directory with a symlink cannot redirect removal
+		if err := RemoveAllSecure(e.path); err != nil {
+			return err
+		}
+		total -= e.size
+	}
+	// remove directories left empty, deepest first, rmdir never follows
+	// symlinks or removes anything else
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+	return nil
+}
+
+// Enforce the quotas of all namespaces
+func (self *CacheManager) Sweep() error {
+	self.mutex.Lock()
+	quotas := maps.Clone(self.quotas)
+	self.mutex.Unlock()
+	now := time.Now()
+	var errs []error
+	for ns, q := range quotas {
+		if err := sweep_cache_namespace(filepath.Join(self.Root, ns), q, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run Sweep() in the background, unless it was already run, by any process,
+// within SweepInterval. Meant to be called by kittens on startup, errors are
+// ignored.
+func (self *CacheManager) SweepInBackground() {
+	stamp := filepath.Join(self.Root, ".last-sweep")
+	if s, err := os.Stat(stamp); err == nil && time.Since(s.ModTime()) < self.SweepInterval {
+		return
+	}
+	if err := os.WriteFile(stamp, nil, 0o600); err != nil {
+		return
+	}
+	go func() { _ = self.Sweep() }()
+}
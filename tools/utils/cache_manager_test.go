@@ -0,0 +1,68 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestCacheManager(t *testing.T) {
+	tdir := t.TempDir()
+	now := time.Now()
+	write := func(path string, size int, age time.Duration) {
+		path = filepath.Join(tdir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/1", 10, time.Hour)
+	write("a/2", 10, 3*time.Hour)
+	write("a/sub/3", 10, 2*time.Hour)
+	write("a/4", 10, 0)
+	write("b/old", 10, 48*time.Hour)
+	write("b/new", 10, 0)
+	write("c/x", 1000, 1000*time.Hour)
+
+	m := NewCacheManager(tdir)
+	m.SetQuota("a", CacheQuota{MaxSize: 25})
+	m.SetQuota("b", CacheQuota{MaxAge: 24 * time.Hour})
+	if err := m.Touch(filepath.Join(tdir, "a", "2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Sweep(); err != nil {
+		t.Fatal(err)
+	}
+	actual := []string{}
+	_ = filepath.WalkDir(tdir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && path != tdir {
+			r, _ := filepath.Rel(tdir, path)
+			actual = append(actual, filepath.ToSlash(r))
+		}
+		return nil
+	})
+	slices.Sort(actual)
+	if diff := cmp.Diff([]string{"a", "a/2", "a/4", "b", "b/new", "c", "c/x"}, actual); diff != "" {
+		t.Fatalf("Incorrect files after sweeping the cache:\n%s", diff)
+	}
+
+	m.SweepInterval = time.Hour
+	m.SweepInBackground()
+	if _, err := os.Stat(filepath.Join(tdir, ".last-sweep")); err != nil {
+		t.Fatalf("Sweeping in the background did not create the stamp file: %s", err)
+	}
+}
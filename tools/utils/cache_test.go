@@ -0,0 +1,110 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestLRU(t *testing.T) {
+	c := NewLRU[string, int](3)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	var evicted []string
+	c.OnEvict = func(key string, val int) { evicted = append(evicted, fmt.Sprintf("%s:%d", key, val)) }
+	keys := func() (ans []string) {
+		for e := c.order.Front(); e != nil; e = e.Next() {
+			ans = append(ans, e.Value.(*lru_entry[string, int]).key)
+		}
+		return
+	}
+	check := func(expected_keys []string, expected_evicted ...string) {
+		t.Helper()
+		if diff := cmp.Diff(expected_keys, keys()); diff != "" {
+			t.Fatalf("Unexpected keys:\n%s", diff)
+		}
+		if diff := cmp.Diff(expected_evicted, evicted); diff != "" {
+			t.Fatalf("Unexpected evictions:\n%s", diff)
+		}
+		evicted = nil
+	}
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	check([]string{"c", "b", "a"})
+	if v, found := c.Get("a"); !found || v != 1 {
+		t.Fatalf("Failed to get a: %v %v", v, found)
+	}
+	c.Set("d", 4)
+	check([]string{"d", "a", "c"}, "b:2")
+	c.Set("c", 33)
+	check([]string{"c", "d", "a"}, "c:3")
+	if !c.Delete("d") || c.Delete("d") {
+		t.Fatalf("Delete did not report presence correctly")
+	}
+	check([]string{"c", "a"}, "d:4")
+
+	c.SetWithTTL("t", 5, time.Second)
+	now = now.Add(999 * time.Millisecond)
+	if _, found := c.Get("t"); !found {
+		t.Fatalf("Entry expired before its TTL")
+	}
+	now = now.Add(time.Millisecond)
+	if _, found := c.Get("t"); found {
+		t.Fatalf("Entry did not expire after its TTL")
+	}
+	check([]string{"c", "a"}, "t:5")
+
+	calls := 0
+	create := func(key string) (int, error) {
+		calls++
+		if key == "bad" {
+			return 0, fmt.Errorf("bad")
+		}
+		return len(key), nil
+	}
+	for i := 0; i < 2; i++ {
+		if v, err := c.GetOrCreate("xyz", create); err != nil || v != 3 {
+			t.Fatalf("GetOrCreate failed: %v %v", v, err)
+		}
+		if _, err := c.GetOrCreate("bad", create); err == nil {
+			t.Fatalf("GetOrCreate did not return the error from create")
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("create was called %d times instead of 3", calls)
+	}
+	check([]string{"xyz", "c", "a"})
+	c.Clear()
+	if c.Len() != 0 {
+		t.Fatalf("Clear did not remove all entries")
+	}
+	slices.Sort(evicted)
+	check(nil, "a:1", "c:33", "xyz:3")
+
+	u := NewLRU[int, int](0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				u.MustGetOrCreate(j, func(k int) int { return k * 2 })
+				u.Set((i+1)*1000+j, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if u.Len() != 900 {
+		t.Fatalf("Unlimited cache has %d entries instead of 900", u.Len())
+	}
+}
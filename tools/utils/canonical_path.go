@@ -0,0 +1,122 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var _ = fmt.Print
+
+func swap_case(x string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsUpper(r):
+			return unicode.ToLower(r)
+		case unicode.IsLower(r):
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, x)
+}
+
+// A different spelling of name that a case or normalization insensitive file
+// system treats as the same name, or the empty string if there is none
+func alternate_spelling(name string) string {
+	for _, alt := range []string{swap_case(name), norm.NFD.String(name), norm.NFC.String(name)} {
+		if alt != name {
+			return alt
+		}
+	}
+	return ""
+}
+
+// Whether a and b are the same name on a case and normalization insensitive
+// file system
+func names_equivalent(a, b string) bool {
+	return strings.EqualFold(norm.NFC.String(a), norm.NFC.String(b))
+}
+
+// The spelling of name as stored in the directory dir. Only differs from name
+// on case or normalization insensitive file systems such as APFS and FAT.
+func on_disk_name(dir, name string) string {
+	alt := alternate_spelling(name)
+	if alt == "" {
+		return name
+	}
+	s, err := os.Lstat(filepath.Join(dir, name))
+	if err != nil {
+		return name
+	}
+	// if the alternate spelling is a different file or does not exist, the
+	// file system is case and normalization sensitive
+	if as, err := os.Lstat(filepath.Join(dir, alt)); err != nil || !os.SameFile(s, as) {
+		return name
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return name
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return name
+	}
+	for _, x := range names {
+		if x == name {
+			return name
+		}
+	}
+	for _, x := range names {
+		if names_equivalent(x, name) {
+			if xs, err := os.Lstat(filepath.Join(dir, x)); err == nil && os.SameFile(s, xs) {
+				return x
+			}
+		}
+	}
+	return name
+}
+
+// Return the absolute path with all symlinks resolved and, on case or
+// normalization insensitive file systems, every component spelled as it is
+// stored on disk, so that paths to the same file compare equal. Trailing
+// components that do not exist are left as is.
+func CanonicalPath(path string) string {
+	existing, rest := Abspath(path), ""
+	for {
+		if r, err := filepath.EvalSymlinks(existing); err == nil {
+			existing = r
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	ans := filepath.VolumeName(existing) + Sep
+	for _, x := range strings.Split(existing[len(ans):], Sep) {
+		if x != "" {
+			ans = filepath.Join(ans, on_disk_name(ans, x))
+		}
+	}
+	return filepath.Join(ans, rest)
+}
+
+// Whether the paths a and b refer to the same file. Paths that do not exist
+// are compared using CanonicalPath().
+func SamePath(a, b string) bool {
+	if sa, err := os.Stat(a); err == nil {
+		if sb, err := os.Stat(b); err == nil {
+			return os.SameFile(sa, sb)
+		}
+	}
+	return CanonicalPath(a) == CanonicalPath(b)
+}
@@ -0,0 +1,56 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestCanonicalPath(t *testing.T) {
+	tdir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := func(x ...string) string { return filepath.Join(append([]string{tdir}, x...)...) }
+	if err = os.MkdirAll(j("Dir", "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(j("Dir"), j("link")); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct{ path, expected string }{
+		{j("Dir", "sub"), j("Dir", "sub")},
+		{j("link", "sub"), j("Dir", "sub")},
+		{j("link", "..", "link", "sub", ".."), j("Dir")},
+		{j("link", "missing", "file"), j("Dir", "missing", "file")},
+	} {
+		if actual := CanonicalPath(x.path); actual != x.expected {
+			t.Fatalf("Incorrect canonical path for %s: %s != %s", x.path, x.expected, actual)
+		}
+	}
+	if !SamePath(j("link", "sub"), j("Dir", "sub")) || !SamePath(j("link", "x"), j("Dir", "x")) || SamePath(j("Dir"), j("Dir", "sub")) {
+		t.Fatalf("SamePath() failed")
+	}
+	// paths that differ in case are the same only on case insensitive file systems
+	_, err = os.Stat(j("dir"))
+	if SamePath(j("dir", "sub"), j("Dir", "sub")) != (err == nil) {
+		t.Fatalf("SamePath() failed for paths differing in case")
+	}
+	if err == nil && CanonicalPath(j("DIR", "SUB")) != j("Dir", "sub") {
+		t.Fatalf("Incorrect canonical path on case insensitive file system: %s", CanonicalPath(j("DIR", "SUB")))
+	}
+
+	for _, x := range [][2]string{{"Abc", "aBC"}, {"café", "CAFÉ"}} {
+		if !names_equivalent(x[0], x[1]) || alternate_spelling(x[0]) == "" {
+			t.Fatalf("%#v and %#v not considered equivalent", x[0], x[1])
+		}
+	}
+	if names_equivalent("abc", "abd") || alternate_spelling("123") != "" {
+		t.Fatalf("Names incorrectly considered equivalent")
+	}
+}
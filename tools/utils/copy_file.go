@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var _ = fmt.Print
+
+// Copy the regular file src to dest, preserving its permissions and
+// modification time. On file systems that support it, such as btrfs, XFS and
+// APFS, the copy shares its data with src until either is modified, making it
+// near instantaneous. dest is replaced atomically.
+func CopyFile(src, dest string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	s, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !s.Mode().IsRegular() {
+		return fmt.Errorf("Cannot copy %s as it is not a regular file", src)
+	}
+	if done, err := clone_file_by_path(src, dest); done {
+		return err
+	}
+	return copy_file_from(in, s, dest)
+}
+
+// Like CopyFile() but copies from the already open regular file in, useful
+// when src must be opened with special flags such as O_NOFOLLOW
+func CopyFileFrom(in *os.File, dest string) error {
+	s, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !s.Mode().IsRegular() {
+		return fmt.Errorf("Cannot copy %s as it is not a regular file", in.Name())
+	}
+	return copy_file_from(in, s, dest)
+}
+
+func copy_file_from(in *os.File, s os.FileInfo, dest string) (err error) {
+	out, err := CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".", "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			RemoveTemp(out.Name())
+		} else {
+			KeepTemp(out.Name())
+		}
+	}()
+	if !clone_file(out, in) {
+		// io.Copy uses copy_file_range() or sendfile() where available
+		_, err = io.Copy(out, in)
+	}
+	if err == nil {
+		err = out.Chmod(s.Mode().Perm())
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		if err = os.Chtimes(out.Name(), s.ModTime(), s.ModTime()); err == nil {
+			err = os.Rename(out.Name(), dest)
+		}
+	}
+	return
+}
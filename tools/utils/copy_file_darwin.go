@@ -0,0 +1,30 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// Copy src using clonefile(), supported by APFS, which also preserves its
+// metadata. Returns false if cloning is not supported.
+func clone_file_by_path(src, dest string) (bool, error) {
+	tmp, err := CreateWithUniqueName(filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+"."), "", func(name string) error {
+		return unix.Clonefile(src, name, unix.CLONE_NOFOLLOW)
+	})
+	if err != nil {
+		return false, nil
+	}
+	if err = os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+	}
+	return true, err
+}
+
+func clone_file(out, in *os.File) bool { return false }
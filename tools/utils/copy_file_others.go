@@ -0,0 +1,16 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !linux && !darwin
+
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+var _ = fmt.Print
+
+func clone_file_by_path(src, dest string) (bool, error) { return false, nil }
+
+func clone_file(out, in *os.File) bool { return false }
@@ -0,0 +1,54 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestCopyFile(t *testing.T) {
+	tdir := t.TempDir()
+	src, dest := filepath.Join(tdir, "src"), filepath.Join(tdir, "dest")
+	data := strings.Repeat("abcd", 100000)
+	if err := os.WriteFile(src, []byte(data), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dest, []byte("existing"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyFile(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != data {
+		t.Fatalf("Copied data is incorrect")
+	}
+	s, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Mode().Perm() != 0o640 || !s.ModTime().Equal(mtime) {
+		t.Fatalf("Metadata not preserved: %v %v", s.Mode(), s.ModTime())
+	}
+	entries, _ := os.ReadDir(tdir)
+	if len(entries) != 2 {
+		t.Fatalf("Temporary files left behind: %v", entries)
+	}
+	if err = CopyFile(tdir, dest); err == nil {
+		t.Fatalf("Copying a directory did not fail")
+	}
+}
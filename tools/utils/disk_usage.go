@@ -0,0 +1,99 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+type DiskUsageStats struct {
+	// The sum of the sizes of all files, as reported by ls
+	ApparentSize int64
+	// The space actually used on disk, as reported by du. Can be smaller
+	// than ApparentSize for sparse or compressed files.
+	OnDiskSize int64
+	// The part of ApparentSize that is the size of directories
+	DirectoriesSize int64
+	// The number of directories and of all other files, such as regular
+	// files and symlinks, that were counted
+	NumFiles, NumDirs int64
+}
+
+type DiskUsageOptions struct {
+	// Called periodically, from the goroutines walking the tree, with the
+	// totals so far. Calls are serialized and block the walk, so it must be fast.
+	Progress func(DiskUsageStats)
+	// The minimum interval between calls to Progress. Defaults to 100ms.
+	ProgressInterval time.Duration
+	// The maximum number of directories read concurrently
+	Workers int
+}
+
+type file_id struct {
+	dev, ino uint64
+}
+
+// The identity and allocation of a file, from its inode on file systems that
+// have them
+type inode_info struct {
+	file_id
+	nlink uint64
+	// the number of 512 byte blocks allocated for the file
+	blocks int64
+}
+
+// Calculate the space used by path and, if it is a directory, all its
+// descendants. Symlinks are not followed and files with multiple hard links
+// are counted once. Directories that cannot be read are skipped.
+func DiskUsage(path string, opts DiskUsageOptions) (ans DiskUsageStats, err error) {
+	var mutex sync.Mutex
+	seen := make(map[file_id]bool)
+	interval := IfElse(opts.ProgressInterval > 0, opts.ProgressInterval, 100*time.Millisecond)
+	last_progress := time.Now()
+
+	callback := func(_, _ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		s, err := d.Info()
+		if err != nil {
+			// the file was deleted after its directory was read
+			return nil
+		}
+		size, on_disk := s.Size(), s.Size()
+		st, ok := inode_of(s)
+		mutex.Lock()
+		if ok {
+			if st.nlink > 1 && !s.IsDir() {
+				key := st.file_id
+				if seen[key] {
+					mutex.Unlock()
+					return nil
+				}
+				seen[key] = true
+			}
+			on_disk = st.blocks * 512
+		}
+		ans.ApparentSize += size
+		ans.OnDiskSize += on_disk
+		if s.IsDir() {
+			ans.NumDirs++
+			ans.DirectoriesSize += size
+		} else {
+			ans.NumFiles++
+		}
+		if opts.Progress != nil && time.Since(last_progress) >= interval {
+			opts.Progress(ans)
+			last_progress = time.Now()
+		}
+		mutex.Unlock()
+		return nil
+	}
+	err = ParallelWalkWithSymlink(path, callback, ParallelWalkOptions{Workers: opts.Workers, DontFollowSymlinks: true})
+	return
+}
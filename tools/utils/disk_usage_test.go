@@ -0,0 +1,63 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestDiskUsage(t *testing.T) {
+	tdir := t.TempDir()
+	write := func(size int, path ...string) {
+		p := filepath.Join(tdir, filepath.Join(path...))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(100, "a")
+	write(200, "d", "b")
+	write(300, "d", "e", "c")
+	if err := os.Link(filepath.Join(tdir, "a"), filepath.Join(tdir, "d", "hardlink")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(tdir, "d"), filepath.Join(tdir, "symlink")); err != nil {
+		t.Fatal(err)
+	}
+	dir_size := func(path ...string) int64 {
+		s, err := os.Lstat(filepath.Join(tdir, filepath.Join(path...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Size()
+	}
+	num_progress_calls := 0
+	ans, err := DiskUsage(tdir, DiskUsageOptions{ProgressInterval: time.Nanosecond, Progress: func(DiskUsageStats) { num_progress_calls++ }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := 600 + dir_size() + dir_size("d") + dir_size("d", "e") + dir_size("symlink")
+	if ans.ApparentSize != expected || ans.NumFiles != 4 || ans.NumDirs != 3 {
+		t.Fatalf("Incorrect disk usage: %#v expected apparent size: %d", ans, expected)
+	}
+	if expected := dir_size() + dir_size("d") + dir_size("d", "e"); ans.DirectoriesSize != expected {
+		t.Fatalf("Incorrect size of directories: %d != %d", expected, ans.DirectoriesSize)
+	}
+	if ans.OnDiskSize <= 0 {
+		t.Fatalf("Incorrect on disk size: %d", ans.OnDiskSize)
+	}
+	if num_progress_calls == 0 {
+		t.Fatalf("Progress callback not called")
+	}
+	if ans, err = DiskUsage(filepath.Join(tdir, "a"), DiskUsageOptions{}); err != nil || ans.ApparentSize != 100 || ans.NumFiles != 1 {
+		t.Fatalf("Incorrect disk usage for a single file: %#v %v", ans, err)
+	}
+}
@@ -34,7 +34,7 @@ func (self *write_counter) Write(p []byte) (int, error) {
 }
 
 func DownloadToWriter(url string, dest io.Writer, progress_callback ReportFunc) error {
-	resp, err := http.Get(url)
+	resp, err := HTTPGet(url, nil)
 	if err != nil {
 		return err
 	}
@@ -69,7 +69,7 @@ func DownloadToFile(destpath, url string, progress_callback ReportFunc, temp_fil
 	if err != nil {
 		return err
 	}
-	dest, err := os.CreateTemp(filepath.Dir(destpath), filepath.Base(destpath)+".partial-download.")
+	dest, err := CreateTemp(filepath.Dir(destpath), filepath.Base(destpath)+".partial-download.", "")
 	if err != nil {
 		return err
 	}
@@ -79,8 +79,10 @@ func DownloadToFile(destpath, url string, progress_callback ReportFunc, temp_fil
 	dest_removed := false
 	defer func() {
 		dest.Close()
-		if !dest_removed {
-			os.Remove(dest.Name())
+		if dest_removed {
+			KeepTemp(dest.Name())
+		} else {
+			RemoveTemp(dest.Name())
 		}
 	}()
 	err = DownloadToWriter(url, dest, progress_callback)
@@ -3,46 +3,99 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"io/fs"
 	"os"
-	"syscall"
+	"path/filepath"
+	"time"
 )
 
 var _ = fmt.Print
 
-func lock(fd, op int, path string) (err error) {
-	for {
-		err = syscall.Flock(fd, op)
-		if err != syscall.EINTR {
-			break
-		}
+// An advisory lock on a file, used to serialize access to files shared by
+// concurrently running processes. The lock is released when the process exits.
+// On Windows the lock is mandatory, other processes cannot read or write the
+// file while it is locked exclusively.
+type FileLock struct {
+	file *os.File
+	path string
+}
+
+// Create a lock on the file at path, creating it if it does not exist. The
+// file is not locked until one of the Lock methods is called.
+func NewFileLock(path string) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
 	}
+	return &FileLock{file: f, path: path}, nil
+}
+
+// Create the file at path already locked, so that other processes can never
+// lock it before this one does. The file is created and locked under a
+// temporary name and then renamed to path, replacing any existing file.
+func NewLockedFileLock(path string, exclusive bool) (ans *FileLock, err error) {
+	f, err := create_temp_lock_file(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
 	if err != nil {
-		opname := "exclusive flock()"
-		switch op {
-		case syscall.LOCK_UN:
-			opname = "unlock flock()"
-		case syscall.LOCK_SH:
-			opname = "shared flock()"
+		return nil, err
+	}
+	ans = &FileLock{file: f, path: path}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			ans = nil
+		}
+	}()
+	var locked bool
+	if locked, err = ans.TryLock(exclusive); err == nil && !locked {
+		err = fmt.Errorf("The newly created lock file %s is locked by another process", f.Name())
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	return
+}
+
+func (self *FileLock) Path() string { return self.path }
+
+func (self *FileLock) TryLock(exclusive bool) (bool, error) {
+	if exclusive {
+		return TryLockFileExclusive(self.file)
+	}
+	return TryLockFileShared(self.file)
+}
+
+// Wait for the lock until ctx is done
+func (self *FileLock) Lock(ctx context.Context, exclusive bool) error {
+	delay := time.Millisecond
+	for {
+		if locked, err := self.TryLock(exclusive); locked || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Failed to lock %s with error: %w", self.file.Name(), ctx.Err())
+		case <-time.After(delay):
 		}
-		return &fs.PathError{
-			Op:   opname,
-			Path: path,
-			Err:  err,
+		if delay < 50*time.Millisecond {
+			delay *= 2
 		}
 	}
-	return nil
 }
 
-func LockFileShared(f *os.File) error {
-	return lock(int(f.Fd()), syscall.LOCK_SH, f.Name())
+// Wait for the lock for at most timeout
+func (self *FileLock) LockWithTimeout(timeout time.Duration, exclusive bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return self.Lock(ctx, exclusive)
 }
 
-func LockFileExclusive(f *os.File) error {
-	return lock(int(f.Fd()), syscall.LOCK_EX, f.Name())
+func (self *FileLock) Unlock() error {
+	return UnlockFile(self.file)
 }
 
-func UnlockFile(f *os.File) error {
-	return lock(int(f.Fd()), syscall.LOCK_UN, f.Name())
+// Release the lock, if held, and close the file
+func (self *FileLock) Close() error {
+	return self.file.Close()
 }
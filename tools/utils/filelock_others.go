@@ -0,0 +1,73 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !windows
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+var _ = fmt.Print
+
+func lock(fd, op int, path string) (err error) {
+	for {
+		err = syscall.Flock(fd, op)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		opname := "exclusive flock()"
+		switch op &^ syscall.LOCK_NB {
+		case syscall.LOCK_UN:
+			opname = "unlock flock()"
+		case syscall.LOCK_SH:
+			opname = "shared flock()"
+		}
+		return &fs.PathError{
+			Op:   opname,
+			Path: path,
+			Err:  err,
+		}
+	}
+	return nil
+}
+
+func LockFileShared(f *os.File) error {
+	return lock(int(f.Fd()), syscall.LOCK_SH, f.Name())
+}
+
+func LockFileExclusive(f *os.File) error {
+	return lock(int(f.Fd()), syscall.LOCK_EX, f.Name())
+}
+
+func UnlockFile(f *os.File) error {
+	return lock(int(f.Fd()), syscall.LOCK_UN, f.Name())
+}
+
+// Try to lock the file without blocking, returns false if the file is locked
+// by someone else
+func TryLockFileExclusive(f *os.File) (bool, error) {
+	return try_lock(f, syscall.LOCK_EX)
+}
+
+func TryLockFileShared(f *os.File) (bool, error) {
+	return try_lock(f, syscall.LOCK_SH)
+}
+
+func try_lock(f *os.File, op int) (bool, error) {
+	err := lock(int(f.Fd()), op|syscall.LOCK_NB, f.Name())
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func create_temp_lock_file(dir, pattern string) (*os.File, error) {
+	return os.CreateTemp(dir, pattern)
+}
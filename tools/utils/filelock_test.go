@@ -3,41 +3,83 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 var _ = fmt.Print
 
-func TestFileLock(t *testing.T) {
-	tdir := t.TempDir()
-
-	file_descriptor, err := os.Open(tdir)
+func TestFileLockType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	a, err := NewFileLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewFileLock(path)
 	if err != nil {
-		t.Fatalf("Initial open of %s failed with error: %s", tdir, err)
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err = a.Lock(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if locked, err := b.TryLock(false); !locked || err != nil {
+		t.Fatalf("Failed to acquire a second shared lock: %v", err)
 	}
-	if err = LockFileExclusive(file_descriptor); err != nil {
-		file_descriptor.Close()
-		t.Fatalf("Initial lock of %s failed with error: %s", tdir, err)
+	if err = b.Unlock(); err != nil {
+		t.Fatal(err)
 	}
-	defer func() {
-		_ = UnlockFile(file_descriptor)
-		file_descriptor.Close()
+	if err = b.LockWithTimeout(10*time.Millisecond, true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Exclusive lock did not time out while a shared lock is held: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err = b.Lock(ctx, true); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Exclusive lock was not canceled: %v", err)
+	}
+	unlocked := make(chan error)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		unlocked <- a.Unlock()
 	}()
-	cmd := exec.Command(KittyExe(), "+runpy", `
-import sys, os, fcntl
-fd = os.open(sys.argv[-1], os.O_RDONLY)
-try:
-	fcntl.flock(fd, fcntl.LOCK_EX | fcntl.LOCK_NB)
-except BlockingIOError:
-    sys.exit(0)
-else:
-	print("Lock unexpectedly succeeded", flush=True)
-	sys.exit(1)
-`, tdir)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		t.Fatalf("Lock test process failed with error: %s and output:\n%s", err, string(output))
+	if err = b.LockWithTimeout(5*time.Second, true); err != nil {
+		t.Fatalf("Failed to acquire exclusive lock after it was released: %v", err)
+	}
+	if err = <-unlocked; err != nil {
+		t.Fatal(err)
 	}
+	if locked, err := a.TryLock(false); locked || err != nil {
+		t.Fatalf("Acquired a shared lock while an exclusive lock is held: %v", err)
+	}
+	// locks that are created locked
+	lpath := filepath.Join(filepath.Dir(path), "locked")
+	c, err := NewLockedFileLock(lpath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Path() != lpath {
+		t.Fatalf("Incorrect path for lock: %s", c.Path())
+	}
+	d, err := NewFileLock(lpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if locked, err := d.TryLock(false); locked || err != nil {
+		t.Fatalf("Acquired a lock on a file created locked: %v", err)
+	}
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil || len(entries) != 2 {
+		t.Fatalf("Temporary lock file not renamed: %v %v", entries, err)
+	}
+
 }
@@ -0,0 +1,85 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+var _ = fmt.Print
+
+// Locks cover the whole file, as with flock(). Unlike flock() they are
+// mandatory, other handles cannot read or write the file while it is locked
+// exclusively.
+const lock_all_bytes = ^uint32(0)
+
+func lock(f *os.File, flags uint32, opname string) error {
+	ol := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, lock_all_bytes, lock_all_bytes, ol); err != nil {
+		return &fs.PathError{Op: opname, Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+func LockFileShared(f *os.File) error {
+	return lock(f, 0, "shared LockFileEx()")
+}
+
+func LockFileExclusive(f *os.File) error {
+	return lock(f, windows.LOCKFILE_EXCLUSIVE_LOCK, "exclusive LockFileEx()")
+}
+
+func UnlockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	if err := windows.UnlockFileEx(windows.Handle(f.Fd()), 0, lock_all_bytes, lock_all_bytes, ol); err != nil {
+		return &fs.PathError{Op: "UnlockFileEx()", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// Try to lock the file without blocking, returns false if the file is locked
+// by someone else
+func TryLockFileExclusive(f *os.File) (bool, error) {
+	return try_lock(f, windows.LOCKFILE_EXCLUSIVE_LOCK, "exclusive LockFileEx()")
+}
+
+func TryLockFileShared(f *os.File) (bool, error) {
+	return try_lock(f, 0, "shared LockFileEx()")
+}
+
+func try_lock(f *os.File, flags uint32, opname string) (bool, error) {
+	err := lock(f, flags|windows.LOCKFILE_FAIL_IMMEDIATELY, opname)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Like os.CreateTemp() except that the file is opened with FILE_SHARE_DELETE,
+// so that it can be renamed while it is open and locked
+func create_temp_lock_file(dir, pattern string) (*os.File, error) {
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+RandomFilename()+suffix)
+		p, err := windows.UTF16PtrFromString(LongPath(name))
+		if err != nil {
+			return nil, &fs.PathError{Op: "createtemp", Path: name, Err: err}
+		}
+		h, err := windows.CreateFile(p, windows.GENERIC_READ|windows.GENERIC_WRITE, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+			nil, windows.CREATE_NEW, windows.FILE_ATTRIBUTE_NORMAL, 0)
+		if err == nil {
+			return os.NewFile(uintptr(h), name), nil
+		}
+		if !errors.Is(err, windows.ERROR_FILE_EXISTS) {
+			return nil, &fs.PathError{Op: "createtemp", Path: name, Err: err}
+		}
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, pattern), Err: fs.ErrExist}
+}
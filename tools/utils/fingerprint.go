@@ -0,0 +1,139 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zeebo/xxh3"
+)
+
+var _ = fmt.Print
+
+// Fingerprints not used for this long are forgotten when the store is saved
+const fingerprint_max_age = 30 * 24 * time.Hour
+
+// File systems with coarse timestamps can store the same modification time
+// for changes made in quick succession, so stat data is not trusted for files
+// that were hashed within this long of being modified
+const fingerprint_racy_interval = 2 * time.Second
+
+type Fingerprint struct {
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+	// The xxh3 hash of the contents of the file
+	Hash uint64 `json:"xxh3"`
+
+	HashedAt time.Time `json:"hashed"`
+	UsedAt   time.Time `json:"used"`
+}
+
+// A persistent map of paths to the fingerprints of the files they had when
+// last checked, used to cheaply determine if files have changed across
+// runs. Files whose size and modification time are unchanged are not read.
+type FingerprintStore struct {
+	Path string
+
+	mutex   sync.Mutex
+	entries map[string]Fingerprint
+	dirty   bool
+}
+
+// Load the fingerprint store at path. A store that does not exist or cannot
+// be read is treated as empty.
+func NewFingerprintStore(path string) *FingerprintStore {
+	ans := &FingerprintStore{Path: path, entries: make(map[string]Fingerprint)}
+	if data, err := os.ReadFile(path); err == nil {
+		if json.Unmarshal(data, &ans.entries) != nil {
+			ans.entries = make(map[string]Fingerprint)
+		}
+	}
+	return ans
+}
+
+// The fingerprint store named name in the fingerprints namespace of CacheDir()
+func FingerprintStoreFor(name string) *FingerprintStore {
+	return NewFingerprintStore(filepath.Join(CacheDir(), "fingerprints", name+".json"))
+}
+
+func hash_file(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	h := xxh3.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
+
+// Return the current fingerprint of the file at path and whether its
+// contents have changed since the last time it was checked, which is always
+// true for a file that was not checked before. The contents are hashed only
+// if the size or modification time of the file are different.
+func (self *FingerprintStore) Check(path string) (fp Fingerprint, changed bool, err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	s, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if !s.Mode().IsRegular() {
+		return fp, false, fmt.Errorf("Cannot fingerprint %s as it is not a regular file", path)
+	}
+	now := time.Now()
+	self.mutex.Lock()
+	prev, found := self.entries[path]
+	self.mutex.Unlock()
+	if found && prev.Size == s.Size() && prev.ModTime.Equal(s.ModTime()) && prev.HashedAt.Sub(prev.ModTime) > fingerprint_racy_interval {
+		fp = prev
+	} else {
+		fp = Fingerprint{ModTime: s.ModTime(), Size: s.Size(), HashedAt: now}
+		if fp.Hash, err = hash_file(path); err != nil {
+			return
+		}
+		changed = !found || fp.Hash != prev.Hash || fp.Size != prev.Size
+	}
+	fp.UsedAt = now
+	self.mutex.Lock()
+	self.entries[path] = fp
+	self.dirty = true
+	self.mutex.Unlock()
+	return
+}
+
+// Write the store to disk, if it was changed, forgetting fingerprints that
+// have not been used for a long time
+func (self *FingerprintStore) Save() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if !self.dirty {
+		return nil
+	}
+	now := time.Now()
+	for path, fp := range self.entries {
+		if now.Sub(fp.UsedAt) > fingerprint_max_age {
+			delete(self.entries, path)
+		}
+	}
+	data, err := json.Marshal(self.entries)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(self.Path), 0o700); err != nil {
+		return err
+	}
+	if err = AtomicWriteFile(self.Path, data, 0o600); err == nil {
+		self.dirty = false
+	}
+	return err
+}
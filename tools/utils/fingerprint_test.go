@@ -0,0 +1,69 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestFingerprintStore(t *testing.T) {
+	tdir := t.TempDir()
+	path := filepath.Join(tdir, "file")
+	store_path := filepath.Join(tdir, "store", "fingerprints.json")
+	old := time.Now().Add(-time.Hour)
+	write := func(data string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(s *FingerprintStore, expected bool) Fingerprint {
+		t.Helper()
+		fp, changed, err := s.Check(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != expected {
+			t.Fatalf("Changed: %v expected: %v", changed, expected)
+		}
+		return fp
+	}
+	write("abc", old)
+	s := NewFingerprintStore(store_path)
+	fp := check(s, true)
+	check(s, false)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = NewFingerprintStore(store_path)
+	if q := check(s, false); q.Hash != fp.Hash || !q.HashedAt.Equal(fp.HashedAt) {
+		t.Fatalf("Fingerprint not loaded from store")
+	}
+	// unchanged stat data means the file is not read
+	write("xyz", old)
+	check(s, false)
+	// changed modification time but same contents
+	write("abc", old.Add(time.Minute))
+	if check(s, false).Hash != fp.Hash {
+		t.Fatalf("Hash changed for the same contents")
+	}
+	write("abcd", old)
+	check(s, true)
+	// files modified just before they were hashed are always hashed
+	now := time.Now()
+	write("1234", now)
+	check(s, true)
+	write("5678", now)
+	check(s, true)
+	if _, _, err := s.Check(tdir); err == nil {
+		t.Fatalf("Fingerprinting a directory did not fail")
+	}
+}
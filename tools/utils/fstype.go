@@ -0,0 +1,48 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// FUSE file systems that are known to be implemented locally, all others
+// are assumed to be network file systems
+var local_fuse_file_systems = NewSet[string]()
+
+func init() {
+	local_fuse_file_systems.AddItems("ntfs-3g", "ntfs", "exfat", "lxcfs", "portal", "bindfs", "mergerfs", "gocryptfs", "encfs", "squashfuse", "appimaged")
+}
+
+// Whether a file system of the specified type, as returned by FSType(), in
+// which the name of a FUSE file system is prefixed by fuse., is a FUSE file
+// system that is not known to be local
+func is_remote_fuse(fs_type string) bool {
+	fuse_type, is_fuse := strings.CutPrefix(fs_type, "fuse.")
+	if !is_fuse {
+		return fs_type == "fuse"
+	}
+	return !local_fuse_file_systems.Has(fuse_type)
+}
+
+// The type of the file system containing path, for example: ext4, apfs, nfs
+// or fuse.sshfs. FUSE file systems have the prefix fuse. when their type can
+// be determined. Returns an empty string on platforms where the type cannot
+// be determined.
+func FSType(path string) (string, error) {
+	name, _, err := fs_type(path)
+	return name, err
+}
+
+// Whether path is on a network file system, such as NFS, SMB or SSHFS, or
+// on a FUSE file system not known to be local. On such file systems hashing
+// entire files, memory mapping them or watching them for changes can be very
+// slow or unreliable. Returns false if the file system could not be
+// determined.
+func IsNetworkFS(path string) bool {
+	_, is_network, err := fs_type(path)
+	return err == nil && is_network
+}
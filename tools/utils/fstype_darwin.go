@@ -0,0 +1,26 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func fs_type(path string) (name string, is_network bool, err error) {
+	var st unix.Statfs_t
+	if err = unix.Statfs(path, &st); err != nil {
+		return "", false, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	name = unix.ByteSliceToString(st.Fstypename[:])
+	// macFUSE and FUSE-T report the same type for all FUSE file systems
+	if strings.Contains(name, "fuse") {
+		return "fuse." + name, true, nil
+	}
+	return name, st.Flags&unix.MNT_LOCAL == 0, nil
+}
@@ -0,0 +1,103 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+const (
+	smb_super_magic  = 0x517b
+	afs_super_magic  = 0x5346414f
+	coda_super_magic = 0x73757245
+	ceph_super_magic = 0xc36400
+	v9fs_magic       = 0x1021997
+	fuse_super_magic = 0x65735546
+	nfs_super_magic  = 0x6969
+	zfs_super_magic  = 0x2fc12fc1
+	ntfs_sb_magic    = 0x5346544e
+)
+
+var fs_names = map[uint32]string{
+	unix.EXT4_SUPER_MAGIC: "ext4", unix.BTRFS_SUPER_MAGIC: "btrfs", unix.XFS_SUPER_MAGIC: "xfs", zfs_super_magic: "zfs",
+	unix.F2FS_SUPER_MAGIC: "f2fs", unix.TMPFS_MAGIC: "tmpfs", unix.RAMFS_MAGIC: "ramfs", unix.OVERLAYFS_SUPER_MAGIC: "overlay",
+	unix.SQUASHFS_MAGIC: "squashfs", unix.ISOFS_SUPER_MAGIC: "iso9660", unix.MSDOS_SUPER_MAGIC: "vfat", unix.EXFAT_SUPER_MAGIC: "exfat",
+	ntfs_sb_magic: "ntfs", unix.ECRYPTFS_SUPER_MAGIC: "ecryptfs", unix.PROC_SUPER_MAGIC: "proc", unix.SYSFS_MAGIC: "sysfs",
+	unix.OCFS2_SUPER_MAGIC: "ocfs2", nfs_super_magic: "nfs", smb_super_magic: "smbfs", unix.SMB2_SUPER_MAGIC: "smb3", unix.CIFS_SUPER_MAGIC: "cifs",
+	afs_super_magic: "afs", coda_super_magic: "coda", ceph_super_magic: "ceph", v9fs_magic: "9p", fuse_super_magic: "fuse",
+}
+
+var network_fs_magics = func() *Set[uint32] {
+	ans := NewSet[uint32]()
+	ans.AddItems(nfs_super_magic, smb_super_magic, unix.SMB2_SUPER_MAGIC, unix.CIFS_SUPER_MAGIC, afs_super_magic, coda_super_magic, ceph_super_magic, v9fs_magic)
+	return ans
+}()
+
+// Undo the octal escaping of spaces and other special characters in mountinfo
+func unescape_mountinfo_field(x string) string {
+	if !strings.Contains(x, `\`) {
+		return x
+	}
+	var b strings.Builder
+	for i := 0; i < len(x); i++ {
+		if x[i] == '\\' && i+3 < len(x) {
+			if n, err := strconv.ParseUint(x[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(x[i])
+	}
+	return b.String()
+}
+
+// The type of the mount containing path from mountinfo, as the kernel only
+// reports that a file system is FUSE, not which FUSE file system it is
+func fs_type_from_mountinfo(mountinfo []byte, path string) (ans string) {
+	longest := -1
+	s := NewRecordScanner(bytes.NewReader(mountinfo))
+	for s.Scan() {
+		// 36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		before, after, found := strings.Cut(s.Text(), " - ")
+		fields, after_fields := strings.Fields(before), strings.Fields(after)
+		if !found || len(fields) < 5 || len(after_fields) < 1 {
+			continue
+		}
+		mount_point := unescape_mountinfo_field(fields[4])
+		if len(mount_point) > longest && (path == mount_point || strings.HasPrefix(path, strings.TrimSuffix(mount_point, "/")+"/")) {
+			longest, ans = len(mount_point), after_fields[0]
+		}
+	}
+	return
+}
+
+func fs_type(path string) (name string, is_network bool, err error) {
+	var st unix.Statfs_t
+	if err = unix.Statfs(path, &st); err != nil {
+		return "", false, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	// the magic numbers are 32 bit but Type is signed on some architectures
+	magic := uint32(st.Type)
+	if magic == fuse_super_magic {
+		name = "fuse"
+		if data, rerr := os.ReadFile("/proc/self/mountinfo"); rerr == nil {
+			if q := fs_type_from_mountinfo(data, transform_symlink(Abspath(path))); strings.HasPrefix(q, "fuse") {
+				name = q
+			}
+		}
+		return name, is_remote_fuse(name), nil
+	}
+	if name = fs_names[magic]; name == "" {
+		name = fmt.Sprintf("0x%x", magic)
+	}
+	return name, network_fs_magics.Has(magic), nil
+}
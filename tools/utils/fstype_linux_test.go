@@ -0,0 +1,25 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestMountInfo(t *testing.T) {
+	mountinfo := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+30 22 0:27 / /home/kovid/mnt\040point rw,nosuid shared:2 - fuse.sshfs host:/ rw,user_id=1000
+31 22 0:28 / /home/kovid/mnt\040pointless rw shared:3 - fuse.rclone remote: rw
+`
+	for path, expected := range map[string]string{
+		"/usr/bin": "ext4", "/home/kovid/mnt point": "fuse.sshfs", "/home/kovid/mnt point/x": "fuse.sshfs",
+		"/home/kovid/mnt pointless/y": "fuse.rclone", "/home/kovid/mnt pointer": "ext4",
+	} {
+		if actual := fs_type_from_mountinfo([]byte(mountinfo), path); actual != expected {
+			t.Fatalf("Incorrect file system type for %#v: %#v != %#v", path, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !linux && !darwin
+
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+var _ = fmt.Print
+
+// The type cannot be determined, but errors are reported as on other platforms
+func fs_type(path string) (name string, is_network bool, err error) {
+	_, err = os.Stat(path)
+	return "", false, err
+}
@@ -0,0 +1,32 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestFSType(t *testing.T) {
+	for fs_type, expected := range map[string]bool{
+		"fuse": true, "fuse.sshfs": true, "fuse.rclone": true, "fuse.ntfs-3g": false, "fuseblk": false, "ext4": false, "nfs": false,
+	} {
+		if actual := is_remote_fuse(fs_type); actual != expected {
+			t.Fatalf("is_remote_fuse(%#v) != %v", fs_type, expected)
+		}
+	}
+	tdir := t.TempDir()
+	name, err := FSType(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name == "" && (runtime.GOOS == "linux" || runtime.GOOS == "darwin") {
+		t.Fatalf("Could not determine the file system type of: %s", tdir)
+	}
+	if _, err = FSType(tdir + "/does-not-exist"); err == nil {
+		t.Fatalf("No error for path that does not exist")
+	}
+}
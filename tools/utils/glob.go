@@ -0,0 +1,32 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+var _ = fmt.Print
+
+// Return the paths matching pattern, sorted. In addition to the syntax
+// supported by filepath.Match, ** matches any number of directories, {a,b}
+// matches either a or b and a leading ~ is expanded to the home directory.
+func Glob(pattern string) ([]string, error) {
+	ans, err := doublestar.FilepathGlob(Expanduser(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid glob pattern with error: %w", pattern, err)
+	}
+	slices.Sort(ans)
+	return ans, nil
+}
+
+// Whether path matches pattern, using the same syntax as Glob(). Invalid
+// patterns match nothing.
+func GlobMatch(pattern, path string) bool {
+	matched, err := doublestar.PathMatch(filepath.FromSlash(pattern), path)
+	return matched && err == nil
+}
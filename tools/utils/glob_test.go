@@ -0,0 +1,59 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestGlob(t *testing.T) {
+	tdir := t.TempDir()
+	for _, x := range []string{"a.go", "b.py", "sub/c.go", "sub/deep/d.go", "sub/deep/e.txt", "f1", "f2"} {
+		path := filepath.Join(tdir, x)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := func(pattern string, expected ...string) {
+		t.Helper()
+		actual, err := Glob(filepath.Join(tdir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, x := range actual {
+			actual[i], _ = filepath.Rel(tdir, x)
+		}
+		if len(actual) == 0 {
+			actual = nil
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Incorrect matches for %s:\n%s", pattern, diff)
+		}
+	}
+	q("**/*.go", "a.go", "sub/c.go", "sub/deep/d.go")
+	q("*.{go,py}", "a.go", "b.py")
+	q("f[0-1]", "f1")
+	q("sub/**/e.*", "sub/deep/e.txt")
+	q("nothing*")
+	if _, err := Glob("[x"); err == nil {
+		t.Fatalf("No error for invalid pattern")
+	}
+	for pattern, path := range map[string]string{"**/*.go": "x/y/z.go", "*.{a,b}": "x.b", "a/*/c": "a/b/c"} {
+		if !GlobMatch(pattern, path) {
+			t.Fatalf("%s did not match %s", pattern, path)
+		}
+	}
+	if GlobMatch("a/*/c", "a/b/b/c") {
+		t.Fatalf("* matched a directory separator")
+	}
+}
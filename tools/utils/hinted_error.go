@@ -0,0 +1,74 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+var _ = fmt.Print
+
+// An error with guidance for the user on how to fix it. The hint is shown
+// after the error message when the error causes a kitten to exit. A non-zero
+// ExitCode overrides the exit code the kitten would otherwise use.
+type HintedError struct {
+	Err      error
+	Hint     string
+	ExitCode int
+}
+
+func (self *HintedError) Error() string { return self.Err.Error() }
+func (self *HintedError) Unwrap() error { return self.Err }
+
+// Wrap err with a hint for the user, returns nil if err is nil. The hint can
+// use the markup understood by the cli package, such as :opt:`name`.
+func WithHint(err error, hint string) error {
+	if err == nil {
+		return nil
+	}
+	return &HintedError{Err: err, Hint: hint}
+}
+
+// Same as WithHint() but also sets the exit code
+func WithHintAndExitCode(err error, hint string, exit_code int) error {
+	if err == nil {
+		return nil
+	}
+	return &HintedError{Err: err, Hint: hint, ExitCode: exit_code}
+}
+
+// The hints of all HintedErrors in the tree of err, outermost first
+func HintsFor(err error) (ans []string) {
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		if he, ok := err.(*HintedError); ok && he.Hint != "" {
+			ans = append(ans, he.Hint)
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(x.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, e := range x.Unwrap() {
+				walk(e)
+			}
+		}
+	}
+	walk(err)
+	return
+}
+
+// The exit code of the outermost HintedError in err that has one, or zero
+func ExitCodeFor(err error) int {
+	var he *HintedError
+	for errors.As(err, &he) {
+		if he.ExitCode != 0 {
+			return he.ExitCode
+		}
+		err = he.Err
+	}
+	return 0
+}
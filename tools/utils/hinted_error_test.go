@@ -0,0 +1,43 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestHintedError(t *testing.T) {
+	if WithHint(nil, "x") != nil || WithHintAndExitCode(nil, "x", 3) != nil {
+		t.Fatalf("Wrapping a nil error did not return nil")
+	}
+	inner := WithHintAndExitCode(fs.ErrNotExist, "inner hint", 3)
+	err := WithHint(fmt.Errorf("Failed to open: %w", inner), "outer hint")
+	if err.Error() != "Failed to open: file does not exist" {
+		t.Fatalf("Incorrect error message: %s", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Wrapped error not found")
+	}
+	if diff := cmp.Diff([]string{"outer hint", "inner hint"}, HintsFor(err)); diff != "" {
+		t.Fatalf("Incorrect hints:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"inner hint"}, HintsFor(errors.Join(errors.New("x"), inner))); diff != "" {
+		t.Fatalf("Incorrect hints for joined errors:\n%s", diff)
+	}
+	if ec := ExitCodeFor(err); ec != 3 {
+		t.Fatalf("Incorrect exit code: %d", ec)
+	}
+	if ec := ExitCodeFor(WithHintAndExitCode(err, "", 7)); ec != 7 {
+		t.Fatalf("Outermost exit code not used: %d", ec)
+	}
+	if ec := ExitCodeFor(errors.New("x")); ec != 0 {
+		t.Fatalf("Incorrect exit code for plain error: %d", ec)
+	}
+}
@@ -0,0 +1,187 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+const http_max_attempts = 4
+
+// The delay before the first retry, doubled for every subsequent retry
+var http_retry_delay = time.Second
+
+func http_transport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// Honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	t.Proxy = http.ProxyFromEnvironment
+	t.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = 15 * time.Second
+	t.ResponseHeaderTimeout = time.Minute
+	if path := os.Getenv("KITTY_EXTRA_CA_CERTS"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the certificates from KITTY_EXTRA_CA_CERTS with error: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No valid PEM certificates found in KITTY_EXTRA_CA_CERTS: %s", path)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return t, nil
+}
+
+type http_client struct {
+	client *http.Client
+	err    error
+}
+
+var shared_http_client = sync.OnceValue(func() http_client {
+	t, err := http_transport()
+	if err != nil {
+		return http_client{err: err}
+	}
+	return http_client{client: &http.Client{Transport: t}}
+})
+
+// The HTTP client shared by all kittens. It uses the proxy specified by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables and trusts the
+// system certificates as well as any in the file pointed to by the
+// KITTY_EXTRA_CA_CERTS environment variable.
+func HTTPClient() (*http.Client, error) {
+	c := shared_http_client()
+	return c.client, c.err
+}
+
+func describe_http_error(req *http.Request, err error) error {
+	if proxy, perr := http.ProxyFromEnvironment(req); perr == nil && proxy != nil {
+		return fmt.Errorf("%w (using the proxy: %s)", err, proxy.Redacted())
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return fmt.Errorf("%w (check your network connection or set the HTTPS_PROXY environment variable if you are behind a proxy)", err)
+	}
+	return err
+}
+
+func is_transient_http_status(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// Make a GET request to url using the shared HTTP client, retrying with
+// exponential backoff on network errors and transient server errors. The
+// caller must close the body of the returned response.
+func HTTPGet(url string, headers map[string]string) (resp *http.Response, err error) {
+	client, err := HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	delay := http_retry_delay
+	for attempt := 1; ; attempt++ {
+		resp, err = client.Do(req)
+		if attempt == http_max_attempts || (err == nil && !is_transient_http_status(resp.StatusCode)) {
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if err != nil {
+		return nil, describe_http_error(req, err)
+	}
+	return resp, nil
+}
+
+type http_cache_entry struct {
+	URL       string `json:"url"`
+	Etag      string `json:"etag"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Download url, caching the result in CacheDir(). Cached data younger than
+// max_age is returned without contacting the server, older data is
+// revalidated using its ETag.
+func DownloadCached(url string, max_age time.Duration) ([]byte, error) {
+	return download_cached(filepath.Join(CacheDir(), "http"), url, max_age)
+}
+
+func download_cached(cache_dir, url string, max_age time.Duration) (data []byte, err error) {
+	h := sha256.Sum256(UnsafeStringToBytes(url))
+	key := hex.EncodeToString(h[:])
+	data_path, meta_path := filepath.Join(cache_dir, key), filepath.Join(cache_dir, key+".json")
+	var meta http_cache_entry
+	if raw, err := os.ReadFile(meta_path); err == nil && json.Unmarshal(raw, &meta) == nil && meta.URL == url {
+		if data, err = os.ReadFile(data_path); err == nil {
+			if ts, err := ISO8601Parse(meta.Timestamp); err == nil && time.Since(ts) < max_age {
+				return data, nil
+			}
+		} else {
+			meta, data = http_cache_entry{}, nil
+		}
+	} else {
+		meta = http_cache_entry{}
+	}
+	headers := map[string]string{}
+	if meta.Etag != "" {
+		headers["If-None-Match"] = meta.Etag
+	}
+	resp, err := HTTPGet(url, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if meta.Etag == "" {
+			return nil, fmt.Errorf("The server responded with the HTTP error: %s", resp.Status)
+		}
+	case http.StatusOK:
+		b := bytes.Buffer{}
+		if _, err = io.Copy(&b, resp.Body); err != nil {
+			return nil, err
+		}
+		data = b.Bytes()
+		meta = http_cache_entry{URL: url, Etag: resp.Header.Get("ETag")}
+	default:
+		return nil, fmt.Errorf("The server responded with the HTTP error: %s", resp.Status)
+	}
+	meta.Timestamp = ISO8601Format(time.Now())
+	// failing to cache is not an error
+	if os.MkdirAll(cache_dir, 0o755) == nil {
+		if resp.StatusCode == http.StatusNotModified || AtomicWriteFile(data_path, data, 0o644) == nil {
+			if raw, err := json.Marshal(meta); err == nil {
+				_ = AtomicWriteFile(meta_path, raw, 0o644)
+			}
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,60 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestHTTPDownloadCached(t *testing.T) {
+	orig := http_retry_delay
+	defer func() { http_retry_delay = orig }()
+	http_retry_delay = time.Millisecond
+	requests, failures := 0, 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "data")
+	}))
+	defer server.Close()
+	cache_dir := t.TempDir()
+	get := func(max_age time.Duration, expected_requests int) {
+		t.Helper()
+		requests = 0
+		data, err := download_cached(cache_dir, server.URL, max_age)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "data" {
+			t.Fatalf("Incorrect data downloaded: %#v", string(data))
+		}
+		if requests != expected_requests {
+			t.Fatalf("Incorrect number of requests: %d != %d", requests, expected_requests)
+		}
+	}
+	// retried after transient failures
+	get(time.Hour, 3)
+	// fresh cache
+	get(time.Hour, 0)
+	// revalidated with the ETag
+	get(0, 1)
+	failures = http_max_attempts
+	if _, err := download_cached(cache_dir, server.URL, 0); err == nil {
+		t.Fatalf("No error when the server keeps failing")
+	}
+}
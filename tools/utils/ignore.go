@@ -0,0 +1,175 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+var _ = fmt.Print
+
+// Rules for skipping files when walking a directory tree, using the gitignore
+// syntax. See https://git-scm.com/docs/gitignore
+type IgnoreRules struct {
+	// Names of files, such as .gitignore or .ignore, that contain rules
+	// applying to the directory they are in and its descendants
+	FileNames []string
+	// Rules relative to the root of the walk. They take precedence over the
+	// rules in files.
+	Patterns []string
+}
+
+type ignore_pattern struct {
+	glob               string
+	negated, dir_only  bool
+	base               string
+	matches_any_prefix bool
+}
+
+// Parse a single line in gitignore syntax. base is the directory, relative
+// to the root of the walk, using / as the separator, that the rule is for.
+func parse_ignore_pattern(line, base string) (ans ignore_pattern, ok bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return
+	}
+	ans.base = base
+	if line[0] == '!' {
+		ans.negated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		ans.dir_only = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return
+	}
+	// a pattern with a separator is relative to base, other patterns
+	// match a name at any depth
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		ans.matches_any_prefix = true
+	}
+	// braces are not special in gitignore
+	ans.glob = strings.NewReplacer("{", `\{`, "}", `\}`).Replace(line)
+	if !doublestar.ValidatePattern(ans.glob) {
+		return
+	}
+	return ans, true
+}
+
+func (self *ignore_pattern) matches(rel string, is_dir bool) bool {
+	if self.dir_only && !is_dir {
+		return false
+	}
+	if self.base != "" {
+		if !strings.HasPrefix(rel, self.base+"/") {
+			return false
+		}
+		rel = rel[len(self.base)+1:]
+	}
+	if self.matches_any_prefix {
+		rel = path.Base(rel)
+	}
+	matched, err := doublestar.Match(self.glob, rel)
+	return matched && err == nil
+}
+
+func parse_ignore_file(data []byte, base string) (ans []ignore_pattern) {
+	s := NewRecordScanner(bytes.NewReader(data))
+	for s.Scan() {
+		if p, ok := parse_ignore_pattern(s.Text(), base); ok {
+			ans = append(ans, p)
+		}
+	}
+	return
+}
+
+// Matches paths in a directory tree against IgnoreRules. The rules files in
+// each directory are read the first time a path in that directory is
+// checked. Safe for concurrent use.
+type IgnoreMatcher struct {
+	root       string
+	file_names []string
+	patterns   []ignore_pattern
+
+	mutex   sync.Mutex
+	per_dir map[string][]ignore_pattern
+}
+
+// Create a matcher for the directory tree rooted at root. Lines in the rules
+// that are invalid are ignored, as git does.
+func NewIgnoreMatcher(root string, rules IgnoreRules) *IgnoreMatcher {
+	ans := IgnoreMatcher{root: root, file_names: rules.FileNames, per_dir: make(map[string][]ignore_pattern)}
+	for _, line := range rules.Patterns {
+		if p, ok := parse_ignore_pattern(line, ""); ok {
+			ans.patterns = append(ans.patterns, p)
+		}
+	}
+	return &ans
+}
+
+func (self *IgnoreMatcher) rules_for_dir(dir string) []ignore_pattern {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	ans, found := self.per_dir[dir]
+	if !found {
+		for _, name := range self.file_names {
+			if data, err := os.ReadFile(filepath.Join(self.root, filepath.FromSlash(dir), name)); err == nil {
+				ans = append(ans, parse_ignore_file(data, dir)...)
+			}
+		}
+		self.per_dir[dir] = ans
+	}
+	return ans
+}
+
+// Whether the path, relative to the root, should be skipped. The rules files
+// of its parent directories apply with the deepest taking precedence, the
+// last matching rule decides. Note that, as with git, it is not possible
+// to include a path whose parent directory is ignored, callers are expected
+// to not descend into ignored directories.
+func (self *IgnoreMatcher) IsIgnored(rel string, is_dir bool) bool {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	ignored := false
+	check := func(patterns []ignore_pattern) {
+		for i := range patterns {
+			if patterns[i].matches(rel, is_dir) {
+				ignored = !patterns[i].negated
+			}
+		}
+	}
+	if len(self.file_names) > 0 {
+		parts := strings.Split(rel, "/")
+		for i := range parts {
+			check(self.rules_for_dir(strings.Join(parts[:i], "/")))
+		}
+	}
+	check(self.patterns)
+	return ignored
+}
+
+// Whether path, which must be the root or a path below it, should be skipped
+func (self *IgnoreMatcher) is_path_ignored(path string, is_dir bool) bool {
+	rel, err := filepath.Rel(self.root, path)
+	return err == nil && self.IsIgnored(rel, is_dir)
+}
@@ -0,0 +1,91 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestIgnoreRules(t *testing.T) {
+	m := NewIgnoreMatcher(t.TempDir(), IgnoreRules{Patterns: []string{
+		"# comment", "", "*.o", "!keep.o", "build/", "/top", "doc/*.txt", "a/**/z", `\#hash`, "trailing  ", "{x,y}",
+	}})
+	for rel, expected := range map[string]bool{
+		"x.o": true, "sub/x.o": true, "keep.o": false, "sub/keep.o": false,
+		"build": false, "top": true, "sub/top": false,
+		"doc/a.txt": true, "doc/sub/a.txt": false, "a/z": true, "a/b/c/z": true,
+		"#hash": true, "comment": false, "trailing": true, "{x,y}": true, "x": false,
+		".": false, "../x.o": false,
+	} {
+		if actual := m.IsIgnored(rel, false); actual != expected {
+			t.Fatalf("IsIgnored(%#v) != %v", rel, expected)
+		}
+	}
+	if !m.IsIgnored("build", true) || !m.IsIgnored("sub/build", true) {
+		t.Fatalf("Directory only pattern matched incorrectly")
+	}
+
+	tdir := t.TempDir()
+	write := func(data string, path ...string) {
+		p := filepath.Join(tdir, filepath.Join(path...))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("node_modules/\n*.log\n", ".gitignore")
+	write("", "node_modules", "pkg", "index.js")
+	write("", "a.log")
+	write("!important.log\nlocal\n", "sub", ".ignore")
+	write("", "sub", "important.log")
+	write("", "sub", "other.log")
+	write("", "sub", "local", "x")
+	write("", "local")
+	write("", "sub", "file")
+	write("", ".git", "HEAD")
+	if err := os.Symlink(filepath.Join(tdir, "sub"), filepath.Join(tdir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	rules := IgnoreRules{FileNames: []string{".gitignore", ".ignore"}, Patterns: []string{".git"}}
+	expected := []string{
+		".", ".gitignore", "link", "link/.ignore", "link/file", "link/important.log", "local",
+		"sub", "sub/.ignore", "sub/file", "sub/important.log",
+	}
+	collect := func(walk func(Walk_callback) error) []string {
+		var mutex sync.Mutex
+		var seen []string
+		if err := walk(func(path, abspath string, d fs.DirEntry, err error) error {
+			rel, _ := filepath.Rel(tdir, path)
+			mutex.Lock()
+			seen = append(seen, filepath.ToSlash(rel))
+			mutex.Unlock()
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(seen)
+		return seen
+	}
+	if diff := cmp.Diff(expected, collect(func(cb Walk_callback) error { return WalkWithSymlinkIgnoring(tdir, rules, cb) })); diff != "" {
+		t.Fatalf("WalkWithSymlinkIgnoring did not skip ignored files:\n%s", diff)
+	}
+	for _, ordered := range []bool{true, false} {
+		if diff := cmp.Diff(expected, collect(func(cb Walk_callback) error {
+			return ParallelWalkWithSymlink(tdir, cb, ParallelWalkOptions{Ordered: ordered, Ignore: &rules})
+		})); diff != "" {
+			t.Fatalf("ParallelWalkWithSymlink (ordered: %v) did not skip ignored files:\n%s", ordered, diff)
+		}
+	}
+}
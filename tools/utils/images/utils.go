@@ -3,10 +3,12 @@
 package images
 
 import (
+	"context"
 	"fmt"
 	"runtime"
-	"sync"
 	"sync/atomic"
+
+	"kitty/tools/utils"
 )
 
 var _ = fmt.Print
@@ -44,13 +46,15 @@ func (self *Context) Parallel(start, stop int, fn func(<-chan int)) {
 	}
 	close(c)
 
-	var wg sync.WaitGroup
+	pool := utils.NewPool(utils.PoolOptions[struct{}]{Workers: procs})
 	for i := 0; i < procs; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+		pool.Submit(func(context.Context) (struct{}, error) {
 			fn(c)
-		}()
+			return struct{}{}, nil
+		})
+	}
+	// re-panic in the calling goroutine, where it can be recovered
+	if err := pool.Wait(); err != nil {
+		panic(err)
 	}
-	wg.Wait()
 }
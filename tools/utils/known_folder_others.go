@@ -0,0 +1,13 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+func known_folder(local bool) string { return "" }
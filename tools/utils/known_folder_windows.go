@@ -0,0 +1,21 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build windows
+
+package utils
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+var _ = fmt.Print
+
+func known_folder(local bool) string {
+	ans, err := windows.KnownFolderPath(IfElse(local, windows.FOLDERID_LocalAppData, windows.FOLDERID_RoamingAppData), windows.KF_FLAG_DEFAULT)
+	if err != nil {
+		return ""
+	}
+	return ans
+}
@@ -0,0 +1,74 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+var _ = fmt.Print
+
+// An error for paths that are longer than the OS supports, either as a whole
+// or because one of their components is too long
+type PathTooLongError struct {
+	Path string
+	// The component of Path that is too long, empty if Path as a whole is too long
+	Component string
+	Length    int
+	Limit     int
+}
+
+func elide_path(path string) string {
+	const head, tail = 32, 64
+	if len(path) <= head+tail+3 {
+		return path
+	}
+	return path[:head] + "…" + path[len(path)-tail:]
+}
+
+func (self *PathTooLongError) Error() string {
+	if self.Component != "" {
+		return fmt.Sprintf("The name %s in the path %s is %d bytes long, longer than the maximum of %d bytes supported by this OS", elide_path(self.Component), elide_path(self.Path), self.Length, self.Limit)
+	}
+	if self.Limit > 0 {
+		return fmt.Sprintf("The path %s is %d bytes long, longer than the maximum of %d bytes supported by this OS", elide_path(self.Path), self.Length, self.Limit)
+	}
+	return fmt.Sprintf("The path %s is %d bytes long, too long for the file system it is on", elide_path(self.Path), self.Length)
+}
+
+func (self *PathTooLongError) Unwrap() error { return syscall.ENAMETOOLONG }
+
+// Return a *PathTooLongError if path or one of its components is longer
+// than the OS supports, nil otherwise. Note that individual file systems can
+// have lower limits.
+func CheckPathLength(path string) error {
+	if lp := LongPath(path); len(lp) > max_path_length {
+		return &PathTooLongError{Path: path, Length: len(lp), Limit: max_path_length}
+	}
+	for _, c := range strings.Split(filepath.ToSlash(path), "/") {
+		if len(c) > max_name_length {
+			return &PathTooLongError{Path: path, Component: c, Length: len(c), Limit: max_name_length}
+		}
+	}
+	return nil
+}
+
+// If err was caused by a path being too long, return a *PathTooLongError
+// describing the problem, otherwise return err unchanged. Useful to replace
+// the rather opaque "file name too long" errors from the OS.
+func ExplainPathError(err error) error {
+	var pe *fs.PathError
+	var tl *PathTooLongError
+	if err == nil || errors.As(err, &tl) || !errors.Is(err, syscall.ENAMETOOLONG) || !errors.As(err, &pe) {
+		return err
+	}
+	if ans := CheckPathLength(pe.Path); ans != nil {
+		return ans
+	}
+	return &PathTooLongError{Path: pe.Path, Length: len(pe.Path)}
+}
@@ -0,0 +1,20 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// PATH_MAX includes the terminating nul
+const max_path_length = unix.PathMax - 1
+const max_name_length = 255
+
+// Only needed on Windows, returns path unchanged
+func LongPath(path string) string { return path }
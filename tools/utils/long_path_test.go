@@ -0,0 +1,85 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !windows
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func TestLongPaths(t *testing.T) {
+	if err := CheckPathLength("/a/b/c"); err != nil {
+		t.Fatalf("Unexpected error for a short path: %s", err)
+	}
+	var tl *PathTooLongError
+	if err := CheckPathLength("/a/" + strings.Repeat("n", max_name_length+1)); !errors.As(err, &tl) || tl.Component == "" || !errors.Is(err, syscall.ENAMETOOLONG) {
+		t.Fatalf("Long component not detected: %v", err)
+	}
+
+	// create a tree deeper than PATH_MAX using paths relative to directory file descriptors
+	tdir := t.TempDir()
+	name := strings.Repeat("d", 200)
+	fd, err := unix.Open(tdir, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth := max_path_length/(len(name)+1) + 2
+	for i := 0; i < depth; i++ {
+		if err = unix.Mkdirat(fd, name, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		nfd, err := unix.Openat(fd, name, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+		unix.Close(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd = nfd
+	}
+	unix.Close(fd)
+	deepest := tdir + strings.Repeat("/"+name, depth)
+	_, err = os.Lstat(deepest)
+	if err = ExplainPathError(err); !errors.As(err, &tl) || tl.Component != "" || tl.Limit != max_path_length {
+		t.Fatalf("Long path not detected: %v", err)
+	}
+
+	check_walk := func(which string, walk func(Walk_callback) error) {
+		num_dirs, too_long := 0, ""
+		err := walk(func(path, abspath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if !errors.As(err, &tl) {
+					t.Fatalf("%s: Unexpected error passed to callback: %s", which, err)
+				}
+				too_long = path
+			} else if d.IsDir() {
+				num_dirs++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: walk failed with error: %s", which, err)
+		}
+		if too_long == "" || len(too_long) <= max_path_length || !strings.HasPrefix(too_long, tdir) {
+			t.Fatalf("%s: too long path not reported, got: %#v", which, elide_path(too_long))
+		}
+		// the directory that is too long to read is itself passed to the
+		// callback first, since its parent could be read
+		if expected := len(strings.Split(too_long[len(tdir):], "/")); num_dirs != expected {
+			t.Fatalf("%s: walked %d directories instead of %d", which, num_dirs, expected)
+		}
+	}
+	check_walk("WalkWithSymlink", func(cb Walk_callback) error { return WalkWithSymlink(tdir, cb) })
+	check_walk("ParallelWalkWithSymlink", func(cb Walk_callback) error {
+		return ParallelWalkWithSymlink(tdir, cb, ParallelWalkOptions{Ordered: true})
+	})
+}
@@ -0,0 +1,35 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// The limit for extended length paths
+const max_path_length = 32767
+const max_name_length = 255
+
+// Paths at least this long must use the extended length form, the limit is
+// lower than MAX_PATH because directories must leave space for an 8.3 file name
+const max_unprefixed_path_length = 248
+
+// Convert absolute paths too long for the Win32 API to the extended length
+// form, \\?\C:\... or \\?\UNC\server\share\... for UNC paths. Other paths are
+// returned unchanged.
+func LongPath(path string) string {
+	if len(path) < max_unprefixed_path_length || !filepath.IsAbs(path) || strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+	// extended length paths are not normalized by the OS, so must not
+	// contain forward slashes or . and .. components
+	path = filepath.Clean(path)
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
@@ -0,0 +1,27 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestLongPaths(t *testing.T) {
+	long := strings.Repeat("d", max_unprefixed_path_length)
+	for path, expected := range map[string]string{
+		`C:\a\b`:                 `C:\a\b`,
+		`a\` + long:              `a\` + long,
+		`C:\` + long:             `\\?\C:\` + long,
+		`C:/x/../` + long:        `\\?\C:\` + long,
+		`\\server\share\` + long: `\\?\UNC\server\share\` + long,
+		`\\?\C:\` + long:         `\\?\C:\` + long,
+	} {
+		if actual := LongPath(path); actual != expected {
+			t.Fatalf("Incorrect long path for %#v: %#v", path, actual)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"sync"
+)
+
+var _ = fmt.Print
+
+// Like sync.OnceValues(), calls a function the first time its result is
+// needed and remembers the result, including any error, for subsequent
+// calls. Unlike sync.OnceValues() the result can be forgotten with Reset(), so
+// that the function is called again the next time, for example, after the
+// environment it depends on changes.
+type OnceWithError[T any] struct {
+	mutex sync.Mutex
+	f     func() (T, error)
+	done  bool
+	value T
+	err   error
+	// incremented by Reset(), so that results of calls started before a
+	// reset are not remembered
+	generation uint64
+}
+
+func NewOnceWithError[T any](f func() (T, error)) *OnceWithError[T] {
+	return &OnceWithError[T]{f: f}
+}
+
+// Return the result of the function, calling it only if it has not been
+// called since the last reset. The function is called without any locks
+// held, so that it can itself use this Once. Concurrent callers that find no
+// result each call the function and all get the result of the call that
+// completes first. If the function panics, the panic is propagated and the
+// function is called again on the next call.
+func (self *OnceWithError[T]) Get() (T, error) {
+	self.mutex.Lock()
+	if self.done {
+		defer self.mutex.Unlock()
+		return self.value, self.err
+	}
+	generation := self.generation
+	self.mutex.Unlock()
+	value, err := self.f()
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if generation != self.generation {
+		// reset while the function was running
+		return value, err
+	}
+	if !self.done {
+		self.done, self.value, self.err = true, value, err
+	}
+	return self.value, self.err
+}
+
+// Forget the result, so that the function is called again by the next Get()
+func (self *OnceWithError[T]) Reset() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	var zero T
+	self.done, self.value, self.err = false, zero, nil
+	self.generation++
+}
+
+// Like OnceWithError, for functions that cannot fail
+type Once[T any] struct {
+	o OnceWithError[T]
+}
+
+func NewOnce[T any](f func() T) *Once[T] {
+	return &Once[T]{o: OnceWithError[T]{f: func() (T, error) { return f(), nil }}}
+}
+
+// Return the result of the function, calling it only if it has not been
+// called since the last reset
+func (self *Once[T]) Get() T {
+	ans, _ := self.o.Get()
+	return ans
+}
+
+// Forget the result, so that the function is called again by the next Get()
+func (self *Once[T]) Reset() { self.o.Reset() }
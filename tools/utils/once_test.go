@@ -0,0 +1,97 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestOnce(t *testing.T) {
+	calls := 0
+	var fail error
+	o := NewOnceWithError(func() (int, error) {
+		calls++
+		return calls, fail
+	})
+	check := func(expected_val, expected_calls int, expected_err error) {
+		t.Helper()
+		val, err := o.Get()
+		if val != expected_val || err != expected_err || calls != expected_calls {
+			t.Fatalf("Unexpected result: (%d, %v) with %d calls instead of: (%d, %v) with %d calls", val, err, calls, expected_val, expected_err, expected_calls)
+		}
+	}
+	check(1, 1, nil)
+	check(1, 1, nil)
+	o.Reset()
+	failed := errors.New("failed")
+	fail = failed
+	check(2, 2, failed)
+	// failures are remembered too
+	fail = nil
+	check(2, 2, failed)
+	o.Reset()
+	check(3, 3, nil)
+
+	// panics are propagated and the function is called again
+	should_panic := true
+	p := NewOnce(func() string {
+		if should_panic {
+			panic("panicked")
+		}
+		return "ok"
+	})
+	func() {
+		defer func() {
+			if r := recover(); r != "panicked" {
+				t.Fatalf("Unexpected recovered value: %#v", r)
+			}
+		}()
+		p.Get()
+	}()
+	should_panic = false
+	if x := p.Get(); x != "ok" {
+		t.Fatalf("Unexpected value after panic: %#v", x)
+	}
+
+	// concurrent callers all get the same result
+	var counter atomic.Int32
+	c := NewOnce(func() int { return int(counter.Add(1)) })
+	var wg sync.WaitGroup
+	results := make([]int, 16)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.Get()
+		}()
+	}
+	wg.Wait()
+	for _, x := range results {
+		if x != c.Get() {
+			t.Fatalf("Concurrent callers got different values: %v", results)
+		}
+	}
+	c.Reset()
+	if x, n := c.Get(), int(counter.Load()); x != n {
+		t.Fatalf("Unexpected value after reset: %d != %d", x, n)
+	}
+
+	// the function can use the Once without deadlocking
+	var r *Once[int]
+	depth := 0
+	r = NewOnce(func() int {
+		if depth++; depth < 3 {
+			return r.Get() + 1
+		}
+		return 0
+	})
+	if x := r.Get(); x != 0 || r.Get() != 0 {
+		t.Fatalf("Unexpected value for recursive use: %d", x)
+	}
+}
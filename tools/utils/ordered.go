@@ -0,0 +1,155 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type ordered_map_entry[K comparable, V any] struct {
+	key K
+	val V
+}
+
+// A map that remembers the order in which keys were first added. Lookups,
+// insertions and deletions are O(1). Not safe for concurrent use.
+type OrderedMap[K comparable, V any] struct {
+	entries map[K]*list.Element
+	order   *list.List
+}
+
+func NewOrderedMap[K comparable, V any](capacity ...int) *OrderedMap[K, V] {
+	c := 8
+	if len(capacity) > 0 {
+		c = capacity[0]
+	}
+	return &OrderedMap[K, V]{entries: make(map[K]*list.Element, c), order: list.New()}
+}
+
+// Set the value for key. Keys that are already present keep their position.
+func (self *OrderedMap[K, V]) Set(key K, val V) {
+	if e, found := self.entries[key]; found {
+		e.Value.(*ordered_map_entry[K, V]).val = val
+	} else {
+		self.entries[key] = self.order.PushBack(&ordered_map_entry[K, V]{key: key, val: val})
+	}
+}
+
+// Set the value for key only if key is not present, returning true if the
+// value was set
+func (self *OrderedMap[K, V]) SetIfAbsent(key K, val V) bool {
+	if _, found := self.entries[key]; found {
+		return false
+	}
+	self.entries[key] = self.order.PushBack(&ordered_map_entry[K, V]{key: key, val: val})
+	return true
+}
+
+func (self *OrderedMap[K, V]) Get(key K) (ans V, found bool) {
+	if e, ok := self.entries[key]; ok {
+		return e.Value.(*ordered_map_entry[K, V]).val, true
+	}
+	return
+}
+
+func (self *OrderedMap[K, V]) Has(key K) bool {
+	_, found := self.entries[key]
+	return found
+}
+
+// Remove key, returning true if it was present
+func (self *OrderedMap[K, V]) Delete(key K) bool {
+	e, found := self.entries[key]
+	if found {
+		self.order.Remove(e)
+		delete(self.entries, key)
+	}
+	return found
+}
+
+func (self *OrderedMap[K, V]) Len() int {
+	return len(self.entries)
+}
+
+// Call f with every key and value in insertion order. f must not modify the map.
+func (self *OrderedMap[K, V]) ForEach(f func(K, V)) {
+	for e := self.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*ordered_map_entry[K, V])
+		f(entry.key, entry.val)
+	}
+}
+
+func (self *OrderedMap[K, V]) Keys() []K {
+	ans := make([]K, 0, self.Len())
+	self.ForEach(func(k K, _ V) { ans = append(ans, k) })
+	return ans
+}
+
+func (self *OrderedMap[K, V]) Values() []V {
+	ans := make([]V, 0, self.Len())
+	self.ForEach(func(_ K, v V) { ans = append(ans, v) })
+	return ans
+}
+
+func (self *OrderedMap[K, V]) String() string {
+	parts := make([]string, 0, self.Len())
+	self.ForEach(func(k K, v V) { parts = append(parts, fmt.Sprintf("%#v: %#v", k, v)) })
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// A set that remembers the order in which items were first added. Membership
+// tests, insertions and removals are O(1). Not safe for concurrent use.
+type OrderedSet[T comparable] struct {
+	m *OrderedMap[T, struct{}]
+}
+
+func NewOrderedSet[T comparable](capacity ...int) *OrderedSet[T] {
+	return &OrderedSet[T]{m: NewOrderedMap[T, struct{}](capacity...)}
+}
+
+func NewOrderedSetWithItems[T comparable](items ...T) *OrderedSet[T] {
+	ans := NewOrderedSet[T](len(items))
+	ans.AddItems(items...)
+	return ans
+}
+
+// Add val, returning true if it was not already present. Items that are
+// already present keep their position.
+func (self *OrderedSet[T]) Add(val T) bool {
+	return self.m.SetIfAbsent(val, struct{}{})
+}
+
+func (self *OrderedSet[T]) AddItems(val ...T) {
+	for _, x := range val {
+		self.m.SetIfAbsent(x, struct{}{})
+	}
+}
+
+func (self *OrderedSet[T]) Remove(val T) bool {
+	return self.m.Delete(val)
+}
+
+func (self *OrderedSet[T]) Has(val T) bool {
+	return self.m.Has(val)
+}
+
+func (self *OrderedSet[T]) Len() int {
+	return self.m.Len()
+}
+
+// Call f with every item in insertion order. f must not modify the set.
+func (self *OrderedSet[T]) ForEach(f func(T)) {
+	self.m.ForEach(func(k T, _ struct{}) { f(k) })
+}
+
+func (self *OrderedSet[T]) AsSlice() []T {
+	return self.m.Keys()
+}
+
+func (self *OrderedSet[T]) String() string {
+	return fmt.Sprintf("%#v", self.AsSlice())
+}
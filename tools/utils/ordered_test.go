@@ -0,0 +1,66 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	m.Set("c", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+	m.Set("a", 4)
+	if m.SetIfAbsent("b", 5) || !m.SetIfAbsent("d", 6) {
+		t.Fatalf("SetIfAbsent() did not respect existing keys")
+	}
+	if diff := cmp.Diff([]string{"c", "a", "b", "d"}, m.Keys()); diff != "" {
+		t.Fatalf("Unexpected keys:\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{1, 4, 3, 6}, m.Values()); diff != "" {
+		t.Fatalf("Unexpected values:\n%s", diff)
+	}
+	if v, found := m.Get("a"); !found || v != 4 {
+		t.Fatalf("Unexpected value for a: %d %v", v, found)
+	}
+	if _, found := m.Get("x"); found || m.Has("x") {
+		t.Fatalf("Found a key that was never set")
+	}
+	if !m.Delete("a") || m.Delete("a") || m.Has("a") {
+		t.Fatalf("Delete() failed")
+	}
+	m.Set("a", 7)
+	if diff := cmp.Diff([]string{"c", "b", "d", "a"}, m.Keys()); diff != "" {
+		t.Fatalf("Unexpected keys after re-adding a deleted key:\n%s", diff)
+	}
+	if m.Len() != 4 || m.String() != `{"c": 1, "b": 3, "d": 6, "a": 7}` {
+		t.Fatalf("Unexpected map: %d %s", m.Len(), m)
+	}
+}
+
+func TestOrderedSet(t *testing.T) {
+	s := NewOrderedSetWithItems(3, 1, 3, 2, 1)
+	if diff := cmp.Diff([]int{3, 1, 2}, s.AsSlice()); diff != "" {
+		t.Fatalf("Unexpected items:\n%s", diff)
+	}
+	if s.Add(1) || !s.Add(0) {
+		t.Fatalf("Add() did not report whether items were new")
+	}
+	if !s.Remove(3) || s.Remove(3) || s.Has(3) || !s.Has(0) {
+		t.Fatalf("Remove() failed")
+	}
+	var items []int
+	s.ForEach(func(x int) { items = append(items, x) })
+	if diff := cmp.Diff([]int{1, 2, 0}, items); diff != "" {
+		t.Fatalf("Unexpected items:\n%s", diff)
+	}
+	if s.Len() != 3 {
+		t.Fatalf("Unexpected length: %d", s.Len())
+	}
+}
@@ -0,0 +1,266 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var _ = fmt.Print
+
+type ParallelWalkOptions struct {
+	// The maximum number of directories processed concurrently, defaults to
+	// the number of CPUs
+	Workers int
+	// Call the callback in the same order and from the same goroutine as
+	// WalkWithSymlink does. Directories are still read concurrently, ahead of
+	// the callback. When false, the callback is called concurrently from
+	// multiple goroutines, in no particular order, except that a directory
+	// is always passed to the callback before its contents.
+	Ordered bool
+	// Transforms applied to paths before resolving symlinks, as for WalkWithSymlink
+	Transformers []func(string) string
+	// Skip files and directories matching these rules, as for WalkWithSymlinkIgnoring
+	Ignore *IgnoreRules
+	// Pass symlinks to directories to the callback as is, instead of
+	// recursing into them
+	DontFollowSymlinks bool
+	// Pass all errors from reading directories to the callback, by default
+	// only errors caused by paths being too long are passed and directories
+	// that cannot be read are ignored
+	ReportErrors bool
+}
+
+type dir_listing struct {
+	entries []fs.DirEntry
+	err     error
+}
+
+type parallel_walker struct {
+	callback  Walk_callback
+	transform func(string) string
+	workers   int
+	ignore    *IgnoreMatcher
+	follow    bool
+	report    bool
+
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	// ordered mode
+	prefetcher *Pool[struct{}]
+	prefetch   map[string]chan dir_listing
+
+	// unordered mode
+	pool *Pool[struct{}]
+}
+
+// we cant use filepath.Join here as it calls Clean() which can alter dirpath if it contains .. or . etc.
+func path_based_on(dirpath, rpath string) string {
+	if !strings.HasSuffix(dirpath, Sep) && dirpath != "" {
+		dirpath += Sep
+	}
+	return dirpath + rpath
+}
+
+func (self *parallel_walker) mark_seen(resolved string) bool {
+	key := CanonicalPath(resolved)
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.seen[key] {
+		return false
+	}
+	self.seen[key] = true
+	return true
+}
+
+func (self *parallel_walker) read_dir(path string) ([]fs.DirEntry, error) {
+	if self.prefetch != nil {
+		self.mutex.Lock()
+		ch := self.prefetch[path]
+		delete(self.prefetch, path)
+		self.mutex.Unlock()
+		if ch != nil {
+			r := <-ch
+			return r.entries, r.err
+		}
+	}
+	return os.ReadDir(path)
+}
+
+func (self *parallel_walker) start_prefetch(paths []string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, path := range paths {
+		if _, found := self.prefetch[path]; found {
+			continue
+		}
+		ch := make(chan dir_listing, 1)
+		self.prefetch[path] = ch
+		self.prefetcher.Submit(func(context.Context) (struct{}, error) {
+			entries, err := os.ReadDir(path)
+			ch <- dir_listing{entries, err}
+			return struct{}{}, nil
+		})
+	}
+}
+
+// Pass errors caused by paths being too long to the callback, ignoring other
+// errors unless ReportErrors is set
+func (self *parallel_walker) report_error(path, abspath string, d fs.DirEntry, err error) error {
+	if !self.report && !errors.Is(err, syscall.ENAMETOOLONG) {
+		return nil
+	}
+	if err = self.callback(path, abspath, d, ExplainPathError(err)); err == fs.SkipDir {
+		err = nil
+	}
+	return err
+}
+
+// The equivalent of transformed_walker.walk(). visit is called to process
+// the contents of directories.
+func (self *parallel_walker) walk_root(dirpath string, visit func(resolved_root, dirpath, dir string) error) error {
+	resolved_root := self.transform(dirpath)
+	if !self.mark_seen(resolved_root) {
+		return nil
+	}
+	s, err := os.Lstat(resolved_root)
+	if err != nil {
+		return self.report_error(path_based_on(dirpath, "."), resolved_root, nil, err)
+	}
+	d := fs.FileInfoToDirEntry(s)
+	if err = self.callback(path_based_on(dirpath, "."), resolved_root, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir {
+			err = nil
+		}
+		return err
+	}
+	return visit(resolved_root, dirpath, resolved_root)
+}
+
+// Process the entries of dir, calling recurse for every sub-directory and
+// walk_root for every symlink to a directory
+func (self *parallel_walker) process_dir(
+	resolved_root, dirpath, dir string, recurse func(resolved_root, dirpath, dir string) error, walk_root func(string) error,
+) error {
+	entries, err := self.read_dir(dir)
+	if err != nil {
+		// Happens if ReadDir failed, skip the directory in that case
+		if rpath, rerr := filepath.Rel(resolved_root, dir); rerr == nil {
+			return self.report_error(path_based_on(dirpath, rpath), dir, nil, err)
+		}
+		return nil
+	}
+	if self.prefetch != nil {
+		var dirs []string
+		for _, e := range entries {
+			if e.IsDir() {
+				dirs = append(dirs, filepath.Join(dir, e.Name()))
+			}
+		}
+		self.start_prefetch(dirs)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		rpath, err := filepath.Rel(resolved_root, path)
+		if err != nil {
+			return err
+		}
+		opath := path_based_on(dirpath, rpath)
+		needs_recurse := self.follow && needs_symlink_recurse(path, e)
+		if self.ignore != nil && self.ignore.is_path_ignored(opath, needs_recurse || e.IsDir()) {
+			continue
+		}
+		if needs_recurse {
+			err = walk_root(opath)
+		} else if err = self.callback(opath, path, e, nil); err == nil && e.IsDir() {
+			err = recurse(resolved_root, dirpath, path)
+		}
+		if err != nil {
+			if err == fs.SkipDir {
+				if e.IsDir() {
+					continue
+				}
+				// skip the remaining entries in this directory
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *parallel_walker) walk_ordered(dirpath string) error {
+	var visit func(resolved_root, dirpath, dir string) error
+	walk_root := func(dirpath string) error { return self.walk_root(dirpath, visit) }
+	visit = func(resolved_root, dirpath, dir string) error {
+		return self.process_dir(resolved_root, dirpath, dir, visit, walk_root)
+	}
+	err := walk_root(dirpath)
+	// wait for outstanding prefetches so that no goroutines are leaked
+	_ = self.prefetcher.Wait()
+	if err == fs.SkipAll {
+		err = nil
+	}
+	return err
+}
+
+func (self *parallel_walker) schedule(job func() error) {
+	self.pool.Submit(func(context.Context) (struct{}, error) { return struct{}{}, job() })
+}
+
+func (self *parallel_walker) walk_unordered(dirpath string) error {
+	var visit func(resolved_root, dirpath, dir string) error
+	var walk_root func(string) error
+	visit = func(resolved_root, dirpath, dir string) error {
+		self.schedule(func() error { return self.process_dir(resolved_root, dirpath, dir, visit, walk_root) })
+		return nil
+	}
+	walk_root = func(dirpath string) error {
+		self.schedule(func() error { return self.walk_root(dirpath, visit) })
+		return nil
+	}
+	// depth first to limit the number of queued directories
+	self.pool = NewPool(PoolOptions[struct{}]{Workers: self.workers, LIFO: true})
+	_ = walk_root(dirpath)
+	return self.pool.Wait()
+}
+
+// Same as WalkWithSymlink except that directories are read concurrently,
+// which is much faster for large trees. See ParallelWalkOptions for the
+// order in which callback is called.
+func ParallelWalkWithSymlink(dirpath string, callback Walk_callback, opts ParallelWalkOptions) error {
+	transform := func(path string) string {
+		for _, t := range opts.Transformers {
+			path = t(path)
+		}
+		return transform_symlink(path)
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	w := parallel_walker{callback: callback, transform: transform, workers: workers, seen: make(map[string]bool), follow: !opts.DontFollowSymlinks, report: opts.ReportErrors}
+	if opts.Ignore != nil {
+		w.ignore = NewIgnoreMatcher(dirpath, *opts.Ignore)
+	}
+	if opts.Ordered {
+		w.prefetcher = NewPool(PoolOptions[struct{}]{Workers: workers})
+		w.prefetch = make(map[string]chan dir_listing)
+		return w.walk_ordered(dirpath)
+	}
+	err := w.walk_unordered(dirpath)
+	if errors.Is(err, fs.SkipAll) {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestParallelWalkWithSymlink(t *testing.T) {
+	tdir := t.TempDir()
+	mkdir := func(path ...string) {
+		if err := os.MkdirAll(filepath.Join(tdir, filepath.Join(path...)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	touch := func(path ...string) {
+		if err := os.WriteFile(filepath.Join(tdir, filepath.Join(path...)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 5; j++ {
+			mkdir("root", fmt.Sprint("d", i), fmt.Sprint("s", j))
+			touch("root", fmt.Sprint("d", i), fmt.Sprint("s", j), "f")
+		}
+		touch("root", fmt.Sprint("d", i), "f")
+	}
+	mkdir("other", "x")
+	touch("other", "x", "y")
+	if err := os.Symlink(filepath.Join(tdir, "other"), filepath.Join(tdir, "root", "link")); err != nil {
+		t.Fatal(err)
+	}
+	// a loop, should be ignored as the target has already been seen
+	if err := os.Symlink(filepath.Join(tdir, "root"), filepath.Join(tdir, "root", "d0", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(tdir, "root")
+
+	var expected []string
+	var mutex sync.Mutex
+	var actual []string
+	collect := func(dest *[]string) Walk_callback {
+		return func(path, abspath string, d fs.DirEntry, err error) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			*dest = append(*dest, path+"|"+abspath)
+			return nil
+		}
+	}
+	if err := WalkWithSymlink(root, collect(&expected)); err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) < 110 {
+		t.Fatalf("Too few entries walked: %d", len(expected))
+	}
+
+	for _, workers := range []int{1, 4} {
+		actual = nil
+		if err := ParallelWalkWithSymlink(root, collect(&actual), ParallelWalkOptions{Workers: workers, Ordered: true}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Ordered walk with %d workers differs from WalkWithSymlink:\n%s", workers, diff)
+		}
+		actual = nil
+		if err := ParallelWalkWithSymlink(root, collect(&actual), ParallelWalkOptions{Workers: workers}); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(actual)
+		sorted_expected := slices.Clone(expected)
+		slices.Sort(sorted_expected)
+		if diff := cmp.Diff(sorted_expected, actual); diff != "" {
+			t.Fatalf("Unordered walk with %d workers differs from WalkWithSymlink:\n%s", workers, diff)
+		}
+	}
+
+	// SkipDir and errors
+	for _, ordered := range []bool{true, false} {
+		actual = nil
+		err := ParallelWalkWithSymlink(root, func(path, abspath string, d fs.DirEntry, err error) error {
+			if d.IsDir() && d.Name() != "root" && d.Name() != "d3" {
+				return fs.SkipDir
+			}
+			return collect(&actual)(path, abspath, d, err)
+		}, ParallelWalkOptions{Ordered: ordered})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range actual {
+			if filepath.Base(filepath.Dir(x)) == "s0" {
+				t.Fatalf("Walked into skipped directory: %s", x)
+			}
+		}
+		if !slices.Contains(actual, filepath.Join(root, "d3", "f")+"|"+filepath.Join(root, "d3", "f")) {
+			t.Fatalf("Did not walk into d3: %v", actual)
+		}
+		e := fmt.Errorf("test error")
+		err = ParallelWalkWithSymlink(root, func(path, abspath string, d fs.DirEntry, err error) error {
+			if d.Name() == "s2" {
+				return e
+			}
+			return nil
+		}, ParallelWalkOptions{Ordered: ordered})
+		if err != e {
+			t.Fatalf("Error not returned from walk, ordered: %v: %v", ordered, err)
+		}
+		// errors are only passed to the callback if requested
+		missing := filepath.Join(tdir, "missing")
+		for _, report := range []bool{true, false} {
+			var reported error
+			err = ParallelWalkWithSymlink(missing, func(path, abspath string, d fs.DirEntry, err error) error {
+				reported = err
+				return nil
+			}, ParallelWalkOptions{Ordered: ordered, ReportErrors: report})
+			if err != nil || (reported != nil) != report {
+				t.Fatalf("Incorrect error reporting for missing directory with ordered: %v report: %v: %v", ordered, report, reported)
+			}
+		}
+	}
+}
@@ -5,25 +5,32 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/base32"
+	"errors"
 	"fmt"
 	"io/fs"
 	not_rand "math/rand/v2"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"unicode/utf8"
-
-	"golang.org/x/sys/unix"
 )
 
 var Sep = string(os.PathSeparator)
 
+// The per user application data directory on Windows, %LOCALAPPDATA% if local
+// is true, otherwise the roaming %APPDATA%
+func windows_app_data_dir(local bool) string {
+	if q := os.Getenv(IfElse(local, "LOCALAPPDATA", "APPDATA")); q != "" {
+		return q
+	}
+	return known_folder(local)
+}
+
 func Expanduser(path string) string {
 	if !strings.HasPrefix(path, "~") {
 		return path
@@ -57,62 +64,108 @@ func Expanduser(path string) string {
 	return strings.Join(parts, Sep)
 }
 
+// The absolute path for path, in the extended length form on Windows if it
+// is too long for the Win32 API, see LongPath()
 func Abspath(path string) string {
 	q, err := filepath.Abs(path)
 	if err == nil {
-		return q
+		return LongPath(q)
 	}
 	return path
 }
 
-var KittyExe = sync.OnceValue(func() string {
+var kitty_exe_once = NewOnceWithError(func() (string, error) {
 	exe, err := os.Executable()
 	if err == nil {
 		ans := filepath.Join(filepath.Dir(exe), "kitty")
 		if s, err := os.Stat(ans); err == nil && !s.IsDir() {
-			return ans
+			return ans, nil
 		}
 	}
-	return os.Getenv("KITTY_PATH_TO_KITTY_EXE")
+	if ans := os.Getenv("KITTY_PATH_TO_KITTY_EXE"); ans != "" {
+		return ans, nil
+	}
+	return "", fmt.Errorf("Could not find the kitty executable, it is not next to this program and KITTY_PATH_TO_KITTY_EXE is not set")
 })
 
-func ConfigDirForName(name string) (config_dir string) {
-	if kcd := os.Getenv("KITTY_CONFIG_DIRECTORY"); kcd != "" {
-		return Abspath(Expanduser(kcd))
-	}
+// The path to the kitty executable or an error if it cannot be found
+var FindKittyExe = kitty_exe_once.Get
+
+// The path to the kitty executable or the empty string if it cannot be found
+func KittyExe() string {
+	ans, _ := kitty_exe_once.Get()
+	return ans
+}
+
+type ConfigDirCandidate struct {
+	// The kitty directory inside one of the XDG config locations
+	Path string
+	// Whether Path is an existing directory
+	Exists bool
+}
+
+// The directories searched for kitty config files, highest priority first,
+// without duplicates. This is the directory from KITTY_CONFIG_DIRECTORY, if
+// set, otherwise the kitty directories in XDG_CONFIG_HOME, XDG_CONFIG_DIRS and
+// ~/.config and, on macOS, ~/Library/Preferences. Useful for loading layered
+// config, where files from higher priority directories override those from
+// lower priority ones.
+func ConfigDirs() (ans []ConfigDirCandidate) {
 	var locations []string
-	seen := NewSet[string]()
-	add := func(x string) {
-		x = Abspath(Expanduser(x))
-		if !seen.Has(x) {
-			seen.Add(x)
-			locations = append(locations, x)
+	if kcd := os.Getenv("KITTY_CONFIG_DIRECTORY"); kcd != "" {
+		locations = append(locations, Abspath(Expanduser(kcd)))
+	} else {
+		unique := NewOrderedMap[string, string]()
+		add := func(x string) {
+			if x == "" {
+				return
+			}
+			x = filepath.Join(Abspath(Expanduser(x)), "kitty")
+			// paths differing only in case can be the same directory on macOS
+			unique.SetIfAbsent(CanonicalPath(x), x)
 		}
-	}
-	if xh := os.Getenv("XDG_CONFIG_HOME"); xh != "" {
-		add(xh)
-	}
-	if dirs := os.Getenv("XDG_CONFIG_DIRS"); dirs != "" {
-		for _, candidate := range strings.Split(dirs, ":") {
-			add(candidate)
+		add(os.Getenv("XDG_CONFIG_HOME"))
+		if dirs := os.Getenv("XDG_CONFIG_DIRS"); dirs != "" {
+			for _, candidate := range filepath.SplitList(dirs) {
+				add(candidate)
+			}
 		}
+		add("~/.config")
+		switch runtime.GOOS {
+		case "darwin":
+			add("~/Library/Preferences")
+		case "windows":
+			add(windows_app_data_dir(false))
+		}
+		locations = unique.Values()
 	}
-	add("~/.config")
-	if runtime.GOOS == "darwin" {
-		add("~/Library/Preferences")
+	ans = make([]ConfigDirCandidate, len(locations))
+	for i, loc := range locations {
+		s, err := os.Stat(loc)
+		ans[i] = ConfigDirCandidate{Path: loc, Exists: err == nil && s.IsDir()}
 	}
-	for _, loc := range locations {
-		if loc != "" {
-			q := filepath.Join(loc, "kitty")
-			if _, err := os.Stat(filepath.Join(q, name)); err == nil {
-				if unix.Access(q, unix.W_OK) == nil {
-					config_dir = q
-					return
+	return
+}
+
+// The highest priority writable config directory containing name, or the
+// default config directory if there is none
+func ConfigDirForName(name string) (config_dir string) {
+	if kcd := os.Getenv("KITTY_CONFIG_DIRECTORY"); kcd != "" {
+		return Abspath(Expanduser(kcd))
+	}
+	for _, c := range ConfigDirs() {
+		if c.Exists {
+			if _, err := os.Stat(filepath.Join(c.Path, name)); err == nil {
+				if access(c.Path, w_ok) == nil {
+					return c.Path
 				}
 			}
 		}
 	}
 	config_dir = os.Getenv("XDG_CONFIG_HOME")
+	if config_dir == "" && runtime.GOOS == "windows" {
+		config_dir = windows_app_data_dir(false)
+	}
 	if config_dir == "" {
 		config_dir = "~/.config"
 	}
@@ -120,16 +173,20 @@ func ConfigDirForName(name string) (config_dir string) {
 	return
 }
 
-var ConfigDir = sync.OnceValue(func() (config_dir string) {
+var config_dir_once = NewOnce(func() (config_dir string) {
 	return ConfigDirForName("kitty.conf")
 })
 
-var CacheDir = sync.OnceValue(func() (cache_dir string) {
+var ConfigDir = config_dir_once.Get
+
+var cache_dir_once = NewOnce(func() (cache_dir string) {
 	candidate := ""
 	if edir := os.Getenv("KITTY_CACHE_DIRECTORY"); edir != "" {
 		candidate = Abspath(Expanduser(edir))
 	} else if runtime.GOOS == "darwin" {
 		candidate = Expanduser("~/Library/Caches/kitty")
+	} else if q := windows_app_data_dir(true); runtime.GOOS == "windows" && q != "" {
+		candidate = filepath.Join(q, "kitty", "cache")
 	} else {
 		candidate = os.Getenv("XDG_CACHE_HOME")
 		if candidate == "" {
@@ -141,57 +198,74 @@ var CacheDir = sync.OnceValue(func() (cache_dir string) {
 	return candidate
 })
 
-func macos_user_cache_dir() string {
-	// Sadly Go does not provide confstr() so we use this hack.
-	// Note that given a user generateduid and uid we can derive this by using
-	// the algorithm at https://github.com/ydkhatri/MacForensics/blob/master/darwin_path_generator.py
-	// but I cant find a good way to get the generateduid. Requires calling dscl in which case we might as well call getconf
-	// The data is in /var/db/dslocal/nodes/Default/users/<username>.plist but it needs root
-	// So instead we use various hacks to get it quickly, falling back to running /usr/bin/getconf
+var CacheDir = cache_dir_once.Get
 
-	is_ok := func(m string) bool {
-		s, err := os.Stat(m)
-		if err != nil {
-			return false
+// windows_default is relative to %LOCALAPPDATA%
+func xdg_dir(env_override, xdg_env, xdg_default, macos_default, windows_default string) (ans string) {
+	if edir := os.Getenv(env_override); edir != "" {
+		ans = Abspath(Expanduser(edir))
+	} else if runtime.GOOS == "darwin" {
+		ans = Expanduser(macos_default)
+	} else if q := windows_app_data_dir(true); runtime.GOOS == "windows" && q != "" {
+		ans = filepath.Join(q, windows_default)
+	} else {
+		ans = os.Getenv(xdg_env)
+		if ans == "" {
+			ans = xdg_default
 		}
-		stat, ok := s.Sys().(unix.Stat_t)
-		return ok && s.IsDir() && int(stat.Uid) == os.Geteuid() && s.Mode().Perm() == 0o700 && unix.Access(m, unix.X_OK|unix.W_OK|unix.R_OK) == nil
+		ans = filepath.Join(Expanduser(ans), "kitty")
 	}
+	_ = os.MkdirAll(ans, 0o755)
+	return
+}
 
-	if tdir := strings.TrimRight(os.Getenv("TMPDIR"), "/"); filepath.Base(tdir) == "T" {
-		if m := filepath.Join(filepath.Dir(tdir), "C"); is_ok(m) {
-			return m
-		}
-	}
+var data_dir_once = NewOnce(func() string {
+	return xdg_dir("KITTY_DATA_DIRECTORY", "XDG_DATA_HOME", "~/.local/share", "~/Library/Application Support/kitty", "kitty")
+})
 
-	matches, err := filepath.Glob("/private/var/folders/*/*/C")
-	if err == nil {
-		for _, m := range matches {
-			if is_ok(m) {
-				return m
+// The directory for persistent data that is not configuration, such as
+// downloaded resources.
+var DataDir = data_dir_once.Get
+
+var state_dir_once = NewOnce(func() string {
+	return xdg_dir("KITTY_STATE_DIRECTORY", "XDG_STATE_HOME", "~/.local/state", "~/Library/Application Support/kitty/state", filepath.Join("kitty", "state"))
+})
+
+// The directory for persistent state that should survive the cache being
+// cleared, such as history.
+var StateDir = state_dir_once.Get
+
+// Return the path to relpath in StateDir(), moving it from CacheDir(), where
+// older versions of kitty stored state, if needed
+func StatePath(relpath string) string {
+	ans := filepath.Join(StateDir(), relpath)
+	if _, err := os.Lstat(ans); errors.Is(err, fs.ErrNotExist) {
+		old := filepath.Join(CacheDir(), relpath)
+		if _, err := os.Lstat(old); err == nil {
+			if os.MkdirAll(filepath.Dir(ans), 0o755) == nil {
+				_ = os.Rename(old, ans)
 			}
 		}
 	}
-	out, err := exec.Command("/usr/bin/getconf", "DARWIN_USER_CACHE_DIR").Output()
-	if err == nil {
-		return strings.TrimRight(strings.TrimSpace(UnsafeBytesToString(out)), "/")
-	}
-	return ""
+	return ans
 }
 
-var RuntimeDir = sync.OnceValue(func() (runtime_dir string) {
+var runtime_dir_once = NewOnce(func() (runtime_dir string) {
 	var candidate string
 	if q := os.Getenv("KITTY_RUNTIME_DIRECTORY"); q != "" {
 		candidate = q
 	} else if runtime.GOOS == "darwin" {
 		candidate = macos_user_cache_dir()
+	} else if runtime.GOOS == "windows" {
+		// there is no per user runtime directory on Windows
+		candidate = filepath.Join(CacheDir(), "run")
 	} else if q := os.Getenv("XDG_RUNTIME_DIR"); q != "" {
 		candidate = q
 	}
 	candidate = strings.TrimRight(candidate, "/")
 	if candidate == "" {
 		q := fmt.Sprintf("/run/user/%d", os.Geteuid())
-		if s, err := os.Stat(q); err == nil && s.IsDir() && unix.Access(q, unix.X_OK|unix.R_OK|unix.W_OK) == nil {
+		if s, err := os.Stat(q); err == nil && s.IsDir() && access(q, x_ok|r_ok|w_ok) == nil {
 			candidate = q
 		} else {
 			candidate = filepath.Join(CacheDir(), "run")
@@ -204,6 +278,23 @@ var RuntimeDir = sync.OnceValue(func() (runtime_dir string) {
 	return candidate
 })
 
+var RuntimeDir = runtime_dir_once.Get
+
+// Forget the paths found by KittyExe(), ConfigDir(), CacheDir(), DataDir(),
+// StateDir() and RuntimeDir(), so that they are found again when next
+// needed. For use after changes to the environment variables they depend on.
+func ResetPaths() {
+	kitty_exe_once.Reset()
+	config_dir_once.Reset()
+	cache_dir_once.Reset()
+	data_dir_once.Reset()
+	state_dir_once.Reset()
+	runtime_dir_once.Reset()
+}
+
+// err is non-nil only for paths that could not be read because they are too
+// long for the OS, in which case it is a *PathTooLongError and d can be nil.
+// Other unreadable paths are skipped.
 type Walk_callback func(path, abspath string, d fs.DirEntry, err error) error
 
 func transform_symlink(path string) string {
@@ -227,31 +318,38 @@ type transformed_walker struct {
 	real_callback      Walk_callback
 	transform_func     func(string) string
 	needs_recurse_func func(string, fs.DirEntry) bool
+	ignore             *IgnoreMatcher
 }
 
 func (self *transformed_walker) walk(dirpath string) error {
 	resolved_path := self.transform_func(dirpath)
-	if self.seen[resolved_path] {
+	key := CanonicalPath(resolved_path)
+	if self.seen[key] {
 		return nil
 	}
-	self.seen[resolved_path] = true
+	self.seen[key] = true
 
 	c := func(path string, d fs.DirEntry, err error) error {
+		rpath, rerr := filepath.Rel(resolved_path, path)
 		if err != nil {
 			// Happens if ReadDir on d failed, skip it in that case
+			if rerr == nil && errors.Is(err, syscall.ENAMETOOLONG) {
+				if err = self.real_callback(path_based_on(dirpath, rpath), path, d, ExplainPathError(err)); err != nil && err != fs.SkipDir {
+					return err
+				}
+			}
 			return fs.SkipDir
 		}
-		rpath, err := filepath.Rel(resolved_path, path)
-		if err != nil {
-			return err
+		if rerr != nil {
+			return rerr
 		}
-		// we cant use filepath.Join here as it calls Clean() which can alter dirpath if it contains .. or . etc.
-		path_based_on_original_dir := dirpath
-		if !strings.HasSuffix(dirpath, Sep) && dirpath != "" {
-			path_based_on_original_dir += Sep
+		path_based_on_original_dir := path_based_on(dirpath, rpath)
+		needs_recurse := self.needs_recurse_func(path, d)
+		if self.ignore != nil && rpath != "." && self.ignore.is_path_ignored(path_based_on_original_dir, needs_recurse || d.IsDir()) {
+			// SkipDir for a file would skip the rest of the directory
+			return IfElse(d.IsDir(), fs.SkipDir, nil)
 		}
-		path_based_on_original_dir += rpath
-		if self.needs_recurse_func(path, d) {
+		if needs_recurse {
 			err = self.walk(path_based_on_original_dir)
 		} else {
 			err = self.real_callback(path_based_on_original_dir, path, d, err)
@@ -265,6 +363,17 @@ func (self *transformed_walker) walk(dirpath string) error {
 // Walk, recursing into symlinks that point to directories. Ignores directories
 // that could not be read.
 func WalkWithSymlink(dirpath string, callback Walk_callback, transformers ...func(string) string) error {
+	return walk_with_symlink(dirpath, callback, nil, transformers...)
+}
+
+// Same as WalkWithSymlink except that files and directories matching the
+// ignore rules are not passed to callback and ignored directories are not
+// descended into.
+func WalkWithSymlinkIgnoring(dirpath string, rules IgnoreRules, callback Walk_callback, transformers ...func(string) string) error {
+	return walk_with_symlink(dirpath, callback, NewIgnoreMatcher(dirpath, rules), transformers...)
+}
+
+func walk_with_symlink(dirpath string, callback Walk_callback, ignore *IgnoreMatcher, transformers ...func(string) string) error {
 
 	transform := func(path string) string {
 		for _, t := range transformers {
@@ -273,7 +382,8 @@ func WalkWithSymlink(dirpath string, callback Walk_callback, transformers ...fun
 		return transform_symlink(path)
 	}
 	sw := transformed_walker{
-		seen: make(map[string]bool), real_callback: callback, transform_func: transform, needs_recurse_func: needs_symlink_recurse}
+		seen: make(map[string]bool), real_callback: callback, transform_func: transform, needs_recurse_func: needs_symlink_recurse,
+		ignore: ignore}
 	return sw.walk(dirpath)
 }
 
@@ -0,0 +1,13 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !darwin
+
+package utils
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+func macos_user_cache_dir() string { return "" }
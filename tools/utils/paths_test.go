@@ -0,0 +1,82 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestXDGDirs(t *testing.T) {
+	tdir := t.TempDir()
+	t.Setenv("KITTY_TEST_DIRECTORY", "")
+	t.Setenv("XDG_TEST_HOME", filepath.Join(tdir, "xdg"))
+	t.Setenv("HOME", tdir)
+	t.Setenv("USERPROFILE", tdir)
+	t.Setenv("LOCALAPPDATA", filepath.Join(tdir, "local"))
+	q := func(expected string) {
+		t.Helper()
+		if actual := xdg_dir("KITTY_TEST_DIRECTORY", "XDG_TEST_HOME", "~/.local/test", "~/Library/Test/kitty", "kitty-test"); actual != expected {
+			t.Fatalf("Incorrect directory: %#v != %#v", actual, expected)
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		q(filepath.Join(tdir, "Library", "Test", "kitty"))
+	case "windows":
+		q(filepath.Join(tdir, "local", "kitty-test"))
+	default:
+		q(filepath.Join(tdir, "xdg", "kitty"))
+		t.Setenv("XDG_TEST_HOME", "")
+		q(filepath.Join(tdir, ".local", "test", "kitty"))
+	}
+	t.Setenv("KITTY_TEST_DIRECTORY", filepath.Join(tdir, "override"))
+	q(filepath.Join(tdir, "override"))
+}
+
+func TestConfigDirs(t *testing.T) {
+	tdir := t.TempDir()
+	t.Setenv("KITTY_CONFIG_DIRECTORY", "")
+	t.Setenv("HOME", tdir)
+	t.Setenv("USERPROFILE", tdir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tdir, "home"))
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(tdir, "a")+string(os.PathListSeparator)+filepath.Join(tdir, "home"))
+	t.Setenv("APPDATA", filepath.Join(tdir, "roaming"))
+	for _, x := range []string{"a/kitty", ".config/kitty"} {
+		if err := os.MkdirAll(filepath.Join(tdir, x), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tdir, ".config", "kitty", "diff.conf"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expected := []ConfigDirCandidate{
+		{filepath.Join(tdir, "home", "kitty"), false}, {filepath.Join(tdir, "a", "kitty"), true}, {filepath.Join(tdir, ".config", "kitty"), true},
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		expected = append(expected, ConfigDirCandidate{filepath.Join(tdir, "Library", "Preferences", "kitty"), false})
+	case "windows":
+		expected = append(expected, ConfigDirCandidate{filepath.Join(tdir, "roaming", "kitty"), false})
+	}
+	if diff := cmp.Diff(expected, ConfigDirs()); diff != "" {
+		t.Fatalf("Incorrect config dirs:\n%s", diff)
+	}
+	if q := ConfigDirForName("diff.conf"); q != expected[2].Path {
+		t.Fatalf("Incorrect config dir for name: %#v", q)
+	}
+	if q := ConfigDirForName("kitty.conf"); q != expected[0].Path {
+		t.Fatalf("Incorrect default config dir: %#v", q)
+	}
+	t.Setenv("KITTY_CONFIG_DIRECTORY", filepath.Join(tdir, "a", "kitty"))
+	if diff := cmp.Diff(expected[1:2], ConfigDirs()); diff != "" {
+		t.Fatalf("Incorrect config dirs with KITTY_CONFIG_DIRECTORY:\n%s", diff)
+	}
+}
@@ -0,0 +1,191 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+var _ = fmt.Print
+
+// The error for a task that panicked
+type PanicError struct {
+	Value any
+	Stack string
+}
+
+func (self *PanicError) Error() string {
+	return fmt.Sprintf("Panic in pool task: %v\n%s", self.Value, self.Stack)
+}
+
+type PoolOptions[T any] struct {
+	// The maximum number of tasks that run concurrently, defaults to the number of CPUs
+	Workers int
+	// When cancelled, tasks that have not yet started are not run. Running
+	// tasks are passed a context derived from it.
+	Context context.Context
+	// Called with the result of every task, including tasks that were not
+	// run because the pool was cancelled, in which case err is the context's
+	// error. Calls are never concurrent.
+	OnResult func(index int, result T, err error)
+	// Call OnResult in the order the tasks were submitted rather than in the
+	// order they complete. Results are buffered until all earlier results
+	// have been delivered.
+	Ordered bool
+	// Run the most recently submitted task first. Useful when tasks submit
+	// more tasks, such as when walking a tree, to limit the number of
+	// queued tasks.
+	LIFO bool
+}
+
+type pool_task[T any] struct {
+	index int
+	run   func(context.Context) (T, error)
+}
+
+type pool_result[T any] struct {
+	val T
+	err error
+}
+
+// Runs tasks concurrently, with a bounded number of goroutines. Tasks can be
+// submitted from other tasks as submitting never blocks. The first task that
+// fails or panics cancels the pool.
+type Pool[T any] struct {
+	opts   PoolOptions[T]
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mutex         sync.Mutex
+	queue         []pool_task[T]
+	running       int
+	num_submitted int
+	err           error
+
+	delivery_mutex sync.Mutex
+	undelivered    map[int]pool_result[T]
+	next_delivery  int
+}
+
+func NewPool[T any](opts PoolOptions[T]) *Pool[T] {
+	if opts.Workers < 1 {
+		opts.Workers = runtime.NumCPU()
+	}
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ans := &Pool[T]{opts: opts}
+	ans.ctx, ans.cancel = context.WithCancel(parent)
+	if opts.Ordered {
+		ans.undelivered = make(map[int]pool_result[T])
+	}
+	return ans
+}
+
+// Queue task to be run, returning its index, which is the number of tasks
+// submitted before it. Must not be called after Wait() returns.
+func (self *Pool[T]) Submit(task func(ctx context.Context) (T, error)) int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	idx := self.num_submitted
+	self.num_submitted++
+	self.queue = append(self.queue, pool_task[T]{idx, task})
+	self.wg.Add(1)
+	if self.running < self.opts.Workers {
+		self.running++
+		go self.worker()
+	}
+	return idx
+}
+
+// Stop running tasks that have not yet started
+func (self *Pool[T]) Cancel() { self.cancel() }
+
+func (self *Pool[T]) next_task() (t pool_task[T], found bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if len(self.queue) == 0 {
+		self.running--
+		return
+	}
+	if self.opts.LIFO {
+		t = self.queue[len(self.queue)-1]
+		self.queue = self.queue[:len(self.queue)-1]
+	} else {
+		t = self.queue[0]
+		self.queue[0] = pool_task[T]{}
+		self.queue = self.queue[1:]
+	}
+	return t, true
+}
+
+func (self *Pool[T]) worker() {
+	for {
+		t, found := self.next_task()
+		if !found {
+			return
+		}
+		self.run(t)
+		self.wg.Done()
+	}
+}
+
+func (self *Pool[T]) run(t pool_task[T]) {
+	var r pool_result[T]
+	if r.err = self.ctx.Err(); r.err == nil {
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					r.err = &PanicError{Value: p, Stack: string(debug.Stack())}
+				}
+			}()
+			r.val, r.err = t.run(self.ctx)
+		}()
+	}
+	if r.err != nil {
+		self.mutex.Lock()
+		if self.err == nil {
+			self.err = r.err
+		}
+		self.mutex.Unlock()
+		self.cancel()
+	}
+	if self.opts.OnResult != nil {
+		self.deliver(t.index, r)
+	}
+}
+
+func (self *Pool[T]) deliver(index int, r pool_result[T]) {
+	self.delivery_mutex.Lock()
+	defer self.delivery_mutex.Unlock()
+	if !self.opts.Ordered {
+		self.opts.OnResult(index, r.val, r.err)
+		return
+	}
+	self.undelivered[index] = r
+	for {
+		r, found := self.undelivered[self.next_delivery]
+		if !found {
+			break
+		}
+		delete(self.undelivered, self.next_delivery)
+		self.opts.OnResult(self.next_delivery, r.val, r.err)
+		self.next_delivery++
+	}
+}
+
+// Wait for all submitted tasks to complete, including tasks submitted by
+// tasks while waiting, returning the error of the first task that failed
+func (self *Pool[T]) Wait() error {
+	self.wg.Wait()
+	self.cancel()
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.err
+}
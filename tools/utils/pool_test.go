@@ -0,0 +1,105 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestPool(t *testing.T) {
+	// ordered results with bounded concurrency
+	var running, max_running atomic.Int32
+	var order []int
+	var failures []string
+	p := NewPool(PoolOptions[int]{Workers: 3, Ordered: true, OnResult: func(index, result int, err error) {
+		// OnResult is called on worker goroutines where t.Fatal cannot be used
+		if err != nil || result != index*index {
+			failures = append(failures, fmt.Sprintf("Unexpected result for task %d: %d %v", index, result, err))
+		}
+		order = append(order, index)
+	}})
+	for i := 0; i < 20; i++ {
+		p.Submit(func(context.Context) (int, error) {
+			n := running.Add(1)
+			for {
+				m := max_running.Load()
+				if n <= m || max_running.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			// later tasks finish first
+			time.Sleep(time.Duration(20-i) * 100 * time.Microsecond)
+			running.Add(-1)
+			return i * i, nil
+		})
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) > 0 {
+		t.Fatal(strings.Join(failures, "\n"))
+	}
+	if m := max_running.Load(); m > 3 || m < 1 {
+		t.Fatalf("Number of concurrently running tasks was: %d", m)
+	}
+	if diff := cmp.Diff([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, order); diff != "" {
+		t.Fatalf("Results not delivered in order:\n%s", diff)
+	}
+
+	// tasks submitting tasks and the first error cancelling the rest
+	var ran atomic.Int32
+	sentinel := errors.New("failed")
+	q := NewPool(PoolOptions[int]{Workers: 1, LIFO: true})
+	var spawn func(depth int) func(context.Context) (int, error)
+	spawn = func(depth int) func(context.Context) (int, error) {
+		return func(context.Context) (int, error) {
+			ran.Add(1)
+			if depth == 3 {
+				return 0, sentinel
+			}
+			q.Submit(spawn(depth + 1))
+			q.Submit(spawn(depth + 1))
+			return 0, nil
+		}
+	}
+	q.Submit(spawn(0))
+	if err := q.Wait(); err != sentinel {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// depth first with a single worker, so 0, 1, 2, 3 run and then the rest are cancelled
+	if n := ran.Load(); n != 4 {
+		t.Fatalf("%d tasks ran instead of 4", n)
+	}
+
+	// panics
+	var errs []error
+	r := NewPool(PoolOptions[int]{OnResult: func(_, _ int, err error) { errs = append(errs, err) }})
+	r.Submit(func(context.Context) (int, error) { panic("boom") })
+	var pe *PanicError
+	if err := r.Wait(); !errors.As(err, &pe) || pe.Value != "boom" || len(errs) != 1 || errs[0] != err {
+		t.Fatalf("Panic not reported correctly: %v", err)
+	}
+
+	// cancellation by the parent context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewPool(PoolOptions[int]{Context: ctx})
+	var ran_after_cancel atomic.Bool
+	s.Submit(func(context.Context) (int, error) { ran_after_cancel.Store(true); return 0, nil })
+	if err := s.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error after cancellation: %v", err)
+	}
+	if ran_after_cancel.Load() {
+		t.Fatalf("Task ran after cancellation")
+	}
+}
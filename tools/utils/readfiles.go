@@ -0,0 +1,51 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+var _ = fmt.Print
+
+// Read a file whose size is expected to be size_hint, using a single read
+// for files whose size has not changed
+func read_file_with_size_hint(path string, size_hint int) (ans []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ans = make([]byte, size_hint+1)
+	n, err := io.ReadFull(f, ans)
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return ans[:n], nil
+	case nil:
+		// the file has grown
+		rest, err := io.ReadAll(f)
+		return append(ans, rest...), err
+	}
+	return nil, err
+}
+
+func read_files_serially(paths []string, size_hints []int, data [][]byte, errs []error) {
+	for i, path := range paths {
+		data[i], errs[i] = read_file_with_size_hint(path, size_hints[i])
+	}
+}
+
+// Read the contents of many small files, such as those in a source code tree.
+// size_hints are the expected sizes of the files, from a previous stat().
+// On Linux, io_uring is used, when available, to batch the system calls
+// needed to open, read and close the files.
+func ReadSmallFiles(paths []string, size_hints []int) (data [][]byte, errs []error) {
+	data, errs = make([][]byte, len(paths)), make([]error, len(paths))
+	if len(paths) > 1 && read_files_with_io_uring(paths, size_hints, data, errs) {
+		return
+	}
+	read_files_serially(paths, size_hints, data, errs)
+	return
+}
@@ -0,0 +1,282 @@
+//go:build linux
+
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// A minimal io_uring implementation, supporting only what is needed to
+// batch the open, read and close system calls for many files. The structures
+// below mirror the ones in <linux/io_uring.h>.
+
+const (
+	ioring_off_sq_ring        = 0
+	ioring_off_cq_ring        = 0x8000000
+	ioring_off_sqes           = 0x10000000
+	ioring_feat_single_mmap   = 1
+	ioring_enter_getevents    = 1
+	ioring_op_openat          = 18
+	ioring_op_close           = 19
+	ioring_op_read            = 22
+	io_uring_entries          = 256
+	io_uring_sqe_size         = 64
+	io_uring_cqe_size         = 16
+	io_uring_max_file_size    = 16 * 1024 * 1024
+	io_uring_max_batch_memory = 64 * 1024 * 1024
+)
+
+type io_sqring_offsets struct {
+	head, tail, ring_mask, ring_entries, flags, dropped, array, resv1 uint32
+	user_addr                                                         uint64
+}
+
+type io_cqring_offsets struct {
+	head, tail, ring_mask, ring_entries, overflow, cqes, flags, resv1 uint32
+	user_addr                                                         uint64
+}
+
+type io_uring_params struct {
+	sq_entries, cq_entries, flags, sq_thread_cpu, sq_thread_idle, features, wq_fd uint32
+	resv                                                                          [3]uint32
+	sq_off                                                                        io_sqring_offsets
+	cq_off                                                                        io_cqring_offsets
+}
+
+type io_uring_sqe struct {
+	opcode, flags          uint8
+	ioprio                 uint16
+	fd                     int32
+	off, addr              uint64
+	len, op_flags          uint32
+	user_data              uint64
+	buf_index, personality uint16
+	splice_fd_in           int32
+	addr3, pad             uint64
+}
+
+type io_uring_cqe struct {
+	user_data uint64
+	res       int32
+	flags     uint32
+}
+
+type io_uring struct {
+	fd                        int
+	sq_ring, cq_ring, sqe_mem []byte
+	sq_head, sq_tail, cq_head *uint32
+	cq_tail                   *uint32
+	sq_mask, cq_mask          uint32
+	sq_entries                uint32
+	sq_array                  []uint32
+	sqes                      []io_uring_sqe
+	cqes                      []io_uring_cqe
+	local_tail                uint32
+}
+
+var io_uring_unavailable atomic.Bool
+
+func new_io_uring(entries uint32) (ans *io_uring, err error) {
+	var p io_uring_params
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	ans = &io_uring{fd: int(fd), sq_entries: p.sq_entries}
+	defer func() {
+		if err != nil {
+			ans.close()
+			ans = nil
+		}
+	}()
+	sq_sz := int(p.sq_off.array + p.sq_entries*4)
+	cq_sz := int(p.cq_off.cqes + p.cq_entries*io_uring_cqe_size)
+	prot, flags := unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE
+	if p.features&ioring_feat_single_mmap != 0 {
+		if ans.sq_ring, err = unix.Mmap(ans.fd, ioring_off_sq_ring, max(sq_sz, cq_sz), prot, flags); err != nil {
+			return
+		}
+		ans.cq_ring = ans.sq_ring
+	} else {
+		if ans.sq_ring, err = unix.Mmap(ans.fd, ioring_off_sq_ring, sq_sz, prot, flags); err != nil {
+			return
+		}
+		if ans.cq_ring, err = unix.Mmap(ans.fd, ioring_off_cq_ring, cq_sz, prot, flags); err != nil {
+			return
+		}
+	}
+	if ans.sqe_mem, err = unix.Mmap(ans.fd, ioring_off_sqes, int(p.sq_entries*io_uring_sqe_size), prot, flags); err != nil {
+		return
+	}
+	u32 := func(ring []byte, offset uint32) *uint32 { return (*uint32)(unsafe.Pointer(&ring[offset])) }
+	ans.sq_head, ans.sq_tail = u32(ans.sq_ring, p.sq_off.head), u32(ans.sq_ring, p.sq_off.tail)
+	ans.sq_mask = *u32(ans.sq_ring, p.sq_off.ring_mask)
+	ans.sq_array = unsafe.Slice(u32(ans.sq_ring, p.sq_off.array), p.sq_entries)
+	ans.cq_head, ans.cq_tail = u32(ans.cq_ring, p.cq_off.head), u32(ans.cq_ring, p.cq_off.tail)
+	ans.cq_mask = *u32(ans.cq_ring, p.cq_off.ring_mask)
+	ans.cqes = unsafe.Slice((*io_uring_cqe)(unsafe.Pointer(&ans.cq_ring[p.cq_off.cqes])), p.cq_entries)
+	ans.sqes = unsafe.Slice((*io_uring_sqe)(unsafe.Pointer(&ans.sqe_mem[0])), p.sq_entries)
+	ans.local_tail = atomic.LoadUint32(ans.sq_tail)
+	return
+}
+
+func (self *io_uring) close() {
+	if self.sqe_mem != nil {
+		_ = unix.Munmap(self.sqe_mem)
+	}
+	if self.cq_ring != nil && &self.cq_ring[0] != &self.sq_ring[0] {
+		_ = unix.Munmap(self.cq_ring)
+	}
+	if self.sq_ring != nil {
+		_ = unix.Munmap(self.sq_ring)
+	}
+	unix.Close(self.fd)
+}
+
+// Queue a submission, the number of queued submissions must not exceed the
+// number of entries in the ring between calls to submit_and_wait()
+func (self *io_uring) queue(sqe io_uring_sqe) {
+	idx := self.local_tail & self.sq_mask
+	self.sqes[idx] = sqe
+	self.sq_array[idx] = idx
+	self.local_tail++
+}
+
+// Submit all queued entries and wait for them to complete, calling callback
+// for every completion
+func (self *io_uring) submit_and_wait(callback func(user_data uint64, res int32)) error {
+	atomic.StoreUint32(self.sq_tail, self.local_tail)
+	pending := self.local_tail - atomic.LoadUint32(self.sq_head)
+	for waiting := pending; waiting > 0; {
+		to_submit := self.local_tail - atomic.LoadUint32(self.sq_head)
+		_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(self.fd), uintptr(to_submit), uintptr(waiting), ioring_enter_getevents, 0, 0)
+		if errno != 0 && errno != unix.EINTR && errno != unix.EAGAIN && errno != unix.EBUSY {
+			return errno
+		}
+		head, tail := *self.cq_head, atomic.LoadUint32(self.cq_tail)
+		for ; head != tail; head++ {
+			cqe := &self.cqes[head&self.cq_mask]
+			callback(cqe.user_data, cqe.res)
+			waiting--
+		}
+		atomic.StoreUint32(self.cq_head, head)
+	}
+	return nil
+}
+
+func (self *io_uring) read_batch(paths []string, size_hints []int, data [][]byte, errs []error) (err error) {
+	c_paths := make([]*byte, len(paths))
+	fds := make([]int32, len(paths))
+	needs_fallback := make([]bool, len(paths))
+	for i, path := range paths {
+		var perr error
+		if c_paths[i], perr = unix.BytePtrFromString(path); perr != nil {
+			fds[i], needs_fallback[i] = -1, true
+			continue
+		}
+		self.queue(io_uring_sqe{opcode: ioring_op_openat, fd: unix.AT_FDCWD, addr: uint64(uintptr(unsafe.Pointer(c_paths[i]))),
+			op_flags: unix.O_RDONLY | unix.O_CLOEXEC, user_data: uint64(i)})
+	}
+	err = self.submit_and_wait(func(i uint64, res int32) {
+		switch {
+		case res >= 0:
+			fds[i] = res
+		case unix.Errno(-res) == unix.EINVAL || unix.Errno(-res) == unix.EOPNOTSUPP:
+			// opcode not supported by this kernel
+			fds[i], needs_fallback[i] = -1, true
+		default:
+			fds[i] = -1
+			errs[i] = &os.PathError{Op: "open", Path: paths[i], Err: unix.Errno(-res)}
+		}
+	})
+	runtime.KeepAlive(c_paths)
+	if err != nil {
+		return
+	}
+	for i, fd := range fds {
+		if fd > -1 {
+			// read one more byte than expected to detect files that have grown
+			data[i] = make([]byte, size_hints[i]+1)
+			self.queue(io_uring_sqe{opcode: ioring_op_read, fd: fd, addr: uint64(uintptr(unsafe.Pointer(&data[i][0]))),
+				len: uint32(len(data[i])), user_data: uint64(i)})
+		}
+	}
+	err = self.submit_and_wait(func(i uint64, res int32) {
+		if res >= 0 && int(res) == size_hints[i] {
+			data[i] = data[i][:res]
+		} else {
+			// either an error, a short read or the file size has changed
+			data[i], needs_fallback[i] = nil, true
+		}
+	})
+	runtime.KeepAlive(data)
+	if err != nil {
+		for _, fd := range fds {
+			if fd > -1 {
+				unix.Close(int(fd))
+			}
+		}
+		return
+	}
+	for i, fd := range fds {
+		if fd > -1 {
+			self.queue(io_uring_sqe{opcode: ioring_op_close, fd: fd, user_data: uint64(i)})
+		}
+	}
+	if err = self.submit_and_wait(func(uint64, int32) {}); err != nil {
+		return
+	}
+	for i, fb := range needs_fallback {
+		if fb {
+			data[i], errs[i] = read_file_with_size_hint(paths[i], size_hints[i])
+		}
+	}
+	return
+}
+
+func read_files_with_io_uring(paths []string, size_hints []int, data [][]byte, errs []error) bool {
+	if io_uring_unavailable.Load() {
+		return false
+	}
+	ring, err := new_io_uring(io_uring_entries)
+	if err != nil {
+		// usually ENOSYS on old kernels or EPERM when disabled via sysctl or seccomp
+		io_uring_unavailable.Store(true)
+		return false
+	}
+	defer ring.close()
+	for start := 0; start < len(paths); {
+		limit, mem := min(len(paths), start+int(ring.sq_entries)), 0
+		end := start
+		for ; end < limit; end++ {
+			if size_hints[end] > io_uring_max_file_size {
+				break
+			}
+			if mem += size_hints[end]; mem > io_uring_max_batch_memory && end > start {
+				break
+			}
+		}
+		if end == start {
+			data[start], errs[start] = read_file_with_size_hint(paths[start], size_hints[start])
+			start++
+			continue
+		}
+		if err = ring.read_batch(paths[start:end], size_hints[start:end], data[start:end], errs[start:end]); err != nil {
+			// the ring is in an unknown state, fallback to reading serially
+			read_files_serially(paths[start:], size_hints[start:], data[start:], errs[start:])
+			return true
+		}
+		start = end
+	}
+	return true
+}
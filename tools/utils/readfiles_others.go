@@ -0,0 +1,15 @@
+//go:build !linux
+
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+func read_files_with_io_uring(paths []string, size_hints []int, data [][]byte, errs []error) bool {
+	return false
+}
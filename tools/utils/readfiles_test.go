@@ -0,0 +1,86 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestReadSmallFiles(t *testing.T) {
+	tdir := t.TempDir()
+	var paths []string
+	var hints []int
+	add := func(name, data string, hint int) {
+		p := filepath.Join(tdir, name)
+		if data != "\x00missing" {
+			if err := os.WriteFile(p, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		paths = append(paths, p)
+		hints = append(hints, hint)
+	}
+	for i := 0; i < 600; i++ {
+		d := strings.Repeat(fmt.Sprint(i), i)
+		add(fmt.Sprint(i), d, len(d))
+	}
+	add("empty", "", 0)
+	add("grown", "abcdefgh", 4)
+	add("shrunk", "abcd", 8)
+	add("missing", "\x00missing", 10)
+	add("has\x00nul", "\x00missing", 10)
+
+	check := func(data [][]byte, errs []error) {
+		for i, path := range paths {
+			expected, eerr := os.ReadFile(path)
+			if eerr != nil {
+				if errs[i] == nil {
+					t.Fatalf("Reading %s did not fail", path)
+				}
+				continue
+			}
+			if errs[i] != nil {
+				t.Fatalf("Reading %s failed with error: %s", path, errs[i])
+			}
+			if diff := cmp.Diff(string(expected), string(data[i])); diff != "" {
+				t.Fatalf("Incorrect data read from %s:\n%s", path, diff)
+			}
+		}
+	}
+	data, errs := make([][]byte, len(paths)), make([]error, len(paths))
+	read_files_serially(paths, hints, data, errs)
+	check(data, errs)
+	check(ReadSmallFiles(paths, hints))
+}
+
+func BenchmarkReadSmallFiles(b *testing.B) {
+	tdir := b.TempDir()
+	paths, hints := make([]string, 2048), make([]int, 2048)
+	for i := range paths {
+		paths[i] = filepath.Join(tdir, fmt.Sprint(i))
+		d := strings.Repeat("x", i)
+		hints[i] = len(d)
+		if err := os.WriteFile(paths[i], []byte(d), 0o600); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.Run("serial", func(b *testing.B) {
+		data, errs := make([][]byte, len(paths)), make([]error, len(paths))
+		for i := 0; i < b.N; i++ {
+			read_files_serially(paths, hints, data, errs)
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ReadSmallFiles(paths, hints)
+		}
+	})
+}
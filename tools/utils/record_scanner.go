@@ -0,0 +1,243 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var _ = fmt.Print
+
+// Delimiters for use with RecordScanner
+var (
+	NulDelimiters = []string{"\x00"}
+	// Both Unix and Windows line endings
+	NewlineDelimiters = []string{"\r\n", "\n"}
+	// The terminators of OSC escape codes, the records contain the OSC
+	// introducer and anything before it
+	OSCDelimiters = []string{"\x1b\\", "\a"}
+)
+
+type RecordTooLongError struct {
+	MaxRecordSize int
+}
+
+func (self *RecordTooLongError) Error() string {
+	return fmt.Sprintf("A record is larger than the maximum allowed size of %d bytes", self.MaxRecordSize)
+}
+
+type RecordScannerOptions struct {
+	// The strings that terminate records, when more than one matches at the
+	// same position, the longest is used. Defaults to NewlineDelimiters.
+	Delimiters []string
+	// The maximum size of a record, excluding its delimiter, records larger than
+	// this cause scanning to stop with a *RecordTooLongError. Zero or less
+	// means no limit.
+	MaxRecordSize int
+	// Discard records larger than MaxRecordSize without buffering them and
+	// continue scanning, instead of failing
+	SkipTooLong bool
+	// Return records larger than MaxRecordSize in pieces of MaxRecordSize
+	// bytes, instead of failing, all but the last piece have an empty
+	// Delimiter()
+	SplitTooLong bool
+}
+
+// Like bufio.Scanner, reads delimited records from a reader, buffering only
+// the current record, but with support for multiple and multi-byte delimiters,
+// no limit on record size by default and the ability to skip over records
+// that are too large instead of aborting.
+type RecordScanner struct {
+	r                               io.Reader
+	opts                            RecordScannerOptions
+	max_delimiter_size              int
+	buf                             []byte
+	start, searched_till, read_till int
+	record                          []byte
+	delimiter                       string
+	skipping                        bool
+	num_skipped                     int
+	eof                             bool
+	err                             error
+}
+
+func NewRecordScanner(r io.Reader, opts ...RecordScannerOptions) *RecordScanner {
+	ans := RecordScanner{r: r}
+	if len(opts) > 0 {
+		ans.opts = opts[0]
+	}
+	if len(ans.opts.Delimiters) == 0 {
+		ans.opts.Delimiters = NewlineDelimiters
+	}
+	for _, d := range ans.opts.Delimiters {
+		if d == "" {
+			panic("The delimiters for a RecordScanner must not be empty")
+		}
+		ans.max_delimiter_size = max(ans.max_delimiter_size, len(d))
+	}
+	return &ans
+}
+
+// Return the position of the earliest delimiter in data, or -1, and the delimiter
+func (self *RecordScanner) find_delimiter(data []byte) (pos int, delimiter string) {
+	pos = -1
+	for _, d := range self.opts.Delimiters {
+		q := data
+		if pos > -1 {
+			// only delimiters at or before the current match can win
+			q = data[:min(len(data), pos+len(d))]
+		}
+		if idx := bytes.Index(q, UnsafeStringToBytes(d)); idx > -1 && (pos < 0 || idx < pos || (idx == pos && len(d) > len(delimiter))) {
+			pos, delimiter = idx, d
+		}
+	}
+	return
+}
+
+func (self *RecordScanner) too_long(size int) bool {
+	return self.opts.MaxRecordSize > 0 && size > self.opts.MaxRecordSize
+}
+
+// Read more data into the buffer, growing it or moving the current record to
+// its start as needed
+func (self *RecordScanner) fill() {
+	if self.start > 0 {
+		n := copy(self.buf, self.buf[self.start:self.read_till])
+		self.searched_till -= self.start
+		self.read_till, self.start = n, 0
+	}
+	if self.read_till == len(self.buf) {
+		sz := max(DEFAULT_IO_BUFFER_SIZE, 2*len(self.buf))
+		if self.opts.MaxRecordSize > 0 {
+			// room for a complete record and its delimiter plus one byte to
+			// detect records that are too long
+			sz = min(sz, max(DEFAULT_IO_BUFFER_SIZE, self.opts.MaxRecordSize+self.max_delimiter_size+1))
+		}
+		if sz > len(self.buf) {
+			nbuf := make([]byte, sz)
+			copy(nbuf, self.buf[:self.read_till])
+			self.buf = nbuf
+		}
+	}
+	for i := 0; i < 100; i++ {
+		n, err := self.r.Read(self.buf[self.read_till:])
+		self.read_till += n
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				self.eof = true
+			} else {
+				self.err = err
+			}
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+	self.err = io.ErrNoProgress
+}
+
+// Advance to the next record, returning false at the end of input or on
+// error. The final record need not be terminated by a delimiter.
+func (self *RecordScanner) Scan() bool {
+	self.record, self.delimiter = nil, ""
+	for self.err == nil {
+		if pos, d := self.find_delimiter(self.buf[self.searched_till:self.read_till]); pos > -1 {
+			end := self.searched_till + pos
+			if self.split_if_too_long(end) {
+				return true
+			}
+			record := self.buf[self.start:end]
+			self.start = end + len(d)
+			self.searched_till = self.start
+			if self.skipping {
+				self.skipping = false
+				continue
+			}
+			if self.too_long(len(record)) {
+				if !self.check_skip() {
+					return false
+				}
+				continue
+			}
+			self.record, self.delimiter = record, d
+			return true
+		}
+		// a delimiter could straddle the end of the data read so far
+		self.searched_till = max(self.start, self.read_till-self.max_delimiter_size+1)
+		if self.split_if_too_long(self.searched_till) {
+			return true
+		}
+		if !self.skipping && self.too_long(self.searched_till-self.start) {
+			if !self.check_skip() {
+				return false
+			}
+			self.skipping = true
+		}
+		if self.skipping {
+			// drop the data that cannot be part of a delimiter
+			self.start = self.searched_till
+		}
+		if self.eof {
+			if self.skipping || self.start >= self.read_till {
+				break
+			}
+			if self.split_if_too_long(self.read_till) {
+				return true
+			}
+			self.record = self.buf[self.start:self.read_till]
+			self.start, self.searched_till = self.read_till, self.read_till
+			if self.too_long(len(self.record)) {
+				self.record = nil
+				if !self.check_skip() {
+					return false
+				}
+				break
+			}
+			return true
+		}
+		self.fill()
+	}
+	return false
+}
+
+// Make the first piece of a record ending at end the current record if the
+// record is too long and SplitTooLong is set
+func (self *RecordScanner) split_if_too_long(end int) bool {
+	if !self.opts.SplitTooLong || self.skipping || !self.too_long(end-self.start) {
+		return false
+	}
+	self.record = self.buf[self.start : self.start+self.opts.MaxRecordSize]
+	self.start += self.opts.MaxRecordSize
+	self.searched_till = max(self.searched_till, self.start)
+	return true
+}
+
+// Count a record that is too long as skipped, returning false if skipping is
+// not allowed
+func (self *RecordScanner) check_skip() bool {
+	if !self.opts.SkipTooLong {
+		self.err = &RecordTooLongError{MaxRecordSize: self.opts.MaxRecordSize}
+		return false
+	}
+	self.num_skipped++
+	return true
+}
+
+// The current record, valid only until the next call to Scan()
+func (self *RecordScanner) Bytes() []byte { return self.record }
+
+func (self *RecordScanner) Text() string { return string(self.record) }
+
+// The delimiter that terminated the current record, empty for a final record
+// not terminated by a delimiter
+func (self *RecordScanner) Delimiter() string { return self.delimiter }
+
+// The number of records that were skipped because they were too long
+func (self *RecordScanner) NumSkipped() int { return self.num_skipped }
+
+// The first error encountered, nil at the end of input
+func (self *RecordScanner) Err() error { return self.err }
@@ -0,0 +1,98 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestRecordScanner(t *testing.T) {
+	scan := func(r io.Reader, opts RecordScannerOptions) (records, delimiters []string, s *RecordScanner) {
+		s = NewRecordScanner(r, opts)
+		for s.Scan() {
+			records = append(records, s.Text())
+			delimiters = append(delimiters, s.Delimiter())
+		}
+		return
+	}
+	check := func(input string, opts RecordScannerOptions, expected ...string) {
+		t.Helper()
+		for _, r := range []io.Reader{strings.NewReader(input), iotest.OneByteReader(strings.NewReader(input))} {
+			records, _, s := scan(r, opts)
+			if s.Err() != nil {
+				t.Fatalf("Scanning %#v failed with error: %s", input, s.Err())
+			}
+			if diff := cmp.Diff(expected, records); diff != "" {
+				t.Fatalf("Unexpected records for %#v:\n%s", input, diff)
+			}
+		}
+	}
+	nul := RecordScannerOptions{Delimiters: NulDelimiters}
+	check("a\nb\r\n\nc", RecordScannerOptions{}, "a", "b", "", "c")
+	check("a\nb\n", RecordScannerOptions{}, "a", "b")
+	check("", RecordScannerOptions{})
+	check("a b\x00c\nd\x00", nul, "a b", "c\nd")
+	check("xyzabab12ab", RecordScannerOptions{Delimiters: []string{"ab"}}, "xyz", "", "12")
+
+	_, delimiters, _ := scan(strings.NewReader("\x1b]52;c;YQ==\x1b\\\x1b]52;p;Yg==\a\x1b]1;x"), RecordScannerOptions{Delimiters: OSCDelimiters})
+	if diff := cmp.Diff([]string{"\x1b\\", "\a", ""}, delimiters); diff != "" {
+		t.Fatalf("Unexpected delimiters:\n%s", diff)
+	}
+
+	// records that are too long
+	long := strings.Repeat("x", 3*DEFAULT_IO_BUFFER_SIZE)
+	limited := RecordScannerOptions{MaxRecordSize: 5}
+	check("12345\nabc", limited, "12345", "abc")
+	for _, input := range []string{"123456\n", "abc\n" + long + "\n", "abc\n" + long} {
+		records, _, s := scan(strings.NewReader(input), limited)
+		var rerr *RecordTooLongError
+		if !errors.As(s.Err(), &rerr) || rerr.MaxRecordSize != 5 {
+			t.Fatalf("Scanning a record that is too long did not fail, got error: %v", s.Err())
+		}
+		if len(records) > 1 {
+			t.Fatalf("Unexpected records before the error: %#v", records)
+		}
+	}
+	skip := RecordScannerOptions{MaxRecordSize: 5, SkipTooLong: true, Delimiters: []string{"\r\n"}}
+	for input, num_skipped := range map[string]int{"abc\r\n" + long + "\r\n123456\r\nd\r\n" + long: 3, "123456\r\nabc\r\nd" + "\r\n" + long + "\r": 2} {
+		records, _, s := scan(iotest.HalfReader(strings.NewReader(input)), skip)
+		if diff := cmp.Diff([]string{"abc", "d"}, records); diff != "" || s.Err() != nil {
+			t.Fatalf("Unexpected records when skipping records that are too long: %v\n%s", s.Err(), diff)
+		}
+		if s.NumSkipped() != num_skipped {
+			t.Fatalf("Unexpected number of skipped records: %d != %d", s.NumSkipped(), num_skipped)
+		}
+		if len(s.buf) > DEFAULT_IO_BUFFER_SIZE {
+			t.Fatalf("Skipped records were buffered, the buffer grew to: %d", len(s.buf))
+		}
+	}
+
+	split := RecordScannerOptions{MaxRecordSize: 3, SplitTooLong: true}
+	check("abcdefgh\r\n1234\nxyz\n\n1234567", split, "abc", "def", "gh", "123", "4", "xyz", "", "123", "456", "7")
+	_, delimiters, _ = scan(strings.NewReader("abcdefg\r\nab"), split)
+	if diff := cmp.Diff([]string{"", "", "\r\n", ""}, delimiters); diff != "" {
+		t.Fatalf("Unexpected delimiters for split records:\n%s", diff)
+	}
+
+	// unlimited record size
+	records, _, s := scan(strings.NewReader(long+"\n"+long), RecordScannerOptions{})
+	if s.Err() != nil || len(records) != 2 || records[0] != long || records[1] != long {
+		t.Fatalf("Failed to scan large records: %v", s.Err())
+	}
+
+	// read errors are reported
+	failed := errors.New("failed")
+	s = NewRecordScanner(iotest.ErrReader(failed))
+	if s.Scan() || s.Err() != failed {
+		t.Fatalf("Read error not reported, got: %v", s.Err())
+	}
+}
@@ -10,7 +10,7 @@ import (
 
 var _ = fmt.Print
 
-var pat_cache = NewLRUCache[string, *regexp.Regexp](128)
+var pat_cache = NewLRU[string, *regexp.Regexp](128)
 
 type SubMatch struct {
 	Text       string
@@ -0,0 +1,123 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !windows
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// Retry fn a few times if it fails with EBUSY, which can happen transiently,
+// for example, while an anti-virus or indexer has a file open
+func retry_on_busy(fn func() error) (err error) {
+	delay := 10 * time.Millisecond
+	for i := 0; i < 5; i++ {
+		if err = fn(); !errors.Is(err, unix.EBUSY) {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return
+}
+
+type secure_remover struct {
+	dev uint64
+}
+
+func (self *secure_remover) remove_at(dirfd int, name, path string) error {
+	err := retry_on_busy(func() error { return unix.Unlinkat(dirfd, name, 0) })
+	if err == nil || errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+	var st unix.Stat_t
+	if serr := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW); serr != nil {
+		if errors.Is(serr, unix.ENOENT) {
+			return nil
+		}
+		return &os.PathError{Op: "lstat", Path: path, Err: serr}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return &os.PathError{Op: "unlink", Path: path, Err: err}
+	}
+	if uint64(st.Dev) != self.dev {
+		return &os.PathError{Op: "remove", Path: path, Err: fmt.Errorf("refusing to remove the contents of a different file system mounted inside the tree")}
+	}
+	// O_NOFOLLOW ensures the directory was not replaced by a symlink after the check above
+	fd, err := unix.Openat(dirfd, name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	dir := os.NewFile(uintptr(fd), path)
+	defer dir.Close()
+	if perm := uint32(st.Mode) & 0o7777; perm&0o700 != 0o700 {
+		// allow removing the contents of read-only directories
+		if err = unix.Fchmod(fd, perm|0o700); err != nil {
+			return &os.PathError{Op: "chmod", Path: path, Err: err}
+		}
+	}
+	for {
+		names, err := dir.Readdirnames(1024)
+		for _, child := range names {
+			if err := self.remove_at(fd, child, filepath.Join(path, child)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || (err == nil && len(names) == 0) {
+			break
+		}
+		if err != nil {
+			return &os.PathError{Op: "readdir", Path: path, Err: err}
+		}
+		// entries were removed, so read again from the start
+		if _, err = dir.Seek(0, io.SeekStart); err != nil {
+			return &os.PathError{Op: "seek", Path: path, Err: err}
+		}
+	}
+	if err = retry_on_busy(func() error { return unix.Unlinkat(dirfd, name, unix.AT_REMOVEDIR) }); err != nil && !errors.Is(err, unix.ENOENT) {
+		return &os.PathError{Op: "rmdir", Path: path, Err: err}
+	}
+	return nil
+}
+
+// Remove path and, if it is a directory, everything inside it. Unlike
+// os.RemoveAll() the contents of read-only directories are removed, removals
+// that fail with EBUSY are retried and other file systems mounted inside the
+// tree are not descended into. Symlinks are removed, never followed, and all
+// operations are relative to open directory handles so the removal cannot be
+// redirected outside the tree by replacing a directory with a symlink while
+// it is in progress. A path that does not exist is not an error.
+func RemoveAllSecure(path string) error {
+	path = filepath.Clean(path)
+	parent, name := filepath.Split(path)
+	if name == "" || name == "." || name == ".." {
+		return &os.PathError{Op: "remove", Path: path, Err: unix.EINVAL}
+	}
+	if parent == "" {
+		parent = "."
+	}
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			return nil
+		}
+		return &os.PathError{Op: "lstat", Path: path, Err: err}
+	}
+	pfd, err := unix.Open(parent, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: parent, Err: err}
+	}
+	defer unix.Close(pfd)
+	r := secure_remover{dev: uint64(st.Dev)}
+	return r.remove_at(pfd, name, path)
+}
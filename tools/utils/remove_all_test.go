@@ -0,0 +1,66 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestRemoveAllSecure(t *testing.T) {
+	tdir := t.TempDir()
+	j := func(x ...string) string { return filepath.Join(append([]string{tdir}, x...)...) }
+	for _, d := range []string{"outside", "tree/a/b", "tree/ro/sub"} {
+		if err := os.MkdirAll(j(d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"outside/precious", "tree/a/b/f", "tree/ro/sub/f", "tree/ro/f"} {
+		if err := os.WriteFile(j(f), []byte("x"), 0o400); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 1100; i++ {
+		if err := os.WriteFile(j("tree", "a", fmt.Sprint(i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(j("outside"), j("tree", "a", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(j("outside"), j("toplink")); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"tree/ro/sub", "tree/ro"} {
+		if err := os.Chmod(j(d), 0o500); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { os.Chmod(j("tree/ro"), 0o700); os.Chmod(j("tree/ro/sub"), 0o700) })
+
+	if err := RemoveAllSecure(j("tree")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(j("tree")); !os.IsNotExist(err) {
+		t.Fatalf("Tree not removed: %v", err)
+	}
+	if err := RemoveAllSecure(j("toplink")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(j("toplink")); !os.IsNotExist(err) {
+		t.Fatalf("Symlink not removed: %v", err)
+	}
+	if _, err := os.Stat(j("outside", "precious")); err != nil {
+		t.Fatalf("File outside the tree was removed: %v", err)
+	}
+	if err := RemoveAllSecure(j("does-not-exist")); err != nil {
+		t.Fatalf("Removing a path that does not exist failed: %v", err)
+	}
+	if err := RemoveAllSecure("."); err == nil {
+		t.Fatalf("Removing . did not fail")
+	}
+}
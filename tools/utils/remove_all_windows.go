@@ -0,0 +1,41 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+var _ = fmt.Print
+
+// Retry fn a few times if it fails because a file is in use, which can happen
+// transiently, for example, while an anti-virus or indexer has a file open
+func retry_on_busy(fn func() error) (err error) {
+	delay := 10 * time.Millisecond
+	for i := 0; i < 5; i++ {
+		if err = fn(); !errors.Is(err, windows.ERROR_SHARING_VIOLATION) {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return
+}
+
+// Remove path and, if it is a directory, everything inside it. On Windows,
+// os.RemoveAll() already removes read-only files and removes symlinks and
+// junctions without following them, so only removals that fail because a
+// file is in use are retried. A path that does not exist is not an error.
+func RemoveAllSecure(path string) error {
+	path = filepath.Clean(path)
+	if name := filepath.Base(path); name == "." || name == ".." || name == string(filepath.Separator) {
+		return &os.PathError{Op: "remove", Path: path, Err: windows.ERROR_INVALID_NAME}
+	}
+	return retry_on_busy(func() error { return os.RemoveAll(path) })
+}
@@ -123,25 +123,14 @@ func create_temp(pattern string, size uint64) (ans MMap, err error) {
 		return
 	}
 	var f *os.File
-	try := 0
-	for {
-		name := prefix + utils.RandomFilename() + suffix
-		path := file_path_from_name(name)
-		f, err = os.OpenFile(path, os.O_EXCL|os.O_CREATE|os.O_RDWR, 0600)
-		if err != nil {
-			if errors.Is(err, fs.ErrExist) {
-				try += 1
-				if try > 10000 {
-					return nil, &os.PathError{Op: "createtemp", Path: prefix + "*" + suffix, Err: fs.ErrExist}
-				}
-				continue
-			}
-			if errors.Is(err, fs.ErrNotExist) {
-				return nil, &ErrNotSupported{err: err}
-			}
-			return
+	if _, err = utils.CreateWithUniqueName(prefix, suffix, func(name string) (err error) {
+		f, err = os.OpenFile(file_path_from_name(name), os.O_EXCL|os.O_CREATE|os.O_RDWR, 0600)
+		return
+	}); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, &ErrNotSupported{err: err}
 		}
-		break
+		return
 	}
 	return file_mmap(f, size, WRITE, true, special_name)
 }
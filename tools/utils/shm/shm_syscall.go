@@ -4,7 +4,6 @@
 package shm
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -153,23 +152,14 @@ func create_temp(pattern string, size uint64) (ans MMap, err error) {
 		prefix = SHM_REQUIRED_PREFIX + prefix
 	}
 	var f *os.File
-	try := 0
-	for {
-		name := prefix + utils.RandomFilename() + suffix
+	if _, err = utils.CreateWithUniqueName(prefix, suffix, func(name string) (err error) {
 		if len(name) > SHM_NAME_MAX {
-			return nil, ErrPatternTooLong
+			return ErrPatternTooLong
 		}
+		// the wrapped errno matches fs.ErrExist
 		f, err = shm_open(name, os.O_EXCL|os.O_CREATE|os.O_RDWR, 0600)
-		if err != nil && (errors.Is(err, fs.ErrExist) || errors.Unwrap(err) == unix.EEXIST) {
-			try += 1
-			if try > 10000 {
-				return nil, &os.PathError{Op: "createtemp", Path: prefix + "*" + suffix, Err: fs.ErrExist}
-			}
-			continue
-		}
-		break
-	}
-	if err != nil {
+		return
+	}); err != nil {
 		return nil, err
 	}
 	return syscall_mmap(f, size, WRITE, true)
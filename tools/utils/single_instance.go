@@ -0,0 +1,150 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// A handle to a named single instance. If IsPrimary is true, this process is
+// the one instance and can receive messages from other instances via Listen(),
+// otherwise Pid is the process id of the primary instance and messages can be
+// sent to it with Signal().
+type Instance struct {
+	Name      string
+	IsPrimary bool
+	Pid       int
+
+	lock_file   *os.File
+	socket_addr string
+	listener    net.Listener
+}
+
+// Ensure only a single instance of the program identified by name runs per
+// user. The primary instance holds an exclusive lock on a pid file in
+// RuntimeDir() and listens on a UNIX socket, which is in the abstract
+// namespace on Linux.
+func SingleInstance(name string) (*Instance, error) {
+	return single_instance(RuntimeDir(), name)
+}
+
+func single_instance(dir, name string) (ans *Instance, err error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("Invalid single instance name: %#v", name)
+	}
+	ans = &Instance{Name: name, socket_addr: filepath.Join(dir, name+".sock")}
+	if runtime.GOOS == "linux" {
+		ans.socket_addr = "@" + ans.socket_addr
+	}
+	path := filepath.Join(dir, name+".pid")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := TryLockFileExclusive(f)
+	if !locked {
+		defer f.Close()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		// the primary instance may not have written its pid yet
+		ans.Pid, _ = strconv.Atoi(strings.TrimSpace(UnsafeBytesToString(data)))
+		return ans, nil
+	}
+	ans.IsPrimary = true
+	ans.Pid = os.Getpid()
+	ans.lock_file = f
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteString(strconv.Itoa(ans.Pid))
+	}
+	if err == nil {
+		if !strings.HasPrefix(ans.socket_addr, "@") {
+			// left over from a primary instance that crashed
+			os.Remove(ans.socket_addr)
+		}
+		ans.listener, err = net.Listen("unix", ans.socket_addr)
+	}
+	if err != nil {
+		ans.Close()
+		return nil, err
+	}
+	return ans, nil
+}
+
+// Call handler with every message sent by other instances, in a separate
+// goroutine. Returns immediately.
+func (self *Instance) Listen(handler func(msg []byte)) error {
+	if !self.IsPrimary {
+		return fmt.Errorf("Cannot listen for messages as this is not the primary instance of %s", self.Name)
+	}
+	l := self.listener
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				if msg, err := io.ReadAll(conn); err == nil {
+					handler(msg)
+				}
+			}()
+		}
+	}()
+	return nil
+}
+
+// Send a message to the primary instance
+func (self *Instance) Signal(msg []byte) error {
+	if self.IsPrimary {
+		return fmt.Errorf("Cannot signal %s as this is the primary instance", self.Name)
+	}
+	conn, err := net.Dial("unix", self.socket_addr)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to the running instance of %s with error: %w", self.Name, err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write(msg); err != nil {
+		return err
+	}
+	if uc, ok := conn.(*net.UnixConn); ok {
+		return uc.CloseWrite()
+	}
+	return nil
+}
+
+// Release the single instance, allowing another instance to become primary
+func (self *Instance) Close() {
+	if self.listener != nil {
+		self.listener.Close()
+		self.listener = nil
+		if !strings.HasPrefix(self.socket_addr, "@") {
+			os.Remove(self.socket_addr)
+		}
+	}
+	if self.lock_file != nil {
+		// the pid file is not removed as that would race with other
+		// processes that have opened it but not yet tried to lock it
+		_ = self.lock_file.Truncate(0)
+		self.lock_file.Close()
+		self.lock_file = nil
+	}
+}
@@ -0,0 +1,58 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestSingleInstance(t *testing.T) {
+	tdir := t.TempDir()
+	primary, err := single_instance(tdir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	if !primary.IsPrimary || primary.Pid != os.Getpid() {
+		t.Fatalf("First instance is not primary: %#v", primary)
+	}
+	received := make(chan string, 1)
+	if err = primary.Listen(func(msg []byte) { received <- string(msg) }); err != nil {
+		t.Fatal(err)
+	}
+	other, err := single_instance(tdir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.IsPrimary || other.Pid != os.Getpid() {
+		t.Fatalf("Second instance is primary or has incorrect pid: %#v", other)
+	}
+	if err = other.Signal([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if msg != "hello" {
+			t.Fatalf("Incorrect message received: %#v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for message")
+	}
+	primary.Close()
+	third, err := single_instance(tdir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	if !third.IsPrimary {
+		t.Fatalf("Instance is not primary after the previous primary was closed")
+	}
+	if _, err = single_instance(tdir, "../x"); err == nil {
+		t.Fatalf("No error for invalid name")
+	}
+}
@@ -0,0 +1,53 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+const (
+	r_ok = unix.R_OK
+	w_ok = unix.W_OK
+	x_ok = unix.X_OK
+)
+
+// Check whether the file at path can be accessed by the current user with
+// mode, a combination of r_ok, w_ok and x_ok
+func access(path string, mode uint32) error {
+	return unix.Access(path, mode)
+}
+
+func inode_of(s fs.FileInfo) (ans inode_info, ok bool) {
+	st, ok := s.Sys().(*syscall.Stat_t)
+	if ok {
+		ans = inode_info{file_id: file_id{uint64(st.Dev), uint64(st.Ino)}, nlink: uint64(st.Nlink), blocks: int64(st.Blocks)}
+	}
+	return
+}
+
+// The access time of the file at path, not following symlinks
+func access_time(path string, s fs.FileInfo) (time.Time, bool) {
+	var st unix.Stat_t
+	if unix.Lstat(path, &st) != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, st.Atim.Nano()), true
+}
+
+func device_of(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return 0, &fs.PathError{Op: "lstat", Path: path, Err: err}
+	}
+	return uint64(st.Dev), nil
+}
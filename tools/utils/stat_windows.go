@@ -0,0 +1,64 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build windows
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+var _ = fmt.Print
+
+const (
+	r_ok = 4
+	w_ok = 2
+	x_ok = 1
+)
+
+// Windows has no equivalent of access(), so only that the file exists and,
+// for w_ok, that it is not read-only is checked
+func access(path string, mode uint32) error {
+	s, err := os.Stat(path)
+	if err == nil && mode&w_ok != 0 && s.Mode().Perm()&0o200 == 0 {
+		err = &fs.PathError{Op: "access", Path: path, Err: fs.ErrPermission}
+	}
+	return err
+}
+
+// File ids are not available from the fs.FileInfo of files on Windows
+func inode_of(s fs.FileInfo) (ans inode_info, ok bool) {
+	return
+}
+
+func access_time(path string, s fs.FileInfo) (time.Time, bool) {
+	if d, ok := s.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, d.LastAccessTime.Nanoseconds()), true
+	}
+	return time.Time{}, false
+}
+
+// The serial number of the volume containing path
+func device_of(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return 0, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := windows.CreateFile(p, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if err != nil {
+		return 0, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer windows.CloseHandle(h)
+	var info windows.ByHandleFileInformation
+	if err = windows.GetFileInformationByHandle(h, &info); err != nil {
+		return 0, &fs.PathError{Op: "stat", Path: path, Err: err}
+	}
+	return uint64(info.VolumeSerialNumber), nil
+}
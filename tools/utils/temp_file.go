@@ -0,0 +1,107 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var _ = fmt.Print
+
+var temp_files struct {
+	mutex      sync.Mutex
+	registered map[string]bool
+}
+
+// Call create with names of the form prefix + random characters + suffix
+// until it succeeds or fails with an error other than fs.ErrExist. Returns
+// the name for which create succeeded.
+func CreateWithUniqueName(prefix, suffix string, create func(name string) error) (string, error) {
+	for try := 0; try < 10000; try++ {
+		name := prefix + RandomFilename() + suffix
+		err := create(name)
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+	return "", &os.PathError{Op: "createtemp", Path: prefix + "*" + suffix, Err: fs.ErrExist}
+}
+
+// Create a new file, readable and writable only by the current user, in dir,
+// or os.TempDir() if dir is empty, named prefix + random characters + suffix.
+// The file is removed when the process exits via the cli package or is
+// killed by SIGINT, SIGTERM or SIGHUP, unless it is removed with RemoveTemp()
+// or kept with KeepTemp() before that. Use CreateAnonymousTemp() for files
+// that do not need a name.
+func CreateTemp(dir, prefix, suffix string) (f *os.File, err error) {
+	if strings.ContainsRune(prefix+suffix, os.PathSeparator) {
+		return nil, &os.PathError{Op: "createtemp", Path: prefix + "*" + suffix, Err: errors.New("pattern contains path separator")}
+	}
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	// the file must be removable even if the working directory changes
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		dir += string(os.PathSeparator)
+	}
+	path, err := CreateWithUniqueName(dir+prefix, suffix, func(name string) (err error) {
+		f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	temp_files.mutex.Lock()
+	if temp_files.registered == nil {
+		temp_files.registered = make(map[string]bool)
+	}
+	temp_files.registered[path] = true
+	temp_files.mutex.Unlock()
+	handle_exit_signals()
+	return f, nil
+}
+
+// Stop tracking the temporary file at path, created by CreateTemp(), so that
+// it is not removed at exit. Must be called after the file is renamed into
+// place.
+func KeepTemp(path string) {
+	temp_files.mutex.Lock()
+	delete(temp_files.registered, path)
+	temp_files.mutex.Unlock()
+}
+
+// Remove the temporary file at path, created by CreateTemp()
+func RemoveTemp(path string) error {
+	KeepTemp(path)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Remove all temporary files created by CreateTemp() in this process that
+// have not been removed or kept
+func RemoveAllTemps() {
+	temp_files.mutex.Lock()
+	paths := make([]string, 0, len(temp_files.registered))
+	for path := range temp_files.registered {
+		paths = append(paths, path)
+	}
+	temp_files.registered = nil
+	temp_files.mutex.Unlock()
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,65 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestCreateTemp(t *testing.T) {
+	tdir := t.TempDir()
+	tries := 0
+	name, err := CreateWithUniqueName("a-", ".b", func(name string) error {
+		if tries++; tries < 3 {
+			return fs.ErrExist
+		}
+		return nil
+	})
+	if err != nil || tries != 3 || !strings.HasPrefix(name, "a-") || !strings.HasSuffix(name, ".b") || len(name) <= 4 {
+		t.Fatalf("Unique name creation failed: %#v %d %v", name, tries, err)
+	}
+	if _, err = CreateWithUniqueName("", "", func(string) error { return fs.ErrExist }); err == nil {
+		t.Fatalf("Unique name creation did not fail")
+	}
+
+	f, err := CreateTemp(tdir, "prefix-", ".suffix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if filepath.Dir(f.Name()) != tdir || !strings.HasPrefix(filepath.Base(f.Name()), "prefix-") || !strings.HasSuffix(f.Name(), ".suffix") {
+		t.Fatalf("Incorrect temp file name: %s", f.Name())
+	}
+	if s, err := os.Stat(f.Name()); err != nil || s.Mode().Perm() != 0o600 {
+		t.Fatalf("Incorrect temp file permissions: %v %v", s, err)
+	}
+	kept, err := CreateTemp(tdir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept.Close()
+	KeepTemp(kept.Name())
+	removed, err := CreateTemp(tdir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed.Close()
+	if err = RemoveTemp(removed.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = CreateTemp(tdir, "a/b", ""); err == nil {
+		t.Fatalf("Creating a temp file with a path separator in its prefix did not fail")
+	}
+	RemoveAllTemps()
+	entries, _ := os.ReadDir(tdir)
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(kept.Name()) {
+		t.Fatalf("Incorrect files left after cleanup: %v", entries)
+	}
+}
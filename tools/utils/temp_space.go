@@ -0,0 +1,163 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+// A private directory for temporary files, that is removed when it is
+// closed, when the process exits via the cli package or is killed by SIGINT,
+// SIGTERM or SIGHUP. The directories of processes that crash are removed the
+// next time any process creates a TempSpace.
+type TempSpace struct {
+	Path string
+
+	mutex   sync.Mutex
+	lock    *FileLock
+	claimed map[string]string
+	closed  bool
+}
+
+var temp_spaces struct {
+	mutex  sync.Mutex
+	active map[*TempSpace]bool
+}
+
+const temp_space_lock_name = ".lock"
+
+// Remove the temp spaces of processes that no longer exist. A temp space is
+// in use for as long as its lock file is locked by the process owning it.
+func remove_orphaned_temp_spaces(base string) {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		path := filepath.Join(base, e.Name())
+		f, err := os.Open(filepath.Join(path, temp_space_lock_name))
+		if err != nil {
+			// a temp space that is still being created has no lock file
+			if s, serr := e.Info(); errors.Is(err, fs.ErrNotExist) && serr == nil && time.Since(s.ModTime()) > time.Minute {
+				RemoveAllSecure(path)
+			}
+			continue
+		}
+		if locked, _ := TryLockFileExclusive(f); locked {
+			RemoveAllSecure(path)
+		}
+		f.Close()
+	}
+}
+
+// Create a new temp space in RuntimeDir() whose name starts with prefix
+func NewTempSpace(prefix string) (*TempSpace, error) {
+	return new_temp_space(filepath.Join(RuntimeDir(), "tmp"), prefix)
+}
+
+func new_temp_space(base, prefix string) (ans *TempSpace, err error) {
+	if err = os.MkdirAll(base, 0o700); err != nil {
+		return nil, err
+	}
+	remove_orphaned_temp_spaces(base)
+	path, err := os.MkdirTemp(base, prefix+"-")
+	if err != nil {
+		return nil, err
+	}
+	// MkdirTemp already uses 0o700 but be robust against a weird umask
+	if err = os.Chmod(path, 0o700); err != nil {
+		RemoveAllSecure(path)
+		return nil, err
+	}
+	// the lock file must be created locked, otherwise another process could
+	// lock it and treat the temp space as orphaned
+	lock, err := NewLockedFileLock(filepath.Join(path, temp_space_lock_name), true)
+	if err != nil {
+		RemoveAllSecure(path)
+		return nil, err
+	}
+	ans = &TempSpace{Path: path, lock: lock, claimed: make(map[string]string)}
+	temp_spaces.mutex.Lock()
+	defer temp_spaces.mutex.Unlock()
+	if temp_spaces.active == nil {
+		temp_spaces.active = make(map[*TempSpace]bool)
+	}
+	temp_spaces.active[ans] = true
+	handle_exit_signals()
+	return ans, nil
+}
+
+// Claim the scratch directory name inside this temp space, creating it. Fails
+// if the name is already claimed.
+func (self *TempSpace) Claim(name string) (string, error) {
+	if name == "" || name == temp_space_lock_name || strings.ContainsRune(name, os.PathSeparator) || name == "." || name == ".." {
+		return "", fmt.Errorf("Invalid name for a scratch area: %#v", name)
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return "", fmt.Errorf("The temp space %s has been closed", self.Path)
+	}
+	if _, found := self.claimed[name]; found {
+		return "", fmt.Errorf("The scratch area %s is already claimed", name)
+	}
+	path := filepath.Join(self.Path, name)
+	if err := os.Mkdir(path, 0o700); err != nil {
+		return "", err
+	}
+	self.claimed[name] = path
+	return path, nil
+}
+
+// Release a previously claimed scratch directory, deleting its contents
+func (self *TempSpace) Release(name string) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	path, found := self.claimed[name]
+	if !found {
+		return fmt.Errorf("The scratch area %s is not claimed", name)
+	}
+	delete(self.claimed, name)
+	return RemoveAllSecure(path)
+}
+
+// Delete the temp space and everything in it
+func (self *TempSpace) Close() (err error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return nil
+	}
+	self.closed = true
+	err = RemoveAllSecure(self.Path)
+	self.lock.Close()
+	temp_spaces.mutex.Lock()
+	delete(temp_spaces.active, self)
+	temp_spaces.mutex.Unlock()
+	return
+}
+
+// Close all temp spaces created by this process
+func CloseAllTempSpaces() {
+	temp_spaces.mutex.Lock()
+	active := make([]*TempSpace, 0, len(temp_spaces.active))
+	for ts := range temp_spaces.active {
+		active = append(active, ts)
+	}
+	temp_spaces.mutex.Unlock()
+	for _, ts := range active {
+		ts.Close()
+	}
+}
@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestTempSpace(t *testing.T) {
+	base := t.TempDir()
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	// the temp space of a process that crashed
+	orphan := filepath.Join(base, "orphan-1")
+	if err := os.MkdirAll(orphan, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(orphan, temp_space_lock_name), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := new_temp_space(base, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists(orphan) {
+		t.Fatalf("Orphaned temp space not removed")
+	}
+	if s, err := os.Stat(a.Path); err != nil || s.Mode().Perm() != 0o700 {
+		t.Fatalf("Temp space not created with correct permissions: %v", err)
+	}
+	b, err := new_temp_space(base, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists(a.Path) || !exists(b.Path) || a.Path == b.Path {
+		t.Fatalf("Temp space in use was removed or not unique")
+	}
+
+	x, err := a.Claim("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(x, "f"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"x", "", "..", "a/b", temp_space_lock_name} {
+		if _, err = a.Claim(name); err == nil {
+			t.Fatalf("Claiming %#v did not fail", name)
+		}
+	}
+	if err = a.Release("x"); err != nil || exists(x) {
+		t.Fatalf("Releasing scratch area failed: %v", err)
+	}
+	if err = a.Release("x"); err == nil {
+		t.Fatalf("Releasing an unclaimed scratch area did not fail")
+	}
+	if _, err = a.Claim("x"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = a.Close(); err != nil || exists(a.Path) {
+		t.Fatalf("Closing temp space failed: %v", err)
+	}
+	if _, err = a.Claim("y"); err == nil {
+		t.Fatalf("Claiming in a closed temp space did not fail")
+	}
+	CloseAllTempSpaces()
+	if exists(b.Path) {
+		t.Fatalf("Closing all temp spaces did not remove: %s", b.Path)
+	}
+}
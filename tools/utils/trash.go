@@ -0,0 +1,163 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var _ = fmt.Print
+
+// The mount point of the file system containing path
+func mount_point_of(path string) (string, error) {
+	dev, err := device_of(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		if pdev, err := device_of(parent); err != nil || pdev != dev {
+			return path, nil
+		}
+		path = parent
+	}
+}
+
+// Split name into a stem and extension so that numbers can be added to the
+// stem to make it unique
+func split_for_uniqueness(name string) (stem, ext string) {
+	ext = filepath.Ext(name)
+	if ext == name || len(ext) > 10 {
+		ext = ""
+	}
+	return name[:len(name)-len(ext)], ext
+}
+
+type freedesktop_trash struct {
+	dir string
+	// The directory relative to which paths are stored, empty for absolute paths
+	topdir string
+}
+
+// Return the directory of the home trash, which can only be used for files
+// on the same file system
+func home_trash_dir() string {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		data = "~/.local/share"
+	}
+	return filepath.Join(Expanduser(data), "Trash")
+}
+
+// Find the trash directory as per the freedesktop.org Trash specification for
+// a file on the device dev
+func find_freedesktop_trash(path string, dev uint64) (ans freedesktop_trash, err error) {
+	home := home_trash_dir()
+	if err = os.MkdirAll(home, 0o700); err == nil {
+		if hdev, herr := device_of(home); herr == nil && hdev == dev {
+			return freedesktop_trash{dir: home}, nil
+		}
+	}
+	topdir, err := mount_point_of(path)
+	if err != nil {
+		return
+	}
+	uid := strconv.Itoa(os.Getuid())
+	// An administrator created .Trash directory must have the sticky bit set
+	// and not be a symlink
+	if s, serr := os.Lstat(filepath.Join(topdir, ".Trash")); serr == nil && s.IsDir() && s.Mode()&fs.ModeSticky != 0 {
+		dir := filepath.Join(topdir, ".Trash", uid)
+		if err = os.MkdirAll(dir, 0o700); err == nil {
+			return freedesktop_trash{dir: dir, topdir: topdir}, nil
+		}
+	}
+	dir := filepath.Join(topdir, ".Trash-"+uid)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return ans, fmt.Errorf("Could not find a trash directory for %s as the trash directory %s could not be created: %w", path, dir, err)
+	}
+	return freedesktop_trash{dir: dir, topdir: topdir}, nil
+}
+
+// Move path into the trash directory, writing the .trashinfo file needed to
+// restore it
+func (self freedesktop_trash) trash(path string, now time.Time) (err error) {
+	files, info := filepath.Join(self.dir, "files"), filepath.Join(self.dir, "info")
+	for _, x := range []string{files, info} {
+		if err = os.MkdirAll(x, 0o700); err != nil {
+			return err
+		}
+	}
+	stored_path := path
+	if self.topdir != "" {
+		if stored_path, err = filepath.Rel(self.topdir, path); err != nil {
+			return err
+		}
+	}
+	entry := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n", (&url.URL{Path: stored_path}).EscapedPath(), now.Format("2006-01-02T15:04:05"))
+	name := filepath.Base(path)
+	stem, ext := split_for_uniqueness(name)
+	// Creating the info file exclusively reserves the name in the trash
+	for i := 2; i < 10000; i++ {
+		info_path := filepath.Join(info, name+".trashinfo")
+		f, err := os.OpenFile(info_path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			dest := filepath.Join(files, name)
+			if _, err = os.Lstat(dest); err == nil {
+				// a stale entry without an info file
+				f.Close()
+				os.Remove(info_path)
+			} else {
+				_, err = f.WriteString(entry)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+				if err == nil {
+					err = os.Rename(path, dest)
+				}
+				if err != nil {
+					os.Remove(info_path)
+				}
+				return err
+			}
+		} else if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		name = fmt.Sprintf("%s.%d%s", stem, i, ext)
+	}
+	return fmt.Errorf("Too many files named %s in the trash directory: %s", filepath.Base(path), self.dir)
+}
+
+// Move path to the trash, from where the user can restore it. Uses the
+// freedesktop.org Trash specification, except on macOS where the trash
+// directories used by the Finder are preferred. Symlinks are trashed
+// themselves, not the files they point to.
+func Trash(path string) (err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	if path == string(os.PathSeparator) {
+		return fmt.Errorf("Refusing to trash: %s", path)
+	}
+	dev, err := device_of(path)
+	if err != nil {
+		return err
+	}
+	if done, err := trash_native(path, dev); done || err != nil {
+		return err
+	}
+	t, err := find_freedesktop_trash(path, dev)
+	if err != nil {
+		return err
+	}
+	return t.trash(path, time.Now())
+}
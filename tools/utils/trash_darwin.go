@@ -0,0 +1,55 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var _ = fmt.Print
+
+// Find the trash directory used by the Finder for files on the device dev
+func macos_trash_dir(path string, dev uint64) string {
+	home := Expanduser("~/.Trash")
+	if hdev, err := device_of(home); err == nil && hdev == dev {
+		return home
+	}
+	topdir, err := mount_point_of(path)
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Join(topdir, ".Trashes", strconv.Itoa(os.Getuid()))
+	if os.MkdirAll(dir, 0o700) != nil {
+		return ""
+	}
+	return dir
+}
+
+// Move path into the trash directory used by the Finder, naming duplicates
+// the way the Finder does. Returns false if no such directory is available or
+// it cannot be used, as happens for ~/.Trash when the program lacks Full Disk
+// Access.
+func trash_native(path string, dev uint64) (bool, error) {
+	dir := macos_trash_dir(path, dev)
+	if dir == "" {
+		return false, nil
+	}
+	name := filepath.Base(path)
+	stem, ext := split_for_uniqueness(name)
+	for i := 2; i < 10000; i++ {
+		dest := filepath.Join(dir, name)
+		if _, err := os.Lstat(dest); err != nil {
+			if err = os.Rename(path, dest); errors.Is(err, fs.ErrPermission) {
+				return false, nil
+			}
+			return true, err
+		}
+		name = fmt.Sprintf("%s %d%s", stem, i, ext)
+	}
+	return true, fmt.Errorf("Too many files named %s in the trash directory: %s", filepath.Base(path), dir)
+}
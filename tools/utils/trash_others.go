@@ -0,0 +1,15 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build !darwin
+
+package utils
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+func trash_native(path string, dev uint64) (bool, error) {
+	return false, nil
+}
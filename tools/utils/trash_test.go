@@ -0,0 +1,70 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestTrash(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("Trashing uses the Finder trash directories on macOS")
+	}
+	tdir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(tdir, "data"))
+	trash := filepath.Join(tdir, "data", "Trash")
+	for _, name := range []string{"a b.txt", "a b.txt", "dir"} {
+		path := filepath.Join(tdir, name)
+		if name == "dir" {
+			if err := os.MkdirAll(filepath.Join(path, "child"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Trash(path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Lstat(path); err == nil {
+			t.Fatalf("%s was not moved to the trash", path)
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(trash, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if diff := cmp.Diff([]string{"a b.2.txt", "a b.txt", "dir"}, names); diff != "" {
+		t.Fatalf("Unexpected files in trash:\n%s", diff)
+	}
+	if _, err = os.Stat(filepath.Join(trash, "files", "dir", "child")); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(trash, "info", "a b.2.txt.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(raw), "\n")
+	if diff := cmp.Diff([]string{"[Trash Info]", "Path=" + strings.ReplaceAll(filepath.Join(tdir, "a b.txt"), " ", "%20")}, lines[:2]); diff != "" {
+		t.Fatalf("Unexpected trash info:\n%s", diff)
+	}
+	if _, err = time.Parse("2006-01-02T15:04:05", strings.TrimPrefix(lines[2], "DeletionDate=")); err != nil {
+		t.Fatalf("Invalid deletion date in trash info: %s", lines[2])
+	}
+	if err = Trash(filepath.Join(tdir, "does not exist")); err == nil {
+		t.Fatalf("No error trashing a non-existent file")
+	}
+}
@@ -0,0 +1,297 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+// The interface implemented by the platform specific watchers. emit must be
+// called with the path of every changed file or directory, with is_new_dir
+// true if the path is a newly created directory. dropped must be called with
+// the path of a watch that the operating system has removed, for example,
+// because the file was deleted or renamed.
+type watch_backend interface {
+	add(path string, is_dir bool) error
+	remove(path string)
+	close() error
+}
+
+// Watch files and directories for changes. Changes are debounced, that is,
+// reported only once no further changes have happened for the debounce
+// interval.
+type Watcher struct {
+	// Receives the sorted list of changed paths. Closed when the watcher is
+	// closed.
+	Events <-chan []string
+	// Receives errors from the operating system, such as an overflow of its
+	// event queue
+	Errors <-chan error
+
+	debounce time.Duration
+	backend  watch_backend
+	mutex    sync.Mutex
+	watched  map[string]watch_backend
+	// the backend for paths on network file systems, created when needed
+	poller          watch_backend
+	poll_interval   time.Duration
+	use_polling     func(path string) bool
+	recursive_roots []string
+	// the paths passed to Add(), mapped to whether they are directories,
+	// these are watched again if they are deleted and re-created
+	added map[string]bool
+	// added paths whose watch was dropped and that do not currently exist
+	lost        *Set[string]
+	retry_timer *time.Timer
+	raw         chan string
+	events      chan []string
+	errors      chan error
+	closed      chan struct{}
+	close_once  sync.Once
+}
+
+func NewWatcher(debounce time.Duration) (ans *Watcher, err error) {
+	ans = &Watcher{
+		debounce: debounce, watched: make(map[string]watch_backend), added: make(map[string]bool), lost: NewSet[string](), raw: make(chan string, 256),
+		events: make(chan []string, 1), errors: make(chan error, 1), closed: make(chan struct{}),
+		poll_interval: watcher_poll_interval, use_polling: IsNetworkFS,
+	}
+	ans.Events, ans.Errors = ans.events, ans.errors
+	if ans.backend, err = new_watch_backend(ans.emit, ans.on_error, ans.dropped); err != nil {
+		return nil, err
+	}
+	go ans.debounce_loop()
+	return
+}
+
+func (self *Watcher) on_error(err error) {
+	select {
+	case self.errors <- err:
+	default:
+	}
+}
+
+// How often to check if added paths that were deleted have been re-created
+const watcher_retry_interval = 500 * time.Millisecond
+
+func (self *Watcher) dropped(path string) {
+	self.mutex.Lock()
+	delete(self.watched, path)
+	_, is_added := self.added[path]
+	self.mutex.Unlock()
+	if is_added && !self.readd(path) {
+		self.mutex.Lock()
+		defer self.mutex.Unlock()
+		self.lost.Add(path)
+		self.schedule_retry()
+	}
+}
+
+// must be called with the mutex held
+func (self *Watcher) schedule_retry() {
+	select {
+	case <-self.closed:
+		return
+	default:
+	}
+	if self.retry_timer == nil {
+		self.retry_timer = time.AfterFunc(watcher_retry_interval, self.retry_lost)
+	} else {
+		self.retry_timer.Reset(watcher_retry_interval)
+	}
+}
+
+func (self *Watcher) retry_lost() {
+	self.mutex.Lock()
+	lost := self.lost.AsSlice()
+	self.mutex.Unlock()
+	for _, path := range lost {
+		if self.readd(path) {
+			self.mutex.Lock()
+			self.lost.Discard(path)
+			self.mutex.Unlock()
+			self.emit(path, false)
+		}
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.lost.Len() > 0 {
+		self.schedule_retry()
+	}
+}
+
+// Watch an added path again, returning false if it does not exist
+func (self *Watcher) readd(path string) bool {
+	self.mutex.Lock()
+	is_dir, is_added := self.added[path]
+	self.mutex.Unlock()
+	if !is_added {
+		// removed since its watch was dropped
+		return true
+	}
+	s, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if s.IsDir() != is_dir {
+		return false
+	}
+	if is_dir && self.in_recursive_root(path) {
+		err = self.add_tree(path)
+	} else {
+		err = self.add_one(path, is_dir)
+	}
+	return err == nil
+}
+
+func (self *Watcher) emit(path string, is_new_dir bool) {
+	if is_new_dir && self.in_recursive_root(path) {
+		if err := self.add_tree(path); err != nil {
+			self.on_error(err)
+		}
+	}
+	select {
+	case self.raw <- path:
+	case <-self.closed:
+	}
+}
+
+func (self *Watcher) in_recursive_root(path string) bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, root := range self.recursive_roots {
+		if path == root || strings.HasPrefix(path, root+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Watcher) debounce_loop() {
+	defer close(self.events)
+	pending := NewSet[string]()
+	timer := time.NewTimer(self.debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-self.closed:
+			return
+		case path := <-self.raw:
+			pending.Add(path)
+			timer.Reset(self.debounce)
+		case <-timer.C:
+			if pending.Len() > 0 {
+				changed := pending.AsSlice()
+				slices.Sort(changed)
+				pending = NewSet[string]()
+				select {
+				case self.events <- changed:
+				case <-self.closed:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (self *Watcher) add_one(path string, is_dir bool) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.watched[path] != nil {
+		return nil
+	}
+	backend := self.backend
+	if self.use_polling(path) {
+		if self.poller == nil {
+			self.poller = new_poll_backend(self.poll_interval, self.emit, self.dropped)
+		}
+		backend = self.poller
+	}
+	if err := backend.add(path, is_dir); err != nil {
+		return fmt.Errorf("Failed to watch %s for changes with error: %w", path, err)
+	}
+	self.watched[path] = backend
+	return nil
+}
+
+func (self *Watcher) add_tree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the directory may have been deleted already
+			return nil
+		}
+		if d.IsDir() {
+			return self.add_one(path, true)
+		}
+		return nil
+	})
+}
+
+// Watch path for changes. If path is a directory changes to its immediate
+// children are reported and, if recursive is true, all its descendants,
+// including in directories created later.
+func (self *Watcher) Add(path string, recursive bool) (err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	s, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	self.mutex.Lock()
+	self.added[path] = s.IsDir()
+	self.mutex.Unlock()
+	if !s.IsDir() || !recursive {
+		return self.add_one(path, s.IsDir())
+	}
+	self.mutex.Lock()
+	self.recursive_roots = append(self.recursive_roots, path)
+	self.mutex.Unlock()
+	return self.add_tree(path)
+}
+
+// Stop watching path, which must have been added with Add(). If it was added
+// recursively, its descendants are no longer watched either.
+func (self *Watcher) Remove(path string) (err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	prefix := path + string(os.PathSeparator)
+	self.recursive_roots = slices.DeleteFunc(self.recursive_roots, func(x string) bool { return x == path })
+	delete(self.added, path)
+	self.lost.Discard(path)
+	for p, backend := range self.watched {
+		if p == path || strings.HasPrefix(p, prefix) {
+			backend.remove(p)
+			delete(self.watched, p)
+		}
+	}
+	return nil
+}
+
+func (self *Watcher) Close() (err error) {
+	self.close_once.Do(func() {
+		close(self.closed)
+		self.mutex.Lock()
+		if self.retry_timer != nil {
+			self.retry_timer.Stop()
+		}
+		if self.poller != nil {
+			_ = self.poller.close()
+		}
+		self.mutex.Unlock()
+		err = self.backend.close()
+	})
+	return
+}
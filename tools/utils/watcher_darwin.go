@@ -0,0 +1,12 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	// Do not prevent the volume containing watched files from being unmounted
+	kqueue_open_flags = unix.O_EVTONLY | unix.O_CLOEXEC
+}
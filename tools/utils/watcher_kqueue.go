@@ -0,0 +1,240 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+var kqueue_open_flags = unix.O_RDONLY | unix.O_CLOEXEC
+
+const kqueue_fflags = unix.NOTE_WRITE | unix.NOTE_EXTEND | unix.NOTE_ATTRIB | unix.NOTE_DELETE | unix.NOTE_RENAME
+
+type kqueue_watch struct {
+	path   string
+	is_dir bool
+	// true if the file is only watched as it is an entry of a watched directory
+	implicit bool
+}
+
+// kqueue only reports changes to open files, so the entries of watched
+// directories are opened and watched as well and directories are re-read
+// when they change, to find new entries.
+type kqueue_backend struct {
+	kq         int
+	wakeup     [2]int
+	mutex      sync.Mutex
+	fd_to_info map[int]*kqueue_watch
+	path_to_fd map[string]int
+	dir_items  map[string]*Set[string]
+	emit       func(string, bool)
+	on_error   func(error)
+	dropped    func(string)
+}
+
+func new_watch_backend(emit func(string, bool), on_error func(error), dropped func(string)) (watch_backend, error) {
+	kq, err := unix.Kqueue()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize kqueue with error: %w", err)
+	}
+	unix.CloseOnExec(kq)
+	ans := &kqueue_backend{
+		kq: kq, emit: emit, on_error: on_error, dropped: dropped,
+		fd_to_info: make(map[int]*kqueue_watch), path_to_fd: make(map[string]int), dir_items: make(map[string]*Set[string]),
+	}
+	if err = unix.Pipe(ans.wakeup[:]); err != nil {
+		unix.Close(kq)
+		return nil, err
+	}
+	unix.CloseOnExec(ans.wakeup[0])
+	unix.CloseOnExec(ans.wakeup[1])
+	ev := make([]unix.Kevent_t, 1)
+	unix.SetKevent(&ev[0], ans.wakeup[0], unix.EVFILT_READ, unix.EV_ADD)
+	if _, err = unix.Kevent(kq, ev, nil, nil); err != nil {
+		unix.Close(kq)
+		unix.Close(ans.wakeup[0])
+		unix.Close(ans.wakeup[1])
+		return nil, err
+	}
+	go ans.read_loop()
+	return ans, nil
+}
+
+// must be called with the mutex held
+func (self *kqueue_backend) watch(path string, is_dir, implicit bool) error {
+	if fd, found := self.path_to_fd[path]; found {
+		if !implicit {
+			self.fd_to_info[fd].implicit = false
+		}
+		return nil
+	}
+	fd, err := unix.Open(path, kqueue_open_flags, 0)
+	if err != nil {
+		return err
+	}
+	ev := make([]unix.Kevent_t, 1)
+	unix.SetKevent(&ev[0], fd, unix.EVFILT_VNODE, unix.EV_ADD|unix.EV_CLEAR)
+	ev[0].Fflags = kqueue_fflags
+	if _, err = unix.Kevent(self.kq, ev, nil, nil); err != nil {
+		unix.Close(fd)
+		return err
+	}
+	self.fd_to_info[fd] = &kqueue_watch{path: path, is_dir: is_dir, implicit: implicit}
+	self.path_to_fd[path] = fd
+	return nil
+}
+
+// must be called with the mutex held
+func (self *kqueue_backend) unwatch(path string) {
+	if fd, found := self.path_to_fd[path]; found {
+		// closing the fd removes it from the kqueue
+		unix.Close(fd)
+		delete(self.path_to_fd, path)
+		delete(self.fd_to_info, fd)
+	}
+	delete(self.dir_items, path)
+}
+
+// Read the entries of the directory, watching new files and returning the
+// new and removed entries. Must be called with the mutex held.
+func (self *kqueue_backend) scan_dir(path string) (added, removed []string, added_dirs map[string]bool) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return
+	}
+	current := NewSet[string](len(entries))
+	added_dirs = make(map[string]bool)
+	previous := self.dir_items[path]
+	for _, e := range entries {
+		child := filepath.Join(path, e.Name())
+		current.Add(child)
+		if previous != nil && previous.Has(child) {
+			continue
+		}
+		added = append(added, child)
+		if e.IsDir() {
+			added_dirs[child] = true
+		} else if e.Type().IsRegular() {
+			_ = self.watch(child, false, true)
+		}
+	}
+	if previous != nil {
+		previous.ForEach(func(child string) {
+			if !current.Has(child) {
+				removed = append(removed, child)
+				if fd, found := self.path_to_fd[child]; found && self.fd_to_info[fd].implicit {
+					self.unwatch(child)
+				}
+			}
+		})
+	}
+	self.dir_items[path] = current
+	return
+}
+
+func (self *kqueue_backend) add(path string, is_dir bool) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if err := self.watch(path, is_dir, false); err != nil {
+		return err
+	}
+	if is_dir {
+		self.scan_dir(path)
+	}
+	return nil
+}
+
+func (self *kqueue_backend) remove(path string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if items := self.dir_items[path]; items != nil {
+		items.ForEach(func(child string) {
+			if fd, found := self.path_to_fd[child]; found && self.fd_to_info[fd].implicit {
+				self.unwatch(child)
+			}
+		})
+	}
+	self.unwatch(path)
+}
+
+func (self *kqueue_backend) close() error {
+	_, err := unix.Write(self.wakeup[1], []byte{0})
+	return err
+}
+
+type kqueue_change struct {
+	path       string
+	is_new_dir bool
+	dropped    bool
+}
+
+func (self *kqueue_backend) changes_for(fd int, fflags uint32) (ans []kqueue_change) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	info := self.fd_to_info[fd]
+	if info == nil {
+		return
+	}
+	ans = append(ans, kqueue_change{path: info.path})
+	if fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME) != 0 {
+		self.unwatch(info.path)
+		ans[0].dropped = !info.implicit
+		return
+	}
+	if info.is_dir && fflags&unix.NOTE_WRITE != 0 {
+		added, removed, added_dirs := self.scan_dir(info.path)
+		for _, x := range added {
+			ans = append(ans, kqueue_change{path: x, is_new_dir: added_dirs[x]})
+		}
+		for _, x := range removed {
+			ans = append(ans, kqueue_change{path: x})
+		}
+	}
+	return
+}
+
+func (self *kqueue_backend) read_loop() {
+	defer func() {
+		self.mutex.Lock()
+		defer self.mutex.Unlock()
+		for fd := range self.fd_to_info {
+			unix.Close(fd)
+		}
+		unix.Close(self.kq)
+		unix.Close(self.wakeup[0])
+		unix.Close(self.wakeup[1])
+	}()
+	events := make([]unix.Kevent_t, 64)
+	for {
+		n, err := unix.Kevent(self.kq, nil, events, nil)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			self.on_error(fmt.Errorf("Failed to read from kqueue with error: %w", err))
+			return
+		}
+		for _, ev := range events[:n] {
+			fd := int(ev.Ident)
+			if fd == self.wakeup[0] {
+				return
+			}
+			// emit must be called without holding the mutex
+			for _, c := range self.changes_for(fd, uint32(ev.Fflags)) {
+				self.emit(c.path, c.is_new_dir)
+				if c.dropped {
+					self.dropped(c.path)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+const inotify_mask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+type inotify_backend struct {
+	fd         int
+	file       *os.File
+	mutex      sync.Mutex
+	wd_to_path map[int32]string
+	path_to_wd map[string]int32
+	emit       func(string, bool)
+	on_error   func(error)
+	dropped    func(string)
+}
+
+func new_watch_backend(emit func(string, bool), on_error func(error), dropped func(string)) (watch_backend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize inotify with error: %w", err)
+	}
+	// As the fd is non-blocking the file uses the runtime poller, so closing it
+	// interrupts a pending read
+	ans := &inotify_backend{
+		fd: fd, file: os.NewFile(uintptr(fd), "inotify"), emit: emit, on_error: on_error, dropped: dropped,
+		wd_to_path: make(map[int32]string), path_to_wd: make(map[string]int32),
+	}
+	go ans.read_loop()
+	return ans, nil
+}
+
+func (self *inotify_backend) add(path string, is_dir bool) error {
+	wd, err := unix.InotifyAddWatch(self.fd, path, inotify_mask)
+	if err != nil {
+		return err
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.wd_to_path[int32(wd)] = path
+	self.path_to_wd[path] = int32(wd)
+	return nil
+}
+
+func (self *inotify_backend) remove(path string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if wd, found := self.path_to_wd[path]; found {
+		_, _ = unix.InotifyRmWatch(self.fd, uint32(wd))
+		delete(self.path_to_wd, path)
+		delete(self.wd_to_path, wd)
+	}
+}
+
+func (self *inotify_backend) close() error {
+	return self.file.Close()
+}
+
+func (self *inotify_backend) path_for(wd int32, mask uint32) (path string, found bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if path, found = self.wd_to_path[wd]; found && mask&unix.IN_IGNORED != 0 {
+		delete(self.wd_to_path, wd)
+		if self.path_to_wd[path] == wd {
+			delete(self.path_to_wd, path)
+		}
+	}
+	return
+}
+
+func (self *inotify_backend) read_loop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := self.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				self.on_error(fmt.Errorf("Failed to read from inotify with error: %w", err))
+			}
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name_start := offset + unix.SizeofInotifyEvent
+			offset = name_start + int(ev.Len)
+			if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+				self.on_error(fmt.Errorf("Too many changes to watch, some changes were missed"))
+				continue
+			}
+			path, found := self.path_for(ev.Wd, ev.Mask)
+			if !found {
+				continue
+			}
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				// the watched file was deleted or its file system unmounted
+				self.dropped(path)
+				continue
+			}
+			if ev.Len > 0 {
+				name := bytes.TrimRight(buf[name_start:min(offset, n)], "\x00")
+				path = filepath.Join(path, string(name))
+			}
+			self.emit(path, ev.Mask&unix.IN_ISDIR != 0 && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0)
+		}
+	}
+}
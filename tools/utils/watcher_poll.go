@@ -0,0 +1,159 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+// Changes made by other computers to files on network file systems are not
+// reported by inotify or kqueue, so paths on them are polled instead
+const watcher_poll_interval = time.Second
+
+type poll_state struct {
+	mtime, size int64
+	mode        fs.FileMode
+	// detects files replaced by atomic saves, which can have the same size
+	// and, as timestamps are coarse, modification time
+	ino uint64
+}
+
+func poll_state_of(s fs.FileInfo) poll_state {
+	ans := poll_state{mtime: s.ModTime().UnixNano(), size: s.Size(), mode: s.Mode()}
+	if st, ok := inode_of(s); ok {
+		ans.ino = st.ino
+	}
+	return ans
+}
+
+type poll_watch struct {
+	is_dir   bool
+	state    poll_state
+	children map[string]poll_state
+}
+
+func poll_snapshot(path string, is_dir bool) (*poll_watch, error) {
+	s, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if s.IsDir() != is_dir {
+		return nil, fmt.Errorf("%s is no longer a %s", path, IfElse(is_dir, "directory", "file"))
+	}
+	ans := &poll_watch{is_dir: is_dir, state: poll_state_of(s)}
+	if is_dir {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		ans.children = make(map[string]poll_state, len(entries))
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil {
+				ans.children[e.Name()] = poll_state_of(info)
+			}
+		}
+	}
+	return ans, nil
+}
+
+type poll_backend struct {
+	mutex      sync.Mutex
+	watches    map[string]*poll_watch
+	emit       func(string, bool)
+	dropped    func(string)
+	closed     chan struct{}
+	close_once sync.Once
+}
+
+func new_poll_backend(interval time.Duration, emit func(string, bool), dropped func(string)) *poll_backend {
+	ans := &poll_backend{watches: make(map[string]*poll_watch), emit: emit, dropped: dropped, closed: make(chan struct{})}
+	go ans.poll_loop(interval)
+	return ans
+}
+
+func (self *poll_backend) add(path string, is_dir bool) error {
+	w, err := poll_snapshot(path, is_dir)
+	if err != nil {
+		return err
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.watches[path] = w
+	return nil
+}
+
+func (self *poll_backend) remove(path string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	delete(self.watches, path)
+}
+
+func (self *poll_backend) close() error {
+	self.close_once.Do(func() { close(self.closed) })
+	return nil
+}
+
+func (self *poll_backend) poll_loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-self.closed:
+			return
+		case <-ticker.C:
+			self.poll()
+		}
+	}
+}
+
+// Report the changes since the last poll the way the other backends do: the
+// changed children of watched directories and changes to watched files, with
+// watches of paths that no longer exist dropped
+func (self *poll_backend) poll() {
+	self.mutex.Lock()
+	watches := maps.Clone(self.watches)
+	self.mutex.Unlock()
+	for path, prev := range watches {
+		cur, err := poll_snapshot(path, prev.is_dir)
+		self.mutex.Lock()
+		if self.watches[path] != prev {
+			// removed or added again since the poll started
+			self.mutex.Unlock()
+			continue
+		}
+		if err != nil {
+			delete(self.watches, path)
+		} else {
+			self.watches[path] = cur
+		}
+		self.mutex.Unlock()
+		switch {
+		case err != nil:
+			self.emit(path, false)
+			self.dropped(path)
+		case !prev.is_dir:
+			if cur.state != prev.state {
+				self.emit(path, false)
+			}
+		default:
+			for name, s := range cur.children {
+				if ps, found := prev.children[name]; !found || ps != s {
+					self.emit(filepath.Join(path, name), !found && s.mode.IsDir())
+				}
+			}
+			for name := range prev.children {
+				if _, found := cur.children[name]; !found {
+					self.emit(filepath.Join(path, name), false)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestWatcher(t *testing.T) {
+	test_watcher(t, false)
+	// as used for paths on network file systems
+	test_watcher(t, true)
+}
+
+func test_watcher(t *testing.T, poll bool) {
+	tdir := t.TempDir()
+	w, err := NewWatcher(20 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if poll {
+		w.poll_interval = 5 * time.Millisecond
+		w.use_polling = func(string) bool { return true }
+	}
+	if err = w.Add(tdir, true); err != nil {
+		t.Fatal(err)
+	}
+	wait_for := func(path string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case changed := <-w.Events:
+				if slices.Contains(changed, path) {
+					return
+				}
+			case err := <-w.Errors:
+				t.Fatal(err)
+			case <-timeout:
+				t.Fatalf("Timed out waiting for a change to: %s", path)
+			}
+		}
+	}
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := filepath.Join(tdir, "a")
+	write(a, "1")
+	wait_for(a)
+	write(a, "2")
+	wait_for(a)
+	sub := filepath.Join(tdir, "sub", "deep")
+	if err = os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wait_for(filepath.Dir(sub))
+	// give the watcher a chance to start watching the new directories
+	time.Sleep(50 * time.Millisecond)
+	b := filepath.Join(sub, "b")
+	write(b, "1")
+	wait_for(b)
+	if err = os.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	wait_for(a)
+	// directories that are deleted and re-created are watched again
+	if err = os.RemoveAll(filepath.Join(tdir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	wait_for(filepath.Join(tdir, "sub"))
+	if err = os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wait_for(filepath.Dir(sub))
+	time.Sleep(50 * time.Millisecond)
+	write(b, "2")
+	wait_for(b)
+	// files replaced by atomic saves are watched again
+	other := filepath.Join(t.TempDir(), "other")
+	write(other, "1")
+	if err = w.Add(other, false); err != nil {
+		t.Fatal(err)
+	}
+	atomic_write := func() {
+		t.Helper()
+		write(other+".tmp", "2")
+		if err = os.Rename(other+".tmp", other); err != nil {
+			t.Fatal(err)
+		}
+		wait_for(other)
+	}
+	atomic_write()
+	time.Sleep(50 * time.Millisecond)
+	atomic_write()
+	// as are files that are deleted and re-created later
+	if err = os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+	wait_for(other)
+	write(other, "3")
+	wait_for(other)
+	time.Sleep(50 * time.Millisecond)
+	write(other, "4")
+	wait_for(other)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range w.Events {
+	}
+}
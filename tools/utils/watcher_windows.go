@@ -0,0 +1,15 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+// Changes are found by polling on Windows, as is done for network file
+// systems on other platforms
+func new_watch_backend(emit func(string, bool), on_error func(error), dropped func(string)) (watch_backend, error) {
+	return new_poll_backend(watcher_poll_interval, emit, dropped), nil
+}
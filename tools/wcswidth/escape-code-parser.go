@@ -5,6 +5,7 @@ package wcswidth
 import (
 	"bytes"
 	"fmt"
+	"unicode/utf8"
 
 	"kitty/tools/utils"
 )
@@ -51,7 +52,12 @@ type EscapeCodeParser struct {
 	ReplaceInvalidUtf8Bytes bool
 
 	// Callbacks
-	HandleRune                func(rune) error
+	HandleRune func(rune) error
+	// Optional, if set, runs of text are passed to it instead of to
+	// HandleRune one rune at a time. The text is valid UTF-8 and is a view
+	// into the data being parsed so it must be copied if it needs to be
+	// retained.
+	HandleText                func([]byte) error
 	HandleEndOfBracketedPaste func() error
 	HandleCSI                 func([]byte) error
 	HandleOSC                 func([]byte) error
@@ -101,12 +107,48 @@ func (self *EscapeCodeParser) ParseByte(b byte) error {
 	return nil
 }
 
+// Return the length of the prefix of data that is valid UTF-8 text that
+// contains no escape codes
+func (self *EscapeCodeParser) text_prefix_len(data []byte) int {
+	in_bp := self.state == bracketed_paste
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < utf8.RuneSelf {
+			if b == 0x1b {
+				return i
+			}
+			i++
+			continue
+		}
+		// C1 control codes start escape codes, but not in bracketed paste
+		if b == 0xc2 && !in_bp {
+			return i
+		}
+		r, sz := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && sz < 2 {
+			return i
+		}
+		i += sz
+	}
+	return len(data)
+}
+
 func (self *EscapeCodeParser) Parse(data []byte) error {
-	for _, b := range data {
-		err := self.ParseByte(b)
-		if err != nil {
+	for len(data) > 0 {
+		if self.HandleText != nil && self.utf8_state == utils.UTF8_ACCEPT && (self.state == normal || (self.state == bracketed_paste && len(self.bracketed_paste_buffer) == 0)) {
+			if n := self.text_prefix_len(data); n > 0 {
+				if err := self.HandleText(data[:n]); err != nil {
+					self.reset_state()
+					return err
+				}
+				data = data[n:]
+				continue
+			}
+		}
+		if err := self.ParseByte(data[0]); err != nil {
 			return err
 		}
+		data = data[1:]
 	}
 	return nil
 }
@@ -3,9 +3,16 @@
 package wcswidth
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
 )
 
+var _ = fmt.Print
+
 func TestEscapeCodeParsing(t *testing.T) {
 	type test_parse_collection struct {
 		actual, expected string
@@ -55,3 +62,63 @@ func TestEscapeCodeParsing(t *testing.T) {
 	test("\x1b]X\x07\x1b]X\x1b\x07\x1b\\", "OSC: X\nOSC: X\x1b\x07")
 
 }
+
+func events_from_parse(data []byte, use_text bool, split_at int) (ans []string) {
+	add := func(prefix string) func([]byte) error {
+		return func(b []byte) error { ans = append(ans, prefix+": "+string(b)); return nil }
+	}
+	p := EscapeCodeParser{
+		HandleCSI: add("CSI"), HandleOSC: add("OSC"), HandleDCS: add("DCS"), HandleSOS: add("SOS"), HandlePM: add("PM"), HandleAPC: add("APC"),
+		HandleRune:                func(r rune) error { ans = append(ans, "CH: "+string(r)); return nil },
+		HandleEndOfBracketedPaste: func() error { ans = append(ans, "END_PASTE"); return nil },
+		ReplaceInvalidUtf8Bytes:   true,
+	}
+	if use_text {
+		p.HandleText = func(text []byte) error {
+			if !utf8.Valid(text) {
+				panic(fmt.Sprintf("HandleText called with invalid UTF-8: %#v", string(text)))
+			}
+			for _, r := range string(text) {
+				ans = append(ans, "CH: "+string(r))
+			}
+			return nil
+		}
+	}
+	split_at = max(0, min(split_at, len(data)))
+	_ = p.Parse(data[:split_at])
+	_ = p.Parse(data[split_at:])
+	return
+}
+
+func FuzzEscapeCodeParser(f *testing.F) {
+	for _, seed := range []string{
+		"ab\nc", "a\x1b[200m\x1b[mb", "\x1b[200~a\x1b[201m\xc2\x9b\x1b[201~\x1b[x", "a\x1bPb\x1b\x1bc\x1b\\d",
+		"\x1b]X\x07\x1b]X\x1b\x07\x1b\\", "\xc2\x9bm\xc2\xa0x", "a\xff\xe2\x82b\xe2\x82\xac", "\x1b[200~\xe2\x82\xac\x1b[20\x1b[201~",
+	} {
+		f.Add([]byte(seed), 3)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, split_at int) {
+		expected := events_from_parse(data, false, split_at)
+		if diff := cmp.Diff(expected, events_from_parse(data, true, split_at)); diff != "" {
+			t.Fatalf("Parsing %#v with text runs differs from parsing one rune at a time:\n%s", string(data), diff)
+		}
+	})
+}
+
+func BenchmarkEscapeCodeParser(b *testing.B) {
+	data := []byte(strings.Repeat("Some pasted text with ünïcödé in it and \x1b[31mescape codes\x1b[m\n", 1024))
+	p := EscapeCodeParser{HandleRune: func(rune) error { return nil }}
+	b.Run("runes", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			_ = p.Parse(data)
+		}
+	})
+	p.HandleText = func([]byte) error { return nil }
+	b.Run("text", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			_ = p.Parse(data)
+		}
+	})
+}
@@ -77,7 +77,7 @@ func (self *WCWidthIterator) handle_rune(ch rune) error {
 	case normal:
 		switch ch {
 		case 0xfe0f:
-			if IsEmojiPresentationBase(self.prev_ch) && self.prev_width == 1 {
+			if IsEmojiPresentationBase(self.prev_ch) && self.prev_width == 1 && !ignore_vs16() {
 				self.current_width += 1
 				self.prev_width = 2
 			} else {
@@ -94,17 +94,9 @@ func (self *WCWidthIterator) handle_rune(ch rune) error {
 			if IsFlagCodepoint(ch) {
 				self.state = flag_pair_started
 			}
-			w := Runewidth(ch)
-			switch w {
-			case -1:
-			case 0:
-				self.prev_width = 0
-			case 2:
-				self.prev_width = 2
-			default:
-				self.prev_width = 1
-			}
-			self.current_width += self.prev_width
+			w := TerminalRunewidth(ch)
+			self.prev_width = w
+			self.current_width += w
 		}
 	}
 	self.prev_ch = ch
@@ -131,6 +123,14 @@ func Stringwidth(text string) int {
 	return w.Parse(utils.UnsafeStringToBytes(text))
 }
 
+var width_cache = utils.NewLRU[string, int](4096)
+
+// Like Stringwidth, but re-uses the widths of recently seen strings, for
+// strings whose width is needed every time the screen is redrawn
+func CachedStringwidth(text string) int {
+	return width_cache.MustGetOrCreate(text, Stringwidth)
+}
+
 func StripEscapeCodes(text string) string {
 	out := strings.Builder{}
 	out.Grow(len(text))
@@ -14,6 +14,11 @@ func TestWCSWidth(t *testing.T) {
 		if w := Stringwidth(text); w != expected {
 			t.Fatalf("The width for %#v was %d instead of %d", text, w, expected)
 		}
+		for i := 0; i < 2; i++ {
+			if w := CachedStringwidth(text); w != expected {
+				t.Fatalf("The cached width for %#v was %d instead of %d", text, w, expected)
+			}
+		}
 	}
 	wcwidth := func(text string, widths ...int) {
 		for i, q := range []rune(text) {
@@ -36,6 +41,10 @@ func TestWCSWidth(t *testing.T) {
 	wcswidth("\U0001F1E6\U0001F1E8a", 3)
 	wcswidth("\U0001F1E6\U0001F1E8\U0001F1E6", 4)
 	wcswidth("a\u00adb", 2)
+	// non-printable characters have no width, regardless of the preceding character
+	wcswidth("\u4e2d\x7f", 2)
+	wcswidth("a\u0085b", 2)
+	wcswidth("\u4e2d\x7fb", 3)
 	wcswidth("a\x1b[22bcd", 25)
 	// Flags individually and together
 	wcwidth("\U0001f1ee\U0001f1f3", 2, 2)
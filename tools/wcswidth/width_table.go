@@ -0,0 +1,230 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package wcswidth
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var _ = fmt.Print
+
+// Change when the meaning of the fields in WidthTable or its serialization changes
+const width_table_format_version = 1
+
+// The widths the terminal being used assigns to characters, where those
+// differ from the built in width database. Terminals disagree on the widths
+// of East Asian ambiguous width characters and emoji, particularly emoji
+// added in recent versions of Unicode, so text measured with the built in
+// database can be misaligned, for example, over SSH in a different terminal.
+type WidthTable struct {
+	// The Unicode version of the built in database the table was created
+	// against. Tables created against other versions are invalid as the
+	// overrides are relative to the database.
+	DatabaseVersion [3]int
+	// The width of East Asian ambiguous width characters, 1 or 2
+	AmbiguousWidth int
+	// The width of private use characters, 1 or 2
+	PrivateUseWidth int
+	// Whether the emoji presentation selector, U+FE0F, is ignored instead
+	// of making text presentation emoji wide
+	IgnoreVS16 bool
+	// Widths of individual characters that differ from the database
+	Overrides map[rune]int
+}
+
+var session_width_table atomic.Pointer[WidthTable]
+
+// Use the specified table for all width calculations in this process, for
+// example, by Stringwidth() and TruncateToVisualLength(). Use nil to go back
+// to using only the built in database.
+func SetWidthTable(t *WidthTable) {
+	session_width_table.Store(t)
+}
+
+// The table set by SetWidthTable() or nil if none
+func CurrentWidthTable() *WidthTable { return session_width_table.Load() }
+
+// The width of ch according to the table, one of -1, 0, 1 or 2 with -1 being
+// used for non-printable characters
+func (self *WidthTable) Runewidth(ch rune) int {
+	if w, found := self.Overrides[ch]; found {
+		return w
+	}
+	w := Runewidth(ch)
+	switch w {
+	case -1, 0, 1, 2:
+		return w
+	case -2:
+		return self.AmbiguousWidth
+	case -3:
+		return self.PrivateUseWidth
+	}
+	return 1
+}
+
+func builtin_table() WidthTable {
+	return WidthTable{DatabaseVersion: UnicodeDatabaseVersion, AmbiguousWidth: 1, PrivateUseWidth: 1}
+}
+
+// The number of cells ch occupies in the terminal, using the table set by
+// SetWidthTable(), if any
+func TerminalRunewidth(ch rune) int {
+	var w int
+	if t := session_width_table.Load(); t != nil {
+		w = t.Runewidth(ch)
+	} else {
+		w = Runewidth(ch)
+	}
+	switch w {
+	case -1, 0:
+		return 0
+	case 2:
+		return 2
+	}
+	return 1
+}
+
+func ignore_vs16() bool {
+	t := session_width_table.Load()
+	return t != nil && t.IgnoreVS16
+}
+
+// Serialize the table into a compact form suitable for an environment
+// variable, so that child processes in the same session can use it
+func (self *WidthTable) Serialize() string {
+	v := self.DatabaseVersion
+	parts := []string{
+		fmt.Sprintf("v=%d", width_table_format_version), fmt.Sprintf("db=%d.%d.%d", v[0], v[1], v[2]),
+		fmt.Sprintf("a=%d", self.AmbiguousWidth), fmt.Sprintf("p=%d", self.PrivateUseWidth),
+	}
+	if self.IgnoreVS16 {
+		parts = append(parts, "vs16=0")
+	}
+	if len(self.Overrides) > 0 {
+		keys := make([]rune, 0, len(self.Overrides))
+		for ch := range self.Overrides {
+			keys = append(keys, ch)
+		}
+		slices.Sort(keys)
+		o := make([]string, len(keys))
+		for i, ch := range keys {
+			o[i] = fmt.Sprintf("%x:%d", ch, self.Overrides[ch])
+		}
+		parts = append(parts, "o="+strings.Join(o, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Parse a table serialized by WidthTable.Serialize(). Fails for tables
+// created by other versions of this code or against a different version of
+// the width database.
+func ParseWidthTable(s string) (*WidthTable, error) {
+	ans := builtin_table()
+	ans.DatabaseVersion = [3]int{}
+	format_version := 0
+	width := func(x string) (int, error) {
+		w, err := strconv.Atoi(x)
+		if err != nil || w < 0 || w > 2 {
+			return 0, fmt.Errorf("Invalid width: %#v", x)
+		}
+		return w, nil
+	}
+	for _, part := range strings.Split(s, ";") {
+		key, val, _ := strings.Cut(part, "=")
+		var err error
+		switch key {
+		case "v":
+			format_version, err = strconv.Atoi(val)
+		case "db":
+			_, err = fmt.Sscanf(val, "%d.%d.%d", &ans.DatabaseVersion[0], &ans.DatabaseVersion[1], &ans.DatabaseVersion[2])
+		case "a":
+			ans.AmbiguousWidth, err = width(val)
+		case "p":
+			ans.PrivateUseWidth, err = width(val)
+		case "vs16":
+			ans.IgnoreVS16 = val == "0"
+		case "o":
+			ans.Overrides = make(map[rune]int)
+			for _, o := range strings.Split(val, ",") {
+				c, w, _ := strings.Cut(o, ":")
+				var ch uint64
+				if ch, err = strconv.ParseUint(c, 16, 32); err == nil {
+					ans.Overrides[rune(ch)], err = width(w)
+				}
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid width table entry: %#v with error: %w", part, err)
+		}
+	}
+	if format_version != width_table_format_version {
+		return nil, fmt.Errorf("Unsupported width table format version: %d", format_version)
+	}
+	if ans.DatabaseVersion != UnicodeDatabaseVersion {
+		return nil, fmt.Errorf("Width table is for a different version of the width database: %v", ans.DatabaseVersion)
+	}
+	return &ans, nil
+}
+
+type width_probe struct {
+	text string
+	// the character whose width is overridden if the measured width differs
+	ch rune
+}
+
+// ambiguous width, private use and VS16 probes first, then CJK as a sanity
+// check, then emoji from successively newer Unicode versions
+var width_probes = []width_probe{
+	{"○", 0}, {"", 0}, {"❤️", 0}, {"一", 0},
+	{"\U0001f600", 0x1f600}, {"\U0001f923", 0x1f923}, {"\U0001f97a", 0x1f97a},
+	{"\U0001fa70", 0x1fa70}, {"\U0001fae0", 0x1fae0}, {"\U0001fae8", 0x1fae8},
+}
+
+// The strings whose widths the terminal must be queried for, to create a table with WidthTableFromProbes()
+func WidthProbes() []string {
+	ans := make([]string, len(width_probes))
+	for i, p := range width_probes {
+		ans[i] = p.text
+	}
+	return ans
+}
+
+// Create a table from the widths the terminal reported for the strings
+// returned by WidthProbes(). Returns nil if the reported widths are not
+// plausible, for instance, because the terminal does not support cursor
+// position reports properly.
+func WidthTableFromProbes(widths []int) *WidthTable {
+	if len(widths) != len(width_probes) || widths[3] != 2 {
+		return nil
+	}
+	for _, w := range widths {
+		if w < 1 || w > 2 {
+			return nil
+		}
+	}
+	ans := builtin_table()
+	ans.AmbiguousWidth, ans.PrivateUseWidth = widths[0], widths[1]
+	ans.IgnoreVS16 = widths[2] == 1
+	for i, p := range width_probes {
+		if p.ch != 0 && widths[i] != Runewidth(p.ch) {
+			if ans.Overrides == nil {
+				ans.Overrides = make(map[rune]int)
+			}
+			ans.Overrides[p.ch] = widths[i]
+		}
+	}
+	return &ans
+}
+
+// Whether the table makes no difference to widths
+func (self *WidthTable) IsBuiltin() bool {
+	b := builtin_table()
+	return self.DatabaseVersion == b.DatabaseVersion && self.AmbiguousWidth == b.AmbiguousWidth && self.PrivateUseWidth == b.PrivateUseWidth && !self.IgnoreVS16 && len(self.Overrides) == 0
+}
@@ -0,0 +1,65 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package wcswidth
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestWidthTable(t *testing.T) {
+	defer SetWidthTable(nil)
+	builtin := make([]int, len(width_probes))
+	for i, p := range width_probes {
+		builtin[i] = Stringwidth(p.text)
+	}
+	if diff := cmp.Diff([]int{1, 1, 2, 2, 2, 2, 2, 2, 2, 2}, builtin); diff != "" {
+		t.Fatalf("Unexpected builtin widths for the probes:\n%s", diff)
+	}
+	if tb := WidthTableFromProbes(builtin); tb == nil || !tb.IsBuiltin() {
+		t.Fatalf("The builtin widths did not result in the builtin table: %#v", tb)
+	}
+	for _, bad := range [][]int{nil, {1, 1, 2, 1, 2, 2, 2, 2, 2, 2}, {1, 1, 2, 2, 2, 2, 2, 2, 2, -1}} {
+		if tb := WidthTableFromProbes(bad); tb != nil {
+			t.Fatalf("Implausible widths: %v resulted in a table", bad)
+		}
+	}
+
+	// A terminal with wide ambiguous characters, no VS16 support and an
+	// older width database
+	tb := WidthTableFromProbes([]int{2, 1, 1, 2, 2, 2, 2, 2, 1, 1})
+	q := *tb
+	q.Overrides = nil
+	if diff := cmp.Diff(WidthTable{DatabaseVersion: UnicodeDatabaseVersion, AmbiguousWidth: 2, PrivateUseWidth: 1, IgnoreVS16: true}, q); diff != "" {
+		t.Fatalf("Unexpected width table:\n%s", diff)
+	}
+	if diff := cmp.Diff(map[rune]int{0x1fae0: 1, 0x1fae8: 1}, tb.Overrides); diff != "" {
+		t.Fatalf("Unexpected width overrides:\n%s", diff)
+	}
+	rt, err := ParseWidthTable(tb.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(tb, rt); diff != "" {
+		t.Fatalf("Serialization round trip failed for %#v:\n%s", tb.Serialize(), diff)
+	}
+	for _, bad := range []string{"", "v=1;db=1.0.0;a=1;p=1", "v=0;db=15.0.0", fmt.Sprintf("v=1;db=%d.%d.%d;a=3", UnicodeDatabaseVersion[0], UnicodeDatabaseVersion[1], UnicodeDatabaseVersion[2])} {
+		if _, err := ParseWidthTable(bad); err == nil {
+			t.Fatalf("Parsing invalid width table: %#v did not fail", bad)
+		}
+	}
+
+	SetWidthTable(tb)
+	for text, expected := range map[string]int{"○a": 3, "❤️": 1, "\U0001fae0x": 2, "一\U0001f600": 4, "\u25b6\ufe0f": 1} {
+		if w := Stringwidth(text); w != expected {
+			t.Fatalf("The width for %#v with the table was %d instead of %d", text, w, expected)
+		}
+	}
+	if diff := cmp.Diff("a○", TruncateToVisualLength("a○bc", 3)); diff != "" {
+		t.Fatalf("Truncation did not use the table:\n%s", diff)
+	}
+}